@@ -0,0 +1,164 @@
+package urlshort
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogFormat is the line format of WithAccessLog
+type LogFormat int
+
+const (
+	// CommonLog is the Common Log Format of web servers:
+	//
+	//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /go HTTP/1.1" 302 49
+	CommonLog LogFormat = iota
+	// CombinedLog is CommonLog followed by the quoted referer and
+	// user agent
+	CombinedLog
+)
+
+// clfTime is the time layout of the Common Log Format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// WithAccessLog writes a line in format to w for every request, with
+// the status and size of the redirect or of whatever the fallback
+// wrote. Lines are written whole, concurrent requests never
+// interleave them.
+func WithAccessLog(w io.Writer, format LogFormat) Option {
+	return func(c *config) {
+		c.accessLog = &accessLog{out: bufio.NewWriter(w), format: format}
+	}
+}
+
+// accessLog formats the lines and writes them one at a time
+type accessLog struct {
+	mu     sync.Mutex
+	out    *bufio.Writer
+	format LogFormat
+}
+
+// wrap returns a writer that remembers the status and size of the
+// response written through it
+func (l *accessLog) wrap(w http.ResponseWriter) *logWriter {
+	return &logWriter{ResponseWriter: w}
+}
+
+// log writes the line for r, start is when the request came in
+func (l *accessLog) log(r *http.Request, lw *logWriter, start time.Time) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if name, _, ok := r.BasicAuth(); ok && name != "" {
+		user = clfEscape(name)
+	}
+	status := lw.status
+	if status == 0 {
+		// nothing was written, net/http sends a 200
+		status = http.StatusOK
+	}
+	size := "-"
+	if lw.size > 0 {
+		size = fmt.Sprint(lw.size)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, "%s - %s [%s] \"%s %s %s\" %d %s",
+		host, user, start.Format(clfTime),
+		clfEscape(r.Method), clfEscape(r.RequestURI), clfEscape(r.Proto), status, size)
+	if l.format == CombinedLog {
+		fmt.Fprintf(l.out, " \"%s\" \"%s\"", clfField(r.Referer()), clfField(r.UserAgent()))
+	}
+	l.out.WriteByte('\n')
+	// the buffer only makes each line a single write
+	l.out.Flush()
+}
+
+// clfEscape keeps quotes and control characters of s from breaking
+// up the line
+func clfEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// clfField is s escaped, or - if it is empty
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return clfEscape(s)
+}
+
+// logWriter records the status and size of a response
+type logWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (lw *logWriter) WriteHeader(status int) {
+	if lw.status == 0 {
+		lw.status = status
+	}
+	lw.ResponseWriter.WriteHeader(status)
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	if lw.status == 0 {
+		lw.status = http.StatusOK
+	}
+	n, err := lw.ResponseWriter.Write(p)
+	lw.size += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the original writer
+func (lw *logWriter) Unwrap() http.ResponseWriter {
+	return lw.ResponseWriter
+}
+
+// writer returns lw with the http.Flusher and http.Hijacker of the
+// original writer, so proxies and streaming fallbacks keep working
+func (lw *logWriter) writer() http.ResponseWriter {
+	f, flusher := lw.ResponseWriter.(http.Flusher)
+	h, hijacker := lw.ResponseWriter.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return struct {
+			*logWriter
+			http.Flusher
+			http.Hijacker
+		}{lw, f, h}
+	case flusher:
+		return struct {
+			*logWriter
+			http.Flusher
+		}{lw, f}
+	case hijacker:
+		return struct {
+			*logWriter
+			http.Hijacker
+		}{lw, h}
+	}
+	return lw
+}
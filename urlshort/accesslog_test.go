@@ -0,0 +1,167 @@
+package urlshort
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const accessLogEntries = `
+- path: /gh
+  url: https://github.com
+- path: /secret
+  url: https://example.com/secret
+  token: hunter2
+`
+
+// logClock is the time every logged request comes in at
+var logClock = func() time.Time { return time.Date(2025, 3, 1, 13, 55, 36, 0, time.FixedZone("", -7*3600)) }
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name   string
+		format LogFormat
+		target string
+		header http.Header
+		user   string
+		want   string
+	}{
+		{"redirect", CommonLog, "/gh", nil, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /gh HTTP/1.1" 302 41`},
+		{"miss", CommonLog, "/missing?q=1", nil, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /missing?q=1 HTTP/1.1" 404 19`},
+		{"basic auth user", CommonLog, "/gh", nil, "nils",
+			`198.51.100.1 - nils [01/Mar/2025:13:55:36 -0700] "GET /gh HTTP/1.1" 302 41`},
+		{"combined", CombinedLog, "/gh", http.Header{"Referer": {"https://mail.example.com/"}, "User-Agent": {"curl/8.0"}}, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /gh HTTP/1.1" 302 41 "https://mail.example.com/" "curl/8.0"`},
+		{"combined without headers", CombinedLog, "/gh", nil, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /gh HTTP/1.1" 302 41 "-" "-"`},
+		{"quotes escaped", CombinedLog, "/gh", http.Header{"User-Agent": {`evil" 200 0 "x`}}, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /gh HTTP/1.1" 302 41 "-" "evil\" 200 0 \"x"`},
+		// the token never ends up in the log
+		{"token", CommonLog, "/secret?t=hunter2&utm_source=mail", nil, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /secret?utm_source=mail HTTP/1.1" 302 49`},
+		{"wrong token", CommonLog, "/secret?t=hunter3", nil, "",
+			`198.51.100.1 - - [01/Mar/2025:13:55:36 -0700] "GET /secret HTTP/1.1" 404 19`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h, err := YAMLHandler([]byte(accessLogEntries), http.NotFoundHandler(), WithAccessLog(&buf, tt.format), WithClock(logClock))
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.RemoteAddr = "198.51.100.1:5000"
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, "password")
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got := buf.String(); got != tt.want+"\n" {
+				t.Errorf("got  %q\nwant %q", got, tt.want+"\n")
+			}
+		})
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that can be hijacked
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestAccessLogWriter(t *testing.T) {
+	// the fallback streams and takes over connections through the
+	// writer of the log
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("the fallback got no http.Flusher")
+			return
+		}
+		w.Write([]byte("part"))
+		f.Flush()
+		if h, ok := w.(http.Hijacker); ok {
+			h.Hijack()
+		}
+	})
+	var buf bytes.Buffer
+	h, err := YAMLHandler([]byte(accessLogEntries), fallback, WithAccessLog(&buf, CommonLog))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	// a miss and an entry that passes the request on
+	for _, target := range []string{"/missing", "/secret"} {
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if !rec.Flushed || !rec.hijacked {
+			t.Errorf("GET %s: flushed %v, hijacked %v", target, rec.Flushed, rec.hijacked)
+		}
+		// a writer without Hijack doesn't grow one
+		plain := httptest.NewRecorder()
+		h.ServeHTTP(plain, httptest.NewRequest(http.MethodGet, target, nil))
+		if !plain.Flushed || plain.Body.String() != "part" {
+			t.Errorf("GET %s: flushed %v, body %q", target, plain.Flushed, plain.Body.String())
+		}
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("got %d lines:\n%s", lines, buf.String())
+	}
+	if !strings.Contains(buf.String(), `"GET /missing HTTP/1.1" 200 4`) {
+		t.Errorf("the streamed response isn't logged with its size:\n%s", buf.String())
+	}
+}
+
+func TestAccessLogConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	h, err := YAMLHandler([]byte(accessLogEntries), urlshorttest.Fallback(), WithAccessLog(&buf, CombinedLog))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	const n = 200
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/gh?n=%d", i), nil)
+			req.Header.Set("User-Agent", strings.Repeat("x", 2000))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	line := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /gh\?n=(\d+) HTTP/1\.1" 302 \d+ "-" "(x+)"$`)
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		m := line.FindStringSubmatch(l)
+		if m == nil {
+			t.Fatalf("a broken line: %.100q", l)
+		}
+		if len(m[2]) != 2000 {
+			t.Fatalf("a line with a user agent of %d bytes", len(m[2]))
+		}
+		seen[m[1]] = true
+	}
+	if len(seen) != n {
+		t.Errorf("got %d distinct lines, want %d", len(seen), n)
+	}
+}
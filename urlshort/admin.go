@@ -0,0 +1,248 @@
+package urlshort
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// AdminHandler returns an http.Handler with a small JSON API to
+// manage the mappings in store at runtime. It expects to be mounted
+// under /api/:
+//
+//	GET    /api/paths         list all mappings
+//	POST   /api/paths         create {"path": "/x", "url": "https://..."}
+//	PUT    /api/paths/{path}  create or replace {"url": "https://..."}
+//	DELETE /api/paths/{path}  delete a mapping
+//	POST   /api/paths/{path}/disable
+//	                          make a mapping act as missing, keeping
+//	                          it, /enable undoes that
+//	POST   /api/shorten       mint a short path {"url": "https://..."}
+//	GET    /api/reverse?url=  paths pointing at url, add prefix=true
+//	                          to match every url starting with it
+//	GET    /api/export        all mappings as YAML, add format=json
+//	                          for JSON
+//	GET    /api/stats/stale   paths not hit in the last 90 days,
+//	                          days= changes the window
+//	POST   /api/backup        all mappings and stats as one JSON
+//	                          document
+//	POST   /api/restore       restore a backup, mode=replace drops
+//	                          the other mappings, mode=merge keeps
+//	                          them
+//
+// {path} is the mapped path without its leading slash, e.g.
+// PUT /api/paths/docs/api changes the mapping for /docs/api.
+// Creating a path that exists answers 409 Conflict, deleting one
+// that doesn't answers 404 Not Found.
+//
+// Serve the same store with NewMutableHandler to redirect using it.
+// The stale report needs it to be served WithStats. The API is
+// open to everyone who can reach it unless WithAdminToken is given.
+func AdminHandler(store *MutableStore, opts ...AdminOption) http.Handler {
+	a := &admin{store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/paths", a.list)
+	mux.HandleFunc("POST /api/paths", a.create)
+	mux.HandleFunc("PUT /api/paths/{path...}", a.put)
+	mux.HandleFunc("DELETE /api/paths/{path...}", a.delete)
+	mux.HandleFunc("POST /api/paths/{path...}", a.toggle)
+	mux.HandleFunc("POST /api/shorten", a.shorten)
+	mux.HandleFunc("GET /api/reverse", a.reverse)
+	mux.HandleFunc("GET /api/export", a.export)
+	mux.HandleFunc("GET /api/stats/stale", a.stale)
+	mux.HandleFunc("POST /api/backup", a.backup)
+	mux.HandleFunc("POST /api/restore", a.restore)
+	return protect(mux, opts)
+}
+
+// AdminOption changes how AdminHandler, StatsHandler and
+// InspectHandler let requests in
+type AdminOption func(*adminConfig)
+
+type adminConfig struct {
+	token   string
+	origins []string
+}
+
+// WithAdminToken only lets in requests with an
+// "Authorization: Bearer token" header, everyone else gets 401
+// Unauthorized
+func WithAdminToken(token string) AdminOption {
+	return func(c *adminConfig) {
+		c.token = token
+	}
+}
+
+// protect wraps handler with the checks of opts. CORS comes first,
+// preflight requests don't carry the token.
+func protect(handler http.Handler, opts []AdminOption) http.Handler {
+	var ac adminConfig
+	for _, opt := range opts {
+		opt(&ac)
+	}
+	if ac.token != "" {
+		handler = requireToken(handler, ac.token)
+	}
+	if len(ac.origins) > 0 {
+		handler = withCORS(handler, ac.origins)
+	}
+	return handler
+}
+
+// requireToken only lets in requests with the bearer token
+func requireToken(handler http.Handler, token string) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or wrong token")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type admin struct {
+	store *MutableStore
+}
+
+func (a *admin) list(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.store.entryList())
+}
+
+func (a *admin) create(w http.ResponseWriter, r *http.Request) {
+	var pu pathUrl
+	if err := json.NewDecoder(r.Body).Decode(&pu); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	err := a.store.Add(pu.Path, pu.URL)
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, pu)
+	}
+}
+
+func (a *admin) put(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	pu := pathUrl{Path: "/" + r.PathValue("path"), URL: body.URL}
+	if err := a.store.Set(pu.Path, pu.URL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, pu)
+}
+
+func (a *admin) delete(w http.ResponseWriter, r *http.Request) {
+	path := "/" + r.PathValue("path")
+	if !a.store.Remove(path) {
+		writeError(w, http.StatusNotFound, "no mapping for "+path)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toggle serves POST /api/paths/{path}/disable and /enable, the
+// pattern can't have anything after {path...}
+func (a *admin) toggle(w http.ResponseWriter, r *http.Request) {
+	rest := r.PathValue("path")
+	var path string
+	var ok bool
+	switch {
+	case strings.HasSuffix(rest, "/disable"):
+		path = "/" + strings.TrimSuffix(rest, "/disable")
+		ok = a.store.Disable(path)
+	case strings.HasSuffix(rest, "/enable"):
+		path = "/" + strings.TrimSuffix(rest, "/enable")
+		ok = a.store.Enable(path)
+	default:
+		writeError(w, http.StatusNotFound, "use /disable or /enable")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "no mapping for "+path)
+		return
+	}
+	pu, _ := a.store.get(path)
+	writeJSON(w, http.StatusOK, pu)
+}
+
+func (a *admin) shorten(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	path, err := a.store.Shorten(body.URL)
+	switch {
+	case errors.Is(err, ErrNoFreeCode):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, pathUrl{Path: path, URL: body.URL})
+	}
+}
+
+func (a *admin) reverse(w http.ResponseWriter, r *http.Request) {
+	dest := r.URL.Query().Get("url")
+	if dest == "" {
+		writeError(w, http.StatusBadRequest, "missing url parameter")
+		return
+	}
+
+	var paths []string
+	if r.URL.Query().Get("prefix") == "true" {
+		paths = a.store.ReverseLookupPrefix(dest)
+	} else {
+		paths = a.store.ReverseLookup(dest)
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"paths": paths})
+}
+
+func (a *admin) export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = FormatYAML
+	}
+	data, err := a.store.Export(format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", exportContentType(format))
+	w.Write(data)
+}
+
+// writeJSON sends v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends {"error": msg} with the given status
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
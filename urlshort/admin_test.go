@@ -0,0 +1,148 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// call records the response of h to a request with a JSON body,
+// body may be empty
+func call(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestAdminHandlerPaths(t *testing.T) {
+	store := NewMutableStore()
+	if err := store.Add("/gh", "https://github.com"); err != nil {
+		t.Fatal(err)
+	}
+	admin := AdminHandler(store)
+	serve := NewMutableHandler(store, urlshorttest.Fallback())
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"create", http.MethodPost, "/api/paths", `{"path": "/go", "url": "https://go.dev"}`, http.StatusCreated},
+		{"create duplicate", http.MethodPost, "/api/paths", `{"path": "/go", "url": "https://go.dev/doc"}`, http.StatusConflict},
+		{"create relative url", http.MethodPost, "/api/paths", `{"path": "/rel", "url": "/somewhere"}`, http.StatusBadRequest},
+		{"create bad path", http.MethodPost, "/api/paths", `{"path": "nope", "url": "https://go.dev"}`, http.StatusBadRequest},
+		{"create invalid json", http.MethodPost, "/api/paths", `{"path": `, http.StatusBadRequest},
+		{"replace", http.MethodPut, "/api/paths/gh", `{"url": "https://github.com/nils"}`, http.StatusOK},
+		{"put new", http.MethodPut, "/api/paths/docs/api", `{"url": "https://docs.example.com/api"}`, http.StatusOK},
+		{"put bad url", http.MethodPut, "/api/paths/gh", `{"url": "::"}`, http.StatusBadRequest},
+		{"delete", http.MethodDelete, "/api/paths/go", "", http.StatusNoContent},
+		{"delete unknown", http.MethodDelete, "/api/paths/go", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		res := call(admin, tt.method, tt.target, tt.body)
+		if res.Code != tt.want {
+			t.Errorf("%s: %s %s got %d, want %d: %s", tt.name, tt.method, tt.target, res.Code, tt.want, res.Body)
+		}
+		if ct := res.Header().Get("Content-Type"); res.Code != http.StatusNoContent && ct != "application/json" {
+			t.Errorf("%s: Content-Type %q", tt.name, ct)
+		}
+	}
+
+	// the lookups see every change
+	urlshorttest.TableTest(t, serve, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/docs/api", Location: "https://docs.example.com/api", Code: http.StatusFound},
+		{Path: "/go"},
+		{Path: "/rel"},
+	})
+
+	res := call(admin, http.MethodGet, "/api/paths", "")
+	var list []struct {
+		Path string `json:"path"`
+		URL  string `json:"url"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &list); err != nil {
+		t.Fatalf("GET /api/paths: %v: %s", err, res.Body)
+	}
+	got := fmt.Sprint(list)
+	if want := "[{/docs/api https://docs.example.com/api} {/gh https://github.com/nils}]"; got != want {
+		t.Errorf("GET /api/paths: got %s, want %s", got, want)
+	}
+}
+
+func TestAdminHandlerConcurrent(t *testing.T) {
+	store := NewMutableStore()
+	admin := AdminHandler(store)
+	serve := NewMutableHandler(store, urlshorttest.Fallback())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				path := fmt.Sprintf("/p%d-%d", i, j)
+				call(admin, http.MethodPost, "/api/paths", fmt.Sprintf(`{"path": %q, "url": "https://example.com%s"}`, path, path))
+				call(admin, http.MethodGet, "/api/paths", "")
+				if j%2 == 0 {
+					call(admin, http.MethodDelete, "/api/paths"+path, "")
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				get(serve, fmt.Sprintf("/p%d-%d", i, j))
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(store.Routes()); n != 4*25 {
+		t.Errorf("got %d mappings, want %d", n, 4*25)
+	}
+}
+
+func TestAdminDisable(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/docs/go", "https://go.dev/doc")
+	admin := AdminHandler(store)
+	h := NewMutableHandler(store, urlshorttest.Fallback())
+
+	res := call(admin, http.MethodPost, "/api/paths/docs/go/disable", "")
+	var pu pathUrl
+	if err := json.Unmarshal(res.Body.Bytes(), &pu); err != nil || res.Code != http.StatusOK || !pu.Disabled || pu.URL != "https://go.dev/doc" {
+		t.Fatalf("disable: got %d %s", res.Code, res.Body.String())
+	}
+	urlshorttest.AssertFallback(t, h, "/docs/go")
+	// it stays in the list, marked as disabled
+	if body := call(admin, http.MethodGet, "/api/paths", "").Body.String(); !strings.Contains(body, `"disabled":true`) {
+		t.Errorf("the list has no disabled entry: %s", body)
+	}
+
+	if res := call(admin, http.MethodPost, "/api/paths/docs/go/enable", ""); res.Code != http.StatusOK || strings.Contains(res.Body.String(), "disabled") {
+		t.Errorf("enable: got %d %s", res.Code, res.Body.String())
+	}
+	urlshorttest.AssertRedirect(t, h, "/docs/go", "https://go.dev/doc", http.StatusFound)
+
+	for target, want := range map[string]int{
+		"/api/paths/missing/disable": http.StatusNotFound,
+		"/api/paths/missing/enable":  http.StatusNotFound,
+		"/api/paths/docs/go/toggle":  http.StatusNotFound,
+	} {
+		if res := call(admin, http.MethodPost, target, ""); res.Code != want {
+			t.Errorf("POST %s: got %d, want %d", target, res.Code, want)
+		}
+	}
+}
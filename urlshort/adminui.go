@@ -0,0 +1,260 @@
+package urlshort
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// uiPageSize is how many mappings a page of the admin UI lists
+const uiPageSize = 50
+
+// csrfCookie holds the random value the CSRF tokens of the admin UI
+// are derived from
+const csrfCookie = "urlshort_csrf"
+
+//go:embed adminui
+var adminUIFiles embed.FS
+
+var adminUITemplate = template.Must(template.ParseFS(adminUIFiles, "adminui/index.html"))
+
+// AdminUI returns an http.Handler serving HTML pages to browse,
+// search, create and delete the mappings in store, for people who'd
+// rather not use AdminHandler with curl. Hit counts are shown when
+// the store is served WithStats. It expects to be mounted under a
+// path ending in a slash:
+//
+//	mux.Handle("/admin/", http.StripPrefix("/admin", urlshort.AdminUI(store)))
+//
+// Creating and deleting work like they do in the JSON API. Forms
+// carry a CSRF token bound to a cookie, so other sites can't submit
+// them. With WithAdminToken, browsers are asked for the token as
+// the password of HTTP basic auth, the user name is ignored.
+func AdminUI(store *MutableStore, opts ...AdminOption) http.Handler {
+	var ac adminConfig
+	for _, opt := range opts {
+		opt(&ac)
+	}
+	ui := &adminUI{store: store}
+	if _, err := rand.Read(ui.secret[:]); err != nil {
+		panic(err)
+	}
+
+	static, _ := fs.Sub(adminUIFiles, "adminui")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", ui.index)
+	mux.HandleFunc("POST /create", ui.create)
+	mux.HandleFunc("POST /delete", ui.delete)
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+
+	var handler http.Handler = mux
+	if ac.token != "" {
+		handler = requireBasicToken(handler, ac.token)
+	}
+	return handler
+}
+
+// requireBasicToken only lets in requests with the token as the
+// password of basic auth, browsers can't send bearer tokens
+func requireBasicToken(handler http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, _ := r.BasicAuth()
+		if subtle.ConstantTimeCompare([]byte(password), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="urlshort", charset="UTF-8"`)
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type adminUI struct {
+	store *MutableStore
+	// secret signs the CSRF tokens
+	secret [32]byte
+}
+
+// uiEntry is a row of the table
+type uiEntry struct {
+	Path string
+	// Link is the path as it is requested, with the prefix
+	Link     string
+	URL      string
+	Hits     int64
+	Disabled bool
+}
+
+// uiPage is what adminUITemplate renders
+type uiPage struct {
+	Entries []uiEntry
+	Stats   bool
+	Query   string
+	Page    int
+	Pages   int
+	Total   int
+	// Prev and Next link to the neighbouring pages, empty if there is
+	// none
+	Prev, Next string
+	CSRF       string
+	Error      string
+	// Form keeps what was entered when creating failed
+	Form uiEntry
+}
+
+func (ui *adminUI) index(w http.ResponseWriter, r *http.Request) {
+	ui.render(w, r, http.StatusOK, "", uiEntry{})
+}
+
+func (ui *adminUI) create(w http.ResponseWriter, r *http.Request) {
+	if !ui.checkCSRF(r) {
+		http.Error(w, "invalid CSRF token, reload the page", http.StatusForbidden)
+		return
+	}
+	form := uiEntry{Link: strings.TrimSpace(r.PostFormValue("path")), URL: strings.TrimSpace(r.PostFormValue("url"))}
+	// paths are entered the way they are requested
+	form.Path = form.Link
+	if prefix := ui.store.pathPrefix(); prefix != "" && strings.HasPrefix(form.Link, prefix+"/") {
+		form.Path = strings.TrimPrefix(form.Link, prefix)
+	}
+	err := ui.store.Add(form.Path, form.URL)
+	switch {
+	case errors.Is(err, ErrDuplicate):
+		ui.render(w, r, http.StatusConflict, err.Error(), form)
+	case err != nil:
+		ui.render(w, r, http.StatusBadRequest, err.Error(), form)
+	default:
+		backToIndex(w)
+	}
+}
+
+func (ui *adminUI) delete(w http.ResponseWriter, r *http.Request) {
+	if !ui.checkCSRF(r) {
+		http.Error(w, "invalid CSRF token, reload the page", http.StatusForbidden)
+		return
+	}
+	path := r.PostFormValue("path")
+	if !ui.store.Remove(path) {
+		ui.render(w, r, http.StatusNotFound, "no mapping for "+path, uiEntry{})
+		return
+	}
+	backToIndex(w)
+}
+
+// backToIndex sends the browser to the list after a change. The
+// location stays relative, http.Redirect would resolve it against
+// the path with the mount prefix stripped.
+func backToIndex(w http.ResponseWriter) {
+	w.Header().Set("Location", "./")
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// render writes the page of mappings r asks for, with msg as the
+// error if it isn't empty
+func (ui *adminUI) render(w http.ResponseWriter, r *http.Request, status int, msg string, form uiEntry) {
+	query := strings.TrimSpace(r.FormValue("q"))
+	prefix := ui.store.pathPrefix()
+	var entries []uiEntry
+	for _, pu := range ui.store.entryList() {
+		link := prefix + pu.Path
+		if query == "" || strings.Contains(link, query) || strings.Contains(pu.URL, query) {
+			entries = append(entries, uiEntry{Path: pu.Path, Link: link, URL: pu.URL, Disabled: pu.Disabled})
+		}
+	}
+
+	page := uiPage{Query: query, Total: len(entries), Error: msg, Form: form, CSRF: ui.csrfToken(w, r)}
+	page.Pages = (len(entries) + uiPageSize - 1) / uiPageSize
+	if page.Pages == 0 {
+		page.Pages = 1
+	}
+	page.Page, _ = strconv.Atoi(r.FormValue("page"))
+	if page.Page < 1 || r.Method != http.MethodGet {
+		page.Page = 1
+	}
+	if page.Page > page.Pages {
+		page.Page = page.Pages
+	}
+	start := (page.Page - 1) * uiPageSize
+	end := start + uiPageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	page.Entries = entries[start:end]
+	if page.Page > 1 {
+		page.Prev = pageLink(query, page.Page-1)
+	}
+	if page.Page < page.Pages {
+		page.Next = pageLink(query, page.Page+1)
+	}
+
+	if stats := ui.store.stats.Load(); stats != nil {
+		page.Stats = true
+		hits := stats.hits.snapshot()
+		for i := range page.Entries {
+			page.Entries[i].Hits = hits[page.Entries[i].Path].Hits
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	adminUITemplate.Execute(w, page)
+}
+
+// pageLink is the relative link to page n of the results for query
+func pageLink(query string, n int) string {
+	v := url.Values{"page": {strconv.Itoa(n)}}
+	if query != "" {
+		v.Set("q", query)
+	}
+	return "./?" + v.Encode()
+}
+
+// csrfToken returns the token for the forms of the page, setting
+// the cookie it is bound to if r doesn't have one yet
+func (ui *adminUI) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			panic(err)
+		}
+		c = &http.Cookie{
+			Name:     csrfCookie,
+			Value:    base64.RawURLEncoding.EncodeToString(b[:]),
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		}
+		http.SetCookie(w, c)
+	}
+	return ui.csrfFor(c.Value)
+}
+
+// checkCSRF reports whether the token posted with r belongs to its
+// cookie
+func (ui *adminUI) checkCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(ui.csrfFor(c.Value)))
+}
+
+// csrfFor derives the CSRF token from the cookie value, so a cookie
+// set by someone else is useless without the secret
+func (ui *adminUI) csrfFor(cookie string) string {
+	mac := hmac.New(sha256.New, ui.secret[:])
+	mac.Write([]byte(cookie))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,318 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// uiSession talks to an AdminUI like a browser, keeping its cookie
+type uiSession struct {
+	t      *testing.T
+	h      http.Handler
+	cookie *http.Cookie
+}
+
+var csrfField = regexp.MustCompile(`name="csrf" value="([^"]+)"`)
+
+func (s *uiSession) do(req *http.Request) *httptest.ResponseRecorder {
+	if s.cookie != nil {
+		req.AddCookie(s.cookie)
+	}
+	w := httptest.NewRecorder()
+	s.h.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookie {
+			s.cookie = c
+		}
+	}
+	return w
+}
+
+func (s *uiSession) get(target string) *httptest.ResponseRecorder {
+	return s.do(httptest.NewRequest(http.MethodGet, target, nil))
+}
+
+func (s *uiSession) post(target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return s.do(req)
+}
+
+// csrf loads the index at target and returns the token of its forms
+func (s *uiSession) csrf(target string) string {
+	s.t.Helper()
+	m := csrfField.FindStringSubmatch(s.get(target).Body.String())
+	if m == nil {
+		s.t.Fatal("no CSRF token on the page")
+	}
+	return m[1]
+}
+
+func wantHTML(t *testing.T, w *httptest.ResponseRecorder, code int, fragments ...string) {
+	t.Helper()
+	if w.Code != code {
+		t.Errorf("got %d, want %d: %s", w.Code, code, w.Body.String())
+	}
+	for _, f := range fragments {
+		if !strings.Contains(w.Body.String(), f) {
+			t.Errorf("the page doesn't contain %q", f)
+		}
+	}
+}
+
+func TestAdminUIIndex(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	store.Set("/docs", "https://docs.example.com/?a=1&b=2")
+	store.Disable("/docs")
+	s := &uiSession{t: t, h: AdminUI(store)}
+
+	w := s.get("/")
+	wantHTML(t, w, http.StatusOK,
+		`<td class="path">/gh</td>`,
+		`<a href="https://github.com" rel="noreferrer">https://github.com</a>`,
+		`<tr class="disabled">`,
+		`<a href="https://docs.example.com/?a=1&amp;b=2" rel="noreferrer">`,
+		`<input type="hidden" name="path" value="/gh">`,
+		`Page 1 of 1 &middot; 2 links`,
+	)
+	if w.Header().Get("Content-Type") != "text/html; charset=utf-8" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("got headers %v", w.Header())
+	}
+	// sorted by path
+	if strings.Index(w.Body.String(), "/docs") > strings.Index(w.Body.String(), "/gh") {
+		t.Error("/docs is listed after /gh")
+	}
+	if strings.Contains(w.Body.String(), `class="hits"`) {
+		t.Error("hits are shown without stats")
+	}
+	c := s.cookie
+	if c == nil || !c.HttpOnly || c.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("got CSRF cookie %+v", c)
+	}
+
+	// the assets are embedded
+	w = s.get("/static/style.css")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("style.css: got %d, %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w := s.get("/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("an unknown page: got %d", w.Code)
+	}
+}
+
+func TestAdminUISearchAndPages(t *testing.T) {
+	store := NewMutableStore()
+	for i := 0; i < 2*uiPageSize+10; i++ {
+		store.Set(fmt.Sprintf("/p%03d", i), fmt.Sprintf("https://example.com/%d", i))
+	}
+	store.Set("/gh", "https://github.com/nils")
+	s := &uiSession{t: t, h: AdminUI(store)}
+
+	tests := []struct {
+		target string
+		want   []string
+		absent []string
+	}{
+		// /gh sorts first
+		{"/", []string{"Page 1 of 3 &middot; 111 links", `<a href="./?page=2">Next &rarr;</a>`, "/gh", "/p000", "/p048"},
+			[]string{"Previous", "/p049"}},
+		{"/?page=2", []string{"Page 2 of 3", `<a href="./?page=1">&larr; Previous</a>`, `<a href="./?page=3">`, "/p049", "/p098"},
+			[]string{"/p048", "/p099"}},
+		{"/?page=3", []string{"Page 3 of 3", "/p099", "/p109"}, []string{"Next", "/p098"}},
+		// out of range pages are clamped
+		{"/?page=9", []string{"Page 3 of 3"}, nil},
+		{"/?page=-1", []string{"Page 1 of 3"}, nil},
+		{"/?page=x", []string{"Page 1 of 3"}, nil},
+		// search goes through paths and urls
+		{"/?q=p10", []string{"Page 1 of 1 &middot; 10 links", "/p100", "/p109", `value="p10"`}, []string{"/p010", "/gh"}},
+		{"/?q=github.com%2Fnils", []string{"1 links", "/gh"}, []string{"/p000"}},
+		{"/?q=p0&page=2", []string{"Page 2 of 2", `<a href="./?page=1&amp;q=p0">`}, nil},
+		{"/?q=nothing", []string{"No short links match nothing."}, nil},
+		// the query is escaped, not run
+		{"/?q=%3Cscript%3E", []string{"match &lt;script&gt;."}, []string{"<script>"}},
+	}
+	for _, tt := range tests {
+		w := s.get(tt.target)
+		wantHTML(t, w, http.StatusOK, tt.want...)
+		for _, a := range tt.absent {
+			if strings.Contains(w.Body.String(), a) {
+				t.Errorf("GET %s: the page contains %q", tt.target, a)
+			}
+		}
+	}
+}
+
+func TestAdminUICreate(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	s := &uiSession{t: t, h: AdminUI(store)}
+	csrf := s.csrf("/")
+
+	w := s.post("/create", url.Values{"csrf": {csrf}, "path": {" /go "}, "url": {"https://go.dev"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "./" {
+		t.Errorf("create: got %d to %q, want 303 to ./", w.Code, w.Header().Get("Location"))
+	}
+	if dest, ok := store.Lookup("/go"); !ok || dest != "https://go.dev" {
+		t.Errorf("the store has /go at %q, %v", dest, ok)
+	}
+
+	// failures render the page again with the error and the input
+	tests := []struct {
+		name string
+		path string
+		url  string
+		code int
+		want []string
+	}{
+		{"duplicate", "/gh", "https://gitlab.com", http.StatusConflict, []string{"path already exists: /gh", `value="/gh"`, `value="https://gitlab.com"`}},
+		{"relative path", "gh", "https://gitlab.com", http.StatusBadRequest, []string{`<p class="error">`, `value="gh"`}},
+		{"relative url", "/x", "gitlab.com", http.StatusBadRequest, []string{`<p class="error">`, `value="gitlab.com"`}},
+		{"empty", "", "", http.StatusBadRequest, []string{`<p class="error">`}},
+		{"script url", "/x", "javascript:alert(1)", http.StatusBadRequest, []string{`<p class="error">`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.post("/create", url.Values{"csrf": {csrf}, "path": {tt.path}, "url": {tt.url}})
+			wantHTML(t, w, tt.code, tt.want...)
+		})
+	}
+	if dest, _ := store.Lookup("/gh"); dest != "https://github.com" {
+		t.Errorf("a failed create changed /gh to %q", dest)
+	}
+	if _, ok := store.Lookup("/x"); ok {
+		t.Error("an invalid mapping was stored")
+	}
+
+	// the browser checks the form as well
+	wantHTML(t, s.get("/"), http.StatusOK, `required pattern="/.*"`, `type="url"`)
+}
+
+func TestAdminUIDelete(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	s := &uiSession{t: t, h: AdminUI(store)}
+	csrf := s.csrf("/")
+
+	w := s.post("/delete", url.Values{"csrf": {csrf}, "path": {"/gh"}})
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("delete: got %d", w.Code)
+	}
+	if _, ok := store.Lookup("/gh"); ok {
+		t.Error("/gh is still mapped")
+	}
+	wantHTML(t, s.post("/delete", url.Values{"csrf": {csrf}, "path": {"/gh"}}), http.StatusNotFound, "no mapping for /gh")
+	// deleting needs a POST
+	if w := s.get("/delete?path=/gh"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /delete: got %d", w.Code)
+	}
+}
+
+func TestAdminUICSRF(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	h := AdminUI(store)
+	s := &uiSession{t: t, h: h}
+	csrf := s.csrf("/")
+	other := &uiSession{t: t, h: h}
+	otherCSRF := other.csrf("/")
+	// another instance derives other tokens from the same cookie
+	foreign := &uiSession{t: t, h: AdminUI(store), cookie: s.cookie}
+	wrong := []byte(csrf)
+	wrong[0] ^= 1
+
+	tests := []struct {
+		name    string
+		session *uiSession
+		csrf    string
+	}{
+		{"no token", s, ""},
+		{"wrong token", s, string(wrong)},
+		{"the cookie as the token", s, s.cookie.Value},
+		{"token of another cookie", s, otherCSRF},
+		{"no cookie", &uiSession{t: t, h: h}, csrf},
+		{"another instance", foreign, csrf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range []string{"/create", "/delete"} {
+				w := tt.session.post(target, url.Values{"csrf": {tt.csrf}, "path": {"/gh"}, "url": {"https://gitlab.com"}})
+				if w.Code != http.StatusForbidden {
+					t.Errorf("POST %s: got %d, want 403", target, w.Code)
+				}
+			}
+			if dest, ok := store.Lookup("/gh"); !ok || dest != "https://github.com" {
+				t.Errorf("/gh changed to %q, %v", dest, ok)
+			}
+		})
+	}
+
+	// the cookie is reused, the token stays the same
+	if again := s.csrf("/"); again != csrf {
+		t.Errorf("the token changed from %q to %q", csrf, again)
+	}
+}
+
+func TestAdminUIAuth(t *testing.T) {
+	store := NewMutableStore()
+	h := AdminUI(store, WithAdminToken("s3cret"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Errorf("without the token: got %d, %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+	for _, password := range []string{"", "wrong", "s3cret!"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("admin", password)
+		if w := (&uiSession{t: t, h: h}).do(req); w.Code != http.StatusUnauthorized {
+			t.Errorf("password %q: got %d", password, w.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetBasicAuth("anyone", "s3cret")
+	if w := (&uiSession{t: t, h: h}).do(req); w.Code != http.StatusOK {
+		t.Errorf("with the token: got %d", w.Code)
+	}
+}
+
+func TestAdminUIStatsAndPrefix(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	store.Set("/go", "https://go.dev")
+	stats := NewStats()
+	redirects := NewMutableHandler(store, nil, WithStats(stats), WithPathPrefix("/l"))
+	for i := 0; i < 3; i++ {
+		get(redirects, "/l/gh")
+	}
+
+	// mounted under a path like the docs show
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", http.StripPrefix("/admin", AdminUI(store)))
+	s := &uiSession{t: t, h: mux}
+	wantHTML(t, s.get("/admin/"), http.StatusOK,
+		`<th class="hits">Hits</th>`,
+		`<td class="path">/l/gh</td>`,
+		`<td class="hits">3</td>`,
+		`<td class="hits">0</td>`,
+		// the form posts the stored path
+		`<input type="hidden" name="path" value="/gh">`,
+	)
+
+	// paths are entered with the prefix, like they are requested
+	csrf := s.csrf("/admin/")
+	w := s.post("/admin/create", url.Values{"csrf": {csrf}, "path": {"/l/docs"}, "url": {"https://docs.example.com"}})
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("create: got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := store.Lookup("/docs"); !ok {
+		t.Errorf("got routes %v, want /docs", store.Routes())
+	}
+	if w := get(redirects, "/l/docs"); w.Header().Get("Location") != "https://docs.example.com" {
+		t.Errorf("GET /l/docs: got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,128 @@
+package urlshort
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkAliases makes sure the aliases of pu are paths
+func checkAliases(pu *pathUrl) error {
+	if len(pu.Aliases) == 0 {
+		return nil
+	}
+	if pu.Regex {
+		return fmt.Errorf("urlshort: %s: regex entries can't have aliases", pu.Path)
+	}
+	for _, alias := range pu.Aliases {
+		if !strings.HasPrefix(alias, "/") {
+			return fmt.Errorf("urlshort: %s: alias %q must start with /", pu.Path, alias)
+		}
+	}
+	return nil
+}
+
+// expandAliases returns pathUrls with a copy of every entry for
+// each of its aliases, right after it. The copies remember the
+// path they are an alias of. Entries that were expanded already,
+// like the flattened entries of a table, are returned as they are.
+func expandAliases(pathUrls []pathUrl) []pathUrl {
+	n := len(pathUrls)
+	for _, pu := range pathUrls {
+		if pu.aliasOf != "" {
+			return pathUrls
+		}
+		n += len(pu.Aliases)
+	}
+	if n == len(pathUrls) {
+		return pathUrls
+	}
+
+	expanded := make([]pathUrl, 0, n)
+	for _, pu := range pathUrls {
+		expanded = append(expanded, pu)
+		for _, alias := range pu.Aliases {
+			copied := pu
+			copied.Path, copied.Aliases, copied.aliasOf = alias, nil, pu.Path
+			expanded = append(expanded, copied)
+		}
+	}
+	return expanded
+}
+
+// collapseAliases undoes expandAliases for exports: the copies are
+// dropped and each entry lists the aliases that are still served
+func collapseAliases(pathUrls []pathUrl) []pathUrl {
+	served := make(map[string][]string)
+	for _, pu := range pathUrls {
+		if pu.aliasOf != "" {
+			key := pu.Host + " " + pu.aliasOf
+			served[key] = append(served[key], pu.Path)
+		}
+	}
+	if len(served) == 0 {
+		return pathUrls
+	}
+
+	collapsed := make([]pathUrl, 0, len(pathUrls))
+	for _, pu := range pathUrls {
+		if pu.aliasOf != "" {
+			continue
+		}
+		if len(pu.Aliases) > 0 {
+			pu.Aliases = served[pu.Host+" "+pu.Path]
+		}
+		collapsed = append(collapsed, pu)
+	}
+	return collapsed
+}
+
+// aliasTargets maps the path of every alias to the path it is an
+// alias of
+func aliasTargets(pathUrls []pathUrl) map[string]string {
+	var targets map[string]string
+	for _, pu := range pathUrls {
+		if pu.aliasOf == "" {
+			continue
+		}
+		if targets == nil {
+			targets = make(map[string]string)
+		}
+		targets[pu.Path] = pu.aliasOf
+	}
+	return targets
+}
+
+// describe names pu in errors, aliases with the path they belong to
+func (pu pathUrl) describe() string {
+	if pu.aliasOf != "" {
+		return pu.Path + " (alias of " + pu.aliasOf + ")"
+	}
+	return pu.Path
+}
+
+// CanonicalHits returns the hits of the configured paths like
+// Snapshot, with the hits of each alias added to the path it is an
+// alias of instead of being listed on their own. Snapshot keeps
+// them apart. Aliases are those of the handler that was built or
+// reloaded last with WithStats(s).
+func (s *Stats) CanonicalHits() map[string]PathStats {
+	hits := s.hits.snapshot()
+	src := s.source.Load()
+	if src == nil || len(src.aliases) == 0 {
+		return hits
+	}
+
+	combined := make(map[string]PathStats, len(hits))
+	for path, ps := range hits {
+		if target, ok := src.aliases[path]; ok {
+			path = target
+		}
+		sum := combined[path]
+		sum.Hits += ps.Hits
+		if ps.LastHit.After(sum.LastHit) {
+			sum.LastHit = ps.LastHit
+		}
+		combined[path] = sum
+	}
+	return combined
+}
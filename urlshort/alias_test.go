@@ -0,0 +1,101 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const aliasEntries = `
+- path: /github
+  url: https://github.com
+  code: 301
+  aliases: [/gh, /git]
+  headers:
+    X-Team: infra
+- path: /go
+  url: https://go.dev
+`
+
+func TestAliases(t *testing.T) {
+	stats := NewStats()
+	h, err := New(FromYAML([]byte(aliasEntries)), urlshorttest.Fallback(), WithStats(stats))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// aliases are the entry under another path
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/github", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/git", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+	})
+	if got := get(h, "/gh").Header().Get("X-Team"); got != "infra" {
+		t.Errorf("the alias got X-Team %q", got)
+	}
+	if h.Len() != 4 {
+		t.Errorf("got %d mappings, want 4", h.Len())
+	}
+
+	// the hits of aliases count for the entry in CanonicalHits only
+	if got := stats.Snapshot().Paths["/gh"].Hits; got != 2 {
+		t.Errorf("Snapshot: got %d hits of /gh, want 2", got)
+	}
+	canonical := stats.CanonicalHits()
+	if got := canonical["/github"].Hits; got != 4 {
+		t.Errorf("CanonicalHits: got %d hits of /github, want 4", got)
+	}
+	if _, ok := canonical["/gh"]; ok {
+		t.Error("CanonicalHits lists the alias")
+	}
+
+	// exports list the aliases with their entry
+	out, err := h.Export("yaml")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if got := string(out); !strings.Contains(got, "aliases:\n  - /gh\n  - /git\n") || strings.Count(got, "- path:") != 2 {
+		t.Errorf("got export:\n%s", got)
+	}
+}
+
+func TestAliasErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"no slash", "- path: /github\n  url: https://github.com\n  aliases: [gh]\n", `urlshort: /github: alias "gh" must start with /`},
+		{"regex", "- path: ^/gh$\n  url: https://github.com\n  regex: true\n  aliases: [/gh]\n", "urlshort: ^/gh$: regex entries can't have aliases"},
+		{"another entry", "- path: /github\n  url: https://github.com\n  aliases: [/go]\n- path: /go\n  url: https://go.dev\n", "/go (alias of /github)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), nil)
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestCollapseAliases(t *testing.T) {
+	pathUrls := expandAliases([]pathUrl{
+		{Path: "/github", URL: "https://github.com", Aliases: []string{"/gh", "/git"}},
+		{Path: "/go", URL: "https://go.dev"},
+	})
+	if len(pathUrls) != 4 || pathUrls[1].Path != "/gh" || pathUrls[1].aliasOf != "/github" || pathUrls[3].Path != "/go" {
+		t.Fatalf("got %+v", pathUrls)
+	}
+	// expanding again changes nothing
+	if again := expandAliases(pathUrls); len(again) != 4 {
+		t.Errorf("expanded twice: got %d entries", len(again))
+	}
+	if got := aliasTargets(pathUrls); len(got) != 2 || got["/git"] != "/github" {
+		t.Errorf("got targets %v", got)
+	}
+
+	// an alias that is gone isn't listed any more
+	collapsed := collapseAliases(append(pathUrls[:1:1], pathUrls[2:]...))
+	if len(collapsed) != 2 || len(collapsed[0].Aliases) != 1 || collapsed[0].Aliases[0] != "/git" {
+		t.Errorf("got %+v", collapsed)
+	}
+}
@@ -0,0 +1,127 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// backupVersion changes whenever Backup changes in a way older
+// backups can't be restored from
+const backupVersion = 1
+
+// Restore modes
+const (
+	// RestoreReplace drops everything that isn't in the backup
+	RestoreReplace = "replace"
+	// RestoreMerge keeps the mappings that aren't in the backup, the
+	// ones that are get the destination of the backup
+	RestoreMerge = "merge"
+)
+
+// Backup is everything in a MutableStore, as returned by
+// POST /api/backup and read by POST /api/restore
+type Backup struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Entries []pathUrl `json:"entries"`
+	// Stats is only set when the store is served WithStats
+	Stats *StatsSnapshot `json:"stats,omitempty"`
+}
+
+// Backup returns all mappings in the store, and the stats it is
+// served with if any
+func (s *MutableStore) Backup() Backup {
+	b := Backup{Version: backupVersion, Created: time.Now().UTC(), Entries: s.entryList()}
+	if b.Entries == nil {
+		b.Entries = []pathUrl{}
+	}
+	if stats := s.stats.Load(); stats != nil {
+		snap := stats.Snapshot()
+		b.Stats = &snap
+	}
+	return b
+}
+
+// Restore puts the mappings of b into the store, mode is
+// RestoreReplace or RestoreMerge. The whole backup is checked first,
+// if anything is wrong with it the store is left as it was. The
+// mappings change at once, readers never see half of them.
+//
+// The stats of b are restored when the store is served WithStats.
+// Replacing resets the counts first, merging adds them to the ones
+// there are, so merging the same backup twice counts its hits twice.
+func (s *MutableStore) Restore(b Backup, mode string) error {
+	if mode != RestoreReplace && mode != RestoreMerge {
+		return fmt.Errorf("urlshort: unknown restore mode %q (use replace or merge)", mode)
+	}
+	if b.Version != backupVersion {
+		if b.Version == 0 {
+			return errors.New("urlshort: not a backup, it has no version")
+		}
+		return fmt.Errorf("urlshort: backup version %d, need %d", b.Version, backupVersion)
+	}
+	seen := make(map[string]bool, len(b.Entries))
+	for i, pu := range b.Entries {
+		if err := validateMapping(pu.Path, pu.URL); err != nil {
+			return fmt.Errorf("urlshort: entry %d: %s", i+1, strings.TrimPrefix(err.Error(), "urlshort: "))
+		}
+		if seen[pu.Path] {
+			return fmt.Errorf("urlshort: entry %d: %s is in the backup twice", i+1, pu.Path)
+		}
+		seen[pu.Path] = true
+	}
+	if b.Stats != nil {
+		if err := checkStatsSnapshot(*b.Stats); err != nil {
+			return err
+		}
+	}
+
+	s.apply(b.Entries, mode == RestoreReplace)
+	if stats := s.stats.Load(); stats != nil && b.Stats != nil {
+		if mode == RestoreReplace {
+			stats.Reset()
+		}
+		stats.Restore(*b.Stats)
+	}
+	return nil
+}
+
+// checkStatsSnapshot makes sure snap only holds counts
+func checkStatsSnapshot(snap StatsSnapshot) error {
+	for _, m := range []map[string]PathStats{snap.Paths, snap.Misses, snap.Disabled} {
+		for path, ps := range m {
+			if ps.Hits < 0 {
+				return fmt.Errorf("urlshort: stats for %s: negative hits %d", path, ps.Hits)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *admin) backup(w http.ResponseWriter, r *http.Request) {
+	b := a.store.Backup()
+	w.Header().Set("Content-Disposition", `attachment; filename="urlshort-backup.json"`)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func (a *admin) restore(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode != RestoreReplace && mode != RestoreMerge {
+		writeError(w, http.StatusBadRequest, "mode must be replace or merge")
+		return
+	}
+	var b Backup
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := a.store.Restore(b, mode); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"entries": len(b.Entries)})
+}
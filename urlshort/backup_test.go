@@ -0,0 +1,228 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// backupOf posts to /api/backup of admin and decodes the document
+func backupOf(t *testing.T, admin http.Handler) (Backup, string) {
+	t.Helper()
+	res := call(admin, http.MethodPost, "/api/backup", "")
+	if res.Code != http.StatusOK {
+		t.Fatalf("backup: got %d: %s", res.Code, res.Body.String())
+	}
+	var b Backup
+	if err := json.Unmarshal(res.Body.Bytes(), &b); err != nil {
+		t.Fatalf("decoding the backup: %v", err)
+	}
+	return b, res.Body.String()
+}
+
+// exportOf returns the YAML export of store
+func exportOf(t *testing.T, store *MutableStore) string {
+	t.Helper()
+	data, err := store.Export(FormatYAML)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	return string(data)
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	store := NewMutableStore()
+	stats := NewStats()
+	serve := NewMutableHandler(store, urlshorttest.Fallback(), WithStats(stats))
+	admin := AdminHandler(store)
+	for path, dest := range map[string]string{"/gh": "https://github.com", "/go": "https://go.dev", "/old": "https://example.com/old"} {
+		if res := call(admin, http.MethodPost, "/api/paths", fmt.Sprintf(`{"path": %q, "url": %q}`, path, dest)); res.Code != http.StatusCreated {
+			t.Fatalf("create %s: got %d", path, res.Code)
+		}
+	}
+	store.Disable("/old")
+	for i := 0; i < 3; i++ {
+		get(serve, "/gh")
+	}
+	get(serve, "/missing")
+
+	exported := exportOf(t, store)
+	snap := stats.Snapshot()
+	b, doc := backupOf(t, admin)
+	if b.Version != backupVersion || b.Created.IsZero() || len(b.Entries) != 3 || b.Stats == nil {
+		t.Fatalf("got backup %s", doc)
+	}
+
+	// wipe everything
+	store.Replace(nil)
+	stats.Reset()
+	urlshorttest.AssertFallback(t, serve, "/gh")
+
+	res := call(admin, http.MethodPost, "/api/restore?mode=replace", doc)
+	if res.Code != http.StatusOK || res.Body.String() != "{\"entries\":3}\n" {
+		t.Fatalf("restore: got %d: %s", res.Code, res.Body.String())
+	}
+	if got := exportOf(t, store); got != exported {
+		t.Errorf("after the restore the export is\n%s\nwant\n%s", got, exported)
+	}
+	// the restored stats lose the /gh 404 of the wipe
+	if got := stats.Snapshot(); !reflect.DeepEqual(got.Paths, snap.Paths) || !reflect.DeepEqual(got.Misses, snap.Misses) {
+		t.Errorf("got stats %+v, want %+v", got, snap)
+	}
+	urlshorttest.TableTest(t, serve, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		// disabled entries stay disabled
+		{Path: "/old"},
+	})
+	if stats.Snapshot().Paths["/gh"].Hits != 4 {
+		t.Errorf("counting doesn't continue from the backup: %+v", stats.Snapshot().Paths)
+	}
+
+	// a store served without stats backs up without them
+	_, doc = backupOf(t, AdminHandler(NewMutableStore()))
+	var plain map[string]any
+	if err := json.Unmarshal([]byte(doc), &plain); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain["stats"]; ok || plain["entries"] == nil {
+		t.Errorf("an empty store without stats backs up as %s", doc)
+	}
+}
+
+func TestRestoreMerge(t *testing.T) {
+	source := NewMutableStore()
+	source.Set("/gh", "https://github.com/nils")
+	source.Set("/go", "https://go.dev")
+	sourceStats := NewStats()
+	get(NewMutableHandler(source, nil, WithStats(sourceStats)), "/gh")
+	_, doc := backupOf(t, AdminHandler(source))
+
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	store.Set("/extra", "https://example.com/extra")
+	stats := NewStats()
+	serve := NewMutableHandler(store, urlshorttest.Fallback(), WithStats(stats))
+	get(serve, "/gh")
+	get(serve, "/gh")
+
+	if res := call(AdminHandler(store), http.MethodPost, "/api/restore?mode=merge", doc); res.Code != http.StatusOK {
+		t.Fatalf("restore: got %d: %s", res.Code, res.Body.String())
+	}
+	want := map[string]string{"/gh": "https://github.com/nils", "/go": "https://go.dev", "/extra": "https://example.com/extra"}
+	if got := store.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// merged counts add up
+	if got := stats.Snapshot().Paths["/gh"].Hits; got != 3 {
+		t.Errorf("/gh hits: got %d, want 3", got)
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	entry := `{"path": "/gh", "url": "https://gitlab.com"}`
+	tests := []struct {
+		name   string
+		target string
+		doc    string
+		want   string
+	}{
+		{"no mode", "/api/restore", `{"version": 1, "entries": []}`, "mode must be replace or merge"},
+		{"unknown mode", "/api/restore?mode=append", `{"version": 1, "entries": []}`, "mode must be replace or merge"},
+		{"not JSON", "/api/restore?mode=replace", `version: 1`, "invalid JSON"},
+		{"no version", "/api/restore?mode=replace", `{"entries": [` + entry + `]}`, "not a backup, it has no version"},
+		{"newer version", "/api/restore?mode=replace", `{"version": 2, "entries": [` + entry + `]}`, "backup version 2, need 1"},
+		{"invalid entry", "/api/restore?mode=merge", `{"version": 1, "entries": [` + entry + `, {"path": "/x", "url": "ftp://x"}]}`, "entry 2: url"},
+		{"relative path", "/api/restore?mode=merge", `{"version": 1, "entries": [{"path": "gh", "url": "https://x.example.com"}]}`, `entry 1: path "gh" must start with /`},
+		{"twice", "/api/restore?mode=replace", `{"version": 1, "entries": [` + entry + `, ` + entry + `]}`, "entry 2: /gh is in the backup twice"},
+		{"negative hits", "/api/restore?mode=replace", `{"version": 1, "entries": [` + entry + `], "stats": {"paths": {"/gh": {"hits": -1}}}}`, "stats for /gh: negative hits -1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewMutableStore()
+			store.Set("/gh", "https://github.com")
+			stats := NewStats()
+			get(NewMutableHandler(store, nil, WithStats(stats)), "/gh")
+			before := exportOf(t, store)
+
+			res := call(AdminHandler(store), http.MethodPost, tt.target, tt.doc)
+			if res.Code != http.StatusBadRequest {
+				t.Errorf("got %d, want 400", res.Code)
+			}
+			var body map[string]string
+			json.Unmarshal(res.Body.Bytes(), &body)
+			if !strings.Contains(body["error"], tt.want) {
+				t.Errorf("got error %q, want one mentioning %q", body["error"], tt.want)
+			}
+			// nothing was applied
+			if got := exportOf(t, store); got != before {
+				t.Errorf("the store changed to\n%s", got)
+			}
+			if got := stats.Snapshot().Paths["/gh"].Hits; got != 1 {
+				t.Errorf("the stats changed, /gh has %d hits", got)
+			}
+		})
+	}
+
+	// restoring needs a POST like backing up
+	if res := call(AdminHandler(NewMutableStore()), http.MethodGet, "/api/backup", ""); res.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /api/backup: got %d", res.Code)
+	}
+}
+
+func TestRestoreAtomic(t *testing.T) {
+	backups := make([]Backup, 2)
+	for i, dest := range []string{"https://a.example.com", "https://b.example.com"} {
+		store := NewMutableStore()
+		for j := 0; j < 500; j++ {
+			store.Set(fmt.Sprintf("/p%d", j), dest)
+		}
+		backups[i] = store.Backup()
+	}
+
+	store := NewMutableStore()
+	if err := store.Restore(backups[0], RestoreReplace); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// readers see one backup or the other, never a mix
+				routes := store.Routes()
+				if len(routes) != 500 {
+					t.Errorf("got %d mappings", len(routes))
+					return
+				}
+				dests := make(map[string]bool)
+				for _, dest := range routes {
+					dests[dest] = true
+				}
+				if len(dests) != 1 {
+					t.Errorf("got a mix of %v", dests)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if err := store.Restore(backups[i%2], RestoreReplace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,191 @@
+package urlshort
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+)
+
+// minBloomCapacity is the fewest paths the filter of a SQLiteStore
+// is sized for, so a new table isn't rebuilt on every Set
+const minBloomCapacity = 1024
+
+// WithBloomFilter keeps a bloom filter of the configured paths, so
+// requests for paths that definitely aren't mapped skip the lookup
+// and go straight to the fallback. fpRate is the share of unmapped
+// paths the filter lets through anyway, e.g. 0.01, and sizes it
+// together with the number of paths. It pays off when most requests
+// miss, like when the shortener shares a domain with a site.
+//
+// Route tables only use the filter when they consist of exact
+// paths, patterns can't be put in one. It is rebuilt whenever the
+// table is, on Reload too. SQLiteHandler builds it from the
+// redirects table, see SQLiteStore.Reload. Other handlers ignore it.
+func WithBloomFilter(fpRate float64) Option {
+	return func(c *config) {
+		c.bloomRate = fpRate
+	}
+}
+
+// bloomFilter is a bloom filter of paths. add is safe to call
+// concurrently with mayContain.
+type bloomFilter struct {
+	bits []atomic.Uint64
+	// m is the number of bits, k the number of hashes per path
+	m, k uint64
+	// capacity is the number of paths the filter was sized for, added
+	// counts the calls to add
+	capacity int64
+	added    atomic.Int64
+}
+
+// newBloomFilter returns a filter for n paths that lets through
+// about the share rate of paths that weren't added
+func newBloomFilter(n int, rate float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{bits: make([]atomic.Uint64, (m+63)/64), m: m, k: k, capacity: int64(n)}
+}
+
+// bloomHashes returns the two hashes the bit positions of path are
+// derived from
+func bloomHashes(path string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	sum := h.Sum64()
+	// the upper half is the step, it must not be zero
+	return sum, sum>>32 | 1
+}
+
+func (f *bloomFilter) add(path string) {
+	f.added.Add(1)
+	h1, h2 := bloomHashes(path)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		word, mask := &f.bits[bit/64], uint64(1)<<(bit%64)
+		for {
+			old := word.Load()
+			if old&mask != 0 || word.CompareAndSwap(old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// mayContain reports false if path definitely wasn't added
+func (f *bloomFilter) mayContain(path string) bool {
+	h1, h2 := bloomHashes(path)
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64].Load()&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// full reports whether more paths were added than the filter was
+// sized for, so it lets through more than its rate
+func (f *bloomFilter) full() bool {
+	return f.added.Load() > f.capacity
+}
+
+// buildBloom sets the filter of rt if it only has exact entries
+func (rt *routeTable) buildBloom(rate float64) {
+	if len(rt.params) > 0 || len(rt.globs) > 0 || len(rt.regex) > 0 || rt.prefixes.size > 0 {
+		return
+	}
+	rt.bloom = newBloomFilter(len(rt.exact), rate)
+	for key := range rt.exact {
+		rt.bloom.add(key)
+	}
+}
+
+// definiteMiss reports whether the filter of rt rules out key and
+// its slash alternative
+func (rt *routeTable) definiteMiss(key string) bool {
+	if rt.bloom == nil || rt.bloom.mayContain(key) {
+		return false
+	}
+	alt, ok := rt.slashAlternative(key)
+	return !ok || !rt.bloom.mayContain(alt)
+}
+
+// Reload reads all paths of the redirects table again to rebuild the
+// filter of WithBloomFilter, and drops the cache, so changes other
+// processes made show right away. Without the filter it only drops
+// the cache. Set waits while the filter is rebuilt.
+func (s *SQLiteStore) Reload(ctx context.Context) error {
+	if s.bloomRate > 0 {
+		if err := s.buildBloom(ctx); err != nil {
+			return err
+		}
+	}
+	s.cache.clear()
+	return nil
+}
+
+// buildBloom replaces the filter with one of the paths in the table
+func (s *SQLiteStore) buildBloom(ctx context.Context) error {
+	// keep Set from writing paths the query might not see
+	s.bloomMu.Lock()
+	defer s.bloomMu.Unlock()
+
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM redirects`).Scan(&n); err != nil {
+		return err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT path FROM redirects`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// leave room for the paths Set adds, it rebuilds the filter once
+	// that is used up
+	capacity := 2 * n
+	if capacity < minBloomCapacity {
+		capacity = minBloomCapacity
+	}
+	f := newBloomFilter(capacity, s.bloomRate)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return err
+		}
+		f.add(path)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	s.bloom.Store(f)
+	return nil
+}
+
+// setWithBloom adds path to the filter before writing it, lookups
+// must find it as soon as it is written. A filter that is full is
+// rebuilt afterwards.
+func (s *SQLiteStore) setWithBloom(ctx context.Context, path, dest string) error {
+	s.bloomMu.RLock()
+	f := s.bloom.Load()
+	f.add(path)
+	err := s.set(ctx, path, dest)
+	s.bloomMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if f.full() {
+		return s.buildBloom(ctx)
+	}
+	return nil
+}
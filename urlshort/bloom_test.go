@@ -0,0 +1,221 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10_000
+	for _, rate := range []float64{0.1, 0.01, 0.001} {
+		f := newBloomFilter(n, rate)
+		for i := 0; i < n; i++ {
+			f.add(fmt.Sprintf("/p%d", i))
+		}
+		// never a false negative
+		for i := 0; i < n; i++ {
+			if !f.mayContain(fmt.Sprintf("/p%d", i)) {
+				t.Fatalf("rate %v: /p%d was added but isn't contained", rate, i)
+			}
+		}
+		const probes = 100_000
+		falsePositives := 0
+		for i := 0; i < probes; i++ {
+			if f.mayContain(fmt.Sprintf("/miss/%d", i)) {
+				falsePositives++
+			}
+		}
+		if got := float64(falsePositives) / probes; got > 2*rate {
+			t.Errorf("rate %v: %v of the misses got through", rate, got)
+		}
+		if f.full() {
+			t.Errorf("rate %v: full at capacity", rate)
+		}
+		f.add("/one-more")
+		if !f.full() {
+			t.Errorf("rate %v: not full past capacity", rate)
+		}
+	}
+
+	// an empty table still gets a working filter
+	f := newBloomFilter(0, 0.01)
+	if f.mayContain("/gh") {
+		t.Error("an empty filter contains /gh")
+	}
+	f.add("/gh")
+	if !f.mayContain("/gh") {
+		t.Error("/gh was added but isn't contained")
+	}
+}
+
+func TestWithBloomFilter(t *testing.T) {
+	paths := map[string]string{"/gh": "https://github.com", "/Docs/": "https://docs.example.com"}
+	h, err := New(FromMap(paths), urlshorttest.Fallback(), WithBloomFilter(0.01),
+		WithCaseInsensitivePaths(), WithSlashNormalization())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if h.routes.Load().bloom == nil {
+		t.Fatal("no filter for exact paths")
+	}
+	// the filter holds the normalized paths, both spellings still match
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/GH/", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/docs/", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	// Reload builds a new filter
+	if err := h.Reload([]Entry{{Path: "/go", URL: "https://go.dev"}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/gh"},
+	})
+
+	// patterns can't be in a filter, tables with them don't get one
+	for _, path := range []string{"/u/:name", "/docs/*", "/blog/**/x", "^/issue/[0-9]+$"} {
+		h, err := New(FromYAML([]byte(fmt.Sprintf("- path: /gh\n  url: https://github.com\n- path: %q\n  url: https://example.com\n  regex: %v\n",
+			path, strings.HasPrefix(path, "^")))), nil, WithBloomFilter(0.01))
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if h.routes.Load().bloom != nil {
+			t.Errorf("%s: the table got a filter", path)
+		}
+	}
+
+	for _, rate := range []float64{-0.1, 1, 2} {
+		_, err := New(FromMap(paths), nil, WithBloomFilter(rate))
+		wantError(t, err, "must be between 0 and 1")
+	}
+}
+
+func TestSQLiteBloomFilter(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openSQLiteStore(t, "- path: /gh\n  url: https://github.com\n", WithBloomFilter(0.01))
+	urlshorttest.TableTest(t, s, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	// Set adds to the filter before the row is there
+	if err := s.Set(ctx, "/go", "https://go.dev"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, s, "/go", "https://go.dev", http.StatusFound)
+
+	// writes of other processes show after Reload
+	if _, err := s.db.ExecContext(ctx, upsertRedirect, "/external", "https://external.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	urlshorttest.AssertFallback(t, s, "/external")
+	if err := s.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, s, "/external", "https://external.example.com", http.StatusFound)
+
+	// a filter that is used up is rebuilt by the next Set
+	full := s.bloom.Load()
+	for i := int64(0); i <= full.capacity; i++ {
+		full.add(fmt.Sprintf("/filler/%d", i))
+	}
+	if err := s.Set(ctx, "/rust", "https://rust-lang.org"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if s.bloom.Load() == full {
+		t.Error("the full filter wasn't rebuilt")
+	}
+	urlshorttest.TableTest(t, s, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/rust", Location: "https://rust-lang.org", Code: http.StatusFound},
+		{Path: "/filler/1"},
+	})
+}
+
+// countingResolver counts the lookups that reach the backend
+type countingResolver struct {
+	Resolver
+	calls atomic.Int64
+}
+
+func (cr *countingResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	cr.calls.Add(1)
+	return cr.Resolver.Resolve(ctx, path)
+}
+
+// BenchmarkBloomMisses serves a workload where 95% of the requests
+// are for paths that aren't mapped and never repeat, like the pages
+// of a site the shortener shares a domain with, from SQLite and from
+// a map. backend-calls/op is the share of requests that reached
+// SQLite.
+func BenchmarkBloomMisses(b *testing.B) {
+	const n = 10_000
+	var yaml strings.Builder
+	paths := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&yaml, "- path: /l/%d\n  url: https://example.com/%d\n", i, i)
+		paths[fmt.Sprintf("/l/%d", i)] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	target := func(i int) string {
+		if i%20 == 0 {
+			return fmt.Sprintf("/l/%d", i%n)
+		}
+		return fmt.Sprintf("/site/page/%d", i)
+	}
+
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{
+		{"off", nil},
+		{"on", []Option{WithBloomFilter(0.01)}},
+	} {
+		b.Run("sqlite/"+bm.name, func(b *testing.B) {
+			dir := b.TempDir()
+			if err := ImportYAMLToSQLite(dir+"/redirects.db", []byte(yaml.String())); err != nil {
+				b.Fatal(err)
+			}
+			s, err := SQLiteHandler(dir+"/redirects.db", nil, bm.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.Close()
+			stmt, err := s.db.Prepare(`SELECT url FROM redirects WHERE path = ?`)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// the handler SQLiteHandler builds, with the lookups counted
+			res := &countingResolver{Resolver: &sqlResolver{stmt: stmt}}
+			s.handler = cachedResolverHandler(res, s.cache, &s.bloom, nil, mustConfig(bm.opts))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target(i), nil))
+			}
+			b.ReportMetric(float64(res.calls.Load())/float64(b.N), "backend-calls/op")
+		})
+		b.Run("map/"+bm.name, func(b *testing.B) {
+			h, err := New(FromMap(paths), nil, append(bm.opts, WithCaseInsensitivePaths())...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target(i), nil))
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltHandler will return an http.HandlerFunc that looks up the
+// request path as a key in the given bucket of db on every
+// request and redirects to the stored URL. If the key or the
+// bucket don't exist, the fallback http.Handler will be called
+// instead.
+//
+// Use SeedBolt to fill the bucket from parsed YAML or JSON.
+func BoltHandler(db *bolt.DB, bucket string, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	if db == nil {
+		return nil, errors.New("urlshort: bolt db is nil")
+	}
+	if bucket == "" {
+		return nil, errors.New("urlshort: bolt bucket name is empty")
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolverHandler(&boltResolver{db: db, bucket: bucket}, fallback, cfg), nil
+}
+
+// boltResolver looks paths up as keys in a bucket
+type boltResolver struct {
+	db     *bolt.DB
+	bucket string
+}
+
+func (br *boltResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	var dest string
+	err := br.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(br.bucket))
+		// a missing bucket just means nothing was seeded yet
+		if b == nil {
+			return nil
+		}
+		// the value is only valid during the transaction, so copy it
+		dest = string(b.Get([]byte(path)))
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return dest, dest != "", nil
+}
+
+// SeedBolt stores the entries in the given bucket of db, creating
+// the bucket if it doesn't exist yet. Existing keys are replaced.
+// All entries are written in a single transaction.
+func SeedBolt(db *bolt.DB, bucket string, pathUrls []pathUrl) error {
+	if err := checkEntries(pathUrls); err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for _, pu := range pathUrls {
+			if err := b.Put([]byte(pu.Path), []byte(pu.URL)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
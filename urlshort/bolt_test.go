@@ -0,0 +1,89 @@
+package urlshort
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	bolt "go.etcd.io/bbolt"
+)
+
+// openBolt opens a bolt database in a temporary directory
+func openBolt(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "urlshort.db"), 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBoltHandler(t *testing.T) {
+	db := openBolt(t)
+	err := SeedBolt(db, "redirects", []pathUrl{
+		{Path: "/gh", URL: "https://github.com"},
+		{Path: "/go", URL: "https://go.dev"},
+	})
+	if err != nil {
+		t.Fatalf("SeedBolt: %v", err)
+	}
+	// seeding again replaces existing keys
+	if err := SeedBolt(db, "redirects", []pathUrl{{Path: "/go", URL: "https://pkg.go.dev"}}); err != nil {
+		t.Fatalf("SeedBolt: %v", err)
+	}
+
+	h, err := BoltHandler(db, "redirects", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("BoltHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://pkg.go.dev", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	// a bucket nothing was seeded into has no matches
+	empty, err := BoltHandler(db, "unseeded", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("BoltHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, empty, []urlshorttest.Case{{Path: "/gh"}})
+}
+
+func TestBoltHandlerConcurrentReads(t *testing.T) {
+	db := openBolt(t)
+	if err := SeedBolt(db, "redirects", []pathUrl{{Path: "/gh", URL: "https://github.com"}}); err != nil {
+		t.Fatalf("SeedBolt: %v", err)
+	}
+	h, err := BoltHandler(db, "redirects", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("BoltHandler: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				urlshorttest.TableTest(t, h, []urlshorttest.Case{
+					{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+					{Path: "/missing"},
+				})
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestBoltErrors(t *testing.T) {
+	db := openBolt(t)
+	_, err := BoltHandler(nil, "redirects", urlshorttest.Fallback())
+	wantError(t, err, "bolt db is nil")
+	_, err = BoltHandler(db, "", urlshorttest.Fallback())
+	wantError(t, err, "bucket name is empty")
+	err = SeedBolt(db, "redirects", []pathUrl{{Path: "/gh"}})
+	wantError(t, err, "missing a url")
+}
@@ -0,0 +1,131 @@
+package urlshort
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// WithCache keeps the results of backend lookups (SQLHandler and
+// friends) in memory for ttl, so hot paths don't hit the backend
+// on every request. Misses are cached as well. A ttl of zero
+// disables the cache, which is the default.
+func WithCache(ttl time.Duration) Option {
+	return func(c *config) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithCacheSize limits the cache of WithCache to n paths, the ones
+// looked up longest ago are dropped first. Zero means no limit.
+func WithCacheSize(n int) Option {
+	return func(c *config) {
+		c.cacheSize = n
+	}
+}
+
+// lookupCache remembers backend lookups for a while
+type lookupCache struct {
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order has the entries oldest first, with a single ttl that is
+	// also the order they expire in
+	order *list.List
+	// gen changes on every invalidate, see put
+	gen uint64
+}
+
+type cacheEntry struct {
+	path    string
+	dest    string
+	found   bool
+	expires time.Time
+}
+
+func newLookupCache(ttl time.Duration, size int) *lookupCache {
+	return &lookupCache{
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached result for path. ok is false if there is
+// none or it expired, found tells whether the backend had the path.
+func (c *lookupCache) get(path string) (dest string, found, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[path]
+	if !ok {
+		return "", false, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return "", false, false
+	}
+	return e.dest, e.found, true
+}
+
+// generation returns what to pass to put for a lookup that starts now
+func (c *lookupCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// put remembers the result of a backend lookup for path. A lookup
+// that started before an invalidate may have read the old value, so
+// it isn't cached if gen is no longer current.
+func (c *lookupCache) put(path, dest string, found bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+	e := &cacheEntry{path: path, dest: dest, found: found, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[path]; ok {
+		el.Value = e
+		c.order.MoveToBack(el)
+		return
+	}
+	c.entries[path] = c.order.PushBack(e)
+	for c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Front())
+	}
+}
+
+// invalidate forgets what is cached for path
+func (c *lookupCache) invalidate(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if el, ok := c.entries[path]; ok {
+		c.remove(el)
+	}
+}
+
+// clear forgets everything
+func (c *lookupCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// remove drops el, the caller must hold the lock
+func (c *lookupCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).path)
+}
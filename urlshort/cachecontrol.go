@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithRedirectCaching lets CDNs and browsers cache permanent
+// redirects: 301 and 308 responses get Cache-Control: public,
+// max-age set to maxAge, 302 and 307 responses get no-store so they
+// are never cached. cache_max_age in seconds overrides maxAge per
+// entry, it is only accepted on entries that redirect permanently.
+func WithRedirectCaching(maxAge time.Duration) Option {
+	return func(c *config) {
+		c.redirectCaching = true
+		c.redirectMaxAge = maxAge
+	}
+}
+
+// checkCacheMaxAge rejects a cache_max_age on an entry whose code
+// makes it a temporary redirect, which is almost always a mistake
+func checkCacheMaxAge(pu *pathUrl) error {
+	if pu.CacheMaxAge < 0 {
+		return fmt.Errorf("urlshort: %s: cache_max_age %d is negative", pu.Path, pu.CacheMaxAge)
+	}
+	if pu.CacheMaxAge > 0 && pu.Code != 0 && !permanent(pu.Code) {
+		return fmt.Errorf("urlshort: %s: cache_max_age only works for permanent redirects, code %d is temporary", pu.Path, pu.Code)
+	}
+	return nil
+}
+
+// permanent reports whether code is a permanent redirect
+func permanent(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// writeCacheControl sets the Cache-Control of a redirect of pu with
+// status, if WithRedirectCaching or the entry ask for one
+func writeCacheControl(w http.ResponseWriter, pu pathUrl, status int, cfg *config) {
+	switch {
+	case permanent(status) && pu.CacheMaxAge > 0:
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(pu.CacheMaxAge))
+	case !cfg.redirectCaching:
+	case permanent(status):
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(cfg.redirectMaxAge/time.Second)))
+	case status == http.StatusFound || status == http.StatusTemporaryRedirect:
+		w.Header().Set("Cache-Control", "no-store")
+	}
+}
@@ -0,0 +1,95 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestRedirectCaching(t *testing.T) {
+	data := `
+- path: /moved
+  url: https://new.example.com
+  code: 301
+- path: /moved-short
+  url: https://new.example.com/short
+  code: 301
+  cache_max_age: 60
+- path: /perm
+  url: https://perm.example.com
+  code: 308
+- path: /found
+  url: https://found.example.com
+- path: /temp
+  url: https://temp.example.com
+  code: 307
+`
+	tests := []struct {
+		name   string
+		opts   []Option
+		method string
+		path   string
+		code   int
+		want   string
+	}{
+		{"301", []Option{WithRedirectCaching(time.Hour)}, http.MethodGet, "/moved", http.StatusMovedPermanently, "public, max-age=3600"},
+		{"308", []Option{WithRedirectCaching(time.Hour)}, http.MethodGet, "/perm", http.StatusPermanentRedirect, "public, max-age=3600"},
+		{"entry max age", []Option{WithRedirectCaching(time.Hour)}, http.MethodGet, "/moved-short", http.StatusMovedPermanently, "public, max-age=60"},
+		{"302", []Option{WithRedirectCaching(time.Hour)}, http.MethodGet, "/found", http.StatusFound, "no-store"},
+		{"307", []Option{WithRedirectCaching(time.Hour)}, http.MethodGet, "/temp", http.StatusTemporaryRedirect, "no-store"},
+		{"302 as 307", []Option{WithRedirectCaching(time.Hour)}, http.MethodPost, "/found", http.StatusTemporaryRedirect, "no-store"},
+		{"zero max age", []Option{WithRedirectCaching(0)}, http.MethodGet, "/moved", http.StatusMovedPermanently, "public, max-age=0"},
+		// without the option only entries ask for caching
+		{"off 301", nil, http.MethodGet, "/moved", http.StatusMovedPermanently, ""},
+		{"off 302", nil, http.MethodGet, "/found", http.StatusFound, ""},
+		{"off entry max age", nil, http.MethodGet, "/moved-short", http.StatusMovedPermanently, "public, max-age=60"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, httptest.NewRequest(tt.method, tt.path, nil))
+			if res.Code != tt.code || res.Header().Get("Cache-Control") != tt.want {
+				t.Errorf("%s %s: got %d with Cache-Control %q, want %d with %q", tt.method, tt.path, res.Code, res.Header().Get("Cache-Control"), tt.code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectCachingDefaultStatus(t *testing.T) {
+	data := "- path: /a\n  url: https://a.example.com\n  cache_max_age: 60\n"
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithStatus(http.StatusMovedPermanently))
+	if err != nil {
+		t.Fatalf("YAMLHandler with a permanent default: %v", err)
+	}
+	if res := get(h, "/a"); res.Header().Get("Cache-Control") != "public, max-age=60" {
+		t.Errorf("GET /a: Cache-Control %q", res.Header().Get("Cache-Control"))
+	}
+
+	_, err = YAMLHandler([]byte(data), urlshorttest.Fallback())
+	wantError(t, err, "cache_max_age only works for permanent redirects, the default code 302 is temporary")
+}
+
+func TestRedirectCachingErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"302", "- path: /a\n  url: https://a.example.com\n  code: 302\n  cache_max_age: 60\n", "cache_max_age only works for permanent redirects, code 302 is temporary"},
+		{"307", "- path: /a\n  url: https://a.example.com\n  code: 307\n  cache_max_age: 60\n", "code 307 is temporary"},
+		{"negative", "- path: /a\n  url: https://a.example.com\n  code: 301\n  cache_max_age: -1\n", "cache_max_age -1 is negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback(), WithRedirectCaching(time.Hour))
+			wantError(t, err, tt.want)
+		})
+	}
+}
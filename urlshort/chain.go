@@ -0,0 +1,130 @@
+package urlshort
+
+import (
+	"net/http"
+	"time"
+)
+
+// LookupHandler is anything that can map a path to a destination,
+// so it can be part of a Chain. *MutableStore is one, MapLookup and
+// YAMLLookup wrap the other formats.
+type LookupHandler interface {
+	Lookup(path string) (dest string, ok bool)
+}
+
+// RouteLister is implemented by lookup handlers that can list all of
+// their mappings, ChainHandler.Routes uses it.
+type RouteLister interface {
+	Routes() map[string]string
+}
+
+// ChainHandler tries a list of lookup handlers in order, see
+// NewChain
+type ChainHandler struct {
+	handlers []LookupHandler
+	fallback http.Handler
+	cfg      *config
+}
+
+// Chain returns a handler that asks each of handlers in turn for the
+// request path and redirects to the first destination found. Paths
+// none of them has get a 404. This replaces nesting MapHandler and
+// YAMLHandler as each other's fallback. See NewChain for a different
+// fallback and for listing the routes.
+func Chain(handlers ...LookupHandler) http.HandlerFunc {
+	return NewChain(nil, handlers...).ServeHTTP
+}
+
+// NewChain is Chain with a fallback http.Handler for the paths none
+// of handlers has, nil answers them with a 404. Unlike the handler
+// of Chain, the ChainHandler can list its Routes.
+func NewChain(fallback http.Handler, handlers ...LookupHandler) *ChainHandler {
+	if fallback == nil {
+		fallback = http.NotFoundHandler()
+	}
+	return &ChainHandler{handlers: handlers, fallback: fallback, cfg: defaultConfig()}
+}
+
+// ServeHTTP redirects using the first handler that knows the path
+func (c *ChainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := requestPath(r)
+	for _, h := range c.handlers {
+		if dest, ok := h.Lookup(path); ok {
+			serveMatch(w, r, match{entry: pathUrl{Path: path, URL: dest}, dest: dest}, c.fallback, c.cfg)
+			return
+		}
+	}
+	serveMiss(w, r, c.fallback, c.cfg)
+}
+
+// Routes returns the mappings of every handler that can list them,
+// as the chain would resolve them: when several handlers have the
+// same path, the earlier one wins. Handlers that can't list their
+// mappings, like database backed ones, are skipped.
+func (c *ChainHandler) Routes() map[string]string {
+	routes := make(map[string]string)
+	for _, h := range c.handlers {
+		lister, ok := h.(RouteLister)
+		if !ok {
+			continue
+		}
+		for path, dest := range lister.Routes() {
+			if _, ok := routes[path]; !ok {
+				routes[path] = dest
+			}
+		}
+	}
+	return routes
+}
+
+// MapLookup returns a LookupHandler for the mappings of
+// pathsToUrls, with the same matching rules as MapHandler.
+func MapLookup(pathsToUrls map[string]string) LookupHandler {
+	pathUrls := make([]pathUrl, 0, len(pathsToUrls))
+	for path, dest := range pathsToUrls {
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+	}
+	routes, err := buildMap(pathUrls, defaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return &tableLookup{routes: routes}
+}
+
+// YAMLLookup parses yamlBytes like YAMLHandler and returns a
+// LookupHandler for the mappings.
+func YAMLLookup(yamlBytes []byte) (LookupHandler, error) {
+	pathUrls, err := parseYAML(yamlBytes)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := buildRoutes(pathUrls, defaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return &tableLookup{routes: routes}, nil
+}
+
+// tableLookup is a LookupHandler backed by a route table
+type tableLookup struct {
+	routes *routeTable
+}
+
+func (t *tableLookup) Lookup(path string) (string, bool) {
+	m, ok := t.routes.lookup(path)
+	if ok {
+		m, ok = m.current(time.Now())
+	}
+	if !ok || m.entry.Disabled {
+		return "", false
+	}
+	return m.dest, true
+}
+
+func (t *tableLookup) Routes() map[string]string {
+	routes := make(map[string]string, len(t.routes.entries))
+	for _, pu := range t.routes.entries {
+		routes[pu.Path] = pu.URL
+	}
+	return routes
+}
@@ -0,0 +1,67 @@
+package urlshort
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// noRoutes is a LookupHandler that can't list its mappings
+type noRoutes map[string]string
+
+func (n noRoutes) Lookup(path string) (string, bool) {
+	dest, ok := n[path]
+	return dest, ok
+}
+
+func TestChain(t *testing.T) {
+	yl, err := YAMLLookup([]byte("- path: /gh\n  url: https://github.com/nils\n- path: /go\n  url: https://go.dev\n"))
+	if err != nil {
+		t.Fatalf("YAMLLookup: %v", err)
+	}
+	ml := MapLookup(map[string]string{"/gh": "https://github.com", "/docs/": "https://example.com/docs"})
+	db := noRoutes{"/db": "https://db.example.com", "/go": "https://golang.org"}
+
+	cases := []urlshorttest.Case{
+		// the earlier handler wins
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/db", Location: "https://db.example.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	}
+	urlshorttest.TableTest(t, NewChain(urlshorttest.Fallback(), ml, yl, db), cases)
+
+	// Chain answers misses with a plain 404
+	h := Chain(ml, yl, db)
+	if code := get(h, "/missing").Code; code != http.StatusNotFound {
+		t.Errorf("GET /missing: got %d, want 404", code)
+	}
+	urlshorttest.AssertRedirect(t, h, "/db", "https://db.example.com", http.StatusFound)
+
+	// an empty chain only has the fallback
+	urlshorttest.TableTest(t, NewChain(urlshorttest.Fallback()), []urlshorttest.Case{{Path: "/gh"}})
+}
+
+func TestChainRoutes(t *testing.T) {
+	yl, err := YAMLLookup([]byte("- path: /gh\n  url: https://github.com/nils\n- path: /go\n  url: https://go.dev\n"))
+	if err != nil {
+		t.Fatalf("YAMLLookup: %v", err)
+	}
+	ml := MapLookup(map[string]string{"/gh": "https://github.com"})
+	c := NewChain(nil, ml, noRoutes{"/db": "https://db.example.com"}, yl)
+	want := map[string]string{"/gh": "https://github.com", "/go": "https://go.dev"}
+	if got := c.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestYAMLLookupErrors(t *testing.T) {
+	_, err := YAMLLookup([]byte("- path: /gh\n  url: ftp://example.com\n"))
+	wantError(t, err, "/gh")
+	_, err = YAMLLookup([]byte("- path: [\n"))
+	if err == nil {
+		t.Error("broken YAML: no error")
+	}
+}
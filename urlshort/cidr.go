@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithTrustedProxies names the addresses or CIDR ranges of proxies in
+// front of the server. For requests from them the client address of
+// allow_cidrs and deny_cidrs is taken from X-Forwarded-For or
+// X-Real-IP, for every other request those headers are ignored since
+// the client could have made them up.
+func WithTrustedProxies(proxies ...string) Option {
+	return func(c *config) {
+		c.proxyList = append(c.proxyList, proxies...)
+	}
+}
+
+// parseNetworks parses the trusted proxies of the options
+func parseNetworks(list []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range list {
+		network, err := parseNetwork(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// checkCIDRs parses the allow_cidrs and deny_cidrs of pu
+func checkCIDRs(pu *pathUrl) error {
+	var err error
+	if pu.allowNets, err = entryNetworks(pu.Path, "allow_cidrs", pu.AllowCIDRs); err != nil {
+		return err
+	}
+	pu.denyNets, err = entryNetworks(pu.Path, "deny_cidrs", pu.DenyCIDRs)
+	return err
+}
+
+// entryNetworks parses list, the field of the entry with path
+func entryNetworks(path, field string, list []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range list {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("urlshort: %s: invalid %s entry %q (use a CIDR range like 10.0.0.0/8)", path, field, s)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// restricted reports whether pu limits which clients it works for
+func (pu pathUrl) restricted() bool {
+	return len(pu.allowNets) > 0 || len(pu.denyNets) > 0
+}
+
+// clientAllowed reports whether the client of r may use pu. Deny
+// ranges win over allow ranges, and a client whose address can't be
+// told is let in by neither.
+func clientAllowed(r *http.Request, pu pathUrl, cfg *config) bool {
+	if !pu.restricted() {
+		return true
+	}
+	ip := net.ParseIP(clientAddr(r, cfg.trustedProxies))
+	if ip == nil {
+		return false
+	}
+	if inNetworks(ip, pu.denyNets) {
+		return false
+	}
+	return len(pu.allowNets) == 0 || inNetworks(ip, pu.allowNets)
+}
+
+// clientAddr returns the address of the client of r. Behind trusted
+// proxies that is the last X-Forwarded-For entry that isn't one of
+// them, or X-Real-IP if there is no X-Forwarded-For. Every other
+// entry could have been made up by the client.
+func clientAddr(r *http.Request, proxies []*net.IPNet) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !trustedAddr(ip, proxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		if !trustedAddr(hop, proxies) {
+			return hop
+		}
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); real != "" && len(r.Header.Values("X-Forwarded-For")) == 0 {
+		return real
+	}
+	return ip
+}
+
+// trustedAddr reports whether ip is one of proxies
+func trustedAddr(ip string, proxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && inNetworks(parsed, proxies)
+}
+
+// inNetworks reports whether ip is in one of networks
+func inNetworks(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const cidrEntries = `
+- path: /dash
+  url: https://dash.internal.example.com
+  allow_cidrs: [10.0.0.0/8, "2001:db8:1::/48"]
+  deny_cidrs: [10.6.6.0/24]
+- path: /public
+  url: https://www.example.com
+  deny_cidrs: [203.0.113.0/24, "2001:db8:bad::/48"]
+- path: /open
+  url: https://open.example.com
+`
+
+// requestFrom records the response of h to a GET of target from
+// remoteAddr with the given headers
+func requestFrom(h http.Handler, target, remoteAddr string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.RemoteAddr = remoteAddr
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestCIDRLists(t *testing.T) {
+	h, err := YAMLHandler([]byte(cidrEntries), urlshorttest.Fallback(), WithTrustedProxies("192.0.2.1", "2001:db8:ffff::/64"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		name   string
+		path   string
+		addr   string
+		header http.Header
+		ok     bool
+	}{
+		{"v4 allowed", "/dash", "10.1.2.3:5000", nil, true},
+		{"v4 not allowed", "/dash", "198.51.100.7:5000", nil, false},
+		{"v4 deny wins over allow", "/dash", "10.6.6.6:5000", nil, false},
+		{"v6 allowed", "/dash", "[2001:db8:1::42]:5000", nil, true},
+		{"v6 not allowed", "/dash", "[2001:db8:2::42]:5000", nil, false},
+		{"v4 denied", "/public", "203.0.113.9:5000", nil, false},
+		{"v4 not denied", "/public", "198.51.100.7:5000", nil, true},
+		{"v6 denied", "/public", "[2001:db8:bad::1]:5000", nil, false},
+		{"unrestricted", "/open", "203.0.113.9:5000", nil, true},
+
+		// behind a trusted proxy the headers name the client
+		{"forwarded allowed", "/dash", "192.0.2.1:5000", http.Header{"X-Forwarded-For": {"10.1.2.3"}}, true},
+		{"forwarded not allowed", "/dash", "192.0.2.1:5000", http.Header{"X-Forwarded-For": {"198.51.100.7"}}, false},
+		{"forwarded spoofed in front", "/dash", "192.0.2.1:5000", http.Header{"X-Forwarded-For": {"10.1.2.3, 198.51.100.7"}}, false},
+		{"forwarded through a v6 proxy", "/dash", "[2001:db8:ffff::1]:5000", http.Header{"X-Forwarded-For": {"2001:db8:1::42"}}, true},
+		{"real ip allowed", "/dash", "192.0.2.1:5000", http.Header{"X-Real-Ip": {"10.1.2.3"}}, true},
+		{"real ip denied", "/public", "192.0.2.1:5000", http.Header{"X-Real-Ip": {"203.0.113.9"}}, false},
+		// from anyone else the headers are ignored
+		{"untrusted forwarded", "/dash", "198.51.100.7:5000", http.Header{"X-Forwarded-For": {"10.1.2.3"}}, false},
+		{"untrusted real ip", "/dash", "198.51.100.7:5000", http.Header{"X-Real-Ip": {"10.1.2.3"}}, false},
+		{"untrusted forwarded past a deny", "/public", "203.0.113.9:5000", http.Header{"X-Forwarded-For": {"198.51.100.7"}}, false},
+		// an address that can't be told is let in by neither list
+		{"garbage forwarded", "/public", "192.0.2.1:5000", http.Header{"X-Forwarded-For": {"not-an-ip"}}, false},
+	}
+	for _, tt := range tests {
+		res := requestFrom(h, tt.path, tt.addr, tt.header)
+		fellBack := res.Header().Get("Location") == ""
+		if fellBack == tt.ok {
+			t.Errorf("%s: GET %s from %s got %d to %q, want ok %v", tt.name, tt.path, tt.addr, res.Code, res.Header().Get("Location"), tt.ok)
+		}
+		// the destination of a refused entry isn't given away
+		if !tt.ok && res.Code != http.StatusNotFound {
+			t.Errorf("%s: got %d, want the 404 of the fallback", tt.name, res.Code)
+		}
+	}
+}
+
+func TestCIDRListsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts []Option
+		want string
+	}{
+		{"allow", "- path: /a\n  url: https://a.example.com\n  allow_cidrs: [10.0.0.0/33]\n", nil,
+			`/a: invalid allow_cidrs entry "10.0.0.0/33" (use a CIDR range like 10.0.0.0/8)`},
+		{"deny", "- path: /a\n  url: https://a.example.com\n  deny_cidrs: [10.0.0.1]\n", nil, `invalid deny_cidrs entry "10.0.0.1"`},
+		{"proxy", "- path: /a\n  url: https://a.example.com\n", []Option{WithTrustedProxies("proxy.internal")}, `invalid address "proxy.internal"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback(), tt.opts...)
+			wantError(t, err, tt.want)
+		})
+	}
+}
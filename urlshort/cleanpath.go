@@ -0,0 +1,83 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// requestPath returns the path of r in the form used for lookups,
+// see cleanPath. It starts from the escaped path, so an encoded
+// slash can't form a dot segment.
+func requestPath(r *http.Request) string {
+	return cleanPath(r.URL.EscapedPath())
+}
+
+// configPath returns a configured path in the form used for
+// lookups. Configured paths may be written decoded (/über) or
+// encoded (/%C3%BCber), both end up the same.
+func configPath(pu pathUrl) string {
+	if pu.Regex {
+		return pu.Path
+	}
+	return cleanPath(pu.Path)
+}
+
+// cleanPath decodes an escaped path, collapses duplicate slashes and
+// resolves . and .. segments, without ever going above the root.
+// Dot segments are only recognized before decoding, so ..%2F stays
+// part of its segment instead of climbing up. Encoded slashes and
+// percent signs stay encoded, a%2Fb is one segment and only becomes
+// a/b as a parameter value, see segmentValue. A trailing slash is
+// kept.
+func cleanPath(escaped string) string {
+	if !strings.HasPrefix(escaped, "/") {
+		return escaped
+	}
+
+	raw := strings.Split(escaped[1:], "/")
+	segments := make([]string, 0, len(raw))
+	for _, seg := range raw {
+		switch seg {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			if decoded, err := url.PathUnescape(seg); err == nil {
+				seg = decoded
+			}
+			segments = append(segments, escapeSeparators(seg))
+		}
+	}
+
+	cleaned := "/" + strings.Join(segments, "/")
+	last := raw[len(raw)-1]
+	if len(segments) > 0 && (last == "" || last == "." || last == "..") {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// separatorEscaper encodes what would change the segments of a
+// cleaned path if it was decoded
+var separatorEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// escapeSeparators encodes the slashes and percent signs of a
+// decoded segment, so it stays one segment and a literal %2F can't
+// be told apart from an encoded slash
+func escapeSeparators(seg string) string {
+	if !strings.ContainsAny(seg, "%/") {
+		return seg
+	}
+	return separatorEscaper.Replace(seg)
+}
+
+// segmentValue decodes a segment of a cleaned path
+func segmentValue(seg string) string {
+	if decoded, err := url.PathUnescape(seg); err == nil {
+		return decoded
+	}
+	return seg
+}
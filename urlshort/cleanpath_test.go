@@ -0,0 +1,83 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		escaped string
+		want    string
+	}{
+		{"/", "/"},
+		{"//", "/"},
+		{"/%C3%BCber", "/über"},
+		{"/über", "/über"},
+		{"//a//b", "/a/b"},
+		{"/a/", "/a/"},
+		{"/a/./b", "/a/b"},
+		{"/a/../b", "/b"},
+		{"/a/.", "/a/"},
+		{"/a/..", "/"},
+		// never above the root
+		{"/../../etc", "/etc"},
+		{"/a/b/../../..", "/"},
+		// encoded slashes stay inside their segment
+		{"/a%2Fb", "/a%2Fb"},
+		{"/a%2fb", "/a%2Fb"},
+		{"/..%2Fsecret", "/..%2Fsecret"},
+		{"/a%25b", "/a%25b"},
+		{"/a%zzb", "/a%25zzb"},
+		{"no-slash", "no-slash"},
+	}
+	for _, tt := range tests {
+		if got := cleanPath(tt.escaped); got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.escaped, got, tt.want)
+		}
+	}
+}
+
+func TestEncodedRequestPaths(t *testing.T) {
+	data := `
+- path: /über
+  url: https://example.com/uber
+- path: /%C3%A4rger
+  url: https://example.com/aerger
+- path: //docs//start
+  url: https://example.com/start
+- path: /admin
+  url: https://example.com/admin
+- path: /a/b
+  url: https://example.com/ab
+- path: /files/:name
+  url: https://files.example.com/get?f=:name
+`
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// encoded unicode, in requests and in the config
+		{Path: "/über", Location: "https://example.com/uber", Code: http.StatusFound},
+		{Path: "/%C3%BCber", Location: "https://example.com/uber", Code: http.StatusFound},
+		{Path: "/%c3%bcber", Location: "https://example.com/uber", Code: http.StatusFound},
+		{Path: "/ärger", Location: "https://example.com/aerger", Code: http.StatusFound},
+		{Path: "/%C3%A4rger", Location: "https://example.com/aerger", Code: http.StatusFound},
+		// double slashes and dot segments
+		{Path: "/docs/start", Location: "https://example.com/start", Code: http.StatusFound},
+		{Path: "//docs///start", Location: "https://example.com/start", Code: http.StatusFound},
+		{Path: "/docs/./start", Location: "https://example.com/start", Code: http.StatusFound},
+		{Path: "/public/../admin", Location: "https://example.com/admin", Code: http.StatusFound},
+		{Path: "/../../admin", Location: "https://example.com/admin", Code: http.StatusFound},
+		// an encoded slash is not a separator
+		{Path: "/a%2Fb"},
+		{Path: "/public/..%2Fadmin"},
+		{Path: "/public%2F..%2Fadmin"},
+		{Path: "/files/report.pdf", Location: "https://files.example.com/get?f=report.pdf", Code: http.StatusFound},
+		{Path: "/files/a%2Fb", Location: "https://files.example.com/get?f=a%2Fb", Code: http.StatusFound},
+		{Path: "/files/a/b"},
+	})
+}
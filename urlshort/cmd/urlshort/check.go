@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort"
+)
+
+// runCheck is the check subcommand, it requests every destination of
+// every mapping in a file once and returns the exit code: 0 if all
+// of them work, 1 if some don't and 2 if the file couldn't be
+// checked at all.
+//
+//	urlshort check -yaml redirects.yaml -concurrency 20 -timeout 5s
+//
+// The file is read like the server reads it, includes and vars
+// included. Every destination is listed in a table, destinations
+// that can't be requested as they are, like those with parameters
+// or relative ones, are skipped.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	yamlFile := fs.String("yaml", "", "YAML file to check")
+	concurrency := fs.Int("concurrency", 8, "destinations to check at once")
+	timeout := fs.Duration("timeout", 10*time.Second, "time limit for each destination")
+	maxRedirects := fs.Int("max-redirects", 10, "redirects to follow, 0 follows none")
+	allow := fs.String("allow", "", "comma separated statuses that count as working, every 2xx by default")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *yamlFile == "" {
+		fmt.Fprintln(stderr, "urlshort check: -yaml is required")
+		return 2
+	}
+	opts := urlshort.CheckOptions{Concurrency: *concurrency, Timeout: *timeout, MaxRedirects: *maxRedirects}
+	for _, s := range strings.Split(*allow, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		status, err := strconv.Atoi(s)
+		if err != nil {
+			fmt.Fprintf(stderr, "urlshort check: bad status %q in -allow\n", s)
+			return 2
+		}
+		opts.AllowedStatus = append(opts.AllowedStatus, status)
+	}
+
+	// relative destinations load, to be skipped, since the server
+	// accepts them with a relative -default-redirect
+	fh, err := urlshort.NewFileHandler(*yamlFile, urlshort.FormatYAML, nil, urlshort.WithRelativeDestinations())
+	if err != nil {
+		fmt.Fprintf(stderr, "urlshort check: %v\n", err)
+		return 2
+	}
+	entries := fh.Snapshot()
+
+	// Ctrl-C still prints what was found so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results := urlshort.CheckDestinations(ctx, entries, opts)
+
+	failed, skipped := 0, 0
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tDESTINATION\tSTATUS\tTIME\tRESULT")
+	for _, cr := range results {
+		status, result := "-", "ok"
+		if cr.Status != 0 {
+			status = strconv.Itoa(cr.Status)
+		}
+		switch {
+		case cr.Skipped:
+			skipped++
+			result = "skipped"
+		case !cr.OK():
+			failed++
+			result = cr.Error
+		case cr.FinalURL != "" && cr.FinalURL != cr.URL:
+			result = "ok, ends at " + cr.FinalURL
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", cr.Path, cr.URL, status, cr.Duration.Round(time.Millisecond), result)
+	}
+	tw.Flush()
+	fmt.Fprintf(stderr, "%s: %d checked, %d failed, %d skipped\n", *yamlFile, len(results)-skipped, failed, skipped)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
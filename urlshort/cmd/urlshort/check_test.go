@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSite answers /ok with a 200, /moved with a redirect to it and
+// everything else with a 404
+func newSite(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunCheck(t *testing.T) {
+	site := newSite(t)
+	working := writeMappings(t, "working.yaml", "- path: /ok\n  url: "+site.URL+"/ok\n"+
+		"- path: /moved\n  url: "+site.URL+"/moved\n"+
+		"- path: /u/:name\n  url: "+site.URL+"/u/:name\n")
+	var stdout, stderr bytes.Buffer
+	if code := runCheck([]string{"-yaml", working, "-concurrency", "2", "-timeout", "5s"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("got exit code %d: %s%s", code, stdout.String(), stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "PATH") {
+		t.Fatalf("got the table\n%s", stdout.String())
+	}
+	for _, want := range []string{"/ok", "/moved", "ok, ends at " + site.URL + "/ok", "skipped"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("the table doesn't contain %q:\n%s", want, stdout.String())
+		}
+	}
+	if want := working + ": 2 checked, 0 failed, 1 skipped\n"; stderr.String() != want {
+		t.Errorf("got summary %q, want %q", stderr.String(), want)
+	}
+
+	// a dead destination is exit code 1
+	broken := writeMappings(t, "broken.yaml", "- path: /ok\n  url: "+site.URL+"/ok\n- path: /gone\n  url: "+site.URL+"/gone\n")
+	stdout.Reset()
+	stderr.Reset()
+	if code := runCheck([]string{"-yaml", broken}, &stdout, &stderr); code != 1 {
+		t.Errorf("got exit code %d, want 1", code)
+	}
+	if !strings.Contains(stdout.String(), "status 404") || !strings.Contains(stderr.String(), "2 checked, 1 failed, 0 skipped") {
+		t.Errorf("got\n%s%s", stdout.String(), stderr.String())
+	}
+
+	// unless it's allowed
+	stdout.Reset()
+	stderr.Reset()
+	if code := runCheck([]string{"-yaml", broken, "-allow", "200, 404"}, &stdout, &stderr); code != 0 {
+		t.Errorf("with -allow 404: got exit code %d: %s", code, stdout.String())
+	}
+	// and the redirect only counts without following it
+	stdout.Reset()
+	stderr.Reset()
+	if code := runCheck([]string{"-yaml", working, "-max-redirects", "0"}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "status 302") {
+		t.Errorf("with -max-redirects 0: got exit code %d: %s", code, stdout.String())
+	}
+}
+
+func TestRunCheckErrors(t *testing.T) {
+	valid := writeMappings(t, "redirects.yaml", "- path: /gh\n  url: https://github.com\n")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no file", nil, "-yaml is required"},
+		{"unknown flag", []string{"-yaml", valid, "-retries", "3"}, "flag provided but not defined: -retries"},
+		{"bad status", []string{"-yaml", valid, "-allow", "200,ok"}, `bad status "ok" in -allow`},
+		{"missing file", []string{"-yaml", valid + ".missing"}, "no such file"},
+		{"invalid file", []string{"-yaml", writeMappings(t, "invalid.yaml", "- path: [/gh\n")}, "invalid.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runCheck(tt.args, &stdout, &stderr); code != 2 {
+				t.Errorf("got exit code %d, want 2", code)
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("got %q, want it to mention %q", stderr.String(), tt.want)
+			}
+		})
+	}
+}
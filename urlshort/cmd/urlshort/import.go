@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NilsKaden/gophercises/urlshort"
+)
+
+// runImport is the import subcommand, it turns the CSV export of
+// another shortener into a YAML file of mappings and returns the
+// exit code: 0 if every row was imported, 1 if some were skipped and
+// 2 if the export couldn't be imported at all.
+//
+//	urlshort import -format bitly -in export.csv -out redirects.yaml
+//
+// With -stats, the clicks of the imported links are saved to a stats
+// file that serving WithStats can continue from.
+func runImport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "format of the export: bitly or rebrandly")
+	in := fs.String("in", "", "CSV export to read")
+	out := fs.String("out", "", "YAML file to write")
+	statsFile := fs.String("stats", "", "JSON stats file to save the clicks to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *in == "" || *out == "" {
+		fmt.Fprintln(stderr, "urlshort import: -in and -out are required")
+		return 2
+	}
+	var importExport func(io.Reader) ([]urlshort.Entry, urlshort.ImportReport, error)
+	switch *format {
+	case "bitly":
+		importExport = urlshort.ImportBitly
+	case "rebrandly":
+		importExport = urlshort.ImportRebrandly
+	default:
+		fmt.Fprintf(stderr, "urlshort import: unknown format %q (use bitly or rebrandly)\n", *format)
+		return 2
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintf(stderr, "urlshort import: %v\n", err)
+		return 2
+	}
+	entries, report, err := importExport(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "urlshort import: %s: %v\n", *in, err)
+		return 2
+	}
+
+	// the store sorts the mappings by path
+	store := urlshort.NewMutableStore()
+	if err := store.BulkSet(entries); err != nil {
+		fmt.Fprintf(stderr, "urlshort import: %v\n", err)
+		return 2
+	}
+	data, err := store.Export(urlshort.FormatYAML)
+	if err != nil {
+		fmt.Fprintf(stderr, "urlshort import: %v\n", err)
+		return 2
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		fmt.Fprintf(stderr, "urlshort import: %v\n", err)
+		return 2
+	}
+	if *statsFile != "" {
+		if err := urlshort.NewFileStatsPersister(*statsFile).Save(report.Stats); err != nil {
+			fmt.Fprintf(stderr, "urlshort import: %v\n", err)
+			return 2
+		}
+	}
+
+	for _, row := range report.Skipped {
+		if row.Path == "" {
+			fmt.Fprintf(stdout, "%s:%d: %s\n", *in, row.Line, row.Reason)
+			continue
+		}
+		fmt.Fprintf(stdout, "%s:%d: %s: %s\n", *in, row.Line, row.Path, row.Reason)
+	}
+	fmt.Fprintf(stderr, "%s: imported %d, skipped %d\n", *in, report.Imported, len(report.Skipped))
+	if len(report.Skipped) > 0 {
+		return 1
+	}
+	return 0
+}
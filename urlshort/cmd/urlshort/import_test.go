@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort"
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestRunImport(t *testing.T) {
+	in := writeMappings(t, "export.csv", "long_url,keyword,clicks\n"+
+		"https://go.dev,go,12\n"+
+		"https://github.com,gh,3\n"+
+		"https://gitlab.com,gh,1\n")
+	dir := t.TempDir()
+	out, statsFile := filepath.Join(dir, "redirects.yaml"), filepath.Join(dir, "stats.json")
+	var stdout, stderr bytes.Buffer
+	code := runImport([]string{"--format", "bitly", "--in", in, "--out", out, "--stats", statsFile}, &stdout, &stderr)
+	// a skipped row is exit code 1, the rest is still written
+	if code != 1 {
+		t.Errorf("got exit code %d, want 1: %s", code, stderr.String())
+	}
+	if want := in + ":4: /gh: duplicate back-half, first seen on line 3\n"; stdout.String() != want {
+		t.Errorf("got stdout %q, want %q", stdout.String(), want)
+	}
+	if !strings.Contains(stderr.String(), "imported 2, skipped 1") {
+		t.Errorf("got stderr %q", stderr.String())
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := urlshort.YAMLHandler(data, nil)
+	if err != nil {
+		t.Fatalf("the written YAML: %v\n%s", err, data)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	// sorted by path, so imports diff well
+	if strings.Index(string(data), "/gh") > strings.Index(string(data), "/go") {
+		t.Errorf("the YAML isn't sorted:\n%s", data)
+	}
+	snap, err := urlshort.NewFileStatsPersister(statsFile).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Paths["/go"].Hits != 12 || snap.Paths["/gh"].Hits != 3 {
+		t.Errorf("got stats %+v", snap.Paths)
+	}
+
+	// every row imported is exit code 0
+	clean := writeMappings(t, "clean.csv", "Slashtag,Destination\nhome,https://www.example.com/\n")
+	stdout.Reset()
+	stderr.Reset()
+	if code := runImport([]string{"-format", "rebrandly", "-in", clean, "-out", out}, &stdout, &stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("got exit code %d and %q", code, stdout.String())
+	}
+}
+
+func TestRunImportErrors(t *testing.T) {
+	in := writeMappings(t, "export.csv", "long_url,keyword\nhttps://go.dev,go\n")
+	out := filepath.Join(t.TempDir(), "redirects.yaml")
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no input", []string{"-format", "bitly", "-out", out}, "-in and -out are required"},
+		{"no output", []string{"-format", "bitly", "-in", in}, "-in and -out are required"},
+		{"unknown format", []string{"-format", "tinyurl", "-in", in, "-out", out}, `unknown format "tinyurl" (use bitly or rebrandly)`},
+		{"missing file", []string{"-format", "bitly", "-in", in + ".missing", "-out", out}, "no such file"},
+		{"wrong layout", []string{"-format", "rebrandly", "-in", in, "-out", out}, "invalid Rebrandly export"},
+		{"unwritable output", []string{"-format", "bitly", "-in", in, "-out", filepath.Join(out, "x", "y.yaml")}, "urlshort import:"},
+		{"unknown flag", []string{"-fromat", "bitly"}, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runImport(tt.args, &stdout, &stderr); code != 2 {
+				t.Errorf("got exit code %d, want 2", code)
+			}
+			if !strings.Contains(stderr.String(), tt.want) {
+				t.Errorf("got stderr %q, want it to mention %q", stderr.String(), tt.want)
+			}
+		})
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("a failed import wrote %s", out)
+	}
+}
@@ -0,0 +1,84 @@
+// Command urlshort serves the redirects of a YAML, JSON or CSV file.
+//
+//	urlshort -yaml paths.yaml -listen :8080
+//
+// Send it SIGHUP to read the file again. To check a file before
+// deploying it, e.g. in CI, run
+//
+//	urlshort validate -yaml paths.yaml
+//
+// which lists every problem and exits with 1 if there are any. To
+// move links over from bit.ly or Rebrandly, turn their CSV export
+// into a file of mappings with
+//
+//	urlshort import -format bitly -in export.csv -out redirects.yaml
+//
+// and to make sure every destination still works, run
+//
+//	urlshort check -yaml redirects.yaml -concurrency 20 -timeout 5s
+//
+// which exits with 1 if any of them doesn't.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long requests in flight get to finish
+const shutdownTimeout = 10 * time.Second
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(runValidate(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	var cfg Config
+	flag.StringVar(&cfg.YAMLFile, "yaml", "", "YAML file with the mappings")
+	flag.StringVar(&cfg.JSONFile, "json", "", "JSON file with the mappings")
+	flag.StringVar(&cfg.CSVFile, "csv", "", "CSV file with the mappings")
+	flag.StringVar(&cfg.Listen, "listen", ":8080", "address to listen on")
+	flag.StringVar(&cfg.DefaultRedirect, "default-redirect", "", "URL to redirect unknown paths to instead of a 404")
+	flag.BoolVar(&cfg.Permanent, "permanent", false, "use permanent (301) redirects")
+	flag.Parse()
+
+	srv, err := BuildServer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s", srv.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/NilsKaden/gophercises/urlshort"
+)
+
+// Config is what the flags of the binary configure
+type Config struct {
+	// YAMLFile, JSONFile and CSVFile are the mappings to serve,
+	// exactly one of them must be set
+	YAMLFile string
+	JSONFile string
+	CSVFile  string
+
+	// Listen is the address to serve on, like ":8080"
+	Listen string
+	// DefaultRedirect is where unknown paths are sent, with the
+	// status of the other redirects, they get a 404 page if it is
+	// empty
+	DefaultRedirect string
+	// Permanent redirects with 301 instead of 302
+	Permanent bool
+}
+
+// BuildServer loads the mappings cfg points at and returns a server
+// for them that hasn't been started yet. The file is read again on
+// SIGHUP until the server is shut down.
+func BuildServer(cfg Config) (*http.Server, error) {
+	path, format, err := cfg.mappings()
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback http.Handler = urlshort.NotFoundHandler()
+	var opts []urlshort.Option
+	if cfg.DefaultRedirect != "" {
+		// checked for loops when the mappings load, the fallback has
+		// to be nil then
+		fallback = nil
+		opts = append(opts, urlshort.WithDefaultDestination(cfg.DefaultRedirect))
+		if strings.HasPrefix(cfg.DefaultRedirect, "/") {
+			// a path on this server, like http.RedirectHandler took
+			// it, which lets the mappings be relative as well
+			opts = append(opts, urlshort.WithRelativeDestinations())
+		}
+	}
+	if cfg.Permanent {
+		opts = append(opts, urlshort.WithStatus(http.StatusMovedPermanently))
+	}
+
+	handler, err := urlshort.NewFileHandler(path, format, fallback, opts...)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("loaded %d mappings from %s", handler.Len(), path)
+
+	// a broken edit keeps the old mappings, it only gets logged
+	handler.ReloadOnSignal(func(err error) {
+		if err != nil {
+			log.Printf("reload failed, keeping the old mappings: %v", err)
+			return
+		}
+		log.Printf("reloaded %d mappings from %s", handler.Len(), path)
+	})
+
+	srv := &http.Server{Addr: cfg.Listen, Handler: handler}
+	srv.RegisterOnShutdown(func() { handler.Close() })
+	return srv, nil
+}
+
+// mappings returns the file to load and its format
+func (cfg Config) mappings() (string, string, error) {
+	var path, format string
+	set := 0
+	for _, f := range []struct{ path, format string }{
+		{cfg.YAMLFile, urlshort.FormatYAML},
+		{cfg.JSONFile, urlshort.FormatJSON},
+		{cfg.CSVFile, urlshort.FormatCSV},
+	} {
+		if f.path != "" {
+			path, format = f.path, f.format
+			set++
+		}
+	}
+	switch set {
+	case 0:
+		return "", "", errors.New("one of -yaml, -json or -csv is required")
+	case 1:
+		return path, format, nil
+	default:
+		return "", "", errors.New("-yaml, -json and -csv are mutually exclusive")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// writeMappings writes data to a file called name in a temporary
+// directory and returns its path
+func writeMappings(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// buildServer calls BuildServer and shuts the server down at the
+// end of the test, it returns what BuildServer logged
+func buildServer(t *testing.T, cfg Config) (*http.Server, string, error) {
+	t.Helper()
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	srv, err := BuildServer(cfg)
+	if srv != nil {
+		t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	}
+	return srv, logged.String(), err
+}
+
+func TestBuildServer(t *testing.T) {
+	yamlFile := writeMappings(t, "paths.yaml", "- path: /gh\n  url: https://github.com\n- path: /go\n  url: https://go.dev\n")
+	jsonFile := writeMappings(t, "paths.json", `[{"path": "/gh", "url": "https://github.com"}]`)
+	csvFile := writeMappings(t, "paths.csv", "path,url\n/gh,https://github.com\n/go,https://go.dev\n/gl,https://gitlab.com\n")
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		mappings   int
+		code       int
+		unknown    int
+		unknownLoc string
+	}{
+		{"yaml", Config{YAMLFile: yamlFile}, 2, http.StatusFound, http.StatusNotFound, ""},
+		{"json", Config{JSONFile: jsonFile}, 1, http.StatusFound, http.StatusNotFound, ""},
+		{"csv", Config{CSVFile: csvFile}, 3, http.StatusFound, http.StatusNotFound, ""},
+		{"permanent", Config{YAMLFile: yamlFile, Permanent: true}, 2, http.StatusMovedPermanently, http.StatusNotFound, ""},
+		{"default redirect", Config{YAMLFile: yamlFile, DefaultRedirect: "https://example.com/home"}, 2,
+			http.StatusFound, http.StatusFound, "https://example.com/home"},
+		{"relative default redirect", Config{YAMLFile: yamlFile, DefaultRedirect: "/gh"}, 2,
+			http.StatusFound, http.StatusFound, "/gh"},
+		{"permanent default redirect", Config{YAMLFile: yamlFile, DefaultRedirect: "https://example.com/home", Permanent: true}, 2,
+			http.StatusMovedPermanently, http.StatusMovedPermanently, "https://example.com/home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Listen = ":8081"
+			srv, logged, err := buildServer(t, tt.cfg)
+			if err != nil {
+				t.Fatalf("BuildServer: %v", err)
+			}
+			if srv.Addr != ":8081" {
+				t.Errorf("Addr is %q, want :8081", srv.Addr)
+			}
+			if want := fmt.Sprintf("loaded %d mappings", tt.mappings); !strings.Contains(logged, want) {
+				t.Errorf("logged %q, want %q", logged, want)
+			}
+
+			urlshorttest.AssertRedirect(t, srv.Handler, "/gh", "https://github.com", tt.code)
+			urlshorttest.AssertRedirect(t, srv.Handler, "/unknown", tt.unknownLoc, tt.unknown)
+		})
+	}
+}
+
+func TestBuildServerErrors(t *testing.T) {
+	yamlFile := writeMappings(t, "paths.yaml", "- path: /gh\n  url: https://github.com\n")
+	jsonFile := writeMappings(t, "paths.json", `[{"path": "/gh", "url": "https://github.com"}]`)
+	broken := writeMappings(t, "broken.yaml", "- path: /gh\n  url: [\n")
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"no mappings", Config{}, "one of -yaml, -json or -csv is required"},
+		{"two files", Config{YAMLFile: yamlFile, JSONFile: jsonFile}, "mutually exclusive"},
+		{"missing file", Config{YAMLFile: filepath.Join(t.TempDir(), "missing.yaml")}, "missing.yaml"},
+		{"parse error", Config{YAMLFile: broken}, "yaml"},
+		// / isn't mapped, so it would be sent to itself
+		{"default redirect loop", Config{YAMLFile: yamlFile, DefaultRedirect: "/"}, "redirect loop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _, err := buildServer(t, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("BuildServer: got %v, want an error mentioning %q", err, tt.want)
+			}
+			if srv != nil {
+				t.Errorf("BuildServer returned a server with the error %v", err)
+			}
+		})
+	}
+}
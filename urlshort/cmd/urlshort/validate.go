@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NilsKaden/gophercises/urlshort"
+)
+
+// runValidate is the validate subcommand, it checks a mappings file
+// without serving it and returns the exit code: 0 if the file is
+// fine, 1 if it has issues and 2 if it couldn't be checked at all.
+//
+//	urlshort validate -yaml paths.yaml
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	yamlFile := fs.String("yaml", "", "YAML file to check")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *yamlFile == "" {
+		fmt.Fprintln(stderr, "urlshort validate: -yaml is required")
+		return 2
+	}
+
+	data, err := os.ReadFile(*yamlFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "urlshort validate: %v\n", err)
+		return 2
+	}
+	issues := urlshort.ValidateYAML(data)
+	for _, issue := range issues {
+		fmt.Fprintf(stdout, "%s: %s\n", *yamlFile, issue)
+	}
+	if len(issues) > 0 {
+		noun := "issues"
+		if len(issues) == 1 {
+			noun = "issue"
+		}
+		fmt.Fprintf(stderr, "%s: %d %s\n", *yamlFile, len(issues), noun)
+		return 1
+	}
+	return 0
+}
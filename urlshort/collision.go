@@ -0,0 +1,132 @@
+package urlshort
+
+import (
+	"fmt"
+	"sync"
+)
+
+// CollisionPolicy decides which entry is kept when two sources map
+// the same path to different URLs. The zero value is CollisionError.
+type CollisionPolicy struct {
+	kind   collisionKind
+	source string
+}
+
+type collisionKind int
+
+const (
+	collisionError collisionKind = iota
+	collisionFirst
+	collisionLast
+	collisionPrefer
+)
+
+var (
+	// CollisionError makes a collision an error naming both sources
+	CollisionError = CollisionPolicy{}
+	// KeepFirst keeps the entry of the earlier source
+	KeepFirst = CollisionPolicy{kind: collisionFirst}
+	// KeepLast keeps the entry of the later source
+	KeepLast = CollisionPolicy{kind: collisionLast}
+)
+
+// PreferSource keeps the entry of the source called name, see
+// Named. Collisions between two other sources are still an error.
+func PreferSource(name string) CollisionPolicy {
+	return CollisionPolicy{kind: collisionPrefer, source: name}
+}
+
+// Collision is a path two sources disagreed on and how the policy
+// resolved it. Sources are named like "source 2 (env)".
+type Collision struct {
+	Path       string `json:"path"`
+	Kept       string `json:"kept"`
+	KeptURL    string `json:"kept_url"`
+	Dropped    string `json:"dropped"`
+	DroppedURL string `json:"dropped_url"`
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("%s: kept %s from %s, dropped %s from %s", c.Path, c.KeptURL, c.Kept, c.DroppedURL, c.Dropped)
+}
+
+// check makes sure a preferred source is one of sources, a typo
+// would otherwise silently turn into errors
+func (p CollisionPolicy) check(sources []Source) error {
+	if p.kind != collisionPrefer {
+		return nil
+	}
+	for _, s := range sources {
+		if s.Name() == p.source {
+			return nil
+		}
+	}
+	return fmt.Errorf("urlshort: PreferSource(%q) names none of the sources", p.source)
+}
+
+// replaces reports whether the entry of later replaces the one of
+// earlier. decided is false if the policy makes it an error.
+func (p CollisionPolicy) replaces(earlier, later Source) (replace, decided bool) {
+	switch p.kind {
+	case collisionFirst:
+		return false, true
+	case collisionLast:
+		return true, true
+	case collisionPrefer:
+		switch {
+		case later.Name() == p.source:
+			return true, true
+		case earlier.Name() == p.source:
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// Merged is a source with the entries of sources, combined like
+// MergeWith does, to serve with New:
+//
+//	m := urlshort.Merged(urlshort.MergeOptions{Collisions: urlshort.PreferSource("admin")}, file, env, admin)
+//	h, err := urlshort.New(m, fallback, opts...)
+//	for _, c := range m.Collisions() {
+//		log.Print(c)
+//	}
+//
+// Paths are compared after the normalization options of New.
+func Merged(opts MergeOptions, sources ...Source) *MergedSource {
+	return &MergedSource{opts: opts, sources: sources}
+}
+
+// MergedSource is the Source Merged returns
+type MergedSource struct {
+	opts    MergeOptions
+	sources []Source
+
+	mu         sync.Mutex
+	collisions []Collision
+}
+
+func (m *MergedSource) Name() string { return "merged" }
+
+// Collisions returns the collisions resolved the last time the
+// sources were loaded
+func (m *MergedSource) Collisions() []Collision {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Collision(nil), m.collisions...)
+}
+
+func (m *MergedSource) load(cfg *config) ([]pathUrl, error) {
+	pathUrls, collisions, err := mergeEntries(m.opts, m.sources, cfg)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.collisions = collisions
+	m.mu.Unlock()
+	return pathUrls, nil
+}
+
+func (m *MergedSource) build(pathUrls []pathUrl, cfg *config) (*routeTable, error) {
+	return buildRoutes(pathUrls, cfg)
+}
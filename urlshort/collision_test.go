@@ -0,0 +1,225 @@
+package urlshort
+
+import (
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// collidingSources are three layers that all map /docs and two of
+// which map /status
+func collidingSources() []Source {
+	return []Source{
+		Named("file", FromYAML([]byte(`
+- path: /docs
+  url: https://docs.example.com/v1
+- path: /gh
+  url: https://github.com
+- path: /status
+  url: https://status.example.com/1
+`))),
+		Named("env", FromJSON([]byte(`[
+  {"path": "/docs", "url": "https://docs.example.com/v2"},
+  {"path": "/status", "url": "https://status.example.com/2"},
+  {"path": "/gh", "url": "https://github.com"}
+]`))),
+		Named("admin", FromYAML([]byte(`
+- path: /docs
+  url: https://docs.example.com/v3
+- path: /new
+  url: https://new.example.com
+`))),
+	}
+}
+
+func TestCollisionPolicies(t *testing.T) {
+	const (
+		file  = "source 1 (file)"
+		env   = "source 2 (env)"
+		admin = "source 3 (admin)"
+		v1    = "https://docs.example.com/v1"
+		v2    = "https://docs.example.com/v2"
+		v3    = "https://docs.example.com/v3"
+		s1    = "https://status.example.com/1"
+		s2    = "https://status.example.com/2"
+	)
+	tests := []struct {
+		name       string
+		opts       MergeOptions
+		docs       string
+		status     string
+		collisions []Collision
+		err        string
+	}{
+		{
+			name: "keep first",
+			opts: MergeOptions{Collisions: KeepFirst},
+			docs: v1, status: s1,
+			collisions: []Collision{
+				{Path: "/docs", Kept: file, KeptURL: v1, Dropped: env, DroppedURL: v2},
+				{Path: "/status", Kept: file, KeptURL: s1, Dropped: env, DroppedURL: s2},
+				{Path: "/docs", Kept: file, KeptURL: v1, Dropped: admin, DroppedURL: v3},
+			},
+		},
+		{
+			name: "keep last",
+			opts: MergeOptions{Collisions: KeepLast},
+			docs: v3, status: s2,
+			collisions: []Collision{
+				{Path: "/docs", Kept: env, KeptURL: v2, Dropped: file, DroppedURL: v1},
+				{Path: "/status", Kept: env, KeptURL: s2, Dropped: file, DroppedURL: s1},
+				{Path: "/docs", Kept: admin, KeptURL: v3, Dropped: env, DroppedURL: v2},
+			},
+		},
+		{
+			name: "later wins",
+			opts: MergeOptions{LaterWins: true},
+			docs: v3, status: s2,
+			collisions: []Collision{
+				{Path: "/docs", Kept: env, KeptURL: v2, Dropped: file, DroppedURL: v1},
+				{Path: "/status", Kept: env, KeptURL: s2, Dropped: file, DroppedURL: s1},
+				{Path: "/docs", Kept: admin, KeptURL: v3, Dropped: env, DroppedURL: v2},
+			},
+		},
+		{
+			// the preferred source wins whether it comes first or last
+			name: "prefer the middle source",
+			opts: MergeOptions{Collisions: PreferSource("env")},
+			docs: v2, status: s2,
+			collisions: []Collision{
+				{Path: "/docs", Kept: env, KeptURL: v2, Dropped: file, DroppedURL: v1},
+				{Path: "/status", Kept: env, KeptURL: s2, Dropped: file, DroppedURL: s1},
+				{Path: "/docs", Kept: env, KeptURL: v2, Dropped: admin, DroppedURL: v3},
+			},
+		},
+		{
+			// file and env disagree on /docs before admin is loaded
+			name: "prefer a source not in the collision",
+			opts: MergeOptions{Collisions: PreferSource("admin")},
+			err:  "/docs conflicts between source 1 (file) (" + v1 + ") and source 2 (env) (" + v2 + ")",
+		},
+		{
+			name: "prefer an unknown source",
+			opts: MergeOptions{Collisions: PreferSource("nobody")},
+			err:  `PreferSource("nobody") names none of the sources`,
+		},
+		{
+			name: "error",
+			err:  "/docs conflicts between source 1 (file) (" + v1 + ") and source 2 (env) (" + v2 + ")",
+		},
+		{
+			// an explicit policy wins over the old flag
+			name: "keep first over later wins",
+			opts: MergeOptions{Collisions: KeepFirst, LaterWins: true},
+			docs: v1, status: s1,
+			collisions: []Collision{
+				{Path: "/docs", Kept: file, KeptURL: v1, Dropped: env, DroppedURL: v2},
+				{Path: "/status", Kept: file, KeptURL: s1, Dropped: env, DroppedURL: s2},
+				{Path: "/docs", Kept: file, KeptURL: v1, Dropped: admin, DroppedURL: v3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, collisions, err := MergeReport(tt.opts, collidingSources()...)
+			if tt.err != "" {
+				wantError(t, err, tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeReport: %v", err)
+			}
+			want := map[string]string{
+				"/docs":   tt.docs,
+				"/status": tt.status,
+				"/gh":     "https://github.com",
+				"/new":    "https://new.example.com",
+			}
+			if !maps.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			// the same /gh in file and env is no collision
+			if !slices.Equal(collisions, tt.collisions) {
+				t.Errorf("got collisions\n%v\nwant\n%v", collisions, tt.collisions)
+			}
+		})
+	}
+}
+
+func TestCollisionString(t *testing.T) {
+	c := Collision{Path: "/docs", Kept: "source 3 (admin)", KeptURL: "https://docs.example.com/v3", Dropped: "source 1 (file)", DroppedURL: "https://docs.example.com/v1"}
+	want := "/docs: kept https://docs.example.com/v3 from source 3 (admin), dropped https://docs.example.com/v1 from source 1 (file)"
+	if got := c.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergedSource(t *testing.T) {
+	m := Merged(MergeOptions{Collisions: PreferSource("admin")},
+		Named("file", FromYAML([]byte("- path: /Docs/\n  url: https://docs.example.com/v1\n- path: /gh\n  url: https://github.com\n"))),
+		Named("admin", FromMap(map[string]string{"/docs": "https://docs.example.com/v3"})),
+	)
+	// /Docs/ and /docs only collide with the normalization of New
+	h, err := New(m, urlshorttest.Fallback(), WithCaseInsensitivePaths(), WithSlashNormalization())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/docs", Location: "https://docs.example.com/v3", Code: http.StatusFound},
+		{Path: "/DOCS/", Location: "https://docs.example.com/v3", Code: http.StatusFound},
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+	})
+	want := []Collision{{Path: "/docs", Kept: "source 2 (admin)", KeptURL: "https://docs.example.com/v3",
+		Dropped: "source 1 (file)", DroppedURL: "https://docs.example.com/v1"}}
+	if got := m.Collisions(); !slices.Equal(got, want) {
+		t.Errorf("got collisions %v, want %v", got, want)
+	}
+
+	// without the normalization both paths are kept
+	if _, err := New(m, urlshorttest.Fallback()); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := m.Collisions(); len(got) != 0 {
+		t.Errorf("got collisions %v without normalization", got)
+	}
+
+	_, err = New(Merged(MergeOptions{}, collidingSources()...), urlshorttest.Fallback())
+	wantError(t, err, "/docs conflicts between source 1 (file)")
+}
+
+func TestWithCollisionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	team := writeFile(t, dir, "team.yaml", "- path: /gh\n  url: https://github.com/team\n")
+	main := writeFile(t, dir, "paths.yaml", "include:\n  - team.yaml\nredirects:\n  - path: /gh\n    url: https://github.com\n")
+	tests := []struct {
+		name   string
+		policy CollisionPolicy
+		want   string
+		err    string
+	}{
+		// included files are read first
+		{"keep first", KeepFirst, "https://github.com/team", ""},
+		{"keep last", KeepLast, "https://github.com", ""},
+		{"prefer the included file", PreferSource(filepath.Join(dir, "team.yaml")), "https://github.com/team", ""},
+		{"prefer the including file", PreferSource(main), "https://github.com", ""},
+		{"error", CollisionError, "", "/gh conflicts between source 1 (" + team + ")"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLFileHandler(main, urlshorttest.Fallback(), WithCollisionPolicy(tt.policy))
+			if tt.err != "" {
+				wantError(t, err, tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("YAMLFileHandler: %v", err)
+			}
+			defer h.Close()
+			urlshorttest.AssertRedirect(t, h, "/gh", tt.want, http.StatusFound)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CompactStore is a read-only set of exact mappings laid out for
+// millions of entries: the paths and urls are packed into two byte
+// slabs with the offsets kept next to them, sorted by path and found
+// by binary search. That costs a few bytes per entry on top of the
+// data instead of the string headers and buckets of a map.
+//
+// It is a Resolver, serve it with ResolverHandler, and a
+// LookupHandler for Chain and NewChain. Paths are matched as they
+// were given, after the usual cleaning, options like
+// WithCaseInsensitivePaths don't apply.
+type CompactStore struct {
+	paths []byte
+	urls  []byte
+	// pathEnds and urlEnds are where entry i ends in its slab, it
+	// starts where entry i-1 ends
+	pathEnds []uint32
+	urlEnds  []uint32
+}
+
+// BuildCompact builds a CompactStore from parsed entries. Only plain
+// exact paths fit, entries with parameters, globs, wildcards,
+// regexes or a host are an error, and so are duplicate paths.
+// Per-entry settings like the code are dropped, ResolverHandler
+// uses its options.
+func BuildCompact(entries []Entry) (*CompactStore, error) {
+	type kv struct{ path, url string }
+	sorted := make([]kv, 0, len(entries))
+	pathSize, urlSize := 0, 0
+	for _, pu := range entries {
+		if pu.Regex || pu.Host != "" || isParamPath(pu.Path) || isGlobPath(pu.Path) || isWildcard(pu.Path) {
+			return nil, fmt.Errorf("urlshort: %s: CompactStore only holds exact paths", pu.Path)
+		}
+		if pu.Path == "" || pu.Path[0] != '/' {
+			return nil, fmt.Errorf("urlshort: path %q must start with /", pu.Path)
+		}
+		path := configPath(pu)
+		sorted = append(sorted, kv{path, pu.URL})
+		pathSize += len(path)
+		urlSize += len(pu.URL)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].path < sorted[j].path })
+
+	cs := &CompactStore{
+		paths:    make([]byte, 0, pathSize),
+		urls:     make([]byte, 0, urlSize),
+		pathEnds: make([]uint32, len(sorted)),
+		urlEnds:  make([]uint32, len(sorted)),
+	}
+	for i, e := range sorted {
+		if i > 0 && sorted[i-1].path == e.path {
+			return nil, fmt.Errorf("urlshort: %s: duplicate path", e.path)
+		}
+		if len(cs.paths)+len(e.path) > math.MaxUint32 || len(cs.urls)+len(e.url) > math.MaxUint32 {
+			return nil, errors.New("urlshort: too much data for a CompactStore")
+		}
+		cs.paths = append(cs.paths, e.path...)
+		cs.urls = append(cs.urls, e.url...)
+		cs.pathEnds[i], cs.urlEnds[i] = uint32(len(cs.paths)), uint32(len(cs.urls))
+	}
+	return cs, nil
+}
+
+// Len returns the number of mappings in the store
+func (cs *CompactStore) Len() int {
+	return len(cs.pathEnds)
+}
+
+// Resolve finds the destination for path, it never fails
+func (cs *CompactStore) Resolve(ctx context.Context, path string) (string, bool, error) {
+	dest, ok := cs.Lookup(path)
+	return dest, ok, nil
+}
+
+// Lookup finds the destination for path
+func (cs *CompactStore) Lookup(path string) (string, bool) {
+	i := sort.Search(len(cs.pathEnds), func(i int) bool {
+		return string(cs.path(i)) >= path
+	})
+	if i == len(cs.pathEnds) || string(cs.path(i)) != path {
+		return "", false
+	}
+	return string(cs.url(i)), true
+}
+
+// path returns the path of entry i, still in the slab
+func (cs *CompactStore) path(i int) []byte {
+	return slabEntry(cs.paths, cs.pathEnds, i)
+}
+
+// url returns the url of entry i, still in the slab
+func (cs *CompactStore) url(i int) []byte {
+	return slabEntry(cs.urls, cs.urlEnds, i)
+}
+
+func slabEntry(slab []byte, ends []uint32, i int) []byte {
+	start := uint32(0)
+	if i > 0 {
+		start = ends[i-1]
+	}
+	return slab[start:ends[i]]
+}
+
+// Routes returns all mappings, which for a large store is a lot of
+// memory again
+func (cs *CompactStore) Routes() map[string]string {
+	routes := make(map[string]string, cs.Len())
+	for i := range cs.pathEnds {
+		routes[string(cs.path(i))] = string(cs.url(i))
+	}
+	return routes
+}
@@ -0,0 +1,199 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"runtime"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// compactEntries generates n entries like a large migrated file,
+// built fresh so the strings aren't shared between measurements
+func compactEntries(n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = Entry{Path: fmt.Sprintf("/p%d", i), URL: fmt.Sprintf("https://example.com/page/%d", i)}
+	}
+	return entries
+}
+
+func TestBuildCompact(t *testing.T) {
+	paths := map[string]string{
+		"/p1":       "https://example.com/1",
+		"/p10":      "https://example.com/10",
+		"/p2":       "https://example.com/2",
+		"/docs/api": "https://docs.example.com/api",
+		"/":         "https://example.com",
+		"/z":        "https://example.com/z",
+	}
+	var entries []Entry
+	for path, dest := range paths {
+		entries = append(entries, Entry{Path: path, URL: dest})
+	}
+	cs, err := BuildCompact(entries)
+	if err != nil {
+		t.Fatalf("BuildCompact: %v", err)
+	}
+	if cs.Len() != len(paths) {
+		t.Errorf("Len: got %d, want %d", cs.Len(), len(paths))
+	}
+	for path, want := range paths {
+		if got, ok := cs.Lookup(path); !ok || got != want {
+			t.Errorf("Lookup(%q): got %q %v, want %q", path, got, ok, want)
+		}
+	}
+	// misses before, between and after the entries, and prefixes of
+	// entries
+	for _, path := range []string{"", "/a", "/p", "/p0", "/p11", "/p3", "/docs", "/docs/api/x", "/zz", "~"} {
+		if got, ok := cs.Lookup(path); ok {
+			t.Errorf("Lookup(%q): got %q, want a miss", path, got)
+		}
+	}
+	if got := cs.Routes(); !maps.Equal(got, paths) {
+		t.Errorf("Routes: got %v, want %v", got, paths)
+	}
+	dest, ok, err := cs.Resolve(context.Background(), "/p10")
+	if dest != "https://example.com/10" || !ok || err != nil {
+		t.Errorf("Resolve(/p10): got %q %v %v", dest, ok, err)
+	}
+
+	empty, err := BuildCompact(nil)
+	if err != nil {
+		t.Fatalf("BuildCompact(nil): %v", err)
+	}
+	if _, ok := empty.Lookup("/p1"); ok || empty.Len() != 0 {
+		t.Errorf("the empty store found /p1 or has %d entries", empty.Len())
+	}
+}
+
+func TestBuildCompactErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry Entry
+		want  string
+	}{
+		{"parameter", Entry{Path: "/u/:name", URL: "https://example.com/:name"}, "/u/:name: CompactStore only holds exact paths"},
+		{"glob", Entry{Path: "/blog/**/draft", URL: "https://example.com"}, "only holds exact paths"},
+		{"wildcard", Entry{Path: "/docs/*", URL: "https://example.com"}, "only holds exact paths"},
+		{"regex", Entry{Path: "^/a$", URL: "https://example.com", Regex: true}, "only holds exact paths"},
+		{"host", Entry{Path: "/a", URL: "https://example.com", Host: "go.example.com"}, "only holds exact paths"},
+		{"relative path", Entry{Path: "a", URL: "https://example.com"}, `path "a" must start with /`},
+		{"duplicate", Entry{Path: "/p1", URL: "https://example.com/other"}, "/p1: duplicate path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildCompact([]Entry{{Path: "/p1", URL: "https://example.com/1"}, tt.entry})
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestCompactStoreHandlers(t *testing.T) {
+	cs, err := BuildCompact([]Entry{
+		{Path: "/gh", URL: "https://github.com"},
+		{Path: "/go", URL: "https://go.dev", Code: http.StatusMovedPermanently},
+	})
+	if err != nil {
+		t.Fatalf("BuildCompact: %v", err)
+	}
+	// the code of the entry is dropped for the one of the options
+	urlshorttest.TableTest(t, ResolverHandler(cs, urlshorttest.Fallback(), WithStatus(http.StatusTemporaryRedirect)), []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusTemporaryRedirect},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusTemporaryRedirect},
+		{Path: "/missing"},
+	})
+	store := NewMutableStore()
+	if err := store.Add("/docs", "https://docs.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	urlshorttest.TableTest(t, NewChain(urlshorttest.Fallback(), cs, store), []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+}
+
+// heapInUse returns the heap in use after a collection
+func heapInUse() uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+func TestCompactStoreMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds 1M entries twice")
+	}
+	const n = 1_000_000
+
+	base := heapInUse()
+	m := make(map[string]string)
+	for _, e := range compactEntries(n) {
+		m[e.Path] = e.URL
+	}
+	mapSize := heapInUse() - base
+
+	base = heapInUse()
+	cs, err := BuildCompact(compactEntries(n))
+	if err != nil {
+		t.Fatalf("BuildCompact: %v", err)
+	}
+	compactSize := heapInUse() - base
+
+	t.Logf("%d entries: map %.1f MB, CompactStore %.1f MB", n, float64(mapSize)/(1<<20), float64(compactSize)/(1<<20))
+	if compactSize > mapSize/2 {
+		t.Errorf("CompactStore uses %d bytes, more than half of the %d of the map", compactSize, mapSize)
+	}
+	if _, ok := cs.Lookup("/p123456"); !ok || len(m) != n {
+		t.Fatal("lost entries")
+	}
+	runtime.KeepAlive(m)
+	runtime.KeepAlive(cs)
+}
+
+func BenchmarkCompactStore(b *testing.B) {
+	const n = 1_000_000
+	entries := compactEntries(n)
+	m := make(map[string]string, n)
+	for _, e := range entries {
+		m[e.Path] = e.URL
+	}
+	cs, err := BuildCompact(entries)
+	if err != nil {
+		b.Fatal(err)
+	}
+	probes := make([]string, 1024)
+	for i := range probes {
+		probes[i] = fmt.Sprintf("/p%d", i*977%n)
+	}
+
+	b.Run("map/Lookup", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, ok := m[probes[i%len(probes)]]; !ok {
+				b.Fatal("miss")
+			}
+		}
+	})
+	b.Run("CompactStore/Lookup", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, ok := cs.Lookup(probes[i%len(probes)]); !ok {
+				b.Fatal("miss")
+			}
+		}
+	})
+	b.Run("CompactStore/Build", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := BuildCompact(entries); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package urlshort
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// confirmParam carries the signature of a destination the visitor
+// agreed to go to
+const confirmParam = "confirm"
+
+// ConfirmationData is what the confirmation page is executed with
+type ConfirmationData struct {
+	// Path is the short link that was requested
+	Path string
+	// Destination is the external site the link points at
+	Destination string
+	// Proceed is the link that redirects to Destination
+	Proceed string
+}
+
+// defaultConfirmationTemplate is the page shown before redirecting
+// to a host that isn't allowed
+var defaultConfirmationTemplate = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Leaving this site</title></head>
+<body>
+<h1>You are leaving this site</h1>
+<p>The short link <code>{{.Path}}</code> points at an external site:</p>
+<p><code>{{.Destination}}</code></p>
+<p><a href="{{.Proceed}}">Continue</a></p>
+</body>
+</html>
+`))
+
+// WithExternalConfirmation shows a confirmation page instead of
+// redirecting to destinations whose host isn't in allowedHosts.
+// Hosts may start with "*." to allow every subdomain, ports are
+// ignored. The page links back to the short link with a signature
+// of the destination, so the link can't be used to redirect
+// anywhere else. See WithConfirmationKey for running more than one
+// instance.
+func WithExternalConfirmation(allowedHosts []string) Option {
+	return func(c *config) {
+		if c.confirm == nil {
+			c.confirm = &confirmation{}
+		}
+		c.confirm.hosts, c.confirm.enabled = allowedHosts, true
+	}
+}
+
+// WithConfirmationKey sets the key proceed links of the
+// confirmation page are signed with. By default every handler gets
+// a random one, so links only work on the instance that made them.
+func WithConfirmationKey(key []byte) Option {
+	return func(c *config) {
+		if c.confirm == nil {
+			c.confirm = &confirmation{}
+		}
+		c.confirm.key = key
+	}
+}
+
+// confirmation decides which destinations need confirming
+type confirmation struct {
+	hosts []string
+	key   []byte
+	// enabled is false if only a key was given
+	enabled bool
+}
+
+// prepare fills in what the options left out
+func (c *confirmation) prepare() error {
+	if len(c.key) == 0 {
+		c.key = make([]byte, 32)
+		if _, err := rand.Read(c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowed reports whether dest can be redirected to without asking
+func (c *confirmation) allowed(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return false
+	}
+	if u.Host == "" {
+		// relative destinations stay on this site
+		return true
+	}
+	host := normalizeHost(u.Host)
+	for _, allowed := range c.hosts {
+		allowed = normalizeHost(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// sign returns the signature of dest for proceed links
+func (c *confirmation) sign(dest string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(dest))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify reports whether sig is the signature of dest
+func (c *confirmation) verify(sig, dest string) bool {
+	return sig != "" && hmac.Equal([]byte(sig), []byte(c.sign(dest)))
+}
+
+// takeConfirmation removes the confirmation signature from the query
+// of r and returns it
+func takeConfirmation(r *http.Request, cfg *config) (*http.Request, string) {
+	if cfg.confirm == nil || !cfg.confirm.enabled {
+		return r, ""
+	}
+	query := r.URL.Query()
+	sig := query.Get(confirmParam)
+	if sig == "" {
+		return r, ""
+	}
+
+	query.Del(confirmParam)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	stripped := r.Clone(r.Context())
+	stripped.URL = &u
+	stripped.RequestURI = u.RequestURI()
+	return stripped, sig
+}
+
+// needsConfirmation reports whether the redirect to dest has to be
+// confirmed first
+func needsConfirmation(dest, sig string, cfg *config) bool {
+	c := cfg.confirm
+	return c != nil && c.enabled && !c.allowed(dest) && !c.verify(sig, dest)
+}
+
+// serveConfirmation writes the page asking whether to go on to dest
+func serveConfirmation(w http.ResponseWriter, r *http.Request, pu pathUrl, dest string, cfg *config) {
+	// the link has to pass the token and signature checks again
+	query := originalQuery(r)
+	query.Set(confirmParam, cfg.confirm.sign(dest))
+	proceed := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+
+	var page bytes.Buffer
+	data := ConfirmationData{Path: pu.Path, Destination: dest, Proceed: proceed.String()}
+	if err := defaultConfirmationTemplate.Execute(&page, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	recordInfo(r, true, pu.Path, http.StatusOK)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+}
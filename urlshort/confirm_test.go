@@ -0,0 +1,117 @@
+package urlshort
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const confirmEntries = `
+- path: /docs
+  url: https://docs.example.com/start
+- path: /api
+  url: https://api.example.com:8443/v1
+- path: /intranet
+  url: https://wiki.corp.example.com
+- path: /corp
+  url: https://corp.example.com
+- path: /evil
+  url: https://evil.example.net/phish
+- path: /other
+  url: https://other.example.net
+- path: /local
+  url: /about
+- path: /u/:name
+  url: https://social.example.net/:name
+`
+
+// proceedLink matches the link of the confirmation page
+var proceedLink = regexp.MustCompile(`<a href="([^"]+)">`)
+
+// confirmationPage returns the proceed link of the confirmation page
+// res is, or fails t if it isn't one
+func confirmationPage(t *testing.T, res *httptest.ResponseRecorder, dest string) string {
+	t.Helper()
+	body := res.Body.String()
+	if res.Code != http.StatusOK || !strings.HasPrefix(res.Header().Get("Content-Type"), "text/html") || !strings.Contains(body, dest) {
+		t.Fatalf("got %d %q to %q, want a confirmation page for %s", res.Code, res.Header().Get("Content-Type"), res.Header().Get("Location"), dest)
+	}
+	m := proceedLink.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("the confirmation page has no proceed link:\n%s", body)
+	}
+	return html.UnescapeString(m[1])
+}
+
+func TestExternalConfirmation(t *testing.T) {
+	h, err := YAMLHandler([]byte(confirmEntries), urlshorttest.Fallback(),
+		WithRelativeDestinations(), WithExternalConfirmation([]string{"docs.example.com", "api.example.com:443", "*.corp.example.com"}))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+
+	// allowed hosts redirect right away
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/docs", Location: "https://docs.example.com/start", Code: http.StatusFound},
+		{Path: "/api", Location: "https://api.example.com:8443/v1", Code: http.StatusFound},
+		{Path: "/intranet", Location: "https://wiki.corp.example.com", Code: http.StatusFound},
+		{Path: "/local", Location: "/about", Code: http.StatusFound},
+	})
+
+	// everything else asks first, the wildcard doesn't cover the
+	// domain itself
+	for path, dest := range map[string]string{
+		"/evil":  "https://evil.example.net/phish",
+		"/corp":  "https://corp.example.com",
+		"/u/bob": "https://social.example.net/bob",
+	} {
+		t.Run(path, func(t *testing.T) {
+			proceed := confirmationPage(t, get(h, path), dest)
+			if !strings.HasPrefix(proceed, path+"?confirm=") {
+				t.Fatalf("proceed link %q doesn't lead back to %s", proceed, path)
+			}
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{{Path: proceed, Location: dest, Code: http.StatusFound}})
+		})
+	}
+}
+
+func TestExternalConfirmationForged(t *testing.T) {
+	h, err := YAMLHandler([]byte(confirmEntries), urlshorttest.Fallback(), WithRelativeDestinations(), WithExternalConfirmation(nil))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	evil := confirmationPage(t, get(h, "/evil"), "https://evil.example.net/phish")
+	sig := strings.TrimPrefix(evil, "/evil?confirm=")
+	bob := confirmationPage(t, get(h, "/u/bob"), "https://social.example.net/bob")
+
+	tests := []struct {
+		name   string
+		target string
+		dest   string
+	}{
+		{"made up", "/evil?confirm=AAAA", "https://evil.example.net/phish"},
+		{"empty", "/evil?confirm=", "https://evil.example.net/phish"},
+		{"signature of another link", "/other?confirm=" + sig, "https://other.example.net"},
+		{"signature of another parameter", strings.Replace(bob, "/u/bob", "/u/alice", 1), "https://social.example.net/alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confirmationPage(t, get(h, tt.target), tt.dest)
+		})
+	}
+
+	// another handler has another key, unless they share one
+	other, _ := YAMLHandler([]byte(confirmEntries), urlshorttest.Fallback(), WithRelativeDestinations(), WithExternalConfirmation(nil))
+	confirmationPage(t, get(other, evil), "https://evil.example.net/phish")
+
+	key := WithConfirmationKey([]byte("shared secret"))
+	a, _ := YAMLHandler([]byte(confirmEntries), urlshorttest.Fallback(), WithRelativeDestinations(), WithExternalConfirmation(nil), key)
+	b, _ := YAMLHandler([]byte(confirmEntries), urlshorttest.Fallback(), WithRelativeDestinations(), WithExternalConfirmation(nil), key)
+	proceed := confirmationPage(t, get(a, "/evil"), "https://evil.example.net/phish")
+	urlshorttest.TableTest(t, b, []urlshorttest.Case{{Path: proceed, Location: "https://evil.example.net/phish", Code: http.StatusFound}})
+}
@@ -0,0 +1,142 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// consulKV is the part of the consul KV API the watcher needs,
+// *api.KV implements it.
+type consulKV interface {
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+}
+
+// consulRetry is how long the watcher waits after a failed query
+const consulRetry = 5 * time.Second
+
+// ConsulWatcher serves redirects from the keys under a consul KV
+// prefix and keeps them up to date while running.
+type ConsulWatcher struct {
+	Handler
+
+	kv     consulKV
+	prefix string
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ConsulHandler loads all keys under prefix from consul and serves
+// them as redirects. The key without the prefix is the path (a
+// leading slash is added if missing), the value is the URL. If the
+// path is not found, the fallback http.Handler will be called
+// instead.
+//
+// A background goroutine watches the prefix with blocking queries
+// and swaps in the new mappings whenever keys change. If consul
+// becomes unreachable, the last mappings that loaded keep being
+// served. Call Close to stop the watcher.
+func ConsulHandler(client *api.Client, prefix string, fallback http.Handler, opts ...Option) (*ConsulWatcher, error) {
+	if client == nil {
+		return nil, errors.New("urlshort: consul client is nil")
+	}
+	return newConsulWatcher(client.KV(), prefix, fallback, opts)
+}
+
+func newConsulWatcher(kv consulKV, prefix string, fallback http.Handler, opts []Option) (*ConsulWatcher, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cw := &ConsulWatcher{
+		Handler: Handler{fallback: fallback, cfg: cfg, source: "consul"},
+		kv:      kv,
+		prefix:  prefix,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	// the first load has to work, there is nothing to serve otherwise
+	index, err := cw.load(ctx, 0)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go cw.watch(ctx, index)
+	return cw, nil
+}
+
+// Close stops the watcher and waits for it to finish. The handler
+// keeps serving the last mappings afterwards.
+func (cw *ConsulWatcher) Close() error {
+	cw.closeOnce.Do(func() {
+		cw.cancel()
+		<-cw.done
+	})
+	return nil
+}
+
+// watch keeps running blocking queries until the context is done
+func (cw *ConsulWatcher) watch(ctx context.Context, index uint64) {
+	defer close(cw.done)
+
+	for ctx.Err() == nil {
+		next, err := cw.load(ctx, index)
+		if err != nil {
+			// keep the last known good mappings and try again later
+			select {
+			case <-ctx.Done():
+			case <-time.After(consulRetry):
+			}
+			continue
+		}
+		// consul asks clients to start over if the index goes backwards
+		if next < index {
+			next = 0
+		}
+		index = next
+	}
+}
+
+// load lists the prefix, blocking until something changed after
+// index, and swaps in the new mappings. It returns the new index.
+func (cw *ConsulWatcher) load(ctx context.Context, index uint64) (uint64, error) {
+	q := (&api.QueryOptions{WaitIndex: index}).WithContext(ctx)
+	pairs, meta, err := cw.kv.List(cw.prefix, q)
+	if err != nil {
+		return 0, err
+	}
+	// blocking queries also return when they time out, nothing changed then
+	if index != 0 && meta.LastIndex == index {
+		return index, nil
+	}
+
+	pathUrls := make([]pathUrl, 0, len(pairs))
+	for _, pair := range pairs {
+		path, ok := keyPath(pair.Key, cw.prefix)
+		// folders in consul show up as keys ending in a slash without a value
+		if !ok || len(pair.Value) == 0 {
+			continue
+		}
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: string(pair.Value)})
+	}
+
+	if err := cw.Reload(pathUrls); err != nil {
+		return 0, err
+	}
+
+	return meta.LastIndex, nil
+}
@@ -0,0 +1,174 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeConsul is a consulKV whose List blocks like a blocking query
+// until the prefix changed after the wait index
+type fakeConsul struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	pairs   api.KVPairs
+	index   uint64
+	err     error
+	// failed counts the queries that returned err
+	failed int
+}
+
+func newFakeConsul(pairs map[string]string) *fakeConsul {
+	fc := &fakeConsul{}
+	fc.changed = sync.NewCond(&fc.mu)
+	fc.put(pairs, nil)
+	return fc
+}
+
+// put replaces the keys and the error of the next queries
+func (fc *fakeConsul) put(pairs map[string]string, err error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.pairs = nil
+	for k, v := range pairs {
+		fc.pairs = append(fc.pairs, &api.KVPair{Key: k, Value: []byte(v)})
+	}
+	fc.index++
+	fc.err = err
+	fc.changed.Broadcast()
+}
+
+func (fc *fakeConsul) List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	ctx := q.Context()
+	stop := context.AfterFunc(ctx, func() {
+		fc.mu.Lock()
+		fc.changed.Broadcast()
+		fc.mu.Unlock()
+	})
+	defer stop()
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for fc.index <= q.WaitIndex && fc.err == nil && ctx.Err() == nil {
+		fc.changed.Wait()
+	}
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	if fc.err != nil {
+		fc.failed++
+		return nil, nil, fc.err
+	}
+	var pairs api.KVPairs
+	for _, p := range fc.pairs {
+		if strings.HasPrefix(p.Key, prefix) {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs, &api.QueryMeta{LastIndex: fc.index}, nil
+}
+
+// eventually fails t unless ok returns true within a second
+func eventually(t *testing.T, what string, ok func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !ok() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestConsulWatcher(t *testing.T) {
+	fc := newFakeConsul(map[string]string{
+		"redirects/gh":      "https://github.com",
+		"redirects/docs/":   "",
+		"redirects/docs/go": "https://go.dev/doc",
+		"other/x":           "https://x.example.com",
+	})
+	cw, err := newConsulWatcher(fc, "redirects/", urlshorttest.Fallback(), nil)
+	if err != nil {
+		t.Fatalf("newConsulWatcher: %v", err)
+	}
+	defer cw.Close()
+	urlshorttest.TableTest(t, cw, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs/go", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "/docs/"},
+		{Path: "/x"},
+	})
+
+	// changes are swapped in
+	fc.put(map[string]string{"redirects/gl": "https://gitlab.com"}, nil)
+	eventually(t, "the update", func() bool { return get(cw, "/gl").Code == http.StatusFound })
+	urlshorttest.TableTest(t, cw, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/gh"},
+	})
+
+	// consul going away keeps the last mappings
+	fc.put(nil, errors.New("connection refused"))
+	eventually(t, "the failed query", func() bool {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		return fc.failed > 0
+	})
+	urlshorttest.TableTest(t, cw, []urlshorttest.Case{{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound}})
+
+	// Close returns although the watcher is waiting to retry
+	closed := make(chan struct{})
+	go func() {
+		cw.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't return")
+	}
+	urlshorttest.TableTest(t, cw, []urlshorttest.Case{{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound}})
+}
+
+func TestConsulHandlerErrors(t *testing.T) {
+	_, err := ConsulHandler(nil, "redirects/", urlshorttest.Fallback())
+	wantError(t, err, "consul client is nil")
+
+	// the first load has to work
+	fc := newFakeConsul(nil)
+	fc.put(nil, errors.New("connection refused"))
+	_, err = newConsulWatcher(fc, "redirects/", urlshorttest.Fallback(), nil)
+	wantError(t, err, "connection refused")
+}
+
+func TestConsulHandlerFallback(t *testing.T) {
+	pairs := map[string]string{"redirects/gh": "https://github.com"}
+
+	// without a fallback misses get a plain 404
+	cw, err := newConsulWatcher(newFakeConsul(pairs), "redirects/", nil, nil)
+	if err != nil {
+		t.Fatalf("newConsulWatcher: %v", err)
+	}
+	defer cw.Close()
+	if code := get(cw, "/missing").Code; code != http.StatusNotFound {
+		t.Errorf("GET /missing: got %d, want 404", code)
+	}
+
+	cw, err = newConsulWatcher(newFakeConsul(pairs), "redirects/", nil, []Option{WithDefaultDestination("https://example.com/home")})
+	if err != nil {
+		t.Fatalf("newConsulWatcher: %v", err)
+	}
+	defer cw.Close()
+	urlshorttest.AssertRedirect(t, cw, "/missing", "https://example.com/home", http.StatusFound)
+	urlshorttest.AssertRedirect(t, cw, "/gh", "https://github.com", http.StatusFound)
+
+	_, err = newConsulWatcher(newFakeConsul(pairs), "redirects/", urlshorttest.Fallback(), []Option{WithDefaultDestination("https://example.com/home")})
+	wantError(t, err, "WithDefaultDestination replaces the fallback")
+}
@@ -0,0 +1,85 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ctxKey is the type of the context keys used by this package
+type ctxKey int
+
+const (
+	requestInfoKey ctxKey = iota
+	suggestionsKey
+	proxyDestKey
+	languageKey
+	// hopsKey counts the rewrites of a request, see WithMaxHops
+	hopsKey
+	// lookupErrorKey holds the store error of a request, see LookupError
+	lookupErrorKey
+	// originalQueryKey holds the query before tokens and signatures
+	// were taken out of it, see keepQuery
+	originalQueryKey
+)
+
+// requestInfo is filled in by the handlers so wrappers like
+// Instrument can find out what happened to a request.
+type requestInfo struct {
+	matched bool
+	// err is what the store returned when the lookup failed
+	err error
+	// path is the configured path that matched
+	path   string
+	status int
+	// decided is when the lookup finished
+	decided time.Time
+}
+
+// withRequestInfo returns r with an empty requestInfo attached
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey, info)), info
+}
+
+// recordInfo fills in the requestInfo of r, if a wrapper attached one
+func recordInfo(r *http.Request, matched bool, path string, status int) {
+	info, ok := r.Context().Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return
+	}
+	info.matched, info.path, info.status = matched, path, status
+	info.decided = time.Now()
+}
+
+// recordError records the store error of r in its requestInfo
+func recordError(r *http.Request, err error) {
+	info, ok := r.Context().Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		return
+	}
+	info.err = err
+	info.decided = time.Now()
+}
+
+// keepQuery remembers the query of r in stripped, the copy of r
+// without the parameters the handler checked, so links back to the
+// entry like the one of the confirmation page can keep them. The
+// first query that was stripped is kept.
+func keepQuery(stripped, r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(originalQueryKey).(string); ok {
+		return stripped
+	}
+	return stripped.WithContext(context.WithValue(stripped.Context(), originalQueryKey, r.URL.RawQuery))
+}
+
+// originalQuery returns the query of r before anything was taken
+// out of it
+func originalQuery(r *http.Request) url.Values {
+	if raw, ok := r.Context().Value(originalQueryKey).(string); ok {
+		query, _ := url.ParseQuery(raw)
+		return query
+	}
+	return r.URL.Query()
+}
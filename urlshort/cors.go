@@ -0,0 +1,64 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS headers of preflight responses
+const (
+	corsMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsHeaders = "Authorization, Content-Type"
+	corsMaxAge  = "600"
+)
+
+// WithCORS lets pages from origins, like https://dash.example.com,
+// call the API from the browser. Preflight requests are answered
+// directly and actual responses get the CORS headers, requests from
+// other origins get none. "*" lets in every origin, but then
+// browsers don't send credentials, so only bearer tokens work.
+func WithCORS(origins []string) AdminOption {
+	return func(c *adminConfig) {
+		c.origins = append(c.origins, origins...)
+	}
+}
+
+// withCORS wraps handler with the CORS handling for origins
+func withCORS(handler http.Handler, origins []string) http.Handler {
+	wildcard := false
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			wildcard = true
+		}
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		// whether the headers are there depends on the origin, even
+		// with the wildcard requests without one get none, so caches
+		// must never hand a response to another origin
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" || (!wildcard && !allowed[origin]) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if wildcard {
+			// the spec doesn't allow credentials with a wildcard
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", corsMethods)
+			h.Set("Access-Control-Allow-Headers", corsHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,136 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// corsRequest records the response of h to a request from origin
+// with the given headers, origin may be empty
+func corsRequest(h http.Handler, method, target, origin string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestCORS(t *testing.T) {
+	store := NewMutableStore()
+	if err := store.Add("/gh", "https://github.com"); err != nil {
+		t.Fatal(err)
+	}
+	inspected, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	opts := []AdminOption{WithCORS([]string{"https://dash.example.com/", "http://localhost:5173"}), WithAdminToken("s3cret")}
+	handlers := map[string]struct {
+		h      http.Handler
+		target string
+	}{
+		"admin":   {AdminHandler(store, opts...), "/api/paths"},
+		"stats":   {StatsHandler(NewStats(), opts...), "/api/stats"},
+		"inspect": {InspectHandler(inspected, opts...), "/api/inspect?path=/gh"},
+	}
+	preflight := http.Header{"Access-Control-Request-Method": {"GET"}, "Access-Control-Request-Headers": {"Authorization"}}
+	authorized := http.Header{"Authorization": {"Bearer s3cret"}}
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		header      http.Header
+		code        int
+		allowOrigin string
+		preflight   bool
+	}{
+		// preflights carry no token and never reach the API
+		{"preflight", http.MethodOptions, "https://dash.example.com", preflight, http.StatusNoContent, "https://dash.example.com", true},
+		{"preflight second origin", http.MethodOptions, "http://localhost:5173", preflight, http.StatusNoContent, "http://localhost:5173", true},
+		{"simple", http.MethodGet, "https://dash.example.com", authorized, http.StatusOK, "https://dash.example.com", false},
+		{"simple without token", http.MethodGet, "https://dash.example.com", nil, http.StatusUnauthorized, "https://dash.example.com", false},
+		{"disallowed preflight", http.MethodOptions, "https://evil.example.net", preflight, http.StatusUnauthorized, "", false},
+		{"disallowed simple", http.MethodGet, "https://evil.example.net", authorized, http.StatusOK, "", false},
+		{"other scheme", http.MethodGet, "http://dash.example.com", authorized, http.StatusOK, "", false},
+		{"no origin", http.MethodGet, "", authorized, http.StatusOK, "", false},
+	}
+	for name, hh := range handlers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				res := corsRequest(hh.h, tt.method, hh.target, tt.origin, tt.header)
+				if res.Code != tt.code {
+					t.Errorf("got %d, want %d: %s", res.Code, tt.code, res.Body)
+				}
+				h := res.Header()
+				if got := h.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+					t.Errorf("Access-Control-Allow-Origin %q, want %q", got, tt.allowOrigin)
+				}
+				if h.Get("Vary") != "Origin" {
+					t.Errorf("Vary %q, want Origin", h.Get("Vary"))
+				}
+				if tt.allowOrigin == "" {
+					// a disallowed origin gets no CORS headers at all
+					for name := range h {
+						if strings.HasPrefix(name, "Access-Control-") {
+							t.Errorf("got %s for a disallowed origin", name)
+						}
+					}
+					return
+				}
+				if got := h.Get("Access-Control-Allow-Credentials"); got != "true" {
+					t.Errorf("Access-Control-Allow-Credentials %q, want true", got)
+				}
+				methods, headers := h.Get("Access-Control-Allow-Methods"), h.Get("Access-Control-Allow-Headers")
+				if tt.preflight {
+					if methods != corsMethods || headers != corsHeaders || h.Get("Access-Control-Max-Age") != corsMaxAge {
+						t.Errorf("preflight got methods %q, headers %q, max age %q", methods, headers, h.Get("Access-Control-Max-Age"))
+					}
+					if res.Body.Len() != 0 {
+						t.Errorf("preflight got body %q", res.Body)
+					}
+				} else if methods != "" || headers != "" {
+					t.Errorf("actual response got the preflight headers %q and %q", methods, headers)
+				}
+			})
+		}
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	h := StatsHandler(NewStats(), WithCORS([]string{"*"}))
+	tests := []struct {
+		name   string
+		method string
+		origin string
+		header http.Header
+		code   int
+		want   string
+	}{
+		{"preflight", http.MethodOptions, "https://anything.example.org", http.Header{"Access-Control-Request-Method": {"GET"}}, http.StatusNoContent, "*"},
+		{"simple", http.MethodGet, "https://anything.example.org", nil, http.StatusOK, "*"},
+		{"no origin", http.MethodGet, "", nil, http.StatusOK, ""},
+		// an OPTIONS that isn't a preflight goes through
+		{"plain options", http.MethodOptions, "https://anything.example.org", nil, http.StatusOK, "*"},
+	}
+	for _, tt := range tests {
+		res := corsRequest(h, tt.method, "/api/stats", tt.origin, tt.header)
+		if res.Code != tt.code {
+			t.Errorf("%s: got %d, want %d", tt.name, res.Code, tt.code)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want %q", tt.name, got, tt.want)
+		}
+		// browsers reject credentials with the wildcard, so they
+		// are never offered
+		if got := res.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("%s: Access-Control-Allow-Credentials %q with the wildcard", tt.name, got)
+		}
+	}
+}
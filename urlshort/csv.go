@@ -0,0 +1,98 @@
+package urlshort
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CSVOption changes how CSV mappings are read
+type CSVOption func(*csvConfig)
+
+type csvConfig struct {
+	lenient bool
+}
+
+// LenientCSV makes the CSV reader ignore any columns after
+// the path and url instead of rejecting the row.
+func LenientCSV() CSVOption {
+	return func(c *csvConfig) {
+		c.lenient = true
+	}
+}
+
+// CSVHandler works like YAMLHandler, but reads the mappings
+// as path,url rows from r. A header row (path,url) is detected
+// and skipped automatically, blank lines are ignored.
+//
+// CSV is expected to be in the format:
+//
+//	path,url
+//	/some-path,https://www.some-url.com/demo
+//
+// Errors for malformed rows include the line they were found on.
+func CSVHandler(r io.Reader, fallback http.Handler, opts ...CSVOption) (http.HandlerFunc, error) {
+	parsedCSV, err := ParseCSV(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(parsedCSV, fallback, mustConfig(nil))
+}
+
+// ParseCSV reads path,url rows from r without building a handler,
+// which is useful to validate a file before deploying it.
+func ParseCSV(r io.Reader, opts ...CSVOption) ([]pathUrl, error) {
+	var cfg csvConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	reader := csv.NewReader(r)
+	// column count is checked by hand below to give nicer errors
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var pathUrls []pathUrl
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("urlshort: invalid CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && isCSVHeader(record) {
+			continue
+		}
+
+		switch {
+		case len(record) < 2:
+			return nil, fmt.Errorf("urlshort: line %d: expected path,url but got %d column(s)", line, len(record))
+		case len(record) > 2 && !cfg.lenient:
+			return nil, fmt.Errorf("urlshort: line %d: expected path,url but got %d columns", line, len(record))
+		}
+
+		pu := pathUrl{
+			Path: strings.TrimSpace(record[0]),
+			URL:  strings.TrimSpace(record[1]),
+		}
+		if pu.Path == "" || pu.URL == "" {
+			return nil, fmt.Errorf("urlshort: line %d: path and url must not be empty", line)
+		}
+		pathUrls = append(pathUrls, pu)
+	}
+
+	return pathUrls, nil
+}
+
+// isCSVHeader reports whether record looks like a path,url header row
+func isCSVHeader(record []string) bool {
+	return len(record) >= 2 &&
+		strings.EqualFold(strings.TrimSpace(record[0]), "path") &&
+		strings.EqualFold(strings.TrimSpace(record[1]), "url")
+}
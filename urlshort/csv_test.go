@@ -0,0 +1,92 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		opts []CSVOption
+		want []pathUrl
+	}{
+		{"header", "path,url\n/gh,https://github.com\n", nil,
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}}},
+		{"header in other case", " Path , URL \n/gh,https://github.com\n", nil,
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}}},
+		{"no header", "/gh,https://github.com\n/go,https://go.dev", nil,
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}, {Path: "/go", URL: "https://go.dev"}}},
+		{"blank lines and spaces", "\n/gh, https://github.com \n\n\n/go,https://go.dev\n", nil,
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}, {Path: "/go", URL: "https://go.dev"}}},
+		{"quoted", "/search,\"https://example.com/?q=a,b\"\n", nil,
+			[]pathUrl{{Path: "/search", URL: "https://example.com/?q=a,b"}}},
+		// only the first row can be the header
+		{"path row later", "/gh,https://github.com\npath,url\n", nil,
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}, {Path: "path", URL: "url"}}},
+		{"lenient", "path,url,comment\n/gh,https://github.com,code\n", []CSVOption{LenientCSV()},
+			[]pathUrl{{Path: "/gh", URL: "https://github.com"}}},
+		{"empty", "", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCSV(strings.NewReader(tt.csv), tt.opts...)
+			if err != nil {
+				t.Fatalf("ParseCSV: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i].Path != tt.want[i].Path || got[i].URL != tt.want[i].URL {
+					t.Errorf("row %d: got %s,%s, want %s,%s", i, got[i].Path, got[i].URL, tt.want[i].Path, tt.want[i].URL)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		opts []CSVOption
+		want string
+	}{
+		{"one column", "path,url\n/gh,https://github.com\n/go\n", nil, "line 3: expected path,url but got 1 column(s)"},
+		{"three columns", "/gh,https://github.com,extra\n", nil, "line 1: expected path,url but got 3 columns"},
+		// lenient only allows more columns
+		{"one column lenient", "/go\n", []CSVOption{LenientCSV()}, "line 1: expected path,url but got 1 column(s)"},
+		{"empty path", "\n\n/gh,https://github.com\n ,https://go.dev\n", nil, "line 4: path and url must not be empty"},
+		{"empty url", "/gh,\"\"\n", nil, "line 1: path and url must not be empty"},
+		{"bare quote", "/gh,https://github.com/\"x\n", nil, "invalid CSV"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCSV(strings.NewReader(tt.csv), tt.opts...)
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestCSVHandler(t *testing.T) {
+	h, err := CSVHandler(strings.NewReader("path,url\n/gh,https://github.com\n/go,https://go.dev\n"), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("CSVHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/path"},
+	})
+
+	// the rows are checked like the entries of every handler
+	_, err = CSVHandler(strings.NewReader("/gh,https://github.com\n/gh,https://gitlab.com\n"), urlshorttest.Fallback())
+	wantError(t, err, "/gh")
+	_, err = CSVHandler(strings.NewReader("/gh,ftp://github.com\n"), urlshorttest.Fallback())
+	wantError(t, err, "/gh")
+}
@@ -0,0 +1,108 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectFallback returns a fallback that redirects every request
+// to dest with code, 302 if code is zero, for handlers that send
+// unmapped paths to a home page:
+//
+//	h := urlshort.MapHandler(paths, urlshort.RedirectFallback("https://www.example.com", 0))
+//
+// A request for dest itself gets a plain 404 instead, redirecting
+// it would loop. See WithDefaultDestination to have New build it.
+func RedirectFallback(dest string, code int) http.Handler {
+	if code == 0 {
+		code = http.StatusFound
+	}
+	target, err := url.Parse(dest)
+	if err != nil {
+		// like http.RedirectHandler, only a broken Location comes of it
+		target = &url.URL{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target.Path == r.URL.Path && (target.Host == "" || normalizeHost(target.Host) == normalizeHost(r.Host)) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, dest, code)
+	})
+}
+
+// WithDefaultDestination redirects requests for unmapped paths to
+// dest, with the status of WithStatus, instead of calling a
+// fallback, which must be nil then. dest must be a destination the
+// handler accepts for its entries.
+//
+// If dest points back at the handler, being relative or on one of
+// the WithSelfHosts hosts, it has to be a mapped path that redirects
+// away, otherwise building the handler fails: every miss would
+// redirect to a miss again.
+func WithDefaultDestination(dest string) Option {
+	return func(c *config) {
+		c.defaultDest = dest
+	}
+}
+
+// fallbackFor returns the handler unmapped paths are passed to:
+// fallback, the redirect of WithDefaultDestination, or a plain 404
+// without either
+func fallbackFor(fallback http.Handler, cfg *config) (http.Handler, error) {
+	if cfg.defaultDest != "" {
+		if fallback != nil {
+			return nil, errors.New("urlshort: WithDefaultDestination replaces the fallback, pass a nil one")
+		}
+		return RedirectFallback(cfg.defaultDest, cfg.status), nil
+	}
+	if fallback == nil {
+		return http.NotFoundHandler(), nil
+	}
+	return fallback, nil
+}
+
+// mustFallbackFor is fallbackFor for the handlers that panic on
+// invalid options
+func mustFallbackFor(fallback http.Handler, cfg *config) http.Handler {
+	fallback, err := fallbackFor(fallback, cfg)
+	if err != nil {
+		panic(err)
+	}
+	return fallback
+}
+
+// checkDefaultDestination follows the default destination through
+// the entries of the table while it points back at the handler, and
+// fails if it runs into a path that would get the fallback again
+func (rt *routeTable) checkDefaultDestination(cfg *config) error {
+	if cfg.defaultDest == "" {
+		return nil
+	}
+	var chain []string
+	seen := make(map[string]bool)
+	pu := pathUrl{URL: cfg.defaultDest}
+	for {
+		host, path, ok := ownDestination(pu, cfg)
+		if !ok {
+			return nil
+		}
+		m, ok := rt.lookupHost(host, path)
+		if !ok || m.entry.Disabled || m.entry.Mode == modeRewrite {
+			// rewrites are served by the fallback too
+			chain = append(chain, path)
+			return fmt.Errorf("urlshort: redirect loop: default destination -> %s -> default destination", strings.Join(chain, " -> "))
+		}
+		key := m.entry.Host + " " + m.entry.Path
+		if !redirects(m.entry) || seen[key] {
+			// loops between entries are reported by checkLoops
+			return nil
+		}
+		seen[key] = true
+		chain = append(chain, m.entry.Path)
+		pu = pathUrl{Host: m.entry.Host, URL: m.dest}
+	}
+}
@@ -0,0 +1,170 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaults of CheckOptions
+const (
+	defaultCheckConcurrency = 8
+	defaultCheckTimeout     = 10 * time.Second
+	defaultCheckRedirects   = 10
+)
+
+// CheckOptions changes how CheckDestinations probes destinations.
+// The zero value is usable, but follows no redirects, a negative
+// MaxRedirects picks the default.
+type CheckOptions struct {
+	// Concurrency is how many destinations are checked at once, the
+	// default is 8
+	Concurrency int
+	// Timeout limits the check of a single destination, redirects
+	// included. The default is 10s.
+	Timeout time.Duration
+	// MaxRedirects is how many redirects are followed before a check
+	// fails, a negative value picks the default of 10. Zero follows
+	// none, the status of the first answer is checked then.
+	MaxRedirects int
+	// AllowedStatus lists the statuses of the final answer that count
+	// as working, by default every 2xx does
+	AllowedStatus []int
+	// Client sends the requests, e.g. for a transport in tests. Its
+	// CheckRedirect is replaced to apply MaxRedirects.
+	Client *http.Client
+}
+
+// CheckResult is what CheckDestinations found out about one
+// destination of an entry
+type CheckResult struct {
+	Path string `json:"path"`
+	// URL is the destination that was checked
+	URL string `json:"url"`
+	// Status is the status of the final answer, zero if there was none
+	Status int `json:"status,omitempty"`
+	// FinalURL is the URL the final answer came from, after the
+	// redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// Error says why the check failed, empty if it succeeded
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Skipped is set for entries that can't be requested as they are,
+	// see LinkChecker, and for disabled ones
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// OK reports whether the check succeeded or was skipped
+func (cr CheckResult) OK() bool {
+	return cr.Error == ""
+}
+
+// CheckDestinations probes every destination of every entry once,
+// the url as well as the weighted, geo, lang, device and inactive
+// ones, with a HEAD request followed by a GET if the server doesn't
+// allow HEAD, e.g. to make sure a file of mappings only points at
+// working pages before deploying it. The results are in the order of
+// entries, those of an entry in the order of the fields. Once ctx is
+// done the checks still running fail with its error, and so do the
+// destinations that weren't checked yet.
+func CheckDestinations(ctx context.Context, entries []Entry, opts CheckOptions) []CheckResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultCheckConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultCheckTimeout
+	}
+	if opts.MaxRedirects < 0 {
+		opts.MaxRedirects = defaultCheckRedirects
+	}
+	client := &http.Client{}
+	if opts.Client != nil {
+		copied := *opts.Client
+		client = &copied
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if opts.MaxRedirects == 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) > opts.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", opts.MaxRedirects)
+		}
+		return nil
+	}
+
+	var results []CheckResult
+	var entryOf []int
+	for i, pu := range entries {
+		for _, dest := range pu.destinations() {
+			results = append(results, CheckResult{Path: pu.Path, URL: dest})
+			entryOf = append(entryOf, i)
+		}
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = checkDestination(ctx, client, entries[entryOf[i]], results[i].URL, opts)
+			}
+		}()
+	}
+	sent := 0
+	for sent < len(results) && ctx.Err() == nil {
+		select {
+		case next <- sent:
+			sent++
+		case <-ctx.Done():
+		}
+	}
+	close(next)
+	wg.Wait()
+	for i := sent; i < len(results); i++ {
+		results[i].Error = ctx.Err().Error()
+	}
+	return results
+}
+
+// checkDestination probes dest, one of the destinations of pu
+func checkDestination(ctx context.Context, client *http.Client, pu pathUrl, dest string, opts CheckOptions) CheckResult {
+	cr := CheckResult{Path: pu.Path, URL: dest}
+	if pu.Disabled || !checkable(dest) {
+		cr.Skipped = true
+		return cr
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, final, err := probe(ctx, client, dest)
+	cr.Duration = time.Since(start)
+	cr.Status, cr.FinalURL = status, final
+	switch {
+	case err != nil:
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			// the method and URL are in the result already
+			err = ue.Err
+		}
+		cr.Error = err.Error()
+	case !allowedStatus(status, opts.AllowedStatus):
+		cr.Error = fmt.Sprintf("status %d", status)
+	}
+	return cr
+}
+
+// allowedStatus reports whether status is one of allowed, or a 2xx
+// if allowed is empty
+func allowedStatus(status int, allowed []int) bool {
+	if len(allowed) == 0 {
+		return status >= 200 && status < 300
+	}
+	return slices.Contains(allowed, status)
+}
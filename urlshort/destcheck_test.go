@@ -0,0 +1,140 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckDestinations(t *testing.T) {
+	d := newDestinations(t)
+	entries := []Entry{
+		{Path: "/ok", URL: d.URL + "/ok"},
+		{Path: "/gone", URL: d.URL + "/gone"},
+		{Path: "/no-head", URL: d.URL + "/no-head"},
+		{Path: "/moved", URL: d.URL + "/moved"},
+		{Path: "/loop", URL: d.URL + "/loop"},
+		{Path: "/slow", URL: d.URL + "/slow"},
+		// every destination of an entry, each once
+		{Path: "/many", URL: d.URL + "/ok", URLs: []weightedURL{{URL: d.URL + "/ok", Weight: 1}, {URL: d.URL + "/broken", Weight: 1}},
+			Geo: map[string]string{"us": d.URL + "/moved", "de": d.URL + "/gone"}, InactiveURL: d.URL + "/no-head"},
+		// skipped
+		{Path: "/u/:name", URL: d.URL + "/u/:name"},
+		{Path: "/relative", URL: "/ok"},
+		{Path: "/disabled", URL: d.URL + "/gone", Disabled: true},
+	}
+	results := CheckDestinations(context.Background(), entries, CheckOptions{Timeout: 100 * time.Millisecond, MaxRedirects: -1})
+
+	want := []struct {
+		path, url string
+		status    int
+		final     string
+		err       string
+		skipped   bool
+	}{
+		{"/ok", "/ok", http.StatusOK, "/ok", "", false},
+		{"/gone", "/gone", http.StatusNotFound, "/gone", "status 404", false},
+		{"/no-head", "/no-head", http.StatusOK, "/no-head", "", false},
+		{"/moved", "/moved", http.StatusOK, "/ok", "", false},
+		{"/loop", "/loop", 0, "", "stopped after 10 redirects", false},
+		{"/slow", "/slow", 0, "", "context deadline exceeded", false},
+		{"/many", "/ok", http.StatusOK, "/ok", "", false},
+		{"/many", "/broken", http.StatusInternalServerError, "/broken", "status 500", false},
+		// geo by country code
+		{"/many", "/gone", http.StatusNotFound, "/gone", "status 404", false},
+		{"/many", "/moved", http.StatusOK, "/ok", "", false},
+		{"/many", "/no-head", http.StatusOK, "/no-head", "", false},
+		{"/u/:name", "/u/:name", 0, "", "", true},
+		{"/relative", "", 0, "", "", true},
+		{"/disabled", "/gone", 0, "", "", true},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		cr := results[i]
+		url := d.URL + w.url
+		if w.path == "/relative" {
+			url = "/ok"
+		}
+		final := ""
+		if w.final != "" {
+			final = d.URL + w.final
+		}
+		if cr.Path != w.path || cr.URL != url || cr.Status != w.status || cr.FinalURL != final || cr.Skipped != w.skipped {
+			t.Errorf("result %d: got %+v, want %+v", i, cr, w)
+		}
+		if w.err == "" && !cr.OK() || w.err != "" && !strings.Contains(cr.Error, w.err) {
+			t.Errorf("result %d, %s: got error %q, want %q", i, cr.URL, cr.Error, w.err)
+		}
+		if w.err != "" && strings.Contains(cr.Error, d.URL) {
+			t.Errorf("result %d: the error repeats the URL: %q", i, cr.Error)
+		}
+		if !w.skipped && cr.Duration <= 0 {
+			t.Errorf("result %d: took %v", i, cr.Duration)
+		}
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	d := newDestinations(t)
+	tests := []struct {
+		name   string
+		path   string
+		opts   CheckOptions
+		status int
+		err    string
+	}{
+		{"no redirects", "/moved", CheckOptions{}, http.StatusFound, "status 302"},
+		{"a redirect allowed", "/moved", CheckOptions{AllowedStatus: []int{http.StatusFound}}, http.StatusFound, ""},
+		{"enough redirects", "/moved-twice", CheckOptions{MaxRedirects: 2}, http.StatusOK, ""},
+		{"too many redirects", "/moved-twice", CheckOptions{MaxRedirects: 1}, 0, "stopped after 1 redirects"},
+		{"a 404 allowed", "/gone", CheckOptions{AllowedStatus: []int{http.StatusOK, http.StatusNotFound}}, http.StatusNotFound, ""},
+		{"a 200 not allowed", "/ok", CheckOptions{AllowedStatus: []int{http.StatusNoContent}}, http.StatusOK, "status 200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := CheckDestinations(context.Background(), []Entry{{Path: tt.path, URL: d.URL + tt.path}}, tt.opts)
+			if len(results) != 1 {
+				t.Fatalf("got %d results", len(results))
+			}
+			cr := results[0]
+			if cr.Status != tt.status || cr.Error != tt.err && !strings.Contains(cr.Error, tt.err) || tt.err == "" && !cr.OK() {
+				t.Errorf("got status %d and error %q, want %d and %q", cr.Status, cr.Error, tt.status, tt.err)
+			}
+		})
+	}
+
+	// the client is copied, not changed
+	client := &http.Client{}
+	CheckDestinations(context.Background(), []Entry{{Path: "/ok", URL: d.URL + "/ok"}}, CheckOptions{Client: client})
+	if client.CheckRedirect != nil {
+		t.Error("CheckDestinations set the CheckRedirect of the client")
+	}
+}
+
+func TestCheckDestinationsCancel(t *testing.T) {
+	d := newDestinations(t)
+	var entries []Entry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, Entry{Path: "/slow", URL: d.URL + "/slow"})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	results := CheckDestinations(ctx, entries, CheckOptions{Concurrency: 2})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %v after ctx was done", elapsed)
+	}
+	// the running checks and those that never started fail alike
+	for i, cr := range results {
+		if cr.Skipped || !strings.Contains(cr.Error, context.DeadlineExceeded.Error()) {
+			t.Errorf("result %d: got %+v", i, cr)
+		}
+	}
+	if requested := len(d.requests("/slow")); requested > 2 {
+		t.Errorf("%d destinations were requested, only 2 run at once", requested)
+	}
+}
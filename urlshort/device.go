@@ -0,0 +1,82 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// device classes of the device map
+const (
+	deviceIOS     = "ios"
+	deviceAndroid = "android"
+	deviceDesktop = "desktop"
+)
+
+// WithDeviceClassifier replaces the User-Agent matcher that sorts
+// requests into the device classes of device entries. classify
+// returns "ios", "android", "desktop" or "" if it can't tell, classes
+// without a destination of their own go to the default.
+func WithDeviceClassifier(classify func(*http.Request) string) Option {
+	return func(c *config) {
+		c.classifyDevice = classify
+	}
+}
+
+// checkDevice validates the device map of pu. The default
+// destination becomes the plain url so everything that doesn't know
+// about devices sees that one.
+func checkDevice(pu *pathUrl) error {
+	if len(pu.Device) == 0 {
+		return nil
+	}
+	if pu.URL != "" && pu.URL != pu.Device[mapDefault] || len(pu.URLs) > 0 || len(pu.Geo) > 0 || len(pu.Lang) > 0 {
+		return fmt.Errorf("urlshort: %s: use either device or url, urls, geo and lang, not both", pu.Path)
+	}
+	for class, dest := range pu.Device {
+		switch class {
+		case deviceIOS, deviceAndroid, deviceDesktop, mapDefault:
+		default:
+			return fmt.Errorf("urlshort: %s: unknown device class %q (use ios, android, desktop or default)", pu.Path, class)
+		}
+		if dest == "" {
+			return fmt.Errorf("urlshort: %s: device destination for %s is missing a url", pu.Path, class)
+		}
+	}
+	if pu.Device[mapDefault] == "" {
+		return fmt.Errorf("urlshort: %s: device needs a default destination", pu.Path)
+	}
+	pu.URL = pu.Device[mapDefault]
+	return nil
+}
+
+// deviceDestination picks the destination of a device entry for the
+// device class of r
+func deviceDestination(r *http.Request, pu pathUrl, cfg *config) string {
+	if dest, ok := pu.Device[cfg.classifyDevice(r)]; ok && dest != "" {
+		return dest
+	}
+	return pu.Device[mapDefault]
+}
+
+// classifyUserAgent is the built-in device classifier. It only goes by
+// tokens that are hard to get wrong and leaves everything else, like
+// tablets that claim to be desktops, to the default.
+func classifyUserAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad") || strings.Contains(ua, "iPod"):
+		return deviceIOS
+	case strings.Contains(ua, "Android"):
+		return deviceAndroid
+	case strings.Contains(ua, "Mobile"):
+		// some other phone
+		return ""
+	case strings.Contains(ua, "Windows NT") || strings.Contains(ua, "Macintosh") ||
+		strings.Contains(ua, "X11; Linux") || strings.Contains(ua, "CrOS"):
+		return deviceDesktop
+	}
+	return ""
+}
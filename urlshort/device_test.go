@@ -0,0 +1,121 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestClassifyUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{"iPhone Safari", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", deviceIOS},
+		{"iPhone Chrome", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1", deviceIOS},
+		{"iPad", "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1", deviceIOS},
+		{"Android phone", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", deviceAndroid},
+		{"Android tablet", "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", deviceAndroid},
+		{"Samsung Internet", "Mozilla/5.0 (Linux; Android 14; SAMSUNG SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36", deviceAndroid},
+		{"Windows Chrome", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", deviceDesktop},
+		{"Windows Edge", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67", deviceDesktop},
+		{"Mac Firefox", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14.4; rv:125.0) Gecko/20100101 Firefox/125.0", deviceDesktop},
+		{"Mac Safari", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15", deviceDesktop},
+		{"Linux Firefox", "Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", deviceDesktop},
+		{"ChromeOS", "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", deviceDesktop},
+		// other phones and clients that aren't browsers are left alone
+		{"BlackBerry", "Mozilla/5.0 (BB10; Touch) AppleWebKit/537.10+ (KHTML, like Gecko) Version/10.0.9.2372 Mobile Safari/537.10+", ""},
+		{"curl", "curl/8.5.0", ""},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/app", nil)
+		req.Header.Set("User-Agent", tt.ua)
+		if got := classifyUserAgent(req); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+const deviceEntries = `
+- path: /app
+  device:
+    ios: https://apps.apple.com/app/id1
+    android: https://play.google.com/store/apps/details?id=com.example
+    default: https://www.example.com/app
+- path: /desk
+  device:
+    desktop: https://www.example.com/download
+    default: https://m.example.com
+`
+
+func TestDeviceDestinations(t *testing.T) {
+	h, err := YAMLHandler([]byte(deviceEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	const (
+		iphone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1"
+		android = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36"
+		windows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+	)
+	tests := []struct {
+		path, ua string
+		want     string
+	}{
+		{"/app", iphone, "https://apps.apple.com/app/id1"},
+		{"/app", android, "https://play.google.com/store/apps/details?id=com.example"},
+		{"/app", windows, "https://www.example.com/app"},
+		{"/app", "curl/8.5.0", "https://www.example.com/app"},
+		{"/desk", windows, "https://www.example.com/download"},
+		{"/desk", iphone, "https://m.example.com"},
+	}
+	for _, tt := range tests {
+		res := requestFrom(h, tt.path, "198.51.100.1:5000", http.Header{"User-Agent": {tt.ua}})
+		if res.Code != http.StatusFound || res.Header().Get("Location") != tt.want {
+			t.Errorf("GET %s as %.30s: got %d to %q, want 302 to %q", tt.path, tt.ua, res.Code, res.Header().Get("Location"), tt.want)
+		}
+	}
+}
+
+func TestWithDeviceClassifier(t *testing.T) {
+	h, err := YAMLHandler([]byte(deviceEntries), urlshorttest.Fallback(), WithDeviceClassifier(func(r *http.Request) string {
+		return r.URL.Query().Get("device")
+	}))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/app?device=ios", Location: "https://apps.apple.com/app/id1", Code: http.StatusFound},
+		{Path: "/app?device=android", Location: "https://play.google.com/store/apps/details?id=com.example", Code: http.StatusFound},
+		{Path: "/app?device=desktop", Location: "https://www.example.com/app", Code: http.StatusFound},
+		{Path: "/app?device=fridge", Location: "https://www.example.com/app", Code: http.StatusFound},
+	})
+
+	_, err = YAMLHandler([]byte(deviceEntries), urlshorttest.Fallback(), WithDeviceClassifier(nil))
+	wantError(t, err, "device classifier is nil")
+}
+
+func TestDeviceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no default", "- path: /app\n  device: {ios: https://apps.apple.com/app/id1}\n", "/app: device needs a default destination"},
+		{"unknown class", "- path: /app\n  device: {watch: https://w.example.com, default: https://example.com}\n",
+			`unknown device class "watch" (use ios, android, desktop or default)`},
+		{"empty url", "- path: /app\n  device: {ios: '', default: https://example.com}\n", "device destination for ios is missing a url"},
+		{"url too", "- path: /app\n  url: https://a.example.com\n  device: {default: https://example.com}\n", "use either device or url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
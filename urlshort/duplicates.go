@@ -0,0 +1,79 @@
+package urlshort
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy decides what happens when the same path is
+// configured more than once
+type DuplicatePolicy int
+
+const (
+	// DuplicateError refuses to build the handler, the default
+	DuplicateError DuplicatePolicy = iota
+	// FirstWins keeps the first entry for a path
+	FirstWins
+	// LastWins keeps the last entry for a path, which is how
+	// handlers behaved before duplicates were detected
+	LastWins
+)
+
+// WithDuplicatePolicy sets what happens when a path is configured
+// more than once. Paths are compared after the normalization options
+// in effect, so /GH and /gh are duplicates with
+// WithCaseInsensitivePaths, and /x and /x/ with
+// WithSlashNormalization.
+func WithDuplicatePolicy(p DuplicatePolicy) Option {
+	return func(c *config) {
+		c.duplicates = p
+	}
+}
+
+// dedupe applies the duplicate policy to pathUrls and returns the
+// entries that are left, in their original order.
+func (rt *routeTable) dedupe(pathUrls []pathUrl, policy DuplicatePolicy) ([]pathUrl, error) {
+	// index of the entry that currently owns each key in kept
+	owner := make(map[string]int, len(pathUrls))
+	kept := make([]pathUrl, 0, len(pathUrls))
+
+	for _, pu := range pathUrls {
+		key := rt.duplicateKey(pu)
+		i, ok := owner[key]
+		if !ok {
+			owner[key] = len(kept)
+			kept = append(kept, pu)
+			continue
+		}
+
+		prev := kept[i]
+		switch policy {
+		case FirstWins:
+		case LastWins:
+			kept[i] = pu
+		default:
+			return nil, fmt.Errorf("urlshort: duplicate path: %s -> %s and %s -> %s",
+				prev.describe(), prev.URL, pu.describe(), pu.URL)
+		}
+	}
+	return kept, nil
+}
+
+// duplicateKey returns the key two entries share if they claim the
+// same requests
+func (rt *routeTable) duplicateKey(pu pathUrl) string {
+	// the same path on different hosts is no conflict
+	var host string
+	if pu.Host != "" {
+		host = normalizeHost(pu.Host) + " "
+	}
+	if pu.Regex {
+		// regexes are only duplicates if they are spelled the same
+		return host + "regex:" + pu.Path
+	}
+	key := rt.normalize(configPath(pu))
+	if rt.slashes && key != "/" && !isWildcard(key) {
+		key = strings.TrimSuffix(key, "/")
+	}
+	return host + key
+}
@@ -0,0 +1,81 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const duplicateEntries = `
+- path: /gh
+  url: https://github.com
+- path: /go
+  url: https://go.dev
+- path: /gh
+  url: https://github.com/nils
+`
+
+func TestDuplicatePolicy(t *testing.T) {
+	_, err := YAMLHandler([]byte(duplicateEntries), urlshorttest.Fallback())
+	wantError(t, err, "urlshort: duplicate path: /gh -> https://github.com and /gh -> https://github.com/nils")
+	// the default is DuplicateError
+	_, err = YAMLHandler([]byte(duplicateEntries), urlshorttest.Fallback(), WithDuplicatePolicy(DuplicateError))
+	wantError(t, err, "duplicate path: /gh")
+
+	tests := []struct {
+		policy DuplicatePolicy
+		want   string
+	}{
+		{FirstWins, "https://github.com"},
+		{LastWins, "https://github.com/nils"},
+	}
+	for _, tt := range tests {
+		h, err := New(FromYAML([]byte(duplicateEntries)), urlshorttest.Fallback(), WithDuplicatePolicy(tt.policy))
+		if err != nil {
+			t.Fatalf("policy %d: %v", tt.policy, err)
+		}
+		urlshorttest.TableTest(t, h, []urlshorttest.Case{
+			{Path: "/gh", Location: tt.want, Code: http.StatusFound},
+			{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		})
+		// the kept entry stays where the first one was
+		if got := h.Snapshot(); len(got) != 2 || got[0].Path != "/gh" || got[0].URL != tt.want || got[1].Path != "/go" {
+			t.Errorf("policy %d: got entries %v", tt.policy, got)
+		}
+	}
+
+	_, err = YAMLHandler([]byte(duplicateEntries), urlshorttest.Fallback(), WithDuplicatePolicy(DuplicatePolicy(7)))
+	wantError(t, err, "unknown duplicate policy 7")
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		dup  bool
+	}{
+		{"same path", "- path: /a\n  url: https://a.example.com\n- path: /a\n  url: https://b.example.com\n", true},
+		// paths are compared after cleaning
+		{"cleaned", "- path: /a\n  url: https://a.example.com\n- path: //a\n  url: https://b.example.com\n", true},
+		{"dot segments", "- path: /a/b\n  url: https://a.example.com\n- path: /a/x/../b\n  url: https://b.example.com\n", true},
+		{"other hosts", "- path: /a\n  url: https://a.example.com\n  host: a.example.com\n- path: /a\n  url: https://b.example.com\n  host: b.example.com\n", false},
+		{"host case", "- path: /a\n  url: https://a.example.com\n  host: a.example.com\n- path: /a\n  url: https://b.example.com\n  host: A.example.com\n", true},
+		{"host and generic", "- path: /a\n  url: https://a.example.com\n  host: a.example.com\n- path: /a\n  url: https://b.example.com\n", false},
+		{"same regex", "- path: ^/a$\n  url: https://a.example.com\n  regex: true\n- path: ^/a$\n  url: https://b.example.com\n  regex: true\n", true},
+		// regexes aren't compared by what they match
+		{"equivalent regex", "- path: ^/a$\n  url: https://a.example.com\n  regex: true\n- path: ^/(a)$\n  url: https://b.example.com\n  regex: true\n", false},
+		{"case", "- path: /a\n  url: https://a.example.com\n- path: /A\n  url: https://b.example.com\n", false},
+		{"slash", "- path: /a\n  url: https://a.example.com\n- path: /a/\n  url: https://b.example.com\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), urlshorttest.Fallback())
+			if tt.dup {
+				wantError(t, err, "duplicate path")
+			} else if err != nil {
+				t.Errorf("got %v, want no duplicates", err)
+			}
+		})
+	}
+}
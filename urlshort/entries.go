@@ -0,0 +1,168 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// mapDefault is the key of the geo, lang and device maps used for
+// requests none of the other keys fit
+const mapDefault = "default"
+
+// prepareEntries checks the optional per-entry fields and fills in
+// the unexported fields derived from them. Every parser runs it, so
+// entries are complete before a route table is built from them.
+func prepareEntries(pathUrls []pathUrl) error {
+	for i := range pathUrls {
+		if err := prepareEntry(&pathUrls[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareEntry does the work of prepareEntries for a single entry
+func prepareEntry(pu *pathUrl) error {
+	switch pu.Code {
+	case 0, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("urlshort: %s: unsupported redirect code %d (use 301, 302, 307 or 308)", pu.Path, pu.Code)
+	}
+
+	if err := checkWeighted(pu); err != nil {
+		return err
+	}
+	if err := checkGeo(pu); err != nil {
+		return err
+	}
+	if err := checkLang(pu); err != nil {
+		return err
+	}
+	if err := checkDevice(pu); err != nil {
+		return err
+	}
+	if err := checkMode(pu); err != nil {
+		return err
+	}
+	if err := checkTemplate(pu); err != nil {
+		return err
+	}
+	if err := checkAliases(pu); err != nil {
+		return err
+	}
+	if err := checkDelay(pu); err != nil {
+		return err
+	}
+	if err := checkRedirect(pu); err != nil {
+		return err
+	}
+	if err := checkCacheMaxAge(pu); err != nil {
+		return err
+	}
+
+	if pu.Expires != "" {
+		t, err := time.Parse(time.RFC3339, pu.Expires)
+		if err != nil {
+			return fmt.Errorf("urlshort: %s: invalid expires timestamp %q (use RFC 3339)", pu.Path, pu.Expires)
+		}
+		pu.expiresAt = t
+	}
+	if err := checkWindow(pu); err != nil {
+		return err
+	}
+	if err := checkUTM(pu); err != nil {
+		return err
+	}
+	if err := checkCIDRs(pu); err != nil {
+		return err
+	}
+	if name, ok := forbiddenHeader(pu.Headers); ok {
+		return fmt.Errorf("urlshort: %s: the %s header can't be set, the redirect sets it", pu.Path, name)
+	}
+	return nil
+}
+
+// expired reports whether the entry has an expiry that passed
+func (pu pathUrl) expired(now time.Time) bool {
+	return !pu.expiresAt.IsZero() && !now.Before(pu.expiresAt)
+}
+
+// destinationMaps returns the geo, lang and device maps of pu, for
+// the code that has to touch every destination of an entry
+func (pu *pathUrl) destinationMaps() []*map[string]string {
+	return []*map[string]string{&pu.Geo, &pu.Lang, &pu.Device}
+}
+
+// mapDestinations replaces the destination maps of pu with copies
+// that have fn applied to every destination
+func (pu *pathUrl) mapDestinations(fn func(string) string) {
+	for _, m := range pu.destinationMaps() {
+		if len(*m) == 0 {
+			continue
+		}
+		mapped := make(map[string]string, len(*m))
+		for k, v := range *m {
+			mapped[k] = fn(v)
+		}
+		*m = mapped
+	}
+}
+
+// destinations returns every destination pu can redirect to once,
+// the url, the weighted urls, the geo, lang and device destinations
+// sorted by key and the inactive url
+func (pu pathUrl) destinations() []string {
+	var dests []string
+	seen := make(map[string]bool)
+	add := func(dest string) {
+		if dest != "" && !seen[dest] {
+			seen[dest] = true
+			dests = append(dests, dest)
+		}
+	}
+	add(pu.URL)
+	for _, wu := range pu.URLs {
+		add(wu.URL)
+	}
+	for _, m := range pu.destinationMaps() {
+		keys := make([]string, 0, len(*m))
+		for k := range *m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			add((*m)[k])
+		}
+	}
+	add(pu.InactiveURL)
+	return dests
+}
+
+// anyMapDestination reports whether fn is true for a destination in
+// one of the destination maps of pu
+func (pu pathUrl) anyMapDestination(fn func(string) bool) bool {
+	for _, m := range pu.destinationMaps() {
+		for _, dest := range *m {
+			if fn(dest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ownKeys returns the sorted keys of a destination map other than
+// the default
+func ownKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		if key != mapDefault {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
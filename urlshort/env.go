@@ -0,0 +1,73 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// EnvHandler builds the mappings from environment variables whose
+// name starts with prefix and serves them like YAMLHandler does. The
+// value of a variable is the URL, its name after the prefix becomes
+// the path:
+//
+//   - the name is lowercased and a leading slash is added
+//   - a double underscore becomes a slash, for nested paths
+//   - a single underscore becomes a dash, since variable names
+//     can't contain dashes
+//
+// So with the prefix "URLSHORT_":
+//
+//	URLSHORT_gh=https://github.com             -> /gh
+//	URLSHORT_GH=https://github.com             -> /gh
+//	URLSHORT_docs__api=https://...             -> /docs/api
+//	URLSHORT_release_notes=https://...         -> /release-notes
+//	URLSHORT_docs__getting_started=https://... -> /docs/getting-started
+//	URLSHORT_=https://...                      -> /
+//
+// Paths with underscores or upper case letters can't be expressed,
+// use one of the file formats for those. An invalid URL fails with
+// the name of the variable in the error. The prefix can't be empty,
+// every variable of the process would be a mapping then.
+func EnvHandler(prefix string, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	if prefix == "" {
+		return nil, errors.New("urlshort: EnvHandler needs a prefix")
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pathUrls, err := parseEnv(os.Environ(), prefix, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(pathUrls, fallback, cfg)
+}
+
+// parseEnv turns the NAME=value pairs starting with prefix into entries
+func parseEnv(environ []string, prefix string, cfg *config) ([]pathUrl, error) {
+	var pathUrls []pathUrl
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if reason := destinationIssue(value, cfg); reason != "" {
+			return nil, fmt.Errorf("urlshort: %s: %s", name, reason)
+		}
+		pathUrls = append(pathUrls, pathUrl{Path: envPath(strings.TrimPrefix(name, prefix)), URL: value})
+	}
+	return pathUrls, nil
+}
+
+// envPath applies the name mangling rules of EnvHandler
+func envPath(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, "__", "/")
+	name = strings.ReplaceAll(name, "_", "-")
+	return "/" + name
+}
@@ -0,0 +1,92 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestEnvPath(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"gh", "/gh"},
+		{"GH", "/gh"},
+		{"Docs__API", "/docs/api"},
+		{"release_notes", "/release-notes"},
+		{"docs__getting_started", "/docs/getting-started"},
+		{"a__b__c", "/a/b/c"},
+		{"", "/"},
+	}
+	for _, tt := range tests {
+		if got := envPath(tt.name); got != tt.want {
+			t.Errorf("envPath(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	environ := []string{
+		"HOME=/root",
+		"URLSHORT_GH=https://github.com",
+		"URLSHORT_docs__getting_started=https://go.dev/doc",
+		"URLSHORT_=https://example.com",
+		"urlshort_lower=https://example.com/lower",
+		"MY_URLSHORT_X=https://example.com/x",
+		"URLSHORT_broken",
+	}
+	got, err := parseEnv(environ, "URLSHORT_", mustConfig(nil))
+	if err != nil {
+		t.Fatalf("parseEnv: %v", err)
+	}
+	// only the names starting with the prefix, which matches case
+	want := []pathUrl{
+		{Path: "/gh", URL: "https://github.com"},
+		{Path: "/docs/getting-started", URL: "https://go.dev/doc"},
+		{Path: "/", URL: "https://example.com"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Path != want[i].Path || got[i].URL != want[i].URL {
+			t.Errorf("entry %d: got %s -> %s, want %s -> %s", i, got[i].Path, got[i].URL, want[i].Path, want[i].URL)
+		}
+	}
+}
+
+func TestParseEnvErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		environ []string
+		want    string
+	}{
+		{"scheme", []string{"URLSHORT_gh=ftp://github.com"}, "URLSHORT_gh"},
+		{"no host", []string{"URLSHORT_gh=https://"}, "URLSHORT_gh"},
+		{"empty", []string{"URLSHORT_ok=https://github.com", "URLSHORT_empty="}, "URLSHORT_empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseEnv(tt.environ, "URLSHORT_", mustConfig(nil))
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestEnvHandler(t *testing.T) {
+	t.Setenv("URLSHORT_TEST_gh", "https://github.com")
+	t.Setenv("URLSHORT_TEST_docs__api", "https://go.dev/doc/api")
+	h, err := EnvHandler("URLSHORT_TEST_", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("EnvHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs/api", Location: "https://go.dev/doc/api", Code: http.StatusFound},
+		{Path: "/home"},
+	})
+
+	_, err = EnvHandler("", urlshorttest.Fallback())
+	wantError(t, err, "EnvHandler needs a prefix")
+}
@@ -0,0 +1,102 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorHandler answers a request whose lookup failed because the
+// store behind a handler returned err, see WithErrorHandler
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// WithErrorHandler makes handlers backed by a store, like
+// ResolverHandler, RedisHandler or SQLHandler, answer requests whose
+// lookup failed with fn. fn can serve a page of its own, log and
+// call FallThrough, or use ServeUnavailable. Without it the request
+// goes to the fallback, as if it was FallThrough.
+//
+// Hooks and wrappers can tell such requests from misses with
+// LookupError, Instrument counts them as urlshort_lookup_errors_total
+// instead of fallbacks.
+func WithErrorHandler(fn ErrorHandler) Option {
+	return func(c *config) {
+		c.errorHandler = fn
+	}
+}
+
+// WithUnavailableOnError answers requests whose lookup failed with
+// 503 Service Unavailable instead of passing them to the fallback,
+// it is the same as WithErrorHandler(ServeUnavailable(0))
+func WithUnavailableOnError() Option {
+	return func(c *config) {
+		c.unavailableOnError = true
+	}
+}
+
+// ServeUnavailable answers with 503 Service Unavailable, and a
+// Retry-After header if retryAfter is at least a second
+func ServeUnavailable(retryAfter time.Duration) ErrorHandler {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if secs := int(retryAfter / time.Second); secs > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+		}
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
+
+// FallThrough passes the request on to the fallback of the handler,
+// like a path that isn't mapped. The miss hook and Stats see it as a
+// miss, LookupError still reports err.
+func FallThrough() ErrorHandler {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		le, ok := r.Context().Value(lookupErrorKey).(*lookupError)
+		if !ok {
+			// not called by one of our handlers, there is no fallback
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		callFallback(w, r, le.fallback, le.cfg)
+	}
+}
+
+// LookupError returns the store error of a request passed to an
+// ErrorHandler, nil if the lookup of r didn't fail. It works in the
+// hooks as well, asynchronous ones included.
+func LookupError(r *http.Request) error {
+	if le, ok := r.Context().Value(lookupErrorKey).(*lookupError); ok {
+		return le.err
+	}
+	return nil
+}
+
+// lookupError is what serveError attaches to a request
+type lookupError struct {
+	err      error
+	fallback http.Handler
+	cfg      *config
+}
+
+// serveError answers r, whose lookup failed with err, by the error
+// handler of cfg
+func serveError(w http.ResponseWriter, r *http.Request, err error, fallback http.Handler, cfg *config) {
+	if cfg.accessLog != nil {
+		lw := cfg.accessLog.wrap(w)
+		defer cfg.accessLog.log(r, lw, cfg.now())
+		w = lw.writer()
+	}
+	defer cfg.recoverPanic(w)
+
+	recordError(r, err)
+	r = r.WithContext(context.WithValue(r.Context(), lookupErrorKey, &lookupError{err: err, fallback: fallback, cfg: cfg}))
+	handler := cfg.errorHandler
+	switch {
+	case handler != nil:
+	case cfg.unavailableOnError:
+		handler = ServeUnavailable(0)
+	default:
+		handler = FallThrough()
+	}
+	handler(w, r, err)
+}
@@ -0,0 +1,155 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errStoreDown = errors.New("store down")
+
+// flakyResolver maps /gh and fails every lookup while failing is set
+type flakyResolver struct {
+	failing atomic.Bool
+	calls   atomic.Int64
+}
+
+func (fr *flakyResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	fr.calls.Add(1)
+	if fr.failing.Load() {
+		return "", false, errStoreDown
+	}
+	if path == "/gh" {
+		return "https://github.com", true, nil
+	}
+	return "", false, nil
+}
+
+func TestErrorHandlerDefault(t *testing.T) {
+	res := &flakyResolver{}
+	var misses []error
+	h := ResolverHandler(res, urlshorttest.Fallback(), WithMissHook(func(r *http.Request) {
+		misses = append(misses, LookupError(r))
+	}))
+
+	// a failing store is a miss by default, the hook can tell it apart
+	res.failing.Store(true)
+	urlshorttest.AssertFallback(t, h, "/gh")
+	res.failing.Store(false)
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+	if len(misses) != 2 || !errors.Is(misses[0], errStoreDown) || misses[1] != nil {
+		t.Errorf("the miss hook saw errors %v, want [store down <nil>]", misses)
+	}
+}
+
+func TestErrorHandlers(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		code       int
+		retryAfter string
+		fallback   bool
+	}{
+		{"unavailable on error", []Option{WithUnavailableOnError()}, http.StatusServiceUnavailable, "", false},
+		{"serve unavailable", []Option{WithErrorHandler(ServeUnavailable(0))}, http.StatusServiceUnavailable, "", false},
+		{"retry after", []Option{WithErrorHandler(ServeUnavailable(30 * time.Second))}, http.StatusServiceUnavailable, "30", false},
+		{"retry after rounds down", []Option{WithErrorHandler(ServeUnavailable(90*time.Second + 900*time.Millisecond))}, http.StatusServiceUnavailable, "90", false},
+		{"retry after under a second", []Option{WithErrorHandler(ServeUnavailable(500 * time.Millisecond))}, http.StatusServiceUnavailable, "", false},
+		{"fall through", []Option{WithErrorHandler(FallThrough())}, http.StatusNotFound, "", true},
+		// the error handler wins over the 503
+		{"both", []Option{WithUnavailableOnError(), WithErrorHandler(FallThrough())}, http.StatusNotFound, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &flakyResolver{}
+			res.failing.Store(true)
+			h := ResolverHandler(res, urlshorttest.Fallback(), tt.opts...)
+			w := get(h, "/gh")
+			if w.Code != tt.code || w.Header().Get("Retry-After") != tt.retryAfter {
+				t.Errorf("got %d with Retry-After %q, want %d with %q", w.Code, w.Header().Get("Retry-After"), tt.code, tt.retryAfter)
+			}
+			if fallback := w.Header().Get("X-Urlshorttest-Fallback") != ""; fallback != tt.fallback {
+				t.Errorf("reached the fallback: %v, want %v", fallback, tt.fallback)
+			}
+
+			// genuine misses and a store that is back aren't affected
+			res.failing.Store(false)
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/missing"},
+			})
+		})
+	}
+}
+
+func TestWithErrorHandler(t *testing.T) {
+	res := &flakyResolver{}
+	res.failing.Store(true)
+	var got error
+	h := ResolverHandler(res, urlshorttest.Fallback(), WithCache(time.Minute),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			got = err
+			if LookupError(r) != err {
+				t.Errorf("LookupError: got %v, want %v", LookupError(r), err)
+			}
+			w.WriteHeader(http.StatusTeapot)
+		}))
+	if w := get(h, "/gh"); w.Code != http.StatusTeapot || !errors.Is(got, errStoreDown) {
+		t.Errorf("got %d and error %v", w.Code, got)
+	}
+
+	// errors aren't cached, the next request asks the store again
+	res.failing.Store(false)
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	if calls := res.calls.Load(); calls != 2 {
+		t.Errorf("the store was asked %d times, want 2", calls)
+	}
+	res.failing.Store(true)
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+}
+
+func TestFallThroughOutsideHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	FallThrough()(w, httptest.NewRequest(http.MethodGet, "/gh", nil), errStoreDown)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got %d, want 503", w.Code)
+	}
+	if err := LookupError(httptest.NewRequest(http.MethodGet, "/gh", nil)); err != nil {
+		t.Errorf("LookupError of a plain request: %v", err)
+	}
+}
+
+func TestLookupErrorMetrics(t *testing.T) {
+	res := &flakyResolver{}
+	reg := prometheus.NewRegistry()
+	h := Instrument(ResolverHandler(res, urlshorttest.Fallback()), reg)
+	get(h, "/gh")
+	get(h, "/missing")
+	res.failing.Store(true)
+	get(h, "/gh")
+	get(h, "/missing")
+
+	got := scrape(t, reg)
+	want := map[string]float64{
+		`urlshort_redirects_total{code=302,path=/gh}`: 1,
+		// store failures count as errors, not as fallbacks
+		`urlshort_fallbacks_total`:         1,
+		`urlshort_lookup_errors_total`:     2,
+		`urlshort_lookup_duration_seconds`: 4,
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %v, want %v", key, got[key], value)
+		}
+	}
+}
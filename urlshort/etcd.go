@@ -0,0 +1,199 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// EtcdKV is the part of the etcd client EtcdHandler needs,
+// *clientv3.Client implements it. Tests can pass a fake.
+type EtcdKV interface {
+	Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error)
+	Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan
+}
+
+// etcdRetry is how long the watcher waits after a failed list
+const etcdRetry = 5 * time.Second
+
+// EtcdWatcher serves redirects from the keys under an etcd prefix
+// and keeps them up to date while running. It is an http.Handler.
+type EtcdWatcher struct {
+	Handler
+
+	kv     EtcdKV
+	prefix string
+	// entries are the keys as the watch last saw them, path -> url.
+	// Only the watching goroutine touches them after the first load.
+	entries map[string]string
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// EtcdHandler loads all keys under prefix from etcd and serves them
+// as redirects. The key without the prefix is the path (a leading
+// slash is added if missing), the value is the URL. If the path is
+// not found, the fallback http.Handler will be called instead.
+//
+// A background goroutine watches the prefix and applies every put
+// and delete to the mappings. If etcd compacted the revisions the
+// watch needs, the prefix is listed again instead of missing
+// changes. A value that isn't a valid URL keeps the last good
+// mappings in place until it is fixed. Call Close to stop the
+// watcher.
+func EtcdHandler(client EtcdKV, prefix string, fallback http.Handler, opts ...Option) (*EtcdWatcher, error) {
+	if client == nil {
+		return nil, errors.New("urlshort: etcd client is nil")
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ew := &EtcdWatcher{
+		Handler: Handler{fallback: fallback, cfg: cfg, source: "etcd"},
+		kv:      client,
+		prefix:  prefix,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	// the first load has to work, there is nothing to serve otherwise
+	rev, err := ew.list(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go ew.watch(ctx, rev)
+	return ew, nil
+}
+
+// Close stops the watcher and waits for it to finish. The handler
+// keeps serving the last mappings afterwards.
+func (ew *EtcdWatcher) Close() error {
+	ew.closeOnce.Do(func() {
+		ew.cancel()
+		<-ew.done
+	})
+	return nil
+}
+
+// list loads every key under the prefix and swaps in the mappings.
+// It returns the revision they are at.
+func (ew *EtcdWatcher) list(ctx context.Context) (int64, error) {
+	resp, err := ew.kv.Get(ctx, ew.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+
+	ew.entries = make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		if path, ok := keyPath(string(kv.Key), ew.prefix); ok && len(kv.Value) > 0 {
+			ew.entries[path] = string(kv.Value)
+		}
+	}
+	if err := ew.apply(); err != nil {
+		return 0, err
+	}
+	return resp.Header.Revision, nil
+}
+
+// watch follows the changes after rev until the context is done
+func (ew *EtcdWatcher) watch(ctx context.Context, rev int64) {
+	defer close(ew.done)
+
+	for ctx.Err() == nil {
+		rev = ew.follow(ctx, rev)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// the watch broke off, changes may have been missed
+		next, err := ew.list(ctx)
+		for err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(etcdRetry):
+			}
+			next, err = ew.list(ctx)
+		}
+		rev = next
+	}
+}
+
+// follow applies the events of a watch from after rev until it ends,
+// and returns the last revision it saw
+func (ew *EtcdWatcher) follow(ctx context.Context, rev int64) int64 {
+	// canceling its own context releases the watch when it is left
+	// before etcd closes it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wch := ew.kv.Watch(ctx, ew.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for {
+		var resp clientv3.WatchResponse
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return rev
+		case resp, ok = <-wch:
+		}
+		if !ok || resp.CompactRevision != 0 || resp.Err() != nil {
+			return rev
+		}
+
+		for _, ev := range resp.Events {
+			path, ok := keyPath(string(ev.Kv.Key), ew.prefix)
+			if !ok {
+				continue
+			}
+			if ev.Type == clientv3.EventTypePut && len(ev.Kv.Value) > 0 {
+				ew.entries[path] = string(ev.Kv.Value)
+			} else {
+				delete(ew.entries, path)
+			}
+		}
+		if len(resp.Events) > 0 {
+			// a bad value keeps the last good mappings, the entries
+			// catch up once it is fixed
+			ew.apply()
+		}
+		rev = resp.Header.Revision
+	}
+}
+
+// apply swaps in the mappings for the current entries
+func (ew *EtcdWatcher) apply() error {
+	pathUrls := make([]pathUrl, 0, len(ew.entries))
+	for path, dest := range ew.entries {
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+	}
+	return ew.Reload(pathUrls)
+}
+
+// keyPath turns a key under prefix into the path it maps, a leading
+// slash is added if missing. ok is false for the prefix itself.
+func keyPath(key, prefix string) (path string, ok bool) {
+	path = strings.TrimPrefix(key, prefix)
+	if path == "" {
+		return "", false
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path, true
+}
@@ -0,0 +1,281 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// fakeEtcd is an EtcdKV over a map. Every Watch is handed to the
+// test through watches, which sends the events itself.
+type fakeEtcd struct {
+	mu     sync.Mutex
+	kvs    map[string]string
+	rev    int64
+	getErr error
+	gets   int
+
+	watches chan *fakeWatch
+}
+
+// fakeWatch is one Watch call
+type fakeWatch struct {
+	ctx context.Context
+	// rev is the revision the watch starts at
+	rev int64
+	ch  chan clientv3.WatchResponse
+}
+
+func newFakeEtcd(kvs map[string]string) *fakeEtcd {
+	fe := &fakeEtcd{kvs: make(map[string]string), rev: 1, watches: make(chan *fakeWatch, 10)}
+	for k, v := range kvs {
+		fe.kvs[k] = v
+	}
+	return fe
+}
+
+func (fe *fakeEtcd) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.gets++
+	if fe.getErr != nil {
+		return nil, fe.getErr
+	}
+	resp := &clientv3.GetResponse{Header: &pb.ResponseHeader{Revision: fe.rev}}
+	for k, v := range fe.kvs {
+		if strings.HasPrefix(k, key) {
+			resp.Kvs = append(resp.Kvs, &mvccpb.KeyValue{Key: []byte(k), Value: []byte(v)})
+		}
+	}
+	return resp, nil
+}
+
+func (fe *fakeEtcd) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	fw := &fakeWatch{ctx: ctx, rev: clientv3.OpGet(key, opts...).Rev(), ch: make(chan clientv3.WatchResponse, 1)}
+	fe.watches <- fw
+	return fw.ch
+}
+
+// nextWatch returns the next Watch call of the watcher
+func (fe *fakeEtcd) nextWatch(t *testing.T) *fakeWatch {
+	t.Helper()
+	select {
+	case fw := <-fe.watches:
+		return fw
+	case <-time.After(time.Second):
+		t.Fatal("the watcher didn't watch")
+		return nil
+	}
+}
+
+// change puts value at key, or deletes key if value is "", and sends
+// the event to fw
+func (fe *fakeEtcd) change(fw *fakeWatch, key, value string) {
+	fe.mu.Lock()
+	fe.rev++
+	ev := &clientv3.Event{Type: mvccpb.PUT, Kv: &mvccpb.KeyValue{Key: []byte(key), Value: []byte(value)}}
+	if value == "" {
+		delete(fe.kvs, key)
+		ev.Type = mvccpb.DELETE
+	} else {
+		fe.kvs[key] = value
+	}
+	resp := clientv3.WatchResponse{Header: &pb.ResponseHeader{Revision: fe.rev}, Events: []*clientv3.Event{ev}}
+	fe.mu.Unlock()
+	fw.ch <- resp
+}
+
+// set changes key without telling any watch, like a write the watch
+// missed
+func (fe *fakeEtcd) set(key, value string) {
+	fe.mu.Lock()
+	defer fe.mu.Unlock()
+	fe.rev++
+	fe.kvs[key] = value
+}
+
+func TestEtcdWatcher(t *testing.T) {
+	fe := newFakeEtcd(map[string]string{
+		"redirects/gh":      "https://github.com",
+		"redirects/docs/go": "https://go.dev/doc",
+		"redirects/":        "https://example.com/prefix",
+		"redirects/empty":   "",
+		"other/x":           "https://x.example.com",
+	})
+	ew, err := EtcdHandler(fe, "redirects/", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	defer ew.Close()
+	urlshorttest.TableTest(t, ew, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs/go", Location: "https://go.dev/doc", Code: http.StatusFound},
+		// the prefix itself and empty values aren't mappings
+		{Path: "/"},
+		{Path: "/empty"},
+		{Path: "/x"},
+	})
+
+	// the watch starts after the listed revision
+	fw := fe.nextWatch(t)
+	if fw.rev != 2 {
+		t.Errorf("the watch starts at revision %d, want 2", fw.rev)
+	}
+
+	fe.change(fw, "redirects/gl", "https://gitlab.com")
+	eventually(t, "the put", func() bool { return get(ew, "/gl").Code == http.StatusFound })
+	fe.change(fw, "redirects/gh", "")
+	eventually(t, "the delete", func() bool { return get(ew, "/gh").Code != http.StatusFound })
+	fe.change(fw, "other/y", "https://y.example.com")
+	urlshorttest.TableTest(t, ew, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/docs/go", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "/gh"},
+		{Path: "/y"},
+	})
+
+	// an invalid value keeps the last good mappings until it's fixed
+	fe.change(fw, "redirects/bad", "javascript:alert(1)")
+	fe.change(fw, "redirects/new", "https://new.example.com")
+	urlshorttest.TableTest(t, ew, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/bad"},
+		{Path: "/new"},
+	})
+	fe.change(fw, "redirects/bad", "")
+	eventually(t, "the fix", func() bool { return get(ew, "/new").Code == http.StatusFound })
+}
+
+func TestEtcdWatcherCompacted(t *testing.T) {
+	fe := newFakeEtcd(map[string]string{"redirects/gh": "https://github.com"})
+	ew, err := EtcdHandler(fe, "redirects/", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	defer ew.Close()
+	fw := fe.nextWatch(t)
+
+	// writes the watch never sees, then the revisions it needs are
+	// compacted away
+	fe.set("redirects/gl", "https://gitlab.com")
+	fe.set("redirects/gh", "https://github.com/nils")
+	fw.ch <- clientv3.WatchResponse{Header: &pb.ResponseHeader{Revision: 3}, CompactRevision: 3, Canceled: true}
+
+	// the prefix is listed again and watched from there
+	next := fe.nextWatch(t)
+	if next.rev != 4 {
+		t.Errorf("the new watch starts at revision %d, want 4", next.rev)
+	}
+	urlshorttest.TableTest(t, ew, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+	})
+
+	// a watch etcd closes is listed again too
+	fe.set("redirects/go", "https://go.dev")
+	close(next.ch)
+	fe.nextWatch(t)
+	urlshorttest.AssertRedirect(t, ew, "/go", "https://go.dev", http.StatusFound)
+}
+
+func TestEtcdWatcherClose(t *testing.T) {
+	fe := newFakeEtcd(map[string]string{"redirects/gh": "https://github.com"})
+	ew, err := EtcdHandler(fe, "redirects/", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	fw := fe.nextWatch(t)
+
+	closed := make(chan struct{})
+	go func() {
+		ew.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close didn't return")
+	}
+	// the watch is released, closing again is fine
+	if fw.ctx.Err() == nil {
+		t.Error("the watch context is still live")
+	}
+	ew.Close()
+	// the last mappings are still served
+	urlshorttest.AssertRedirect(t, ew, "/gh", "https://github.com", http.StatusFound)
+
+	// Close returns while the watcher waits to list again
+	fe = newFakeEtcd(map[string]string{"redirects/gh": "https://github.com"})
+	ew, err = EtcdHandler(fe, "redirects/", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	fw = fe.nextWatch(t)
+	fe.mu.Lock()
+	fe.getErr = errors.New("connection refused")
+	fe.mu.Unlock()
+	close(fw.ch)
+	eventually(t, "the failed list", func() bool {
+		fe.mu.Lock()
+		defer fe.mu.Unlock()
+		return fe.gets > 1
+	})
+	closed = make(chan struct{})
+	go func() {
+		ew.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close waits for the retry")
+	}
+}
+
+func TestEtcdHandlerErrors(t *testing.T) {
+	_, err := EtcdHandler(nil, "redirects/", urlshorttest.Fallback())
+	wantError(t, err, "etcd client is nil")
+
+	// the first load has to work
+	fe := newFakeEtcd(nil)
+	fe.getErr = errors.New("connection refused")
+	_, err = EtcdHandler(fe, "redirects/", urlshorttest.Fallback())
+	wantError(t, err, "connection refused")
+
+	_, err = EtcdHandler(newFakeEtcd(map[string]string{"redirects/bad": "ftp://example.com"}), "redirects/", urlshorttest.Fallback())
+	wantError(t, err, "/bad")
+}
+
+func TestEtcdHandlerFallback(t *testing.T) {
+	kvs := map[string]string{"redirects/gh": "https://github.com"}
+
+	// without a fallback misses get a plain 404
+	ew, err := EtcdHandler(newFakeEtcd(kvs), "redirects/", nil)
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	defer ew.Close()
+	if code := get(ew, "/missing").Code; code != http.StatusNotFound {
+		t.Errorf("GET /missing: got %d, want 404", code)
+	}
+
+	ew, err = EtcdHandler(newFakeEtcd(kvs), "redirects/", nil, WithDefaultDestination("https://example.com/home"))
+	if err != nil {
+		t.Fatalf("EtcdHandler: %v", err)
+	}
+	defer ew.Close()
+	urlshorttest.AssertRedirect(t, ew, "/missing", "https://example.com/home", http.StatusFound)
+	urlshorttest.AssertRedirect(t, ew, "/gh", "https://github.com", http.StatusFound)
+
+	_, err = EtcdHandler(newFakeEtcd(kvs), "redirects/", urlshorttest.Fallback(), WithDefaultDestination("https://example.com/home"))
+	wantError(t, err, "WithDefaultDestination replaces the fallback")
+}
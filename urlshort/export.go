@@ -0,0 +1,47 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Export formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Export returns all mappings in the store as YAML or JSON, in the
+// format YAMLHandler and JSONHandler read. Entries are sorted by
+// path, so exports of the same mappings are identical and diff
+// well. Paths start with the WithPathPrefix of the handler the
+// store is served with, Backup has them without it.
+func (s *MutableStore) Export(format string) ([]byte, error) {
+	return exportEntries(prefixPaths(s.entryList(), s.pathPrefix()), format)
+}
+
+// exportEntries encodes pathUrls in format
+func exportEntries(pathUrls []pathUrl, format string) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		return yaml.Marshal(pathUrls)
+	case FormatJSON:
+		data, err := json.MarshalIndent(pathUrls, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("urlshort: unknown export format %q (use yaml or json)", format)
+	}
+}
+
+// exportContentType is the Content-Type of an export in format
+func exportContentType(format string) string {
+	if format == FormatJSON {
+		return "application/json"
+	}
+	return "application/yaml"
+}
@@ -0,0 +1,92 @@
+package urlshort
+
+import (
+	"bytes"
+	"maps"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// exportStore returns a store with a few mappings added out of order
+func exportStore(t *testing.T) *MutableStore {
+	t.Helper()
+	store := NewMutableStore()
+	for _, path := range []string{"/zeta", "/alpha", "/mid/deep", "/b"} {
+		if err := store.Add(path, "https://example.com"+path+"?q=1&x=y"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	store := exportStore(t)
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			data, err := store.Export(format)
+			if err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			source := FromYAML(data)
+			if format == FormatJSON {
+				source = FromJSON(data)
+			}
+			h, err := New(source, urlshorttest.Fallback())
+			if err != nil {
+				t.Fatalf("loading the export: %v\n%s", err, data)
+			}
+			got := make(map[string]string)
+			for _, e := range h.Snapshot() {
+				got[e.Path] = e.URL
+			}
+			if want := store.Routes(); !maps.Equal(got, want) {
+				t.Errorf("round trip got %v, want %v", got, want)
+			}
+
+			// sorted by path, so the same mappings export the same way
+			last := -1
+			for _, path := range []string{"/alpha", "/b", "/mid/deep", "/zeta"} {
+				i := bytes.Index(data, []byte(`"`+path+`"`))
+				if i < 0 {
+					i = bytes.Index(data, []byte(" "+path+"\n"))
+				}
+				if i <= last {
+					t.Errorf("%s is not after the paths before it:\n%s", path, data)
+				}
+				last = i
+			}
+			again, _ := exportStore(t).Export(format)
+			if !bytes.Equal(data, again) {
+				t.Errorf("two exports of the same mappings differ:\n%s\n%s", data, again)
+			}
+		})
+	}
+	_, err := store.Export("xml")
+	wantError(t, err, `unknown export format "xml"`)
+}
+
+func TestAdminExport(t *testing.T) {
+	admin := AdminHandler(exportStore(t))
+	tests := []struct {
+		target      string
+		code        int
+		contentType string
+	}{
+		{"/api/export", http.StatusOK, "application/yaml"},
+		{"/api/export?format=yaml", http.StatusOK, "application/yaml"},
+		{"/api/export?format=json", http.StatusOK, "application/json"},
+		{"/api/export?format=xml", http.StatusBadRequest, "application/json"},
+	}
+	for _, tt := range tests {
+		res := call(admin, http.MethodGet, tt.target, "")
+		if res.Code != tt.code || res.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.target, res.Code, res.Header().Get("Content-Type"), tt.code, tt.contentType)
+		}
+		if tt.code == http.StatusOK && !strings.Contains(res.Body.String(), "/mid/deep") {
+			t.Errorf("GET %s: export is missing /mid/deep:\n%s", tt.target, res.Body)
+		}
+	}
+}
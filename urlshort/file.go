@@ -0,0 +1,179 @@
+package urlshort
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// FormatCSV is the format of path,url files, see CSVHandler
+const FormatCSV = "csv"
+
+// FileHandler serves redirects from a YAML, JSON or CSV file. Unlike
+// WatchedHandler it doesn't poll for changes, the file is read again
+// when Reload is called or, with ReloadOnSignal, on SIGHUP.
+type FileHandler struct {
+	Handler
+
+	path   string
+	format string
+
+	// signals is set while ReloadOnSignal is active
+	mu      sync.Mutex
+	signals chan os.Signal
+	done    chan struct{}
+}
+
+// NewFileHandler reads the file at path and serves it like the
+// handler for its format does. format is FormatYAML, FormatJSON,
+// FormatCSV or FormatHCL, the empty string picks it by the file
+// extension.
+func NewFileHandler(path, format string, fallback http.Handler, opts ...Option) (*FileHandler, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format = formatForPath(path)
+	}
+	switch format {
+	case FormatYAML, FormatJSON, FormatCSV, FormatHCL:
+	default:
+		return nil, fmt.Errorf("urlshort: %s: unknown format %q (use yaml, json, csv or hcl)", path, format)
+	}
+
+	fh := &FileHandler{
+		Handler: Handler{fallback: fallback, cfg: cfg, source: "file"},
+		path:    path,
+		format:  format,
+	}
+	pathUrls, err := fh.load()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := buildRoutes(pathUrls, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	fh.store(routes)
+	return fh, nil
+}
+
+// Path returns the file the handler serves
+func (fh *FileHandler) Path() string {
+	return fh.path
+}
+
+// Reload reads the file again and swaps in the new mappings. If the
+// file can't be read or parsed the old mappings stay in place.
+func (fh *FileHandler) Reload() error {
+	pathUrls, err := fh.load()
+	if err != nil {
+		return err
+	}
+	if err := fh.Handler.Reload(pathUrls); err != nil {
+		return fmt.Errorf("%s: %w", fh.path, err)
+	}
+	return nil
+}
+
+// ReloadOnSignal calls Reload whenever the process receives one of
+// sigs, SIGHUP if there are none, until Close is called. report, if
+// not nil, is called with the result of every reload so it can be
+// logged. Calling it again replaces the earlier signals and report.
+func (fh *FileHandler) ReloadOnSignal(report func(error), sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	fh.Close()
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, sigs...)
+
+	fh.mu.Lock()
+	fh.signals, fh.done = signals, done
+	fh.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		for range signals {
+			err := fh.Reload()
+			if report != nil {
+				report(err)
+			}
+		}
+	}()
+}
+
+// Close stops reloading on signals. The handler keeps serving the
+// last mappings afterwards.
+func (fh *FileHandler) Close() error {
+	fh.mu.Lock()
+	signals, done := fh.signals, fh.done
+	fh.signals, fh.done = nil, nil
+	fh.mu.Unlock()
+
+	if signals != nil {
+		signal.Stop(signals)
+		close(signals)
+		<-done
+	}
+	return nil
+}
+
+// load reads and parses the file
+func (fh *FileHandler) load() ([]pathUrl, error) {
+	return parseFile(fh.path, fh.format, fh.cfg)
+}
+
+// parseFile reads the file at path and parses it in format
+func parseFile(path, format string, cfg *config) ([]pathUrl, error) {
+	if format == FormatYAML {
+		// YAML files can include others, see include.go
+		return parseYAMLFile(path, cfg)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pathUrls []pathUrl
+	switch format {
+	case FormatJSON:
+		pathUrls, err = parseJSON(data)
+	case FormatCSV:
+		pathUrls, err = ParseCSV(bytes.NewReader(data))
+	case FormatHCL:
+		pathUrls, err = parseHCL(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pathUrls, nil
+}
+
+// formatForPath guesses the format of a file from its extension,
+// anything unknown is read as YAML
+func formatForPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON
+	case ".csv":
+		return FormatCSV
+	case ".hcl":
+		return FormatHCL
+	default:
+		return FormatYAML
+	}
+}
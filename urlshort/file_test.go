@@ -0,0 +1,156 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestFileHandlerFormats(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, file, format, data string
+	}{
+		{"yaml", "links.yaml", "", "- path: /gh\n  url: https://github.com\n"},
+		{"yml", "links.yml", "", "- path: /gh\n  url: https://github.com\n"},
+		{"json", "links.json", "", `[{"path": "/gh", "url": "https://github.com"}]`},
+		{"csv", "links.CSV", "", "/gh,https://github.com\n"},
+		{"unknown extension", "links.txt", "", "- path: /gh\n  url: https://github.com\n"},
+		{"format given", "links.txt", FormatJSON, `[{"path": "/gh", "url": "https://github.com"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, dir, tt.file, tt.data)
+			fh, err := NewFileHandler(path, tt.format, urlshorttest.Fallback())
+			if err != nil {
+				t.Fatalf("NewFileHandler: %v", err)
+			}
+			if fh.Path() != path {
+				t.Errorf("got Path %q, want %q", fh.Path(), path)
+			}
+			urlshorttest.AssertRedirect(t, fh, "/gh", "https://github.com", http.StatusFound)
+		})
+	}
+
+	_, err := NewFileHandler(writeFile(t, dir, "links.yaml", ""), "toml", nil)
+	wantError(t, err, `unknown format "toml"`)
+	_, err = NewFileHandler(writeFile(t, dir, "bad.json", `[{"path": "/gh", "url": "ftp://example.com"}]`), "", nil)
+	wantError(t, err, "bad.json: ")
+}
+
+func TestFileHandlerReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "links.yaml", "- path: /gh\n  url: https://github.com\n")
+	fh, err := NewFileHandler(path, "", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("NewFileHandler: %v", err)
+	}
+
+	writeFile(t, dir, "links.yaml", "- path: /gl\n  url: https://gitlab.com\n- path: /go\n  url: https://go.dev\n")
+	if err := fh.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if fh.Len() != 2 {
+		t.Errorf("got %d mappings, want 2", fh.Len())
+	}
+	urlshorttest.TableTest(t, fh, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/gh"},
+	})
+
+	// broken edits keep the old mappings
+	writeFile(t, dir, "links.yaml", "- path: /gh\n  url: [\n")
+	if err := fh.Reload(); err == nil {
+		t.Error("Reload of broken YAML: no error")
+	}
+	writeFile(t, dir, "links.yaml", "- path: /gh\n  url: ftp://example.com\n")
+	wantError(t, fh.Reload(), path+": ")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := fh.Reload(); err == nil {
+		t.Error("Reload of a missing file: no error")
+	}
+	urlshorttest.AssertRedirect(t, fh, "/gl", "https://gitlab.com", http.StatusFound)
+}
+
+// hup delivers a signal to the ReloadOnSignal goroutine of fh
+// without sending a real one
+func hup(fh *FileHandler) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	fh.signals <- syscall.SIGHUP
+}
+
+func TestFileHandlerReloadOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "links.yaml", "- path: /gh\n  url: https://github.com\n")
+	fh, err := NewFileHandler(path, "", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("NewFileHandler: %v", err)
+	}
+	reports := make(chan error, 1)
+	fh.ReloadOnSignal(func(err error) { reports <- err })
+	defer fh.Close()
+
+	writeFile(t, dir, "links.yaml", "- path: /gl\n  url: https://gitlab.com\n")
+	hup(fh)
+	if err := nextReport(t, reports); err != nil {
+		t.Errorf("the reload failed: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, fh, "/gl", "https://gitlab.com", http.StatusFound)
+
+	writeFile(t, dir, "links.yaml", "- path: /gl\n  url: [\n")
+	hup(fh)
+	if err := nextReport(t, reports); err == nil {
+		t.Error("the broken reload was reported without an error")
+	}
+	urlshorttest.AssertRedirect(t, fh, "/gl", "https://gitlab.com", http.StatusFound)
+
+	// calling it again replaces the report
+	again := make(chan error, 1)
+	fh.ReloadOnSignal(func(err error) { again <- err })
+	writeFile(t, dir, "links.yaml", "- path: /go\n  url: https://go.dev\n")
+	hup(fh)
+	if err := nextReport(t, again); err != nil {
+		t.Errorf("the reload failed: %v", err)
+	}
+	select {
+	case err := <-reports:
+		t.Errorf("the replaced report was called with %v", err)
+	default:
+	}
+
+	// Close stops the goroutine, closing twice is fine
+	fh.Close()
+	fh.Close()
+	if fh.signals != nil {
+		t.Error("Close left the signals behind")
+	}
+	urlshorttest.AssertRedirect(t, fh, "/go", "https://go.dev", http.StatusFound)
+
+	// Close without ReloadOnSignal is fine too
+	other, err := NewFileHandler(path, "", nil)
+	if err != nil {
+		t.Fatalf("NewFileHandler: %v", err)
+	}
+	if err := other.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
+
+// nextReport waits for the next reload report
+func nextReport(t *testing.T, reports chan error) error {
+	t.Helper()
+	select {
+	case err := <-reports:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("no reload was reported")
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package urlshort
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithChainFlattening makes entries whose destination is another
+// entry of the handler (on one of the WithSelfHosts hosts) redirect
+// straight to where that chain ends, saving clients the extra round
+// trips. Chains with more than maxDepth hops, or that loop, fail the
+// build. Flattened entries keep their configured url in Original,
+// which exports show. Entries that only redirect sometimes, like
+// those with a token, weights or an active window, end a chain.
+func WithChainFlattening(maxDepth int) Option {
+	return func(c *config) {
+		c.flattenDepth = maxDepth
+	}
+}
+
+// flattenable reports whether a chain may be followed through pu.
+// Anything that doesn't redirect the same way on every request
+// has to keep its own redirect.
+func flattenable(pu pathUrl) bool {
+	return redirects(pu) && !pu.Disabled && pu.Token == "" && len(pu.URLs) == 0 && len(pu.Geo) == 0 && len(pu.Lang) == 0 && len(pu.Device) == 0 &&
+		pu.expiresAt.IsZero() && pu.activeFrom.IsZero() && pu.activeUntil.IsZero()
+}
+
+// flatten returns the entries of the table with every chain through
+// other entries replaced by its final destination
+func (rt *routeTable) flatten(cfg *config) ([]pathUrl, bool, error) {
+	flattened := make([]pathUrl, len(rt.entries))
+	changed := false
+	for i, pu := range rt.entries {
+		flattened[i] = pu
+		if !redirects(pu) {
+			continue
+		}
+		dest, hops, err := rt.chainEnd(pu, cfg)
+		if err != nil {
+			return nil, false, err
+		}
+		if hops > 0 {
+			flattened[i].Original, flattened[i].URL = pu.URL, dest
+			changed = true
+		}
+	}
+	return flattened, changed, nil
+}
+
+// chainEnd follows the redirect of pu through the entries of the
+// table and returns the final destination and the number of hops
+func (rt *routeTable) chainEnd(pu pathUrl, cfg *config) (string, int, error) {
+	chain := []string{pu.Path}
+	seen := map[string]bool{pu.Host + " " + pu.Path: true}
+	dest := pu.URL
+	for hops := 0; ; hops++ {
+		m, ok := rt.follow(pathUrl{Host: pu.Host, URL: dest}, cfg)
+		if !ok || !flattenable(m.entry) {
+			return dest, hops, nil
+		}
+		chain = append(chain, m.entry.Path)
+		key := m.entry.Host + " " + m.entry.Path
+		if seen[key] {
+			return "", 0, fmt.Errorf("urlshort: redirect loop: %s", strings.Join(chain, " -> "))
+		}
+		if hops+1 > cfg.flattenDepth {
+			return "", 0, fmt.Errorf("urlshort: redirect chain %s exceeds the maximum depth of %d", strings.Join(chain, " -> "), cfg.flattenDepth)
+		}
+		seen[key] = true
+		pu, dest = m.entry, m.dest
+	}
+}
+
+// Export returns the mappings being served as YAML or JSON, like
+// MutableStore.Export, sorted by path. Aliases are listed with their
+// entry, like they were configured. With WithPathPrefix, paths
+// start with the prefix.
+func (h *Handler) Export(format string) ([]byte, error) {
+	pathUrls := collapseAliases(append([]pathUrl(nil), h.routes.Load().entries...))
+	pathUrls = prefixPaths(pathUrls, h.cfg.pathPrefix)
+	sort.SliceStable(pathUrls, func(i, j int) bool {
+		return pathUrls[i].Path < pathUrls[j].Path
+	})
+	return exportEntries(pathUrls, format)
+}
@@ -0,0 +1,88 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const flattenEntries = `
+- path: /a
+  url: https://go.example.com/b
+- path: /b
+  url: https://go.example.com:8080/c
+- path: /c
+  url: https://final.example.com
+- path: /to-token
+  url: https://go.example.com/token
+- path: /token
+  url: https://secret.example.com
+  token: hunter2
+- path: /to-ab
+  url: https://go.example.com/ab
+- path: /ab
+  urls:
+    - url: https://a.example.com
+      weight: 1
+    - url: https://b.example.com
+      weight: 1
+- path: /elsewhere
+  url: https://other.example.com/c
+`
+
+func TestChainFlattening(t *testing.T) {
+	opts := []Option{WithSelfHosts("go.example.com"), WithChainFlattening(3)}
+	h, err := New(FromYAML([]byte(flattenEntries)), urlshorttest.Fallback(), opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/a", Location: "https://final.example.com", Code: http.StatusFound},
+		{Path: "/b", Location: "https://final.example.com", Code: http.StatusFound},
+		{Path: "/c", Location: "https://final.example.com", Code: http.StatusFound},
+		// entries that don't always redirect end a chain
+		{Path: "/to-token", Location: "https://go.example.com/token", Code: http.StatusFound},
+		{Path: "/to-ab", Location: "https://go.example.com/ab", Code: http.StatusFound},
+		{Path: "/elsewhere", Location: "https://other.example.com/c", Code: http.StatusFound},
+	})
+
+	// exports keep the configured url
+	out, err := h.Export("yaml")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	for _, want := range []string{
+		"- path: /a\n  url: https://final.example.com\n  original: https://go.example.com/b\n",
+		"- path: /c\n  url: https://final.example.com\n-",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("the export has no %q:\n%s", want, out)
+		}
+	}
+
+	// without flattening every hop is a redirect
+	h, err = New(FromYAML([]byte(flattenEntries)), urlshorttest.Fallback(), WithSelfHosts("go.example.com"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/a", "https://go.example.com/b", http.StatusFound)
+}
+
+func TestChainFlatteningErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml string
+		depth      int
+		want       string
+	}{
+		{"too deep", flattenEntries, 1, "urlshort: redirect chain /a -> /b -> /c exceeds the maximum depth of 1"},
+		{"loop", "- path: /x\n  url: https://go.example.com/y\n- path: /y\n  url: https://go.example.com/x\n", 5, "redirect loop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(FromYAML([]byte(tt.yaml)), nil, WithSelfHosts("go.example.com"), WithChainFlattening(tt.depth))
+			wantError(t, err, tt.want)
+		})
+	}
+}
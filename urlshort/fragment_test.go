@@ -0,0 +1,48 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestFragments(t *testing.T) {
+	data := `
+- path: /users/:id
+  url: https://app.example.com/#/users/:id
+- path: /item/:id
+  url: https://app.example.com/view?x=1#/item/:id?tab=info&ref=:id
+- path: /docs
+  url: https://docs.example.com/guide#install
+- path: /spaced
+  url: https://docs.example.com/guide#first%20steps
+- path: /p/:id
+  url: https://app.example.com/p/:id?a=1#/p/:id
+- path: /s/:v
+  url: https://app.example.com/#/s/:v
+`
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithQueryForwarding())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// parameters are filled in inside the fragment
+		{Path: "/users/42", Location: "https://app.example.com/#/users/42", Code: http.StatusFound},
+		{Path: "/item/7", Location: "https://app.example.com/view?x=1#/item/7?tab=info&ref=7", Code: http.StatusFound},
+		{Path: "/p/9", Location: "https://app.example.com/p/9?a=1#/p/9", Code: http.StatusFound},
+		// forwarded parameters go in front of the fragment
+		{Path: "/users/42?utm=x", Location: "https://app.example.com/?utm=x#/users/42", Code: http.StatusFound},
+		{Path: "/item/7?b=2", Location: "https://app.example.com/view?x=1&b=2#/item/7?tab=info&ref=7", Code: http.StatusFound},
+		{Path: "/docs?lang=de", Location: "https://docs.example.com/guide?lang=de#install", Code: http.StatusFound},
+		{Path: "/p/9?a=2&b=3", Location: "https://app.example.com/p/9?a=1&b=3#/p/9", Code: http.StatusFound},
+		// fragments are neither decoded nor encoded twice
+		{Path: "/docs", Location: "https://docs.example.com/guide#install", Code: http.StatusFound},
+		{Path: "/spaced", Location: "https://docs.example.com/guide#first%20steps", Code: http.StatusFound},
+		{Path: "/spaced?x=1", Location: "https://docs.example.com/guide?x=1#first%20steps", Code: http.StatusFound},
+		// values can't end the fragment or start its query
+		{Path: "/s/a%23b", Location: "https://app.example.com/#/s/a%23b", Code: http.StatusFound},
+		{Path: "/s/a%3Fb?z=1", Location: "https://app.example.com/?z=1#/s/a%3Fb", Code: http.StatusFound},
+		{Path: "/s/a%20b", Location: "https://app.example.com/#/s/a%20b", Code: http.StatusFound},
+	})
+}
@@ -0,0 +1,118 @@
+package urlshort
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// GeoLookup finds the ISO 3166-1 alpha-2 country of an address, e.g.
+// backed by a MaxMind database
+type GeoLookup interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GeoLookupFunc adapts a function to GeoLookup
+type GeoLookupFunc func(ip net.IP) (string, error)
+
+func (f GeoLookupFunc) Country(ip net.IP) (string, error) {
+	return f(ip)
+}
+
+// WithGeoHeader takes the country of geo entries from the request
+// header name, like CF-IPCountry or X-Geo-Country, set by a CDN or
+// proxy in front of the server. Clients can send the header
+// themselves, only use it when the proxy always overwrites it.
+func WithGeoHeader(name string) Option {
+	return func(c *config) {
+		c.geoHeader = http.CanonicalHeaderKey(name)
+	}
+}
+
+// WithGeoLookup looks up the country of geo entries by client
+// address, for requests that don't have the WithGeoHeader header.
+// The address is found like for allow_cidrs, see WithTrustedProxies.
+func WithGeoLookup(lookup GeoLookup) Option {
+	return func(c *config) {
+		c.geoLookup = lookup
+	}
+}
+
+// checkGeo validates the geo map of pu. Country codes are made upper
+// case, and the default destination becomes the plain url so
+// everything that doesn't know about countries sees that one.
+func checkGeo(pu *pathUrl) error {
+	if len(pu.Geo) == 0 {
+		return nil
+	}
+	// an entry that was prepared before has the default as its url
+	if pu.URL != "" && pu.URL != pu.Geo[mapDefault] || len(pu.URLs) > 0 {
+		return fmt.Errorf("urlshort: %s: use either geo or url and urls, not both", pu.Path)
+	}
+	geo := make(map[string]string, len(pu.Geo))
+	for country, dest := range pu.Geo {
+		if dest == "" {
+			return fmt.Errorf("urlshort: %s: geo destination for %s is missing a url", pu.Path, country)
+		}
+		if country == mapDefault {
+			geo[country] = dest
+			continue
+		}
+		if !isCountryCode(country) {
+			return fmt.Errorf("urlshort: %s: %q is not a two letter country code", pu.Path, country)
+		}
+		geo[strings.ToUpper(country)] = dest
+	}
+	if geo[mapDefault] == "" {
+		return fmt.Errorf("urlshort: %s: geo needs a default destination", pu.Path)
+	}
+	pu.Geo = geo
+	pu.URL = geo[mapDefault]
+	return nil
+}
+
+// isCountryCode reports whether s looks like an ISO 3166-1 alpha-2
+// code
+func isCountryCode(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	for _, c := range []byte(strings.ToUpper(s)) {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// geoDestination picks the destination of a geo entry for the
+// country of r, the default if the country is unknown or has none
+func geoDestination(r *http.Request, pu pathUrl, cfg *config) string {
+	if dest, ok := pu.Geo[requestCountry(r, cfg)]; ok {
+		return dest
+	}
+	return pu.Geo[mapDefault]
+}
+
+// requestCountry returns the upper case country of r, "" if it
+// can't be told
+func requestCountry(r *http.Request, cfg *config) string {
+	if cfg.geoHeader != "" {
+		if country := strings.ToUpper(strings.TrimSpace(r.Header.Get(cfg.geoHeader))); isCountryCode(country) {
+			return country
+		}
+	}
+	if cfg.geoLookup == nil {
+		return ""
+	}
+	ip := net.ParseIP(clientAddr(r, cfg.trustedProxies))
+	if ip == nil {
+		return ""
+	}
+	country, err := cfg.geoLookup.Country(ip)
+	if err != nil {
+		return ""
+	}
+	return strings.ToUpper(country)
+}
@@ -0,0 +1,117 @@
+package urlshort
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const geoEntries = `
+- path: /shop
+  geo:
+    de: https://shop.example.de
+    AT: https://shop.example.de/at
+    default: https://shop.example.com
+- path: /gh
+  url: https://github.com
+`
+
+// fakeGeo is a GeoLookup of a fixed table, addresses not in it fail
+type fakeGeo map[string]string
+
+func (g fakeGeo) Country(ip net.IP) (string, error) {
+	country, ok := g[ip.String()]
+	if !ok {
+		return "", errors.New("address not in the database")
+	}
+	return country, nil
+}
+
+func TestGeoHeader(t *testing.T) {
+	h, err := YAMLHandler([]byte(geoEntries), urlshorttest.Fallback(), WithGeoHeader("cf-ipcountry"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"DE", "https://shop.example.de"},
+		{"de", "https://shop.example.de"},
+		{" AT ", "https://shop.example.de/at"},
+		{"US", "https://shop.example.com"},
+		{"", "https://shop.example.com"},
+		// Cloudflare's code for unknown countries
+		{"XX", "https://shop.example.com"},
+		{"germany", "https://shop.example.com"},
+	}
+	for _, tt := range tests {
+		res := requestFrom(h, "/shop", "198.51.100.1:5000", http.Header{"Cf-Ipcountry": {tt.country}})
+		if res.Code != http.StatusFound || res.Header().Get("Location") != tt.want {
+			t.Errorf("GET /shop from %q: got %d to %q, want 302 to %q", tt.country, res.Code, res.Header().Get("Location"), tt.want)
+		}
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+}
+
+func TestGeoLookup(t *testing.T) {
+	geo := fakeGeo{"198.51.100.1": "DE", "198.51.100.2": "us", "2001:db8::1": "at", "10.1.2.3": "DE"}
+	h, err := YAMLHandler([]byte(geoEntries), urlshorttest.Fallback(),
+		WithGeoHeader("X-Geo-Country"), WithGeoLookup(geo), WithTrustedProxies("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		name   string
+		addr   string
+		header http.Header
+		want   string
+	}{
+		{"v4", "198.51.100.1:5000", nil, "https://shop.example.de"},
+		{"lower case", "198.51.100.2:5000", nil, "https://shop.example.com"},
+		{"v6", "[2001:db8::1]:5000", nil, "https://shop.example.de/at"},
+		{"lookup fails", "203.0.113.1:5000", nil, "https://shop.example.com"},
+		{"header wins", "198.51.100.1:5000", http.Header{"X-Geo-Country": {"AT"}}, "https://shop.example.de/at"},
+		{"invalid header", "198.51.100.1:5000", http.Header{"X-Geo-Country": {"nowhere"}}, "https://shop.example.de"},
+		{"behind a proxy", "192.0.2.1:5000", http.Header{"X-Forwarded-For": {"10.1.2.3"}}, "https://shop.example.de"},
+		{"untrusted forwarded", "203.0.113.1:5000", http.Header{"X-Forwarded-For": {"10.1.2.3"}}, "https://shop.example.com"},
+	}
+	for _, tt := range tests {
+		res := requestFrom(h, "/shop", tt.addr, tt.header)
+		if res.Code != http.StatusFound || res.Header().Get("Location") != tt.want {
+			t.Errorf("%s: got %d to %q, want 302 to %q", tt.name, res.Code, res.Header().Get("Location"), tt.want)
+		}
+	}
+
+	// without a header or lookup everyone gets the default
+	plain, err := YAMLHandler([]byte(geoEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, plain, "/shop", "https://shop.example.com", http.StatusFound)
+}
+
+func TestGeoErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"no default", "- path: /shop\n  geo: {de: https://shop.example.de}\n", "/shop: geo needs a default destination"},
+		{"bad country", "- path: /shop\n  geo: {deu: https://shop.example.de, default: https://shop.example.com}\n", `"deu" is not a two letter country code`},
+		{"empty url", "- path: /shop\n  geo: {de: '', default: https://shop.example.com}\n", "geo destination for de is missing a url"},
+		{"url too", "- path: /shop\n  url: https://shop.example.com\n  geo: {de: https://shop.example.de, default: https://shop.example.net}\n", "use either geo or url and urls, not both"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
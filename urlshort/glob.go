@@ -0,0 +1,129 @@
+package urlshort
+
+import (
+	"sort"
+	"strings"
+)
+
+// globRoute is an entry whose path has glob segments: * matches
+// exactly one segment and ** any number of them, none included,
+// so /blog/**/draft matches /blog/draft and /blog/2024/05/draft.
+// A * at the very end is the older prefix wildcard instead, see
+// isWildcard.
+type globRoute struct {
+	segments []string
+	entry    pathUrl
+	// double is set if the path has a **, prefix is the number of
+	// literal segments before the first glob segment and literals
+	// the number of all of them
+	double   bool
+	prefix   int
+	literals int
+}
+
+// isGlobPath reports whether path has a ** segment, or a * segment
+// that isn't the last one
+func isGlobPath(path string) bool {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if seg == "**" || seg == "*" && i < len(segments)-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// newGlobRoute splits the entry path into its segments
+func newGlobRoute(pu pathUrl) globRoute {
+	gr := globRoute{segments: strings.Split(configPath(pu), "/"), entry: pu, prefix: -1}
+	for i, seg := range gr.segments {
+		if seg != "*" && seg != "**" {
+			gr.literals++
+			continue
+		}
+		if gr.prefix == -1 {
+			gr.prefix = i
+		}
+		if seg == "**" {
+			gr.double = true
+		}
+	}
+	return gr
+}
+
+// match compares the request path segment by segment. A * never
+// matches an empty segment. With foldCase the literal segments are
+// compared ignoring case.
+func (gr globRoute) match(segments []string, foldCase bool) bool {
+	return matchSegments(gr.segments, segments, foldCase)
+}
+
+// matchSegments matches the glob segments of pattern against path.
+// When a segment doesn't match, the last ** takes one more segment
+// and matching goes on after it, which is enough to find a match
+// if there is one.
+func matchSegments(pattern, path []string, foldCase bool) bool {
+	p, s := 0, 0
+	// double is the index of the last ** seen, resume the path index
+	// to go on from when backtracking to it
+	double, resume := -1, 0
+	for s < len(path) {
+		switch {
+		case p < len(pattern) && pattern[p] == "**":
+			double, resume = p, s
+			p++
+		case p < len(pattern) && segmentMatches(pattern[p], path[s], foldCase):
+			p++
+			s++
+		case double >= 0:
+			resume++
+			p, s = double+1, resume
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == "**" {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// segmentMatches reports whether one pattern segment matches seg
+func segmentMatches(pattern, seg string, foldCase bool) bool {
+	if pattern == "*" {
+		return seg != ""
+	}
+	return pattern == seg || foldCase && strings.EqualFold(pattern, seg)
+}
+
+// sortGlobRoutes puts paths with only * before those with **, then
+// longer literal prefixes first and then paths with more literal
+// segments, keeping the declaration order on ties
+func sortGlobRoutes(routes []globRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if a.double != b.double {
+			return !a.double
+		}
+		if a.prefix != b.prefix {
+			return a.prefix > b.prefix
+		}
+		return a.literals > b.literals
+	})
+}
+
+// MatchGlob reports whether an entry with path pattern matches the
+// request path, the way a handler without options sees it: a plain
+// path only matches itself, one ending in /* everything below it,
+// and globs segment by segment, see YAMLHandler. It is meant for
+// testing patterns before they are deployed.
+func MatchGlob(pattern, path string) bool {
+	switch {
+	case isGlobPath(pattern):
+		return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"), false)
+	case isWildcard(pattern):
+		return strings.HasPrefix(path, strings.TrimSuffix(pattern, "*"))
+	default:
+		return pattern == path
+	}
+}
@@ -0,0 +1,127 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		// ** matches any number of segments, none included
+		{"/blog/**/draft", "/blog/draft", true},
+		{"/blog/**/draft", "/blog/2024/draft", true},
+		{"/blog/**/draft", "/blog/2024/05/draft", true},
+		{"/blog/**/draft", "/blog/draft/draft", true},
+		{"/blog/**/draft", "/blog/draft/x", false},
+		{"/blog/**/draft", "/blogs/draft", false},
+		{"/blog/**/draft", "/Blog/draft", false},
+		{"/docs/**", "/docs", true},
+		{"/docs/**", "/docs/a/b/c", true},
+		{"/docs/**", "/doc", false},
+		{"/**", "/", true},
+		{"/**", "/anything/at/all", true},
+		{"/**/edit", "/edit", true},
+		{"/**/edit", "/wiki/page/edit", true},
+		{"/**/edit", "/wiki/edit/page", false},
+		// the last ** backtracks when a later segment doesn't match
+		{"/a/**/b/**/c", "/a/b/c", true},
+		{"/a/**/b/**/c", "/a/x/b/y/z/c", true},
+		{"/a/**/b/**/c", "/a/b/b/c/c", true},
+		{"/a/**/b/**/c", "/a/x/c", false},
+		// * matches exactly one segment, never an empty one
+		{"/a/*/c", "/a/b/c", true},
+		{"/a/*/c", "/a/c", false},
+		{"/a/*/c", "/a//c", false},
+		{"/a/*/c", "/a/b/x/c", false},
+		{"/x/*/**", "/x", false},
+		{"/x/*/**", "/x/y", true},
+		{"/x/*/**", "/x/y/z", true},
+		// a * at the end is the prefix wildcard
+		{"/docs/*", "/docs/a/b", true},
+		{"/docs/*", "/docs/", true},
+		{"/docs/*", "/docs", false},
+		{"/docs/*", "/docsx", false},
+		// plain paths only match themselves
+		{"/gh", "/gh", true},
+		{"/gh", "/gh/", false},
+		{"/gh", "/GH", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGlobPrecedence(t *testing.T) {
+	// declared least specific first, so the order can't be what
+	// decides
+	data := `
+- path: /docs/**
+  url: https://docs.example.com/double
+- path: /docs/**/intro
+  url: https://docs.example.com/double-intro
+- path: /docs/api/**
+  url: https://docs.example.com/double-api
+- path: /*/api/x
+  url: https://docs.example.com/single-api-x
+- path: /docs/*/intro
+  url: https://docs.example.com/single-intro
+- path: /docs/api/intro
+  url: https://docs.example.com/exact
+- path: /docs/*
+  url: https://docs.example.com/wildcard
+- path: /t/**/x
+  url: https://t.example.com/first
+- path: /t/**/x/**
+  url: https://t.example.com/second
+`
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// exact over single-star over double-star
+		{Path: "/docs/api/intro", Location: "https://docs.example.com/exact", Code: http.StatusFound},
+		{Path: "/docs/guide/intro", Location: "https://docs.example.com/single-intro", Code: http.StatusFound},
+		{Path: "/docs/a/b/intro", Location: "https://docs.example.com/double-intro", Code: http.StatusFound},
+		// a single-star wins even with a shorter literal prefix
+		{Path: "/docs/api/x", Location: "https://docs.example.com/single-api-x", Code: http.StatusFound},
+		// among double-stars the longer literal prefix wins, then the
+		// one with more literal segments
+		{Path: "/docs/api/y/intro", Location: "https://docs.example.com/double-api", Code: http.StatusFound},
+		{Path: "/docs/api/y", Location: "https://docs.example.com/double-api", Code: http.StatusFound},
+		{Path: "/docs/other/page", Location: "https://docs.example.com/double", Code: http.StatusFound},
+		// globs win over the prefix wildcard
+		{Path: "/docs/page", Location: "https://docs.example.com/double", Code: http.StatusFound},
+		// on a full tie the declaration order decides
+		{Path: "/t/a/x", Location: "https://t.example.com/first", Code: http.StatusFound},
+		{Path: "/t/a/x/b", Location: "https://t.example.com/second", Code: http.StatusFound},
+		{Path: "/t/a"},
+		{Path: "/other"},
+	})
+}
+
+func TestGlobOptions(t *testing.T) {
+	data := "- path: /blog/**/Draft\n  url: https://blog.example.com/drafts\n- path: /u/*/posts\n  url: https://blog.example.com/posts\n"
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithCaseInsensitivePaths(), WithSlashNormalization())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/BLOG/2024/draft", Location: "https://blog.example.com/drafts", Code: http.StatusFound},
+		{Path: "/blog/draft/", Location: "https://blog.example.com/drafts", Code: http.StatusFound},
+		{Path: "/U/nils/Posts", Location: "https://blog.example.com/posts", Code: http.StatusFound},
+		{Path: "/u/nils/posts/", Location: "https://blog.example.com/posts", Code: http.StatusFound},
+		{Path: "/u/posts"},
+	})
+
+	// the same glob twice is a duplicate like any other path
+	_, err = YAMLHandler([]byte("- path: /blog/**/draft\n  url: https://a.example.com\n- path: /blog/**/draft\n  url: https://b.example.com\n"), nil)
+	wantError(t, err, "duplicate path: /blog/**/draft")
+}
@@ -1,7 +1,17 @@
 package urlshort
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
 	yaml "gopkg.in/yaml.v2"
 )
 
@@ -11,22 +21,200 @@ import (
 // that each key in the map points to, in string format).
 // If the path is not provided in the map, then the fallback
 // http.Handler will be called instead.
-func MapHandler(pathsToUrls map[string]string, fallback http.Handler) http.HandlerFunc {
-	// define the returned handler func
-	return func(w http.ResponseWriter, r *http.Request) {
-		// get path from Request
-		path := r.URL.Path
-
-		// check if key (path from request) exits in map
-		if dest, ok := pathsToUrls[path]; ok {
-			// if so, create a redirect to the value of the map (dest)
-			http.Redirect(w, r, dest, http.StatusFound)
+//
+// A path segment like ":name" matches any single segment and
+// its value replaces ":name" in the URL, so "/u/:user" mapped to
+// "https://github.com/:user" redirects "/u/nils" to
+// "https://github.com/nils".
+//
+// A path ending in "/*" matches every path below it, so
+// "/docs/*" redirects "/docs/getting-started" as well. Exact
+// paths win over wildcards, and the longest wildcard wins
+// when they are nested.
+//
+// Request and configured paths are compared after decoding, with
+// duplicate slashes collapsed and . and .. segments resolved, so
+// /%C3%BCber finds /über and //docs/./api finds /docs/api.
+//
+// MapHandler panics if the options are invalid, e.g. a
+// WithStatus code outside of the 3xx range.
+func MapHandler(pathsToUrls map[string]string, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	// plain maps have no per-entry settings, so every entry uses the default code
+	h, err := New(FromMap(pathsToUrls), fallback, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return h.ServeHTTP
+}
+
+// routeHandler does the actual work for MapHandler and the
+// parsing handlers, which keep the per-entry settings around.
+func routeHandler(routes *routeTable, fallback http.Handler, cfg *config) http.HandlerFunc {
+	h := &Handler{fallback: fallback, cfg: cfg, source: "static"}
+	h.store(routes)
+	return h.ServeHTTP
+}
+
+// serveMatch writes the redirect for a matched entry. Every
+// handler goes through it, whatever the entries are stored in.
+// Rewrite entries are passed to the fallback and proxy entries to
+// their upstream instead, interstitial entries and redirects that
+// need confirming get a page.
+func serveMatch(w http.ResponseWriter, r *http.Request, m match, fallback http.Handler, cfg *config) {
+	if cfg.accessLog != nil {
+		lw := cfg.accessLog.wrap(w)
+		start := cfg.now()
+		// r is replaced by the request without the token below, that
+		// is the one to log
+		defer func() { cfg.accessLog.log(r, lw, start) }()
+		w = lw.writer()
+	}
+	defer cfg.recoverPanic(w)
+	if m.entry.Disabled {
+		if cfg.stats != nil {
+			cfg.stats.disabledHit(m.entry.Path, cfg.now())
+		}
+		passToFallback(w, r, fallback, cfg)
+		return
+	}
+	r, ok := checkToken(r, m.entry, cfg)
+	if !ok || !clientAllowed(r, m.entry, cfg) {
+		// don't give away that the path exists
+		callFallback(w, r, fallback, cfg)
+		return
+	}
+	redirecting := m.entry.Mode != modeRewrite && m.entry.Mode != modeProxy
+	if r.Method == http.MethodOptions && redirecting {
+		serveOptions(w, r, fallback, cfg)
+		return
+	}
+	if r.Method == http.MethodHead && redirecting {
+		w = headWriter{w}
+	}
+	if m.entry.Mode == modePixel {
+		servePixelEntry(w, r, m, cfg)
+		return
+	}
+	r, confirmed := takeConfirmation(r, cfg)
+
+	dest := m.dest
+	if m.entry.tmpl != nil {
+		var err error
+		if dest, err = templateDestination(r, m.entry); err != nil {
+			callFallback(w, r, fallback, cfg)
 			return
 		}
+	}
+	if len(m.entry.URLs) > 0 {
+		dest = chooseDestination(w, r, m.entry, cfg)
+	}
+	if len(m.entry.Geo) > 0 {
+		dest = geoDestination(r, m.entry, cfg)
+	}
+	if len(m.entry.Lang) > 0 {
+		var lang string
+		dest, lang = langDestination(r, m.entry)
+		r = withLanguage(r, lang)
+	}
+	if len(m.entry.Device) > 0 {
+		dest = deviceDestination(r, m.entry, cfg)
+	}
+	dest = addQuery(dest, r, m.entry, cfg)
+	switch m.entry.Mode {
+	case modeRewrite:
+		serveRewrite(w, r, m.entry.Path, dest, fallback, cfg)
+		return
+	case modeProxy:
+		serveProxy(w, r, m.entry, dest, cfg)
+		return
+	}
+
+	if needsConfirmation(dest, confirmed, cfg) {
+		serveConfirmation(w, r, m.entry, dest, cfg)
+		return
+	}
+
+	status := responseStatus(r, m.entry, cfg)
+	recordInfo(r, true, m.entry.Path, status)
+	// headers of the entry can still override the Cache-Control
+	writeCacheControl(w, m.entry, status, cfg)
+	writeHeaders(w, m.entry, cfg)
+	switch {
+	case m.entry.Mode == modeInterstitial:
+		serveInterstitial(w, m.entry, dest, cfg)
+	case m.entry.Redirect == redirectMeta:
+		serveMetaRefresh(w, dest)
+	default:
+		http.Redirect(w, r, dest, status)
+	}
+
+	if cfg.stats != nil {
+		cfg.stats.hit(m.entry.Path, cfg.now())
+	}
+	cfg.onRedirect(r, dest, status)
+}
+
+// addQuery adds the UTM parameters of the entry and, with
+// WithQueryForwarding, the query of r to dest, as far as the entry
+// lets it through. Configured parameters go first, so they win
+// over the request's.
+func addQuery(dest string, r *http.Request, pu pathUrl, cfg *config) string {
+	dest = addUTM(dest, r.URL.Path, pu, cfg)
+	if cfg.forwardQuery {
+		dest = forwardQuery(dest, filterQuery(r.URL.Query(), pu, cfg))
+	}
+	return dest
+}
+
+// responseStatus is the status of the response for a redirecting
+// entry, pages are served with 200
+func responseStatus(r *http.Request, pu pathUrl, cfg *config) int {
+	if pu.Mode == modeInterstitial || pu.Redirect == redirectMeta {
+		return http.StatusOK
+	}
+	return pu.status(r.Method, cfg.status)
+}
+
+// serveMiss calls the fallback for a request that didn't match
+func serveMiss(w http.ResponseWriter, r *http.Request, fallback http.Handler, cfg *config) {
+	if cfg.accessLog != nil {
+		lw := cfg.accessLog.wrap(w)
+		defer cfg.accessLog.log(r, lw, cfg.now())
+		w = lw.writer()
+	}
+	defer cfg.recoverPanic(w)
+	callFallback(w, r, fallback, cfg)
+}
+
+// callFallback counts the miss and passes r on to the fallback
+func callFallback(w http.ResponseWriter, r *http.Request, fallback http.Handler, cfg *config) {
+	if cfg.stats != nil {
+		cfg.stats.miss(r.URL.Path, cfg.now())
+	}
+	passToFallback(w, r, fallback, cfg)
+}
+
+// passToFallback treats r as a miss without counting it, for
+// requests that were counted elsewhere
+func passToFallback(w http.ResponseWriter, r *http.Request, fallback http.Handler, cfg *config) {
+	recordInfo(r, false, "", 0)
+	cfg.onMiss(r)
 
-		// serve fallback
-		fallback.ServeHTTP(w, r)
+	fallback.ServeHTTP(w, r)
+}
+
+// entriesHandler builds the route table for parsed entries and
+// wraps it in a handler.
+func entriesHandler(pathUrls []pathUrl, fallback http.Handler, cfg *config) (http.HandlerFunc, error) {
+	fallback, err := fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
 	}
+	routes, err := buildRoutes(pathUrls, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return routeHandler(routes, fallback, cfg), nil
 }
 
 // YAMLHandler will parse the provided YAML and then return
@@ -37,49 +225,506 @@ func MapHandler(pathsToUrls map[string]string, fallback http.Handler) http.Handl
 //
 // YAML is expected to be in the format:
 //
-//     - path: /some-path
-//       url: https://www.some-url.com/demo
+//   - path: /some-path
+//     url: https://www.some-url.com/demo
+//     code: 301
+//   - path: /docs/*
+//     url: https://docs.some-url.com
+//     expires: 2025-01-31T00:00:00Z
+//   - path: /u/:username
+//     url: https://github.com/:username
+//   - path: /blog/**/draft
+//     url: https://blog.some-url.com/drafts
+//   - path: /app/users/:id
+//     url: https://app.some-url.com/#/users/:id
+//   - path: ^/ticket-(\d+)$
+//     url: https://tracker.some-url.com/issues/$1
+//     regex: true
+//   - path: /launch
+//     url: https://www.some-url.com/campaign
+//     active_from: 2025-03-01T00:00:00Z
+//     active_until: 2025-04-01T00:00:00Z
+//     inactive_url: https://www.some-url.com/campaign-ended
+//   - path: /about
+//     url: /pages/about-us.html
+//     mode: rewrite
+//   - path: /docs
+//     url: https://docs.example.org
+//     host: links.example.org
+//   - path: /promo
+//     urls: [{url: "https://new.some-url.com", weight: 80}, {url: "https://old.some-url.com", weight: 20}]
+//     sticky: cookie
+//   - path: /partner
+//     url: https://partner.example.com
+//     headers: {Referrer-Policy: no-referrer}
+//   - path: /preview
+//     url: https://www.some-url.com/unreleased
+//     token: s3cret
+//   - path: /portal
+//     url: https://sso.some-url.com/portal
+//     redirect: meta
+//
+// To avoid repeating common parts of urls, the list can be put under
+// redirects next to a vars block, whose values replace ${name} in
+// the urls ($${name} stays a literal ${name}):
+//
+//	vars:
+//	  base: https://intranet.some-url.com
+//	redirects:
+//	  - path: /onboarding
+//	    url: ${base}/wiki/Onboarding
+//
+// Every url must be an absolute http or https URL, unless the
+// options allow otherwise. All invalid entries are reported
+// together in a *ValidationError.
+//
+// Entries with disabled true stay configured, exports list them,
+// but requests for them get the fallback as if they were missing.
+// WithStats counts those requests apart from hits and misses.
+//
+// The code is optional and defaults to 302 (or the WithStatus
+// option), and to 307 or 308 for requests that aren't GET or HEAD.
+// Only 301, 302, 307 and 308 are accepted, permanent ones can set
+// cache_max_age for WithRedirectCaching. Entries with
+// an expires timestamp act as missing once it has passed, entries
+// with active_from or active_until only work inside that window and
+// redirect to their inactive_url, if any, outside of it.
+//
+// Entries with mode rewrite don't redirect, the fallback serves
+// the request as if their url, which must be a path, had been
+// requested. The original path is passed in the X-Original-Path
+// header. Entries with mode proxy fetch their url for the client
+// through a reverse proxy, see WithProxyTimeout. Entries with mode
+// interstitial answer with a page that moves on to their url after
+// delay seconds, see WithInterstitialTemplate. Entries with mode
+// pixel answer with an uncacheable 1x1 transparent GIF for open
+// tracking, they are counted and reported to the redirect hook
+// with status 200 like a redirect, and their url is optional.
+// Redirect entries with redirect meta answer with a minimal page
+// that refreshes to their url right away instead of a redirect
+// status, for clients that drop the Location of cross-origin
+// redirects.
+//
+// In paths, a * segment matches exactly one segment and a **
+// segment any number of them, none included. A * at the end makes
+// the entry match every path below it instead. Exact paths win over
+// parameters, then paths with only * (the longest literal prefix
+// first), then paths with **, regexes and finally the longest
+// wildcard, see WithOrderedMatching and MatchGlob.
+//
+// The url of an entry with template true is a text/template,
+// executed for every request with a DestinationData. Everything
+// the actions write is escaped for the part of the url it ends up
+// in, and the url must start with a fixed scheme and host. When
+// executing it fails the request gets the fallback.
+//
+//   - path: /support
+//     url: https://support.some-url.com/new?subject={{.Header.Get "X-App"}}
+//     template: true
+//
+// Parameters are filled in everywhere in the url, the fragment
+// included, which is how single page apps with hash routing are
+// linked to. Forwarded and UTM query parameters always go in front
+// of the fragment.
+//
+// With WithQueryForwarding, an entry's query_allow lists the only
+// request parameters that are forwarded and query_strip the ones
+// that never are, added to WithQueryAllow and WithQueryStrip. Allow
+// lists win over strip lists.
+//
+// An entry with aliases is served under each of them too, as if it
+// was repeated with the alias as its path. An alias that is also
+// the path of another entry is a duplicate. Exports list the
+// aliases with their entry again, stats count them on their own,
+// see Stats.CanonicalHits to add them up.
+//
+//   - path: /docs
+//     url: https://docs.some-url.com
+//     aliases: [/documentation, /doku]
+//
+// Entries with a host only match requests for that host, and win
+// over entries without one. A host like *.go.example.com matches
+// every subdomain of go.example.com, docs.go.example.com and
+// a.docs.go.example.com alike, and fills in :subdomain in the url
+// with the labels in front (docs, a.docs). Entries for the exact
+// host win over wildcards, and the wildcard of the longest domain
+// wins over the others. See WithWildcardApex to match
+// go.example.com too. Entries with urls pick one of them per
+// request by weight, their urls are used as they are. Entries with
+// a token only work for requests that pass it as ?t=token, others
+// get the fallback.
 //
 // The only errors that can be returned all related to having
-// invalid YAML data.
+// invalid YAML data or invalid options.
 //
 // See MapHandler to create a similar http.HandlerFunc via
 // a mapping of paths to urls.
-func YAMLHandler(yamlBytes []byte, fallback http.Handler) (http.HandlerFunc, error) {
-	// fill variables with yaml data
-	parsedYaml, err := parseYAML(yamlBytes)
+func YAMLHandler(yamlBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	h, err := New(FromYAML(yamlBytes), fallback, opts...)
 	if err != nil {
 		return nil, err
 	}
-	pathMap := buildMap(parsedYaml)
-	return MapHandler(pathMap, fallback), nil
+	return h.ServeHTTP, nil
 }
 
 func parseYAML(data []byte) ([]pathUrl, error) {
-	var pathUrls []pathUrl
 	// unmarshal references the struct for mapping yaml to variables
-	err := yaml.Unmarshal(data, &pathUrls)
+	pathUrls, err := unmarshalYAML(data, yaml.Unmarshal)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := prepareEntries(pathUrls); err != nil {
+		return nil, err
+	}
+
+	return pathUrls, nil
+}
+
+// JSONHandler works like YAMLHandler, but parses the mappings
+// from JSON instead.
+//
+// JSON is expected to be in the format:
+//
+//	[{"path": "/some-path", "url": "https://www.some-url.com/demo"}]
+//
+// An error is returned for malformed JSON and for entries
+// missing either the path or the url.
+func JSONHandler(jsonBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	h, err := New(FromJSON(jsonBytes), fallback, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.ServeHTTP, nil
+}
+
+// JSONLinesHandler works like JSONHandler, but reads newline
+// delimited JSON from r, one entry object per line, like
+// ParseJSONLines does:
+//
+//	# docs team
+//	{"path": "/docs", "url": "https://docs.some-url.com"}
+//	{"path": "/gh", "url": "https://github.com", "code": 301}
+//
+// r is read as it is parsed, so large files never have to be in
+// memory as a whole. Errors name the line of a broken entry.
+func JSONLinesHandler(r io.Reader, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	h, err := New(&source{name: "jsonl", fn: func(cfg *config) ([]pathUrl, error) {
+		var pathUrls []pathUrl
+		err := ParseJSONLines(r, func(pu Entry) error {
+			pathUrls = append(pathUrls, pu)
+			return nil
+		})
+		return pathUrls, err
+	}}, fallback, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return h.ServeHTTP, nil
+}
+
+func parseJSON(data []byte) ([]pathUrl, error) {
+	var pathUrls []pathUrl
+	if err := json.Unmarshal(data, &pathUrls); err != nil {
+		return nil, fmt.Errorf("urlshort: invalid JSON: %v", err)
+	}
+
+	if err := checkEntries(pathUrls); err != nil {
+		return nil, err
+	}
 
 	return pathUrls, nil
 }
 
+// checkEntries makes sure every entry has both a path and a url.
+// Most decoders silently leave missing fields empty, which would
+// otherwise end up as a redirect to nowhere.
+func checkEntries(pathUrls []pathUrl) error {
+	for i, pu := range pathUrls {
+		if err := checkComplete(i, pu); err != nil {
+			return err
+		}
+	}
+	return prepareEntries(pathUrls)
+}
 
-func buildMap(pathUrls []pathUrl) map[string]string {
-	// make preallocates the space required for the map. Additionally, it supports maps with len != cap
-	pathsToUrls := make(map[string]string)
+// checkComplete is the check of checkEntries for entry i
+func checkComplete(i int, pu pathUrl) error {
+	if pu.Path == "" {
+		return fmt.Errorf("urlshort: entry %d is missing a path", i)
+	}
+	if !hasDestination(pu) {
+		return fmt.Errorf("urlshort: entry %d (%s) is missing a url", i, pu.Path)
+	}
+	return nil
+}
+
+// hasDestination reports whether pu has a url of some kind, pixel
+// entries don't need one
+func hasDestination(pu pathUrl) bool {
+	return pu.URL != "" || len(pu.URLs) > 0 || len(pu.Geo) > 0 || len(pu.Lang) > 0 || len(pu.Device) > 0 || pu.Mode == modePixel
+}
+
+func buildMap(pathUrls []pathUrl, cfg *config) (*routeTable, error) {
+	pathUrls = expandAliases(pathUrls)
+	routes := newRouteTable(len(pathUrls), cfg)
+
+	pathUrls, err := routes.dedupe(pathUrls, cfg.duplicates)
+	if err != nil {
+		return nil, err
+	}
+	routes.aliases = aliasTargets(pathUrls)
+	var transport *http.Transport
+	for i := range pathUrls {
+		if pathUrls[i].Mode != modeProxy {
+			continue
+		}
+		if transport == nil {
+			transport = newProxyTransport(cfg)
+		}
+		pathUrls[i].proxy = newProxy(transport)
+	}
+	routes.entries = pathUrls
+	routes.reverse = buildReverse(pathUrls)
+
+	// entries for a specific host get a table of their own, the
+	// generic entries stay in routes
+	var generic []pathUrl
+	byHost := make(map[string][]pathUrl)
 	for _, pu := range pathUrls {
-		pathsToUrls[pu.Path] = pu.URL
+		if pu.Host == "" {
+			generic = append(generic, pu)
+			continue
+		}
+		if err := checkHost(pu); err != nil {
+			return nil, err
+		}
+		host := normalizeHost(pu.Host)
+		byHost[host] = append(byHost[host], pu)
+	}
+	if err := routes.index(generic); err != nil {
+		return nil, err
+	}
+	for host, entries := range byHost {
+		hostRoutes := newRouteTable(len(entries), cfg)
+		if err := hostRoutes.index(entries); err != nil {
+			return nil, err
+		}
+		if cfg.bloomRate > 0 {
+			hostRoutes.buildBloom(cfg.bloomRate)
+		}
+		if cfg.suggest {
+			hostRoutes.byLength = buildLengthIndex(hostRoutes.exact)
+		}
+		if domain, ok := wildcardSuffix(host); ok {
+			if routes.wildcards == nil {
+				routes.wildcards = make(map[string]*routeTable)
+			}
+			routes.wildcards[domain] = hostRoutes
+			continue
+		}
+		if routes.hosts == nil {
+			routes.hosts = make(map[string]*routeTable)
+		}
+		routes.hosts[host] = hostRoutes
+	}
+	if cfg.bloomRate > 0 {
+		routes.buildBloom(cfg.bloomRate)
+	}
+	if cfg.suggest {
+		routes.byLength = buildLengthIndex(routes.exact)
+	}
+
+	return routes, nil
+}
+
+// newRouteTable returns an empty table for size entries
+func newRouteTable(size int, cfg *config) *routeTable {
+	// make preallocates the space required for the map. Additionally, it supports maps with len != cap
+	return &routeTable{
+		exact:        make(map[string]pathUrl, size),
+		foldCase:     cfg.foldCase,
+		slashes:      cfg.slashes,
+		wildcardApex: cfg.wildcardApex,
+
+		orderedRules: cfg.orderedMatching,
 	}
+}
+
+// index sorts pathUrls into the lookup structures of the table
+func (rt *routeTable) index(pathUrls []pathUrl) error {
+	for _, pu := range pathUrls {
+		if pu.Regex {
+			pattern := pu.Path
+			if rt.foldCase {
+				pattern = "(?i)" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("urlshort: %s: invalid regex: %v", pu.Path, err)
+			}
+			if rt.orderedRules {
+				rt.ordered = append(rt.ordered, orderedRoute{regex: &regexRoute{re: re, entry: pu}, entry: pu})
+				continue
+			}
+			rt.regex = append(rt.regex, regexRoute{re: re, entry: pu})
+			continue
+		}
+		if isGlobPath(pu.Path) {
+			if isParamPath(pu.Path) {
+				return fmt.Errorf("urlshort: %s: globs can't be combined with :name parameters", pu.Path)
+			}
+			gr := newGlobRoute(pu)
+			if rt.orderedRules {
+				rt.ordered = append(rt.ordered, orderedRoute{glob: &gr, entry: pu})
+				continue
+			}
+			rt.globs = append(rt.globs, gr)
+			continue
+		}
+		if isParamPath(pu.Path) {
+			pr := newParamRoute(pu)
+			if rt.orderedRules {
+				rt.ordered = append(rt.ordered, orderedRoute{param: &pr, entry: pu})
+				continue
+			}
+			rt.params = append(rt.params, pr)
+			continue
+		}
 
-	return pathsToUrls
+		key := rt.normalize(configPath(pu))
+		if isWildcard(pu.Path) {
+			// keep the slash so /docs/* doesn't match /docsearch
+			prefix := strings.TrimSuffix(key, "*")
+			if rt.orderedRules {
+				rt.ordered = append(rt.ordered, orderedRoute{prefix: prefix, entry: pu})
+				continue
+			}
+			rt.prefixes.insert(prefix, pu)
+			continue
+		}
+		rt.exact[key] = pu
+	}
+	sortParamRoutes(rt.params)
+	sortGlobRoutes(rt.globs)
+	return nil
 }
 
-// interface for mapping yaml data to variables 
+// Entry is a single mapping with its optional settings, as the
+// parsers and ParseYAMLStream return it
+type Entry = pathUrl
+
+// interface for mapping yaml (and json, toml, xml) data to variables
 type pathUrl struct {
-	Path string `yaml:"path"`
-	URL string `yaml:"url"`
+	Path string `yaml:"path" json:"path" toml:"path" xml:"path"`
+	URL  string `yaml:"url" json:"url" toml:"url" xml:"url"`
+	// Aliases are more paths the entry is served under, with the
+	// same settings
+	Aliases []string `yaml:"aliases,omitempty" json:"aliases,omitempty" toml:"aliases,omitempty" xml:"aliases>path,omitempty"`
+	// Code is the redirect status, zero means the handler default
+	Code int `yaml:"code,omitempty" json:"code,omitempty" toml:"code,omitempty" xml:"code,omitempty"`
+	// Regex treats Path as a regular expression, URL may then
+	// reference capture groups like $1
+	Regex bool `yaml:"regex,omitempty" json:"regex,omitempty" toml:"regex,omitempty" xml:"regex,omitempty"`
+	// Expires is an optional RFC 3339 timestamp after which
+	// the entry is treated as if it didn't exist
+	Expires string `yaml:"expires,omitempty" json:"expires,omitempty" toml:"expires,omitempty" xml:"expires,omitempty"`
+	// ActiveFrom and ActiveUntil are optional RFC 3339 timestamps
+	// limiting when the entry works, outside of that window it
+	// redirects to InactiveURL if set and is missing otherwise
+	ActiveFrom  string `yaml:"active_from,omitempty" json:"active_from,omitempty" toml:"active_from,omitempty" xml:"active_from,omitempty"`
+	ActiveUntil string `yaml:"active_until,omitempty" json:"active_until,omitempty" toml:"active_until,omitempty" xml:"active_until,omitempty"`
+	InactiveURL string `yaml:"inactive_url,omitempty" json:"inactive_url,omitempty" toml:"inactive_url,omitempty" xml:"inactive_url,omitempty"`
+	// URLs replaces URL with several destinations that are picked
+	// by weight, Sticky "cookie" keeps a visitor on the one they got
+	URLs   []weightedURL `yaml:"urls,omitempty" json:"urls,omitempty" toml:"urls,omitempty" xml:"urls>destination,omitempty"`
+	Sticky string        `yaml:"sticky,omitempty" json:"sticky,omitempty" toml:"sticky,omitempty" xml:"sticky,omitempty"`
+	// Original is the configured url of an entry whose chain of
+	// redirects was flattened, URL is then where the chain ends
+	Original string `yaml:"original,omitempty" json:"original,omitempty" toml:"original,omitempty" xml:"original,omitempty"`
+	// Token makes the entry only work for requests with ?t=Token,
+	// it is removed before the query is passed on
+	Token string `yaml:"token,omitempty" json:"token,omitempty" toml:"token,omitempty" xml:"token,omitempty"`
+	// UTM overrides the WithUTM parameters, keys are source,
+	// medium, campaign, term and content. An empty value leaves the
+	// parameter out.
+	UTM map[string]string `yaml:"utm,omitempty" json:"utm,omitempty" toml:"utm,omitempty" xml:"-"`
+	// Headers are added to the redirect response
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty" toml:"headers,omitempty" xml:"-"`
+	// Host limits the entry to requests for that host, entries
+	// without one match every host
+	Host string `yaml:"host,omitempty" json:"host,omitempty" toml:"host,omitempty" xml:"host,omitempty"`
+	// Mode is "redirect" (the default), "rewrite", which serves the
+	// destination path from the fallback without a redirect,
+	// "proxy", which serves the destination through a reverse proxy,
+	// "interstitial", which serves a page linking to it, or "pixel",
+	// which serves a transparent GIF and needs no url
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty" toml:"mode,omitempty" xml:"mode,omitempty"`
+	// Delay is how many seconds an interstitial page waits before
+	// going on to the destination
+	Delay int `yaml:"delay,omitempty" json:"delay,omitempty" toml:"delay,omitempty" xml:"delay,omitempty"`
+	// CacheMaxAge overrides the max-age of WithRedirectCaching in
+	// seconds, it only works for permanent redirects
+	CacheMaxAge int `yaml:"cache_max_age,omitempty" json:"cache_max_age,omitempty" toml:"cache_max_age,omitempty" xml:"cache_max_age,omitempty"`
+	// Redirect is "http" (the default) for a redirect status with a
+	// Location, or "meta" for a page with a meta refresh instead
+	Redirect string `yaml:"redirect,omitempty" json:"redirect,omitempty" toml:"redirect,omitempty" xml:"redirect,omitempty"`
+	// AllowCIDRs limits the entry to clients in these ranges,
+	// DenyCIDRs shuts clients in them out. For everyone else the
+	// entry is missing, see WithTrustedProxies.
+	AllowCIDRs []string `yaml:"allow_cidrs,omitempty" json:"allow_cidrs,omitempty" toml:"allow_cidrs,omitempty" xml:"allow_cidrs>cidr,omitempty"`
+	DenyCIDRs  []string `yaml:"deny_cidrs,omitempty" json:"deny_cidrs,omitempty" toml:"deny_cidrs,omitempty" xml:"deny_cidrs>cidr,omitempty"`
+	// Geo replaces URL with a destination per country code, the
+	// "default" key is used for every other country, see
+	// WithGeoHeader and WithGeoLookup
+	Geo map[string]string `yaml:"geo,omitempty" json:"geo,omitempty" toml:"geo,omitempty" xml:"-"`
+	// Lang replaces URL with a destination per language tag, picked
+	// by Accept-Language, the "default" key is used when none of the
+	// languages of the request has one
+	Lang map[string]string `yaml:"lang,omitempty" json:"lang,omitempty" toml:"lang,omitempty" xml:"-"`
+	// Device replaces URL with a destination per device class, ios,
+	// android or desktop, the "default" key is used for the rest,
+	// see WithDeviceClassifier
+	Device map[string]string `yaml:"device,omitempty" json:"device,omitempty" toml:"device,omitempty" xml:"-"`
+	// QueryAllow and QueryStrip choose which request parameters are
+	// forwarded, on top of WithQueryAllow and WithQueryStrip
+	QueryAllow []string `yaml:"query_allow,omitempty" json:"query_allow,omitempty" toml:"query_allow,omitempty" xml:"query_allow>param,omitempty"`
+	QueryStrip []string `yaml:"query_strip,omitempty" json:"query_strip,omitempty" toml:"query_strip,omitempty" xml:"query_strip>param,omitempty"`
+	// Template makes URL a text/template executed per request with
+	// a DestinationData, see YAMLHandler
+	Template bool `yaml:"template,omitempty" json:"template,omitempty" toml:"template,omitempty" xml:"template,omitempty"`
+	// Disabled keeps the entry around but makes it act as missing
+	Disabled bool `yaml:"disabled,omitempty" json:"disabled,omitempty" toml:"disabled,omitempty" xml:"disabled,omitempty"`
+
+	// filled in by prepareEntries
+	expiresAt   time.Time
+	activeFrom  time.Time
+	activeUntil time.Time
+	allowNets   []*net.IPNet
+	denyNets    []*net.IPNet
+	tmpl        *template.Template
+	// filled in by buildMap for proxy entries
+	proxy *httputil.ReverseProxy
+	// aliasOf is set by buildMap on the copies made for the aliases
+	// of an entry, to the path of the entry
+	aliasOf string
 }
 
+// status returns the redirect status code to use for the entry,
+// def is used when the entry doesn't have a code of its own. For
+// methods other than GET and HEAD the default becomes 307, or 308
+// if def is permanent, so clients keep the method and body instead
+// of turning e.g. a POST into a GET.
+func (pu pathUrl) status(method string, def int) int {
+	if pu.Code != 0 {
+		return pu.Code
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, "":
+		return def
+	}
+	if permanent(def) {
+		return http.StatusPermanentRedirect
+	}
+	return http.StatusTemporaryRedirect
+}
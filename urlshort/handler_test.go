@@ -0,0 +1,259 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// get records the response of h to a GET of target
+func get(h http.Handler, target string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+	return res
+}
+
+// wantError fails t unless err is set and mentions want
+func wantError(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("got no error, want one mentioning %q", want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("got error %q, want one mentioning %q", err, want)
+	}
+}
+
+func TestJSONHandlerMatchesYAMLHandler(t *testing.T) {
+	yamlData := `
+- path: /urlshort
+  url: https://github.com/gophercises/urlshort
+- path: /urlshort-final
+  url: https://github.com/gophercises/urlshort/tree/solution
+`
+	jsonData := `[
+  {"path": "/urlshort", "url": "https://github.com/gophercises/urlshort"},
+  {"path": "/urlshort-final", "url": "https://github.com/gophercises/urlshort/tree/solution"}
+]`
+	cases := []urlshorttest.Case{
+		{Path: "/urlshort", Location: "https://github.com/gophercises/urlshort", Code: http.StatusFound},
+		{Path: "/urlshort-final", Location: "https://github.com/gophercises/urlshort/tree/solution", Code: http.StatusFound},
+		{Path: "/missing"},
+		{Path: "/"},
+	}
+
+	yamlHandler, err := YAMLHandler([]byte(yamlData), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	jsonHandler, err := JSONHandler([]byte(jsonData), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("JSONHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, yamlHandler, cases)
+	urlshorttest.TableTest(t, jsonHandler, cases)
+}
+
+func TestJSONHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"malformed", `[{"path": "/a", "url": `, "invalid JSON"},
+		{"not a list", `{"path": "/a", "url": "https://a.example.com"}`, "invalid JSON"},
+		{"missing path", `[{"url": "https://a.example.com"}]`, "entry 0 is missing a path"},
+		{"missing url", `[{"path": "/a", "url": "https://a.example.com"}, {"path": "/b"}]`, "entry 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRedirectStatusByMethod(t *testing.T) {
+	data := []byte(`
+- path: /hook
+  url: https://hooks.example.com/in
+- path: /moved
+  url: https://new.example.com
+  code: 301
+- path: /temp
+  url: https://temp.example.com
+  code: 307
+`)
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		method string
+		want   int
+	}{
+		{"get", nil, "/hook", http.MethodGet, http.StatusFound},
+		{"head", nil, "/hook", http.MethodHead, http.StatusFound},
+		{"post", nil, "/hook", http.MethodPost, http.StatusTemporaryRedirect},
+		{"put", nil, "/hook", http.MethodPut, http.StatusTemporaryRedirect},
+		{"delete", nil, "/hook", http.MethodDelete, http.StatusTemporaryRedirect},
+		{"post permanent", []Option{WithStatus(http.StatusMovedPermanently)}, "/hook", http.MethodPost, http.StatusPermanentRedirect},
+		{"put permanent", []Option{WithStatus(http.StatusMovedPermanently)}, "/hook", http.MethodPut, http.StatusPermanentRedirect},
+		// an explicit code wins
+		{"post with code", nil, "/moved", http.MethodPost, http.StatusMovedPermanently},
+		{"delete with code", nil, "/temp", http.MethodDelete, http.StatusTemporaryRedirect},
+	}
+	locations := map[string]string{
+		"/hook":  "https://hooks.example.com/in",
+		"/moved": "https://new.example.com",
+		"/temp":  "https://temp.example.com",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler(data, urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, httptest.NewRequest(tt.method, tt.path, strings.NewReader("payload")))
+			got, want := res.Header().Get("Location"), locations[tt.path]
+			if res.Code != tt.want || got != want {
+				t.Errorf("%s %s: got %d to %q, want %d to %q", tt.method, tt.path, res.Code, got, tt.want, want)
+			}
+		})
+	}
+}
+
+func TestEntryCode(t *testing.T) {
+	data := []byte(`
+- path: /default
+  url: https://example.com/default
+- path: /301
+  url: https://example.com/301
+  code: 301
+- path: /302
+  url: https://example.com/302
+  code: 302
+- path: /307
+  url: https://example.com/307
+  code: 307
+- path: /308
+  url: https://example.com/308
+  code: 308
+`)
+	h, err := YAMLHandler(data, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/default", Location: "https://example.com/default", Code: http.StatusFound},
+		{Path: "/301", Location: "https://example.com/301", Code: http.StatusMovedPermanently},
+		{Path: "/302", Location: "https://example.com/302", Code: http.StatusFound},
+		{Path: "/307", Location: "https://example.com/307", Code: http.StatusTemporaryRedirect},
+		{Path: "/308", Location: "https://example.com/308", Code: http.StatusPermanentRedirect},
+	})
+
+	// WithStatus only changes the entries without a code
+	h, err = YAMLHandler(data, urlshorttest.Fallback(), WithStatus(http.StatusMovedPermanently))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/default", Location: "https://example.com/default", Code: http.StatusMovedPermanently},
+		{Path: "/302", Location: "https://example.com/302", Code: http.StatusFound},
+	})
+}
+
+func TestEntryCodeErrors(t *testing.T) {
+	for _, code := range []string{"200", "300", "303", "304", "404", "-1"} {
+		t.Run(code, func(t *testing.T) {
+			_, err := YAMLHandler([]byte("- path: /x\n  url: https://example.com\n  code: "+code+"\n"), urlshorttest.Fallback())
+			wantError(t, err, "/x: unsupported redirect code "+code+" (use 301, 302, 307 or 308)")
+			_, err = JSONHandler([]byte(`[{"path": "/x", "url": "https://example.com", "code": `+code+`}]`), urlshorttest.Fallback())
+			wantError(t, err, "/x: unsupported redirect code "+code)
+		})
+	}
+}
+
+const disabledEntries = `
+- path: /paused
+  url: https://example.com/broken
+  code: 301
+  disabled: true
+  headers:
+    X-Team: infra
+- path: /gh
+  url: https://github.com
+`
+
+func TestDisabledEntries(t *testing.T) {
+	stats := NewStats()
+	h, err := New(FromYAML([]byte(disabledEntries)), urlshorttest.Fallback(), WithStats(stats))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/paused"},
+		{Path: "/paused?x=1"},
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+	})
+
+	// the requests are counted on their own
+	snap := stats.Snapshot()
+	if got := snap.Disabled["/paused"].Hits; got != 2 {
+		t.Errorf("got %d disabled hits, want 2", got)
+	}
+	if _, ok := snap.Paths["/paused"]; ok {
+		t.Error("the disabled path was counted as a hit")
+	}
+	if _, ok := snap.Misses["/paused"]; ok {
+		t.Error("the disabled path was counted as a miss")
+	}
+
+	// the entry is kept with all of its settings
+	out, err := h.Export("yaml")
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if want := "- path: /paused\n  url: https://example.com/broken\n  code: 301\n"; !strings.Contains(string(out), want) || !strings.Contains(string(out), "disabled: true") {
+		t.Errorf("got export:\n%s", out)
+	}
+	if in := h.Inspect(httptest.NewRequest(http.MethodGet, "/paused", nil)); in.Matched || !in.Disabled || in.Entry != "/paused" {
+		t.Errorf("Inspect: got %+v", in)
+	}
+
+	// re-enabling it restores everything
+	entries := h.Snapshot()
+	for i := range entries {
+		entries[i].Disabled = false
+	}
+	if err := h.Reload(entries); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	res := get(h, "/paused")
+	if res.Code != http.StatusMovedPermanently || res.Header().Get("X-Team") != "infra" {
+		t.Errorf("enabled again: got %d with X-Team %q", res.Code, res.Header().Get("X-Team"))
+	}
+}
+
+func TestDisabledLookups(t *testing.T) {
+	yl, err := YAMLLookup([]byte(disabledEntries))
+	if err != nil {
+		t.Fatalf("YAMLLookup: %v", err)
+	}
+	store := NewMutableStore()
+	store.Set("/stored", "https://example.com/stored")
+	store.Disable("/stored")
+	res, err := YAMLResolver([]byte(disabledEntries))
+	if err != nil {
+		t.Fatalf("YAMLResolver: %v", err)
+	}
+
+	// everything that looks paths up treats disabled ones as missing
+	cases := []urlshorttest.Case{{Path: "/paused"}, {Path: "/stored"}, {Path: "/gh", Location: "https://github.com", Code: http.StatusFound}}
+	urlshorttest.TableTest(t, NewChain(urlshorttest.Fallback(), yl, store), cases)
+	urlshorttest.TableTest(t, NewMutableHandler(store, urlshorttest.Fallback()), cases[1:2])
+	urlshorttest.TableTest(t, ResolverHandler(res, urlshorttest.Fallback()), []urlshorttest.Case{cases[0], cases[2]})
+}
@@ -0,0 +1,155 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// FormatHCL is the format of HCL files, see HCLHandler
+const FormatHCL = "hcl"
+
+// HCLHandler works like YAMLHandler, but parses the mappings from
+// HCL instead, so they can live next to Terraform configuration.
+//
+// HCL is expected to be a redirect block per entry, labeled with
+// its path:
+//
+//	redirect "/gh" {
+//	  url  = "https://github.com"
+//	  code = 301
+//	}
+//
+//	redirect "/promo" {
+//	  url     = "https://www.some-url.com/campaign"
+//	  expires = "2025-01-31T00:00:00Z"
+//	  headers = { "Referrer-Policy" = "no-referrer" }
+//	}
+//
+// The attributes are named like the YAML keys. Weighted urls aren't
+// supported. Errors give the line and column of every problem HCL
+// found, unknown attributes included.
+func HCLHandler(hclBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	h, err := New(FromHCL(hclBytes), fallback, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return h.ServeHTTP, nil
+}
+
+// FromHCL is a source parsed from HCL like HCLHandler accepts
+func FromHCL(hclBytes []byte) Source {
+	return &source{name: "hcl", fn: func(cfg *config) ([]pathUrl, error) { return parseHCL(hclBytes) }}
+}
+
+// hclDoc is the top level of an HCL mapping document
+type hclDoc struct {
+	Redirects []hclRedirect `hcl:"redirect,block"`
+}
+
+// hclRedirect is a redirect block, gohcl can't decode into pathUrl
+// directly since the path is a label and every attribute optional
+type hclRedirect struct {
+	Path        string            `hcl:"path,label"`
+	URL         string            `hcl:"url,optional"`
+	Aliases     []string          `hcl:"aliases,optional"`
+	Code        int               `hcl:"code,optional"`
+	Regex       bool              `hcl:"regex,optional"`
+	Expires     string            `hcl:"expires,optional"`
+	ActiveFrom  string            `hcl:"active_from,optional"`
+	ActiveUntil string            `hcl:"active_until,optional"`
+	InactiveURL string            `hcl:"inactive_url,optional"`
+	Token       string            `hcl:"token,optional"`
+	UTM         map[string]string `hcl:"utm,optional"`
+	Headers     map[string]string `hcl:"headers,optional"`
+	Host        string            `hcl:"host,optional"`
+	Mode        string            `hcl:"mode,optional"`
+	Delay       int               `hcl:"delay,optional"`
+	CacheMaxAge int               `hcl:"cache_max_age,optional"`
+	Redirect    string            `hcl:"redirect,optional"`
+	AllowCIDRs  []string          `hcl:"allow_cidrs,optional"`
+	DenyCIDRs   []string          `hcl:"deny_cidrs,optional"`
+	Geo         map[string]string `hcl:"geo,optional"`
+	Lang        map[string]string `hcl:"lang,optional"`
+	Device      map[string]string `hcl:"device,optional"`
+	QueryAllow  []string          `hcl:"query_allow,optional"`
+	QueryStrip  []string          `hcl:"query_strip,optional"`
+	Template    bool              `hcl:"template,optional"`
+	Disabled    bool              `hcl:"disabled,optional"`
+}
+
+func (hr hclRedirect) entry() pathUrl {
+	return pathUrl{
+		Path:        hr.Path,
+		URL:         hr.URL,
+		Aliases:     hr.Aliases,
+		Code:        hr.Code,
+		Regex:       hr.Regex,
+		Expires:     hr.Expires,
+		ActiveFrom:  hr.ActiveFrom,
+		ActiveUntil: hr.ActiveUntil,
+		InactiveURL: hr.InactiveURL,
+		Token:       hr.Token,
+		UTM:         hr.UTM,
+		Headers:     hr.Headers,
+		Host:        hr.Host,
+		Mode:        hr.Mode,
+		Delay:       hr.Delay,
+		CacheMaxAge: hr.CacheMaxAge,
+		Redirect:    hr.Redirect,
+		AllowCIDRs:  hr.AllowCIDRs,
+		DenyCIDRs:   hr.DenyCIDRs,
+		Geo:         hr.Geo,
+		Lang:        hr.Lang,
+		Device:      hr.Device,
+		QueryAllow:  hr.QueryAllow,
+		QueryStrip:  hr.QueryStrip,
+		Template:    hr.Template,
+		Disabled:    hr.Disabled,
+	}
+}
+
+func parseHCL(data []byte) ([]pathUrl, error) {
+	file, diags := hclparse.NewParser().ParseHCL(data, "")
+	if diags.HasErrors() {
+		return nil, hclError(diags)
+	}
+	var doc hclDoc
+	if diags := gohcl.DecodeBody(file.Body, nil, &doc); diags.HasErrors() {
+		return nil, hclError(diags)
+	}
+
+	pathUrls := make([]pathUrl, len(doc.Redirects))
+	for i, hr := range doc.Redirects {
+		pathUrls[i] = hr.entry()
+	}
+	if err := checkEntries(pathUrls); err != nil {
+		return nil, err
+	}
+	return pathUrls, nil
+}
+
+// hclError turns the errors among diags into a single error, one
+// "line 3, column 5: summary; detail" per problem
+func hclError(diags hcl.Diagnostics) error {
+	var msgs []string
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+		var b strings.Builder
+		if d.Subject != nil {
+			fmt.Fprintf(&b, "line %d, column %d: ", d.Subject.Start.Line, d.Subject.Start.Column)
+		}
+		b.WriteString(d.Summary)
+		if d.Detail != "" {
+			b.WriteString("; " + d.Detail)
+		}
+		msgs = append(msgs, b.String())
+	}
+	return fmt.Errorf("urlshort: invalid HCL: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,156 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestHCLMatchesYAML(t *testing.T) {
+	hclData := `
+# infra links
+redirect "/gh" {
+  url  = "https://github.com"
+  code = 301
+}
+
+redirect "/promo" {
+  url     = "https://www.some-url.com/campaign"
+  expires = "2025-01-31T00:00:00Z"
+  headers = { "Referrer-Policy" = "no-referrer", "X-Team" = "growth" }
+}
+
+redirect "/docs" {
+  url     = "https://docs.example.com"
+  aliases = ["/d", "/documentation"]
+  utm     = { campaign = "docs" }
+}
+
+redirect "/u/:name" {
+  url = "https://github.com/:name"
+}
+
+redirect "/old" {
+  url      = "https://example.com/old"
+  disabled = true
+}
+`
+	yamlData := `
+- path: /gh
+  url: https://github.com
+  code: 301
+- path: /promo
+  url: https://www.some-url.com/campaign
+  expires: "2025-01-31T00:00:00Z"
+  headers:
+    Referrer-Policy: no-referrer
+    X-Team: growth
+- path: /docs
+  url: https://docs.example.com
+  aliases: [/d, /documentation]
+  utm:
+    campaign: docs
+- path: /u/:name
+  url: https://github.com/:name
+- path: /old
+  url: https://example.com/old
+  disabled: true
+`
+	for _, now := range []time.Time{
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		// /promo has expired
+		time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		opts := []Option{WithClock(func() time.Time { return now }), WithUTM("links", "web")}
+		fromHCL, err := New(FromHCL([]byte(hclData)), urlshorttest.Fallback(), opts...)
+		if err != nil {
+			t.Fatalf("HCL: %v", err)
+		}
+		fromYAML, err := New(FromYAML([]byte(yamlData)), urlshorttest.Fallback(), opts...)
+		if err != nil {
+			t.Fatalf("YAML: %v", err)
+		}
+		if got, want := fromHCL.Snapshot(), fromYAML.Snapshot(); !reflect.DeepEqual(got, want) {
+			t.Errorf("at %v: HCL entries %+v, YAML entries %+v", now, got, want)
+		}
+		for _, path := range []string{"/gh", "/promo", "/docs", "/d", "/documentation", "/u/nils", "/old", "/missing"} {
+			got, want := get(fromHCL, path), get(fromYAML, path)
+			if got.Code != want.Code || !reflect.DeepEqual(got.Header(), want.Header()) || got.Body.String() != want.Body.String() {
+				t.Errorf("at %v, GET %s: HCL %d %v, YAML %d %v", now, path, got.Code, got.Header(), want.Code, want.Header())
+			}
+		}
+	}
+
+	h, err := HCLHandler([]byte(hclData), urlshorttest.Fallback(), WithClock(func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }))
+	if err != nil {
+		t.Fatalf("HCLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/promo", Location: "https://www.some-url.com/campaign", Code: http.StatusFound},
+		{Path: "/d", Location: "https://docs.example.com?utm_campaign=docs", Code: http.StatusFound},
+		{Path: "/u/nils", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/old"},
+	})
+	if got := get(h, "/promo").Header().Get("X-Team"); got != "growth" {
+		t.Errorf("X-Team: got %q", got)
+	}
+}
+
+func TestHCLHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"syntax", "redirect \"/gh\" {\n  url = \n}\n", []string{"invalid HCL: line 2, column"}},
+		{"unknown attribute", "redirect \"/gh\" {\n  url = \"https://github.com\"\n  status = 301\n}\n",
+			[]string{"line 3, column 3: Unsupported argument", `"status"`}},
+		{"wrong type", "redirect \"/gh\" {\n  url  = \"https://github.com\"\n  code = \"permanent\"\n}\n",
+			[]string{"line 3, column", "Unsuitable value type"}},
+		{"missing label", "redirect {\n  url = \"https://github.com\"\n}\n", []string{"line 1, column", "Missing path for redirect"}},
+		{"unknown block", "link \"/gh\" {\n  url = \"https://github.com\"\n}\n", []string{"line 1, column 1", "Unsupported block type"}},
+		// every problem is listed, not just the first
+		{"several", "redirect \"/a\" {\n  status = 1\n}\nredirect \"/b\" {\n  target = \"x\"\n}\n",
+			[]string{"line 2, column 3", "line 5, column 3"}},
+		{"weighted urls", "redirect \"/a\" {\n  urls = []\n}\n", []string{"line 2, column 3: Unsupported argument"}},
+		{"missing url", "redirect \"/gh\" {\n  code = 301\n}\n", []string{"(/gh) is missing a url"}},
+		{"duplicate", "redirect \"/gh\" {\n  url = \"https://a.example.com\"\n}\nredirect \"/gh\" {\n  url = \"https://b.example.com\"\n}\n",
+			[]string{"duplicate path: /gh"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := HCLHandler([]byte(tt.data), nil)
+			for _, want := range tt.want {
+				wantError(t, err, want)
+			}
+		})
+	}
+}
+
+func TestHCLFile(t *testing.T) {
+	dir := t.TempDir()
+	good := writeFile(t, dir, "links.hcl", "redirect \"/gh\" {\n  url = \"https://github.com\"\n}\n")
+	fh, err := NewFileHandler(good, "", urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("NewFileHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, fh, "/gh", "https://github.com", http.StatusFound)
+
+	// errors name the file in front of the line
+	bad := writeFile(t, dir, "broken.hcl", "redirect \"/gh\" {\n  url = \"https://github.com\"\n  status = 301\n}\n")
+	_, err = NewFileHandler(bad, "", nil)
+	wantError(t, err, bad+": urlshort: invalid HCL: line 3, column 3")
+	if err := os.Remove(bad); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewFileHandler(bad, FormatHCL, nil)
+	if err == nil || !strings.Contains(err.Error(), "broken.hcl") {
+		t.Errorf("a missing file: got %v", err)
+	}
+}
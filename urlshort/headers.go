@@ -0,0 +1,37 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WithHeaders adds headers to every redirect, e.g. a
+// Referrer-Policy. Headers of an entry override these. Location
+// can't be set this way.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *config) {
+		c.headers = headers
+	}
+}
+
+// forbiddenHeader returns a header that must not be set through
+// the headers of an entry or WithHeaders, if there is one
+func forbiddenHeader(headers map[string]string) (string, bool) {
+	for name := range headers {
+		if strings.EqualFold(name, "Location") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// writeHeaders sets the handler level headers and then those of pu
+func writeHeaders(w http.ResponseWriter, pu pathUrl, cfg *config) {
+	h := w.Header()
+	for name, value := range cfg.headers {
+		h.Set(name, value)
+	}
+	for name, value := range pu.Headers {
+		h.Set(name, value)
+	}
+}
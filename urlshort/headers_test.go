@@ -0,0 +1,60 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestHeaders(t *testing.T) {
+	data := `
+- path: /partner
+  url: https://partner.example.com
+  headers: {Referrer-Policy: no-referrer, X-Campaign: spring}
+- path: /plain
+  url: https://plain.example.com
+`
+	tests := []struct {
+		name string
+		opts []Option
+		path string
+		want map[string]string
+	}{
+		{"entry", nil, "/partner", map[string]string{"Referrer-Policy": "no-referrer", "X-Campaign": "spring"}},
+		{"no headers", nil, "/plain", map[string]string{"Referrer-Policy": "", "X-Campaign": ""}},
+		{"handler", []Option{WithHeaders(map[string]string{"X-Shortener": "1"})}, "/plain", map[string]string{"X-Shortener": "1"}},
+		{"entry overrides handler", []Option{WithHeaders(map[string]string{"Referrer-Policy": "origin", "X-Shortener": "1"})}, "/partner",
+			map[string]string{"Referrer-Policy": "no-referrer", "X-Shortener": "1"}},
+		{"fallback", []Option{WithHeaders(map[string]string{"X-Shortener": "1"})}, "/missing", map[string]string{"X-Shortener": "", "Referrer-Policy": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			if tt.path == "/missing" {
+				urlshorttest.AssertFallback(t, h, tt.path)
+			}
+			res := get(h, tt.path)
+			if tt.path != "/missing" && res.Code != http.StatusFound {
+				t.Fatalf("GET %s: got %d, want 302", tt.path, res.Code)
+			}
+			for name, want := range tt.want {
+				if got := res.Header().Get(name); got != want {
+					t.Errorf("GET %s: %s is %q, want %q", tt.path, name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHeadersLocation(t *testing.T) {
+	_, err := YAMLHandler([]byte("- path: /a\n  url: https://a.example.com\n  headers: {location: https://evil.example.com}\n"), urlshorttest.Fallback())
+	wantError(t, err, "the location header can't be set")
+
+	_, err = YAMLHandler([]byte("- path: /a\n  url: https://a.example.com\n"), urlshorttest.Fallback(),
+		WithHeaders(map[string]string{"Location": "https://evil.example.com"}))
+	wantError(t, err, "the Location header can't be set")
+}
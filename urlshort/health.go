@@ -0,0 +1,44 @@
+package urlshort
+
+import (
+	"net/http"
+	"time"
+)
+
+// healthStatus is the body of GET /readyz
+type healthStatus struct {
+	Status     string     `json:"status"`
+	Mappings   int        `json:"mappings"`
+	Source     string     `json:"source"`
+	LastReload *time.Time `json:"last_reload,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness checks of h:
+//
+//	GET /healthz  always 200, the process is up
+//	GET /readyz   200 with the mappings being served
+//
+// /readyz answers with the number of mappings, where they come from
+// and when they were last loaded. Every handler loads its mappings
+// before its constructor returns, so h is ready as soon as it
+// exists: start listening for the checks once it is built. Mount it
+// on its own mux or port to keep the checks away from the short
+// links, e.g. for a WatchedHandler wh:
+//
+//	go http.ListenAndServe(":9090", urlshort.HealthHandler(&wh.Handler))
+func HealthHandler(h *Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		last := h.LastLoad()
+		writeJSON(w, http.StatusOK, healthStatus{
+			Status:     "ok",
+			Mappings:   h.Len(),
+			Source:     h.source,
+			LastReload: &last,
+		})
+	})
+	return mux
+}
@@ -0,0 +1,81 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthHandler(t *testing.T) {
+	clock := newFakeClock()
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com", "/go": "https://go.dev"}), nil, WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	health := HealthHandler(h)
+
+	res := get(health, "/healthz")
+	if res.Code != http.StatusOK || res.Body.String() != `{"status":"ok"}`+"\n" {
+		t.Errorf("GET /healthz: got %d %s", res.Code, res.Body.String())
+	}
+
+	// readyz reports the mappings and when they were loaded
+	readyz := func(want healthStatus) {
+		t.Helper()
+		res := get(health, "/readyz")
+		if res.Code != http.StatusOK {
+			t.Fatalf("GET /readyz: got %d, want 200", res.Code)
+		}
+		if got := res.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("got Content-Type %q", got)
+		}
+		var got healthStatus
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decoding %s: %v", res.Body.String(), err)
+		}
+		if got.Status != want.Status || got.Mappings != want.Mappings || got.Source != want.Source ||
+			got.LastReload == nil || !got.LastReload.Equal(*want.LastReload) {
+			t.Errorf("GET /readyz: got %s", res.Body.String())
+		}
+	}
+	loaded := clock.Now()
+	readyz(healthStatus{Status: "ok", Mappings: 2, Source: "map", LastReload: &loaded})
+
+	clock.Advance(time.Hour)
+	if err := h.Reload([]Entry{{Path: "/gh", URL: "https://github.com"}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	reloaded := clock.Now()
+	readyz(healthStatus{Status: "ok", Mappings: 1, Source: "map", LastReload: &reloaded})
+
+	// a failed reload leaves the last load alone
+	clock.Advance(time.Hour)
+	if err := h.Reload([]Entry{{Path: "/gh", URL: "ftp://github.com"}, {Path: "/go", URL: "https://go.dev"}}); err == nil {
+		t.Fatal("Reload accepted an invalid destination")
+	}
+	readyz(healthStatus{Status: "ok", Mappings: 1, Source: "map", LastReload: &reloaded})
+}
+
+func TestHealthHandlerRoutes(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	health := HealthHandler(h)
+	tests := []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK},
+		{http.MethodHead, "/readyz", http.StatusOK},
+		{http.MethodPost, "/healthz", http.StatusMethodNotAllowed},
+		// the short links aren't served here
+		{http.MethodGet, "/gh", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if res := call(health, tt.method, tt.target, ""); res.Code != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.target, res.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// RedirectHook is called after a redirect was written. path is the
+// request path, dest and status are what the client was sent.
+type RedirectHook func(r *http.Request, path, dest string, status int)
+
+// MissHook is called before a request is passed to the fallback
+type MissHook func(r *http.Request)
+
+// DisableHook is called after an entry was disabled because its
+// destination kept failing, reason is why the last check failed
+type DisableHook func(path, dest, reason string)
+
+// WithRedirectHook calls fn for every redirect, e.g. to write an
+// audit record. Panics inside fn are recovered, a broken hook never
+// breaks request handling. WithRecovery gets them reported.
+func WithRedirectHook(fn RedirectHook) Option {
+	return func(c *config) {
+		c.redirectHook = fn
+	}
+}
+
+// WithMissHook calls fn for every request that falls through to the
+// fallback. Panics inside fn are recovered.
+func WithMissHook(fn MissHook) Option {
+	return func(c *config) {
+		c.missHook = fn
+	}
+}
+
+// WithDisableHook calls fn for every entry a LinkChecker disables,
+// e.g. to alert someone. It is an option of LinkCheckOptions.Hooks.
+// Panics inside fn are recovered.
+func WithDisableHook(fn DisableHook) Option {
+	return func(c *config) {
+		c.disableHook = fn
+	}
+}
+
+// WithAsyncHooks runs the hooks on workers background goroutines
+// instead of the request goroutine, for hooks that talk to slow
+// sinks. At most queueSize calls wait for a worker, further calls
+// are dropped rather than slowing down requests. The hooks get a
+// copy of the request, since the original may be gone by then.
+//
+// The workers start with the first hook call. Use Handler.CloseHooks
+// on shutdown to stop them once the queued calls ran, the hooks of a
+// LinkChecker are stopped by its Close. Like with WithWebhook,
+// handlers that are only an http.HandlerFunc can't be closed.
+func WithAsyncHooks(workers, queueSize int) Option {
+	return func(c *config) {
+		c.hookWorkers, c.hookQueueSize = workers, queueSize
+	}
+}
+
+// hookQueue hands hook calls to a fixed set of workers. They are
+// started by the first call, so configs that never serve a request,
+// like the ones of failed constructors, don't leave any behind.
+type hookQueue struct {
+	calls   chan func()
+	workers int
+	report  func(p *hookPanic)
+
+	start sync.Once
+	// mu guards closing calls against submits
+	mu      sync.RWMutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func newHookQueue(workers, size int, report func(p *hookPanic)) *hookQueue {
+	return &hookQueue{calls: make(chan func(), size), workers: workers, report: report}
+}
+
+// run starts the workers
+func (q *hookQueue) run() {
+	q.running.Add(q.workers)
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			defer q.running.Done()
+			for call := range q.calls {
+				q.report(safeCall(call))
+			}
+		}()
+	}
+}
+
+// submit queues call and reports false if the queue is full or
+// closed
+func (q *hookQueue) submit(call func()) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	q.start.Do(q.run)
+	select {
+	case q.calls <- call:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops taking calls and returns once the workers ran the
+// queued ones
+func (q *hookQueue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.calls)
+	}
+	q.mu.Unlock()
+	q.running.Wait()
+}
+
+// CloseHooks stops the workers of WithAsyncHooks and returns once
+// the queued hook calls ran. Hooks of later requests are dropped.
+// Without WithAsyncHooks there is nothing to stop.
+func (h *Handler) CloseHooks() error {
+	if h.cfg.hookQueue != nil {
+		h.cfg.hookQueue.close()
+	}
+	return nil
+}
+
+// runHook calls fn directly or through the hook queue
+func (c *config) runHook(r *http.Request, fn func(r *http.Request)) {
+	if c.hookQueue == nil {
+		if p := safeCall(func() { fn(r) }); p != nil {
+			if p.err == http.ErrAbortHandler {
+				panic(p.err)
+			}
+			c.reportHookPanic(p)
+		}
+		return
+	}
+	ctx := context.Background()
+	if le := r.Context().Value(lookupErrorKey); le != nil {
+		// for LookupError
+		ctx = context.WithValue(ctx, lookupErrorKey, le)
+	}
+	rc := r.Clone(ctx)
+	c.hookQueue.submit(func() { fn(rc) })
+}
+
+// onRedirect runs the redirect hook, if there is one, and queues
+// the events of the webhooks
+func (c *config) onRedirect(r *http.Request, dest string, status int) {
+	path := r.URL.Path
+	for _, wh := range c.webhooks {
+		wh.notify(r, path, dest, c.now())
+	}
+	if c.redirectHook == nil {
+		return
+	}
+	c.runHook(r, func(r *http.Request) { c.redirectHook(r, path, dest, status) })
+}
+
+// onMiss runs the miss hook, if there is one
+func (c *config) onMiss(r *http.Request) {
+	if c.missHook == nil {
+		return
+	}
+	c.runHook(r, func(r *http.Request) { c.missHook(r) })
+}
+
+// onDisable runs the disable hook, if there is one
+func (c *config) onDisable(path, dest, reason string) {
+	if c.disableHook == nil {
+		return
+	}
+	if c.hookQueue == nil {
+		c.reportHookPanic(safeCall(func() { c.disableHook(path, dest, reason) }))
+		return
+	}
+	c.hookQueue.submit(func() { c.disableHook(path, dest, reason) })
+}
+
+// hookPanic is a panic safeCall recovered
+type hookPanic struct {
+	err   any
+	stack []byte
+}
+
+// safeCall calls fn and returns the panic it recovered, if any
+func safeCall(fn func()) (p *hookPanic) {
+	defer func() {
+		if err := recover(); err != nil {
+			p = &hookPanic{err: err, stack: debug.Stack()}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// reportHookPanic passes p to the WithRecovery logger, without one
+// hook panics are swallowed like they always were
+func (c *config) reportHookPanic(p *hookPanic) {
+	if p == nil || c.recoverLog == nil {
+		return
+	}
+	c.recoverLog(p.err, p.stack)
+}
+
+// validateHooks checks the hook options and sets up the queue
+func (c *config) validateHooks() error {
+	if c.hookWorkers == 0 && c.hookQueueSize == 0 {
+		return nil
+	}
+	if c.hookWorkers <= 0 || c.hookQueueSize < 0 {
+		return errors.New("urlshort: async hooks need at least one worker and a non-negative queue size")
+	}
+	c.hookQueue = newHookQueue(c.hookWorkers, c.hookQueueSize, c.reportHookPanic)
+	return nil
+}
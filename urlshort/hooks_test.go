@@ -0,0 +1,163 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// hookCall is one call of a redirect or miss hook
+type hookCall struct {
+	path, dest string
+	status     int
+}
+
+// hookRecorder records the calls of its hooks
+type hookRecorder struct {
+	mu    sync.Mutex
+	calls []hookCall
+}
+
+func (hr *hookRecorder) redirect(r *http.Request, path, dest string, status int) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.calls = append(hr.calls, hookCall{path, dest, status})
+}
+
+func (hr *hookRecorder) miss(r *http.Request) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.calls = append(hr.calls, hookCall{path: r.URL.Path})
+}
+
+func (hr *hookRecorder) len() int {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	return len(hr.calls)
+}
+
+func TestHooks(t *testing.T) {
+	var hr hookRecorder
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(),
+		WithRedirectHook(hr.redirect), WithMissHook(hr.miss))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// the hooks run before ServeHTTP returns
+	get(h, "/gh")
+	get(h, "/missing")
+	want := []hookCall{{"/gh", "https://github.com", http.StatusFound}, {path: "/missing"}}
+	if len(hr.calls) != len(want) || hr.calls[0] != want[0] || hr.calls[1] != want[1] {
+		t.Errorf("got calls %v, want %v", hr.calls, want)
+	}
+	// there are no workers to stop
+	if err := h.CloseHooks(); err != nil {
+		t.Errorf("CloseHooks: %v", err)
+	}
+	get(h, "/gh")
+	if hr.len() != 3 {
+		t.Errorf("got %d calls after CloseHooks, want 3", hr.len())
+	}
+}
+
+func TestAsyncHooks(t *testing.T) {
+	var hr hookRecorder
+	release := make(chan struct{})
+	var live atomic.Int32
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(), WithAsyncHooks(2, 16),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) {
+			<-release
+			// the hook gets a request that outlives the original
+			if r.Context().Err() == nil {
+				live.Add(1)
+			}
+			hr.redirect(r, path, dest, status)
+		}),
+		WithMissHook(hr.miss))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// requests don't wait for the hooks
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/gh", nil).WithContext(ctx)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		cancel()
+	}
+	get(h, "/missing")
+	if got := hr.len(); got != 0 {
+		t.Errorf("got %d calls before the hooks were released, want 0", got)
+	}
+
+	// CloseHooks waits for the queued calls
+	close(release)
+	if err := h.CloseHooks(); err != nil {
+		t.Fatalf("CloseHooks: %v", err)
+	}
+	if got := hr.len(); got != 6 {
+		t.Errorf("got %d calls, want 6", got)
+	}
+	if live.Load() != 5 {
+		t.Errorf("%d of 5 hooks got a live request", live.Load())
+	}
+
+	// later hooks are dropped, requests are still served
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	h.CloseHooks()
+	if got := hr.len(); got != 6 {
+		t.Errorf("got %d calls after CloseHooks, want 6", got)
+	}
+}
+
+func TestAsyncHooksQueueFull(t *testing.T) {
+	var hr hookRecorder
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(), WithAsyncHooks(1, 1),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) {
+			started <- struct{}{}
+			<-release
+			hr.redirect(r, path, dest, status)
+		}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	get(h, "/gh")
+	<-started
+	// one call is running and one waits, the rest is dropped
+	for i := 0; i < 5; i++ {
+		urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	}
+	close(release)
+	h.CloseHooks()
+	if got := hr.len(); got != 2 {
+		t.Errorf("got %d calls, want 2", got)
+	}
+}
+
+func TestAsyncHooksStartLazily(t *testing.T) {
+	// failed constructors leave no workers behind
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		_, err := YAMLHandler([]byte("- path: /x\n  url: ftp://example.com\n"), urlshorttest.Fallback(), WithAsyncHooks(100, 1))
+		if err == nil {
+			t.Fatal("YAMLHandler accepted an invalid url")
+		}
+	}
+	if after := runtime.NumGoroutine(); after >= before+100 {
+		t.Errorf("%d goroutines before, %d after", before, after)
+	}
+}
+
+func TestAsyncHooksErrors(t *testing.T) {
+	for _, opt := range []Option{WithAsyncHooks(0, 1), WithAsyncHooks(-1, 1), WithAsyncHooks(1, -1)} {
+		_, err := New(FromMap(nil), urlshorttest.Fallback(), opt)
+		wantError(t, err, "async hooks need at least one worker")
+	}
+}
@@ -0,0 +1,80 @@
+package urlshort
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// normalizeHost returns the form of a host used as a key, without
+// the port and in lower case, since host names are case-insensitive
+// (RFC 4343).
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+	return strings.ToLower(host)
+}
+
+// WithWildcardApex makes entries for *.example.com match requests
+// for example.com itself too, with an empty :subdomain. Without it
+// the wildcard needs at least one label in front.
+func WithWildcardApex() Option {
+	return func(c *config) {
+		c.wildcardApex = true
+	}
+}
+
+// wildcardSuffix returns the domain a host like *.example.com
+// stands for the subdomains of, host must be normalized
+func wildcardSuffix(host string) (string, bool) {
+	return strings.CutPrefix(host, "*.")
+}
+
+// checkHost makes sure a * in host is a leading *. wildcard
+func checkHost(pu pathUrl) error {
+	host := normalizeHost(pu.Host)
+	if suffix, ok := wildcardSuffix(host); ok {
+		host = suffix
+	}
+	if host == "" || strings.Contains(host, "*") {
+		return fmt.Errorf("urlshort: %s: host %q can only have a leading *. wildcard", pu.Path, pu.Host)
+	}
+	return nil
+}
+
+// lookupWildcard returns the entry for path in the table of the
+// closest wildcard that matches host, which must be normalized. The
+// labels in front of the wildcard's domain fill in :subdomain.
+func (rt *routeTable) lookupWildcard(host, path string) (match, bool) {
+	// the apex is the longest domain there is
+	if rt.wildcardApex {
+		if m, ok := rt.lookupSubdomain(host, "", path); ok {
+			return m, true
+		}
+	}
+	for i := 0; i < len(host); i++ {
+		if host[i] != '.' {
+			continue
+		}
+		if m, ok := rt.lookupSubdomain(host[i+1:], host[:i], path); ok {
+			return m, true
+		}
+	}
+	return match{}, false
+}
+
+// lookupSubdomain looks up path in the table of *.domain
+func (rt *routeTable) lookupSubdomain(domain, subdomain, path string) (match, bool) {
+	hostRoutes := rt.wildcards[domain]
+	if hostRoutes == nil {
+		return match{}, false
+	}
+	m, ok := hostRoutes.lookup(path)
+	if !ok {
+		return match{}, false
+	}
+	m.dest = substituteParams(m.dest, map[string]string{"subdomain": subdomain})
+	return m, true
+}
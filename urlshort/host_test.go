@@ -0,0 +1,132 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// hostEntries maps /docs differently on two hosts and generically
+const hostEntries = `
+- path: /docs
+  url: https://docs.example.com
+- path: /docs
+  url: https://go.dev/doc
+  host: go.example.com
+- path: /docs
+  url: https://wiki.example.org
+  host: Links.Example.Org
+- path: /only-go
+  url: https://go.dev
+  host: go.example.com
+`
+
+func TestHostRouting(t *testing.T) {
+	h, err := YAMLHandler([]byte(hostEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "http://go.example.com/docs", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "http://links.example.org/docs", Location: "https://wiki.example.org", Code: http.StatusFound},
+		// ports and case don't matter
+		{Path: "http://go.example.com:8080/docs", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "http://GO.Example.COM/docs", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "http://links.example.org./docs", Location: "https://wiki.example.org", Code: http.StatusFound},
+		// unknown hosts get the entries without a host
+		{Path: "http://other.example.net/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "http://127.0.0.1:8080/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "http://go.example.com/only-go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "http://links.example.org/only-go"},
+		{Path: "http://other.example.net/only-go"},
+	})
+}
+
+func TestHostRoutingDuplicates(t *testing.T) {
+	data := `
+- path: /docs
+  url: https://a.example.com
+  host: go.example.com
+- path: /docs
+  url: https://b.example.com
+  host: GO.example.com
+`
+	_, err := YAMLHandler([]byte(data), urlshorttest.Fallback())
+	wantError(t, err, "duplicate path: /docs")
+}
+
+// wildcardEntries maps the subdomains of go.example.com, with an
+// exact host and a nested wildcard that win over *.go.example.com
+const wildcardEntries = `
+- path: /api
+  url: https://docs.example.com/:subdomain/api
+  host: "*.go.example.com"
+- path: /api
+  url: https://play.go.dev/api
+  host: play.go.example.com
+- path: /api
+  url: https://eu.example.com/:subdomain/api
+  host: "*.eu.go.example.com"
+- path: /api
+  url: https://api.example.com
+- path: /home
+  url: https://go.dev/home
+  host: "*.go.example.com"
+`
+
+func TestWildcardHosts(t *testing.T) {
+	h, err := YAMLHandler([]byte(wildcardEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "http://docs.go.example.com/api", Location: "https://docs.example.com/docs/api", Code: http.StatusFound},
+		// the exact host wins over the wildcard
+		{Path: "http://play.go.example.com/api", Location: "https://play.go.dev/api", Code: http.StatusFound},
+		// several labels fill in :subdomain together
+		{Path: "http://a.docs.go.example.com/api", Location: "https://docs.example.com/a.docs/api", Code: http.StatusFound},
+		// the wildcard of the longest domain wins
+		{Path: "http://paris.eu.go.example.com/api", Location: "https://eu.example.com/paris/api", Code: http.StatusFound},
+		{Path: "http://a.paris.eu.go.example.com/api", Location: "https://eu.example.com/a.paris/api", Code: http.StatusFound},
+		// ports and case don't matter
+		{Path: "http://docs.go.example.com:8080/api", Location: "https://docs.example.com/docs/api", Code: http.StatusFound},
+		{Path: "http://Docs.GO.example.com/api", Location: "https://docs.example.com/docs/api", Code: http.StatusFound},
+		{Path: "http://PLAY.go.example.com:443/api", Location: "https://play.go.dev/api", Code: http.StatusFound},
+		// entries without a host come last
+		{Path: "http://other.example.net/api", Location: "https://api.example.com", Code: http.StatusFound},
+		{Path: "http://docs.go.example.org/api", Location: "https://api.example.com", Code: http.StatusFound},
+		// *. needs a label in front by default
+		{Path: "http://go.example.com/api", Location: "https://api.example.com", Code: http.StatusFound},
+		{Path: "http://go.example.com/home"},
+		{Path: "http://fakego.example.com/home"},
+		{Path: "http://docs.go.example.com/home", Location: "https://go.dev/home", Code: http.StatusFound},
+		{Path: "http://docs.go.example.com/missing"},
+	})
+}
+
+func TestWildcardApex(t *testing.T) {
+	h, err := YAMLHandler([]byte(wildcardEntries), urlshorttest.Fallback(), WithWildcardApex())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// the apex matches with an empty :subdomain
+		{Path: "http://go.example.com/api", Location: "https://docs.example.com//api", Code: http.StatusFound},
+		{Path: "http://go.example.com:8080/home", Location: "https://go.dev/home", Code: http.StatusFound},
+		// the apex of *.eu.go.example.com wins over *.go.example.com
+		{Path: "http://eu.go.example.com/api", Location: "https://eu.example.com//api", Code: http.StatusFound},
+		{Path: "http://docs.go.example.com/api", Location: "https://docs.example.com/docs/api", Code: http.StatusFound},
+		{Path: "http://example.com/home"},
+	})
+}
+
+func TestWildcardHostErrors(t *testing.T) {
+	for _, host := range []string{"*", "*.", "go.*.example.com", "*go.example.com", "*.*.example.com", "go.example.*"} {
+		t.Run(host, func(t *testing.T) {
+			data := "- path: /api\n  url: https://example.com\n  host: \"" + host + "\"\n"
+			_, err := YAMLHandler([]byte(data), urlshorttest.Fallback())
+			wantError(t, err, "can only have a leading *. wildcard")
+		})
+	}
+}
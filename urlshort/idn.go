@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// WithUnicodeHosts keeps internationalized destination hosts like
+// bücher.example as they are configured. By default they are
+// converted to punycode (xn--bcher-kva.example) when the handler is
+// built, since some clients mishandle unicode in Location headers.
+func WithUnicodeHosts() Option {
+	return func(c *config) {
+		c.unicodeHosts = true
+	}
+}
+
+// punycodeEntries returns pathUrls with every destination host
+// converted to its ASCII form. Hosts that can't be converted are all
+// reported together in a *ValidationError.
+func punycodeEntries(pathUrls []pathUrl, cfg *config) ([]pathUrl, error) {
+	if cfg.unicodeHosts {
+		return pathUrls, nil
+	}
+
+	var issues []ValidationIssue
+	var converted []pathUrl
+	convert := func(i int, dest *string) {
+		ascii, err := punycodeHost(*dest)
+		if err != nil {
+			issues = append(issues, ValidationIssue{Path: pathUrls[i].Path, Reason: err.Error()})
+			return
+		}
+		*dest = ascii
+	}
+
+	for i, pu := range pathUrls {
+		if !hasUnicodeHost(pu) {
+			continue
+		}
+		// copy on the first change, the caller's entries stay as they are
+		if converted == nil {
+			converted = append([]pathUrl(nil), pathUrls...)
+		}
+		entry := &converted[i]
+		entry.URLs = append([]weightedURL(nil), entry.URLs...)
+		convert(i, &entry.URL)
+		convert(i, &entry.InactiveURL)
+		for j := range entry.URLs {
+			convert(i, &entry.URLs[j].URL)
+		}
+		entry.mapDestinations(func(dest string) string {
+			convert(i, &dest)
+			return dest
+		})
+	}
+	if len(issues) > 0 {
+		return nil, &ValidationError{Issues: issues}
+	}
+	if converted == nil {
+		return pathUrls, nil
+	}
+	return converted, nil
+}
+
+// hasUnicodeHost reports whether any destination of pu may have a
+// host that isn't ASCII. It only looks for non-ASCII bytes, so it
+// can be wrong in the cheap direction.
+func hasUnicodeHost(pu pathUrl) bool {
+	if !isASCII(pu.URL) || !isASCII(pu.InactiveURL) {
+		return true
+	}
+	for _, wu := range pu.URLs {
+		if !isASCII(wu.URL) {
+			return true
+		}
+	}
+	return pu.anyMapDestination(func(dest string) bool {
+		return !isASCII(dest)
+	})
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// punycodeHost converts the host of dest to punycode. Only the host
+// is replaced, the rest of dest is left exactly as it was.
+func punycodeHost(dest string) (string, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" || isASCII(u.Host) {
+		// invalid urls are reported by the destination checks
+		return dest, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(u.Hostname())
+	if err != nil {
+		return "", fmt.Errorf("url %q: invalid internationalized host: %v", dest, err)
+	}
+	host := ascii
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(ascii, port)
+	}
+
+	// replace the host where it is written, u.String would
+	// re-encode the path and query
+	i := strings.Index(dest, u.Host)
+	if i < 0 {
+		u.Host = host
+		return u.String(), nil
+	}
+	return dest[:i] + host + dest[i+len(u.Host):], nil
+}
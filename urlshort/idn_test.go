@@ -0,0 +1,101 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestPunycodeHost(t *testing.T) {
+	tests := []struct {
+		dest, want string
+	}{
+		{"https://bücher.example/", "https://xn--bcher-kva.example/"},
+		{"https://Bücher.example", "https://xn--bcher-kva.example"},
+		// only the host changes
+		{"https://bücher.example:8443/bücher?q=ü#ä", "https://xn--bcher-kva.example:8443/bücher?q=ü#ä"},
+		{"https://user@bücher.example/", "https://user@xn--bcher-kva.example/"},
+		{"https://github.com/ü", "https://github.com/ü"},
+		{"/relative/ü", "/relative/ü"},
+		{"://broken", "://broken"},
+	}
+	for _, tt := range tests {
+		got, err := punycodeHost(tt.dest)
+		if err != nil || got != tt.want {
+			t.Errorf("punycodeHost(%q) = %q, %v, want %q", tt.dest, got, err, tt.want)
+		}
+	}
+
+	if _, err := punycodeHost("https://a b.example/"); err == nil {
+		t.Error("a host with a no-break space: no error")
+	}
+}
+
+const idnEntries = `
+- path: /buch
+  url: https://bücher.example/neu
+- path: /ab
+  urls:
+    - url: https://bücher.example/a
+      weight: 1
+- path: /lang
+  lang:
+    de: https://bücher.example/de
+    default: https://example.com
+- path: /gh
+  url: https://github.com
+`
+
+func TestUnicodeHosts(t *testing.T) {
+	h, err := YAMLHandler([]byte(idnEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	de := http.Header{"Accept-Language": {"de"}}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/buch", Location: "https://xn--bcher-kva.example/neu", Code: http.StatusFound},
+		{Path: "/ab", Location: "https://xn--bcher-kva.example/a", Code: http.StatusFound},
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+	})
+	if got := requestFrom(h, "/lang", "198.51.100.1:5000", de).Header().Get("Location"); got != "https://xn--bcher-kva.example/de" {
+		t.Errorf("GET /lang in German: got %q", got)
+	}
+
+	h, err = YAMLHandler([]byte(idnEntries), urlshorttest.Fallback(), WithUnicodeHosts())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	// net/http escapes the bytes of the Location header, the host
+	// isn't converted
+	urlshorttest.AssertRedirect(t, h, "/buch", "https://b%c3%bccher.example/neu", http.StatusFound)
+}
+
+func TestPunycodeEntries(t *testing.T) {
+	pathUrls := []pathUrl{
+		{Path: "/gh", URL: "https://github.com"},
+		{Path: "/buch", URL: "https://bücher.example", InactiveURL: "https://bücher.example/alt"},
+	}
+	converted, err := punycodeEntries(pathUrls, defaultConfig())
+	if err != nil {
+		t.Fatalf("punycodeEntries: %v", err)
+	}
+	if converted[1].URL != "https://xn--bcher-kva.example" || converted[1].InactiveURL != "https://xn--bcher-kva.example/alt" {
+		t.Errorf("got %+v", converted[1])
+	}
+	// the entries passed in stay as they are
+	if pathUrls[1].URL != "https://bücher.example" {
+		t.Errorf("the entry was changed: %+v", pathUrls[1])
+	}
+
+	// every bad host is reported
+	_, err = punycodeEntries([]pathUrl{
+		{Path: "/a", URL: "https://a b.example"},
+		{Path: "/b", URL: "https://c d.example"},
+	}, defaultConfig())
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Issues) != 2 || verr.Issues[1].Path != "/b" {
+		t.Errorf("got %v, want a *ValidationError for /a and /b", err)
+	}
+}
@@ -0,0 +1,196 @@
+package urlshort
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ImportReport tells what ImportBitly and ImportRebrandly did with
+// the rows of an export
+type ImportReport struct {
+	// Imported is the number of entries returned
+	Imported int
+	// Skipped are the rows that weren't imported, with the reason
+	Skipped []SkippedRow
+	// Stats holds the clicks of the imported links as hits. Pass it to
+	// Stats.Restore, or save it with a StatsPersister, to keep
+	// counting from there.
+	Stats StatsSnapshot
+}
+
+// SkippedRow is a row of an export that wasn't imported
+type SkippedRow struct {
+	Line int
+	// Path is the path the row would have become, if it had one
+	Path   string
+	Reason string
+}
+
+// importColumns names the columns of an export format. Each field
+// lists the accepted header names, normalized by importHeader.
+type importColumns struct {
+	format string
+	url    []string
+	path   []string
+	clicks []string
+}
+
+var bitlyColumns = importColumns{
+	format: "bit.ly",
+	url:    []string{"long_url", "destination", "original_url"},
+	path:   []string{"keyword", "back_half", "custom_back_half", "bitlink", "link"},
+	clicks: []string{"clicks", "total_clicks"},
+}
+
+var rebrandlyColumns = importColumns{
+	format: "Rebrandly",
+	url:    []string{"destination", "long_url"},
+	path:   []string{"slashtag", "back_half", "short_url", "shorturl"},
+	clicks: []string{"clicks"},
+}
+
+// ImportBitly reads a CSV export of bit.ly links. The header row
+// names the columns, the long_url and keyword (or back-half or
+// bitlink) columns are needed, clicks is optional and the others,
+// like created, are ignored. The back-half becomes the path, like
+// /abc for bit.ly/abc, and the long url its destination.
+//
+// Rows that can't be served, like app deep links or a back-half
+// that was already imported, are skipped and listed in the report
+// rather than failing the import. An error is only returned for
+// exports that can't be read at all.
+func ImportBitly(r io.Reader) ([]pathUrl, ImportReport, error) {
+	return importExport(r, bitlyColumns)
+}
+
+// ImportRebrandly works like ImportBitly, for CSV exports of
+// Rebrandly with the slashtag and destination columns
+func ImportRebrandly(r io.Reader) ([]pathUrl, ImportReport, error) {
+	return importExport(r, rebrandlyColumns)
+}
+
+// importExport reads an export with the columns of cols
+func importExport(r io.Reader, cols importColumns) ([]pathUrl, ImportReport, error) {
+	report := ImportReport{Stats: StatsSnapshot{Paths: make(map[string]PathStats), Misses: make(map[string]PathStats)}}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("the file is empty")
+		}
+		return nil, report, fmt.Errorf("urlshort: invalid %s export: %v", cols.format, err)
+	}
+	if len(header) > 0 {
+		// exports saved with Excel start with a byte order mark
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[importHeader(name)] = i
+	}
+	column := func(names []string) int {
+		for _, name := range names {
+			if i, ok := index[name]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	urlCol, pathCol, clicksCol := column(cols.url), column(cols.path), column(cols.clicks)
+	if urlCol < 0 || pathCol < 0 {
+		return nil, report, fmt.Errorf("urlshort: invalid %s export: need the %s and %s columns, got %s",
+			cols.format, cols.url[0], cols.path[0], strings.Join(header, ","))
+	}
+
+	var pathUrls []pathUrl
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, report, fmt.Errorf("urlshort: invalid %s export: %v", cols.format, err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+		skip := func(path, reason string) {
+			report.Skipped = append(report.Skipped, SkippedRow{Line: line, Path: path, Reason: reason})
+		}
+
+		path := backHalfPath(field(pathCol))
+		dest := field(urlCol)
+		switch {
+		case path == "":
+			skip("", "no back-half")
+			continue
+		case dest == "":
+			skip(path, "no destination")
+			continue
+		}
+		if prev, ok := seen[path]; ok {
+			skip(path, fmt.Sprintf("duplicate back-half, first seen on line %d", prev))
+			continue
+		}
+		if err := validateMapping(path, dest); err != nil {
+			skip(path, strings.TrimPrefix(err.Error(), "urlshort: "))
+			continue
+		}
+		var clicks int64
+		if v := strings.ReplaceAll(field(clicksCol), ",", ""); v != "" {
+			if clicks, err = strconv.ParseInt(v, 10, 64); err != nil || clicks < 0 {
+				skip(path, fmt.Sprintf("clicks %q is not a count", field(clicksCol)))
+				continue
+			}
+		}
+
+		seen[path] = line
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+		if clicks > 0 {
+			report.Stats.Paths[path] = PathStats{Hits: clicks}
+		}
+	}
+	report.Imported = len(pathUrls)
+	return pathUrls, report, nil
+}
+
+// importHeader normalizes the name of a column, "Long URL" and
+// "long_url" are the same
+func importHeader(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+}
+
+// backHalfPath turns the back-half of a link into a path. Exports
+// hold it on its own (abc), as a short link (bit.ly/abc) or as a
+// full URL (https://bit.ly/abc).
+func backHalfPath(v string) string {
+	if _, rest, ok := strings.Cut(v, "://"); ok {
+		v = rest
+	}
+	if i := strings.Index(v, "/"); i >= 0 && strings.Contains(v[:i], ".") {
+		// the part before the first slash is the short domain
+		v = v[i+1:]
+	}
+	v = strings.Trim(v, "/")
+	if v == "" {
+		return ""
+	}
+	return "/" + v
+}
@@ -0,0 +1,155 @@
+package urlshort
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// bitlyExport is laid out like the CSV bit.ly exports, saved from
+// Excel with a byte order mark
+const bitlyExport = "\ufeffDate Created,Title,Long URL,Bitlink,Custom Bitlinks,Clicks\r\n" +
+	"2024-01-05 10:00:00,Spring sale,https://shop.example.com/spring?utm_source=bitly,bit.ly/3xYzAbc,,\"1,204\"\r\n" +
+	"2024-01-06 09:30:00,\"Docs, start here\",https://docs.example.com/start,https://bit.ly/docs,bit.ly/docs,17\r\n" +
+	"2024-01-07 12:00:00,App,myapp://open/home,bit.ly/app,,3\r\n" +
+	"2024-01-08 08:00:00,Docs again,https://docs.example.com/v2,bit.ly/docs,,0\r\n" +
+	"\r\n" +
+	"2024-01-09 08:00:00,No link,https://example.com/nolink,,,\r\n" +
+	"2024-01-10 08:00:00,No destination,,bit.ly/empty,,\r\n" +
+	"2024-01-11 08:00:00,Odd clicks,https://example.com/odd,bit.ly/odd,,many\r\n" +
+	"2024-01-12 08:00:00,Negative,https://example.com/neg,bit.ly/neg,,-4\r\n" +
+	"2024-01-13 08:00:00,Nested,https://example.com/team,bit.ly/team/infra/,,\r\n"
+
+// rebrandlyExport is laid out like the CSV Rebrandly exports
+const rebrandlyExport = `Id,Title,Slashtag,Destination,Short URL,Domain,Clicks,Created At
+a1,Home,home,https://www.example.com/,rebrand.ly/home,rebrand.ly,42,2024-02-01T10:00:00Z
+a2,Gh,gh,https://github.com/nils,rebrand.ly/gh,rebrand.ly,7,2024-02-02T10:00:00Z
+a3,Dup,GH,https://gitlab.com,rebrand.ly/GH,rebrand.ly,1,2024-02-03T10:00:00Z
+a4,Dup,gh,https://gitlab.com,rebrand.ly/gh,rebrand.ly,1,2024-02-03T10:00:00Z
+a5,Mail,mail,mailto:team@example.com,rebrand.ly/mail,rebrand.ly,0,2024-02-04T10:00:00Z
+`
+
+func TestImportBitly(t *testing.T) {
+	pathUrls, report, err := ImportBitly(strings.NewReader(bitlyExport))
+	if err != nil {
+		t.Fatalf("ImportBitly: %v", err)
+	}
+	want := []pathUrl{
+		{Path: "/3xYzAbc", URL: "https://shop.example.com/spring?utm_source=bitly"},
+		{Path: "/docs", URL: "https://docs.example.com/start"},
+		{Path: "/team/infra", URL: "https://example.com/team"},
+	}
+	if !reflect.DeepEqual(pathUrls, want) {
+		t.Errorf("got %+v, want %+v", pathUrls, want)
+	}
+	if report.Imported != 3 {
+		t.Errorf("Imported: got %d", report.Imported)
+	}
+
+	wantSkipped := []SkippedRow{
+		{Line: 4, Path: "/app", Reason: `url "myapp://open/home" must use one of the schemes http, https`},
+		{Line: 5, Path: "/docs", Reason: "duplicate back-half, first seen on line 3"},
+		{Line: 7, Reason: "no back-half"},
+		{Line: 8, Path: "/empty", Reason: "no destination"},
+		{Line: 9, Path: "/odd", Reason: `clicks "many" is not a count`},
+		{Line: 10, Path: "/neg", Reason: `clicks "-4" is not a count`},
+	}
+	if !reflect.DeepEqual(report.Skipped, wantSkipped) {
+		t.Errorf("got skipped\n%+v\nwant\n%+v", report.Skipped, wantSkipped)
+	}
+
+	// the clicks become hits, links without clicks have none
+	wantStats := map[string]PathStats{"/3xYzAbc": {Hits: 1204}, "/docs": {Hits: 17}}
+	if !reflect.DeepEqual(report.Stats.Paths, wantStats) {
+		t.Errorf("got stats %+v, want %+v", report.Stats.Paths, wantStats)
+	}
+	stats := NewStats()
+	stats.Restore(report.Stats)
+	h, err := New(FromMap(nil), urlshorttest.Fallback(), WithStats(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(pathUrls); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/docs", "https://docs.example.com/start", http.StatusFound)
+	if got := stats.Snapshot().Paths["/docs"].Hits; got != 18 {
+		t.Errorf("hits after the import and a request: got %d, want 18", got)
+	}
+}
+
+func TestImportRebrandly(t *testing.T) {
+	pathUrls, report, err := ImportRebrandly(strings.NewReader(rebrandlyExport))
+	if err != nil {
+		t.Fatalf("ImportRebrandly: %v", err)
+	}
+	// paths keep their case, /GH and /gh are different links
+	want := []pathUrl{
+		{Path: "/home", URL: "https://www.example.com/"},
+		{Path: "/gh", URL: "https://github.com/nils"},
+		{Path: "/GH", URL: "https://gitlab.com"},
+	}
+	if !reflect.DeepEqual(pathUrls, want) {
+		t.Errorf("got %+v, want %+v", pathUrls, want)
+	}
+	wantSkipped := []SkippedRow{
+		{Line: 5, Path: "/gh", Reason: "duplicate back-half, first seen on line 3"},
+		{Line: 6, Path: "/mail", Reason: `url "mailto:team@example.com" must use one of the schemes http, https`},
+	}
+	if !reflect.DeepEqual(report.Skipped, wantSkipped) {
+		t.Errorf("got skipped\n%+v\nwant\n%+v", report.Skipped, wantSkipped)
+	}
+	if report.Stats.Paths["/home"].Hits != 42 || report.Stats.Paths["/GH"].Hits != 1 {
+		t.Errorf("got stats %+v", report.Stats.Paths)
+	}
+
+	// a Rebrandly export isn't a bit.ly one
+	_, _, err = ImportBitly(strings.NewReader(rebrandlyExport))
+	wantError(t, err, "invalid bit.ly export: need the long_url and keyword columns")
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", "", "invalid bit.ly export: the file is empty"},
+		{"no url column", "keyword,clicks\nabc,1\n", "need the long_url and keyword columns, got keyword,clicks"},
+		{"no path column", "long_url,clicks\nhttps://example.com,1\n", "need the long_url and keyword columns"},
+		{"broken quotes", "long_url,keyword\n\"https://example.com,abc\n", "invalid bit.ly export"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ImportBitly(strings.NewReader(tt.data))
+			wantError(t, err, tt.want)
+		})
+	}
+
+	// only a header is fine
+	pathUrls, report, err := ImportBitly(strings.NewReader("long_url,keyword\n"))
+	if err != nil || len(pathUrls) != 0 || report.Imported != 0 {
+		t.Errorf("a header only: got %v, %+v, %v", pathUrls, report, err)
+	}
+}
+
+func TestBackHalfPath(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"abc", "/abc"},
+		{"/abc", "/abc"},
+		{"bit.ly/abc", "/abc"},
+		{"https://bit.ly/abc", "/abc"},
+		{"http://go.example.com/team/infra/", "/team/infra"},
+		{"team/infra", "/team/infra"},
+		{"bit.ly/", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := backHalfPath(tt.in); got != tt.want {
+			t.Errorf("backHalfPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// maxIncludeDepth is how deep includes can be nested, a chain this
+// long is almost certainly a mistake
+const maxIncludeDepth = 8
+
+// YAMLFileHandler serves the YAML file at path like YAMLHandler
+// does, with one addition: a file in the vars format can include
+// others, so a large set of redirects can be split up by team,
+//
+//	include:
+//	  - teams/docs.yaml
+//	  - /etc/urlshort/shared.yaml
+//	redirects:
+//	  - path: /home
+//	    url: https://example.com
+//
+// Relative paths are relative to the directory of the including
+// file. Included files are read before the entries of the file that
+// includes them and can include files of their own, up to 8 levels
+// deep. A file that ends up including itself is an error, one that
+// is included twice by different files is only read once. Variables
+// only apply to the file they are defined in.
+//
+// A path in two files is a collision, decided by
+// WithCollisionPolicy. Files are named by their path as included,
+// joined with the directory of the file including them, both in
+// errors and for PreferSource. A path twice in the same file is
+// still a duplicate, see WithDuplicatePolicy.
+//
+// The handler can be reloaded like the one of NewFileHandler, which
+// reads includes for YAML files too.
+func YAMLFileHandler(path string, fallback http.Handler, opts ...Option) (*FileHandler, error) {
+	return NewFileHandler(path, FormatYAML, fallback, opts...)
+}
+
+// WithCollisionPolicy sets which entry is kept when files composed
+// with include: map the same path to different URLs, see
+// YAMLFileHandler. The default is CollisionError.
+func WithCollisionPolicy(p CollisionPolicy) Option {
+	return func(c *config) {
+		c.collisions = p
+	}
+}
+
+// includedFile is one file of a composed set and its entries
+type includedFile struct {
+	path     string
+	pathUrls []pathUrl
+}
+
+// parseYAMLFile reads the YAML file at path and everything it
+// includes, and merges the entries of all of them
+func parseYAMLFile(path string, cfg *config) ([]pathUrl, error) {
+	return parseYAMLFiles(path, cfg, nil)
+}
+
+// parseYAMLFiles is parseYAMLFile calling onRead, if it isn't nil,
+// with every file right before it is read, missing ones included,
+// so watchers know which files to look at
+func parseYAMLFiles(path string, cfg *config, onRead func(path string)) ([]pathUrl, error) {
+	c := includeCollector{cfg: cfg, seen: make(map[string]bool), onRead: onRead}
+	if err := c.collect(path, nil); err != nil {
+		return nil, err
+	}
+	if len(c.files) == 1 {
+		return c.files[0].pathUrls, nil
+	}
+
+	sources := make([]Source, len(c.files))
+	for i, f := range c.files {
+		pathUrls := f.pathUrls
+		sources[i] = &source{name: f.path, fn: func(*config) ([]pathUrl, error) { return pathUrls, nil }}
+	}
+	pathUrls, _, err := mergeEntries(MergeOptions{Collisions: cfg.collisions, repeats: true}, sources, cfg)
+	return pathUrls, err
+}
+
+// includeCollector reads a file and its includes, depth first, so
+// files come before the ones that include them
+type includeCollector struct {
+	cfg *config
+	// seen holds the absolute path of every file read so far
+	seen  map[string]bool
+	files []includedFile
+	// onRead is called with a file before it is read, see
+	// parseYAMLFiles
+	onRead func(path string)
+}
+
+// collect reads path, stack is the chain of files that included it
+func (c *includeCollector) collect(path string, stack []string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for i, p := range stack {
+		if p, _ := filepath.Abs(p); p == abs {
+			chain := append(append([]string(nil), stack[i:]...), path)
+			return fmt.Errorf("urlshort: include cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if len(stack) > maxIncludeDepth {
+		return fmt.Errorf("urlshort: %s: includes are nested more than %d levels deep", stack[0], maxIncludeDepth)
+	}
+	if c.seen[abs] {
+		return nil
+	}
+	c.seen[abs] = true
+
+	if c.onRead != nil {
+		c.onRead(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if len(stack) > 0 {
+			return fmt.Errorf("urlshort: %s: include: %w", stack[len(stack)-1], err)
+		}
+		return err
+	}
+	pathUrls, includes, err := c.parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	stack = append(stack, path)
+	for _, inc := range includes {
+		if inc == "" {
+			return fmt.Errorf("urlshort: %s: empty include", path)
+		}
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+		if err := c.collect(inc, stack); err != nil {
+			return err
+		}
+	}
+	c.files = append(c.files, includedFile{path: path, pathUrls: pathUrls})
+	return nil
+}
+
+// parse parses one file in the mode the options ask for, like
+// config.parseYAML but keeping its includes
+func (c *includeCollector) parse(data []byte) ([]pathUrl, []string, error) {
+	if c.cfg.strict {
+		pathUrls, includes, err := unmarshalYAMLFile(data, yaml.UnmarshalStrict)
+		if err != nil {
+			return nil, nil, strictError(data, err)
+		}
+		if err := checkStrict(pathUrls); err != nil {
+			return nil, nil, err
+		}
+		return pathUrls, includes, nil
+	}
+
+	pathUrls, includes, err := unmarshalYAMLFile(data, yaml.Unmarshal)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := prepareEntries(pathUrls); err != nil {
+		return nil, nil, err
+	}
+	return pathUrls, includes, nil
+}
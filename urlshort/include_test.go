@@ -0,0 +1,87 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestYAMLFileHandlerIncludes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "teams"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	shared := writeFile(t, t.TempDir(), "shared.yaml", "- path: /shared\n  url: https://shared.example.com\n")
+	// relative to the including file, so common.yaml is next to docs.yaml
+	writeFile(t, dir, "teams/docs.yaml", "include:\n  - common.yaml\nvars:\n  base: https://docs.example.com\nredirects:\n  - path: /docs\n    url: ${base}/start\n")
+	writeFile(t, dir, "teams/common.yaml", "- path: /common\n  url: https://common.example.com\n")
+	// included twice, read once
+	writeFile(t, dir, "teams/ops.yaml", "include:\n  - common.yaml\nredirects:\n  - path: /ops\n    url: https://ops.example.com\n")
+	main := writeFile(t, dir, "main.yaml", fmt.Sprintf("include:\n  - teams/docs.yaml\n  - teams/ops.yaml\n  - %s\nredirects:\n  - path: /home\n    url: https://example.com\n", shared))
+
+	fh, err := YAMLFileHandler(main, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLFileHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, fh, []urlshorttest.Case{
+		{Path: "/home", Location: "https://example.com", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com/start", Code: http.StatusFound},
+		{Path: "/common", Location: "https://common.example.com", Code: http.StatusFound},
+		{Path: "/ops", Location: "https://ops.example.com", Code: http.StatusFound},
+		{Path: "/shared", Location: "https://shared.example.com", Code: http.StatusFound},
+	})
+	if fh.Len() != 5 {
+		t.Errorf("got %d mappings, want 5", fh.Len())
+	}
+
+	// a reload reads the included files again
+	writeFile(t, dir, "teams/common.yaml", "- path: /common\n  url: https://common.example.com/v2\n")
+	if err := fh.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, fh, "/common", "https://common.example.com/v2", http.StatusFound)
+}
+
+func TestYAMLFileHandlerIncludeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", "include: [b.yaml]\nredirects: []\n")
+	writeFile(t, dir, "b.yaml", "include: [a.yaml]\nredirects: []\n")
+	writeFile(t, dir, "missing.yaml", "include: [nope.yaml]\nredirects: []\n")
+	writeFile(t, dir, "empty.yaml", "include: ['']\nredirects: []\n")
+	writeFile(t, dir, "vars.yaml", "include: [child.yaml]\nvars:\n  base: https://example.com\nredirects: []\n")
+	writeFile(t, dir, "child.yaml", "redirects:\n  - path: /x\n    url: ${base}/x\n")
+	writeFile(t, dir, "strict.yaml", "include: [typo.yaml]\nredirects: []\n")
+	writeFile(t, dir, "typo.yaml", "- path: /x\n  ulr: https://example.com\n")
+	for i := 0; i < 10; i++ {
+		writeFile(t, dir, fmt.Sprintf("deep%d.yaml", i), fmt.Sprintf("include: [deep%d.yaml]\nredirects: []\n", i+1))
+	}
+	writeFile(t, dir, "deep10.yaml", "[]\n")
+
+	tests := []struct {
+		name, file string
+		opts       []Option
+		want       string
+	}{
+		{"cycle", "a.yaml", nil, "urlshort: include cycle: " + filepath.Join(dir, "a.yaml") + " -> " + filepath.Join(dir, "b.yaml") + " -> " + filepath.Join(dir, "a.yaml")},
+		{"missing", "missing.yaml", nil, "urlshort: " + filepath.Join(dir, "missing.yaml") + ": include: open " + filepath.Join(dir, "nope.yaml")},
+		{"empty", "empty.yaml", nil, "urlshort: " + filepath.Join(dir, "empty.yaml") + ": empty include"},
+		// variables stay in their file
+		{"vars", "vars.yaml", nil, "undefined variable ${base}"},
+		{"strict", "strict.yaml", []Option{WithStrictParsing()}, filepath.Join(dir, "typo.yaml") + ": urlshort: entry 0: "},
+		{"too deep", "deep0.yaml", nil, "includes are nested more than 8 levels deep"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLFileHandler(filepath.Join(dir, tt.file), nil, tt.opts...)
+			wantError(t, err, tt.want)
+		})
+	}
+
+	// without a file there is nothing to resolve includes against
+	_, err := YAMLHandler([]byte("include: [a.yaml]\nredirects: []\n"), nil)
+	wantError(t, err, "include only works in files, use YAMLFileHandler")
+}
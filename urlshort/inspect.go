@@ -0,0 +1,117 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Inspection is what a handler would do with a request for a path,
+// see InspectHandler
+type Inspection struct {
+	Path    string `json:"path"`
+	Matched bool   `json:"matched"`
+	// Entry is the configured path that matched
+	Entry string `json:"entry,omitempty"`
+	// Destination has parameters and UTM substituted. Entries with
+	// several destinations report the one requests without cookies,
+	// country, language or device class would get.
+	Destination string `json:"destination,omitempty"`
+	// Status is zero for rewrite and proxy entries, which don't
+	// redirect
+	Status int    `json:"status,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+	// Inactive is set when the entry is outside of its active window
+	// and Destination is its inactive url
+	Inactive bool `json:"inactive,omitempty"`
+	// Disabled is set for disabled entries, which act as missing
+	Disabled    bool       `json:"disabled,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+}
+
+// Inspector finds out what a request would get without serving it.
+// Handler implements it, and with it the file, remote, consul and
+// etcd backed handlers.
+type Inspector interface {
+	Inspect(r *http.Request) Inspection
+}
+
+// InspectHandler returns an http.Handler for finding out where a
+// short link goes without following it:
+//
+//	GET /api/inspect?path=/gh  what a request for /gh would get,
+//	                           host= for host entries
+//
+// Inspecting doesn't count as a hit and doesn't call the hooks. It
+// takes the same options as AdminHandler, use WithAdminToken to keep
+// it private.
+func InspectHandler(target Inspector, opts ...AdminOption) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/inspect", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		path := query.Get("path")
+		if !strings.HasPrefix(path, "/") {
+			writeError(w, http.StatusBadRequest, "path parameter must start with /")
+			return
+		}
+		u, err := url.ParseRequestURI(path)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid path: "+err.Error())
+			return
+		}
+		host := query.Get("host")
+		if host == "" {
+			host = r.Host
+		}
+		// a bare request, so entries with several destinations
+		// report their default
+		probe := (&http.Request{Method: http.MethodGet, URL: u, Host: host, Header: http.Header{}}).WithContext(r.Context())
+		writeJSON(w, http.StatusOK, target.Inspect(probe))
+	})
+	return protect(mux, opts)
+}
+
+// Inspect reports what ServeHTTP would do with r
+func (h *Handler) Inspect(r *http.Request) Inspection {
+	in := Inspection{Path: r.URL.Path}
+	now := h.cfg.now()
+	path, ok := lookupPath(r, h.cfg)
+	if !ok {
+		return in
+	}
+	m, ok := h.routes.Load().lookupHost(r.Host, path)
+	if !ok {
+		return in
+	}
+	in.Entry = m.entry.Path
+	in.Expires = timeOrNil(m.entry.expiresAt)
+	in.ActiveFrom = timeOrNil(m.entry.activeFrom)
+	in.ActiveUntil = timeOrNil(m.entry.activeUntil)
+	if m.entry.Disabled {
+		in.Disabled = true
+		return in
+	}
+	m, ok = m.current(now)
+	if !ok {
+		return in
+	}
+	in.Matched = true
+	in.Inactive = !m.entry.active(now)
+	in.Mode = m.entry.Mode
+	in.Destination = addQuery(m.dest, r, m.entry, h.cfg)
+	if m.entry.Mode != modeRewrite && m.entry.Mode != modeProxy {
+		in.Status = responseStatus(r, m.entry, h.cfg)
+	}
+	return in
+}
+
+// timeOrNil returns a pointer to t, nil if it is the zero time
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,99 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const inspectEntries = `
+- path: /gh
+  url: https://github.com
+- path: /moved
+  url: https://example.com/new
+  code: 301
+- path: /old
+  url: https://example.com/old
+  expires: 2024-12-01T00:00:00Z
+- path: /soon
+  url: https://example.com/launch
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/teaser
+- path: /about
+  url: /pages/about-us.html
+  mode: rewrite
+`
+
+func TestInspect(t *testing.T) {
+	clock := newFakeClock()
+	hits := 0
+	h, err := New(FromYAML([]byte(inspectEntries)), urlshorttest.Fallback(), WithClock(clock.Now), WithQueryForwarding(),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) { hits++ }))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	soon := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	old := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		target string
+		want   Inspection
+	}{
+		{"/gh?q=1", Inspection{Path: "/gh", Matched: true, Entry: "/gh", Destination: "https://github.com?q=1", Status: http.StatusFound}},
+		{"/moved", Inspection{Path: "/moved", Matched: true, Entry: "/moved", Destination: "https://example.com/new", Status: http.StatusMovedPermanently}},
+		// expired entries are reported without matching
+		{"/old", Inspection{Path: "/old", Entry: "/old", Expires: &old}},
+		{"/soon", Inspection{Path: "/soon", Matched: true, Entry: "/soon", Destination: "https://example.com/teaser", Status: http.StatusFound, Mode: modeRedirect,
+			Inactive: true, ActiveFrom: &soon}},
+		{"/about", Inspection{Path: "/about", Matched: true, Entry: "/about", Destination: "/pages/about-us.html", Mode: modeRewrite}},
+		{"/missing", Inspection{Path: "/missing"}},
+	}
+	for _, tt := range tests {
+		if got := h.Inspect(httptest.NewRequest(http.MethodGet, tt.target, nil)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Inspect(%s):\ngot  %+v\nwant %+v", tt.target, got, tt.want)
+		}
+	}
+	// inspecting isn't a visit
+	if hits != 0 {
+		t.Errorf("the hook was called %d times", hits)
+	}
+
+	clock.Advance(48 * time.Hour)
+	if got := h.Inspect(httptest.NewRequest(http.MethodGet, "/soon", nil)); got.Inactive || got.Destination != "https://example.com/launch" {
+		t.Errorf("after the launch: got %+v", got)
+	}
+}
+
+func TestInspectHandler(t *testing.T) {
+	h, err := New(FromYAML([]byte(inspectEntries)), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ih := InspectHandler(h, WithAdminToken("s3cret"))
+	auth := http.Header{"Authorization": {"Bearer s3cret"}}
+
+	if res := get(ih, "/api/inspect?path=/gh"); res.Code != http.StatusUnauthorized {
+		t.Errorf("without the token: got %d", res.Code)
+	}
+	res := requestFrom(ih, "/api/inspect?path=/gh%3Fq%3D1", "198.51.100.1:5000", auth)
+	var in Inspection
+	if err := json.Unmarshal(res.Body.Bytes(), &in); err != nil {
+		t.Fatalf("decoding %s: %v", res.Body.String(), err)
+	}
+	if want := (Inspection{Path: "/gh", Matched: true, Entry: "/gh", Destination: "https://github.com", Status: http.StatusFound}); in != want {
+		t.Errorf("got %+v, want %+v", in, want)
+	}
+	if got := requestFrom(ih, "/api/inspect?path=/missing", "198.51.100.1:5000", auth).Body.String(); got != `{"path":"/missing","matched":false}`+"\n" {
+		t.Errorf("a miss: got %s", got)
+	}
+
+	for _, target := range []string{"/api/inspect", "/api/inspect?path=gh", "/api/inspect?path=/%zz"} {
+		if res := requestFrom(ih, target, "198.51.100.1:5000", auth); res.Code != http.StatusBadRequest {
+			t.Errorf("GET %s: got %d, want 400", target, res.Code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+)
+
+// modeInterstitial serves a page linking to the destination instead
+// of a redirect, for clients that prefetch Location targets
+const modeInterstitial = "interstitial"
+
+// InterstitialData is what the interstitial template is executed with
+type InterstitialData struct {
+	// Path is the short link that was requested
+	Path string
+	// Destination is where the page sends the visitor
+	Destination string
+	// Delay is how many seconds the page waits before going there
+	Delay int
+}
+
+// defaultInterstitialTemplate is the page interstitial entries serve
+// unless WithInterstitialTemplate replaces it
+var defaultInterstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Delay}};url={{.Destination}}">
+<title>Redirecting</title>
+</head>
+<body>
+<p><a href="{{.Destination}}">Continue to {{.Destination}}</a></p>
+</body>
+</html>
+`))
+
+// WithInterstitialTemplate replaces the page served by entries with
+// mode interstitial. The template is executed with an
+// InterstitialData, html/template takes care of escaping the
+// destination.
+func WithInterstitialTemplate(t *template.Template) Option {
+	return func(c *config) {
+		c.interstitial = t
+	}
+}
+
+// checkDelay makes sure the delay of pu is usable
+func checkDelay(pu *pathUrl) error {
+	if pu.Delay < 0 {
+		return fmt.Errorf("urlshort: %s: delay %d is negative", pu.Path, pu.Delay)
+	}
+	return nil
+}
+
+// serveInterstitial writes the interstitial page for a redirect of
+// path to dest
+func serveInterstitial(w http.ResponseWriter, pu pathUrl, dest string, cfg *config) {
+	var page bytes.Buffer
+	data := InterstitialData{Path: pu.Path, Destination: dest, Delay: pu.Delay}
+	if err := cfg.interstitial.Execute(&page, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,83 @@
+package urlshort
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const interstitialEntries = `
+- path: /wait
+  url: https://example.com/?a=1&b="2"
+  mode: interstitial
+  delay: 3
+- path: /now
+  url: https://example.com/now
+  mode: interstitial
+- path: /gh
+  url: https://github.com
+`
+
+func TestInterstitial(t *testing.T) {
+	h, err := YAMLHandler([]byte(interstitialEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		// the destination is escaped
+		{"/wait", []string{`content="3;url=https://example.com/?a=1&amp;b=&#34;2&#34;"`, `<a href="https://example.com/?a=1&amp;b=%222%22">`}},
+		{"/now", []string{`content="0;url=https://example.com/now"`, "Continue to https://example.com/now"}},
+	}
+	for _, tt := range tests {
+		res := get(h, tt.path)
+		if res.Code != http.StatusOK || res.Header().Get("Location") != "" {
+			t.Errorf("GET %s: got %d to %q, want a page", tt.path, res.Code, res.Header().Get("Location"))
+		}
+		if got := res.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("GET %s: got Content-Type %q", tt.path, got)
+		}
+		if got := res.Header().Get("Cache-Control"); got != "no-cache, no-store, must-revalidate" {
+			t.Errorf("GET %s: got Cache-Control %q", tt.path, got)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(res.Body.String(), s) {
+				t.Errorf("GET %s: the page has no %q:\n%s", tt.path, s, res.Body.String())
+			}
+		}
+	}
+	// other entries still redirect
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+}
+
+func TestInterstitialTemplate(t *testing.T) {
+	custom := template.Must(template.New("custom").Parse(`{{.Path}} -> {{.Destination}} in {{.Delay}}`))
+	h, err := YAMLHandler([]byte(interstitialEntries), urlshorttest.Fallback(), WithInterstitialTemplate(custom))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	if got := get(h, "/now").Body.String(); got != "/now -> https://example.com/now in 0" {
+		t.Errorf("got %q", got)
+	}
+
+	// a template that fails gives a 500, not half a page
+	broken := template.Must(template.New("broken").Parse(`start {{.Missing}}`))
+	h, err = YAMLHandler([]byte(interstitialEntries), urlshorttest.Fallback(), WithInterstitialTemplate(broken))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	res := get(h, "/now")
+	if res.Code != http.StatusInternalServerError || strings.Contains(res.Body.String(), "start") {
+		t.Errorf("got %d: %q", res.Code, res.Body.String())
+	}
+}
+
+func TestInterstitialErrors(t *testing.T) {
+	_, err := YAMLHandler([]byte("- path: /wait\n  url: https://example.com\n  mode: interstitial\n  delay: -1\n"), nil)
+	wantError(t, err, "urlshort: /wait: delay -1 is negative")
+}
@@ -0,0 +1,125 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestJSONLinesHandler(t *testing.T) {
+	data := "# docs team\n" +
+		`{"path": "/docs", "url": "https://docs.example.com"}` + "\n" +
+		"\n" +
+		"   \t\n" +
+		"  # indented comment\n" +
+		`{"path": "/gh", "url": "https://github.com", "code": 301}` + "\r\n" +
+		`{"path": "/tmp", "url": "https://example.com/tmp", "headers": {"X-Team": "infra"}}`
+	h, err := JSONLinesHandler(strings.NewReader(data), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("JSONLinesHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		// the last line doesn't need a newline
+		{Path: "/tmp", Location: "https://example.com/tmp", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+	if got := get(h, "/tmp").Header().Get("X-Team"); got != "infra" {
+		t.Errorf("X-Team: got %q", got)
+	}
+
+	// an empty file is just no entries
+	h, err = JSONLinesHandler(strings.NewReader("# nothing yet\n\n"), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("JSONLinesHandler: %v", err)
+	}
+	urlshorttest.AssertFallback(t, h, "/docs")
+}
+
+func TestJSONLinesErrors(t *testing.T) {
+	good := `{"path": "/a", "url": "https://a.example.com"}` + "\n"
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		// comments and blank lines count for the line, not the entry
+		{"malformed", "# header\n" + good + "\n" + `{"path": "/b", "url": }` + "\n", "entry 1 (line 4): invalid JSON"},
+		{"not an object", good + `["/b", "https://b.example.com"]` + "\n", "entry 1 (line 2): invalid JSON"},
+		{"two objects on a line", good + good[:len(good)-1] + good, "entry 1 (line 2): invalid JSON"},
+		{"missing url", good + `{"path": "/b"}` + "\n", "entry 1"},
+		{"bad code", `{"path": "/b", "url": "https://b.example.com", "code": 200}` + "\n", "entry 0"},
+		{"duplicate", good + good, "duplicate path: /a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := JSONLinesHandler(strings.NewReader(tt.data), nil)
+			wantError(t, err, tt.want)
+		})
+	}
+
+	// errors of the reader and of fn come back as they are
+	broken := errors.New("disk on fire")
+	_, err := JSONLinesHandler(io.MultiReader(strings.NewReader(good), iotest.ErrReader(broken)), nil)
+	if !errors.Is(err, broken) {
+		t.Errorf("a failing reader: got %v", err)
+	}
+	stop := errors.New("stop")
+	if err := ParseJSONLines(strings.NewReader(good+good), func(Entry) error { return stop }); err != stop {
+		t.Errorf("an fn error: got %v", err)
+	}
+}
+
+func TestJSONLinesLarge(t *testing.T) {
+	const n = 100_000
+	// lines far longer than the 64KiB a default bufio.Scanner takes
+	long := strings.Repeat("x", 256<<10)
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < n; i++ {
+			if i%1000 == 0 {
+				fmt.Fprintf(pw, "# batch %d\n\n", i/1000)
+			}
+			if i == n/2 || i == n-1 {
+				fmt.Fprintf(pw, `{"path": "/l/%d", "url": "https://example.com/%d?pad=%s"}`+"\n", i, i, long)
+				continue
+			}
+			fmt.Fprintf(pw, `{"path": "/l/%d", "url": "https://example.com/%d"}`+"\n", i, i)
+		}
+		pw.Close()
+	}()
+	h, err := JSONLinesHandler(pr, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("JSONLinesHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/l/0", Location: "https://example.com/0", Code: http.StatusFound},
+		{Path: "/l/49999", Location: "https://example.com/49999", Code: http.StatusFound},
+		{Path: fmt.Sprintf("/l/%d", n/2), Location: fmt.Sprintf("https://example.com/%d?pad=%s", n/2, long), Code: http.StatusFound},
+		{Path: fmt.Sprintf("/l/%d", n-1), Location: fmt.Sprintf("https://example.com/%d?pad=%s", n-1, long), Code: http.StatusFound},
+		{Path: fmt.Sprintf("/l/%d", n)},
+	})
+
+	// a broken line deep in the file still gets its number, 100 comment
+	// and 100 blank lines before it
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if i%1000 == 0 {
+			fmt.Fprintf(&b, "# batch %d\n\n", i/1000)
+		}
+		if i == n-1 {
+			b.WriteString("{\"path\": \"/l\"\n")
+			continue
+		}
+		fmt.Fprintf(&b, `{"path": "/l/%d", "url": "https://example.com/%d"}`+"\n", i, i)
+	}
+	_, err = JSONLinesHandler(strings.NewReader(b.String()), nil)
+	wantError(t, err, fmt.Sprintf("entry %d (line %d): invalid JSON", n-1, n+200))
+}
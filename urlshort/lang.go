@@ -0,0 +1,120 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// LanguageFromContext returns the language a lang entry picked for
+// the request, like "fr" or "pt-br", or "" if it went to the default
+// destination. The request passed to a RedirectHook has it, for
+// analytics.
+func LanguageFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey).(string)
+	return lang
+}
+
+// withLanguage attaches the picked language to the request context
+func withLanguage(r *http.Request, lang string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), languageKey, lang))
+}
+
+// checkLang validates the lang map of pu. Language tags are made
+// lower case, and the default destination becomes the plain url so
+// everything that doesn't know about languages sees that one.
+func checkLang(pu *pathUrl) error {
+	if len(pu.Lang) == 0 {
+		return nil
+	}
+	if pu.URL != "" && pu.URL != pu.Lang[mapDefault] || len(pu.URLs) > 0 || len(pu.Geo) > 0 {
+		return fmt.Errorf("urlshort: %s: use either lang or url, urls and geo, not both", pu.Path)
+	}
+	langs := make(map[string]string, len(pu.Lang))
+	for tag, dest := range pu.Lang {
+		if dest == "" {
+			return fmt.Errorf("urlshort: %s: lang destination for %s is missing a url", pu.Path, tag)
+		}
+		if tag != mapDefault && !isLanguageTag(tag) {
+			return fmt.Errorf("urlshort: %s: %q is not a language tag like fr or pt-BR", pu.Path, tag)
+		}
+		langs[strings.ToLower(tag)] = dest
+	}
+	if langs[mapDefault] == "" {
+		return fmt.Errorf("urlshort: %s: lang needs a default destination", pu.Path)
+	}
+	pu.Lang = langs
+	pu.URL = langs[mapDefault]
+	return nil
+}
+
+// isLanguageTag reports whether s looks like a BCP 47 tag: subtags
+// of one to eight letters or digits separated by dashes, the first
+// of them letters only
+func isLanguageTag(s string) bool {
+	for i, sub := range strings.Split(s, "-") {
+		if len(sub) == 0 || len(sub) > 8 {
+			return false
+		}
+		for _, c := range []byte(sub) {
+			letter := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+			digit := c >= '0' && c <= '9'
+			if !letter && (i == 0 || !digit) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// langDestination picks the destination of a lang entry for the
+// Accept-Language of r and returns the language it picked, "" for
+// the default. The languages are tried in order of preference, each
+// first as it is and then by its primary subtag, so fr-CA finds fr.
+func langDestination(r *http.Request, pu pathUrl) (string, string) {
+	for _, tag := range acceptedLanguages(r.Header.Values("Accept-Language")) {
+		if dest, ok := pu.Lang[tag]; ok {
+			return dest, tag
+		}
+		if primary, _, ok := strings.Cut(tag, "-"); ok {
+			if dest, ok := pu.Lang[primary]; ok {
+				return dest, primary
+			}
+		}
+	}
+	return pu.Lang[mapDefault], ""
+}
+
+// acceptedLanguages returns the lower case tags of Accept-Language
+// headers, most preferred first. Tags with q=0 are not acceptable and
+// left out, so is *, which is what the default is for.
+func acceptedLanguages(headers []string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var accepted []weighted
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			tag, params, _ := strings.Cut(part, ";")
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || tag == "*" || !isLanguageTag(tag) {
+				continue
+			}
+			if q := qValue(params); q > 0 {
+				accepted = append(accepted, weighted{tag, q})
+			}
+		}
+	}
+	// equal weights keep the order of the header
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].q > accepted[j].q
+	})
+	tags := make([]string, len(accepted))
+	for i, a := range accepted {
+		tags[i] = a.tag
+	}
+	return tags
+}
@@ -0,0 +1,99 @@
+package urlshort
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const langEntries = `
+- path: /help
+  lang:
+    default: https://example.com/help
+    fr: https://example.com/fr/aide
+    DE: https://example.com/de/hilfe
+    pt-BR: https://example.com/br/ajuda
+`
+
+func TestLang(t *testing.T) {
+	var picked []string
+	h, err := YAMLHandler([]byte(langEntries), urlshorttest.Fallback(), WithRedirectHook(func(r *http.Request, path, dest string, status int) {
+		picked = append(picked, LanguageFromContext(r.Context()))
+	}))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		name   string
+		accept []string
+		want   string
+		lang   string
+	}{
+		{"no Accept-Language", nil, "https://example.com/help", ""},
+		{"exact", []string{"fr"}, "https://example.com/fr/aide", "fr"},
+		{"case", []string{"De-de"}, "https://example.com/de/hilfe", "de"},
+		{"primary subtag", []string{"fr-CA"}, "https://example.com/fr/aide", "fr"},
+		{"region", []string{"pt-BR"}, "https://example.com/br/ajuda", "pt-br"},
+		{"other region", []string{"pt-PT"}, "https://example.com/help", ""},
+		{"q-values", []string{"fr;q=0.5, de;q=0.8"}, "https://example.com/de/hilfe", "de"},
+		{"order breaks ties", []string{"de, fr"}, "https://example.com/de/hilfe", "de"},
+		{"an unknown one first", []string{"es, fr;q=0.9"}, "https://example.com/fr/aide", "fr"},
+		{"refused", []string{"fr;q=0, *"}, "https://example.com/help", ""},
+		{"several headers", []string{"es", "de;q=0.5"}, "https://example.com/de/hilfe", "de"},
+		{"not a tag", []string{"f_r, 12"}, "https://example.com/help", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			picked = nil
+			res := requestFrom(h, "/help", "198.51.100.1:5000", http.Header{"Accept-Language": tt.accept})
+			if got := res.Header().Get("Location"); res.Code != http.StatusFound || got != tt.want {
+				t.Errorf("got %d to %q, want 302 to %q", res.Code, got, tt.want)
+			}
+			if !slices.Equal(picked, []string{tt.lang}) {
+				t.Errorf("the hook saw languages %q, want %q", picked, tt.lang)
+			}
+		})
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    []string
+	}{
+		{nil, []string{}},
+		{[]string{"en-US,en;q=0.9,de;q=0.8"}, []string{"en-us", "en", "de"}},
+		{[]string{"de;q=0.1", "fr"}, []string{"fr", "de"}},
+		{[]string{" fr ; q=0.5 , , *;q=0.9"}, []string{"fr"}},
+		{[]string{"zh-Hant-TW, x;q=0"}, []string{"zh-hant-tw"}},
+	}
+	for _, tt := range tests {
+		if got := acceptedLanguages(tt.headers); !slices.Equal(got, tt.want) {
+			t.Errorf("acceptedLanguages(%q) = %q, want %q", tt.headers, got, tt.want)
+		}
+	}
+}
+
+func TestLangErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"no default", "- path: /help\n  lang:\n    fr: https://example.com/fr\n", "urlshort: /help: lang needs a default destination"},
+		{"url too", "- path: /help\n  url: https://example.com\n  lang:\n    default: https://example.com/help\n",
+			"urlshort: /help: use either lang or url, urls and geo, not both"},
+		{"not a tag", "- path: /help\n  lang:\n    default: https://example.com\n    french: https://example.com/fr\n    f_r: https://example.com/fr\n",
+			`urlshort: /help: "f_r" is not a language tag like fr or pt-BR`},
+		{"empty destination", "- path: /help\n  lang:\n    default: https://example.com\n    fr: ''\n", "urlshort: /help: lang destination for fr is missing a url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), nil)
+			wantError(t, err, tt.want)
+		})
+	}
+	if !isLanguageTag("french") || isLanguageTag("francais-123456789") || isLanguageTag("1fr") || !isLanguageTag("es-419") {
+		t.Error("isLanguageTag misjudges a tag")
+	}
+}
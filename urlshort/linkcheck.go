@@ -0,0 +1,379 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaults of LinkCheckOptions
+const (
+	defaultLinkCheckInterval    = time.Hour
+	defaultLinkCheckConcurrency = 4
+	defaultLinkCheckTimeout     = 10 * time.Second
+)
+
+// LinkCheckOptions changes how a LinkChecker checks destinations.
+// The zero value is usable, Interval, Concurrency and Timeout have
+// defaults.
+type LinkCheckOptions struct {
+	// Interval is the wait between two rounds of checks, the default
+	// is an hour
+	Interval time.Duration
+	// Concurrency is how many destinations are checked at once, the
+	// default is 4
+	Concurrency int
+	// HostInterval is the least time between two requests to the
+	// same host, so a round doesn't hammer a site many entries point
+	// at. Zero doesn't throttle.
+	HostInterval time.Duration
+	// Timeout limits a single check when Client isn't set, the
+	// default is 10s
+	Timeout time.Duration
+	// Client sends the requests, e.g. for custom TLS settings or a
+	// transport in tests
+	Client *http.Client
+	// DisableAfter disables an entry after that many failed checks
+	// in a row, zero never does. The routes must be a store that can
+	// disable entries, like *MutableStore.
+	DisableAfter int
+	// Hooks are the options of the hook system that is told about the
+	// entries DisableAfter disabled, WithDisableHook and e.g.
+	// WithAsyncHooks or WithRecovery
+	Hooks []Option
+}
+
+// LinkHealth is the result of the last check of one entry
+type LinkHealth struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	// Status is the status of the last response, zero if the request
+	// failed
+	Status int `json:"status,omitempty"`
+	// Error says why the last check failed, empty if it succeeded
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency_ns"`
+	Checked time.Time     `json:"checked"`
+	// Failures counts the failed checks in a row
+	Failures int `json:"failures"`
+	// Disabled is set once DisableAfter disabled the entry
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// OK reports whether the last check succeeded
+func (lh LinkHealth) OK() bool {
+	return lh.Error == ""
+}
+
+// disabler is implemented by stores whose entries can be disabled
+type disabler interface {
+	Disable(path string) bool
+}
+
+// LinkChecker checks the destinations of routes in the background
+// for dead links. Every round sends a HEAD request to each absolute
+// http or https destination, followed by a GET if the server doesn't
+// allow HEAD, and counts anything but a 2xx or 3xx answer after the
+// redirects as a failure. Destinations with placeholders, like
+// parameters or templates, are skipped, and so are relative ones.
+//
+// Entries that recover are not enabled again, that is left to
+// whoever looks at the report.
+type LinkChecker struct {
+	routes RouteLister
+	opts   LinkCheckOptions
+	client *http.Client
+	// hooks runs the disable hook
+	hooks *config
+
+	mu      sync.Mutex
+	results map[string]LinkHealth
+
+	// hostMu guards nextRequest, the time each host may get the next
+	// request
+	hostMu      sync.Mutex
+	nextRequest map[string]time.Time
+
+	// round serializes the rounds of run and CheckNow
+	round  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewLinkChecker starts a LinkChecker for the mappings of routes,
+// the first round right away. Close it on shutdown.
+func NewLinkChecker(routes RouteLister, opts LinkCheckOptions) (*LinkChecker, error) {
+	if opts.Interval < 0 || opts.Concurrency < 0 || opts.HostInterval < 0 || opts.Timeout < 0 || opts.DisableAfter < 0 {
+		return nil, errors.New("urlshort: link check options can't be negative")
+	}
+	if _, ok := routes.(disabler); opts.DisableAfter > 0 && !ok {
+		return nil, fmt.Errorf("urlshort: DisableAfter needs a store that can disable entries, %T can't", routes)
+	}
+	if opts.Interval == 0 {
+		opts.Interval = defaultLinkCheckInterval
+	}
+	if opts.Concurrency == 0 {
+		opts.Concurrency = defaultLinkCheckConcurrency
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultLinkCheckTimeout
+	}
+	hooks, err := newConfig(opts.Hooks)
+	if err != nil {
+		return nil, err
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lc := &LinkChecker{
+		routes:      routes,
+		opts:        opts,
+		client:      client,
+		hooks:       hooks,
+		results:     make(map[string]LinkHealth),
+		nextRequest: make(map[string]time.Time),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go lc.run(ctx)
+	return lc, nil
+}
+
+// Close stops the checks and returns once a running round gave up
+// and the queued hook calls ran
+func (lc *LinkChecker) Close() error {
+	lc.cancel()
+	<-lc.done
+	if lc.hooks.hookQueue != nil {
+		lc.hooks.hookQueue.close()
+	}
+	return nil
+}
+
+// CheckNow runs a round right away and returns once it is done, or
+// ctx is. Rounds never overlap, it waits for a running one first.
+func (lc *LinkChecker) CheckNow(ctx context.Context) {
+	lc.check(ctx)
+}
+
+// Report returns the result of the last check of every checked
+// entry, sorted by path
+func (lc *LinkChecker) Report() []LinkHealth {
+	lc.mu.Lock()
+	report := make([]LinkHealth, 0, len(lc.results))
+	for _, lh := range lc.results {
+		report = append(report, lh)
+	}
+	lc.mu.Unlock()
+	sort.Slice(report, func(i, j int) bool { return report[i].Path < report[j].Path })
+	return report
+}
+
+// LinkHealthHandler serves the Report of lc as JSON, meant to be
+// mounted at GET /api/health/links next to AdminHandler:
+//
+//	[{"path": "/gh", "url": "https://...", "status": 200, ...}]
+//
+// With ?failing=true only entries whose last check failed are
+// listed. It takes the same options as AdminHandler.
+func LinkHealthHandler(lc *LinkChecker, opts ...AdminOption) http.Handler {
+	return protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := lc.Report()
+		if r.URL.Query().Get("failing") == "true" {
+			failing := report[:0]
+			for _, lh := range report {
+				if !lh.OK() {
+					failing = append(failing, lh)
+				}
+			}
+			report = failing
+		}
+		writeJSON(w, http.StatusOK, report)
+	}), opts)
+}
+
+// run checks every interval until ctx is canceled
+func (lc *LinkChecker) run(ctx context.Context) {
+	defer close(lc.done)
+	ticker := time.NewTicker(lc.opts.Interval)
+	defer ticker.Stop()
+	for {
+		lc.check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check runs one round over the current mappings
+func (lc *LinkChecker) check(ctx context.Context) {
+	lc.round.Lock()
+	defer lc.round.Unlock()
+
+	routes := lc.routes.Routes()
+	lc.mu.Lock()
+	for path, lh := range lc.results {
+		// forget removed entries and those with a new destination
+		if dest, ok := routes[path]; !ok || dest != lh.URL {
+			delete(lc.results, path)
+		}
+	}
+	lc.mu.Unlock()
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < lc.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				lc.checkEntry(ctx, path, routes[path])
+			}
+		}()
+	}
+	for path, dest := range routes {
+		if !checkable(dest) {
+			continue
+		}
+		select {
+		case paths <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(paths)
+	wg.Wait()
+}
+
+// checkEntry checks dest and records the result for path
+func (lc *LinkChecker) checkEntry(ctx context.Context, path, dest string) {
+	if !lc.waitForHost(ctx, dest) {
+		return
+	}
+	start := time.Now()
+	status, _, err := probe(ctx, lc.client, dest)
+	if ctx.Err() != nil {
+		// shutting down, not the destination's fault
+		return
+	}
+	lh := LinkHealth{Path: path, URL: dest, Status: status, Latency: time.Since(start), Checked: start}
+	if err == nil && (status < 200 || status >= 400) {
+		err = fmt.Errorf("status %d", status)
+	}
+
+	lc.mu.Lock()
+	last := lc.results[path]
+	lh.Disabled = last.Disabled
+	if err != nil {
+		lh.Error = err.Error()
+		lh.Failures = last.Failures + 1
+	}
+	disable := lc.opts.DisableAfter > 0 && lh.Failures >= lc.opts.DisableAfter && !lh.Disabled
+	if disable {
+		lh.Disabled = lc.routes.(disabler).Disable(path)
+		disable = lh.Disabled
+	}
+	lc.results[path] = lh
+	lc.mu.Unlock()
+
+	if disable {
+		lc.hooks.onDisable(path, dest, lh.Error)
+	}
+}
+
+// waitForHost waits until the host of dest may get the next request
+// and reserves the slot after it. It reports false if ctx is done
+// first.
+func (lc *LinkChecker) waitForHost(ctx context.Context, dest string) bool {
+	if lc.opts.HostInterval == 0 {
+		return true
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return true
+	}
+	host := normalizeHost(u.Host)
+
+	lc.hostMu.Lock()
+	now := time.Now()
+	at := lc.nextRequest[host]
+	if at.Before(now) {
+		at = now
+	}
+	lc.nextRequest[host] = at.Add(lc.opts.HostInterval)
+	lc.hostMu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// probe sends a HEAD request for dest, and a GET if the server
+// answers 405 Method Not Allowed, and returns the final status and
+// the URL it came from after the redirects
+func probe(ctx context.Context, client *http.Client, dest string) (int, string, error) {
+	status, final, err := probeWith(ctx, client, http.MethodHead, dest)
+	if err == nil && status == http.StatusMethodNotAllowed {
+		status, final, err = probeWith(ctx, client, http.MethodGet, dest)
+	}
+	return status, final, err
+}
+
+func probeWith(ctx context.Context, client *http.Client, method, dest string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, dest, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	// a little of the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	return resp.StatusCode, resp.Request.URL.String(), nil
+}
+
+// checkable reports whether dest is an absolute http or https URL
+// that can be requested as it is, without placeholders that are
+// filled in per request
+func checkable(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if strings.Contains(dest, "{{") || strings.Contains(dest, "$") {
+		// templates and regex groups
+		return false
+	}
+	rest := dest[len(u.Scheme)+len("://"):]
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == ':' && paramNameLen(rest[i+1:]) > 0 {
+			return false
+		}
+	}
+	return true
+}
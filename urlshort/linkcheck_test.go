@@ -0,0 +1,371 @@
+package urlshort
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// destinations is a site with live and dead pages that records the
+// methods each path was requested with
+type destinations struct {
+	*httptest.Server
+	mu      sync.Mutex
+	methods map[string][]string
+	times   []time.Time
+}
+
+func newDestinations(t *testing.T) *destinations {
+	d := &destinations{methods: make(map[string][]string)}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d.mu.Lock()
+		d.methods[r.URL.Path] = append(d.methods[r.URL.Path], r.Method)
+		d.times = append(d.times, time.Now())
+		d.mu.Unlock()
+		switch r.URL.Path {
+		case "/ok":
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/moved-away":
+			http.Redirect(w, r, "/gone", http.StatusMovedPermanently)
+		case "/moved-twice":
+			http.Redirect(w, r, "/moved", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+// requests returns the methods path was requested with
+func (d *destinations) requests(path string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.methods[path]...)
+}
+
+// newLinkChecker starts a LinkChecker that only checks when told to,
+// it returns once the first round is done
+func newLinkChecker(t *testing.T, routes RouteLister, opts LinkCheckOptions) *LinkChecker {
+	t.Helper()
+	opts.Interval = time.Hour
+	lc, err := NewLinkChecker(routes, opts)
+	if err != nil {
+		t.Fatalf("NewLinkChecker: %v", err)
+	}
+	t.Cleanup(func() { lc.Close() })
+	// the first round holds the lock until it is done, wait for it
+	// to start and then for the lock
+	for deadline := time.Now().Add(5 * time.Second); len(lc.Report()) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the first round never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	lc.round.Lock()
+	lc.round.Unlock()
+	return lc
+}
+
+// healthByPath returns the report of lc by path
+func healthByPath(lc *LinkChecker) map[string]LinkHealth {
+	byPath := make(map[string]LinkHealth)
+	for _, lh := range lc.Report() {
+		byPath[lh.Path] = lh
+	}
+	return byPath
+}
+
+func TestLinkChecker(t *testing.T) {
+	d := newDestinations(t)
+	store := NewMutableStore()
+	for _, path := range []string{"/ok", "/gone", "/broken", "/no-head", "/moved", "/moved-away", "/slow"} {
+		store.Set(path, d.URL+path)
+	}
+	// never requested
+	store.Set("/param/:name", d.URL+"/u/:name")
+
+	start := time.Now()
+	lc := newLinkChecker(t, store, LinkCheckOptions{Timeout: 100 * time.Millisecond})
+	tests := []struct {
+		path   string
+		status int
+		err    string
+	}{
+		{"/ok", http.StatusOK, ""},
+		{"/gone", 0, "status 404"},
+		{"/broken", 0, "status 500"},
+		// a GET when HEAD isn't allowed
+		{"/no-head", http.StatusOK, ""},
+		// the status after the redirects counts
+		{"/moved", http.StatusOK, ""},
+		{"/moved-away", 0, "status 404"},
+		{"/slow", 0, "Timeout"},
+	}
+	check := func(round int) {
+		t.Helper()
+		report := healthByPath(lc)
+		if len(report) != len(tests) {
+			t.Errorf("round %d: got %d results, want %d: %+v", round, len(report), len(tests), report)
+		}
+		for _, tt := range tests {
+			lh, ok := report[tt.path]
+			if !ok {
+				t.Errorf("round %d: %s wasn't checked", round, tt.path)
+				continue
+			}
+			if tt.err == "" {
+				if !lh.OK() || lh.Status != tt.status || lh.Failures != 0 {
+					t.Errorf("round %d: %s: got %+v, want status %d", round, tt.path, lh, tt.status)
+				}
+			} else if lh.OK() || !strings.Contains(lh.Error, tt.err) || lh.Failures != round {
+				t.Errorf("round %d: %s: got %+v, want %d failures with %q", round, tt.path, lh, round, tt.err)
+			}
+			if tt.status != 0 && lh.Status != tt.status {
+				t.Errorf("round %d: %s: got status %d", round, tt.path, lh.Status)
+			}
+			if lh.URL != d.URL+tt.path || lh.Checked.Before(start) || lh.Latency <= 0 {
+				t.Errorf("round %d: %s: got %+v", round, tt.path, lh)
+			}
+		}
+	}
+	check(1)
+	lc.CheckNow(context.Background())
+	check(2)
+
+	if got := strings.Join(d.requests("/no-head"), " "); got != "HEAD GET HEAD GET" {
+		t.Errorf("/no-head was requested with %s", got)
+	}
+	if got := strings.Join(d.requests("/ok"), " "); got != "HEAD HEAD HEAD HEAD" {
+		// twice directly, twice after /moved
+		t.Errorf("/ok was requested with %s", got)
+	}
+
+	// removed entries and new destinations are forgotten
+	store.Remove("/gone")
+	store.Set("/broken", d.URL+"/ok")
+	lc.CheckNow(context.Background())
+	report := healthByPath(lc)
+	if _, ok := report["/gone"]; ok {
+		t.Error("a removed entry is still reported")
+	}
+	if lh := report["/broken"]; !lh.OK() || lh.Failures != 0 {
+		t.Errorf("after a new destination: got %+v", lh)
+	}
+}
+
+func TestLinkCheckerDisable(t *testing.T) {
+	d := newDestinations(t)
+	store := NewMutableStore()
+	store.Set("/ok", d.URL+"/ok")
+	store.Set("/gone", d.URL+"/gone")
+
+	var mu sync.Mutex
+	var disabled []string
+	var panics []any
+	hook := func(path, dest, reason string) {
+		mu.Lock()
+		disabled = append(disabled, path+" "+dest+" "+reason)
+		mu.Unlock()
+		// a broken hook doesn't stop the checker
+		panic("hook failed")
+	}
+	lc := newLinkChecker(t, store, LinkCheckOptions{DisableAfter: 2, Hooks: []Option{
+		WithDisableHook(hook),
+		WithRecovery(func(err any, stack []byte) { panics = append(panics, err) }),
+	}})
+
+	if lh := healthByPath(lc)["/gone"]; lh.Disabled || lh.Failures != 1 {
+		t.Fatalf("after one failure: got %+v", lh)
+	}
+	serve := NewMutableHandler(store, urlshorttest.Fallback())
+	urlshorttest.AssertRedirect(t, serve, "/gone", d.URL+"/gone", http.StatusFound)
+	for i := 0; i < 3; i++ {
+		lc.CheckNow(context.Background())
+	}
+	if lh := healthByPath(lc)["/gone"]; !lh.Disabled || lh.Failures != 4 {
+		t.Errorf("after four failures: got %+v", lh)
+	}
+	urlshorttest.AssertFallback(t, serve, "/gone")
+	urlshorttest.AssertRedirect(t, serve, "/ok", d.URL+"/ok", http.StatusFound)
+	if healthByPath(lc)["/ok"].Disabled {
+		t.Error("/ok got disabled")
+	}
+	// the hook is told once
+	mu.Lock()
+	defer mu.Unlock()
+	if want := "/gone " + d.URL + "/gone status 404"; len(disabled) != 1 || disabled[0] != want {
+		t.Errorf("got disables %q, want %q", disabled, want)
+	}
+	if len(panics) != 1 || panics[0] != "hook failed" {
+		t.Errorf("got panics %v", panics)
+	}
+}
+
+func TestLinkCheckerHostInterval(t *testing.T) {
+	d := newDestinations(t)
+	store := NewMutableStore()
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		store.Set(path, d.URL+"/ok?"+path[1:])
+	}
+	start := time.Now()
+	newLinkChecker(t, store, LinkCheckOptions{Concurrency: 4, HostInterval: 30 * time.Millisecond})
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.times) != 4 {
+		t.Fatalf("got %d requests", len(d.times))
+	}
+	// the gaps between arrivals jitter with the scheduler, but the
+	// later requests can't be sent before their turn
+	for i, at := range sortedTimes(d.times) {
+		if earliest := time.Duration(i) * 30 * time.Millisecond; at.Sub(start) < earliest {
+			t.Errorf("request %d came %v after the start, want at least %v", i, at.Sub(start), earliest)
+		}
+	}
+}
+
+// sortedTimes returns a sorted copy of times
+func sortedTimes(times []time.Time) []time.Time {
+	sorted := append([]time.Time(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	return sorted
+}
+
+func TestLinkCheckerClose(t *testing.T) {
+	d := newDestinations(t)
+	store := NewMutableStore()
+	store.Set("/a", d.URL+"/ok?a")
+	store.Set("/b", d.URL+"/ok?b")
+	lc, err := NewLinkChecker(store, LinkCheckOptions{HostInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewLinkChecker: %v", err)
+	}
+	// the second request waits for an hour, Close doesn't
+	for len(lc.Report()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	closed := make(chan struct{})
+	go func() {
+		lc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waits for the host interval")
+	}
+	// the skipped check isn't counted as a failure
+	if report := lc.Report(); len(report) != 1 || !report[0].OK() {
+		t.Errorf("got %+v", report)
+	}
+}
+
+func TestLinkHealthHandler(t *testing.T) {
+	d := newDestinations(t)
+	store := NewMutableStore()
+	store.Set("/ok", d.URL+"/ok")
+	store.Set("/gone", d.URL+"/gone")
+	lc := newLinkChecker(t, store, LinkCheckOptions{})
+	h := LinkHealthHandler(lc, WithAdminToken("secret"))
+
+	if res := get(h, "/api/health/links"); res.Code != http.StatusUnauthorized {
+		t.Errorf("without the token: got %d", res.Code)
+	}
+	tests := []struct {
+		target string
+		paths  []string
+	}{
+		{"/api/health/links", []string{"/gone", "/ok"}},
+		{"/api/health/links?failing=true", []string{"/gone"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		var report []LinkHealth
+		if err := json.Unmarshal(res.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: decoding %s: %v", tt.target, res.Body.String(), err)
+		}
+		var paths []string
+		for _, lh := range report {
+			paths = append(paths, lh.Path)
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.paths, " ") {
+			t.Errorf("%s: got %v, want %v", tt.target, paths, tt.paths)
+		}
+	}
+}
+
+func TestNewLinkCheckerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes RouteLister
+		opts   LinkCheckOptions
+		want   string
+	}{
+		{"negative interval", NewMutableStore(), LinkCheckOptions{Interval: -time.Second}, "link check options can't be negative"},
+		{"negative concurrency", NewMutableStore(), LinkCheckOptions{Concurrency: -1}, "link check options can't be negative"},
+		{"negative failures", NewMutableStore(), LinkCheckOptions{DisableAfter: -1}, "link check options can't be negative"},
+		{"can't disable", staticRoutes{}, LinkCheckOptions{DisableAfter: 3}, "DisableAfter needs a store that can disable entries, urlshort.staticRoutes can't"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLinkChecker(tt.routes, tt.opts)
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+// staticRoutes is a RouteLister that can't disable entries
+type staticRoutes map[string]string
+
+func (r staticRoutes) Routes() map[string]string { return r }
+
+func TestCheckable(t *testing.T) {
+	tests := []struct {
+		dest string
+		want bool
+	}{
+		{"https://example.com", true},
+		{"http://example.com:8080/a?b=c", true},
+		{"https://example.com/docs#top", true},
+		{"/relative", false},
+		{"ftp://example.com", false},
+		{"mailto:someone@example.com", false},
+		{"https://example.com/u/:name", false},
+		{"https://example.com/{{.Query.Get \"q\"}}", false},
+		{"https://example.com/$1", false},
+		{"https://", false},
+	}
+	for _, tt := range tests {
+		if got := checkable(tt.dest); got != tt.want {
+			t.Errorf("checkable(%q): got %v, want %v", tt.dest, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Issue is a problem ValidateYAML found in a redirects file
+type Issue struct {
+	// Index is the entry the issue is in, -1 if it is about the
+	// file as a whole
+	Index int
+	// Line is the approximate 1-based line the entry starts on, 0
+	// if it isn't known
+	Line    int
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	if i.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", i.Line)
+	}
+	if i.Index >= 0 {
+		fmt.Fprintf(&b, "entry %d", i.Index)
+		if i.Path != "" {
+			fmt.Fprintf(&b, " (%s)", i.Path)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// ValidateYAML checks data the way YAMLHandler with
+// WithStrictParsing would, but reports every problem instead of
+// stopping at the first: unknown keys, missing paths and urls,
+// destinations with a scheme other than http or https, duplicate
+// paths, unsupported codes and broken optional fields. An empty
+// result means the file is fine. It is meant for checks in CI,
+// where a broken file should be caught before it is deployed.
+func ValidateYAML(data []byte) []Issue {
+	starts := entryLines(data)
+	issue := func(index int, path, msg string) Issue {
+		line := 0
+		if index >= 0 && index < len(starts) {
+			line = starts[index]
+		}
+		return Issue{Index: index, Line: line, Path: path, Message: msg}
+	}
+
+	var issues []Issue
+	// the strict decode finds the unknown keys, the lenient one still
+	// gets the entries to check when there are some
+	if _, _, err := decodeYAML(data, yaml.UnmarshalStrict); err != nil {
+		var te *yaml.TypeError
+		if !errors.As(err, &te) {
+			// not YAML at all, there is nothing more to check
+			line, msg := 0, strings.TrimPrefix(err.Error(), "yaml: ")
+			if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				msg = strings.TrimSpace(strings.TrimPrefix(msg, m[0]))
+			}
+			return []Issue{{Index: -1, Line: line, Message: msg}}
+		}
+		for _, msg := range te.Errors {
+			index := -1
+			if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				index = entryAtLine(data, line)
+				msg = strings.TrimSpace(strings.TrimPrefix(msg, m[0]))
+			}
+			issues = append(issues, issue(index, "", msg))
+		}
+	}
+	doc, isDoc, _ := decodeYAML(data, yaml.Unmarshal)
+
+	cfg := defaultConfig()
+	routes := newRouteTable(0, cfg)
+	seen := make(map[string]int, len(doc.Redirects))
+	for i, pu := range doc.Redirects {
+		add := func(msg string) {
+			issues = append(issues, issue(i, pu.Path, msg))
+		}
+		if pu.Path == "" {
+			add("missing a path")
+		} else if !pu.Regex && pu.Path[0] != '/' {
+			add(fmt.Sprintf("path %q must start with /", pu.Path))
+		}
+		if !hasDestination(pu) {
+			add("missing a url")
+		}
+
+		entry := []pathUrl{pu}
+		expanded := true
+		if isDoc {
+			if err := expandVars(entry, doc.Vars); err != nil {
+				for _, vi := range err.(*ValidationError).Issues {
+					add(vi.Reason)
+				}
+				expanded = false
+			}
+		}
+		if err := prepareEntry(&entry[0]); err != nil {
+			add(issueMessage(err, pu.Path))
+		}
+		// a url with a missing variable is broken already
+		if expanded && entry[0].URL != "" {
+			if err := validateEntries(entry, cfg); err != nil {
+				for _, vi := range err.(*ValidationError).Issues {
+					add(vi.Reason)
+				}
+			}
+		}
+		if pu.Regex {
+			if _, err := regexp.Compile(pu.Path); err != nil {
+				add(fmt.Sprintf("invalid regex: %v", err))
+			}
+		}
+
+		if pu.Path == "" {
+			continue
+		}
+		key := routes.duplicateKey(pu)
+		if first, ok := seen[key]; ok {
+			add(fmt.Sprintf("duplicate path, entry %d has it too", first))
+			continue
+		}
+		seen[key] = i
+		for _, alias := range pu.Aliases {
+			key := routes.duplicateKey(pathUrl{Path: alias, Host: pu.Host})
+			if first, ok := seen[key]; ok {
+				add(fmt.Sprintf("alias %s is a duplicate path, entry %d has it too", alias, first))
+				continue
+			}
+			seen[key] = i
+		}
+	}
+
+	// the issues of the type errors don't know their path yet
+	for j := range issues {
+		if i := issues[j].Index; i >= 0 && i < len(doc.Redirects) {
+			issues[j].Path = doc.Redirects[i].Path
+		}
+	}
+	sort.SliceStable(issues, func(a, b int) bool {
+		return issues[a].Index < issues[b].Index
+	})
+	return issues
+}
+
+// issueMessage is the message of err without the prefix naming the
+// package and the path, the issue carries those already
+func issueMessage(err error, path string) string {
+	msg := strings.TrimPrefix(err.Error(), "urlshort: ")
+	return strings.TrimPrefix(msg, path+": ")
+}
@@ -0,0 +1,81 @@
+package urlshort
+
+import (
+	"reflect"
+	"testing"
+)
+
+const lintFile = `# redirects
+- path: /gh
+  url: https://github.com
+- path: /ftp
+  url: ftp://example.com
+- path: gh2
+  url: https://github.com
+- path: /gh
+  url: https://gitlab.com
+- path: /code
+  url: https://example.com
+  code: 200
+- url: https://example.com/nopath
+- path: /typo
+  ulr: https://example.com
+- path: '[a-'
+  url: https://example.com
+  regex: true
+`
+
+func TestValidateYAML(t *testing.T) {
+	want := []Issue{
+		{1, 4, "/ftp", `url "ftp://example.com" must use one of the schemes http, https`},
+		{2, 6, "gh2", `path "gh2" must start with /`},
+		{3, 8, "/gh", "duplicate path, entry 0 has it too"},
+		{4, 10, "/code", "unsupported redirect code 200 (use 301, 302, 307 or 308)"},
+		{5, 13, "", "missing a path"},
+		{6, 14, "/typo", "field ulr not found in type urlshort.pathUrl"},
+		{6, 14, "/typo", "missing a url"},
+		{7, 16, "[a-", "invalid regex: error parsing regexp: missing closing ]: `[a-`"},
+	}
+	if got := ValidateYAML([]byte(lintFile)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestValidateYAMLFiles(t *testing.T) {
+	tests := []struct {
+		name, yaml string
+		want       []Issue
+	}{
+		{"fine", "- path: /gh\n  url: https://github.com\n", nil},
+		{"empty", "", nil},
+		{"not YAML", "- path: /gh\n  url: [\n", []Issue{{-1, 2, "", "did not find expected node content"}}},
+		{"document", "vars:\n  base: https://example.com\nredirects:\n  - path: /a\n    url: ${base}/a\n  - path: /b\n    url: ${bsae}/b\n",
+			[]Issue{{1, 6, "/b", "undefined variable ${bsae}"}}},
+		{"duplicate alias", "- path: /a\n  url: https://example.com\n- path: /b\n  url: https://example.com\n  aliases: [/a]\n",
+			[]Issue{{1, 3, "/b", "alias /a is a duplicate path, entry 0 has it too"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateYAML([]byte(tt.yaml)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	tests := []struct {
+		issue Issue
+		want  string
+	}{
+		{Issue{3, 8, "/gh", "duplicate path"}, "line 8: entry 3 (/gh): duplicate path"},
+		{Issue{5, 13, "", "missing a path"}, "line 13: entry 5: missing a path"},
+		{Issue{-1, 3, "", "broken"}, "line 3: broken"},
+		{Issue{-1, 0, "", "broken"}, "broken"},
+	}
+	for _, tt := range tests {
+		if got := tt.issue.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package urlshort
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithExpirySweep drops expired entries from the route table every
+// interval, so long running servers don't keep them in memory
+// forever. Expired entries are never served either way, this only
+// bounds memory. The sweep runs in the background and is triggered
+// by incoming requests.
+func WithExpirySweep(interval time.Duration) Option {
+	return func(c *config) {
+		c.sweepInterval = interval
+	}
+}
+
+// Handler serves redirects from a route table that can be
+// replaced while requests are coming in. New returns one, every
+// other handler built from a route table uses it too, and the
+// refreshable handlers embed it so it can be passed to
+// HealthHandler.
+type Handler struct {
+	fallback http.Handler
+	cfg      *config
+	routes   atomic.Pointer[routeTable]
+
+	// source names where the mappings come from, for HealthHandler
+	source string
+	// loaded is the unix nano time the route table was last replaced,
+	// zero until the first successful load
+	loaded atomic.Int64
+	// storeMu orders the swaps of the route table, so a sweep never
+	// replaces a table that was swapped in while it ran
+	storeMu sync.Mutex
+
+	// nextSweep is the unix nano time the next expiry sweep is due
+	nextSweep atomic.Int64
+}
+
+// ServeHTTP redirects using the latest route table
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.tracer != nil {
+		var end func()
+		r, end = h.cfg.startSpan(r, h.source)
+		defer end()
+	}
+	now := h.cfg.now()
+	h.maybeSweep(now)
+
+	// expiry and active windows are checked per request, so entries
+	// start and stop working on time no matter when the table was built
+	routes := h.routes.Load()
+	path, ok := lookupPath(r, h.cfg)
+	if !ok {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+	if m, ok := routes.lookupHost(r.Host, path); ok {
+		if m, ok := m.current(now); ok {
+			serveMatch(w, r, m, h.fallback, h.cfg)
+			return
+		}
+	}
+
+	if h.cfg.suggest {
+		found := routes.suggestHost(r.Host, path)
+		if s, ok := correction(found); ok && h.cfg.autoCorrect {
+			if m, ok := (match{entry: s.entry, dest: s.entry.URL}).current(now); ok {
+				serveMatch(w, r, m, h.fallback, h.cfg)
+				return
+			}
+		}
+		if len(found) > 0 {
+			suggestions := make([]string, len(found))
+			for i, s := range found {
+				suggestions[i] = h.cfg.pathPrefix + s.entry.Path
+			}
+			r = withSuggestions(r, suggestions)
+		}
+	}
+	serveMiss(w, r, h.fallback, h.cfg)
+}
+
+// Reload replaces the current mappings with newEntries. The new
+// route table is fully built and validated before it is swapped
+// in with a single atomic store, so requests never wait on a lock
+// and a bad reload leaves the old mappings in place. The entries
+// are checked like the handler's own: duplicates, destinations and
+// everything else its options ask for.
+func (h *Handler) Reload(newEntries []Entry) error {
+	// prepareEntries fills in derived fields, work on a copy so the
+	// caller's slice is left alone
+	pathUrls := append([]pathUrl(nil), newEntries...)
+	if err := checkEntries(pathUrls); err != nil {
+		return err
+	}
+	routes, err := buildRoutes(pathUrls, h.cfg)
+	if err != nil {
+		return err
+	}
+	h.store(routes)
+	return nil
+}
+
+// ReloadYAML is Reload with the entries parsed from data, in the
+// format YAMLHandler accepts
+func (h *Handler) ReloadYAML(data []byte) error {
+	pathUrls, err := h.cfg.parseYAML(data)
+	if err != nil {
+		return err
+	}
+	routes, err := buildRoutes(pathUrls, h.cfg)
+	if err != nil {
+		return err
+	}
+	h.store(routes)
+	return nil
+}
+
+// Snapshot returns a copy of the entries being served, as they are
+// after parsing, e.g. to diff them with the next reload. They can
+// be passed to Reload again.
+func (h *Handler) Snapshot() []Entry {
+	return append([]Entry(nil), h.routes.Load().entries...)
+}
+
+// store swaps in routes and remembers when it happened
+func (h *Handler) store(routes *routeTable) {
+	h.storeMu.Lock()
+	defer h.storeMu.Unlock()
+	h.storeLocked(routes)
+}
+
+// storeLocked is store with storeMu held
+func (h *Handler) storeLocked(routes *routeTable) {
+	h.routes.Store(routes)
+	h.loaded.Store(h.cfg.now().UnixNano())
+	if h.cfg.stats != nil {
+		h.cfg.stats.track(routes.statsPaths, routes.aliases, h.cfg.now)
+	}
+}
+
+// Len returns the number of mappings being served
+func (h *Handler) Len() int {
+	return len(h.routes.Load().entries)
+}
+
+// LastLoad returns when the mappings were last loaded successfully,
+// the zero time if they never were
+func (h *Handler) LastLoad() time.Time {
+	n := h.loaded.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// maybeSweep starts a sweep in the background if one is due. Only
+// the request that moves nextSweep forward starts it.
+func (h *Handler) maybeSweep(now time.Time) {
+	if h.cfg.sweepInterval == 0 {
+		return
+	}
+	due := h.nextSweep.Load()
+	if now.UnixNano() < due {
+		return
+	}
+	if h.nextSweep.CompareAndSwap(due, now.Add(h.cfg.sweepInterval).UnixNano()) {
+		go h.sweep(now)
+	}
+}
+
+// sweep rebuilds the route table without the expired entries
+func (h *Handler) sweep(now time.Time) {
+	old := h.routes.Load()
+
+	var kept []pathUrl
+	for _, pu := range old.entries {
+		if !pu.expired(now) {
+			kept = append(kept, pu)
+		}
+	}
+	if len(kept) == len(old.entries) {
+		return
+	}
+
+	routes, err := buildRoutes(kept, h.cfg)
+	if err != nil {
+		return
+	}
+	h.storeMu.Lock()
+	defer h.storeMu.Unlock()
+	// don't throw away a table that was swapped in while sweeping
+	if h.routes.Load() == old {
+		h.storeLocked(routes)
+	}
+}
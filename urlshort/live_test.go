@@ -0,0 +1,277 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestHandlerReload(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	first := h.LastLoad()
+	if first.IsZero() || h.Len() != 1 {
+		t.Fatalf("after New: LastLoad %v, Len %d", first, h.Len())
+	}
+
+	err = h.Reload([]Entry{
+		{Path: "/gl", URL: "https://gitlab.com"},
+		{Path: "/go", URL: "https://go.dev", Code: http.StatusMovedPermanently},
+	})
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusMovedPermanently},
+		{Path: "/gh"},
+	})
+	if h.Len() != 2 || h.LastLoad().Before(first) {
+		t.Errorf("after Reload: LastLoad %v, Len %d", h.LastLoad(), h.Len())
+	}
+
+	// a bad reload leaves everything as it was
+	for _, bad := range [][]Entry{
+		{{Path: "/ok", URL: "https://ok.example.com"}, {Path: "/no-url"}},
+		{{Path: "/ok", URL: "https://ok.example.com"}, {Path: "/dup", URL: "https://a.example.com"}, {Path: "/dup", URL: "https://b.example.com"}},
+		{{Path: "/ok", URL: "https://ok.example.com"}, {Path: "/code", URL: "https://a.example.com", Code: 200}},
+		{{Path: "/ok", URL: "https://ok.example.com"}, {Path: "/rel", URL: "not a url"}},
+	} {
+		if err := h.Reload(bad); err == nil {
+			t.Errorf("Reload(%v): got no error", bad)
+		}
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/ok"},
+	})
+
+	// the snapshot can be loaded again and the caller's slice is left alone
+	snapshot := h.Snapshot()
+	before := slices.Clone(snapshot)
+	if err := h.Reload(snapshot); err != nil {
+		t.Fatalf("Reload(Snapshot()): %v", err)
+	}
+	if !reflect.DeepEqual(snapshot, before) {
+		t.Errorf("Reload changed the entries it was given:\n%v\nwas\n%v", snapshot, before)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{{Path: "/go", Location: "https://go.dev", Code: http.StatusMovedPermanently}})
+
+	// nor does changing a snapshot change what is served
+	for i := range snapshot {
+		snapshot[i].URL = "https://changed.example.com"
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusMovedPermanently},
+	})
+}
+
+func TestHandlerReloadYAML(t *testing.T) {
+	h, err := New(FromYAML([]byte("- path: /gh\n  url: https://github.com\n")), urlshorttest.Fallback(), WithCaseInsensitivePaths())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"invalid yaml", "- path: /gl\n  url: [\n", "yaml"},
+		{"missing url", "- path: /gl\n", "/gl"},
+		{"bad url", "- path: /gl\n  url: not a url\n", "/gl"},
+		{"duplicate", "- path: /gl\n  url: https://gitlab.com\n- path: /gl\n  url: https://gitlab.com/x\n", "duplicate path: /gl"},
+		// the checks of the handler's options apply too
+		{"duplicate by case", "- path: /gl\n  url: https://gitlab.com\n- path: /GL\n  url: https://gitlab.com/x\n", "duplicate path"},
+		{"relative", "- path: /gl\n  url: /gitlab\n", `url "/gitlab" is not absolute`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantError(t, h.ReloadYAML([]byte(tt.data)), tt.err)
+			// the old mappings are still served
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/GH", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/gl"},
+			})
+		})
+	}
+
+	err = h.ReloadYAML([]byte("vars:\n  base: https://gitlab.com\nredirects:\n  - path: /gl\n    url: ${base}/nils\n    code: 301\n"))
+	if err != nil {
+		t.Fatalf("ReloadYAML: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/GL", Location: "https://gitlab.com/nils", Code: http.StatusMovedPermanently},
+		{Path: "/gh"},
+	})
+	want := []Entry{{Path: "/gl", URL: "https://gitlab.com/nils", Code: http.StatusMovedPermanently}}
+	if got := h.Snapshot(); len(got) != 1 || got[0].Path != want[0].Path || got[0].URL != want[0].URL || got[0].Code != want[0].Code {
+		t.Errorf("Snapshot: got %v, want %v", got, want)
+	}
+}
+
+func TestHandlerReloadUnderLoad(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/a": "https://a.example.com/0"}), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var stop atomic.Bool
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for i := 1; !stop.Load(); i++ {
+			h.Reload([]Entry{
+				{Path: "/a", URL: fmt.Sprintf("https://a.example.com/%d", i)},
+				{Path: "/b", URL: fmt.Sprintf("https://b.example.com/%d", i)},
+			})
+			// failing reloads in between are never served
+			if err := h.Reload([]Entry{{Path: "/a", URL: "https://bad.example.com"}, {Path: "/a", URL: "https://bad.example.com/2"}}); err == nil {
+				t.Error("a reload with a duplicate got no error")
+			}
+			if err := h.ReloadYAML([]byte("- path: /a\n  url: https://bad.example.com\n- path: /b\n")); err == nil {
+				t.Error("a reload missing a url got no error")
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				res := get(h, "/a")
+				if loc := res.Header().Get("Location"); res.Code != http.StatusFound || !strings.HasPrefix(loc, "https://a.example.com/") {
+					t.Errorf("GET /a during reloads: %d to %q", res.Code, loc)
+					return
+				}
+				// a table is swapped in as a whole, never half of it
+				snap := h.Snapshot()
+				if len(snap) == 2 && strings.TrimPrefix(snap[0].URL, "https://a.example.com/") != strings.TrimPrefix(snap[1].URL, "https://b.example.com/") {
+					t.Errorf("a snapshot during reloads mixes two loads: %v", snap)
+					return
+				}
+				if n := len(snap); n != 1 && n != 2 {
+					t.Errorf("a snapshot during reloads has %d entries", n)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	stop.Store(true)
+	<-reloaded
+}
+
+// sweepEntries expire an hour and a day after the fake clock starts
+const sweepEntries = `
+- path: /gh
+  url: https://github.com
+- path: /old
+  url: https://example.com/old
+  expires: 2025-01-01T01:00:00Z
+- path: /later
+  url: https://example.com/later
+  expires: 2025-01-02T00:00:00Z
+`
+
+func TestExpirySweep(t *testing.T) {
+	clock := newFakeClock()
+	stats := NewStats()
+	h, err := New(FromYAML([]byte(sweepEntries)), urlshorttest.Fallback(), WithExpirySweep(time.Hour), WithClock(clock.Now), WithStats(stats))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	paths := func() []string {
+		var paths []string
+		for _, e := range h.Snapshot() {
+			paths = append(paths, e.Path)
+		}
+		slices.Sort(paths)
+		return paths
+	}
+	urlshorttest.AssertRedirect(t, h, "/old", "https://example.com/old", http.StatusFound)
+	if h.Len() != 3 {
+		t.Fatalf("got %d entries, want 3", h.Len())
+	}
+
+	// the request after the expiry starts the sweep
+	clock.Advance(2 * time.Hour)
+	urlshorttest.AssertFallback(t, h, "/old")
+	eventually(t, "the sweep", func() bool { return h.Len() == 2 })
+	if got, want := paths(), []string{"/gh", "/later"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// the swept table is stored like a reload
+	if !h.LastLoad().Equal(clock.Now()) {
+		t.Errorf("last load %v, want %v", h.LastLoad(), clock.Now())
+	}
+	for _, ps := range stats.StaleReport(0) {
+		if ps.Path == "/old" {
+			t.Error("the stale report still has /old")
+		}
+	}
+
+	clock.Advance(24 * time.Hour)
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	eventually(t, "the second sweep", func() bool { return h.Len() == 1 })
+	urlshorttest.AssertFallback(t, h, "/later")
+}
+
+// benchmarkPaths maps n paths
+func benchmarkPaths(n int) map[string]string {
+	paths := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		paths[fmt.Sprintf("/p%d", i)] = fmt.Sprintf("https://example.com/%d", i)
+	}
+	return paths
+}
+
+func BenchmarkHandlerServe(b *testing.B) {
+	paths := benchmarkPaths(1000)
+	entries := make([]Entry, 0, len(paths))
+	for path, dest := range paths {
+		entries = append(entries, Entry{Path: path, URL: dest})
+	}
+
+	b.Run("MapHandler", func(b *testing.B) {
+		h := MapHandler(paths, urlshorttest.Fallback())
+		for i := 0; i < b.N; i++ {
+			get(h, "/p500")
+		}
+	})
+	b.Run("Handler", func(b *testing.B) {
+		h, _ := New(FromMap(paths), urlshorttest.Fallback())
+		for i := 0; i < b.N; i++ {
+			get(h, "/p500")
+		}
+	})
+	b.Run("Handler reloading", func(b *testing.B) {
+		h, _ := New(FromMap(paths), urlshorttest.Fallback())
+		var stop atomic.Bool
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for !stop.Load() {
+				h.Reload(entries)
+			}
+		}()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			get(h, "/p500")
+		}
+		b.StopTimer()
+		stop.Store(true)
+		<-done
+	})
+}
@@ -0,0 +1,168 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultMaxHops is how often a request can be rewritten before it
+// is given up on
+const defaultMaxHops = 10
+
+// WithSelfHosts tells the handler which hosts it is serving, so
+// destinations pointing back at one of its own entries are
+// recognized. Building the handler then fails for redirect loops
+// like /a -> https://go.example.com/a too, not only for those
+// through relative destinations and rewrites like /a -> /b -> /a,
+// with an error showing the whole cycle. Ports are ignored.
+func WithSelfHosts(hosts ...string) Option {
+	return func(c *config) {
+		c.selfHosts = make(map[string]bool, len(hosts))
+		for _, host := range hosts {
+			c.selfHosts[normalizeHost(host)] = true
+		}
+	}
+}
+
+// WithMaxHops sets how often a single request can be rewritten by
+// entries with mode rewrite, when the fallback passes rewritten
+// requests back to a handler. Past that the request is answered
+// with 508 Loop Detected. Loops between the entries of one table
+// are found when it is built already, this catches the ones that
+// go through entries added later or through other handlers. The
+// default is 10.
+func WithMaxHops(n int) Option {
+	return func(c *config) {
+		c.maxHops = n
+	}
+}
+
+// hops returns how often the request of ctx was rewritten so far
+func hops(ctx context.Context) int {
+	n, _ := ctx.Value(hopsKey).(int)
+	return n
+}
+
+// withHop returns ctx counting one more rewrite, and whether that
+// is still within the limit of cfg
+func withHop(ctx context.Context, cfg *config) (context.Context, bool) {
+	n := hops(ctx) + 1
+	if n > cfg.maxHops {
+		return ctx, false
+	}
+	return context.WithValue(ctx, hopsKey, n), true
+}
+
+// serveLoopDetected answers a request that was rewritten too often
+func serveLoopDetected(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusLoopDetected), http.StatusLoopDetected)
+}
+
+// follow returns the entry of the table the redirect of pu ends up
+// at, if its destination points back at this handler and that entry
+// passes requests on again, by redirecting or rewriting
+func (rt *routeTable) follow(pu pathUrl, cfg *config) (match, bool) {
+	host, path, ok := ownDestination(pu, cfg)
+	if !ok {
+		return match{}, false
+	}
+	m, ok := rt.lookupHost(host, path)
+	if !ok || !internal(m.entry) {
+		return match{}, false
+	}
+	return m, true
+}
+
+// ownDestination returns the host and path the redirect of pu goes
+// to, if it points back at this handler
+func ownDestination(pu pathUrl, cfg *config) (string, string, bool) {
+	u, err := url.Parse(pu.URL)
+	if err != nil {
+		return "", "", false
+	}
+	host := u.Host
+	if host == "" {
+		// relative destinations stay on the host of the entry
+		host = pu.Host
+	} else if !cfg.selfHosts[normalizeHost(host)] {
+		return "", "", false
+	}
+	return host, u.Path, true
+}
+
+// redirects reports whether pu answers with a redirect other
+// entries could be reached through
+func redirects(pu pathUrl) bool {
+	return pu.Mode == "" || pu.Mode == modeRedirect
+}
+
+// next returns the entry pu passes requests on to inside this
+// table: the entry a rewrite lands on, or the one its redirect
+// points back at
+func (rt *routeTable) next(pu pathUrl, cfg *config) (match, bool) {
+	if pu.Mode != modeRewrite {
+		return rt.follow(pu, cfg)
+	}
+	u, err := url.Parse(pu.URL)
+	if err != nil {
+		return match{}, false
+	}
+	m, ok := rt.lookupHost(pu.Host, u.Path)
+	if !ok || !internal(m.entry) {
+		return match{}, false
+	}
+	return m, true
+}
+
+// internal reports whether pu passes requests on to other entries,
+// by redirecting or rewriting
+func internal(pu pathUrl) bool {
+	return redirects(pu) || pu.Mode == modeRewrite
+}
+
+// checkLoops returns an error for the first loop between the
+// entries of the table, through redirects and rewrites. Relative
+// destinations always stay on this handler, absolute ones only
+// with WithSelfHosts.
+func (rt *routeTable) checkLoops(cfg *config) error {
+	for _, pu := range rt.entries {
+		if !internal(pu) {
+			continue
+		}
+		if chain, ok := rt.loopFrom(pu, cfg); ok {
+			return fmt.Errorf("urlshort: redirect loop: %s", strings.Join(chain, " -> "))
+		}
+	}
+	return nil
+}
+
+// loopFrom follows the redirects and rewrites starting at pu and
+// returns the paths of the cycle if they run into one. Every entry
+// is visited at most once, so the walk ends after as many steps as
+// the table has entries.
+func (rt *routeTable) loopFrom(pu pathUrl, cfg *config) ([]string, bool) {
+	m, ok := rt.next(pu, cfg)
+	if !ok {
+		// most entries lead out of the table right away
+		return nil, false
+	}
+
+	chain := []string{pu.Path}
+	seen := map[string]int{pu.Host + " " + pu.Path: 0}
+	for {
+		pu = m.entry
+		key := pu.Host + " " + pu.Path
+		if i, ok := seen[key]; ok {
+			return append(chain[i:], pu.Path), true
+		}
+		seen[key] = len(chain)
+		chain = append(chain, pu.Path)
+
+		if m, ok = rt.next(pu, cfg); !ok {
+			return nil, false
+		}
+	}
+}
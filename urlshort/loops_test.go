@@ -0,0 +1,156 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestWithSelfHosts(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"self reference", "- path: /a\n  url: https://go.example.com/a\n", "redirect loop: /a -> /a"},
+		{"port and case", "- path: /a\n  url: https://GO.example.com:8443/a\n", "redirect loop: /a -> /a"},
+		{"query", "- path: /a\n  url: https://go.example.com/a?x=1\n", "redirect loop: /a -> /a"},
+		{"through another host", "- path: /a\n  url: https://go.example.com/b\n- path: /b\n  url: http://links.example.org/a\n",
+			"redirect loop: /a -> /b -> /a"},
+		{"three entries", "- path: /a\n  url: https://go.example.com/b\n- path: /b\n  url: https://go.example.com/c\n- path: /c\n  url: https://go.example.com/a\n",
+			"redirect loop: /a -> /b -> /c -> /a"},
+		{"parameters", "- path: /a/:x\n  url: https://go.example.com/a/:x\n", "redirect loop: /a/:x -> /a/:x"},
+		{"relative", "- path: /a\n  url: /b\n- path: /b\n  url: /a\n", "redirect loop: /a -> /b -> /a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback(),
+				WithSelfHosts("go.example.com", "links.example.org"), WithRelativeDestinations())
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestWithSelfHostsNoLoop(t *testing.T) {
+	data := `
+- path: /a
+  url: https://go.example.com/b
+- path: /b
+  url: https://docs.example.com
+- path: /c
+  url: https://other.example.com/c
+- path: /d
+  url: https://go.example.com/unknown
+`
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithSelfHosts("go.example.com"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/a", Location: "https://go.example.com/b", Code: http.StatusFound},
+		{Path: "/c", Location: "https://other.example.com/c", Code: http.StatusFound},
+		{Path: "/d", Location: "https://go.example.com/unknown", Code: http.StatusFound},
+	})
+
+	// it's opt-in, the library can't know its own hosts
+	if _, err := YAMLHandler([]byte("- path: /a\n  url: https://go.example.com/a\n"), urlshorttest.Fallback()); err != nil {
+		t.Errorf("without WithSelfHosts: %v", err)
+	}
+}
+
+func TestRewriteLoops(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"self loop", "- path: /a\n  url: /a\n  mode: rewrite\n", "redirect loop: /a -> /a"},
+		{"two entries", "- path: /a\n  url: /b\n  mode: rewrite\n- path: /b\n  url: /a\n  mode: rewrite\n", "redirect loop: /a -> /b -> /a"},
+		{"rewrite and redirect", "- path: /a\n  url: /b\n  mode: rewrite\n- path: /b\n  url: /a\n", "redirect loop: /a -> /b -> /a"},
+		{"through a wildcard", "- path: /a\n  url: /w/x\n  mode: rewrite\n- path: /w/*\n  url: /a\n  mode: rewrite\n", "redirect loop: /a -> /w/* -> /a"},
+		{"behind a chain", "- path: /start\n  url: /a\n  mode: rewrite\n- path: /a\n  url: /b\n  mode: rewrite\n- path: /b\n  url: /a\n  mode: rewrite\n",
+			"redirect loop: /a -> /b -> /a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback(), WithRelativeDestinations())
+			wantError(t, err, tt.want)
+		})
+	}
+
+	// a deep chain without a cycle is fine, and so are rewrites to
+	// proxies and pages that don't pass requests on
+	data := "- path: /p\n  url: /q\n  mode: rewrite\n- path: /q\n  url: https://upstream.example.com\n  mode: proxy\n"
+	for i := 0; i < 20; i++ {
+		data += fmt.Sprintf("- path: /s%d\n  url: /s%d\n  mode: rewrite\n", i, i+1)
+	}
+	if _, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithRelativeDestinations()); err != nil {
+		t.Errorf("a deep chain: %v", err)
+	}
+}
+
+// rewriteChain builds n handlers that each rewrite /s<i> to /s<i+1>
+// and pass it to the next one, the last passes it to last
+func rewriteChain(t *testing.T, n int, last http.Handler, opts ...Option) http.Handler {
+	t.Helper()
+	next := last
+	for i := n - 1; i >= 0; i-- {
+		h, err := YAMLHandler([]byte(fmt.Sprintf("- path: /s%d\n  url: /s%d\n  mode: rewrite\n", i, i+1)), next, opts...)
+		if err != nil {
+			t.Fatalf("YAMLHandler: %v", err)
+		}
+		next = h
+	}
+	return next
+}
+
+func TestMaxHops(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		opts  []Option
+		code  int
+	}{
+		{"under the default", 9, nil, http.StatusOK},
+		{"at the default", 10, nil, http.StatusOK},
+		{"past the default", 11, nil, http.StatusLoopDetected},
+		{"at a lower limit", 3, []Option{WithMaxHops(3)}, http.StatusOK},
+		{"past a lower limit", 4, []Option{WithMaxHops(3)}, http.StatusLoopDetected},
+		{"a deep chain", 40, []Option{WithMaxHops(50)}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := rewriteChain(t, tt.depth, echoFallback(), tt.opts...)
+			res := get(h, "/s0")
+			if res.Code != tt.code {
+				t.Fatalf("GET /s0 through %d rewrites: got %d, want %d", tt.depth, res.Code, tt.code)
+			}
+			if want := fmt.Sprintf("/s%d ", tt.depth); tt.code == http.StatusOK && !strings.HasPrefix(res.Body.String(), want) {
+				t.Errorf("the last fallback got %q, want %q", res.Body, want)
+			}
+		})
+	}
+
+	// handlers passing requests back to each other can't see the loop
+	// when they are built, the hop count stops it
+	var h1, h2 http.Handler
+	h1, err := YAMLHandler([]byte("- path: /a\n  url: /b\n  mode: rewrite\n"),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { h2.ServeHTTP(w, r) }), WithMaxHops(5))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	h2, err = YAMLHandler([]byte("- path: /b\n  url: /a\n  mode: rewrite\n"),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { h1.ServeHTTP(w, r) }), WithMaxHops(5))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	if res := get(h1, "/a"); res.Code != http.StatusLoopDetected {
+		t.Errorf("GET /a ping-ponging between handlers: got %d, want 508", res.Code)
+	}
+
+	_, err = YAMLHandler([]byte("- path: /a\n  url: https://a.example.com\n"), nil, WithMaxHops(0))
+	wantError(t, err, "max hops 0 must be at least 1")
+}
@@ -0,0 +1,201 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Source is a set of mappings to serve with New or to merge with
+// others, see FromYAML, FromJSON, FromMap and FromFile.
+type Source interface {
+	// Name identifies the source in error messages
+	Name() string
+	load(cfg *config) ([]pathUrl, error)
+	// build makes the route table for the loaded entries
+	build(pathUrls []pathUrl, cfg *config) (*routeTable, error)
+}
+
+// FromYAML is a source parsed from YAML like YAMLHandler accepts
+func FromYAML(yamlBytes []byte) Source {
+	return &source{name: "yaml", fn: func(cfg *config) ([]pathUrl, error) { return cfg.parseYAML(yamlBytes) }}
+}
+
+// FromJSON is a source parsed from JSON like JSONHandler accepts
+func FromJSON(jsonBytes []byte) Source {
+	return &source{name: "json", fn: func(cfg *config) ([]pathUrl, error) { return parseJSON(jsonBytes) }}
+}
+
+// FromMap is a source with the mappings of m, like MapHandler uses.
+// Like with MapHandler the destinations aren't validated.
+func FromMap(m map[string]string) Source {
+	return &source{name: "map", unchecked: true, fn: func(cfg *config) ([]pathUrl, error) {
+		pathUrls := make([]pathUrl, 0, len(m))
+		for path, dest := range m {
+			pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+		}
+		return pathUrls, nil
+	}}
+}
+
+// FromFile is a source read from the file at path when it is loaded.
+// The format is picked by the extension like NewFileHandler does,
+// which also reloads the file.
+func FromFile(path string) Source {
+	return &source{name: path, fn: func(cfg *config) ([]pathUrl, error) {
+		return parseFile(path, formatForPath(path), cfg)
+	}}
+}
+
+// Named gives s a name to be used in error messages, e.g. the
+// file it was read from or the team that owns it.
+func Named(name string, s Source) Source {
+	return &namedSource{Source: s, name: name}
+}
+
+// source is a Source backed by a load function. unchecked skips
+// validating the destinations, for maps.
+type source struct {
+	name      string
+	fn        func(cfg *config) ([]pathUrl, error)
+	unchecked bool
+}
+
+func (s *source) Name() string { return s.name }
+
+func (s *source) load(cfg *config) ([]pathUrl, error) { return s.fn(cfg) }
+
+func (s *source) build(pathUrls []pathUrl, cfg *config) (*routeTable, error) {
+	if s.unchecked {
+		return buildMap(schemeEntries(pathUrls, cfg), cfg)
+	}
+	return buildRoutes(pathUrls, cfg)
+}
+
+type namedSource struct {
+	Source
+	name string
+}
+
+func (s *namedSource) Name() string { return s.name }
+
+// MergeOptions changes how MergeWith combines sources
+type MergeOptions struct {
+	// Collisions decides which entry is kept when sources map the
+	// same path to different URLs, the default is an error
+	Collisions CollisionPolicy
+	// LaterWins is Collisions KeepLast, from before there were
+	// collision policies
+	LaterWins bool
+
+	// repeats leaves paths that a single source has more than once
+	// to buildRoutes and the duplicate policy, like for one file
+	repeats bool
+}
+
+// policy returns the collision policy opts ask for
+func (opts MergeOptions) policy() CollisionPolicy {
+	if opts.LaterWins && opts.Collisions == CollisionError {
+		return KeepLast
+	}
+	return opts.Collisions
+}
+
+// Merge combines the mappings of all sources into a single map. A
+// path that two sources map to different URLs is an error naming
+// both sources, use MergeWith to pick a winner instead.
+func Merge(sources ...Source) (map[string]string, error) {
+	return MergeWith(MergeOptions{}, sources...)
+}
+
+// MergeWith is Merge with options
+func MergeWith(opts MergeOptions, sources ...Source) (map[string]string, error) {
+	pathsToUrls, _, err := MergeReport(opts, sources...)
+	return pathsToUrls, err
+}
+
+// MergeReport is MergeWith that also returns the collisions the
+// policy resolved, e.g. to log them at startup
+func MergeReport(opts MergeOptions, sources ...Source) (map[string]string, []Collision, error) {
+	pathUrls, collisions, err := mergeEntries(opts, sources, defaultConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pathsToUrls := make(map[string]string, len(pathUrls))
+	for _, pu := range pathUrls {
+		pathsToUrls[pu.Path] = pu.URL
+	}
+	return pathsToUrls, collisions, nil
+}
+
+// MergedHandler merges the sources like Merge does and serves the
+// result like YAMLHandler. Per-entry settings like the redirect code
+// are kept. Use New with Merged for collision policies and options.
+func MergedHandler(fallback http.Handler, sources ...Source) (http.HandlerFunc, error) {
+	cfg := defaultConfig()
+	pathUrls, _, err := mergeEntries(MergeOptions{}, sources, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(pathUrls, fallback, cfg)
+}
+
+// mergeEntries loads all sources and combines their entries, keeping
+// the order in which paths first appeared. Paths are compared after
+// the normalization options of cfg, like duplicates within a source.
+func mergeEntries(opts MergeOptions, sources []Source, cfg *config) ([]pathUrl, []Collision, error) {
+	policy := opts.policy()
+	if err := policy.check(sources); err != nil {
+		return nil, nil, err
+	}
+
+	var merged []pathUrl
+	var collisions []Collision
+	// index into merged and the source that set it, per path
+	owner := make(map[string]int)
+	ownerSource := make(map[string]Source)
+	ownerLabel := make(map[string]string)
+	routes := newRouteTable(0, cfg)
+
+	for n, s := range sources {
+		label := fmt.Sprintf("source %d (%s)", n+1, s.Name())
+
+		pathUrls, err := s.load(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("urlshort: %s: %w", label, err)
+		}
+
+		for _, pu := range pathUrls {
+			key := routes.duplicateKey(pu)
+			i, ok := owner[key]
+			if ok && opts.repeats && ownerSource[key] == s {
+				merged = append(merged, pu)
+				continue
+			}
+			if !ok {
+				owner[key] = len(merged)
+				merged = append(merged, pu)
+				ownerSource[key], ownerLabel[key] = s, label
+				continue
+			}
+			if merged[i].URL == pu.URL {
+				// the same mapping twice is not a conflict
+				continue
+			}
+
+			replace, decided := policy.replaces(ownerSource[key], s)
+			if !decided {
+				return nil, nil, fmt.Errorf("urlshort: %s conflicts between %s (%s) and %s (%s)",
+					pu.Path, ownerLabel[key], merged[i].URL, label, pu.URL)
+			}
+			c := Collision{Path: pu.Path, Kept: ownerLabel[key], KeptURL: merged[i].URL, Dropped: label, DroppedURL: pu.URL}
+			if replace {
+				c.Kept, c.KeptURL, c.Dropped, c.DroppedURL = c.Dropped, c.DroppedURL, c.Kept, c.KeptURL
+				merged[i] = pu
+				ownerSource[key], ownerLabel[key] = s, label
+			}
+			collisions = append(collisions, c)
+		}
+	}
+	return merged, collisions, nil
+}
@@ -0,0 +1,109 @@
+package urlshort
+
+import (
+	"maps"
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestMerge(t *testing.T) {
+	web := Named("web team", FromYAML([]byte("- path: /gh\n  url: https://github.com\n- path: /docs\n  url: https://docs.example.com\n")))
+	ops := Named("ops team", FromJSON([]byte(`[{"path": "/status", "url": "https://status.example.com"}, {"path": "/gh", "url": "https://github.com"}]`)))
+	extra := FromMap(map[string]string{"/go": "https://go.dev"})
+	conflicting := Named("docs team", FromMap(map[string]string{"/docs": "https://wiki.example.com", "/wiki": "https://wiki.example.com"}))
+
+	tests := []struct {
+		name    string
+		opts    MergeOptions
+		sources []Source
+		want    map[string]string
+		err     string
+	}{
+		{
+			name:    "disjoint and same destination",
+			sources: []Source{web, ops, extra},
+			want: map[string]string{
+				"/gh":     "https://github.com",
+				"/docs":   "https://docs.example.com",
+				"/status": "https://status.example.com",
+				"/go":     "https://go.dev",
+			},
+		},
+		{
+			name:    "conflict",
+			sources: []Source{web, extra, conflicting},
+			err:     `/docs`,
+		},
+		{
+			name:    "later wins",
+			opts:    MergeOptions{LaterWins: true},
+			sources: []Source{web, ops, conflicting},
+			want: map[string]string{
+				"/gh":     "https://github.com",
+				"/docs":   "https://wiki.example.com",
+				"/status": "https://status.example.com",
+				"/wiki":   "https://wiki.example.com",
+			},
+		},
+		{
+			name:    "no sources",
+			sources: nil,
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergeWith(tt.opts, tt.sources...)
+			if tt.err != "" {
+				wantError(t, err, tt.err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeWith: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeConflictNamesSources(t *testing.T) {
+	_, err := Merge(
+		Named("web team", FromMap(map[string]string{"/docs": "https://docs.example.com"})),
+		FromMap(map[string]string{"/go": "https://go.dev"}),
+		Named("docs team", FromMap(map[string]string{"/docs": "https://wiki.example.com"})),
+	)
+	wantError(t, err, "web team")
+	wantError(t, err, "docs team")
+
+	// a source that fails to load is named too
+	_, err = Merge(Named("broken.json", FromJSON([]byte(`[{"path": `))))
+	wantError(t, err, "broken.json")
+}
+
+func TestMergedHandler(t *testing.T) {
+	h, err := MergedHandler(urlshorttest.Fallback(),
+		FromYAML([]byte("- path: /gh\n  url: https://github.com\n  code: 301\n")),
+		FromJSON([]byte(`[{"path": "/go", "url": "https://go.dev"}]`)),
+		FromMap(map[string]string{"/docs": "https://docs.example.com"}),
+	)
+	if err != nil {
+		t.Fatalf("MergedHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// the code of the entry is kept
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	_, err = MergedHandler(urlshorttest.Fallback(),
+		FromMap(map[string]string{"/gh": "https://github.com"}),
+		FromMap(map[string]string{"/gh": "https://gitlab.com"}),
+	)
+	wantError(t, err, "/gh")
+}
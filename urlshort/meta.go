@@ -0,0 +1,42 @@
+package urlshort
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// how redirect mode entries send the visitor on, see pathUrl.Redirect
+const (
+	redirectHTTP = "http"
+	redirectMeta = "meta"
+)
+
+// checkRedirect makes sure the redirect style of pu is known and
+// that pu actually redirects
+func checkRedirect(pu *pathUrl) error {
+	switch pu.Redirect {
+	case "", redirectHTTP:
+		return nil
+	case redirectMeta:
+		if pu.Mode != "" && pu.Mode != modeRedirect {
+			return fmt.Errorf("urlshort: %s: redirect meta only works for mode redirect, not %s", pu.Path, pu.Mode)
+		}
+		return nil
+	default:
+		return fmt.Errorf("urlshort: %s: unknown redirect %q (use http or meta)", pu.Path, pu.Redirect)
+	}
+}
+
+// serveMetaRefresh answers with a page that moves on to dest right
+// away, for clients that drop the Location of cross-origin redirects
+func serveMetaRefresh(w http.ResponseWriter, dest string) {
+	escaped := html.EscapeString(dest)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<meta http-equiv="refresh" content="0;url=%s">
+<a href="%s">%s</a>
+`, escaped, escaped, escaped)
+}
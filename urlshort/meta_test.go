@@ -0,0 +1,84 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestMetaRefresh(t *testing.T) {
+	data := `
+- path: /portal
+  url: https://sso.example.com/portal?next=/home&lang=de
+  redirect: meta
+- path: /quote
+  url: https://sso.example.com/it's
+  redirect: meta
+- path: /u/:name
+  url: https://sso.example.com/u/:name
+  redirect: meta
+- path: /gh
+  url: https://github.com
+- path: /explicit
+  url: https://github.com/explicit
+  redirect: http
+`
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/portal", `<!DOCTYPE html>
+<meta http-equiv="refresh" content="0;url=https://sso.example.com/portal?next=/home&amp;lang=de">
+<a href="https://sso.example.com/portal?next=/home&amp;lang=de">https://sso.example.com/portal?next=/home&amp;lang=de</a>
+`},
+		{"/quote", `<!DOCTYPE html>
+<meta http-equiv="refresh" content="0;url=https://sso.example.com/it&#39;s">
+<a href="https://sso.example.com/it&#39;s">https://sso.example.com/it&#39;s</a>
+`},
+		{"/u/%3Cb%3E", `<!DOCTYPE html>
+<meta http-equiv="refresh" content="0;url=https://sso.example.com/u/%3Cb%3E">
+<a href="https://sso.example.com/u/%3Cb%3E">https://sso.example.com/u/%3Cb%3E</a>
+`},
+	}
+	for _, tt := range tests {
+		res := get(h, tt.path)
+		if res.Code != http.StatusOK || res.Header().Get("Location") != "" {
+			t.Errorf("GET %s: got %d to %q, want 200 without a Location", tt.path, res.Code, res.Header().Get("Location"))
+		}
+		if ct := res.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("GET %s: Content-Type %q", tt.path, ct)
+		}
+		if got := res.Body.String(); got != tt.want {
+			t.Errorf("GET %s: body\n%s\nwant\n%s", tt.path, got, tt.want)
+		}
+	}
+
+	// the other entries of the file still redirect
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/explicit", Location: "https://github.com/explicit", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+}
+
+func TestMetaRefreshErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"unknown", "- path: /a\n  url: https://a.example.com\n  redirect: js\n", `unknown redirect "js" (use http or meta)`},
+		{"rewrite", "- path: /a\n  url: /b\n  mode: rewrite\n  redirect: meta\n", "redirect meta only works for mode redirect, not rewrite"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
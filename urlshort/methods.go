@@ -0,0 +1,49 @@
+package urlshort
+
+import "net/http"
+
+// OptionsPolicy decides what happens to OPTIONS requests for mapped
+// paths
+type OptionsPolicy int
+
+const (
+	// OptionsFallback passes them to the fallback untouched, the
+	// default, so CORS preflights are answered by whatever answers
+	// them for the rest of the site
+	OptionsFallback OptionsPolicy = iota
+	// OptionsAllow answers them with 204 No Content and an Allow
+	// header listing GET and HEAD
+	OptionsAllow
+)
+
+// allowedMethods is the Allow header of OptionsAllow
+const allowedMethods = "GET, HEAD"
+
+// WithOptionsPolicy sets what happens to OPTIONS requests for mapped
+// paths, which are never redirected. Rewrite and proxy entries get
+// them like every other method.
+func WithOptionsPolicy(p OptionsPolicy) Option {
+	return func(c *config) {
+		c.optionsPolicy = p
+	}
+}
+
+// serveOptions answers an OPTIONS request for a mapped path
+func serveOptions(w http.ResponseWriter, r *http.Request, fallback http.Handler, cfg *config) {
+	if cfg.optionsPolicy == OptionsAllow {
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	fallback.ServeHTTP(w, r)
+}
+
+// headWriter drops the body of responses to HEAD requests, which
+// http.Redirect and the pages write like for GET
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (hw headWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
@@ -0,0 +1,124 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const methodEntries = `
+- path: /gh
+  url: https://github.com
+- path: /wait
+  url: https://example.com/slow
+  mode: interstitial
+- path: /meta
+  url: https://example.com/meta
+  redirect: meta
+- path: /about
+  url: /pages/about.html
+  mode: rewrite
+`
+
+// serveMethod records the response of h to a request of target with
+// the given method
+func serveMethod(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(method, target, nil))
+	return res
+}
+
+func TestHead(t *testing.T) {
+	h, err := YAMLHandler([]byte(methodEntries), echoFallback(), WithRelativeDestinations())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/gh", http.StatusFound, "https://github.com"},
+		{"/wait", http.StatusOK, ""},
+		{"/meta", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		// a GET gets a body, the HEAD the same status and headers
+		// without one
+		getRes := serveMethod(h, http.MethodGet, tt.path)
+		if getRes.Body.Len() == 0 {
+			t.Errorf("GET %s: empty body, the HEAD check below proves nothing", tt.path)
+		}
+		res := serveMethod(h, http.MethodHead, tt.path)
+		if res.Code != tt.code || res.Header().Get("Location") != tt.location {
+			t.Errorf("HEAD %s: got %d to %q, want %d to %q", tt.path, res.Code, res.Header().Get("Location"), tt.code, tt.location)
+		}
+		if res.Body.Len() != 0 {
+			t.Errorf("HEAD %s: got a %d byte body %q, want none", tt.path, res.Body.Len(), res.Body.String())
+		}
+		if got, want := res.Header().Get("Content-Type"), getRes.Header().Get("Content-Type"); got != want {
+			t.Errorf("HEAD %s: Content-Type %q, want %q like the GET", tt.path, got, want)
+		}
+	}
+
+	// rewrites leave HEAD to the fallback
+	res := serveMethod(h, http.MethodHead, "/about")
+	if got := res.Body.String(); got != "/pages/about.html  original=/about" {
+		t.Errorf("HEAD /about: the fallback wrote %q, want the rewritten request", got)
+	}
+}
+
+func TestOptionsPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		code   int
+		allow  string
+		passed bool
+	}{
+		{"default", nil, "/gh", http.StatusNotFound, "", true},
+		{"fallback", []Option{WithOptionsPolicy(OptionsFallback)}, "/gh", http.StatusNotFound, "", true},
+		{"allow", []Option{WithOptionsPolicy(OptionsAllow)}, "/gh", http.StatusNoContent, "GET, HEAD", false},
+		{"allow interstitial", []Option{WithOptionsPolicy(OptionsAllow)}, "/wait", http.StatusNoContent, "GET, HEAD", false},
+		{"allow unmapped", []Option{WithOptionsPolicy(OptionsAllow)}, "/missing", http.StatusNotFound, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(methodEntries), urlshorttest.Fallback(), append(tt.opts, WithRelativeDestinations())...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			res := serveMethod(h, http.MethodOptions, tt.path)
+			if res.Code != tt.code {
+				t.Errorf("OPTIONS %s: got %d, want %d", tt.path, res.Code, tt.code)
+			}
+			if loc := res.Header().Get("Location"); loc != "" {
+				t.Errorf("OPTIONS %s: redirected to %q", tt.path, loc)
+			}
+			if got := res.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("OPTIONS %s: Allow %q, want %q", tt.path, got, tt.allow)
+			}
+			if passed := res.Header().Get("X-Urlshorttest-Fallback") != ""; passed != tt.passed {
+				t.Errorf("OPTIONS %s: passed to the fallback %v, want %v", tt.path, passed, tt.passed)
+			}
+			if !tt.passed && res.Body.Len() != 0 {
+				t.Errorf("OPTIONS %s: got body %q, want none", tt.path, res.Body.String())
+			}
+		})
+	}
+
+	// rewrites get OPTIONS like every other method
+	h, err := YAMLHandler([]byte(methodEntries), echoFallback(), WithRelativeDestinations(), WithOptionsPolicy(OptionsAllow))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	if got := serveMethod(h, http.MethodOptions, "/about").Body.String(); got != "/pages/about.html  original=/about" {
+		t.Errorf("OPTIONS /about: the fallback wrote %q, want the rewritten request", got)
+	}
+
+	_, err = YAMLHandler([]byte(methodEntries), nil, WithRelativeDestinations(), WithOptionsPolicy(OptionsPolicy(7)))
+	wantError(t, err, "unknown OPTIONS policy 7")
+}
@@ -0,0 +1,167 @@
+package urlshort
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// otherPathLabel is used for paths over the WithPathLabelLimit
+const otherPathLabel = "other"
+
+// MetricsOption changes how Instrument labels its metrics
+type MetricsOption func(*metricsConfig)
+
+type metricsConfig struct {
+	maxPaths   int
+	hashLabels bool
+}
+
+// WithPathLabelLimit caps the number of distinct path label values
+// at n. Paths after the first n are reported as "other".
+func WithPathLabelLimit(n int) MetricsOption {
+	return func(c *metricsConfig) {
+		c.maxPaths = n
+	}
+}
+
+// WithHashedPathLabels reports a short hash of the path instead of
+// the path itself, for mapping sets that shouldn't show up in
+// dashboards verbatim.
+func WithHashedPathLabels() MetricsOption {
+	return func(c *metricsConfig) {
+		c.hashLabels = true
+	}
+}
+
+// Instrument wraps handler, which must be one of the handlers of
+// this package, and records Prometheus metrics in reg:
+//
+//	urlshort_redirects_total{path,code}  redirects served
+//	urlshort_fallbacks_total             requests passed to the fallback
+//	urlshort_lookup_errors_total         lookups the store failed, see WithErrorHandler
+//	urlshort_lookup_duration_seconds     time until the lookup finished
+//
+// The path label is the configured path that matched, so wildcard and
+// parameter entries don't add a label value per request. Registering
+// is idempotent, instrumenting several handlers with the same
+// registry shares the collectors. Like prometheus.MustRegister,
+// Instrument panics if other collectors conflict with them.
+func Instrument(handler http.Handler, reg prometheus.Registerer, opts ...MetricsOption) http.Handler {
+	var mc metricsConfig
+	for _, opt := range opts {
+		opt(&mc)
+	}
+
+	redirects, err := register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "urlshort_redirects_total",
+		Help: "Redirects served, by matched path and status code.",
+	}, []string{"path", "code"}))
+	if err != nil {
+		panic(err)
+	}
+	fallbacks, err := register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "urlshort_fallbacks_total",
+		Help: "Requests that didn't match and were passed to the fallback.",
+	}))
+	if err != nil {
+		panic(err)
+	}
+	lookupErrors, err := register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "urlshort_lookup_errors_total",
+		Help: "Requests whose lookup failed because the store returned an error.",
+	}))
+	if err != nil {
+		panic(err)
+	}
+	latency, err := register(reg, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "urlshort_lookup_duration_seconds",
+		Help:    "Time from receiving a request until the lookup finished.",
+		Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+	}))
+	if err != nil {
+		panic(err)
+	}
+
+	labels := &pathLabels{cfg: mc, seen: make(map[string]bool)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, info := withRequestInfo(r)
+
+		handler.ServeHTTP(w, r)
+
+		// the handler didn't tell us anything, e.g. it isn't one of ours
+		if info.decided.IsZero() {
+			return
+		}
+		latency.Observe(info.decided.Sub(start).Seconds())
+		switch {
+		case info.err != nil:
+			lookupErrors.Inc()
+		case info.matched:
+			redirects.WithLabelValues(labels.label(info.path), statusLabel(info.status)).Inc()
+		default:
+			fallbacks.Inc()
+		}
+	})
+}
+
+// register registers c, or returns the collector that was already
+// registered under the same description.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		existing, ok := are.ExistingCollector.(C)
+		if !ok {
+			return c, err
+		}
+		return existing, nil
+	}
+	return c, err
+}
+
+// pathLabels turns paths into label values within the limits
+type pathLabels struct {
+	cfg metricsConfig
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (pl *pathLabels) label(path string) string {
+	if pl.cfg.hashLabels {
+		sum := sha256.Sum256([]byte(path))
+		path = hex.EncodeToString(sum[:6])
+	}
+	if pl.cfg.maxPaths <= 0 {
+		return path
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.seen[path] {
+		return path
+	}
+	if len(pl.seen) >= pl.cfg.maxPaths {
+		return otherPathLabel
+	}
+	pl.seen[path] = true
+	return path
+}
+
+// statusLabel is the code label for status, matches served without
+// a redirect, like rewrites, have none
+func statusLabel(status int) string {
+	if status == 0 {
+		return "none"
+	}
+	return strconv.Itoa(status)
+}
@@ -0,0 +1,129 @@
+package urlshort
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrape gathers reg and returns the value of every counter and the
+// sample count of every histogram, keyed like name{label=value}
+func scrape(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var labels []string
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, lp.GetName()+"="+lp.GetValue())
+			}
+			sort.Strings(labels)
+			key := mf.GetName()
+			if len(labels) > 0 {
+				key += "{" + strings.Join(labels, ",") + "}"
+			}
+			if m.GetHistogram() != nil {
+				values[key] = float64(m.GetHistogram().GetSampleCount())
+				continue
+			}
+			values[key] = m.GetCounter().GetValue()
+		}
+	}
+	return values
+}
+
+func TestInstrument(t *testing.T) {
+	paths := map[string]string{
+		"/gh":     "https://github.com",
+		"/docs/*": "https://docs.example.com",
+		"/u/:id":  "https://example.com/users/:id",
+	}
+	reg := prometheus.NewRegistry()
+	h := Instrument(MapHandler(paths, urlshorttest.Fallback(), WithStatus(http.StatusMovedPermanently)), reg)
+	for _, path := range []string{"/gh", "/gh", "/docs/a", "/docs/b", "/u/1", "/u/2", "/u/3", "/missing"} {
+		get(h, path)
+	}
+
+	got := scrape(t, reg)
+	want := map[string]float64{
+		`urlshort_redirects_total{code=301,path=/gh}`:     2,
+		`urlshort_redirects_total{code=301,path=/docs/*}`: 2,
+		`urlshort_redirects_total{code=301,path=/u/:id}`:  3,
+		`urlshort_fallbacks_total`:                        1,
+		`urlshort_lookup_errors_total`:                    0,
+		`urlshort_lookup_duration_seconds`:                8,
+	}
+	for key, v := range want {
+		if got[key] != v {
+			t.Errorf("%s = %v, want %v", key, got[key], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got metrics %v, want %v", got, want)
+	}
+
+	// registering again shares the collectors
+	other := Instrument(MapHandler(paths, urlshorttest.Fallback()), reg)
+	get(other, "/missing")
+	if got := scrape(t, reg)["urlshort_fallbacks_total"]; got != 2 {
+		t.Errorf("urlshort_fallbacks_total = %v after the second handler, want 2", got)
+	}
+}
+
+func TestInstrumentPathLabels(t *testing.T) {
+	paths := map[string]string{
+		"/a": "https://a.example.com",
+		"/b": "https://b.example.com",
+		"/c": "https://c.example.com",
+	}
+	tests := []struct {
+		name string
+		opts []MetricsOption
+		want []string
+	}{
+		{"limit", []MetricsOption{WithPathLabelLimit(2)}, []string{"path=/a", "path=/b", "path=other"}},
+		// the first 6 bytes of the sha256 of /a, /b and /c
+		{"hashed", []MetricsOption{WithHashedPathLabels()}, []string{"path=6a50dc858413", "path=9812b0b9d61e", "path=facd442ef630"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			h := Instrument(MapHandler(paths, urlshorttest.Fallback()), reg, tt.opts...)
+			for _, path := range []string{"/a", "/b", "/c", "/a"} {
+				get(h, path)
+			}
+			var labels []string
+			for key := range scrape(t, reg) {
+				if rest, ok := strings.CutPrefix(key, "urlshort_redirects_total{code=302,"); ok {
+					labels = append(labels, strings.TrimSuffix(rest, "}"))
+				}
+			}
+			sort.Strings(labels)
+			if strings.Join(labels, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("got labels %v, want %v", labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentLookupErrors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	db := openSQL(t, nil)
+	sh, err := SQLHandler(db, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	get(Instrument(sh, reg), "/gh")
+	if got := scrape(t, reg)["urlshort_lookup_errors_total"]; got != 1 {
+		t.Errorf("urlshort_lookup_errors_total = %v, want 1", got)
+	}
+}
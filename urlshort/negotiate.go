@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"strconv"
+	"strings"
+)
+
+// qValue returns the q parameter of the parameters of a header
+// element like "en;q=0.8", 1 if it has none or an invalid one
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && v >= 0 && v <= 1 {
+				return v
+			}
+		}
+	}
+	return 1
+}
+
+// acceptQuality returns the q-value the Accept headers give
+// mediaType, like "application/json". The most specific range that
+// matches counts (RFC 9110, section 12.5.1), without Accept headers
+// everything is acceptable.
+func acceptQuality(headers []string, mediaType string) float64 {
+	if len(headers) == 0 {
+		return 1
+	}
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			rng, params, _ := strings.Cut(part, ";")
+			rng = strings.ToLower(strings.TrimSpace(rng))
+			s := -1
+			switch {
+			case rng == mediaType:
+				s = 2
+			case rng == typ+"/*":
+				s = 1
+			case rng == "*/*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = qValue(params), s
+			}
+		}
+	}
+	return q
+}
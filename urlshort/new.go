@@ -0,0 +1,36 @@
+package urlshort
+
+import "net/http"
+
+// New builds a Handler serving the mappings of source, e.g.
+//
+//	h, err := urlshort.New(urlshort.FromYAML(data), fallback, urlshort.WithStatus(http.StatusMovedPermanently))
+//
+// It is what MapHandler, YAMLHandler and JSONHandler use, so the
+// mappings are read and checked the same way. Invalid or
+// conflicting options are an error, as are the errors of source.
+// If a path isn't mapped, the fallback http.Handler will be called
+// instead, a nil fallback answers 404 Not Found, see also
+// WithDefaultDestination.
+func New(source Source, fallback http.Handler, opts ...Option) (*Handler, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+	pathUrls, err := source.load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := source.build(pathUrls, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handler{fallback: fallback, cfg: cfg, source: source.Name()}
+	h.store(routes)
+	return h, nil
+}
@@ -0,0 +1,114 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestNewSources(t *testing.T) {
+	const (
+		yamlData = "- path: /gh\n  url: https://github.com\n- path: /go\n  url: https://go.dev?x=1\n"
+		jsonData = `[{"path": "/gh", "url": "https://github.com"}, {"path": "/go", "url": "https://go.dev?x=1"}]`
+		hclData  = "redirect \"/gh\" {\n  url = \"https://github.com\"\n}\nredirect \"/go\" {\n  url = \"https://go.dev?x=1\"\n}\n"
+	)
+	dir := t.TempDir()
+	files := map[string]string{"paths.yaml": yamlData, "paths.json": jsonData, "paths.hcl": hclData}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sources := map[string]Source{
+		"yaml":      FromYAML([]byte(yamlData)),
+		"json":      FromJSON([]byte(jsonData)),
+		"hcl":       FromHCL([]byte(hclData)),
+		"map":       FromMap(map[string]string{"/gh": "https://github.com", "/go": "https://go.dev?x=1"}),
+		"yaml file": FromFile(filepath.Join(dir, "paths.yaml")),
+		"json file": FromFile(filepath.Join(dir, "paths.json")),
+		"hcl file":  FromFile(filepath.Join(dir, "paths.hcl")),
+	}
+	options := []struct {
+		name  string
+		opts  []Option
+		cases []urlshorttest.Case
+	}{
+		{"defaults", nil, []urlshorttest.Case{
+			{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+			{Path: "/go?y=2", Location: "https://go.dev?x=1", Code: http.StatusFound},
+			{Path: "/missing"},
+		}},
+		{"permanent", []Option{WithStatus(http.StatusMovedPermanently)}, []urlshorttest.Case{
+			{Path: "/gh", Location: "https://github.com", Code: http.StatusMovedPermanently},
+			{Path: "/missing"},
+		}},
+		{"query forwarding", []Option{WithQueryForwarding()}, []urlshorttest.Case{
+			{Path: "/go?y=2", Location: "https://go.dev?x=1&y=2", Code: http.StatusFound},
+			{Path: "/gh?utm=1", Location: "https://github.com?utm=1", Code: http.StatusFound},
+		}},
+		{"prefix", []Option{WithPathPrefix("/s")}, []urlshorttest.Case{
+			{Path: "/s/gh", Location: "https://github.com", Code: http.StatusFound},
+			{Path: "/gh"},
+		}},
+	}
+	for name, source := range sources {
+		for _, o := range options {
+			t.Run(name+"/"+o.name, func(t *testing.T) {
+				h, err := New(source, urlshorttest.Fallback(), o.opts...)
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				urlshorttest.TableTest(t, h, o.cases)
+			})
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	yaml := FromYAML([]byte("- path: /gh\n  url: https://github.com\n"))
+	tests := []struct {
+		name     string
+		source   Source
+		fallback http.Handler
+		opts     []Option
+		want     string
+	}{
+		{"not a redirect", yaml, nil, []Option{WithStatus(http.StatusOK)}, "status 200 is not a redirect (3xx) code"},
+		{"query allow without forwarding", yaml, nil, []Option{WithQueryAllow("q")}, "WithQueryAllow and WithQueryStrip need WithQueryForwarding"},
+		{"confirmation key alone", yaml, nil, []Option{WithConfirmationKey([]byte("k"))}, "WithConfirmationKey needs WithExternalConfirmation"},
+		{"flattening without self hosts", yaml, nil, []Option{WithChainFlattening(3)}, "chain flattening needs WithSelfHosts"},
+		{"default destination and fallback", yaml, urlshorttest.Fallback(),
+			[]Option{WithDefaultDestination("https://example.com")}, "WithDefaultDestination replaces the fallback, pass a nil one"},
+		{"negative cache ttl", yaml, nil, []Option{WithCache(-1)}, "cache ttl -1ns is negative"},
+		{"bad source", FromYAML([]byte("- path: /gh\n  url: [\n")), nil, nil, "yaml"},
+		{"missing file", FromFile(filepath.Join(t.TempDir(), "missing.yaml")), nil, nil, "missing.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(tt.source, tt.fallback, tt.opts...)
+			wantError(t, err, tt.want)
+			if h != nil {
+				t.Errorf("New returned a handler with the error %v", err)
+			}
+		})
+	}
+
+	// the old constructors check options the same way
+	_, err := YAMLHandler([]byte("- path: /gh\n  url: https://github.com\n"), nil, WithStatus(http.StatusOK))
+	wantError(t, err, "status 200 is not a redirect (3xx) code")
+	_, err = JSONHandler([]byte(`[]`), nil, WithQueryStrip("q"))
+	wantError(t, err, "need WithQueryForwarding")
+}
+
+func TestNewNilFallback(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if res := get(h, "/missing"); res.Code != http.StatusNotFound {
+		t.Errorf("GET /missing with a nil fallback: got %d, want 404", res.Code)
+	}
+}
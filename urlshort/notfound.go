@@ -0,0 +1,178 @@
+package urlshort
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+// NotFoundOption changes the page served by NotFoundHandler
+type NotFoundOption func(*notFound)
+
+// NotFoundData is what the not found template is executed with
+type NotFoundData struct {
+	// Path is the short link that doesn't exist
+	Path string
+	// Home is the link back to the home page, may be empty
+	Home string
+	// Suggestions are existing paths close to Path
+	Suggestions []string
+}
+
+// defaultNotFoundTemplate is the page NotFoundHandler serves unless
+// NotFoundTemplate replaces it
+var defaultNotFoundTemplate = template.Must(template.New("notfound").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Short link not found</title></head>
+<body>
+<h1>Short link not found</h1>
+<p>The short link <code>{{.Path}}</code> doesn't exist.</p>
+{{- if .Suggestions}}
+<p>Did you mean:</p>
+<ul>
+{{- range .Suggestions}}
+<li><a href="{{.}}">{{.}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Home}}
+<p><a href="{{.Home}}">Go to the home page</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+type notFound struct {
+	tmpl        *template.Template
+	home        string
+	routes      RouteLister
+	suggestions int
+}
+
+// NotFoundTemplate replaces the default page. The template is
+// executed with a NotFoundData.
+func NotFoundTemplate(t *template.Template) NotFoundOption {
+	return func(nf *notFound) {
+		nf.tmpl = t
+	}
+}
+
+// NotFoundHome adds a link to url to the page
+func NotFoundHome(url string) NotFoundOption {
+	return func(nf *notFound) {
+		nf.home = url
+	}
+}
+
+// NotFoundSuggestions lists up to n of the configured paths of
+// routes that are closest to the requested path. Without it, the
+// page lists the suggestions of a handler using WithSuggestions.
+func NotFoundSuggestions(routes RouteLister, n int) NotFoundOption {
+	return func(nf *notFound) {
+		nf.routes, nf.suggestions = routes, n
+	}
+}
+
+// NotFoundResponse is the body of the 404 NotFoundHandler answers
+// clients preferring JSON with:
+//
+//	{"error": "not_found", "path": "/gihub", "suggestions": ["/github"]}
+type NotFoundResponse struct {
+	// Error is always "not_found"
+	Error string `json:"error"`
+	Path  string `json:"path"`
+	// Suggestions are existing paths close to Path, never null
+	Suggestions []string `json:"suggestions"`
+}
+
+// NotFoundHandler returns an http.Handler that answers every request
+// with a 404 page saying the short link doesn't exist. It's meant to
+// be the fallback of MapHandler and the other handlers. Requests
+// whose Accept header prefers application/json over text/html get a
+// NotFoundResponse instead, browsers and everything else the page.
+func NotFoundHandler(opts ...NotFoundOption) http.Handler {
+	nf := &notFound{tmpl: defaultNotFoundTemplate}
+	for _, opt := range opts {
+		opt(nf)
+	}
+	return nf
+}
+
+func (nf *notFound) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data := NotFoundData{Path: r.URL.Path, Home: nf.home}
+	if nf.routes != nil && nf.suggestions > 0 {
+		data.Suggestions = closestPaths(r.URL.Path, nf.routes.Routes(), nf.suggestions)
+	} else {
+		data.Suggestions = SuggestionsFromContext(r.Context())
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Add("Vary", "Accept")
+	if prefersJSON(r) {
+		if data.Suggestions == nil {
+			data.Suggestions = []string{}
+		}
+		writeJSON(w, http.StatusNotFound, NotFoundResponse{Error: "not_found", Path: data.Path, Suggestions: data.Suggestions})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	nf.tmpl.Execute(w, data)
+}
+
+// prefersJSON reports whether the Accept headers of r rank JSON
+// above HTML, ties go to HTML
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Values("Accept")
+	return acceptQuality(accept, "application/json") > acceptQuality(accept, "text/html")
+}
+
+// closestPaths returns the n paths of routes with the smallest edit
+// distance to path, ties are broken alphabetically.
+func closestPaths(path string, routes map[string]string, n int) []string {
+	type candidate struct {
+		path     string
+		distance int
+	}
+	candidates := make([]candidate, 0, len(routes))
+	for p := range routes {
+		candidates = append(candidates, candidate{p, levenshtein(path, p)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].path < candidates[j].path
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	paths := make([]string, len(candidates))
+	for i, c := range candidates {
+		paths[i] = c.path
+	}
+	return paths
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
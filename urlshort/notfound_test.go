@@ -0,0 +1,193 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNotFoundNegotiation(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/github": "https://github.com", "/gitlab": "https://gitlab.com"}),
+		NotFoundHandler(), WithSuggestions())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	const browser = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	tests := []struct {
+		name   string
+		accept []string
+		json   bool
+	}{
+		{"no Accept", nil, false},
+		{"anything", []string{"*/*"}, false},
+		{"a browser", []string{browser}, false},
+		{"JSON", []string{"application/json"}, true},
+		{"JSON with a charset", []string{"application/json; charset=utf-8"}, true},
+		{"case", []string{"Application/JSON"}, true},
+		{"JSON before anything else", []string{"application/json, */*;q=0.5"}, true},
+		{"HTML ranked lower", []string{"text/html;q=0.5, application/json"}, true},
+		{"JSON ranked lower", []string{"application/json;q=0.5, text/html"}, false},
+		// ties go to HTML
+		{"a tie", []string{"application/json, text/html"}, false},
+		{"a tie in q-values", []string{"text/html;q=0.7, application/json;q=0.7"}, false},
+		// the most specific range counts
+		{"application/*", []string{"application/*, text/*;q=0.5"}, true},
+		{"JSON refused", []string{"application/json;q=0, */*"}, false},
+		{"over several headers", []string{"text/html;q=0.1", "application/json"}, true},
+		{"invalid q", []string{"application/json;q=2, text/html;q=0.5"}, true},
+		{"unrelated types", []string{"image/png"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Accept": tt.accept}
+			res := requestFrom(h, "/gihub", "198.51.100.1:5000", header)
+			if res.Code != http.StatusNotFound {
+				t.Errorf("got %d, want 404", res.Code)
+			}
+			if vary := res.Header().Values("Vary"); !containsValue(vary, "Accept") {
+				t.Errorf("got Vary %v", vary)
+			}
+			if !tt.json {
+				if got := res.Header().Get("Content-Type"); got != "text/html; charset=utf-8" || !strings.Contains(res.Body.String(), "/github") {
+					t.Errorf("got %q: %s", got, res.Body.String())
+				}
+				return
+			}
+			if got := res.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("got Content-Type %q", got)
+			}
+			// /gitlab is too far off to suggest
+			if got, want := res.Body.String(), `{"error":"not_found","path":"/gihub","suggestions":["/github"]}`+"\n"; got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// containsValue reports whether one of the comma separated header
+// values is value
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestNotFoundJSON(t *testing.T) {
+	header := http.Header{"Accept": {"application/json"}}
+	// suggestions are a list even when there are none
+	res := requestFrom(NotFoundHandler(), "/nothing-like-it", "198.51.100.1:5000", header)
+	var body map[string]any
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", res.Body.String(), err)
+	}
+	if suggestions, ok := body["suggestions"].([]any); !ok || len(suggestions) != 0 {
+		t.Errorf("got suggestions %#v", body["suggestions"])
+	}
+
+	// NotFoundSuggestions works for JSON too
+	store := NewMutableStore()
+	store.Set("/docs", "https://docs.example.com")
+	res = requestFrom(NotFoundHandler(NotFoundSuggestions(store, 3)), "/dcos", "198.51.100.1:5000", header)
+	var resp NotFoundResponse
+	if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", res.Body.String(), err)
+	}
+	if resp.Error != "not_found" || resp.Path != "/dcos" || len(resp.Suggestions) != 1 || resp.Suggestions[0] != "/docs" {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/docs", "https://docs.example.com")
+	store.Set("/dogs", "https://dogs.example.com")
+	store.Set("/go", "https://go.dev")
+	custom := template.Must(template.New("custom").Parse(`{{.Path}} by {{.Home}}: {{range .Suggestions}}{{.}} {{end}}`))
+
+	tests := []struct {
+		name    string
+		opts    []NotFoundOption
+		want    []string
+		notWant []string
+	}{
+		{"default", nil, []string{"<code>/dcos</code> doesn't exist"}, []string{"Did you mean", "home page"}},
+		{"home", []NotFoundOption{NotFoundHome("https://example.com/")},
+			[]string{`<a href="https://example.com/">Go to the home page</a>`}, nil},
+		{"suggestions", []NotFoundOption{NotFoundSuggestions(store, 2)},
+			[]string{"Did you mean", `<li><a href="/docs">/docs</a></li>`, `<li><a href="/dogs">/dogs</a></li>`}, []string{"/go"}},
+		{"custom template", []NotFoundOption{NotFoundTemplate(custom), NotFoundHome("https://example.com/"), NotFoundSuggestions(store, 1)},
+			[]string{"/dcos by https://example.com/: /docs "}, []string{"<html>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := get(NotFoundHandler(tt.opts...), "/dcos")
+			if res.Code != http.StatusNotFound {
+				t.Errorf("got %d, want 404", res.Code)
+			}
+			if got := res.Header().Get("Cache-Control"); got != "no-cache, no-store, must-revalidate" {
+				t.Errorf("got Cache-Control %q", got)
+			}
+			body := res.Body.String()
+			for _, s := range tt.want {
+				if !strings.Contains(body, s) {
+					t.Errorf("the page has no %q:\n%s", s, body)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(body, s) {
+					t.Errorf("the page has %q:\n%s", s, body)
+				}
+			}
+		})
+	}
+}
+
+func TestClosestPaths(t *testing.T) {
+	routes := map[string]string{"/docs": "", "/dogs": "", "/dots": "", "/go": "", "/github": ""}
+	tests := []struct {
+		path string
+		n    int
+		want []string
+	}{
+		// ties are alphabetical
+		{"/dos", 3, []string{"/docs", "/dogs", "/dots"}},
+		{"/dos", 1, []string{"/docs"}},
+		{"/gihub", 2, []string{"/github", "/go"}},
+		{"/x", 10, []string{"/go", "/docs", "/dogs", "/dots", "/github"}},
+		{"/x", 0, []string{}},
+	}
+	for _, tt := range tests {
+		if got := closestPaths(tt.path, routes, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("closestPaths(%q, %d): got %v, want %v", tt.path, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"/github", "/gihub", 1},
+		{"/café", "/cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
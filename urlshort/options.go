@@ -0,0 +1,301 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option changes the behavior of the handlers created by New,
+// MapHandler, YAMLHandler and friends.
+type Option func(*config)
+
+// config holds everything the options can change
+type config struct {
+	// status is used for entries without their own code
+	status int
+	// forwardQuery appends the request query to the destination,
+	// the parameters left after queryAllow and queryStrip
+	forwardQuery bool
+	queryAllow   []string
+	queryStrip   []string
+	// foldCase makes path matching case-insensitive
+	foldCase bool
+	// slashes makes /x and /x/ match the same entry
+	slashes bool
+	// duplicates decides what happens to paths configured twice
+	duplicates DuplicatePolicy
+	// orderedMatching tries pattern entries in declaration order,
+	// longestPrefix only asks for the default explicitly
+	orderedMatching bool
+	longestPrefix   bool
+	// optionsPolicy decides what happens to OPTIONS for mapped paths
+	optionsPolicy OptionsPolicy
+	// strict rejects unknown keys and incomplete entries in YAML
+	strict bool
+	// collisions decides between files composed with include:
+	collisions CollisionPolicy
+	// suggest finds close paths on a miss, autoCorrect redirects to
+	// the closest one if it's unambiguous
+	suggest     bool
+	autoCorrect bool
+	// cacheTTL is how long backend lookups are cached, zero disables it
+	cacheTTL time.Duration
+	// cacheSize is the most paths the cache holds, zero means no limit
+	cacheSize int
+	// pathPrefix is stripped from request paths before the lookup
+	pathPrefix string
+	// defaultDest is where WithDefaultDestination sends misses
+	defaultDest string
+	// wildcardApex makes *.domain hosts match domain too
+	wildcardApex bool
+	// bloomRate is the false positive rate of WithBloomFilter, zero
+	// builds no filter
+	bloomRate float64
+	// unavailableOnError answers 503 instead of serving the fallback
+	// when a store fails, errorHandler takes precedence over it
+	unavailableOnError bool
+	errorHandler       ErrorHandler
+	// pollInterval is how often watched files are checked for changes
+	pollInterval time.Duration
+	// sweepInterval is how often expired entries are dropped, zero never
+	sweepInterval time.Duration
+	// proxyTimeout limits connecting to and waiting for proxy upstreams
+	proxyTimeout time.Duration
+
+	// stats counts hits and misses, nil means no counting
+	stats *Stats
+
+	// hooks called for redirects, misses and disabled entries, see
+	// hooks.go
+	redirectHook  RedirectHook
+	missHook      MissHook
+	disableHook   DisableHook
+	hookWorkers   int
+	hookQueueSize int
+	hookQueue     *hookQueue
+	// webhooks get an event for every redirect, webhookErr is why a
+	// WithWebhook couldn't start its webhook
+	webhooks   []*Webhook
+	webhookErr error
+	// recoverLog gets the panics WithRecovery recovers
+	recoverLog PanicLogger
+	// accessLog writes a line per request, nil writes none
+	accessLog *accessLog
+	// geoHeader and geoLookup find the country of geo entries
+	geoHeader string
+	geoLookup GeoLookup
+	// classifyDevice sorts requests for device entries
+	classifyDevice func(*http.Request) string
+	// trustedProxies are parsed from proxyList by newConfig
+	proxyList      []string
+	trustedProxies []*net.IPNet
+
+	// schemes destinations may use, allowRelative also permits
+	// destinations without scheme and host
+	schemes       []string
+	allowRelative bool
+	// unicodeHosts keeps destination hosts out of punycode
+	unicodeHosts bool
+
+	// headers are added to every redirect
+	headers map[string]string
+	// token protects every entry without a token of its own
+	token string
+	// utm are the UTM parameters added to every redirect, see WithUTM
+	utm map[string]string
+
+	// selfHosts are the hosts the handler serves, for finding loops
+	selfHosts map[string]bool
+	// maxHops is how often a request can be rewritten
+	maxHops int
+	// clockSkew is how long signed links work past their expiry
+	clockSkew time.Duration
+	// flattenDepth is the longest chain flattened, zero disables it
+	flattenDepth int
+
+	// confirm asks before redirecting to other sites, nil never
+	confirm *confirmation
+
+	// interstitial is the page of interstitial entries
+	interstitial *template.Template
+
+	// picker chooses the destination of weighted entries
+	picker *weightedPicker
+
+	// defaultScheme is put in front of destinations without one
+	defaultScheme string
+	// redirectCaching sets Cache-Control on redirects, permanent
+	// ones may be cached for redirectMaxAge
+	redirectCaching bool
+	redirectMaxAge  time.Duration
+	// tracer creates the spans of WithTracing, nil traces nothing
+	tracer trace.Tracer
+
+	// now is the clock used for everything time related
+	now func() time.Time
+}
+
+// WithStatus sets the redirect status code used for all entries
+// that don't specify their own. It must be a 3xx code, the
+// default is http.StatusFound. Requests other than GET and HEAD
+// get 307 instead, or 308 when code is 301 or 308.
+func WithStatus(code int) Option {
+	return func(c *config) {
+		c.status = code
+	}
+}
+
+// WithCaseInsensitivePaths makes paths match regardless of case,
+// so /GH and /Gh find the entry for /gh. Configured paths that only
+// differ in case are duplicates (see WithDuplicatePolicy). Values
+// of path parameters keep the case they were requested with.
+func WithCaseInsensitivePaths() Option {
+	return func(c *config) {
+		c.foldCase = true
+	}
+}
+
+// WithSlashNormalization treats /x and /x/ as the same path when
+// looking up exact and parameter entries. Configuring both forms
+// makes them duplicates (see WithDuplicatePolicy). The root path
+// is never changed.
+func WithSlashNormalization() Option {
+	return func(c *config) {
+		c.slashes = true
+	}
+}
+
+// defaultConfig returns the config used when there are no options
+func defaultConfig() *config {
+	return &config{
+		status:       http.StatusFound,
+		pollInterval: time.Second,
+		proxyTimeout: defaultProxyTimeout,
+		maxHops:      defaultMaxHops,
+		clockSkew:    defaultClockSkew,
+		picker:       newWeightedPicker(),
+		interstitial: defaultInterstitialTemplate,
+		schemes:      []string{"http", "https"},
+		now:          time.Now,
+
+		classifyDevice: classifyUserAgent,
+	}
+}
+
+// newConfig applies opts on top of the defaults and makes sure the
+// result is usable, so mistakes show up when the handler is built
+// and not when the first request comes in.
+func newConfig(opts []Option) (*config, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.webhookErr != nil {
+		return nil, cfg.webhookErr
+	}
+	if cfg.status < 300 || cfg.status > 399 {
+		return nil, fmt.Errorf("urlshort: status %d is not a redirect (3xx) code", cfg.status)
+	}
+	if cfg.duplicates < DuplicateError || cfg.duplicates > LastWins {
+		return nil, fmt.Errorf("urlshort: unknown duplicate policy %d", cfg.duplicates)
+	}
+	if err := cfg.checkMatching(); err != nil {
+		return nil, err
+	}
+	if cfg.optionsPolicy < OptionsFallback || cfg.optionsPolicy > OptionsAllow {
+		return nil, fmt.Errorf("urlshort: unknown OPTIONS policy %d", cfg.optionsPolicy)
+	}
+	if cfg.cacheTTL < 0 {
+		return nil, fmt.Errorf("urlshort: cache ttl %v is negative", cfg.cacheTTL)
+	}
+	if cfg.cacheSize < 0 {
+		return nil, fmt.Errorf("urlshort: cache size %d is negative", cfg.cacheSize)
+	}
+	if err := checkPathPrefix(cfg.pathPrefix); err != nil {
+		return nil, err
+	}
+	if cfg.defaultDest != "" {
+		if reason := destinationIssue(cfg.defaultDest, cfg); reason != "" {
+			return nil, fmt.Errorf("urlshort: default destination: %s", reason)
+		}
+	}
+	if cfg.bloomRate < 0 || cfg.bloomRate >= 1 {
+		return nil, fmt.Errorf("urlshort: bloom filter false positive rate %v must be between 0 and 1", cfg.bloomRate)
+	}
+	if cfg.redirectMaxAge < 0 {
+		return nil, fmt.Errorf("urlshort: redirect max age %v is negative", cfg.redirectMaxAge)
+	}
+	if cfg.sweepInterval < 0 {
+		return nil, fmt.Errorf("urlshort: sweep interval %v is negative", cfg.sweepInterval)
+	}
+	if cfg.pollInterval <= 0 {
+		return nil, fmt.Errorf("urlshort: poll interval %v must be positive", cfg.pollInterval)
+	}
+	if name, ok := forbiddenHeader(cfg.headers); ok {
+		return nil, fmt.Errorf("urlshort: the %s header can't be set, the redirect sets it", name)
+	}
+	if cfg.confirm != nil {
+		if err := cfg.confirm.prepare(); err != nil {
+			return nil, err
+		}
+	}
+	if (len(cfg.queryAllow) > 0 || len(cfg.queryStrip) > 0) && !cfg.forwardQuery {
+		return nil, errors.New("urlshort: WithQueryAllow and WithQueryStrip need WithQueryForwarding")
+	}
+	if cfg.flattenDepth < 0 {
+		return nil, fmt.Errorf("urlshort: chain flattening depth %d is negative", cfg.flattenDepth)
+	}
+	if cfg.flattenDepth > 0 && len(cfg.selfHosts) == 0 {
+		return nil, errors.New("urlshort: chain flattening needs WithSelfHosts")
+	}
+	if cfg.maxHops < 1 {
+		return nil, fmt.Errorf("urlshort: max hops %d must be at least 1", cfg.maxHops)
+	}
+	if cfg.clockSkew < 0 {
+		return nil, fmt.Errorf("urlshort: clock skew %v is negative", cfg.clockSkew)
+	}
+	if cfg.classifyDevice == nil {
+		return nil, errors.New("urlshort: device classifier is nil")
+	}
+	if cfg.interstitial == nil {
+		return nil, errors.New("urlshort: interstitial template is nil")
+	}
+	if cfg.proxyTimeout <= 0 {
+		return nil, fmt.Errorf("urlshort: proxy timeout %v must be positive", cfg.proxyTimeout)
+	}
+	if len(cfg.schemes) == 0 {
+		return nil, errors.New("urlshort: at least one url scheme must be allowed")
+	}
+	if cfg.defaultScheme != "" && !schemeAllowed(cfg.defaultScheme, cfg) {
+		return nil, fmt.Errorf("urlshort: default scheme %q is not one of the allowed schemes", cfg.defaultScheme)
+	}
+	proxies, err := parseNetworks(cfg.proxyList)
+	if err != nil {
+		return nil, err
+	}
+	cfg.trustedProxies = proxies
+	if cfg.confirm != nil && !cfg.confirm.enabled {
+		return nil, errors.New("urlshort: WithConfirmationKey needs WithExternalConfirmation")
+	}
+	if err := cfg.validateHooks(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// mustConfig is newConfig for constructors that can't return an error
+func mustConfig(opts []Option) *config {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
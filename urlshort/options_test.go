@@ -0,0 +1,178 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestWithStatus(t *testing.T) {
+	paths := map[string]string{"/gh": "https://github.com"}
+	yamlData := []byte("- path: /gh\n  url: https://github.com\n- path: /go\n  url: https://go.dev\n  code: 307\n")
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{"default", nil, http.StatusFound},
+		{"moved permanently", []Option{WithStatus(http.StatusMovedPermanently)}, http.StatusMovedPermanently},
+		{"permanent redirect", []Option{WithStatus(http.StatusPermanentRedirect)}, http.StatusPermanentRedirect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlshorttest.TableTest(t, MapHandler(paths, urlshorttest.Fallback(), tt.opts...), []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: tt.want},
+				{Path: "/missing"},
+			})
+
+			h, err := YAMLHandler(yamlData, urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: tt.want},
+				// the code of the entry wins
+				{Path: "/go", Location: "https://go.dev", Code: http.StatusTemporaryRedirect},
+			})
+		})
+	}
+}
+
+func TestWithStatusMethods(t *testing.T) {
+	tests := []struct {
+		status int
+		method string
+		want   int
+	}{
+		{http.StatusFound, http.MethodHead, http.StatusFound},
+		{http.StatusFound, http.MethodPost, http.StatusTemporaryRedirect},
+		{http.StatusMovedPermanently, http.MethodPost, http.StatusPermanentRedirect},
+		{http.StatusPermanentRedirect, http.MethodDelete, http.StatusPermanentRedirect},
+	}
+	for _, tt := range tests {
+		h := MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback(), WithStatus(tt.status))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(tt.method, "/gh", nil))
+		if res.Code != tt.want {
+			t.Errorf("WithStatus(%d), %s: got %d, want %d", tt.status, tt.method, res.Code, tt.want)
+		}
+	}
+}
+
+func TestWithStatusInvalid(t *testing.T) {
+	for _, status := range []int{0, http.StatusOK, http.StatusNotFound, 400} {
+		_, err := YAMLHandler([]byte("- path: /gh\n  url: https://github.com\n"), urlshorttest.Fallback(), WithStatus(status))
+		wantError(t, err, "is not a redirect (3xx) code")
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("MapHandler with WithStatus(%d) didn't panic", status)
+				}
+			}()
+			MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback(), WithStatus(status))
+		}()
+	}
+}
+
+func TestWithSlashNormalization(t *testing.T) {
+	paths := map[string]string{
+		"/urlshort-final": "https://github.com/gophercises/urlshort/tree/solution",
+		"/docs/":          "https://docs.example.com",
+		"/":               "https://example.com",
+	}
+	h := MapHandler(paths, urlshorttest.Fallback(), WithSlashNormalization())
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/urlshort-final", Location: "https://github.com/gophercises/urlshort/tree/solution", Code: http.StatusFound},
+		{Path: "/urlshort-final/", Location: "https://github.com/gophercises/urlshort/tree/solution", Code: http.StatusFound},
+		{Path: "/docs/", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/", Location: "https://example.com", Code: http.StatusFound},
+		{Path: "/docs//", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/urlshort"},
+	})
+
+	// without the option the other form misses
+	urlshorttest.TableTest(t, MapHandler(paths, urlshorttest.Fallback()), []urlshorttest.Case{
+		{Path: "/urlshort-final/"},
+		{Path: "/docs"},
+	})
+}
+
+func TestWithSlashNormalizationDuplicates(t *testing.T) {
+	data := []byte("- path: /x\n  url: https://a.example.com\n- path: /x/\n  url: https://b.example.com\n")
+	_, err := YAMLHandler(data, urlshorttest.Fallback(), WithSlashNormalization())
+	wantError(t, err, "/x")
+
+	h, err := YAMLHandler(data, urlshorttest.Fallback(), WithSlashNormalization(), WithDuplicatePolicy(FirstWins))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/x", Location: "https://a.example.com", Code: http.StatusFound},
+		{Path: "/x/", Location: "https://a.example.com", Code: http.StatusFound},
+	})
+}
+
+func TestWithCaseInsensitivePaths(t *testing.T) {
+	data := []byte(`
+- path: /gh
+  url: https://github.com
+- path: /GoDoc
+  url: https://pkg.go.dev
+- path: /u/:name
+  url: https://github.com/:name
+- path: /files/*
+  url: https://files.example.com
+`)
+	h, err := YAMLHandler(data, urlshorttest.Fallback(), WithCaseInsensitivePaths())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/GH", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/Gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/godoc", Location: "https://pkg.go.dev", Code: http.StatusFound},
+		// parameters keep the case they were requested with
+		{Path: "/U/Nils", Location: "https://github.com/Nils", Code: http.StatusFound},
+		{Path: "/FILES/Report.pdf", Location: "https://files.example.com", Code: http.StatusFound},
+		{Path: "/ghx"},
+	})
+
+	// without the option the case has to match
+	h, err = YAMLHandler(data, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/GH"},
+		{Path: "/godoc"},
+		{Path: "/GoDoc", Location: "https://pkg.go.dev", Code: http.StatusFound},
+	})
+
+	// MapHandler takes the option too
+	urlshorttest.AssertRedirect(t, MapHandler(map[string]string{"/Gh": "https://github.com"}, urlshorttest.Fallback(), WithCaseInsensitivePaths()),
+		"/GH", "https://github.com", http.StatusFound)
+}
+
+func TestWithCaseInsensitivePathsDuplicates(t *testing.T) {
+	data := []byte("- path: /gh\n  url: https://github.com\n- path: /GH\n  url: https://gitlab.com\n")
+	_, err := YAMLHandler(data, urlshorttest.Fallback(), WithCaseInsensitivePaths())
+	wantError(t, err, "duplicate path: /gh -> https://github.com and /GH -> https://gitlab.com")
+
+	// paths only differing in case are fine without the option
+	h, err := YAMLHandler(data, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/GH", "https://gitlab.com", http.StatusFound)
+
+	h, err = YAMLHandler(data, urlshorttest.Fallback(), WithCaseInsensitivePaths(), WithDuplicatePolicy(LastWins))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://gitlab.com", http.StatusFound)
+}
@@ -0,0 +1,81 @@
+package urlshort
+
+import (
+	"errors"
+	"strings"
+)
+
+// WithOrderedMatching matches the pattern entries, those with
+// :name parameters, globs, regexes and wildcards, in the order they were
+// declared, and the first that matches wins. Exact paths still win
+// over all of them. Without it the most specific pattern wins
+// whatever the order: parameter entries before globs before regexes
+// before the longest wildcard, see WithLongestPrefixMatching.
+//
+// Handlers only do one or the other, giving both options is an
+// error.
+func WithOrderedMatching() Option {
+	return func(c *config) {
+		c.orderedMatching = true
+	}
+}
+
+// WithLongestPrefixMatching asks for the default matching, where
+// the most specific pattern wins and the longest wildcard wins
+// when they are nested. It only exists to make the choice explicit
+// next to WithOrderedMatching.
+func WithLongestPrefixMatching() Option {
+	return func(c *config) {
+		c.longestPrefix = true
+	}
+}
+
+// checkMatching makes sure at most one matching mode was asked for
+func (c *config) checkMatching() error {
+	if c.orderedMatching && c.longestPrefix {
+		return errors.New("urlshort: WithOrderedMatching and WithLongestPrefixMatching can't be combined, choose one")
+	}
+	return nil
+}
+
+// orderedRoute is a pattern entry of a table with ordered matching,
+// exactly one of param, glob, regex and prefix is set
+type orderedRoute struct {
+	param  *paramRoute
+	glob   *globRoute
+	regex  *regexRoute
+	prefix string
+	entry  pathUrl
+}
+
+// lookupOrdered tries the pattern entries top to bottom, key is the
+// normalized path
+func (rt *routeTable) lookupOrdered(path, key string) (match, bool) {
+	var segments []string
+	for _, or := range rt.ordered {
+		switch {
+		case or.param != nil:
+			if segments == nil {
+				segments = paramSegments(path, rt.slashes)
+			}
+			if params, ok := or.param.match(segments, rt.foldCase); ok {
+				return match{entry: or.entry, dest: substituteParams(or.entry.URL, params)}, true
+			}
+		case or.glob != nil:
+			if segments == nil {
+				segments = paramSegments(path, rt.slashes)
+			}
+			if or.glob.match(segments, rt.foldCase) {
+				return match{entry: or.entry, dest: or.entry.URL}, true
+			}
+		case or.regex != nil:
+			if idx := or.regex.re.FindStringSubmatchIndex(path); idx != nil {
+				dest := or.regex.re.ExpandString(nil, or.entry.URL, path, idx)
+				return match{entry: or.entry, dest: string(dest)}, true
+			}
+		case strings.HasPrefix(key, or.prefix):
+			return match{entry: or.entry, dest: or.entry.URL}, true
+		}
+	}
+	return match{}, false
+}
@@ -0,0 +1,113 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// orderedEntries has pattern entries that overlap, each declared
+// before the one the default matching prefers
+const orderedEntries = `
+- path: /docs/*
+  url: https://docs.example.com
+- path: /docs/api/*
+  url: https://api.example.com
+- path: /docs/api/v1
+  url: https://api.example.com/v1
+- path: ^/u/(admin|root)$
+  url: https://admin.example.com/$1
+  regex: true
+- path: /u/:name
+  url: https://social.example.com/:name
+- path: /blog/*
+  url: https://blog.example.com
+- path: /blog/**/draft
+  url: https://blog.example.com/drafts
+`
+
+func TestOrderedMatching(t *testing.T) {
+	tests := []struct {
+		path             string
+		ordered, longest string
+	}{
+		// the first wildcard against the longest
+		{"/docs/api/users", "https://docs.example.com", "https://api.example.com"},
+		{"/docs/guide", "https://docs.example.com", "https://docs.example.com"},
+		// a regex declared first against parameters before regexes
+		{"/u/admin", "https://admin.example.com/admin", "https://social.example.com/admin"},
+		{"/u/nils", "https://social.example.com/nils", "https://social.example.com/nils"},
+		// a wildcard declared first against globs before wildcards
+		{"/blog/2024/05/draft", "https://blog.example.com", "https://blog.example.com/drafts"},
+		// exact entries win in both modes
+		{"/docs/api/v1", "https://api.example.com/v1", "https://api.example.com/v1"},
+	}
+	modes := []struct {
+		name    string
+		opts    []Option
+		ordered bool
+	}{
+		{"ordered", []Option{WithOrderedMatching()}, true},
+		{"default", nil, false},
+		{"longest prefix", []Option{WithLongestPrefixMatching()}, false},
+	}
+	for _, mode := range modes {
+		t.Run(mode.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(orderedEntries), urlshorttest.Fallback(), mode.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			cases := []urlshorttest.Case{{Path: "/missing"}, {Path: "/u/a/b"}}
+			for _, tt := range tests {
+				want := tt.longest
+				if mode.ordered {
+					want = tt.ordered
+				}
+				cases = append(cases, urlshorttest.Case{Path: tt.path, Location: want, Code: http.StatusFound})
+			}
+			urlshorttest.TableTest(t, h, cases)
+		})
+	}
+}
+
+func TestOrderedMatchingIsStable(t *testing.T) {
+	// the declaration order decides, not the order of a map, so every
+	// build of the same entries matches the same way
+	for i := 0; i < 20; i++ {
+		h, err := YAMLHandler([]byte(orderedEntries), urlshorttest.Fallback(), WithOrderedMatching())
+		if err != nil {
+			t.Fatalf("YAMLHandler: %v", err)
+		}
+		urlshorttest.AssertRedirect(t, h, "/docs/api/users", "https://docs.example.com", http.StatusFound)
+		urlshorttest.AssertRedirect(t, h, "/u/root", "https://admin.example.com/root", http.StatusFound)
+	}
+
+	// reversing the entries reverses which one wins
+	h, err := YAMLHandler([]byte(`
+- path: /u/:name
+  url: https://social.example.com/:name
+- path: ^/u/(admin|root)$
+  url: https://admin.example.com/$1
+  regex: true
+- path: /docs/api/*
+  url: https://api.example.com
+- path: /docs/*
+  url: https://docs.example.com
+`), urlshorttest.Fallback(), WithOrderedMatching())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/u/root", Location: "https://social.example.com/root", Code: http.StatusFound},
+		{Path: "/docs/api/users", Location: "https://api.example.com", Code: http.StatusFound},
+		{Path: "/docs/guide", Location: "https://docs.example.com", Code: http.StatusFound},
+	})
+}
+
+func TestOrderedMatchingWithLongestPrefix(t *testing.T) {
+	_, err := YAMLHandler([]byte(orderedEntries), urlshorttest.Fallback(), WithOrderedMatching(), WithLongestPrefixMatching())
+	wantError(t, err, "WithOrderedMatching and WithLongestPrefixMatching can't be combined, choose one")
+	_, err = New(FromYAML([]byte(orderedEntries)), urlshorttest.Fallback(), WithLongestPrefixMatching(), WithOrderedMatching())
+	wantError(t, err, "choose one")
+}
@@ -0,0 +1,128 @@
+package urlshort
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// paramRoute is an entry with named parameters like /u/:username,
+// segments holds the path split at the slashes
+type paramRoute struct {
+	segments []string
+	entry    pathUrl
+}
+
+// isParamPath reports whether path has at least one :name segment
+func isParamPath(path string) bool {
+	for _, seg := range strings.Split(path, "/") {
+		if isParamSegment(seg) {
+			return true
+		}
+	}
+	return false
+}
+
+// isParamSegment reports whether seg is a parameter like :name
+func isParamSegment(seg string) bool {
+	return len(seg) > 1 && seg[0] == ':' && paramNameLen(seg[1:]) == len(seg)-1
+}
+
+// paramNameLen returns how many bytes at the start of s form a
+// parameter name. Names start with a letter or underscore, so
+// ports like :8080 in a destination are left alone.
+func paramNameLen(s string) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		isLetter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (n == 0 || c < '0' || c > '9') {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// newParamRoute splits the entry path into its segments
+func newParamRoute(pu pathUrl) paramRoute {
+	return paramRoute{segments: strings.Split(configPath(pu), "/"), entry: pu}
+}
+
+// match compares the request path segment by segment and returns
+// the values for the parameters. The number of segments must be
+// the same and parameters never match an empty segment. With
+// foldCase the literal segments are compared ignoring case.
+func (pr paramRoute) match(segments []string, foldCase bool) (map[string]string, bool) {
+	if len(segments) != len(pr.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range pr.segments {
+		if isParamSegment(seg) {
+			if segments[i] == "" {
+				return nil, false
+			}
+			// matched escaped, so an encoded slash is part of the value
+			params[seg[1:]] = segmentValue(segments[i])
+			continue
+		}
+		if seg != segments[i] && !(foldCase && strings.EqualFold(seg, segments[i])) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// literals counts the segments that are not parameters
+func (pr paramRoute) literals() int {
+	n := 0
+	for _, seg := range pr.segments {
+		if !isParamSegment(seg) {
+			n++
+		}
+	}
+	return n
+}
+
+// sortParamRoutes puts the most specific routes (most literal
+// segments) first, keeping the declaration order on ties.
+func sortParamRoutes(routes []paramRoute) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].literals() > routes[j].literals()
+	})
+}
+
+// substituteParams replaces every :name in dest with the matching
+// parameter value. Values are escaped for the part of the URL they
+// end up in, so they can't add path segments or query parameters.
+// The fragment counts as a path up to a ?, hash routers read what
+// follows like a query.
+func substituteParams(dest string, params map[string]string) string {
+	var b strings.Builder
+	inQuery := false
+	for i := 0; i < len(dest); i++ {
+		c := dest[i]
+		switch c {
+		case '?':
+			inQuery = true
+		case '#':
+			// a # inside a value is escaped, so this starts the fragment
+			inQuery = false
+		case ':':
+			n := paramNameLen(dest[i+1:])
+			if value, ok := params[dest[i+1:i+1+n]]; n > 0 && ok {
+				if inQuery {
+					b.WriteString(url.QueryEscape(value))
+				} else {
+					b.WriteString(url.PathEscape(value))
+				}
+				i += n
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestParamPaths(t *testing.T) {
+	paths := map[string]string{
+		"/u/:username":               "https://github.com/:username",
+		"/u/nils":                    "https://nils.example.com",
+		"/r/:owner/:repo":            "https://github.com/:owner/:repo",
+		"/issues/:id/comments":       "https://tracker.example.com/issue?id=:id#comments",
+		"/search/:term":              "https://www.google.com/search?q=:term",
+		"/local/:port":               "http://localhost:8080/:port",
+		"/u/:username/repos/:repo/x": "https://github.com/:username/:repo/x",
+	}
+	h := MapHandler(paths, urlshorttest.Fallback())
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// static entries win
+		{Path: "/u/nils", Location: "https://nils.example.com", Code: http.StatusFound},
+		{Path: "/u/gopher", Location: "https://github.com/gopher", Code: http.StatusFound},
+		{Path: "/r/golang/go", Location: "https://github.com/golang/go", Code: http.StatusFound},
+		{Path: "/issues/42/comments", Location: "https://tracker.example.com/issue?id=42#comments", Code: http.StatusFound},
+		{Path: "/u/gopher/repos/tools/x", Location: "https://github.com/gopher/tools/x", Code: http.StatusFound},
+		// values are escaped for where they end up
+		{Path: "/search/a%20b", Location: "https://www.google.com/search?q=a+b", Code: http.StatusFound},
+		{Path: "/u/a%3Fb", Location: "https://github.com/a%3Fb", Code: http.StatusFound},
+		// :8080 is a port, not a parameter
+		{Path: "/local/x", Location: "http://localhost:8080/x", Code: http.StatusFound},
+		// too few and too many segments
+		{Path: "/u"},
+		{Path: "/u/"},
+		{Path: "/r/golang"},
+		{Path: "/r/golang/go/issues"},
+		{Path: "/issues/42"},
+	})
+}
@@ -0,0 +1,46 @@
+package urlshort
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// modePixel entries answer with a transparent image instead of
+// redirecting, for open tracking in emails
+const modePixel = "pixel"
+
+// pixelGIF is a transparent 1x1 GIF
+var pixelGIF = []byte{
+	'G', 'I', 'F', '8', '9', 'a',
+	0x01, 0x00, 0x01, 0x00, // 1x1
+	0x80, 0x00, 0x00, // global color table of 2 colors
+	0x00, 0x00, 0x00, 0xff, 0xff, 0xff,
+	0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, // color 0 is transparent
+	0x2c, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00,
+	0x02, 0x02, 0x44, 0x01, 0x00, // the one pixel
+	0x3b,
+}
+
+// servePixelEntry counts the hit of a pixel entry and answers with
+// the image. The redirect hook sees it with status 200 and the url
+// of the entry, which may be empty.
+func servePixelEntry(w http.ResponseWriter, r *http.Request, m match, cfg *config) {
+	recordInfo(r, true, m.entry.Path, http.StatusOK)
+	writeHeaders(w, m.entry, cfg)
+	servePixel(w)
+
+	if cfg.stats != nil {
+		cfg.stats.hit(m.entry.Path, cfg.now())
+	}
+	cfg.onRedirect(r, m.dest, http.StatusOK)
+}
+
+// servePixel answers with pixelGIF. It must never be cached, every
+// open has to reach the handler to be counted.
+func servePixel(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Content-Length", strconv.Itoa(len(pixelGIF)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(pixelGIF)
+}
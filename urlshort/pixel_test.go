@@ -0,0 +1,106 @@
+package urlshort
+
+import (
+	"bytes"
+	"image/gif"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// transparentGIF is the smallest transparent 1x1 GIF, 43 bytes
+var transparentGIF = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff" +
+	"!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+const pixelEntries = `
+- path: /open/spring
+  mode: pixel
+- path: /open/summer
+  url: https://example.com/campaigns/summer
+  mode: pixel
+  headers:
+    X-Campaign: summer
+- path: /gh
+  url: https://github.com
+`
+
+func TestPixelMode(t *testing.T) {
+	type hook struct {
+		path, dest string
+		status     int
+	}
+	var hooks []hook
+	stats := NewStats()
+	h, err := YAMLHandler([]byte(pixelEntries), urlshorttest.Fallback(), WithStats(stats),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) {
+			hooks = append(hooks, hook{path, dest, status})
+		}))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+
+	for _, path := range []string{"/open/spring", "/open/summer", "/open/summer"} {
+		res := get(h, path)
+		if res.Code != http.StatusOK || !bytes.Equal(res.Body.Bytes(), transparentGIF) {
+			t.Errorf("GET %s: got %d with %q, want 200 with the GIF", path, res.Code, res.Body.Bytes())
+		}
+		want := map[string]string{"Content-Type": "image/gif", "Content-Length": "43", "Cache-Control": "no-store", "Location": ""}
+		for name, value := range want {
+			if got := res.Header().Get(name); got != value {
+				t.Errorf("GET %s: %s %q, want %q", path, name, got, value)
+			}
+		}
+	}
+	if got := get(h, "/open/summer").Header().Get("X-Campaign"); got != "summer" {
+		t.Errorf("the headers of the entry: X-Campaign %q", got)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/open/autumn"},
+	})
+
+	// hits are counted and the hook sees them with a 200
+	snap := stats.Snapshot()
+	if snap.Paths["/open/spring"].Hits != 1 || snap.Paths["/open/summer"].Hits != 3 {
+		t.Errorf("got hits %v", snap.Paths)
+	}
+	summer := hook{"/open/summer", "https://example.com/campaigns/summer", http.StatusOK}
+	want := []hook{{"/open/spring", "", http.StatusOK}, summer, summer, summer, {"/gh", "https://github.com", http.StatusFound}}
+	if !slices.Equal(hooks, want) {
+		t.Errorf("got hooks %v, want %v", hooks, want)
+	}
+
+	// HEAD gets the headers without the image
+	res := serveMethod(h, http.MethodHead, "/open/spring")
+	if res.Code != http.StatusOK || res.Body.Len() != 0 || res.Header().Get("Content-Type") != "image/gif" {
+		t.Errorf("HEAD: got %d, Content-Type %q, %d bytes", res.Code, res.Header().Get("Content-Type"), res.Body.Len())
+	}
+}
+
+func TestPixelGIF(t *testing.T) {
+	if !bytes.Equal(pixelGIF, transparentGIF) {
+		t.Fatalf("got %q, want %q", pixelGIF, transparentGIF)
+	}
+	img, err := gif.Decode(bytes.NewReader(pixelGIF))
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Errorf("got a %dx%d image", b.Dx(), b.Dy())
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("the pixel has alpha %d, want it transparent", a)
+	}
+}
+
+func TestPixelURLOptional(t *testing.T) {
+	if _, err := YAMLHandler([]byte("- path: /open\n  mode: pixel\n"), nil); err != nil {
+		t.Errorf("a pixel entry without a url: %v", err)
+	}
+	_, err := YAMLHandler([]byte("- path: /open\n  mode: redirect\n"), nil)
+	wantError(t, err, "/open")
+	_, err = YAMLHandler([]byte("- path: /open\n"), nil)
+	wantError(t, err, "/open")
+}
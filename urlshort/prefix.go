@@ -0,0 +1,84 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WithPathPrefix serves the mappings under prefix, for handlers
+// mounted below a path of a bigger site. With WithPathPrefix("/go")
+// a request for /go/gh looks up /gh, and /go and /go/ look up /.
+// Requests outside of the prefix are passed to the fallback as they
+// are, without counting them as misses. Export and the admin UI
+// show the paths with the prefix, the way they are requested. The
+// prefix must start with a slash and not end with one.
+func WithPathPrefix(prefix string) Option {
+	return func(c *config) {
+		c.pathPrefix = prefix
+	}
+}
+
+// checkPathPrefix makes sure prefix can be stripped from paths
+func checkPathPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("urlshort: path prefix %q must start with / and not end with one", prefix)
+	}
+	return nil
+}
+
+// lookupPath returns the path of r to look up, see requestPath,
+// without the prefix of WithPathPrefix. It reports false for
+// requests that don't have the prefix.
+func lookupPath(r *http.Request, cfg *config) (string, bool) {
+	path := requestPath(r)
+	if cfg.pathPrefix == "" {
+		return path, true
+	}
+	rest, ok := strings.CutPrefix(path, cfg.pathPrefix)
+	switch {
+	case !ok:
+		return "", false
+	case rest == "":
+		return "/", true
+	case rest[0] != '/':
+		// /gopher doesn't start with /go
+		return "", false
+	}
+	return rest, true
+}
+
+// prefixPaths returns pathUrls with prefix in front of every path
+// and alias, for showing them the way they are requested. Regex
+// entries are left alone, they match the path without the prefix.
+func prefixPaths(pathUrls []pathUrl, prefix string) []pathUrl {
+	if prefix == "" {
+		return pathUrls
+	}
+	prefixed := make([]pathUrl, len(pathUrls))
+	for i, pu := range pathUrls {
+		if !pu.Regex {
+			pu.Path = prefix + pu.Path
+			if len(pu.Aliases) > 0 {
+				aliases := make([]string, len(pu.Aliases))
+				for j, alias := range pu.Aliases {
+					aliases[j] = prefix + alias
+				}
+				pu.Aliases = aliases
+			}
+		}
+		prefixed[i] = pu
+	}
+	return prefixed
+}
+
+// pathPrefix is the prefix of the handler the store is served with
+func (s *MutableStore) pathPrefix() string {
+	if prefix := s.prefix.Load(); prefix != nil {
+		return *prefix
+	}
+	return ""
+}
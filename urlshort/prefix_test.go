@@ -0,0 +1,138 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const prefixEntries = `
+- path: /
+  url: https://go.example.com
+- path: /gh
+  url: https://github.com
+  aliases: [/github]
+- path: /u/:name
+  url: https://github.com/:name
+`
+
+func TestPathPrefix(t *testing.T) {
+	stats := NewStats()
+	h, err := YAMLHandler([]byte(prefixEntries), urlshorttest.Fallback(), WithPathPrefix("/go"), WithStats(stats))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/go/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go/github", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go/u/nils", Location: "https://github.com/nils", Code: http.StatusFound},
+		// the bare prefix, with and without a trailing slash
+		{Path: "/go", Location: "https://go.example.com", Code: http.StatusFound},
+		{Path: "/go/", Location: "https://go.example.com", Code: http.StatusFound},
+		{Path: "/go/missing"},
+		// outside of the prefix
+		{Path: "/gh"},
+		{Path: "/"},
+		{Path: "/gopher"},
+		{Path: "/gogh"},
+		{Path: "/other/go/gh"},
+	})
+
+	// only /go/missing is a miss, counted as it was requested
+	if snap := stats.Snapshot(); len(snap.Misses) != 1 || snap.Misses["/go/missing"].Hits != 1 {
+		t.Errorf("got misses %v", snap.Misses)
+	}
+
+	// the fallback sees the request untouched
+	var seen string
+	h, err = YAMLHandler([]byte(prefixEntries), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.URL.Path
+	}), WithPathPrefix("/go"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	for _, path := range []string{"/gopher", "/go/missing"} {
+		get(h, path)
+		if seen != path {
+			t.Errorf("GET %s: the fallback got %q", path, seen)
+		}
+	}
+}
+
+func TestPathPrefixNested(t *testing.T) {
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(), WithPathPrefix("/links/go"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/links/go/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/links/gh"},
+		{Path: "/links/go"},
+	})
+}
+
+func TestPathPrefixBackends(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	res := ResolverFunc(func(_ context.Context, path string) (string, bool, error) {
+		if path == "/gh" {
+			return "https://github.com", true, nil
+		}
+		return "", false, nil
+	})
+	for name, h := range map[string]http.Handler{
+		"store":    NewMutableHandler(store, urlshorttest.Fallback(), WithPathPrefix("/go")),
+		"resolver": ResolverHandler(res, urlshorttest.Fallback(), WithPathPrefix("/go")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/go/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/gh"},
+				{Path: "/gopher"},
+			})
+		})
+	}
+}
+
+func TestPathPrefixExport(t *testing.T) {
+	h, err := New(FromYAML([]byte(prefixEntries)), nil, WithPathPrefix("/go"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	data, err := h.Export(FormatYAML)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	for _, want := range []string{"path: /go/\n", "path: /go/gh\n", "- /go/github\n", "path: /go/u/:name\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("the export doesn't contain %q:\n%s", want, data)
+		}
+	}
+
+	// the store exports with the prefix, backups are without it
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	NewMutableHandler(store, nil, WithPathPrefix("/go"))
+	data, err = store.Export(FormatYAML)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if !strings.Contains(string(data), "path: /go/gh\n") {
+		t.Errorf("the store exports\n%s", data)
+	}
+	if b := store.Backup(); b.Entries[0].Path != "/gh" {
+		t.Errorf("the backup has %s", b.Entries[0].Path)
+	}
+}
+
+func TestPathPrefixErrors(t *testing.T) {
+	for _, prefix := range []string{"go", "/go/", "/"} {
+		t.Run(prefix, func(t *testing.T) {
+			_, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil, WithPathPrefix(prefix))
+			wantError(t, err, "must start with / and not end with one")
+		})
+	}
+}
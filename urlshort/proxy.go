@@ -0,0 +1,84 @@
+package urlshort
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// modeProxy serves the destination through a reverse proxy, so the
+// short URL stays in the browser bar
+const modeProxy = "proxy"
+
+// defaultProxyTimeout is how long a proxy entry waits for the
+// upstream to answer unless WithProxyTimeout says otherwise
+const defaultProxyTimeout = 30 * time.Second
+
+// WithProxyTimeout sets how long proxy entries wait for the
+// upstream to connect and send its response headers. The body is
+// not limited, so streaming responses keep working.
+func WithProxyTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.proxyTimeout = d
+	}
+}
+
+// newProxyTransport returns the transport shared by the proxy
+// entries of a route table, with the timeouts of cfg
+func newProxyTransport(cfg *config) *http.Transport {
+	dialer := &net.Dialer{Timeout: cfg.proxyTimeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.ResponseHeaderTimeout = cfg.proxyTimeout
+	return transport
+}
+
+// newProxy creates the reverse proxy for a proxy entry. The
+// outgoing request goes to the destination with its Host, and the
+// client address and original host are passed in the X-Forwarded
+// headers.
+func newProxy(transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// the destination differs per request for parameter entries
+			dest := pr.In.Context().Value(proxyDestKey).(*url.URL)
+			query := pr.In.URL.RawQuery
+			if dest.RawQuery != "" {
+				query = dest.RawQuery
+			}
+			pr.Out.URL = &url.URL{
+				Scheme:   dest.Scheme,
+				Host:     dest.Host,
+				Path:     dest.Path,
+				RawPath:  dest.RawPath,
+				RawQuery: query,
+			}
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+		},
+		Transport:     transport,
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, "urlshort: upstream unavailable", http.StatusBadGateway)
+		},
+	}
+}
+
+// serveProxy passes r to the reverse proxy of the entry
+func serveProxy(w http.ResponseWriter, r *http.Request, pu pathUrl, dest string, cfg *config) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		// checked when the entry was parsed, only substitutions can get here
+		http.Error(w, "urlshort: upstream unavailable", http.StatusBadGateway)
+		return
+	}
+
+	recordInfo(r, true, pu.Path, 0)
+	if cfg.stats != nil {
+		cfg.stats.hit(pu.Path, cfg.now())
+	}
+	pu.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyDestKey, u)))
+}
@@ -0,0 +1,121 @@
+package urlshort
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// proxyEntries maps /status and /api/:v to backend in proxy mode
+func proxyEntries(backend string) []byte {
+	return []byte(fmt.Sprintf(`
+- path: /status
+  url: %[1]s/health
+  mode: proxy
+- path: /api/:v
+  url: %[1]s/v/:v
+  mode: proxy
+`, backend))
+}
+
+func TestProxyMode(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Backend", "1")
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "%s %s?%s host=%s fwd-host=%s fwd-for=%t body=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, r.Host, r.Header.Get("X-Forwarded-Host"),
+			r.Header.Get("X-Forwarded-For") != "", body)
+	}))
+	defer backend.Close()
+	backendHost := strings.TrimPrefix(backend.URL, "http://")
+
+	h, err := YAMLHandler(proxyEntries(backend.URL), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		method, target, body string
+		want                 string
+	}{
+		{http.MethodGet, "/status?verbose=1", "", "GET /health?verbose=1 host=" + backendHost + " fwd-host=short.example.com fwd-for=true body="},
+		{http.MethodPost, "/api/2", `{"a": 1}`, "POST /v/2? host=" + backendHost + ` fwd-host=short.example.com fwd-for=true body={"a": 1}`},
+		{http.MethodDelete, "/status", "", "DELETE /health? host=" + backendHost + " fwd-host=short.example.com fwd-for=true body="},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://short.example.com"+tt.target, strings.NewReader(tt.body))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Code != http.StatusAccepted || res.Body.String() != tt.want || res.Header().Get("X-Backend") != "1" {
+			t.Errorf("%s %s: got %d %q, want 202 %q from the backend", tt.method, tt.target, res.Code, res.Body, tt.want)
+		}
+		if loc := res.Header().Get("Location"); loc != "" {
+			t.Errorf("%s %s: proxied with a Location of %q", tt.method, tt.target, loc)
+		}
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{{Path: "/missing"}})
+}
+
+func TestProxyModeStreaming(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "second\n")
+	}))
+	defer backend.Close()
+	// the backend has to finish for Close, even if the test fails
+	releaseOnce := sync.OnceFunc(func() { close(release) })
+	defer releaseOnce()
+
+	h, err := YAMLHandler(proxyEntries(backend.URL), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// the first line arrives while the backend is still writing
+	buf := make([]byte, len("first\n"))
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first\n" {
+		t.Fatalf("read %q, %v before the response ended", buf, err)
+	}
+	releaseOnce()
+	if rest, err := io.ReadAll(resp.Body); err != nil || string(rest) != "second\n" {
+		t.Errorf("read %q, %v after the first line", rest, err)
+	}
+}
+
+func TestProxyModeFailures(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	for name, backend := range map[string]string{"timeout": slow.URL, "unreachable": gone.URL} {
+		t.Run(name, func(t *testing.T) {
+			h, err := YAMLHandler(proxyEntries(backend), urlshorttest.Fallback(), WithProxyTimeout(50*time.Millisecond))
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			res := get(h, "/status")
+			if res.Code != http.StatusBadGateway || !strings.Contains(res.Body.String(), "upstream unavailable") {
+				t.Errorf("GET /status: got %d %q, want 502", res.Code, res.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package urlshort
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithQueryForwarding appends the query string of the incoming
+// request to the destination, so /gh?tab=repositories keeps its
+// parameters. Parameters already on the destination URL win when
+// both have the same key.
+func WithQueryForwarding() Option {
+	return func(c *config) {
+		c.forwardQuery = true
+	}
+}
+
+// WithQueryAllow only forwards the query parameters named in keys,
+// for every entry. A key ending in * matches every parameter that
+// starts with the rest, like utm_*. Entries can allow more with
+// query_allow. When any allow list applies to an entry, its strip
+// lists are ignored. Needs WithQueryForwarding.
+func WithQueryAllow(keys ...string) Option {
+	return func(c *config) {
+		c.queryAllow = append(c.queryAllow, keys...)
+	}
+}
+
+// WithQueryStrip never forwards the query parameters named in keys,
+// like session or internal tracking ids, for every entry. Keys work
+// like for WithQueryAllow, entries can strip more with query_strip.
+// Needs WithQueryForwarding.
+func WithQueryStrip(keys ...string) Option {
+	return func(c *config) {
+		c.queryStrip = append(c.queryStrip, keys...)
+	}
+}
+
+// filterQuery returns the parameters of incoming that may be
+// forwarded for pu. Parameters the handler adds itself, like the
+// UTM ones, are added to the destination before and never filtered.
+func filterQuery(incoming url.Values, pu pathUrl, cfg *config) url.Values {
+	allow := len(cfg.queryAllow) > 0 || len(pu.QueryAllow) > 0
+	if !allow && len(cfg.queryStrip) == 0 && len(pu.QueryStrip) == 0 {
+		return incoming
+	}
+
+	kept := url.Values{}
+	for key, values := range incoming {
+		if allow {
+			if !matchesKey(key, cfg.queryAllow) && !matchesKey(key, pu.QueryAllow) {
+				continue
+			}
+		} else if matchesKey(key, cfg.queryStrip) || matchesKey(key, pu.QueryStrip) {
+			continue
+		}
+		kept[key] = values
+	}
+	return kept
+}
+
+// matchesKey reports whether key is one of keys, or starts with a
+// key ending in *
+func matchesKey(key string, keys []string) bool {
+	for _, k := range keys {
+		if strings.HasSuffix(k, "*") {
+			if strings.HasPrefix(key, k[:len(k)-1]) {
+				return true
+			}
+		} else if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardQuery merges incoming into the query of dest. Keys that
+// are already on dest are left alone, and the order of the
+// existing query is kept. Destinations that don't parse are
+// returned unchanged.
+func forwardQuery(dest string, incoming url.Values) string {
+	if len(incoming) == 0 {
+		return dest
+	}
+
+	u, err := url.Parse(dest)
+	if err != nil {
+		return dest
+	}
+
+	existing := u.Query()
+	extra := url.Values{}
+	for key, values := range incoming {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		extra[key] = values
+	}
+	if len(extra) == 0 {
+		return dest
+	}
+
+	if u.RawQuery == "" {
+		u.RawQuery = extra.Encode()
+	} else {
+		u.RawQuery += "&" + extra.Encode()
+	}
+	// String puts the query in front of the fragment again
+	return u.String()
+}
@@ -0,0 +1,114 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestWithQueryForwarding(t *testing.T) {
+	paths := map[string]string{
+		"/gh":    "https://github.com/nils",
+		"/q":     "https://example.com/search?lang=en",
+		"/frag":  "https://example.com/page#section",
+		"/both":  "https://example.com/page?lang=en#section",
+		"/empty": "https://example.com/page?",
+	}
+	h := MapHandler(paths, urlshorttest.Fallback(), WithQueryForwarding())
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/gh?", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/gh?tab=repositories", Location: "https://github.com/nils?tab=repositories", Code: http.StatusFound},
+		{Path: "/gh?b=2&a=1&a=3", Location: "https://github.com/nils?a=1&a=3&b=2", Code: http.StatusFound},
+		// destination values win on conflicts
+		{Path: "/q?q=go", Location: "https://example.com/search?lang=en&q=go", Code: http.StatusFound},
+		{Path: "/q?lang=de&q=go", Location: "https://example.com/search?lang=en&q=go", Code: http.StatusFound},
+		{Path: "/q", Location: "https://example.com/search?lang=en", Code: http.StatusFound},
+		// the fragment stays at the end
+		{Path: "/frag?x=1", Location: "https://example.com/page?x=1#section", Code: http.StatusFound},
+		{Path: "/both?x=1", Location: "https://example.com/page?lang=en&x=1#section", Code: http.StatusFound},
+		{Path: "/empty?x=1", Location: "https://example.com/page?x=1", Code: http.StatusFound},
+		{Path: "/missing?x=1"},
+	})
+
+	// without the option the query is dropped
+	urlshorttest.TableTest(t, MapHandler(paths, urlshorttest.Fallback()), []urlshorttest.Case{
+		{Path: "/gh?tab=repositories", Location: "https://github.com/nils", Code: http.StatusFound},
+	})
+}
+
+func TestQueryAllowStrip(t *testing.T) {
+	data := `
+- path: /default
+  url: https://example.com/default
+- path: /strip
+  url: https://example.com/strip
+  query_strip: [internal_*, ref]
+- path: /allow
+  url: https://example.com/allow
+  query_allow: [q]
+- path: /both
+  url: https://example.com/both
+  query_allow: [q, page]
+  query_strip: [q]
+- path: /dest
+  url: https://example.com/dest?q=fixed&session=dest
+  query_strip: [q]
+`
+	tests := []struct {
+		name  string
+		opts  []Option
+		cases []urlshorttest.Case
+	}{
+		{"entry lists", nil, []urlshorttest.Case{
+			{Path: "/default?session=s1&q=go", Location: "https://example.com/default?q=go&session=s1", Code: http.StatusFound},
+			{Path: "/strip?internal_id=7&internal_=x&ref=mail&q=go", Location: "https://example.com/strip?q=go", Code: http.StatusFound},
+			// repeated parameters are kept or dropped together
+			{Path: "/strip?ref=a&q=go&ref=b&q=rust", Location: "https://example.com/strip?q=go&q=rust", Code: http.StatusFound},
+			{Path: "/allow?q=go&q=rust&session=s1&page=2", Location: "https://example.com/allow?q=go&q=rust", Code: http.StatusFound},
+			{Path: "/allow?session=s1", Location: "https://example.com/allow", Code: http.StatusFound},
+			// the allow list wins, the strip list is ignored
+			{Path: "/both?q=go&page=2&session=s1", Location: "https://example.com/both?page=2&q=go", Code: http.StatusFound},
+			// the destination keeps its own values either way
+			{Path: "/dest?q=go&session=s1&x=1", Location: "https://example.com/dest?q=fixed&session=dest&x=1", Code: http.StatusFound},
+		}},
+		{"handler strip merged with the entries", []Option{WithQueryStrip("session", "utm_*")}, []urlshorttest.Case{
+			{Path: "/default?session=s1&utm_source=x&q=go", Location: "https://example.com/default?q=go", Code: http.StatusFound},
+			{Path: "/strip?session=s1&ref=mail&q=go", Location: "https://example.com/strip?q=go", Code: http.StatusFound},
+			// an allow list of the entry beats the strip list of the handler
+			{Path: "/allow?q=go&session=s1", Location: "https://example.com/allow?q=go", Code: http.StatusFound},
+		}},
+		{"handler allow merged with the entries", []Option{WithQueryAllow("lang")}, []urlshorttest.Case{
+			{Path: "/default?lang=de&q=go&session=s1", Location: "https://example.com/default?lang=de", Code: http.StatusFound},
+			{Path: "/allow?lang=de&q=go&session=s1", Location: "https://example.com/allow?lang=de&q=go", Code: http.StatusFound},
+			// with the allow list of the handler no strip list applies
+			{Path: "/strip?lang=de&ref=mail", Location: "https://example.com/strip?lang=de", Code: http.StatusFound},
+			{Path: "/both?q=go&page=2&lang=de&x=1", Location: "https://example.com/both?lang=de&page=2&q=go", Code: http.StatusFound},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), append(tt.opts, WithQueryForwarding())...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			urlshorttest.TableTest(t, h, tt.cases)
+		})
+	}
+}
+
+func TestQueryStripKeepsUTM(t *testing.T) {
+	data := "- path: /spring\n  url: https://shop.example.com/spring\n  query_allow: [q]\n- path: /summer\n  url: https://shop.example.com/summer\n"
+	h, err := YAMLHandler([]byte(data), urlshorttest.Fallback(), WithUTM("mail", "email"),
+		WithQueryForwarding(), WithQueryStrip("utm_*"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	// the injected parameters survive both lists, the incoming ones
+	// don't
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/summer?utm_source=evil&utm_term=x&q=go", Location: "https://shop.example.com/summer?utm_campaign=summer&utm_medium=email&utm_source=mail&q=go", Code: http.StatusFound},
+		{Path: "/spring?utm_source=evil&q=go", Location: "https://shop.example.com/spring?utm_campaign=spring&utm_medium=email&utm_source=mail&q=go", Code: http.StatusFound},
+	})
+}
@@ -0,0 +1,170 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIdleTimeout is how long the bucket of a quiet client is
+// kept unless RateLimitOptions say otherwise
+const defaultIdleTimeout = 10 * time.Minute
+
+// RateLimitOptions configure RateLimit
+type RateLimitOptions struct {
+	// Rate is how many requests per second a client may make on
+	// average, it must be positive
+	Rate float64
+	// Burst is how many requests a client may make at once, the
+	// default is Rate rounded up
+	Burst int
+	// TrustedProxies are the addresses or CIDR ranges of proxies in
+	// front of the server. Requests from them are limited by the
+	// client address in X-Forwarded-For or X-Real-IP instead.
+	TrustedProxies []string
+	// IdleTimeout is how long a client that made no requests is
+	// remembered, the default is ten minutes
+	IdleTimeout time.Duration
+	// Now is the clock, the default is time.Now
+	Now func() time.Time
+}
+
+// RateLimit wraps handler with a token bucket per client IP. Clients
+// that run out of tokens get 429 Too Many Requests and a Retry-After
+// header. The buckets of idle clients are dropped after IdleTimeout,
+// so memory stays bounded by the number of active clients.
+//
+// RateLimit panics if the options are invalid.
+func RateLimit(handler http.Handler, opts RateLimitOptions) http.Handler {
+	rl, err := newRateLimiter(opts)
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := rl.allow(clientAddr(r, rl.proxies)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter holds the buckets of all clients
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	idle    time.Duration
+	now     func() time.Time
+	proxies []*net.IPNet
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(opts RateLimitOptions) (*rateLimiter, error) {
+	if opts.Rate <= 0 || math.IsInf(opts.Rate, 0) || math.IsNaN(opts.Rate) {
+		return nil, errors.New("urlshort: rate limit rate must be positive")
+	}
+	if opts.Burst < 0 {
+		return nil, errors.New("urlshort: rate limit burst is negative")
+	}
+	if opts.IdleTimeout < 0 {
+		return nil, errors.New("urlshort: rate limit idle timeout is negative")
+	}
+
+	rl := &rateLimiter{
+		rate:    opts.Rate,
+		burst:   float64(opts.Burst),
+		idle:    opts.IdleTimeout,
+		now:     opts.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+	if rl.burst == 0 {
+		rl.burst = math.Ceil(opts.Rate)
+	}
+	if rl.idle == 0 {
+		rl.idle = defaultIdleTimeout
+	}
+	if rl.now == nil {
+		rl.now = time.Now
+	}
+	proxies, err := parseNetworks(opts.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	rl.proxies = proxies
+	return rl, nil
+}
+
+// parseNetwork parses a CIDR range or a single address
+func parseNetwork(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("urlshort: invalid network %q", s)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("urlshort: invalid address %q", s)
+	}
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// allow takes a token from the bucket of ip. If there is none it
+// returns how long until there will be.
+func (rl *rateLimiter) allow(ip string) (time.Duration, bool) {
+	now := rl.now()
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if !now.Before(rl.nextSweep) {
+		rl.sweep(now)
+	}
+
+	b, ok := rl.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[ip] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(rl.burst, b.tokens+elapsed.Seconds()*rl.rate)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rl.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// sweep drops the buckets of clients that have been idle for too
+// long. The caller must hold the lock.
+func (rl *rateLimiter) sweep(now time.Time) {
+	for ip, b := range rl.buckets {
+		if now.Sub(b.last) >= rl.idle {
+			delete(rl.buckets, ip)
+		}
+	}
+	rl.nextSweep = now.Add(rl.idle)
+}
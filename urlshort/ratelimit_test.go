@@ -0,0 +1,161 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// fakeClock is a clock for RateLimitOptions.Now that only moves
+// when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// from records the response of h to a GET from remoteAddr, with
+// an X-Forwarded-For header if forwarded isn't empty
+func from(h http.Handler, remoteAddr, forwarded string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/gh", nil)
+	req.RemoteAddr = remoteAddr
+	if forwarded != "" {
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := newFakeClock()
+	h := RateLimit(MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback()), RateLimitOptions{
+		Rate:  2,
+		Burst: 3,
+		Now:   clock.Now,
+	})
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		addr    string
+		want    int
+		retry   string
+	}{
+		{"burst 1", 0, "192.0.2.1:1000", http.StatusFound, ""},
+		{"burst 2", 0, "192.0.2.1:1001", http.StatusFound, ""},
+		{"burst 3", 0, "192.0.2.1:1002", http.StatusFound, ""},
+		{"exhausted", 0, "192.0.2.1:1003", http.StatusTooManyRequests, "1"},
+		{"other client", 0, "192.0.2.2:1000", http.StatusFound, ""},
+		{"not refilled yet", 400 * time.Millisecond, "192.0.2.1:1000", http.StatusTooManyRequests, "1"},
+		{"refilled one", 100 * time.Millisecond, "192.0.2.1:1000", http.StatusFound, ""},
+		{"empty again", 0, "192.0.2.1:1000", http.StatusTooManyRequests, "1"},
+		{"full after a while", time.Minute, "192.0.2.1:1000", http.StatusFound, ""},
+		{"full 2", 0, "192.0.2.1:1000", http.StatusFound, ""},
+		{"full 3", 0, "192.0.2.1:1000", http.StatusFound, ""},
+		{"never more than the burst", 0, "192.0.2.1:1000", http.StatusTooManyRequests, "1"},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		res := from(h, tt.addr, "")
+		if res.Code != tt.want || res.Header().Get("Retry-After") != tt.retry {
+			t.Errorf("%s: got %d with Retry-After %q, want %d with %q", tt.name, res.Code, res.Header().Get("Retry-After"), tt.want, tt.retry)
+		}
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	h := RateLimit(http.NotFoundHandler(), RateLimitOptions{Rate: 0.1, Burst: 1, Now: clock.Now})
+	from(h, "192.0.2.1:1000", "")
+	clock.Advance(2500 * time.Millisecond)
+	// 7.5s are left, rounded up
+	if res := from(h, "192.0.2.1:1000", ""); res.Code != http.StatusTooManyRequests || res.Header().Get("Retry-After") != "8" {
+		t.Errorf("got %d with Retry-After %q, want 429 with 8", res.Code, res.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitTrustedProxies(t *testing.T) {
+	clock := newFakeClock()
+	h := RateLimit(http.NotFoundHandler(), RateLimitOptions{
+		Rate:           1,
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.10"},
+		Now:            clock.Now,
+	})
+	tests := []struct {
+		name      string
+		addr      string
+		forwarded string
+		want      int
+	}{
+		{"client a through the proxy", "10.0.0.1:1000", "198.51.100.1", http.StatusNotFound},
+		{"client a again", "10.0.0.2:1000", "198.51.100.1", http.StatusTooManyRequests},
+		{"client b through the proxy", "10.0.0.1:1000", "198.51.100.2", http.StatusNotFound},
+		{"through two proxies", "192.0.2.10:1000", "198.51.100.3, 10.0.0.5", http.StatusNotFound},
+		{"spoofed hop before the client", "10.0.0.1:1000", "198.51.100.9, 198.51.100.3", http.StatusTooManyRequests},
+		// untrusted peers are limited by their own address
+		{"untrusted peer", "203.0.113.1:1000", "198.51.100.4", http.StatusNotFound},
+		{"untrusted peer with another header", "203.0.113.1:1000", "198.51.100.5", http.StatusTooManyRequests},
+		{"client c directly", "198.51.100.4:1000", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if res := from(h, tt.addr, tt.forwarded); res.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, res.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimitIdleBuckets(t *testing.T) {
+	clock := newFakeClock()
+	rl, err := newRateLimiter(RateLimitOptions{Rate: 1, IdleTimeout: time.Minute, Now: clock.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl.allow("192.0.2.1")
+	rl.allow("192.0.2.2")
+	clock.Advance(30 * time.Second)
+	rl.allow("192.0.2.2")
+	clock.Advance(40 * time.Second)
+	rl.allow("192.0.2.3")
+	// 192.0.2.1 was idle for a minute, 192.0.2.2 for 40s
+	if _, ok := rl.buckets["192.0.2.1"]; ok || len(rl.buckets) != 2 {
+		t.Errorf("after the idle timeout: %d buckets, 192.0.2.1 kept %v", len(rl.buckets), ok)
+	}
+}
+
+func TestRateLimitOptionsInvalid(t *testing.T) {
+	for name, opts := range map[string]RateLimitOptions{
+		"zero rate":      {},
+		"negative burst": {Rate: 1, Burst: -1},
+		"negative idle":  {Rate: 1, IdleTimeout: -time.Second},
+		"bad proxy":      {Rate: 1, TrustedProxies: []string{"proxy.internal"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RateLimit(%+v) didn't panic", opts)
+				}
+			}()
+			RateLimit(http.NotFoundHandler(), opts)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package urlshort
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicLogger is told about panics WithRecovery recovered, with the
+// stack of the goroutine that panicked
+type PanicLogger func(err any, stack []byte)
+
+// WithRecovery recovers panics in the fallback and in the hooks and
+// reports them to logger. A panicking fallback gets the client a
+// 500 instead of a dropped connection, a panicking hook is only
+// reported since the response is written by then.
+// http.ErrAbortHandler is passed on, it is how handlers abort a
+// response on purpose.
+func WithRecovery(logger PanicLogger) Option {
+	return func(c *config) {
+		c.recoverLog = logger
+	}
+}
+
+// recoverPanic is deferred around everything that calls into user
+// code while serving r, it does nothing without WithRecovery
+func (c *config) recoverPanic(w http.ResponseWriter) {
+	if c.recoverLog == nil {
+		return
+	}
+	err := recover()
+	if err == nil {
+		return
+	}
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	c.recoverLog(err, debug.Stack())
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// panicLog collects what WithRecovery reports
+type panicLog struct {
+	mu     sync.Mutex
+	errs   []any
+	stacks []string
+}
+
+func (l *panicLog) log(err any, stack []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+	l.stacks = append(l.stacks, string(stack))
+}
+
+// panicking returns a handler that panics with err
+func panicking(err any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(err)
+	})
+}
+
+// servePanic serves a GET of target with h and returns the value it
+// panicked with, if it did
+func servePanic(h http.Handler, target string) (p any) {
+	defer func() { p = recover() }()
+	get(h, target)
+	return nil
+}
+
+func TestWithRecovery(t *testing.T) {
+	paths := map[string]string{"/gh": "https://github.com"}
+	tests := []struct {
+		name     string
+		fallback http.Handler
+		opts     []Option
+		path     string
+		code     int
+		logged   string
+	}{
+		{"fallback", panicking("fallback broke"), nil, "/missing", http.StatusInternalServerError, "fallback broke"},
+		{"redirect hook", urlshorttest.Fallback(), []Option{WithRedirectHook(func(r *http.Request, path, dest string, status int) {
+			panic("redirect hook broke")
+		})}, "/gh", http.StatusFound, "redirect hook broke"},
+		// the fallback still runs after a broken miss hook
+		{"miss hook", urlshorttest.Fallback(), []Option{WithMissHook(func(r *http.Request) {
+			panic("miss hook broke")
+		})}, "/missing", http.StatusNotFound, "miss hook broke"},
+		{"nothing", urlshorttest.Fallback(), nil, "/gh", http.StatusFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log panicLog
+			h := MapHandler(paths, tt.fallback, append(tt.opts, WithRecovery(log.log))...)
+			res := get(h, tt.path)
+			if res.Code != tt.code {
+				t.Errorf("GET %s: got %d, want %d", tt.path, res.Code, tt.code)
+			}
+			if tt.logged == "" {
+				if len(log.errs) != 0 {
+					t.Errorf("logged %v without a panic", log.errs)
+				}
+				return
+			}
+			if len(log.errs) != 1 || log.errs[0] != tt.logged {
+				t.Fatalf("logged %v, want %q", log.errs, tt.logged)
+			}
+			if !strings.Contains(log.stacks[0], "recover_test.go") {
+				t.Errorf("the stack doesn't show where the panic came from:\n%s", log.stacks[0])
+			}
+		})
+	}
+}
+
+func TestWithRecoveryAbortHandler(t *testing.T) {
+	var log panicLog
+	h := MapHandler(map[string]string{"/gh": "https://github.com"}, panicking(http.ErrAbortHandler), WithRecovery(log.log),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) { panic(http.ErrAbortHandler) }))
+	for _, target := range []string{"/missing", "/gh"} {
+		if p := servePanic(h, target); p != http.ErrAbortHandler {
+			t.Errorf("GET %s: panicked with %v, want http.ErrAbortHandler", target, p)
+		}
+	}
+	if len(log.errs) != 0 {
+		t.Errorf("logged %v, aborting isn't a failure", log.errs)
+	}
+}
+
+func TestWithoutRecovery(t *testing.T) {
+	// without WithRecovery the fallback's panic is the server's to handle
+	h := MapHandler(map[string]string{"/gh": "https://github.com"}, panicking("fallback broke"))
+	if p := servePanic(h, "/missing"); p != "fallback broke" {
+		t.Errorf("panicked with %v, want the panic of the fallback", p)
+	}
+
+	// and hook panics are swallowed
+	h = MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback(),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) { panic("redirect hook broke") }))
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+}
+
+func TestWithRecoveryAsyncHooks(t *testing.T) {
+	var log panicLog
+	h := MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback(), WithRecovery(log.log), WithAsyncHooks(1, 4),
+		WithRedirectHook(func(r *http.Request, path, dest string, status int) { panic("redirect hook broke") }))
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	eventually(t, "the hook panic to be logged", func() bool {
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		return len(log.errs) == 1 && log.errs[0] == "redirect hook broke"
+	})
+}
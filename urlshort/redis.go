@@ -0,0 +1,66 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WithFallbackOnError makes handlers backed by a remote store call
+// the fallback when the store can't be reached. That is the default,
+// the option undoes WithUnavailableOnError.
+func WithFallbackOnError() Option {
+	return func(c *config) {
+		c.unavailableOnError = false
+	}
+}
+
+// RedisHandler will return an http.HandlerFunc that GETs
+// keyPrefix + path from redis on every request and redirects to
+// the stored URL. If the key doesn't exist, the fallback
+// http.Handler will be called instead.
+//
+// Connection errors are passed to the fallback like misses, see
+// WithUnavailableOnError and WithErrorHandler for other answers.
+// RedisHandler panics if the options are invalid.
+//
+// Use LoadToRedis to push parsed entries into redis.
+func RedisHandler(client *redis.Client, keyPrefix string, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	return ResolverHandler(&redisResolver{client: client, keyPrefix: keyPrefix}, fallback, opts...)
+}
+
+// redisResolver looks paths up as keys in redis
+type redisResolver struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func (rr *redisResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	dest, err := rr.client.Get(ctx, rr.keyPrefix+path).Result()
+	switch {
+	case err == nil:
+		return dest, true, nil
+	case errors.Is(err, redis.Nil):
+		return "", false, nil
+	default:
+		return "", false, err
+	}
+}
+
+// LoadToRedis stores every entry under keyPrefix + path, replacing
+// existing keys. The writes are sent in a single pipeline.
+func LoadToRedis(client *redis.Client, keyPrefix string, pathUrls []pathUrl) error {
+	if err := checkEntries(pathUrls); err != nil {
+		return err
+	}
+
+	_, err := client.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for _, pu := range pathUrls {
+			pipe.Set(context.Background(), keyPrefix+pu.Path, pu.URL, 0)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,177 @@
+package urlshort
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis speaks just enough of the redis protocol for GET and
+// SET, there is no redis server in the tests
+type fakeRedis struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	data map[string]string
+}
+
+// startRedis starts a fakeRedis and returns a client for it
+func startRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRedis{ln: ln, data: make(map[string]string)}
+	go fr.serve()
+	client := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true, MaxRetries: -1})
+	t.Cleanup(func() {
+		client.Close()
+		ln.Close()
+	})
+	return fr, client
+}
+
+func (fr *fakeRedis) serve() {
+	for {
+		conn, err := fr.ln.Accept()
+		if err != nil {
+			return
+		}
+		go fr.handle(conn)
+	}
+}
+
+func (fr *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		fr.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := fr.data[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(v), v)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "SET":
+			fr.data[args[1]] = args[2]
+			io.WriteString(conn, "+OK\r\n")
+		case "HELLO":
+			// like a redis 5, the client falls back to RESP2
+			io.WriteString(conn, "-ERR unknown command 'HELLO'\r\n")
+		default:
+			io.WriteString(conn, "+OK\r\n")
+		}
+		fr.mu.Unlock()
+	}
+}
+
+// set stores a key like a SET would
+func (fr *fakeRedis) set(key, value string) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.data[key] = value
+}
+
+// get returns a key like a GET would
+func (fr *fakeRedis) get(key string) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.data[key]
+}
+
+// readCommand reads an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisHandler(t *testing.T) {
+	fr, client := startRedis(t)
+	err := LoadToRedis(client, "urlshort:", []pathUrl{
+		{Path: "/gh", URL: "https://github.com"},
+		{Path: "/go", URL: "https://go.dev"},
+	})
+	if err != nil {
+		t.Fatalf("LoadToRedis: %v", err)
+	}
+	if got := fr.get("urlshort:/gh"); got != "https://github.com" {
+		t.Fatalf("LoadToRedis stored %q for urlshort:/gh", got)
+	}
+	// a key of another prefix
+	fr.set("other:/docs", "https://docs.example.com")
+
+	urlshorttest.TableTest(t, RedisHandler(client, "urlshort:", urlshorttest.Fallback()), []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/missing"},
+		{Path: "/docs"},
+	})
+	urlshorttest.TableTest(t, RedisHandler(client, "other:", urlshorttest.Fallback()), []urlshorttest.Case{
+		{Path: "/docs", Location: "https://docs.example.com", Code: http.StatusFound},
+		{Path: "/gh"},
+	})
+}
+
+func TestRedisHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{"fallback", nil, http.StatusNotFound},
+		{"unavailable", []Option{WithUnavailableOnError()}, http.StatusServiceUnavailable},
+		{"fallback again", []Option{WithUnavailableOnError(), WithFallbackOnError()}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr, client := startRedis(t)
+			fr.set("/gh", "https://github.com")
+			h := RedisHandler(client, "", urlshorttest.Fallback(), tt.opts...)
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{{Path: "/gh", Location: "https://github.com", Code: http.StatusFound}})
+
+			// every command fails from here on
+			fr.ln.Close()
+			client.Close()
+			if res := get(h, "/gh"); res.Code != tt.want {
+				t.Errorf("GET /gh without redis: got %d, want %d", res.Code, tt.want)
+			}
+		})
+	}
+}
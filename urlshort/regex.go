@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// RegexRule maps every path matching Pattern to URL. URL may
+// reference capture groups of the pattern, e.g. $1 or ${id}.
+// Patterns are not anchored automatically, use ^ and $ to
+// match the whole path.
+type RegexRule struct {
+	Pattern *regexp.Regexp
+	URL     string
+	// Code is the redirect status, zero means the handler default
+	Code int
+}
+
+// RegexHandler will return an http.HandlerFunc that redirects
+// paths matching one of the rules. Rules are tried in the order
+// given and the first match wins. If no rule matches, the
+// fallback http.Handler will be called instead.
+//
+// Patterns are compiled by the caller, so they can't fail per
+// request. RegexHandler panics if a rule has no pattern or the
+// options are invalid.
+//
+// YAMLHandler users can get the same behavior per entry with
+// the regex flag.
+func RegexHandler(rules []RegexRule, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	cfg := mustConfig(opts)
+
+	pathUrls := make([]pathUrl, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Pattern == nil {
+			panic(fmt.Sprintf("urlshort: regex rule %d has no pattern", i))
+		}
+		pathUrls = append(pathUrls, pathUrl{Path: rule.Pattern.String(), URL: rule.URL, Code: rule.Code, Regex: true})
+	}
+	if err := prepareEntries(pathUrls); err != nil {
+		panic(err)
+	}
+
+	// the patterns compiled before, so building the table can't fail on them
+	routes, err := buildMap(schemeEntries(pathUrls, cfg), cfg)
+	if err != nil {
+		panic(err)
+	}
+	return routeHandler(routes, mustFallbackFor(fallback, cfg), cfg)
+}
@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestRegexHandler(t *testing.T) {
+	h := RegexHandler([]RegexRule{
+		{Pattern: regexp.MustCompile(`^/issues/(\d+)$`), URL: "https://github.com/NilsKaden/gophercises/issues/$1"},
+		{Pattern: regexp.MustCompile(`^/u/(?P<user>[a-z]+)$`), URL: "https://github.com/${user}", Code: http.StatusMovedPermanently},
+		// not anchored, so it matches anywhere in the path
+		{Pattern: regexp.MustCompile(`legacy`), URL: "https://example.com/legacy"},
+		// never reached for /issues/1, the first match wins
+		{Pattern: regexp.MustCompile(`^/issues/`), URL: "https://example.com/all-issues"},
+	}, urlshorttest.Fallback())
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/issues/42", Location: "https://github.com/NilsKaden/gophercises/issues/42", Code: http.StatusFound},
+		{Path: "/u/nils", Location: "https://github.com/nils", Code: http.StatusMovedPermanently},
+		{Path: "/old/legacy/page", Location: "https://example.com/legacy", Code: http.StatusFound},
+		{Path: "/issues/new", Location: "https://example.com/all-issues", Code: http.StatusFound},
+		{Path: "/u/Nils"},
+		{Path: "/unknown"},
+	})
+}
+
+func TestRegexEntries(t *testing.T) {
+	data := []byte(`
+- path: /docs/latest
+  url: https://go.dev/doc
+- path: ^/docs/(v\d+)/(.*)$
+  url: https://docs.example.com/$2?version=$1
+  regex: true
+- path: ^/Blog/(.+)$
+  url: https://blog.example.com/$1
+  regex: true
+`)
+	h, err := YAMLHandler(data, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// exact entries win over regex entries
+		{Path: "/docs/latest", Location: "https://go.dev/doc", Code: http.StatusFound},
+		{Path: "/docs/v2/intro", Location: "https://docs.example.com/intro?version=v2", Code: http.StatusFound},
+		{Path: "/Blog/hello", Location: "https://blog.example.com/hello", Code: http.StatusFound},
+		{Path: "/blog/hello"},
+	})
+
+	// case-insensitive paths apply to the patterns too
+	h, err = YAMLHandler(data, urlshorttest.Fallback(), WithCaseInsensitivePaths())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/blog/hello", "https://blog.example.com/hello", http.StatusFound)
+}
+
+func TestRegexErrors(t *testing.T) {
+	_, err := YAMLHandler([]byte("- path: ^/(unclosed$\n  url: https://example.com\n  regex: true\n"), urlshorttest.Fallback())
+	wantError(t, err, "^/(unclosed$: invalid regex")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("RegexHandler accepted a rule without a pattern")
+		}
+	}()
+	RegexHandler([]RegexRule{{URL: "https://example.com"}}, urlshorttest.Fallback())
+}
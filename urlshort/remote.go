@@ -0,0 +1,175 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RemoteHandler serves redirects from a YAML document fetched over
+// HTTP and refreshes them periodically.
+type RemoteHandler struct {
+	Handler
+
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu           sync.Mutex
+	etag         string
+	lastModified string
+	lastRefresh  time.Time
+	lastErr      error
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// RemoteYAMLHandler fetches the YAML document at url (in the format
+// YAMLHandler accepts) and serves it like YAMLHandler does. The
+// document is fetched again every refresh interval. ETag and
+// Last-Modified headers are sent back to the server, so unchanged
+// documents are not downloaded and parsed again.
+//
+// If a refresh fails to fetch or parse, the current mappings are
+// left untouched and the error is reported by LastRefresh. Only the
+// first fetch has to succeed, otherwise an error is returned. Call
+// Close to stop refreshing.
+func RemoteYAMLHandler(url string, refresh time.Duration, fallback http.Handler, opts ...Option) (*RemoteHandler, error) {
+	if refresh <= 0 {
+		return nil, fmt.Errorf("urlshort: refresh interval %v must be positive", refresh)
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rh := &RemoteHandler{
+		Handler: Handler{fallback: fallback, cfg: cfg, source: "remote"},
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if err := rh.fetch(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go rh.loop(ctx)
+	return rh, nil
+}
+
+// LastRefresh returns when the mappings were last fetched
+// successfully (or confirmed to be unchanged) and the error of the
+// most recent attempt, which is nil if it succeeded.
+func (rh *RemoteHandler) LastRefresh() (time.Time, error) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	return rh.lastRefresh, rh.lastErr
+}
+
+// Close stops refreshing and waits for a running fetch to finish.
+// The handler keeps serving the last mappings afterwards.
+func (rh *RemoteHandler) Close() error {
+	rh.closeOnce.Do(func() {
+		rh.cancel()
+		<-rh.done
+	})
+	return nil
+}
+
+// loop refreshes the mappings until the context is done
+func (rh *RemoteHandler) loop(ctx context.Context) {
+	defer close(rh.done)
+
+	ticker := time.NewTicker(rh.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rh.fetch(ctx)
+		}
+	}
+}
+
+// fetch downloads the document if it changed and swaps in the new
+// mappings. The result is recorded for LastRefresh.
+func (rh *RemoteHandler) fetch(ctx context.Context) error {
+	err := rh.doFetch(ctx)
+
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.lastErr = err
+	if err == nil {
+		rh.lastRefresh = time.Now()
+	}
+	return err
+}
+
+func (rh *RemoteHandler) doFetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rh.url, nil)
+	if err != nil {
+		return err
+	}
+
+	rh.mu.Lock()
+	if rh.etag != "" {
+		req.Header.Set("If-None-Match", rh.etag)
+	}
+	if rh.lastModified != "" {
+		req.Header.Set("If-Modified-Since", rh.lastModified)
+	}
+	rh.mu.Unlock()
+
+	resp, err := rh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil
+	default:
+		return fmt.Errorf("urlshort: fetching %s: %s", rh.url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("urlshort: fetching %s: empty document", rh.url)
+	}
+
+	pathUrls, err := rh.cfg.parseYAML(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rh.url, err)
+	}
+	if err := rh.Reload(pathUrls); err != nil {
+		return err
+	}
+
+	// only remember the validators once the document was applied, a
+	// broken document must be downloaded again after it's fixed
+	rh.mu.Lock()
+	rh.etag = resp.Header.Get("ETag")
+	rh.lastModified = resp.Header.Get("Last-Modified")
+	rh.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,120 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// remoteDoc serves a YAML document that can be changed, with an
+// ETag per version
+type remoteDoc struct {
+	mu      sync.Mutex
+	body    string
+	status  int
+	version int
+	// sent counts the full documents, notModified the 304 answers
+	sent, notModified int
+}
+
+func (rd *remoteDoc) set(status int, body string) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.status, rd.body = status, body
+	rd.version++
+}
+
+func (rd *remoteDoc) counts() (sent, notModified int) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	return rd.sent, rd.notModified
+}
+
+func (rd *remoteDoc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	etag := fmt.Sprintf(`"v%d"`, rd.version)
+	if r.Header.Get("If-None-Match") == etag {
+		rd.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	rd.sent++
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(rd.status)
+	fmt.Fprint(w, rd.body)
+}
+
+func TestRemoteYAMLHandler(t *testing.T) {
+	doc := &remoteDoc{}
+	doc.set(http.StatusOK, "- path: /gh\n  url: https://github.com\n")
+	srv := httptest.NewServer(doc)
+	defer srv.Close()
+
+	rh, err := RemoteYAMLHandler(srv.URL, 10*time.Millisecond, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("RemoteYAMLHandler: %v", err)
+	}
+	defer rh.Close()
+	urlshorttest.TableTest(t, rh, []urlshorttest.Case{{Path: "/gh", Location: "https://github.com", Code: http.StatusFound}})
+	first, err := rh.LastRefresh()
+	if first.IsZero() || err != nil {
+		t.Fatalf("LastRefresh after the first fetch: %v, %v", first, err)
+	}
+
+	// unchanged documents aren't downloaded again
+	eventually(t, "a 304", func() bool {
+		_, notModified := doc.counts()
+		return notModified > 0
+	})
+	if sent, _ := doc.counts(); sent != 1 {
+		t.Errorf("the unchanged document was sent %d times", sent)
+	}
+
+	doc.set(http.StatusOK, "- path: /gl\n  url: https://gitlab.com\n")
+	eventually(t, "the new document", func() bool { return get(rh, "/gl").Code == http.StatusFound })
+	urlshorttest.TableTest(t, rh, []urlshorttest.Case{{Path: "/gh"}})
+
+	// broken documents and failed fetches keep the mappings
+	for _, tt := range []struct {
+		status int
+		body   string
+	}{
+		{http.StatusOK, "- path: /gl\n  url: [broken\n"},
+		{http.StatusInternalServerError, "oops"},
+		{http.StatusOK, ""},
+	} {
+		doc.set(tt.status, tt.body)
+		eventually(t, "the failed refresh", func() bool {
+			_, err := rh.LastRefresh()
+			return err != nil
+		})
+		urlshorttest.TableTest(t, rh, []urlshorttest.Case{{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound}})
+		// let the next one start out with a good document
+		doc.set(http.StatusOK, "- path: /gl\n  url: https://gitlab.com\n")
+		eventually(t, "the recovery", func() bool {
+			_, err := rh.LastRefresh()
+			return err == nil
+		})
+	}
+	if last, _ := rh.LastRefresh(); !last.After(first) {
+		t.Errorf("LastRefresh still reports %v", last)
+	}
+}
+
+func TestRemoteYAMLHandlerErrors(t *testing.T) {
+	doc := &remoteDoc{}
+	doc.set(http.StatusNotFound, "")
+	srv := httptest.NewServer(doc)
+	defer srv.Close()
+
+	_, err := RemoteYAMLHandler(srv.URL, time.Minute, urlshorttest.Fallback())
+	wantError(t, err, "404")
+	_, err = RemoteYAMLHandler(srv.URL, 0, urlshorttest.Fallback())
+	wantError(t, err, "refresh interval")
+}
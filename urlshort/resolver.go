@@ -0,0 +1,160 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// Resolver finds the destination for a path. It is the seam for
+// plugging in storage of your own, see ResolverHandler. ok is false
+// if the path isn't mapped, err is for the storage failing.
+type Resolver interface {
+	Resolve(ctx context.Context, path string) (dest string, ok bool, err error)
+}
+
+// ResolverFunc turns a function into a Resolver
+type ResolverFunc func(ctx context.Context, path string) (string, bool, error)
+
+// Resolve calls f
+func (f ResolverFunc) Resolve(ctx context.Context, path string) (string, bool, error) {
+	return f(ctx, path)
+}
+
+// ResolverHandler will return an http.HandlerFunc that asks res for
+// the destination of every request path and redirects to it. If the
+// path isn't mapped, the fallback http.Handler will be called
+// instead.
+//
+// Resolver errors are passed to the fallback like misses, unless
+// WithUnavailableOnError or WithErrorHandler is given. Use WithCache
+// to keep results in memory for a while. ResolverHandler panics if
+// the options are invalid.
+//
+// It isn't called Handler because that is the name of the type New
+// returns, the one Reload and HealthHandler work with.
+func ResolverHandler(res Resolver, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	return resolverHandler(res, fallback, mustConfig(opts))
+}
+
+// resolverHandler is the request flow of every Resolver backed
+// handler
+func resolverHandler(res Resolver, fallback http.Handler, cfg *config) http.HandlerFunc {
+	var cache *lookupCache
+	if cfg.cacheTTL > 0 {
+		cache = newLookupCache(cfg.cacheTTL, cfg.cacheSize)
+	}
+	return cachedResolverHandler(res, cache, nil, fallback, cfg)
+}
+
+// cachedResolverHandler is resolverHandler with a cache the caller
+// keeps, to invalidate it on writes, and a bloom filter of the paths
+// of res. cache and bloom may be nil.
+func cachedResolverHandler(res Resolver, cache *lookupCache, bloom *atomic.Pointer[bloomFilter], fallback http.Handler, cfg *config) http.HandlerFunc {
+	backend := resolverBackend(res)
+	fallback = mustFallbackFor(fallback, cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.tracer != nil {
+			var end func()
+			r, end = cfg.startSpan(r, backend)
+			defer end()
+		}
+		path, ok := lookupPath(r, cfg)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		if bloom != nil {
+			if f := bloom.Load(); f != nil && !f.mayContain(path) {
+				serveMiss(w, r, fallback, cfg)
+				return
+			}
+		}
+
+		dest, found, cached := "", false, false
+		if cache != nil {
+			dest, found, cached = cache.get(path)
+		}
+
+		if !cached {
+			var gen uint64
+			if cache != nil {
+				gen = cache.generation()
+			}
+			var err error
+			if cfg.tracer != nil {
+				ctx, end := cfg.startLookupSpan(r.Context(), backend)
+				dest, found, err = res.Resolve(ctx, path)
+				end(found, err)
+			} else {
+				dest, found, err = res.Resolve(r.Context(), path)
+			}
+			if err != nil {
+				// don't cache errors, the next request should try again
+				serveError(w, r, err, fallback, cfg)
+				return
+			}
+			if cache != nil {
+				cache.put(path, dest, found, gen)
+			}
+		}
+
+		if found {
+			serveMatch(w, r, match{entry: pathUrl{Path: path, URL: dest}, dest: dest}, fallback, cfg)
+			return
+		}
+		serveMiss(w, r, fallback, cfg)
+	}
+}
+
+// MapResolver returns a Resolver for the paths in pathsToUrls, with
+// the same matching rules as MapHandler. It panics like MapHandler
+// if the options are invalid.
+func MapResolver(pathsToUrls map[string]string, opts ...Option) Resolver {
+	cfg := mustConfig(opts)
+	pathUrls := make([]pathUrl, 0, len(pathsToUrls))
+	for path, dest := range pathsToUrls {
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+	}
+	routes, err := buildMap(schemeEntries(pathUrls, cfg), cfg)
+	if err != nil {
+		panic(err)
+	}
+	return &tableResolver{routes: routes, cfg: cfg}
+}
+
+// YAMLResolver returns a Resolver for YAML in the format
+// YAMLHandler accepts. Only the destinations are resolved, settings
+// like per-entry codes need YAMLHandler.
+func YAMLResolver(yamlBytes []byte, opts ...Option) (Resolver, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	pathUrls, err := cfg.parseYAML(yamlBytes)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := buildRoutes(pathUrls, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &tableResolver{routes: routes, cfg: cfg}, nil
+}
+
+// tableResolver is a Resolver backed by a route table
+type tableResolver struct {
+	routes *routeTable
+	cfg    *config
+}
+
+func (t *tableResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	m, ok := t.routes.lookup(path)
+	if ok {
+		m, ok = m.current(t.cfg.now())
+	}
+	if !ok || m.entry.Disabled {
+		return "", false, nil
+	}
+	return m.dest, true, nil
+}
@@ -0,0 +1,119 @@
+package urlshort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestResolverHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		res  Resolver
+	}{
+		{"func", ResolverFunc(func(ctx context.Context, path string) (string, bool, error) {
+			if path == "/gh" {
+				return "https://github.com", true, nil
+			}
+			return "", false, nil
+		})},
+		{"map", MapResolver(map[string]string{"/gh": "https://github.com"})},
+		{"yaml", mustYAMLResolver(t, "- path: /gh\n  url: https://github.com\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlshorttest.TableTest(t, ResolverHandler(tt.res, urlshorttest.Fallback()), []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/gh?q=1", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/missing"},
+			})
+		})
+	}
+
+	// the request context reaches the resolver
+	type key struct{}
+	var got any
+	h := ResolverHandler(ResolverFunc(func(ctx context.Context, path string) (string, bool, error) {
+		got = ctx.Value(key{})
+		return "", false, nil
+	}), urlshorttest.Fallback())
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), key{}, "value")))
+	if got != "value" {
+		t.Errorf("the resolver got context value %v", got)
+	}
+}
+
+func mustYAMLResolver(t *testing.T, yaml string) Resolver {
+	t.Helper()
+	res, err := YAMLResolver([]byte(yaml))
+	if err != nil {
+		t.Fatalf("YAMLResolver: %v", err)
+	}
+	return res
+}
+
+func TestResolverHandlerCache(t *testing.T) {
+	var failing atomic.Bool
+	res := &countingResolver{Resolver: ResolverFunc(func(ctx context.Context, path string) (string, bool, error) {
+		if failing.Load() {
+			return "", false, errors.New("connection refused")
+		}
+		return MapResolver(map[string]string{"/gh": "https://github.com"}).Resolve(ctx, path)
+	})}
+	h := ResolverHandler(res, urlshorttest.Fallback(), WithCache(time.Minute))
+	for i := 0; i < 3; i++ {
+		urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+		urlshorttest.AssertFallback(t, h, "/missing")
+	}
+	// hits and misses are both cached
+	if n := res.calls.Load(); n != 2 {
+		t.Errorf("got %d lookups, want one of each path", n)
+	}
+
+	// errors aren't
+	failing.Store(true)
+	for i := 0; i < 2; i++ {
+		urlshorttest.AssertFallback(t, h, "/other")
+	}
+	if n := res.calls.Load(); n != 4 {
+		t.Errorf("got %d lookups, want the failing path asked twice", n-2)
+	}
+
+	// without a cache every request asks
+	res = &countingResolver{Resolver: MapResolver(map[string]string{"/gh": "https://github.com"})}
+	h = ResolverHandler(res, urlshorttest.Fallback())
+	for i := 0; i < 3; i++ {
+		get(h, "/gh")
+	}
+	if n := res.calls.Load(); n != 3 {
+		t.Errorf("got %d lookups, want 3", n)
+	}
+}
+
+func TestResolverErrors(t *testing.T) {
+	_, err := YAMLResolver([]byte("- path: /gh\n  url: ftp://example.com\n"))
+	wantError(t, err, "/gh")
+	_, err = YAMLResolver([]byte("- path: /gh\n"), WithStatus(200))
+	wantError(t, err, "200")
+
+	for name, f := range map[string]func(){
+		"ResolverHandler": func() { ResolverHandler(MapResolver(nil), nil, WithStatus(200)) },
+		"MapResolver":     func() { MapResolver(map[string]string{"/gh": "https://github.com"}, WithStatus(200)) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s didn't panic on an invalid option", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,89 @@
+package urlshort
+
+import (
+	"sort"
+	"strings"
+)
+
+// ReverseLookup returns the paths that redirect to dest, sorted
+func (s *MutableStore) ReverseLookup(dest string) []string {
+	us := s.urlShard(dest)
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return sortedKeys(us.byURL[dest])
+}
+
+// ReverseLookupPrefix returns the paths whose destination starts
+// with prefix, e.g. everything pointing under
+// https://old-wiki.example.com/. The result is sorted.
+func (s *MutableStore) ReverseLookupPrefix(prefix string) []string {
+	var paths []string
+	for i := range s.urlShards {
+		us := &s.urlShards[i]
+		us.mu.Lock()
+		for dest, set := range us.byURL {
+			if strings.HasPrefix(dest, prefix) {
+				for path := range set {
+					paths = append(paths, path)
+				}
+			}
+		}
+		us.mu.Unlock()
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// ReverseLookup returns the configured paths that redirect to dest
+func (h *Handler) ReverseLookup(dest string) []string {
+	return h.routes.Load().reverseLookup(dest, false)
+}
+
+// ReverseLookupPrefix returns the configured paths whose destination
+// starts with prefix
+func (h *Handler) ReverseLookupPrefix(prefix string) []string {
+	return h.routes.Load().reverseLookup(prefix, true)
+}
+
+// reverseLookup uses the reverse index built with the table
+func (rt *routeTable) reverseLookup(dest string, prefix bool) []string {
+	if !prefix {
+		return append([]string(nil), rt.reverse[dest]...)
+	}
+
+	var paths []string
+	for d, list := range rt.reverse {
+		if strings.HasPrefix(d, dest) {
+			paths = append(paths, list...)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// buildReverse indexes the entries by destination, the paths for
+// each destination are sorted.
+func buildReverse(pathUrls []pathUrl) map[string][]string {
+	reverse := make(map[string][]string)
+	for _, pu := range pathUrls {
+		if pu.URL == "" {
+			// pixel entries lead nowhere
+			continue
+		}
+		reverse[pu.URL] = append(reverse[pu.URL], pu.Path)
+	}
+	for _, paths := range reverse {
+		sort.Strings(paths)
+	}
+	return reverse
+}
+
+// sortedKeys returns the keys of set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
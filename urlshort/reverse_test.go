@@ -0,0 +1,62 @@
+package urlshort
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const reverseEntries = `
+- path: /gh
+  url: https://github.com
+- path: /code
+  url: https://github.com
+- path: /nils
+  url: https://github.com/nils
+- path: /go
+  url: https://go.dev
+- path: /pixel
+  mode: pixel
+`
+
+func TestHandlerReverseLookup(t *testing.T) {
+	h, err := New(FromYAML([]byte(reverseEntries)), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tests := []struct {
+		name   string
+		lookup func(string) []string
+		dest   string
+		want   []string
+	}{
+		{"exact", h.ReverseLookup, "https://github.com", []string{"/code", "/gh"}},
+		{"exact doesn't match longer", h.ReverseLookup, "https://github.com/nils", []string{"/nils"}},
+		{"unknown", h.ReverseLookup, "https://gitlab.com", nil},
+		{"prefix", h.ReverseLookupPrefix, "https://github.com", []string{"/code", "/gh", "/nils"}},
+		{"prefix of nothing", h.ReverseLookupPrefix, "https://gitlab.com", nil},
+		// pixel entries have no destination
+		{"everything", h.ReverseLookupPrefix, "", []string{"/code", "/gh", "/go", "/nils"}},
+	}
+	for _, tt := range tests {
+		if got := tt.lookup(tt.dest); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// the result is a copy
+	got := h.ReverseLookup("https://github.com")
+	got[0] = "/changed"
+	if again := h.ReverseLookup("https://github.com"); again[0] != "/code" {
+		t.Errorf("changing the result changed the index: %v", again)
+	}
+
+	// a reload rebuilds the index
+	if err := h.Reload([]Entry{{Path: "/gl", URL: "https://github.com"}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := h.ReverseLookup("https://github.com"); !slices.Equal(got, []string{"/gl"}) {
+		t.Errorf("after Reload: got %v", got)
+	}
+}
@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// entry modes, see proxy.go for modeProxy, interstitial.go for
+// modeInterstitial and pixel.go for modePixel
+const (
+	modeRedirect = "redirect"
+	modeRewrite  = "rewrite"
+)
+
+// originalPathHeader carries the requested path to the fallback
+// when an entry rewrites the request
+const originalPathHeader = "X-Original-Path"
+
+// checkMode makes sure the mode of pu is known and that rewrite
+// entries point at a path on this server.
+func checkMode(pu *pathUrl) error {
+	switch pu.Mode {
+	case "", modeRedirect, modeProxy, modeInterstitial, modePixel:
+		return nil
+	case modeRewrite:
+		u, err := url.Parse(pu.URL)
+		if err != nil {
+			return fmt.Errorf("urlshort: %s: invalid rewrite url %q: %v", pu.Path, pu.URL, err)
+		}
+		if u.Scheme != "" || u.Host != "" {
+			return fmt.Errorf("urlshort: %s: rewrite url %q must be a path, not an absolute URL", pu.Path, pu.URL)
+		}
+		return nil
+	default:
+		return fmt.Errorf("urlshort: %s: unknown mode %q (use redirect, rewrite, proxy, interstitial or pixel)", pu.Path, pu.Mode)
+	}
+}
+
+// serveRewrite passes a copy of r with the path changed to dest to
+// the fallback. A query in dest replaces the one of the request.
+func serveRewrite(w http.ResponseWriter, r *http.Request, path, dest string, fallback http.Handler, cfg *config) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		// checked when the entry was parsed, only substitutions can get here
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// the fallback may pass the request back here, see WithMaxHops
+	ctx, ok := withHop(r.Context(), cfg)
+	if !ok {
+		recordInfo(r, true, path, http.StatusLoopDetected)
+		serveLoopDetected(w)
+		return
+	}
+
+	recordInfo(r, true, path, 0)
+	rewritten := r.Clone(ctx)
+	rewritten.URL.Path, rewritten.URL.RawPath = u.Path, u.RawPath
+	if u.RawQuery != "" {
+		rewritten.URL.RawQuery = u.RawQuery
+	}
+	rewritten.RequestURI = rewritten.URL.RequestURI()
+	rewritten.Header.Set(originalPathHeader, r.URL.Path)
+
+	if cfg.stats != nil {
+		cfg.stats.hit(path, cfg.now())
+	}
+	fallback.ServeHTTP(w, rewritten)
+}
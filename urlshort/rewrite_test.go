@@ -0,0 +1,79 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// echoFallback writes the request it got, to see what a rewrite
+// passed on
+func echoFallback() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s original=%s", r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Original-Path"))
+	})
+}
+
+func TestRewriteMode(t *testing.T) {
+	data := `
+- path: /about
+  url: /pages/about-us.html
+  mode: rewrite
+- path: /search
+  url: /find?engine=internal
+  mode: rewrite
+- path: /u/:name
+  url: /users/:name/profile
+  mode: rewrite
+- path: /gh
+  url: https://github.com
+`
+	h, err := YAMLHandler([]byte(data), echoFallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/about", "/pages/about-us.html  original=/about"},
+		{"/about?x=1", "/pages/about-us.html x=1 original=/about"},
+		{"/search?q=go", "/find engine=internal original=/search"},
+		{"/u/nils", "/users/nils/profile  original=/u/nils"},
+		{"/missing", "/missing  original="},
+	}
+	for _, tt := range tests {
+		res := get(h, tt.target)
+		if res.Code != http.StatusOK || res.Body.String() != tt.want {
+			t.Errorf("GET %s: got %d %q, want 200 %q", tt.target, res.Code, res.Body, tt.want)
+		}
+	}
+	// redirect entries still redirect
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+}
+
+func TestRewriteModeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"absolute url", "- path: /gh\n  url: https://github.com\n  mode: rewrite\n", "must be a path, not an absolute URL"},
+		{"host only", "- path: /gh\n  url: //github.com/x\n  mode: rewrite\n", "must be a path"},
+		{"unknown mode", "- path: /gh\n  url: https://github.com\n  mode: teleport\n", `unknown mode "teleport"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), echoFallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+func TestRewriteLoop(t *testing.T) {
+	// loops between entries are found when the mappings load
+	_, err := YAMLHandler([]byte("- path: /a\n  url: /b\n  mode: rewrite\n- path: /b\n  url: /a\n  mode: rewrite\n"), echoFallback())
+	wantError(t, err, "redirect loop: /a -> /b -> /a")
+}
@@ -0,0 +1,183 @@
+package urlshort
+
+import (
+	"regexp"
+	"strings"
+)
+
+// routeTable finds the entry for a request path. Exact paths are
+// looked up in a map, paths with :name parameters and globs are
+// matched segment by segment, regex entries are tried in the order they
+// were declared and wildcard paths ending in "/*" match every
+// path below them, found with a walk over the prefix trie.
+type routeTable struct {
+	// entries are all entries the table was built from
+	entries []pathUrl
+	// reverse maps each destination to the paths pointing at it
+	reverse map[string][]string
+	// byLength groups the exact keys by length, only built for suggestions
+	byLength map[int][]string
+
+	exact  map[string]pathUrl
+	params []paramRoute
+	globs  []globRoute
+	regex  []regexRoute
+	// prefixes holds the wildcard entries by path segment
+	prefixes prefixTrie
+	// ordered holds params, globs, regex and wildcard entries in the order
+	// they were declared instead, with orderedRules
+	ordered      []orderedRoute
+	orderedRules bool
+	// hosts holds the tables for entries with a host, keyed by
+	// normalizeHost, wildcards the ones for *.domain keyed by domain
+	hosts     map[string]*routeTable
+	wildcards map[string]*routeTable
+	// wildcardApex makes *.domain match domain too
+	wildcardApex bool
+
+	// aliases maps the path of every alias to the path of its entry
+	aliases map[string]string
+
+	// bloom rules out exact paths that aren't mapped, it is only
+	// built for tables of exact entries, see WithBloomFilter
+	bloom *bloomFilter
+
+	// foldCase makes lookups ignore the case of the path
+	foldCase bool
+	// slashes treats /x and /x/ as the same path
+	slashes bool
+}
+
+// match is the result of a successful lookup
+type match struct {
+	entry pathUrl
+	// dest is the destination with any substitutions applied
+	dest string
+}
+
+// regexRoute is an entry whose path is a regular expression
+type regexRoute struct {
+	re    *regexp.Regexp
+	entry pathUrl
+}
+
+// lookupHost returns the entry for path on host. Entries for the
+// host win, then entries for the closest *.domain wildcard, entries
+// without a host are the fallback.
+func (rt *routeTable) lookupHost(host, path string) (match, bool) {
+	if len(rt.hosts) > 0 || len(rt.wildcards) > 0 {
+		host = normalizeHost(host)
+		if hostRoutes := rt.hosts[host]; hostRoutes != nil {
+			if m, ok := hostRoutes.lookup(path); ok {
+				return m, true
+			}
+		}
+		if len(rt.wildcards) > 0 {
+			if m, ok := rt.lookupWildcard(host, path); ok {
+				return m, true
+			}
+		}
+	}
+	return rt.lookup(path)
+}
+
+// lookup returns the entry for path, ignoring host entries. Exact entries win over
+// parameter entries, then globs, regex entries and finally wildcard
+// entries. The first matching regex wins, and the longest
+// matching wildcard wins when they are nested. With ordered rules
+// the first matching pattern entry wins instead.
+func (rt *routeTable) lookup(path string) (match, bool) {
+	key := rt.normalize(path)
+	if rt.definiteMiss(key) {
+		return match{}, false
+	}
+	if pu, ok := rt.exact[key]; ok {
+		return match{entry: pu, dest: pu.URL}, true
+	}
+	// only try the other spelling after the configured one missed,
+	// so an entry for exactly the requested form always wins
+	if alt, ok := rt.slashAlternative(key); ok {
+		if pu, ok := rt.exact[alt]; ok {
+			return match{entry: pu, dest: pu.URL}, true
+		}
+	}
+
+	if rt.orderedRules {
+		return rt.lookupOrdered(path, key)
+	}
+
+	if len(rt.params) > 0 || len(rt.globs) > 0 {
+		segments := paramSegments(path, rt.slashes)
+		for _, pr := range rt.params {
+			if params, ok := pr.match(segments, rt.foldCase); ok {
+				return match{entry: pr.entry, dest: substituteParams(pr.entry.URL, params)}, true
+			}
+		}
+		for _, gr := range rt.globs {
+			if gr.match(segments, rt.foldCase) {
+				return match{entry: gr.entry, dest: gr.entry.URL}, true
+			}
+		}
+	}
+
+	for _, rr := range rt.regex {
+		if idx := rr.re.FindStringSubmatchIndex(path); idx != nil {
+			// fill $1, ${name} etc. in the destination with the capture groups
+			dest := rr.re.ExpandString(nil, rr.entry.URL, path, idx)
+			return match{entry: rr.entry, dest: string(dest)}, true
+		}
+	}
+
+	if pu, ok := rt.prefixes.longest(key); ok {
+		return match{entry: pu, dest: pu.URL}, true
+	}
+
+	return match{}, false
+}
+
+// paramSegments splits path for matching parameter entries.
+// Parameters get their values from the path as it was requested.
+func paramSegments(path string, slashes bool) []string {
+	segments := strings.Split(path, "/")
+	if slashes && len(segments) > 2 && segments[len(segments)-1] == "" {
+		segments = segments[:len(segments)-1]
+	}
+	return segments
+}
+
+// normalize returns the form of path used as a key in the table.
+// Configured paths and request paths both go through it, so they
+// always compare equal.
+func (rt *routeTable) normalize(path string) string {
+	if rt.foldCase {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// slashAlternative returns path with the trailing slash added or
+// removed when slash normalization is on, see otherSlash
+func (rt *routeTable) slashAlternative(path string) (string, bool) {
+	if !rt.slashes {
+		return "", false
+	}
+	return otherSlash(path)
+}
+
+// otherSlash returns path with the trailing slash added or removed.
+// The root path has no alternative, it must never become the empty
+// string.
+func otherSlash(path string) (string, bool) {
+	if path == "/" || path == "" {
+		return "", false
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/"), true
+	}
+	return path + "/", true
+}
+
+// isWildcard reports whether path is a prefix pattern like /docs/*
+func isWildcard(path string) bool {
+	return strings.HasSuffix(path, "/*")
+}
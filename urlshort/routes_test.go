@@ -0,0 +1,63 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestWildcardPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths map[string]string
+		cases []urlshorttest.Case
+	}{
+		{
+			name: "exact beats prefix",
+			paths: map[string]string{
+				"/docs/*":    "https://docs.example.com",
+				"/docs/faq":  "https://faq.example.com",
+				"/docs/faq/": "https://faq.example.com/slash",
+			},
+			cases: []urlshorttest.Case{
+				{Path: "/docs/faq", Location: "https://faq.example.com", Code: http.StatusFound},
+				{Path: "/docs/faq/", Location: "https://faq.example.com/slash", Code: http.StatusFound},
+				{Path: "/docs/getting-started", Location: "https://docs.example.com", Code: http.StatusFound},
+				{Path: "/docs/faq/more", Location: "https://docs.example.com", Code: http.StatusFound},
+				{Path: "/docsx"},
+			},
+		},
+		{
+			name: "nested prefixes",
+			paths: map[string]string{
+				"/docs/*":     "https://docs.example.com",
+				"/docs/api/*": "https://api.example.com",
+			},
+			cases: []urlshorttest.Case{
+				{Path: "/docs/guide", Location: "https://docs.example.com", Code: http.StatusFound},
+				{Path: "/docs/api/v1", Location: "https://api.example.com", Code: http.StatusFound},
+				{Path: "/docs/api/v1/users", Location: "https://api.example.com", Code: http.StatusFound},
+				{Path: "/docs/apis", Location: "https://docs.example.com", Code: http.StatusFound},
+				{Path: "/other"},
+			},
+		},
+		{
+			name: "root",
+			paths: map[string]string{
+				"/*":  "https://example.com",
+				"/gh": "https://github.com",
+			},
+			cases: []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/anything", Location: "https://example.com", Code: http.StatusFound},
+				{Path: "/deeply/nested/path", Location: "https://example.com", Code: http.StatusFound},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlshorttest.TableTest(t, MapHandler(tt.paths, urlshorttest.Fallback()), tt.cases)
+		})
+	}
+}
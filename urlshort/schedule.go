@@ -0,0 +1,76 @@
+package urlshort
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithClock sets the clock used for expiry, active windows and
+// everything else time related, so tests don't have to sleep. The
+// default is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+// checkWindow parses the active window of pu
+func checkWindow(pu *pathUrl) error {
+	var err error
+	if pu.activeFrom, err = parseEntryTime(pu, "active_from", pu.ActiveFrom); err != nil {
+		return err
+	}
+	if pu.activeUntil, err = parseEntryTime(pu, "active_until", pu.ActiveUntil); err != nil {
+		return err
+	}
+	if !pu.activeFrom.IsZero() && !pu.activeUntil.IsZero() && !pu.activeFrom.Before(pu.activeUntil) {
+		return fmt.Errorf("urlshort: %s: active_from %s is not before active_until %s", pu.Path, pu.ActiveFrom, pu.ActiveUntil)
+	}
+	if pu.InactiveURL != "" && pu.activeFrom.IsZero() && pu.activeUntil.IsZero() {
+		return fmt.Errorf("urlshort: %s: inactive_url needs active_from or active_until", pu.Path)
+	}
+	return nil
+}
+
+// parseEntryTime parses the RFC 3339 timestamp value of field, the
+// empty string is the zero time
+func parseEntryTime(pu *pathUrl, field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("urlshort: %s: invalid %s timestamp %q (use RFC 3339)", pu.Path, field, value)
+	}
+	return t, nil
+}
+
+// active reports whether now is inside the active window of the
+// entry. Entries without a window are always active.
+func (pu pathUrl) active(now time.Time) bool {
+	if !pu.activeFrom.IsZero() && now.Before(pu.activeFrom) {
+		return false
+	}
+	return pu.activeUntil.IsZero() || now.Before(pu.activeUntil)
+}
+
+// current returns what m means at now: nothing once the entry has
+// expired or outside its active window, unless it has an inactive
+// url to redirect to instead.
+func (m match) current(now time.Time) (match, bool) {
+	if m.entry.expired(now) {
+		return match{}, false
+	}
+	if m.entry.active(now) {
+		return m, true
+	}
+	if m.entry.InactiveURL == "" {
+		return match{}, false
+	}
+	// a plain redirect, whatever the entry does while it's active
+	inactive := m.entry
+	inactive.URLs, inactive.Mode = nil, modeRedirect
+	inactive.Geo, inactive.Lang, inactive.Device = nil, nil, nil
+	inactive.Template, inactive.tmpl = false, nil
+	return match{entry: inactive, dest: inactive.InactiveURL}, true
+}
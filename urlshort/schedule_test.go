@@ -0,0 +1,185 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// inactiveEntries starts every entry in 2025-01-02, before that
+// they send clients to the waiting page
+const inactiveEntries = `
+- path: /geo
+  geo:
+    de: https://shop.example.de
+    default: https://shop.example.com
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/soon
+- path: /lang
+  lang:
+    de: https://docs.example.com/de
+    default: https://docs.example.com/en
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/soon
+- path: /device
+  device:
+    ios: https://apps.apple.com/app
+    default: https://example.com/app
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/soon
+- path: /search
+  url: https://search.example.com/{{.Query.Get "q"}}
+  template: true
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/soon
+- path: /weighted
+  urls:
+    - url: https://a.example.com
+      weight: 1
+    - url: https://b.example.com
+      weight: 1
+  active_from: 2025-01-02T00:00:00Z
+  inactive_url: https://example.com/soon
+`
+
+func TestInactiveDestinations(t *testing.T) {
+	clock := newFakeClock()
+	h, err := YAMLHandler([]byte(inactiveEntries), urlshorttest.Fallback(), WithClock(clock.Now), WithGeoHeader("cf-ipcountry"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	header := http.Header{
+		"Cf-Ipcountry":    {"DE"},
+		"Accept-Language": {"de"},
+		"User-Agent":      {"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"},
+	}
+	tests := []struct {
+		path   string
+		active string
+	}{
+		{"/geo", "https://shop.example.de"},
+		{"/lang", "https://docs.example.com/de"},
+		{"/device", "https://apps.apple.com/app"},
+		{"/search?q=go", "https://search.example.com/go"},
+		{"/weighted", ""},
+	}
+	for _, tt := range tests {
+		// whatever the request, the inactive url wins
+		res := requestFrom(h, tt.path, "198.51.100.1:5000", header)
+		if res.Code != http.StatusFound || res.Header().Get("Location") != "https://example.com/soon" {
+			t.Errorf("GET %s while inactive: got %d to %q", tt.path, res.Code, res.Header().Get("Location"))
+		}
+	}
+
+	clock.Advance(24 * time.Hour)
+	for _, tt := range tests {
+		res := requestFrom(h, tt.path, "198.51.100.1:5000", header)
+		loc := res.Header().Get("Location")
+		if res.Code != http.StatusFound || loc == "https://example.com/soon" || tt.active != "" && loc != tt.active {
+			t.Errorf("GET %s while active: got %d to %q, want %q", tt.path, res.Code, loc, tt.active)
+		}
+	}
+}
+
+// windowEntries are active from 10:00 to 12:00 on 2025-01-01, the
+// day the fake clock starts
+const windowEntries = `
+- path: /sale
+  url: https://example.com/sale
+  active_from: 2025-01-01T10:00:00Z
+  active_until: 2025-01-01T12:00:00Z
+  inactive_url: https://example.com/over
+- path: /launch
+  url: https://example.com/launch
+  active_from: 2025-01-01T10:00:00Z
+- path: /flash
+  url: https://example.com/flash
+  active_until: 2025-01-01T12:00:00Z
+- path: /promo
+  url: https://example.com/promo
+  active_from: 2025-01-01T10:00:00Z
+  active_until: 2025-01-01T12:00:00Z
+  inactive_url: https://example.com/over
+  expires: 2025-01-01T11:00:00Z
+`
+
+func TestActiveWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Duration
+		want []urlshorttest.Case
+	}{
+		{"before", 9 * time.Hour, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/over", Code: http.StatusFound},
+			// without an inactive url it's a miss
+			{Path: "/launch"},
+			{Path: "/flash", Location: "https://example.com/flash", Code: http.StatusFound},
+			{Path: "/promo", Location: "https://example.com/over", Code: http.StatusFound},
+		}},
+		{"just before", 10*time.Hour - time.Nanosecond, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/over", Code: http.StatusFound},
+			{Path: "/launch"},
+		}},
+		// active_from is part of the window
+		{"from", 10 * time.Hour, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/sale", Code: http.StatusFound},
+			{Path: "/launch", Location: "https://example.com/launch", Code: http.StatusFound},
+			{Path: "/flash", Location: "https://example.com/flash", Code: http.StatusFound},
+			{Path: "/promo", Location: "https://example.com/promo", Code: http.StatusFound},
+		}},
+		// expiry wins over the window and the inactive url
+		{"expired inside", 11 * time.Hour, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/sale", Code: http.StatusFound},
+			{Path: "/promo"},
+		}},
+		{"just inside", 12*time.Hour - time.Nanosecond, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/sale", Code: http.StatusFound},
+			{Path: "/flash", Location: "https://example.com/flash", Code: http.StatusFound},
+		}},
+		// active_until isn't
+		{"until", 12 * time.Hour, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/over", Code: http.StatusFound},
+			{Path: "/launch", Location: "https://example.com/launch", Code: http.StatusFound},
+			{Path: "/flash"},
+			{Path: "/promo"},
+		}},
+		{"after", 36 * time.Hour, []urlshorttest.Case{
+			{Path: "/sale", Location: "https://example.com/over", Code: http.StatusFound},
+			{Path: "/launch", Location: "https://example.com/launch", Code: http.StatusFound},
+			{Path: "/flash"},
+			{Path: "/promo"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			clock.Advance(tt.at)
+			h, err := YAMLHandler([]byte(windowEntries), urlshorttest.Fallback(), WithClock(clock.Now))
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			urlshorttest.TableTest(t, h, tt.want)
+		})
+	}
+}
+
+func TestActiveWindowErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"empty window", "- path: /x\n  url: https://example.com\n  active_from: 2025-01-01T12:00:00Z\n  active_until: 2025-01-01T12:00:00Z\n",
+			"/x: active_from 2025-01-01T12:00:00Z is not before active_until"},
+		{"inactive url without a window", "- path: /x\n  url: https://example.com\n  inactive_url: https://example.com/over\n",
+			"/x: inactive_url needs active_from or active_until"},
+		{"invalid timestamp", "- path: /x\n  url: https://example.com\n  active_from: 2025-01-01\n",
+			`/x: invalid active_from timestamp "2025-01-01"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package urlshort
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithDefaultScheme puts scheme in front of destinations that look
+// like a host but have no scheme, so github.com/foo becomes
+// https://github.com/foo with WithDefaultScheme("https") instead of
+// a relative redirect. A destination looks like a host if its first
+// path segment contains a dot. Paths like /internal/page are left
+// alone, see WithRelativeDestinations.
+func WithDefaultScheme(scheme string) Option {
+	return func(c *config) {
+		c.defaultScheme = scheme
+	}
+}
+
+// schemeEntries returns pathUrls with the default scheme added to
+// every destination that lacks one. Rewrite destinations are paths
+// on this server and stay as they are.
+func schemeEntries(pathUrls []pathUrl, cfg *config) []pathUrl {
+	if cfg.defaultScheme == "" {
+		return pathUrls
+	}
+
+	var fixed []pathUrl
+	for i, pu := range pathUrls {
+		if pu.Mode == modeRewrite || !needsScheme(pu) {
+			continue
+		}
+		// copy on the first change, the caller's entries stay as they are
+		if fixed == nil {
+			fixed = append([]pathUrl(nil), pathUrls...)
+		}
+		entry := &fixed[i]
+		entry.URLs = append([]weightedURL(nil), entry.URLs...)
+		entry.URL = addScheme(entry.URL, cfg.defaultScheme)
+		entry.InactiveURL = addScheme(entry.InactiveURL, cfg.defaultScheme)
+		for j := range entry.URLs {
+			entry.URLs[j].URL = addScheme(entry.URLs[j].URL, cfg.defaultScheme)
+		}
+		entry.mapDestinations(func(dest string) string {
+			return addScheme(dest, cfg.defaultScheme)
+		})
+	}
+	if fixed == nil {
+		return pathUrls
+	}
+	return fixed
+}
+
+// needsScheme reports whether any destination of pu looks like a
+// host without a scheme
+func needsScheme(pu pathUrl) bool {
+	if looksLikeHost(pu.URL) || looksLikeHost(pu.InactiveURL) {
+		return true
+	}
+	for _, wu := range pu.URLs {
+		if looksLikeHost(wu.URL) {
+			return true
+		}
+	}
+	return pu.anyMapDestination(looksLikeHost)
+}
+
+// addScheme puts scheme in front of dest if it looks like a host
+func addScheme(dest, scheme string) string {
+	if !looksLikeHost(dest) {
+		return dest
+	}
+	return scheme + "://" + dest
+}
+
+// looksLikeHost reports whether dest has no scheme and a first path
+// segment with a dot in it, like github.com/foo
+func looksLikeHost(dest string) bool {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.HasPrefix(u.Path, "/") {
+		return false
+	}
+	first, _, _ := strings.Cut(u.Path, "/")
+	return strings.Contains(first, ".")
+}
@@ -0,0 +1,102 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestLooksLikeHost(t *testing.T) {
+	tests := []struct {
+		dest string
+		want bool
+	}{
+		{"github.com", true},
+		{"github.com/foo?q=1", true},
+		{"localhost:8080/x", false},
+		{"docs/page", false},
+		{"/internal/page", false},
+		{"/file.html", false},
+		{"https://github.com", false},
+		{"//github.com/foo", false},
+		{"mailto:a@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := looksLikeHost(tt.dest); got != tt.want {
+			t.Errorf("looksLikeHost(%q) = %v, want %v", tt.dest, got, tt.want)
+		}
+	}
+}
+
+const schemeEntriesYAML = `
+- path: /gh
+  url: github.com/nils
+- path: /full
+  url: http://example.com
+- path: /ab
+  urls:
+    - url: a.example.com
+      weight: 1
+    - url: https://b.example.com
+      weight: 1
+- path: /geo
+  geo:
+    de: de.example.com
+    default: example.com
+`
+
+func TestDefaultScheme(t *testing.T) {
+	h, err := YAMLHandler([]byte(schemeEntriesYAML), urlshorttest.Fallback(), WithDefaultScheme("https"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/full", Location: "http://example.com", Code: http.StatusFound},
+		{Path: "/geo", Location: "https://example.com", Code: http.StatusFound},
+	})
+
+	// without it hosts without a scheme are an error
+	_, err = YAMLHandler([]byte(schemeEntriesYAML), urlshorttest.Fallback())
+	wantError(t, err, `url "github.com/nils" is missing a scheme`)
+
+	// relative destinations stay relative
+	h, err = YAMLHandler([]byte("- path: /a\n  url: /b\n- path: /b\n  url: docs.example.com\n"), urlshorttest.Fallback(),
+		WithDefaultScheme("https"), WithRelativeDestinations())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/a", "/b", http.StatusFound)
+	urlshorttest.AssertRedirect(t, h, "/b", "https://docs.example.com", http.StatusFound)
+}
+
+func TestSchemeEntries(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.defaultScheme = "https"
+	pathUrls := []pathUrl{
+		{Path: "/full", URL: "https://example.com"},
+		{Path: "/ab", URLs: []weightedURL{{URL: "a.example.com", Weight: 1}}, InactiveURL: "ended.example.com",
+			Geo: map[string]string{"de": "de.example.com"}},
+		{Path: "/rw", URL: "files.example.com", Mode: modeRewrite},
+	}
+	got := schemeEntries(pathUrls, cfg)
+	if got[1].URLs[0].URL != "https://a.example.com" || got[1].InactiveURL != "https://ended.example.com" || got[1].Geo["de"] != "https://de.example.com" {
+		t.Errorf("got %+v", got[1])
+	}
+	// rewrites are paths on this server
+	if got[2].URL != "files.example.com" {
+		t.Errorf("the rewrite got %q", got[2].URL)
+	}
+	// the entries passed in stay as they are
+	if pathUrls[1].URLs[0].URL != "a.example.com" || pathUrls[1].Geo["de"] != "de.example.com" {
+		t.Errorf("the entry was changed: %+v", pathUrls[1])
+	}
+
+	// nothing to fix returns the entries themselves
+	plain := []pathUrl{{Path: "/full", URL: "https://example.com"}}
+	if got := schemeEntries(plain, cfg); &got[0] != &plain[0] {
+		t.Error("entries without a change were copied")
+	}
+}
@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// storeShard holds the mappings of a MutableStore whose paths hash
+// to it
+type storeShard struct {
+	mu      sync.RWMutex
+	entries map[string]pathUrl
+}
+
+// urlShard holds the reverse index of a MutableStore for the URLs
+// that hash to it, the paths pointing at each URL
+type urlShard struct {
+	mu    sync.Mutex
+	byURL map[string]map[string]bool
+}
+
+// WithShards splits the store into n shards by the hash of the path,
+// each with a lock of its own, so writes to different shards don't
+// wait for each other and bulk writes only stall the readers of one
+// shard at a time. Listing the mappings, e.g. for Export or Stats,
+// copies one shard after the other instead of stopping all writes.
+// The default is a single shard, like the store always was.
+func WithShards(n int) StoreOption {
+	return func(s *MutableStore) {
+		if n > 0 {
+			s.makeShards(n)
+		}
+	}
+}
+
+func (s *MutableStore) makeShards(n int) {
+	s.shards = make([]storeShard, n)
+	s.urlShards = make([]urlShard, n)
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]pathUrl)
+		s.urlShards[i].byURL = make(map[string]map[string]bool)
+	}
+}
+
+// shardIndex picks one of n shards for key
+func shardIndex(key string, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// shard returns the shard of path
+func (s *MutableStore) shard(path string) *storeShard {
+	return &s.shards[shardIndex(path, len(s.shards))]
+}
+
+// urlShard returns the reverse index shard of dest
+func (s *MutableStore) urlShard(dest string) *urlShard {
+	return &s.urlShards[shardIndex(dest, len(s.urlShards))]
+}
+
+// each calls fn for every mapping, holding the lock of one shard at
+// a time. fn must not call back into the store.
+func (s *MutableStore) each(fn func(pu pathUrl)) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for _, pu := range sh.entries {
+			fn(pu)
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+// putNew stores pu unless its path is mapped already, and reports
+// whether it did
+func (s *MutableStore) putNew(pu pathUrl) bool {
+	sh := s.shard(pu.Path)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, taken := sh.entries[pu.Path]; taken {
+		return false
+	}
+	s.put(sh, pu)
+	return true
+}
+
+// BulkSet stores many mappings like Set does, e.g. for an import.
+// Only the path and url of each entry are kept. The entries are
+// grouped by shard, so each shard is locked once. Every mapping is
+// validated first, if one is invalid the store is left as it was.
+// Unlike Replace, readers may see some of the mappings before the
+// others.
+func (s *MutableStore) BulkSet(pathUrls []pathUrl) error {
+	for i := range pathUrls {
+		if err := validateMapping(pathUrls[i].Path, pathUrls[i].URL); err != nil {
+			return err
+		}
+	}
+
+	// group indexes, pathUrl is too large to copy around
+	byShard := make([][]int, len(s.shards))
+	for i := range pathUrls {
+		si := shardIndex(pathUrls[i].Path, len(s.shards))
+		byShard[si] = append(byShard[si], i)
+	}
+	for si, indexes := range byShard {
+		if len(indexes) == 0 {
+			continue
+		}
+		sh := &s.shards[si]
+		sh.mu.Lock()
+		for _, i := range indexes {
+			s.put(sh, pathUrl{Path: pathUrls[i].Path, URL: pathUrls[i].URL})
+		}
+		sh.mu.Unlock()
+	}
+	return nil
+}
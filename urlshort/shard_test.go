@@ -0,0 +1,307 @@
+package urlshort
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestShardIndex(t *testing.T) {
+	const n = 64
+	counts := make([]int, n)
+	for i := 0; i < 10_000; i++ {
+		path := fmt.Sprintf("/p%d", i)
+		idx := shardIndex(path, n)
+		if idx != shardIndex(path, n) {
+			t.Fatalf("%s moves between shards", path)
+		}
+		counts[idx]++
+		if shardIndex(path, 1) != 0 {
+			t.Fatalf("%s isn't in the only shard", path)
+		}
+	}
+	// about 156 per shard, none should be far off
+	for i, c := range counts {
+		if c < 80 || c > 240 {
+			t.Errorf("shard %d has %d of 10000 paths", i, c)
+		}
+	}
+}
+
+func TestShardedStore(t *testing.T) {
+	for _, shards := range []int{0, 1, 4, 64} {
+		t.Run(fmt.Sprintf("%d shards", shards), func(t *testing.T) {
+			store := NewMutableStore(WithShards(shards))
+			want := max(shards, 1)
+			if len(store.shards) != want || len(store.urlShards) != want {
+				t.Fatalf("got %d and %d shards, want %d", len(store.shards), len(store.urlShards), want)
+			}
+
+			routes := make(map[string]string)
+			for i := 0; i < 200; i++ {
+				path, dest := fmt.Sprintf("/p%d", i), fmt.Sprintf("https://example.com/%d", i%10)
+				if err := store.Add(path, dest); err != nil {
+					t.Fatalf("Add: %v", err)
+				}
+				routes[path] = dest
+			}
+			if err := store.Add("/p1", "https://example.com/x"); err == nil {
+				t.Error("adding /p1 twice worked")
+			}
+			store.Set("/p1", "https://example.com/set")
+			routes["/p1"] = "https://example.com/set"
+			store.Remove("/p2")
+			delete(routes, "/p2")
+			store.Disable("/p3")
+
+			if got := store.Routes(); !maps.Equal(got, routes) {
+				t.Errorf("Routes: got %d mappings, want %d", len(got), len(routes))
+			}
+			// the reverse index spans the shards
+			wantPaths := []string{"/p10", "/p100", "/p110", "/p120", "/p130", "/p140", "/p150", "/p160", "/p170", "/p180", "/p190", "/p20", "/p30", "/p40", "/p50", "/p60", "/p70", "/p80", "/p90"}
+			if got := store.ReverseLookup("https://example.com/0"); !slices.Equal(got, append([]string{"/p0"}, wantPaths...)) {
+				t.Errorf("ReverseLookup: got %v", got)
+			}
+			if got := store.ReverseLookup("https://example.com/set"); !slices.Equal(got, []string{"/p1"}) {
+				t.Errorf("ReverseLookup of a Set: got %v", got)
+			}
+			if got := store.ReverseLookup("https://example.com/2"); slices.Contains(got, "/p2") {
+				t.Errorf("ReverseLookup after Remove: got %v", got)
+			}
+			if got := len(store.ReverseLookupPrefix("https://example.com/")); got != 199 {
+				t.Errorf("ReverseLookupPrefix: got %d paths, want 199", got)
+			}
+			entries := store.entryList()
+			if len(entries) != 199 || !slices.IsSortedFunc(entries, func(a, b pathUrl) int { return strings.Compare(a.Path, b.Path) }) {
+				t.Errorf("entryList: %d entries, sorted %v", len(entries), entries)
+			}
+
+			h := NewMutableHandler(store, urlshorttest.Fallback())
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/p1", Location: "https://example.com/set", Code: http.StatusFound},
+				{Path: "/p199", Location: "https://example.com/9", Code: http.StatusFound},
+				{Path: "/p2"},
+				{Path: "/p3"},
+			})
+
+			// Replace swaps the mappings of every shard
+			if err := store.Replace(map[string]string{"/gh": "https://github.com"}); err != nil {
+				t.Fatalf("Replace: %v", err)
+			}
+			if got := store.Routes(); len(got) != 1 || got["/gh"] != "https://github.com" {
+				t.Errorf("after Replace: %v", got)
+			}
+			if got := store.ReverseLookupPrefix("https://example.com/"); len(got) != 0 {
+				t.Errorf("after Replace the reverse index has %v", got)
+			}
+		})
+	}
+}
+
+func TestBulkSet(t *testing.T) {
+	for _, shards := range []int{1, 16} {
+		t.Run(fmt.Sprintf("%d shards", shards), func(t *testing.T) {
+			store := NewMutableStore(WithShards(shards))
+			store.Set("/old", "https://example.com/old")
+			store.Set("/gh", "https://gitlab.com")
+			store.Disable("/gh")
+
+			var pathUrls []pathUrl
+			for i := 0; i < 1000; i++ {
+				pathUrls = append(pathUrls, pathUrl{Path: fmt.Sprintf("/b%d", i), URL: fmt.Sprintf("https://example.com/%d", i)})
+			}
+			pathUrls = append(pathUrls,
+				// only the path and url are kept, existing mappings
+				// are replaced like by Set
+				pathUrl{Path: "/gh", URL: "https://github.com", Code: http.StatusMovedPermanently, Disabled: true},
+				// the last entry for a path wins
+				pathUrl{Path: "/b7", URL: "https://example.com/again"},
+			)
+			if err := store.BulkSet(pathUrls); err != nil {
+				t.Fatalf("BulkSet: %v", err)
+			}
+			if got := len(store.Routes()); got != 1002 {
+				t.Errorf("got %d mappings, want 1002", got)
+			}
+			h := NewMutableHandler(store, urlshorttest.Fallback())
+			urlshorttest.TableTest(t, h, []urlshorttest.Case{
+				{Path: "/b0", Location: "https://example.com/0", Code: http.StatusFound},
+				{Path: "/b999", Location: "https://example.com/999", Code: http.StatusFound},
+				{Path: "/b7", Location: "https://example.com/again", Code: http.StatusFound},
+				{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/old", Location: "https://example.com/old", Code: http.StatusFound},
+			})
+			if got := store.ReverseLookup("https://gitlab.com"); len(got) != 0 {
+				t.Errorf("the replaced url still points at %v", got)
+			}
+
+			// one invalid entry and nothing is stored
+			err := store.BulkSet([]pathUrl{{Path: "/new", URL: "https://example.com/new"}, {Path: "/b0", URL: "https://example.com/changed"}, {Path: "bad", URL: "https://example.com"}})
+			wantError(t, err, "bad")
+			if _, ok := store.Lookup("/new"); ok {
+				t.Error("/new was stored with an invalid entry")
+			}
+			if dest, _ := store.Lookup("/b0"); dest != "https://example.com/0" {
+				t.Errorf("/b0 changed to %q with an invalid entry", dest)
+			}
+			if err := store.BulkSet(nil); err != nil {
+				t.Errorf("BulkSet(nil): %v", err)
+			}
+		})
+	}
+}
+
+func TestShardedStoreConcurrent(t *testing.T) {
+	store := NewMutableStore(WithShards(8))
+	h := NewMutableHandler(store, urlshorttest.Fallback(), WithStats(NewStats()))
+	store.Set("/gh", "https://github.com")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				batch := make([]pathUrl, 50)
+				for k := range batch {
+					batch[k] = pathUrl{Path: fmt.Sprintf("/w%d-%d", i, k), URL: fmt.Sprintf("https://example.com/%d", j)}
+				}
+				if err := store.BulkSet(batch); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				path := fmt.Sprintf("/w%d-%d", i, j%50)
+				store.Remove(path)
+				store.Set(path, "https://example.com/set")
+				store.Disable(path)
+				store.Enable(path)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				get(h, fmt.Sprintf("/w%d-%d", i, j%50))
+				urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				// listing never sees a path twice or a half written entry
+				seen := make(map[string]bool)
+				for _, pu := range store.entryList() {
+					if seen[pu.Path] || pu.URL == "" {
+						t.Errorf("entryList has %+v", pu)
+					}
+					seen[pu.Path] = true
+				}
+				store.ReverseLookupPrefix("https://example.com/")
+				if _, err := store.Export(FormatJSON); err != nil {
+					t.Errorf("Export: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the reverse index agrees with the mappings afterwards
+	reverse := make(map[string]string)
+	for _, path := range store.ReverseLookupPrefix("") {
+		reverse[path] = ""
+	}
+	routes := store.Routes()
+	if len(reverse) != len(routes) {
+		t.Errorf("the reverse index has %d paths, the store %d", len(reverse), len(routes))
+	}
+	for path, dest := range routes {
+		if !slices.Contains(store.ReverseLookup(dest), path) {
+			t.Errorf("%s isn't in the reverse index of %s", path, dest)
+		}
+	}
+}
+
+// BenchmarkMutableStore serves lookups from parallel goroutines while
+// one in ten operations writes, with a single lock and with shards
+func BenchmarkMutableStore(b *testing.B) {
+	const n = 10_000
+	for _, shards := range []int{1, 64} {
+		b.Run(fmt.Sprintf("mixed/shards=%d", shards), func(b *testing.B) {
+			store := NewMutableStore(WithShards(shards))
+			for i := 0; i < n; i++ {
+				store.Set(fmt.Sprintf("/p%d", i), "https://example.com")
+			}
+			paths := make([]string, n)
+			for i := range paths {
+				paths[i] = fmt.Sprintf("/p%d", i)
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					path := paths[i%n]
+					if i%10 == 0 {
+						store.Set(path, "https://example.com/new")
+					} else {
+						store.Lookup(path)
+					}
+					i++
+				}
+			})
+		})
+	}
+
+	// a 50k entry import, one Set per entry and one BulkSet, while
+	// readers keep looking up
+	pathUrls := make([]pathUrl, 50_000)
+	for i := range pathUrls {
+		pathUrls[i] = pathUrl{Path: fmt.Sprintf("/i%d", i), URL: "https://example.com"}
+	}
+	for _, shards := range []int{1, 64} {
+		for _, bulk := range []bool{false, true} {
+			name := fmt.Sprintf("import/shards=%d/Set", shards)
+			if bulk {
+				name = fmt.Sprintf("import/shards=%d/BulkSet", shards)
+			}
+			b.Run(name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					store := NewMutableStore(WithShards(shards))
+					stop := make(chan struct{})
+					var wg sync.WaitGroup
+					for r := 0; r < 4; r++ {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							for j := 0; ; j++ {
+								select {
+								case <-stop:
+									return
+								default:
+									store.Lookup(pathUrls[j%len(pathUrls)].Path)
+								}
+							}
+						}()
+					}
+					if bulk {
+						store.BulkSet(pathUrls)
+					} else {
+						for _, pu := range pathUrls {
+							store.Set(pu.Path, pu.URL)
+						}
+					}
+					close(stop)
+					wg.Wait()
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package urlshort
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	// defaultCodeLength gives 62^6, about 56 billion, codes
+	defaultCodeLength = 6
+	// shortenAttempts is how often Shorten retries on a collision
+	shortenAttempts = 10
+)
+
+// base62 is the alphabet of generated codes
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrNoFreeCode is returned when Shorten can't find an unused code
+var ErrNoFreeCode = errors.New("urlshort: no free short code found, consider a longer code length")
+
+// WithCodeLength sets the length of the codes Shorten generates,
+// the default is 6.
+func WithCodeLength(n int) StoreOption {
+	return func(s *MutableStore) {
+		if n > 0 {
+			s.codeLength = n
+		}
+	}
+}
+
+// Shorten mints a new short path like /aZ3k9Q for dest and stores
+// the mapping. If dest is already mapped, the existing path is
+// returned instead of minting a new one.
+func (s *MutableStore) Shorten(dest string) (string, error) {
+	if err := validateDestination(dest); err != nil {
+		return "", err
+	}
+
+	s.shortenMu.Lock()
+	defer s.shortenMu.Unlock()
+
+	if paths := s.ReverseLookup(dest); len(paths) > 0 {
+		return paths[0], nil
+	}
+
+	for attempt := 0; attempt < shortenAttempts; attempt++ {
+		code, err := randomCode(s.codeLength)
+		if err != nil {
+			return "", err
+		}
+		path := "/" + code
+		if s.putNew(pathUrl{Path: path, URL: dest}) {
+			return path, nil
+		}
+	}
+	return "", ErrNoFreeCode
+}
+
+// randomCode returns n random base62 characters
+func randomCode(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(base62)))
+	for i := range b {
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("urlshort: generating short code: %v", err)
+		}
+		b[i] = base62[c.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,131 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestShorten(t *testing.T) {
+	store := NewMutableStore()
+	path, err := store.Shorten("https://github.com/gophercises/urlshort")
+	if err != nil {
+		t.Fatalf("Shorten: %v", err)
+	}
+	if len(path) != 1+defaultCodeLength || strings.Trim(path[1:], base62) != "" {
+		t.Errorf("Shorten minted %q, want / and %d base62 characters", path, defaultCodeLength)
+	}
+	urlshorttest.TableTest(t, NewMutableHandler(store, urlshorttest.Fallback()), []urlshorttest.Case{
+		{Path: path, Location: "https://github.com/gophercises/urlshort", Code: http.StatusFound},
+	})
+
+	// shortening again returns the same path
+	again, err := store.Shorten("https://github.com/gophercises/urlshort")
+	if err != nil || again != path {
+		t.Errorf("Shorten again = %q, %v, want %q", again, err, path)
+	}
+	// and so does a path that was added by hand
+	store.Add("/gh", "https://github.com")
+	if got, err := store.Shorten("https://github.com"); err != nil || got != "/gh" {
+		t.Errorf("Shorten of a mapped url = %q, %v, want /gh", got, err)
+	}
+
+	for _, dest := range []string{"", "github.com", "/relative"} {
+		if _, err := store.Shorten(dest); err == nil {
+			t.Errorf("Shorten(%q): got no error", dest)
+		}
+	}
+}
+
+func TestShortenCollisions(t *testing.T) {
+	store := NewMutableStore(WithCodeLength(1))
+	// a third of the codes are taken, ten attempts all colliding is
+	// about a one in 80000 chance
+	taken := make(map[string]bool)
+	for _, c := range base62[:20] {
+		path := "/" + string(c)
+		taken[path] = true
+		store.Add(path, "https://example.com"+path)
+	}
+	path, err := store.Shorten("https://new.example.com")
+	if err != nil {
+		t.Fatalf("Shorten: %v", err)
+	}
+	if taken[path] {
+		t.Errorf("Shorten minted %s, which was taken", path)
+	}
+
+	// once every code is taken it gives up
+	for _, c := range base62 {
+		store.Set("/"+string(c), "https://example.com/"+string(c))
+	}
+	if _, err := store.Shorten("https://full.example.com"); !errors.Is(err, ErrNoFreeCode) {
+		t.Errorf("Shorten with all codes taken: got %v, want ErrNoFreeCode", err)
+	}
+}
+
+func TestShortenConcurrent(t *testing.T) {
+	store := NewMutableStore()
+	const workers = 8
+	same := make([]string, workers)
+	distinct := make([]string, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			same[i], _ = store.Shorten("https://same.example.com")
+			distinct[i], _ = store.Shorten(fmt.Sprintf("https://example.com/%d", i))
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := 0; i < workers; i++ {
+		if same[i] != same[0] {
+			t.Errorf("the same url got the paths %s and %s", same[0], same[i])
+		}
+		if seen[distinct[i]] || distinct[i] == same[0] {
+			t.Errorf("path %s was minted twice", distinct[i])
+		}
+		seen[distinct[i]] = true
+	}
+	if n := len(store.Routes()); n != workers+1 {
+		t.Errorf("the store has %d mappings, want %d", n, workers+1)
+	}
+}
+
+func TestAdminShorten(t *testing.T) {
+	store := NewMutableStore()
+	admin := AdminHandler(store)
+
+	res := call(admin, http.MethodPost, "/api/shorten", `{"url": "https://go.dev"}`)
+	var body struct {
+		Path string `json:"path"`
+		URL  string `json:"url"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); res.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /api/shorten: %d %s", res.Code, res.Body)
+	}
+	if dest, ok := store.Lookup(body.Path); !ok || dest != "https://go.dev" || body.URL != dest {
+		t.Errorf("POST /api/shorten answered %+v, the store has %q", body, dest)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"url": "not a url"}`, http.StatusBadRequest},
+		{`{"url": `, http.StatusBadRequest},
+	} {
+		if res := call(admin, http.MethodPost, "/api/shorten", tt.body); res.Code != tt.want {
+			t.Errorf("POST /api/shorten %s: got %d, want %d", tt.body, res.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package urlshort
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// query parameters of a signed link
+const (
+	expiryParam    = "exp"
+	signatureParam = "sig"
+)
+
+// defaultClockSkew is how long a signed link keeps working after it
+// expired, for servers whose clocks disagree a little
+const defaultClockSkew = time.Minute
+
+// WithClockSkew sets how long links of SignedHandler keep working
+// past their expiry, to make up for the clock of the server that
+// signed them being ahead. The default is a minute.
+func WithClockSkew(d time.Duration) Option {
+	return func(c *config) {
+		c.clockSkew = d
+	}
+}
+
+// SignPath returns path with the expiry and signature SignedHandler
+// checks in its query, like /p/abc?exp=1735689600&sig=..., to put
+// behind the host of the handler. The link stops working at expiry,
+// and changing either the path or the expiry breaks the signature.
+func SignPath(secret []byte, path string, expiry time.Time) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("urlshort: signing needs a secret")
+	}
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("urlshort: path %q must start with /", path)
+	}
+	if strings.ContainsAny(path, "?#") {
+		return "", fmt.Errorf("urlshort: path %q can't have a query or fragment", path)
+	}
+
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	query := url.Values{expiryParam: {exp}, signatureParam: {signature(secret, path, exp)}}
+	u := url.URL{Path: path, RawQuery: query.Encode()}
+	return u.String(), nil
+}
+
+// SignedHandler will return an http.HandlerFunc that only serves
+// links made by SignPath with the same secret, like ResolverHandler
+// with res does. Requests without a valid signature, and those past
+// their expiry (see WithClockSkew), get the fallback. The exp and
+// sig parameters are removed before the request goes on, so they
+// are never forwarded to the destination.
+//
+// SignedHandler panics if secret is empty or the options are
+// invalid.
+func SignedHandler(secret []byte, res Resolver, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	if len(secret) == 0 {
+		panic("urlshort: SignedHandler needs a secret")
+	}
+	cfg := mustConfig(opts)
+	// copy the secret, the caller might reuse the slice
+	secret = append([]byte(nil), secret...)
+	// resolverHandler resolves the fallback it is given itself
+	resolve := resolverHandler(res, fallback, cfg)
+	fallback = mustFallbackFor(fallback, cfg)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, ok := checkSignature(r, secret, cfg)
+		if !ok {
+			serveMiss(w, r, fallback, cfg)
+			return
+		}
+		resolve(w, r)
+	}
+}
+
+// checkSignature reports whether r carries a valid signature that
+// hasn't expired. Like checkToken, the returned request is r without
+// the signature either way.
+func checkSignature(r *http.Request, secret []byte, cfg *config) (*http.Request, bool) {
+	query := r.URL.Query()
+	exp, sig := query.Get(expiryParam), query.Get(signatureParam)
+
+	query.Del(expiryParam)
+	query.Del(signatureParam)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	stripped := r.Clone(r.Context())
+	stripped.URL = &u
+	stripped.RequestURI = u.RequestURI()
+	stripped = keepQuery(stripped, r)
+
+	if exp == "" || sig == "" {
+		return stripped, false
+	}
+	// check the signature before looking at the expiry, so nothing
+	// about a link can be learned without the secret
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, r.URL.Path, exp))) {
+		return stripped, false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return stripped, false
+	}
+	if cfg.now().After(time.Unix(unix, 0).Add(cfg.clockSkew)) {
+		return stripped, false
+	}
+	return stripped, true
+}
+
+// signature is the HMAC-SHA256 of path and exp, URL safe
+func signature(secret []byte, path, exp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path))
+	// the newline keeps /a + 12 and /a1 + 2 apart
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,218 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+var signingSecret = []byte("correct horse battery staple")
+
+// signedResolver resolves the paths the signed tests link to
+var signedResolver = ResolverFunc(func(ctx context.Context, path string) (string, bool, error) {
+	dest, ok := map[string]string{"/p/abc": "https://example.com/offer?id=1", "/p/other": "https://example.com/other"}[path]
+	return dest, ok, nil
+})
+
+func TestSignPath(t *testing.T) {
+	expiry := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	link, err := SignPath(signingSecret, "/p/abc", expiry)
+	if err != nil {
+		t.Fatalf("SignPath: %v", err)
+	}
+	prefix := "/p/abc?exp=" + strconv.FormatInt(expiry.Unix(), 10) + "&sig="
+	if !strings.HasPrefix(link, prefix) {
+		t.Fatalf("got %q, want it to start with %q", link, prefix)
+	}
+	// an unpadded URL safe HMAC-SHA256
+	if sig := strings.TrimPrefix(link, prefix); len(sig) != 43 || strings.ContainsAny(sig, "+/=") {
+		t.Errorf("got signature %q", sig)
+	}
+
+	// the same input signs the same, anything else differently
+	again, _ := SignPath(signingSecret, "/p/abc", expiry)
+	otherPath, _ := SignPath(signingSecret, "/p/abd", expiry)
+	otherExpiry, _ := SignPath(signingSecret, "/p/abc", expiry.Add(time.Second))
+	otherSecret, _ := SignPath([]byte("another secret"), "/p/abc", expiry)
+	if again != link {
+		t.Errorf("signing twice gave %q and %q", link, again)
+	}
+	for _, other := range []string{otherPath, otherExpiry, otherSecret} {
+		if sig(t, other) == sig(t, link) {
+			t.Errorf("%q has the signature of %q", other, link)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		secret []byte
+		path   string
+		want   string
+	}{
+		{"no secret", nil, "/p/abc", "signing needs a secret"},
+		{"relative", signingSecret, "p/abc", `path "p/abc" must start with /`},
+		{"query", signingSecret, "/p/abc?x=1", "can't have a query or fragment"},
+		{"fragment", signingSecret, "/p/abc#top", "can't have a query or fragment"},
+	}
+	for _, tt := range tests {
+		_, err := SignPath(tt.secret, tt.path, expiry)
+		wantError(t, err, tt.want)
+	}
+}
+
+// sig returns the signature of a signed link
+func sig(t *testing.T, link string) string {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get(signatureParam)
+}
+
+// tamper returns link with the query parameter name set to value
+func tamper(t *testing.T, link, name, value string) string {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func TestSignedHandler(t *testing.T) {
+	clock := newFakeClock()
+	expiry := clock.Now().Add(7 * 24 * time.Hour)
+	link, err := SignPath(signingSecret, "/p/abc", expiry)
+	if err != nil {
+		t.Fatalf("SignPath: %v", err)
+	}
+	unmapped, _ := SignPath(signingSecret, "/p/missing", expiry)
+	foreign, _ := SignPath([]byte("another secret"), "/p/abc", expiry)
+	flipped := []byte(sig(t, link))
+	flipped[0] ^= 1
+	h := SignedHandler(signingSecret, signedResolver, urlshorttest.Fallback(), WithClock(clock.Now))
+
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: link, Location: "https://example.com/offer?id=1", Code: http.StatusFound},
+		// parameters that aren't signed can be added
+		{Path: link + "&utm_source=mail", Location: "https://example.com/offer?id=1", Code: http.StatusFound},
+		{Path: "/p/abc"},
+		{Path: unmapped},
+		{Path: foreign},
+		{Path: tamper(t, link, signatureParam, string(flipped))},
+		{Path: tamper(t, link, signatureParam, "")},
+		{Path: tamper(t, link, expiryParam, strconv.FormatInt(expiry.Add(24*time.Hour).Unix(), 10))},
+		{Path: tamper(t, link, expiryParam, "")},
+		{Path: tamper(t, link, expiryParam, "soon")},
+		// the signature of one path doesn't work for another
+		{Path: strings.Replace(link, "/p/abc", "/p/other", 1)},
+	})
+
+	tests := []struct {
+		name  string
+		skew  []Option
+		after time.Duration
+		ok    bool
+	}{
+		{"an hour before", nil, -time.Hour, true},
+		{"at expiry", nil, 0, true},
+		{"within the default skew", nil, 59 * time.Second, true},
+		{"past the default skew", nil, 61 * time.Second, false},
+		{"a day late", nil, 24 * time.Hour, false},
+		{"within a longer skew", []Option{WithClockSkew(10 * time.Minute)}, 9 * time.Minute, true},
+		{"past a longer skew", []Option{WithClockSkew(10 * time.Minute)}, 11 * time.Minute, false},
+		{"without skew", []Option{WithClockSkew(0)}, time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at := expiry.Add(tt.after)
+			h := SignedHandler(signingSecret, signedResolver, urlshorttest.Fallback(),
+				append(tt.skew, WithClock(func() time.Time { return at }))...)
+			if tt.ok {
+				urlshorttest.AssertRedirect(t, h, link, "https://example.com/offer?id=1", http.StatusFound)
+			} else {
+				urlshorttest.AssertFallback(t, h, link)
+			}
+		})
+	}
+}
+
+func TestSignedHandlerQuery(t *testing.T) {
+	clock := newFakeClock()
+	link, err := SignPath(signingSecret, "/p/abc", clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SignPath: %v", err)
+	}
+	// the signature is never forwarded to the destination
+	h := SignedHandler(signingSecret, signedResolver, urlshorttest.Fallback(), WithClock(clock.Now), WithQueryForwarding())
+	urlshorttest.AssertRedirect(t, h, link+"&utm_source=mail", "https://example.com/offer?id=1&utm_source=mail", http.StatusFound)
+	urlshorttest.AssertRedirect(t, h, link, "https://example.com/offer?id=1", http.StatusFound)
+}
+
+func TestSignedHandlerPanics(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret []byte
+		opts   []Option
+	}{
+		{"no secret", nil, nil},
+		{"negative skew", signingSecret, []Option{WithClockSkew(-time.Second)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("SignedHandler didn't panic")
+				}
+			}()
+			SignedHandler(tt.secret, signedResolver, nil, tt.opts...)
+		})
+	}
+}
+
+func TestSignedHandlerCopiesSecret(t *testing.T) {
+	clock := newFakeClock()
+	secret := append([]byte(nil), signingSecret...)
+	h := SignedHandler(secret, signedResolver, urlshorttest.Fallback(), WithClock(clock.Now))
+	secret[0] ^= 1
+	link, _ := SignPath(signingSecret, "/p/abc", clock.Now().Add(time.Hour))
+	urlshorttest.AssertRedirect(t, h, link, "https://example.com/offer?id=1", http.StatusFound)
+}
+
+func TestSignedHandlerFallback(t *testing.T) {
+	clock := newFakeClock()
+	link, _ := SignPath(signingSecret, "/p/abc", clock.Now().Add(time.Hour))
+	unknown, _ := SignPath(signingSecret, "/p/unknown", clock.Now().Add(time.Hour))
+
+	// without a fallback bad signatures and unknown paths get a plain 404
+	h := SignedHandler(signingSecret, signedResolver, nil, WithClock(clock.Now))
+	for _, target := range []string{"/p/abc", unknown} {
+		if code := get(h, target).Code; code != http.StatusNotFound {
+			t.Errorf("GET %s: got %d, want 404", target, code)
+		}
+	}
+
+	h = SignedHandler(signingSecret, signedResolver, nil, WithClock(clock.Now), WithDefaultDestination("https://example.com/home"))
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: link, Location: "https://example.com/offer?id=1", Code: http.StatusFound},
+		{Path: "/p/abc", Location: "https://example.com/home", Code: http.StatusFound},
+		{Path: unknown, Location: "https://example.com/home", Code: http.StatusFound},
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("SignedHandler didn't panic with a fallback and a default destination")
+		}
+	}()
+	SignedHandler(signingSecret, signedResolver, urlshorttest.Fallback(), WithDefaultDestination("https://example.com/home"))
+}
@@ -0,0 +1,145 @@
+package urlshort
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// snapshotMagic starts every snapshot, followed by snapshotVersion,
+// the SHA-256 of the payload and the gob payload itself
+const snapshotMagic = "urlshort snapshot\n"
+
+// snapshotVersion changes whenever pathUrl changes in a way gob
+// can't decode older snapshots into correctly
+const snapshotVersion uint32 = 1
+
+// SaveSnapshot writes entries to w in a binary format LoadSnapshot
+// reads back much faster than the file they were parsed from.
+func SaveSnapshot(w io.Writer, entries []pathUrl) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(entries); err != nil {
+		return fmt.Errorf("urlshort: encoding snapshot: %w", err)
+	}
+	sum := sha256.Sum256(payload.Bytes())
+
+	var b bytes.Buffer
+	b.WriteString(snapshotMagic)
+	binary.Write(&b, binary.BigEndian, snapshotVersion)
+	b.Write(sum[:])
+	b.Write(payload.Bytes())
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// LoadSnapshot reads entries written by SaveSnapshot. Snapshots of
+// another version, and those whose checksum doesn't match, are an
+// error. The entries are checked like parsed ones.
+func LoadSnapshot(r io.Reader) ([]pathUrl, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("urlshort: reading snapshot: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte(snapshotMagic)) || len(data) < len(snapshotMagic)+4+sha256.Size {
+		return nil, errors.New("urlshort: not a snapshot")
+	}
+	rest := data[len(snapshotMagic):]
+	if version := binary.BigEndian.Uint32(rest); version != snapshotVersion {
+		return nil, fmt.Errorf("urlshort: snapshot version %d, need %d", version, snapshotVersion)
+	}
+	sum, payload := rest[4:4+sha256.Size], rest[4+sha256.Size:]
+	if got := sha256.Sum256(payload); !bytes.Equal(got[:], sum) {
+		return nil, errors.New("urlshort: snapshot checksum mismatch")
+	}
+
+	var entries []pathUrl
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("urlshort: decoding snapshot: %w", err)
+	}
+	if err := prepareEntries(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SnapshotYAMLHandler works like a YAMLHandler for the file at
+// yamlPath, but keeps a snapshot of its entries at snapshotPath to
+// start faster. The snapshot is used when it is newer than the YAML
+// file, otherwise the YAML is parsed and the snapshot written anew.
+// A snapshot that can't be read, is corrupt or of another version is
+// ignored the same way, and one that can't be written only means
+// the next start parses the YAML again.
+//
+// Only the modification time of yamlPath is compared, edits to files
+// it includes need the snapshot to be removed.
+func SnapshotYAMLHandler(yamlPath, snapshotPath string, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(yamlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pathUrls, ok := loadSnapshotFile(snapshotPath, info)
+	if !ok {
+		if pathUrls, err = parseYAMLFile(yamlPath, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	src := &source{name: yamlPath, fn: func(*config) ([]pathUrl, error) { return pathUrls, nil }}
+	h, err := New(src, fallback, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", yamlPath, err)
+	}
+	if !ok {
+		// only entries that built a handler are worth a snapshot, a
+		// failed save is retried on the next start
+		saveSnapshotFile(snapshotPath, pathUrls)
+	}
+	return h.ServeHTTP, nil
+}
+
+// loadSnapshotFile reads the snapshot at path if it is newer than
+// the YAML file
+func loadSnapshotFile(path string, yamlInfo os.FileInfo) ([]pathUrl, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.ModTime().After(yamlInfo.ModTime()) {
+		return nil, false
+	}
+	pathUrls, err := LoadSnapshot(f)
+	return pathUrls, err == nil
+}
+
+// saveSnapshotFile writes a snapshot of pathUrls to path through a
+// temporary file, so a concurrent start never reads half of one
+func saveSnapshotFile(path string, pathUrls []pathUrl) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := SaveSnapshot(tmp, pathUrls); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,304 @@
+package urlshort
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const snapshotEntries = `
+- path: /gh
+  url: https://github.com
+  code: 301
+  aliases: [/github]
+  headers:
+    X-Team: infra
+- path: /u/:name
+  url: https://github.com/:name
+- path: ^/issue/([0-9]+)$
+  url: https://github.com/nils/repo/issues/$1
+  regex: true
+- path: /promo
+  url: https://shop.example.com/spring
+  expires: "2030-01-01T00:00:00Z"
+`
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	entries, err := parseYAML([]byte(snapshotEntries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := SaveSnapshot(&b, entries); err != nil {
+		t.Fatalf("SaveSnapshot: %v", err)
+	}
+	loaded, err := LoadSnapshot(&b)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, entries) {
+		t.Errorf("got %+v, want %+v", loaded, entries)
+	}
+
+	// the loaded entries are ready to serve, regexes included
+	h, err := New(FromMap(nil), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(loaded); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/github", Location: "https://github.com", Code: http.StatusMovedPermanently},
+		{Path: "/u/nils", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/issue/42", Location: "https://github.com/nils/repo/issues/42", Code: http.StatusFound},
+		{Path: "/issue/x"},
+	})
+}
+
+// snapshotBytes returns a snapshot of entries with version and
+// payload as given, checksummed unless sum is set
+func snapshotBytes(version uint32, payload, sum []byte) []byte {
+	if sum == nil {
+		s := sha256.Sum256(payload)
+		sum = s[:]
+	}
+	var b bytes.Buffer
+	b.WriteString(snapshotMagic)
+	binary.Write(&b, binary.BigEndian, version)
+	b.Write(sum)
+	b.Write(payload)
+	return b.Bytes()
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	var good bytes.Buffer
+	if err := SaveSnapshot(&good, []pathUrl{{Path: "/gh", URL: "https://github.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	payload := good.Bytes()[len(snapshotMagic)+4+sha256.Size:]
+	flipped := bytes.Clone(good.Bytes())
+	flipped[len(flipped)-5] ^= 1
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"empty", nil, "not a snapshot"},
+		{"yaml", []byte(snapshotEntries), "not a snapshot"},
+		{"truncated header", good.Bytes()[:len(snapshotMagic)+10], "not a snapshot"},
+		{"truncated payload", good.Bytes()[:good.Len()-10], "snapshot checksum mismatch"},
+		{"flipped bit", flipped, "snapshot checksum mismatch"},
+		{"old version", snapshotBytes(snapshotVersion-1, payload, nil), fmt.Sprintf("snapshot version %d, need %d", snapshotVersion-1, snapshotVersion)},
+		{"newer version", snapshotBytes(snapshotVersion+1, payload, nil), "snapshot version"},
+		{"not gob", snapshotBytes(snapshotVersion, []byte("garbage"), nil), "decoding snapshot"},
+		// the entries are checked like parsed ones
+		{"invalid entry", snapshotBytes(snapshotVersion, gobEntries(t, []pathUrl{{Path: "/a", URL: "https://a.example.com", Code: 200}}), nil), "/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSnapshot(bytes.NewReader(tt.data))
+			wantError(t, err, tt.want)
+		})
+	}
+}
+
+// gobEntries returns the payload of a snapshot of entries
+func gobEntries(t *testing.T, entries []pathUrl) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := SaveSnapshot(&b, entries); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()[len(snapshotMagic)+4+sha256.Size:]
+}
+
+// age sets the modification time of path to d ago
+func age(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-d)
+	if err := os.Chtimes(path, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotYAMLHandler(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := writeFile(t, dir, "paths.yaml", snapshotEntries)
+	snapPath := filepath.Join(dir, "paths.snap")
+	age(t, yamlPath, time.Hour)
+
+	// the first start parses the YAML and writes the snapshot
+	h, err := SnapshotYAMLHandler(yamlPath, snapPath, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SnapshotYAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/u/nils", "https://github.com/nils", http.StatusFound)
+	written, err := os.ReadFile(snapPath)
+	if err != nil {
+		t.Fatalf("no snapshot written: %v", err)
+	}
+	if _, err := LoadSnapshot(bytes.NewReader(written)); err != nil {
+		t.Fatalf("the written snapshot: %v", err)
+	}
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp*")); len(tmps) > 0 {
+		t.Errorf("temporary files left: %v", tmps)
+	}
+
+	// a snapshot newer than the YAML is used, even if the YAML
+	// changed without its modification time
+	writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: https://gitlab.com\n")
+	age(t, yamlPath, time.Hour)
+	h, err = SnapshotYAMLHandler(yamlPath, snapPath, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SnapshotYAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusMovedPermanently)
+
+	// a YAML newer than the snapshot is parsed again
+	age(t, snapPath, 2*time.Hour)
+	h, err = SnapshotYAMLHandler(yamlPath, snapPath, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SnapshotYAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/u/nils"},
+	})
+	// and the snapshot rewritten
+	entries := loadSnapshotPath(t, snapPath)
+	if len(entries) != 1 || entries[0].URL != "https://gitlab.com" {
+		t.Errorf("the rewritten snapshot has %+v", entries)
+	}
+}
+
+// loadSnapshotPath reads the snapshot at path
+func loadSnapshotPath(t *testing.T, path string) []pathUrl {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entries, err := LoadSnapshot(f)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+	return entries
+}
+
+func TestSnapshotYAMLHandlerBadSnapshots(t *testing.T) {
+	var good bytes.Buffer
+	SaveSnapshot(&good, []pathUrl{{Path: "/gh", URL: "https://snapshot.example.com"}})
+	payload := good.Bytes()[len(snapshotMagic)+4+sha256.Size:]
+	flipped := bytes.Clone(good.Bytes())
+	flipped[len(flipped)-5] ^= 1
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"garbage", []byte("\x00\xff not a snapshot at all")},
+		{"truncated", good.Bytes()[:good.Len()/2]},
+		{"corrupt", flipped},
+		{"other version", snapshotBytes(snapshotVersion+1, payload, nil)},
+		{"not gob", snapshotBytes(snapshotVersion, bytes.Repeat([]byte{0xff}, 64), nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			yamlPath := writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: https://github.com\n")
+			age(t, yamlPath, time.Hour)
+			snapPath := writeFile(t, dir, "paths.snap", string(tt.data))
+
+			// the YAML is used instead and the snapshot repaired
+			h, err := SnapshotYAMLHandler(yamlPath, snapPath, urlshorttest.Fallback())
+			if err != nil {
+				t.Fatalf("SnapshotYAMLHandler: %v", err)
+			}
+			urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+			if entries := loadSnapshotPath(t, snapPath); len(entries) != 1 || entries[0].URL != "https://github.com" {
+				t.Errorf("the repaired snapshot has %+v", entries)
+			}
+		})
+	}
+}
+
+func TestSnapshotYAMLHandlerErrors(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: https://github.com\n")
+
+	// a snapshot that can't be written only costs the next start
+	h, err := SnapshotYAMLHandler(yamlPath, filepath.Join(dir, "missing", "paths.snap"), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("an unwritable snapshot: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+
+	_, err = SnapshotYAMLHandler(filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "paths.snap"), nil)
+	if !os.IsNotExist(err) {
+		t.Errorf("a missing YAML file: got %v", err)
+	}
+	bad := writeFile(t, dir, "bad.yaml", "- path: /gh\n")
+	_, err = SnapshotYAMLHandler(bad, filepath.Join(dir, "bad.snap"), nil)
+	wantError(t, err, `/gh: url "" is not absolute`)
+	if _, err := os.Stat(filepath.Join(dir, "bad.snap")); !os.IsNotExist(err) {
+		t.Errorf("a snapshot was written for a broken file: %v", err)
+	}
+}
+
+// BenchmarkSnapshotStartup starts a handler for a generated 200k
+// entry YAML file by parsing it, and from a snapshot of it. -short
+// uses 10k entries.
+func BenchmarkSnapshotStartup(b *testing.B) {
+	n := 200_000
+	if testing.Short() {
+		n = 10_000
+	}
+	dir := b.TempDir()
+	yamlPath := writeBenchFile(b, dir, "paths.yaml", n, func(w io.Writer, i int) {
+		fmt.Fprintf(w, "- path: /p%d\n  url: https://example.com/page/%d\n", i, i)
+	})
+	at := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(yamlPath, at, at); err != nil {
+		b.Fatal(err)
+	}
+	snapPath := filepath.Join(dir, "paths.snap")
+
+	b.Run("yaml", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := YAMLFileHandler(yamlPath, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		// the first start writes the snapshot
+		if _, err := SnapshotYAMLHandler(yamlPath, snapPath, nil); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := SnapshotYAMLHandler(yamlPath, snapPath, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
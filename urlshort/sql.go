@@ -0,0 +1,63 @@
+package urlshort
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+// Migrate creates the redirects table used by SQLHandler if it
+// doesn't exist yet.
+func Migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS redirects (
+		path TEXT PRIMARY KEY,
+		url  TEXT NOT NULL
+	)`)
+	return err
+}
+
+// SQLHandler will return an http.HandlerFunc that looks up the
+// request path in the redirects(path, url) table of db on every
+// request. Use WithCache to keep results in memory for a while.
+// If the path isn't in the table, or the query fails, the
+// fallback http.Handler will be called instead. WithErrorHandler
+// changes what happens to failed queries.
+//
+// The query is prepared once here, so an error is returned if the
+// database can't be reached or the table doesn't exist. See Migrate
+// to create it.
+func SQLHandler(db *sql.DB, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	if db == nil {
+		return nil, errors.New("urlshort: sql db is nil")
+	}
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := db.Prepare(`SELECT url FROM redirects WHERE path = $1`)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolverHandler(&sqlResolver{stmt: stmt}, fallback, cfg), nil
+}
+
+// sqlResolver looks paths up with the prepared query
+type sqlResolver struct {
+	stmt *sql.Stmt
+}
+
+func (sr *sqlResolver) Resolve(ctx context.Context, path string) (string, bool, error) {
+	var dest string
+	err := sr.stmt.QueryRowContext(ctx, path).Scan(&dest)
+	switch {
+	case err == nil:
+		return dest, true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return "", false, nil
+	default:
+		return "", false, err
+	}
+}
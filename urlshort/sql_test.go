@@ -0,0 +1,121 @@
+package urlshort
+
+import (
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// openSQL opens a migrated sqlite database in a temporary directory
+func openSQL(t *testing.T, rows map[string]string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "urlshort.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	// migrating twice is fine
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	for path, dest := range rows {
+		if _, err := db.Exec(`INSERT INTO redirects (path, url) VALUES ($1, $2)`, path, dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestSQLHandler(t *testing.T) {
+	db := openSQL(t, map[string]string{"/gh": "https://github.com"})
+	h, err := SQLHandler(db, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SQLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	// without a cache every request sees the table as it is
+	if _, err := db.Exec(`UPDATE redirects SET url = 'https://gitlab.com' WHERE path = '/gh'`); err != nil {
+		t.Fatal(err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{{Path: "/gh", Location: "https://gitlab.com", Code: http.StatusFound}})
+}
+
+func TestSQLHandlerCache(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	db := openSQL(t, map[string]string{"/gh": "https://github.com"})
+	h, err := SQLHandler(db, urlshorttest.Fallback(), WithCache(ttl))
+	if err != nil {
+		t.Fatalf("SQLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/new"},
+	})
+
+	if _, err := db.Exec(`UPDATE redirects SET url = 'https://gitlab.com' WHERE path = '/gh'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO redirects (path, url) VALUES ('/new', 'https://new.example.com')`); err != nil {
+		t.Fatal(err)
+	}
+	// hits and misses are cached until the ttl passes
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/new"},
+	})
+	time.Sleep(2 * ttl)
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/new", Location: "https://new.example.com", Code: http.StatusFound},
+	})
+}
+
+func TestSQLHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{"fallback", nil, http.StatusNotFound},
+		{"unavailable", []Option{WithUnavailableOnError()}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openSQL(t, map[string]string{"/gh": "https://github.com"})
+			h, err := SQLHandler(db, urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("SQLHandler: %v", err)
+			}
+			db.Close()
+			if tt.want == http.StatusNotFound {
+				urlshorttest.AssertFallback(t, h, "/gh")
+			} else if res := get(h, "/gh"); res.Code != tt.want {
+				t.Errorf("GET /gh with the db closed: got %d, want %d", res.Code, tt.want)
+			}
+		})
+	}
+
+	_, err := SQLHandler(nil, urlshorttest.Fallback())
+	wantError(t, err, "sql db is nil")
+
+	// the table has to exist
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "empty.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := SQLHandler(db, urlshorttest.Fallback()); err == nil {
+		t.Error("SQLHandler without a redirects table: got no error")
+	}
+}
@@ -0,0 +1,179 @@
+package urlshort
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+	_ "modernc.org/sqlite"
+)
+
+// the cache of SQLiteHandler unless WithCache and WithCacheSize say
+// otherwise
+const (
+	defaultSQLiteCacheTTL  = 5 * time.Minute
+	defaultSQLiteCacheSize = 10000
+)
+
+// upsertRedirect stores a mapping, replacing the url of an existing path
+const upsertRedirect = `INSERT INTO redirects (path, url) VALUES (?, ?)
+	ON CONFLICT (path) DO UPDATE SET url = excluded.url`
+
+// SQLiteStore serves the redirects table of an SQLite file, see
+// SQLiteHandler. It is safe for concurrent use.
+type SQLiteStore struct {
+	db      *sql.DB
+	cache   *lookupCache
+	handler http.HandlerFunc
+
+	// bloom holds the paths of the table when bloomRate is set, see
+	// WithBloomFilter
+	bloomRate float64
+	bloom     atomic.Pointer[bloomFilter]
+	// bloomMu is held by Set for reading and by rebuilds for writing
+	bloomMu sync.RWMutex
+}
+
+// SQLiteHandler opens the SQLite file at path, creating it and its
+// redirects(path, url) table if needed, and serves the redirects in
+// it like SQLHandler does. Lookups go through an in-memory cache,
+// by default of 10000 paths for 5 minutes, see WithCache and
+// WithCacheSize. Writes with Set and Remove go straight to the file
+// and drop the path from the cache, so only changes made by other
+// processes take up to the ttl to show, or until Reload. Call Close
+// to close the file.
+func SQLiteHandler(path string, fallback http.Handler, opts ...Option) (*SQLiteStore, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := db.Prepare(`SELECT url FROM redirects WHERE path = ?`)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	ttl, size := cfg.cacheTTL, cfg.cacheSize
+	if ttl == 0 {
+		ttl = defaultSQLiteCacheTTL
+	}
+	if size == 0 {
+		size = defaultSQLiteCacheSize
+	}
+	s := &SQLiteStore{db: db, cache: newLookupCache(ttl, size), bloomRate: cfg.bloomRate}
+	if s.bloomRate > 0 {
+		if err := s.buildBloom(context.Background()); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s.handler = cachedResolverHandler(&sqlResolver{stmt: stmt}, s.cache, &s.bloom, fallback, cfg)
+	return s, nil
+}
+
+// openSQLite opens the file at path and makes sure the redirects
+// table exists. Readers don't block each other and writers wait for
+// the file instead of failing when it is busy.
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + url.PathEscape(path) + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("urlshort: %s: %w", path, err)
+	}
+	if err := Migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("urlshort: %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// ServeHTTP redirects using the table
+func (s *SQLiteStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler(w, r)
+}
+
+// Set stores a mapping, replacing the destination if path is
+// already mapped
+func (s *SQLiteStore) Set(ctx context.Context, path, dest string) error {
+	if err := validateMapping(path, dest); err != nil {
+		return err
+	}
+	if s.bloomRate > 0 {
+		return s.setWithBloom(ctx, path, dest)
+	}
+	return s.set(ctx, path, dest)
+}
+
+// set writes a mapping that was validated
+func (s *SQLiteStore) set(ctx context.Context, path, dest string) error {
+	_, err := s.db.ExecContext(ctx, upsertRedirect, path, dest)
+	// drop the path even if the write failed, it may have gone through
+	s.cache.invalidate(path)
+	return err
+}
+
+// Remove deletes the mapping for path and reports whether it existed
+func (s *SQLiteStore) Remove(ctx context.Context, path string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM redirects WHERE path = ?`, path)
+	s.cache.invalidate(path)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Close closes the file
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ImportYAMLToSQLite stores the entries of yamlBytes in the
+// redirects table of the SQLite file at dbPath, creating both if
+// needed, to move from a YAML file to SQLiteHandler. Only the path
+// and url of each entry are kept, existing paths are replaced. All
+// entries are written in a single transaction, so a bad entry
+// leaves the file as it was.
+func ImportYAMLToSQLite(dbPath string, yamlBytes []byte) error {
+	pathUrls, err := unmarshalYAML(yamlBytes, yaml.Unmarshal)
+	if err != nil {
+		return err
+	}
+	if err := checkEntries(pathUrls); err != nil {
+		return err
+	}
+	for _, pu := range pathUrls {
+		if err := validateMapping(pu.Path, pu.URL); err != nil {
+			return err
+		}
+	}
+
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, pu := range pathUrls {
+		if _, err := tx.Exec(upsertRedirect, pu.Path, pu.URL); err != nil {
+			return fmt.Errorf("urlshort: %s: %w", pu.Path, err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,146 @@
+package urlshort
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// openSQLiteStore returns a store over a sqlite file in a temporary
+// directory, with the mappings of yamlData imported, and its path
+func openSQLiteStore(t *testing.T, yamlData string, opts ...Option) (*SQLiteStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redirects.db")
+	if err := ImportYAMLToSQLite(path, []byte(yamlData)); err != nil {
+		t.Fatalf("ImportYAMLToSQLite: %v", err)
+	}
+	s, err := SQLiteHandler(path, urlshorttest.Fallback(), opts...)
+	if err != nil {
+		t.Fatalf("SQLiteHandler: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func TestSQLiteHandler(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openSQLiteStore(t, "- path: /gh\n  url: https://github.com\n- path: /go\n  url: https://go.dev\n")
+	urlshorttest.TableTest(t, s, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/missing"},
+	})
+
+	// writes drop the cached entry, so they show right away
+	if err := s.Set(ctx, "/gh", "https://github.com/nils"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Set(ctx, "/missing", "https://found.example.com"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ok, err := s.Remove(ctx, "/go"); !ok || err != nil {
+		t.Fatalf("Remove(/go) = %v, %v", ok, err)
+	}
+	if ok, err := s.Remove(ctx, "/go"); ok || err != nil {
+		t.Errorf("Remove(/go) again = %v, %v", ok, err)
+	}
+	urlshorttest.TableTest(t, s, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/missing", Location: "https://found.example.com", Code: http.StatusFound},
+		{Path: "/go"},
+	})
+
+	for _, bad := range [][2]string{{"nope", "https://a.example.com"}, {"/a", "/relative"}} {
+		if err := s.Set(ctx, bad[0], bad[1]); err == nil {
+			t.Errorf("Set(%q, %q): got no error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestSQLiteHandlerCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	s, path := openSQLiteStore(t, "- path: /gh\n  url: https://github.com\n", WithCache(50*time.Millisecond))
+	urlshorttest.AssertRedirect(t, s, "/gh", "https://github.com", http.StatusFound)
+
+	// another process writes to the file
+	other, err := SQLiteHandler(path, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SQLiteHandler: %v", err)
+	}
+	defer other.Close()
+	if err := other.Set(ctx, "/gh", "https://github.com/other"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, s, "/gh", "https://github.com", http.StatusFound)
+	time.Sleep(60 * time.Millisecond)
+	urlshorttest.AssertRedirect(t, s, "/gh", "https://github.com/other", http.StatusFound)
+}
+
+func TestImportYAMLToSQLite(t *testing.T) {
+	s, path := openSQLiteStore(t, "- path: /gh\n  url: https://github.com\n")
+
+	// a bad entry leaves the file as it was
+	err := ImportYAMLToSQLite(path, []byte("- path: /go\n  url: https://go.dev\n- path: /rel\n  url: /somewhere\n"))
+	wantError(t, err, `url "/somewhere" is not absolute`)
+	err = ImportYAMLToSQLite(path, []byte("- path: /gh\n  url: [\n"))
+	if err == nil {
+		t.Errorf("importing broken YAML: got no error")
+	}
+	urlshorttest.TableTest(t, s, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go"},
+	})
+
+	// importing again replaces existing paths
+	if err := ImportYAMLToSQLite(path, []byte("- path: /gl\n  url: https://gitlab.com\n- path: /gh\n  url: https://github.com/nils\n")); err != nil {
+		t.Fatalf("ImportYAMLToSQLite: %v", err)
+	}
+	fresh, err := SQLiteHandler(path, urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("SQLiteHandler: %v", err)
+	}
+	defer fresh.Close()
+	urlshorttest.TableTest(t, fresh, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+	})
+}
+
+func TestSQLiteHandlerConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s, _ := openSQLiteStore(t, "- path: /gh\n  url: https://github.com\n", WithCacheSize(8))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := s.Set(ctx, fmt.Sprintf("/w%d/%d", w, i), fmt.Sprintf("https://example.com/%d/%d", w, i)); err != nil {
+					t.Errorf("Set: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				urlshorttest.AssertRedirect(t, s, "/gh", "https://github.com", http.StatusFound)
+				get(s, fmt.Sprintf("/w%d/%d", w, i%25))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for w := 0; w < 4; w++ {
+		for i := 0; i < 25; i++ {
+			urlshorttest.AssertRedirect(t, s, fmt.Sprintf("/w%d/%d", w, i), fmt.Sprintf("https://example.com/%d/%d", w, i), http.StatusFound)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package urlshort
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultStaleDays is the window of GET /api/stats/stale without a
+// days parameter
+const defaultStaleDays = 90
+
+// statsSource is where Stats finds the configured paths and the
+// clock of the handler counting into it
+type statsSource struct {
+	paths func() []string
+	now   func() time.Time
+	// aliases maps aliases to the path they belong to
+	aliases map[string]string
+}
+
+// track makes paths the configured paths StaleReport looks at, and
+// aliases the ones CanonicalHits adds up. The handler built or
+// reloaded last wins.
+func (s *Stats) track(paths func() []string, aliases map[string]string, now func() time.Time) {
+	s.source.Store(&statsSource{paths: paths, now: now, aliases: aliases})
+}
+
+// StaleReport returns the configured paths that were never hit or
+// not within olderThan, the ones unused the longest first. Paths
+// that were never hit have a zero LastHit and come before all
+// others. Only the paths of the handler that was built or reloaded
+// last with WithStats(s) are reported, and the window is measured
+// with the clock of that handler, see WithClock.
+func (s *Stats) StaleReport(olderThan time.Duration) []PathStats {
+	src := s.source.Load()
+	if src == nil {
+		return nil
+	}
+	cutoff := src.now().Add(-olderThan)
+
+	var stale []PathStats
+	for _, path := range src.paths() {
+		var ps PathStats
+		if c, ok := s.hits.m.Load(path); ok {
+			ps = c.(*counter).stats()
+		}
+		if ps.LastHit.IsZero() || ps.LastHit.Before(cutoff) {
+			ps.Path = path
+			stale = append(stale, ps)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		if !stale[i].LastHit.Equal(stale[j].LastHit) {
+			return stale[i].LastHit.Before(stale[j].LastHit)
+		}
+		return stale[i].Path < stale[j].Path
+	})
+	return stale
+}
+
+// statsPaths returns the configured paths of the table, each once
+func (rt *routeTable) statsPaths() []string {
+	seen := make(map[string]bool, len(rt.entries))
+	paths := make([]string, 0, len(rt.entries))
+	for _, pu := range rt.entries {
+		if !seen[pu.Path] {
+			seen[pu.Path] = true
+			paths = append(paths, pu.Path)
+		}
+	}
+	return paths
+}
+
+// stale serves the StaleReport of the stats the store is served
+// with, the window is ?days=
+func (a *admin) stale(w http.ResponseWriter, r *http.Request) {
+	stats := a.store.stats.Load()
+	if stats == nil {
+		writeError(w, http.StatusNotFound, "no stats, serve the store with WithStats")
+		return
+	}
+
+	days := defaultStaleDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "days must be a non-negative number")
+			return
+		}
+		days = n
+	}
+
+	report := stats.StaleReport(time.Duration(days) * 24 * time.Hour)
+	if report == nil {
+		report = []PathStats{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]PathStats{"paths": report})
+}
@@ -0,0 +1,149 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const staleEntries = `
+- path: /gh
+  url: https://github.com
+- path: /go
+  url: https://go.dev
+- path: /docs
+  url: https://go.dev/doc
+- path: /never
+  url: https://example.com/never
+- path: /also-never
+  url: https://example.com/also-never
+`
+
+const day = 24 * time.Hour
+
+// stalePaths returns the paths of report in order
+func stalePaths(report []PathStats) []string {
+	paths := make([]string, len(report))
+	for i, ps := range report {
+		paths[i] = ps.Path
+	}
+	return paths
+}
+
+func TestStaleReport(t *testing.T) {
+	if got := NewStats().StaleReport(day); got != nil {
+		t.Errorf("stats without a handler: got %v", got)
+	}
+
+	clock := newFakeClock()
+	start := clock.Now()
+	stats := NewStats()
+	h, err := New(FromYAML([]byte(staleEntries)), urlshorttest.Fallback(), WithStats(stats), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// /gh on day 0, /go on day 10 and /docs on day 20, which is now
+	get(h, "/gh")
+	get(h, "/gh")
+	clock.Advance(10 * day)
+	get(h, "/go")
+	clock.Advance(10 * day)
+	get(h, "/docs")
+	get(h, "/missing")
+
+	tests := []struct {
+		olderThan time.Duration
+		want      []string
+	}{
+		// never hit paths come first, then the ones unused the longest
+		{15 * day, []string{"/also-never", "/never", "/gh"}},
+		{5 * day, []string{"/also-never", "/never", "/gh", "/go"}},
+		// a hit right at the threshold is recent enough
+		{10 * day, []string{"/also-never", "/never", "/gh"}},
+		{20 * day, []string{"/also-never", "/never"}},
+		{0, []string{"/also-never", "/never", "/gh", "/go"}},
+	}
+	for _, tt := range tests {
+		if got := stalePaths(stats.StaleReport(tt.olderThan)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("StaleReport(%v): got %q, want %q", tt.olderThan, got, tt.want)
+		}
+	}
+	report := stats.StaleReport(15 * day)
+	if gh := report[2]; gh.Hits != 2 || !gh.LastHit.Equal(start) {
+		t.Errorf("got %+v for /gh", gh)
+	}
+	if never := report[0]; never.Hits != 0 || !never.LastHit.IsZero() {
+		t.Errorf("got %+v for /also-never", never)
+	}
+
+	// only the paths that are still configured are reported
+	if err := h.Reload([]Entry{{Path: "/gh", URL: "https://github.com"}, {Path: "/new", URL: "https://example.com/new"}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got, want := stalePaths(stats.StaleReport(15*day)), []string{"/new", "/gh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after a reload: got %q, want %q", got, want)
+	}
+}
+
+func TestAdminStale(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMutableStore()
+	for path, dest := range map[string]string{"/gh": "https://github.com", "/go": "https://go.dev", "/never": "https://example.com/never"} {
+		if err := store.Add(path, dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := AdminHandler(store)
+	// the endpoint needs stats
+	if res := call(admin, http.MethodGet, "/api/stats/stale", ""); res.Code != http.StatusNotFound {
+		t.Errorf("without stats: got %d, want 404", res.Code)
+	}
+
+	serve := NewMutableHandler(store, urlshorttest.Fallback(), WithStats(NewStats()), WithClock(clock.Now))
+	get(serve, "/gh")
+	clock.Advance(50 * day)
+	get(serve, "/go")
+	clock.Advance(50 * day)
+
+	tests := []struct {
+		target string
+		code   int
+		want   []string
+	}{
+		// 90 days by default
+		{"/api/stats/stale", http.StatusOK, []string{"/never", "/gh"}},
+		{"/api/stats/stale?days=30", http.StatusOK, []string{"/never", "/gh", "/go"}},
+		{"/api/stats/stale?days=0", http.StatusOK, []string{"/never", "/gh", "/go"}},
+		{"/api/stats/stale?days=-1", http.StatusBadRequest, nil},
+		{"/api/stats/stale?days=soon", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		res := call(admin, http.MethodGet, tt.target, "")
+		if res.Code != tt.code {
+			t.Errorf("GET %s: got %d, want %d", tt.target, res.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var body struct {
+			Paths []PathStats `json:"paths"`
+		}
+		if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+			t.Fatalf("GET %s: decoding %s: %v", tt.target, res.Body.String(), err)
+		}
+		if got := stalePaths(body.Paths); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GET %s: got %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	// nothing stale is an empty list, not null
+	get(serve, "/never")
+	if res := call(admin, http.MethodGet, "/api/stats/stale?days=100", ""); res.Body.String() != `{"paths":[]}`+"\n" {
+		t.Errorf("got %s", res.Body.String())
+	}
+}
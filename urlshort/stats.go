@@ -0,0 +1,179 @@
+package urlshort
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxMissPaths limits how many distinct missed paths are counted
+// separately, everything after that is counted under missOverflow.
+// Scanners would otherwise grow the misses without bound.
+const (
+	maxMissPaths = 10000
+	missOverflow = "*"
+)
+
+// Stats counts how often each path was requested. Use WithStats to
+// attach it to a handler and StatsHandler to serve the numbers.
+// Counting only uses atomic operations once a path has been seen,
+// so it doesn't add lock contention to the request path.
+type Stats struct {
+	hits   counters
+	misses counters
+	// disabled counts the requests for disabled entries
+	disabled counters
+
+	// source is where StaleReport finds the configured paths
+	source atomic.Pointer[statsSource]
+	// persist is set for stats from NewPersistentStats
+	persist *statsPersistence
+}
+
+// NewStats returns empty statistics
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// WithStats counts every matched path in s, and every path that
+// fell through to the fallback in a separate misses bucket.
+func WithStats(s *Stats) Option {
+	return func(c *config) {
+		c.stats = s
+	}
+}
+
+// PathStats is what is known about the requests for a single path
+type PathStats struct {
+	// Path is only set in a StaleReport, snapshots are keyed by path
+	Path    string    `json:"path,omitempty"`
+	Hits    int64     `json:"hits"`
+	LastHit time.Time `json:"last_hit"`
+}
+
+// StatsSnapshot is a copy of the statistics at one point in time.
+// Paths are the configured paths that matched, so wildcard and
+// parameter entries are counted under their pattern.
+type StatsSnapshot struct {
+	Paths  map[string]PathStats `json:"paths"`
+	Misses map[string]PathStats `json:"misses"`
+	// Disabled are the requests for entries that are disabled, by
+	// their path
+	Disabled map[string]PathStats `json:"disabled,omitempty"`
+}
+
+// Snapshot returns a copy of the current counts
+func (s *Stats) Snapshot() StatsSnapshot {
+	snap := StatsSnapshot{
+		Paths:  s.hits.snapshot(),
+		Misses: s.misses.snapshot(),
+	}
+	if disabled := s.disabled.snapshot(); len(disabled) > 0 {
+		snap.Disabled = disabled
+	}
+	return snap
+}
+
+// Reset sets all counts back to zero. Requests counted while it runs
+// may or may not be part of the new counts.
+func (s *Stats) Reset() {
+	s.hits.reset()
+	s.misses.reset()
+	s.disabled.reset()
+}
+
+// ResetStats resets the statistics of WithStats that h counts into,
+// see Stats.Reset. Without WithStats there is nothing to reset.
+func (h *Handler) ResetStats() {
+	if h.cfg.stats != nil {
+		h.cfg.stats.Reset()
+	}
+}
+
+// StatsHandler serves the current statistics as JSON:
+//
+//	{"paths": {"/gh": {"hits": 42, "last_hit": "..."}}, "misses": {...}}
+//
+// With ?group=canonical the hits of aliases are added to their
+// entry's path, see CanonicalHits. It takes the same options as
+// AdminHandler.
+func StatsHandler(s *Stats, opts ...AdminOption) http.Handler {
+	return protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap := s.Snapshot()
+		if r.URL.Query().Get("group") == "canonical" {
+			snap.Paths = s.CanonicalHits()
+		}
+		writeJSON(w, http.StatusOK, snap)
+	}), opts)
+}
+
+func (s *Stats) hit(path string, now time.Time) {
+	s.hits.get(path, 0).add(now)
+}
+
+func (s *Stats) miss(path string, now time.Time) {
+	s.misses.get(path, maxMissPaths).add(now)
+}
+
+func (s *Stats) disabledHit(path string, now time.Time) {
+	s.disabled.get(path, 0).add(now)
+}
+
+// counters holds one counter per path
+type counters struct {
+	m sync.Map // path -> *counter
+	n atomic.Int64
+}
+
+// get returns the counter for path, creating it if needed. If limit
+// is set and reached, new paths share the missOverflow counter.
+func (cs *counters) get(path string, limit int64) *counter {
+	if c, ok := cs.m.Load(path); ok {
+		return c.(*counter)
+	}
+	if limit > 0 && cs.n.Load() >= limit {
+		path = missOverflow
+	}
+	c, loaded := cs.m.LoadOrStore(path, new(counter))
+	if !loaded {
+		cs.n.Add(1)
+	}
+	return c.(*counter)
+}
+
+func (cs *counters) snapshot() map[string]PathStats {
+	out := make(map[string]PathStats)
+	cs.m.Range(func(key, value any) bool {
+		out[key.(string)] = value.(*counter).stats()
+		return true
+	})
+	return out
+}
+
+func (cs *counters) reset() {
+	cs.m.Range(func(key, _ any) bool {
+		cs.m.Delete(key)
+		return true
+	})
+	cs.n.Store(0)
+}
+
+// counter is the hit count and last hit time of a single path
+type counter struct {
+	hits    atomic.Int64
+	lastHit atomic.Int64 // unix nanoseconds
+}
+
+func (c *counter) add(now time.Time) {
+	c.hits.Add(1)
+	c.lastHit.Store(now.UnixNano())
+}
+
+func (c *counter) stats() PathStats {
+	ps := PathStats{Hits: c.hits.Load()}
+	if last := c.lastHit.Load(); last != 0 {
+		ps.LastHit = time.Unix(0, last).UTC()
+	}
+	return ps
+}
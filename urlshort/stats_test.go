@@ -0,0 +1,110 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	stats := NewStats()
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com", "/go": "https://go.dev"}), urlshorttest.Fallback(),
+		WithStats(stats), WithClock(clock.Now))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	get(h, "/gh")
+	clock.Advance(time.Minute)
+	get(h, "/gh")
+	get(h, "/gihub")
+
+	snap := stats.Snapshot()
+	if gh := snap.Paths["/gh"]; gh.Hits != 2 || !gh.LastHit.Equal(clock.Now()) {
+		t.Errorf("got %+v for /gh", gh)
+	}
+	if _, ok := snap.Paths["/go"]; ok {
+		t.Error("/go was never hit, but is counted")
+	}
+	if miss := snap.Misses["/gihub"]; miss.Hits != 1 {
+		t.Errorf("got %+v for the /gihub miss", miss)
+	}
+
+	res := get(StatsHandler(stats), "/")
+	var body StatsSnapshot
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", res.Body.String(), err)
+	}
+	if res.Code != http.StatusOK || body.Paths["/gh"].Hits != 2 || body.Misses["/gihub"].Hits != 1 {
+		t.Errorf("got %d %s", res.Code, res.Body.String())
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	stats := NewStats()
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com", "/go": "https://go.dev"}), urlshorttest.Fallback(), WithStats(stats))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	const workers, requests = 20, 50
+	hitAll := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < requests; j++ {
+					get(h, "/gh")
+					get(h, "/go")
+					get(h, "/missing")
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	want := func(what string, n int64) {
+		t.Helper()
+		snap := stats.Snapshot()
+		if snap.Paths["/gh"].Hits != n || snap.Paths["/go"].Hits != n || snap.Misses["/missing"].Hits != n {
+			t.Errorf("%s: got %d, %d and %d misses, want %d each", what,
+				snap.Paths["/gh"].Hits, snap.Paths["/go"].Hits, snap.Misses["/missing"].Hits, n)
+		}
+	}
+
+	hitAll()
+	want("concurrent hits", workers*requests)
+
+	h.ResetStats()
+	snap := stats.Snapshot()
+	if len(snap.Paths) != 0 || len(snap.Misses) != 0 {
+		t.Errorf("after a reset: got %+v", snap)
+	}
+	hitAll()
+	want("after the reset", workers*requests)
+
+	// a handler without stats has nothing to reset
+	plain, _ := New(FromMap(nil), urlshorttest.Fallback())
+	plain.ResetStats()
+}
+
+func TestStatsMissOverflow(t *testing.T) {
+	stats := NewStats()
+	for i := 0; i < maxMissPaths+5; i++ {
+		stats.miss("/"+strconv.Itoa(i), time.Now())
+	}
+	snap := stats.Snapshot()
+	if len(snap.Misses) != maxMissPaths+1 || snap.Misses[missOverflow].Hits != 5 {
+		t.Errorf("got %d missed paths, %d overflowed", len(snap.Misses), snap.Misses[missOverflow].Hits)
+	}
+	// a reset makes room again
+	stats.Reset()
+	stats.miss("/new", time.Now())
+	if snap := stats.Snapshot(); snap.Misses["/new"].Hits != 1 {
+		t.Errorf("after a reset: got %+v", snap.Misses)
+	}
+}
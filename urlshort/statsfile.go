@@ -0,0 +1,181 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StatsPersister stores snapshots of Stats so the counts survive a
+// restart. Load returns an empty snapshot if nothing was saved yet.
+type StatsPersister interface {
+	Load() (StatsSnapshot, error)
+	Save(StatsSnapshot) error
+}
+
+// FileStatsPersister keeps the snapshot in a JSON file. Saving
+// writes a temporary file next to it and renames it into place, so
+// a crash leaves either the old or the new snapshot behind.
+type FileStatsPersister struct {
+	path string
+}
+
+// NewFileStatsPersister persists to the file at path, which doesn't
+// have to exist yet
+func NewFileStatsPersister(path string) *FileStatsPersister {
+	return &FileStatsPersister{path: path}
+}
+
+// Load reads the snapshot from the file
+func (fp *FileStatsPersister) Load() (StatsSnapshot, error) {
+	data, err := os.ReadFile(fp.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return StatsSnapshot{}, nil
+	}
+	if err != nil {
+		return StatsSnapshot{}, fmt.Errorf("urlshort: reading stats: %w", err)
+	}
+	var snap StatsSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return StatsSnapshot{}, fmt.Errorf("urlshort: %s: %w", fp.path, err)
+	}
+	return snap, nil
+}
+
+// Save replaces the snapshot in the file with snap
+func (fp *FileStatsPersister) Save(snap StatsSnapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fp.path), filepath.Base(fp.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("urlshort: saving stats: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("urlshort: saving stats: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("urlshort: saving stats: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("urlshort: saving stats: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), fp.path); err != nil {
+		return fmt.Errorf("urlshort: saving stats: %w", err)
+	}
+	return nil
+}
+
+// statsPersistence saves the stats in the background
+type statsPersistence struct {
+	persister StatsPersister
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// NewPersistentStats returns statistics that start out with the
+// counts p has saved and are saved to p every interval and on Close.
+// A failed save in between is retried at the next interval, Close
+// returns the error of the last one.
+//
+//	stats, err := urlshort.NewPersistentStats(urlshort.NewFileStatsPersister("stats.json"), time.Minute)
+//	defer stats.Close()
+//	h := urlshort.MapHandler(paths, fallback, urlshort.WithStats(stats))
+func NewPersistentStats(p StatsPersister, interval time.Duration) (*Stats, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("urlshort: save interval %v must be positive", interval)
+	}
+	snap, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewStats()
+	s.Restore(snap)
+	s.persist = &statsPersistence{
+		persister: p,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.saveLoop(interval)
+	return s, nil
+}
+
+// Restore adds the counts of snap to s, last hits are kept if they
+// are later than the ones in snap
+func (s *Stats) Restore(snap StatsSnapshot) {
+	for path, ps := range snap.Paths {
+		s.hits.get(path, 0).restore(ps)
+	}
+	for path, ps := range snap.Misses {
+		s.misses.get(path, maxMissPaths).restore(ps)
+	}
+	for path, ps := range snap.Disabled {
+		s.disabled.get(path, 0).restore(ps)
+	}
+}
+
+// Flush saves the current counts right away. It does nothing for
+// stats that aren't persisted.
+func (s *Stats) Flush() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist.persister.Save(s.Snapshot())
+}
+
+// Close stops saving in the background and saves the counts one last
+// time. Count nothing into s after closing it.
+func (s *Stats) Close() error {
+	if s.persist == nil {
+		return nil
+	}
+	s.persist.closeOnce.Do(func() {
+		close(s.persist.stop)
+		<-s.persist.done
+		s.persist.err = s.Flush()
+	})
+	return s.persist.err
+}
+
+// saveLoop saves the counts every interval until Close is called
+func (s *Stats) saveLoop(interval time.Duration) {
+	defer close(s.persist.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.persist.stop:
+			return
+		case <-ticker.C:
+			s.Flush()
+		}
+	}
+}
+
+func (c *counter) restore(ps PathStats) {
+	c.hits.Add(ps.Hits)
+	if ps.LastHit.IsZero() {
+		return
+	}
+	last := ps.LastHit.UnixNano()
+	for {
+		cur := c.lastHit.Load()
+		if cur >= last || c.lastHit.CompareAndSwap(cur, last) {
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// runWithStats starts persistent stats saved to path, sends the
+// requests to a handler counting into them and closes them again,
+// like one run of a server
+func runWithStats(t *testing.T, path string, targets ...string) StatsSnapshot {
+	t.Helper()
+	stats, err := NewPersistentStats(NewFileStatsPersister(path), time.Hour)
+	if err != nil {
+		t.Fatalf("NewPersistentStats: %v", err)
+	}
+	h := MapHandler(map[string]string{"/gh": "https://github.com", "/go": "https://go.dev"}, urlshorttest.Fallback(), WithStats(stats))
+	for _, target := range targets {
+		get(h, target)
+	}
+	snap := stats.Snapshot()
+	if err := stats.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return snap
+}
+
+func TestPersistentStatsRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+
+	tests := []struct {
+		targets    []string
+		gh, goHits int64
+		misses     int64
+	}{
+		{[]string{"/gh", "/gh", "/go", "/missing"}, 2, 1, 1},
+		// the next run starts with the counts of the last one
+		{nil, 2, 1, 1},
+		{[]string{"/gh", "/missing"}, 3, 1, 2},
+	}
+	for i, tt := range tests {
+		snap := runWithStats(t, path, tt.targets...)
+		if snap.Paths["/gh"].Hits != tt.gh || snap.Paths["/go"].Hits != tt.goHits || snap.Misses["/missing"].Hits != tt.misses {
+			t.Errorf("run %d: got /gh %d, /go %d, /missing %d, want %d, %d, %d", i,
+				snap.Paths["/gh"].Hits, snap.Paths["/go"].Hits, snap.Misses["/missing"].Hits, tt.gh, tt.goHits, tt.misses)
+		}
+		if snap.Paths["/gh"].LastHit.IsZero() {
+			t.Errorf("run %d: the last hit of /gh was lost", i)
+		}
+	}
+
+	// saving leaves no temporary files behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "stats.json" {
+		t.Errorf("the directory has %v, want only stats.json", entries)
+	}
+}
+
+func TestPersistentStatsInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	stats, err := NewPersistentStats(NewFileStatsPersister(path), 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewPersistentStats: %v", err)
+	}
+	defer stats.Close()
+	h := MapHandler(map[string]string{"/gh": "https://github.com"}, urlshorttest.Fallback(), WithStats(stats))
+	get(h, "/gh")
+
+	// saved without closing, as if the process crashed afterwards
+	eventually(t, "the stats to be saved", func() bool {
+		snap, err := NewFileStatsPersister(path).Load()
+		return err == nil && snap.Paths["/gh"].Hits == 1
+	})
+}
+
+func TestFileStatsPersister(t *testing.T) {
+	dir := t.TempDir()
+
+	snap, err := NewFileStatsPersister(filepath.Join(dir, "missing.json")).Load()
+	if err != nil || len(snap.Paths) != 0 {
+		t.Errorf("Load of a missing file: %v, %v, want an empty snapshot", snap, err)
+	}
+
+	broken := filepath.Join(dir, "broken.json")
+	os.WriteFile(broken, []byte(`{"paths": `), 0o644)
+	_, err = NewFileStatsPersister(broken).Load()
+	wantError(t, err, "broken.json")
+	_, err = NewPersistentStats(NewFileStatsPersister(broken), time.Minute)
+	wantError(t, err, "broken.json")
+
+	// saving into a directory that is gone fails
+	fp := NewFileStatsPersister(filepath.Join(dir, "gone", "stats.json"))
+	err = fp.Save(StatsSnapshot{Paths: map[string]PathStats{"/gh": {Hits: 1}}})
+	wantError(t, err, "saving stats")
+
+	_, err = NewPersistentStats(NewFileStatsPersister(filepath.Join(dir, "stats.json")), 0)
+	wantError(t, err, "must be positive")
+}
+
+func TestPersistentStatsCloseError(t *testing.T) {
+	dir := t.TempDir()
+	stats, err := NewPersistentStats(NewFileStatsPersister(filepath.Join(dir, "sub", "stats.json")), time.Hour)
+	if err != nil {
+		t.Fatalf("NewPersistentStats: %v", err)
+	}
+	err = stats.Close()
+	wantError(t, err, "saving stats")
+	// closing again reports the same error instead of saving again
+	if again := stats.Close(); again != err {
+		t.Errorf("second Close: %v, want %v", again, err)
+	}
+	if err := NewStats().Close(); err != nil {
+		t.Errorf("Close of stats that aren't persisted: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrDuplicate is returned when adding a path that already exists
+var ErrDuplicate = errors.New("urlshort: path already exists")
+
+// MutableStore holds mappings that can be changed while they are
+// being served. Unlike the map given to MapHandler, which must not
+// be modified once the handler is in use, it is safe for concurrent
+// use. See WithShards for stores with many concurrent writes.
+type MutableStore struct {
+	// shards hold the mappings by the hash of their path, urlShards
+	// the reverse index by the hash of the URL. A shard is always
+	// locked before a urlShard.
+	shards    []storeShard
+	urlShards []urlShard
+	// shortenMu makes Shorten check and map a URL in one go
+	shortenMu sync.Mutex
+
+	// codeLength is the length of the codes Shorten generates
+	codeLength int
+	// stats is what the store is served with, for the admin API
+	stats atomic.Pointer[Stats]
+	// prefix is the WithPathPrefix it is served with, for exports
+	prefix atomic.Pointer[string]
+}
+
+// StoreOption changes the behavior of a MutableStore
+type StoreOption func(*MutableStore)
+
+// NewMutableStore returns an empty store
+func NewMutableStore(opts ...StoreOption) *MutableStore {
+	s := &MutableStore{codeLength: defaultCodeLength}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if len(s.shards) == 0 {
+		s.makeShards(1)
+	}
+	return s
+}
+
+// put stores pu in sh, the shard of its path, and keeps the reverse
+// index up to date. The caller must hold the lock of sh.
+func (s *MutableStore) put(sh *storeShard, pu pathUrl) {
+	s.del(sh, pu.Path)
+	sh.entries[pu.Path] = pu
+	us := s.urlShard(pu.URL)
+	us.mu.Lock()
+	if us.byURL[pu.URL] == nil {
+		us.byURL[pu.URL] = make(map[string]bool)
+	}
+	us.byURL[pu.URL][pu.Path] = true
+	us.mu.Unlock()
+}
+
+// del removes path from sh, the shard of path, and keeps the reverse
+// index up to date. The caller must hold the lock of sh.
+func (s *MutableStore) del(sh *storeShard, path string) bool {
+	pu, ok := sh.entries[path]
+	if !ok {
+		return false
+	}
+	delete(sh.entries, path)
+	us := s.urlShard(pu.URL)
+	us.mu.Lock()
+	delete(us.byURL[pu.URL], path)
+	if len(us.byURL[pu.URL]) == 0 {
+		delete(us.byURL, pu.URL)
+	}
+	us.mu.Unlock()
+	return true
+}
+
+// Add stores a new mapping. It fails with ErrDuplicate if path is
+// already mapped, use Set to replace it.
+func (s *MutableStore) Add(path, dest string) error {
+	if err := validateMapping(path, dest); err != nil {
+		return err
+	}
+
+	sh := s.shard(path)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if _, ok := sh.entries[path]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, path)
+	}
+	s.put(sh, pathUrl{Path: path, URL: dest})
+	return nil
+}
+
+// Set stores a mapping, replacing the destination if path is
+// already mapped.
+func (s *MutableStore) Set(path, dest string) error {
+	if err := validateMapping(path, dest); err != nil {
+		return err
+	}
+
+	sh := s.shard(path)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	s.put(sh, pathUrl{Path: path, URL: dest})
+	return nil
+}
+
+// Replace swaps all mappings for the ones in pathsToUrls. Every
+// mapping is validated first, if one is invalid the store is left
+// as it was.
+func (s *MutableStore) Replace(pathsToUrls map[string]string) error {
+	for path, dest := range pathsToUrls {
+		if err := validateMapping(path, dest); err != nil {
+			return err
+		}
+	}
+
+	pathUrls := make([]pathUrl, 0, len(pathsToUrls))
+	for path, dest := range pathsToUrls {
+		pathUrls = append(pathUrls, pathUrl{Path: path, URL: dest})
+	}
+	s.apply(pathUrls, true)
+	return nil
+}
+
+// apply stores pathUrls, which must be valid, while holding the
+// locks of all shards, so readers see either none or all of them
+// and no mix. With replace, all other mappings are removed. Entries
+// keep whether they are disabled.
+func (s *MutableStore) apply(pathUrls []pathUrl, replace bool) {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		defer s.shards[i].mu.Unlock()
+		if replace {
+			s.shards[i].entries = make(map[string]pathUrl)
+		}
+	}
+	if replace {
+		for i := range s.urlShards {
+			s.urlShards[i].mu.Lock()
+			s.urlShards[i].byURL = make(map[string]map[string]bool)
+			s.urlShards[i].mu.Unlock()
+		}
+	}
+	for _, pu := range pathUrls {
+		s.put(s.shard(pu.Path), pathUrl{Path: pu.Path, URL: pu.URL, Disabled: pu.Disabled})
+	}
+}
+
+// Disable makes path act as missing, keeping its mapping, and
+// reports whether it exists. Set replaces the mapping with an
+// enabled one.
+func (s *MutableStore) Disable(path string) bool {
+	return s.setDisabled(path, true)
+}
+
+// Enable undoes Disable and reports whether path exists
+func (s *MutableStore) Enable(path string) bool {
+	return s.setDisabled(path, false)
+}
+
+func (s *MutableStore) setDisabled(path string, disabled bool) bool {
+	sh := s.shard(path)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	pu, ok := sh.entries[path]
+	if !ok {
+		return false
+	}
+	pu.Disabled = disabled
+	sh.entries[path] = pu
+	return true
+}
+
+// Remove deletes the mapping for path and reports whether it existed
+func (s *MutableStore) Remove(path string) bool {
+	sh := s.shard(path)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return s.del(sh, path)
+}
+
+// Lookup returns the destination for path, disabled mappings are
+// missing
+func (s *MutableStore) Lookup(path string) (string, bool) {
+	pu, ok := s.get(path)
+	if !ok || pu.Disabled {
+		return "", false
+	}
+	return pu.URL, true
+}
+
+// get returns the entry for path
+func (s *MutableStore) get(path string) (pathUrl, bool) {
+	sh := s.shard(path)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	pu, ok := sh.entries[path]
+	return pu, ok
+}
+
+// lookup is get with the slash normalization of cfg. Like in a route
+// table the other spelling is only tried after path missed.
+func (s *MutableStore) lookup(path string, cfg *config) (pathUrl, bool) {
+	if pu, ok := s.get(path); ok || !cfg.slashes {
+		return pu, ok
+	}
+	if alt, ok := otherSlash(path); ok {
+		return s.get(alt)
+	}
+	return pathUrl{}, false
+}
+
+// Routes returns a copy of all mappings. Like for everything that
+// lists the mappings, each shard is copied on its own, so writes
+// that happen meanwhile may only show in some of them.
+func (s *MutableStore) Routes() map[string]string {
+	routes := make(map[string]string)
+	s.each(func(pu pathUrl) {
+		routes[pu.Path] = pu.URL
+	})
+	return routes
+}
+
+// paths returns the paths in the store
+func (s *MutableStore) paths() []string {
+	var paths []string
+	s.each(func(pu pathUrl) {
+		paths = append(paths, pu.Path)
+	})
+	return paths
+}
+
+// entryList returns a copy of all mappings sorted by path
+func (s *MutableStore) entryList() []pathUrl {
+	var pathUrls []pathUrl
+	s.each(func(pu pathUrl) {
+		pathUrls = append(pathUrls, pu)
+	})
+
+	sort.Slice(pathUrls, func(i, j int) bool {
+		return pathUrls[i].Path < pathUrls[j].Path
+	})
+	return pathUrls
+}
+
+// NewMutableHandler will return an http.HandlerFunc that redirects
+// using the mappings in store as they are at the time of each
+// request. If the path is not in the store, the fallback
+// http.Handler will be called instead. WithSlashNormalization works
+// like for the other handlers, WithCaseInsensitivePaths doesn't,
+// since the store keeps paths as they were written.
+// NewMutableHandler panics if the options are invalid.
+func NewMutableHandler(store *MutableStore, fallback http.Handler, opts ...Option) http.HandlerFunc {
+	cfg := mustConfig(opts)
+	if cfg.foldCase {
+		panic("urlshort: NewMutableHandler can't match paths case-insensitively, the store keeps them as written")
+	}
+	fallback = mustFallbackFor(fallback, cfg)
+	store.prefix.Store(&cfg.pathPrefix)
+	if cfg.stats != nil {
+		cfg.stats.track(store.paths, nil, cfg.now)
+		store.stats.Store(cfg.stats)
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.tracer != nil {
+			var end func()
+			r, end = cfg.startSpan(r, "mutable")
+			defer end()
+		}
+		path, ok := lookupPath(r, cfg)
+		if !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+
+		if pu, ok := store.lookup(path, cfg); ok {
+			serveMatch(w, r, match{entry: pu, dest: pu.URL}, fallback, cfg)
+			return
+		}
+		serveMiss(w, r, fallback, cfg)
+	}
+}
+
+// validateMapping checks a single path and destination
+func validateMapping(path, dest string) error {
+	if path == "" || path[0] != '/' {
+		return fmt.Errorf("urlshort: path %q must start with /", path)
+	}
+	return validateDestination(dest)
+}
+
+// mappingConfig is what validateDestination checks against, it is
+// only read. Building a defaultConfig per call would cost more than
+// the write being validated.
+var mappingConfig = defaultConfig()
+
+// validateDestination makes sure dest is an absolute http(s) URL
+func validateDestination(dest string) error {
+	if reason := destinationIssue(dest, mappingConfig); reason != "" {
+		return fmt.Errorf("urlshort: %s", reason)
+	}
+	return nil
+}
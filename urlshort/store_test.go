@@ -0,0 +1,143 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestMutableStore(t *testing.T) {
+	store := NewMutableStore()
+	h := NewMutableHandler(store, urlshorttest.Fallback())
+
+	if err := store.Add("/gh", "https://github.com"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := store.Add("/gh", "https://gitlab.com"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Add of a mapped path: got %v, want ErrDuplicate", err)
+	}
+	if err := store.Add("/go", "https://go.dev"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+	})
+
+	if dest, ok := store.Lookup("/go"); !ok || dest != "https://go.dev" {
+		t.Errorf("Lookup(/go) = %q, %v", dest, ok)
+	}
+	if !store.Remove("/go") || store.Remove("/go") {
+		t.Error("Remove should report true once and false after")
+	}
+	if _, ok := store.Lookup("/go"); ok {
+		t.Error("Lookup(/go) found the removed mapping")
+	}
+
+	if err := store.Replace(map[string]string{"/a": "https://a.example.com", "/b": "https://b.example.com"}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/a", Location: "https://a.example.com", Code: http.StatusFound},
+		{Path: "/b", Location: "https://b.example.com", Code: http.StatusFound},
+		{Path: "/gh"},
+	})
+
+	// an invalid mapping leaves the store as it was
+	if err := store.Replace(map[string]string{"/c": "https://c.example.com", "d": "https://d.example.com"}); err == nil {
+		t.Error("Replace with an invalid path: got no error")
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/a", Location: "https://a.example.com", Code: http.StatusFound},
+		{Path: "/c"},
+	})
+}
+
+func TestMutableStoreValidation(t *testing.T) {
+	tests := []struct {
+		path, dest string
+		ok         bool
+	}{
+		{"/gh", "https://github.com", true},
+		{"/docs/api", "http://docs.example.com/api?v=1", true},
+		{"gh", "https://github.com", false},
+		{"", "https://github.com", false},
+		{"/gh", "", false},
+		{"/gh", "://github.com", false},
+		{"/gh", "github.com", false},
+	}
+	for _, tt := range tests {
+		err := NewMutableStore().Add(tt.path, tt.dest)
+		if (err == nil) != tt.ok {
+			t.Errorf("Add(%q, %q) = %v, want ok = %v", tt.path, tt.dest, err, tt.ok)
+		}
+	}
+}
+
+func TestMutableHandlerSlashes(t *testing.T) {
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	store.Set("/docs/", "https://go.dev/doc")
+	store.Set("/x", "https://example.com/x")
+	store.Set("/x/", "https://example.com/x-slash")
+	store.Set("/", "https://example.com")
+	h := NewMutableHandler(store, urlshorttest.Fallback(), WithSlashNormalization())
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh/", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://go.dev/doc", Code: http.StatusFound},
+		// the requested spelling wins
+		{Path: "/x", Location: "https://example.com/x", Code: http.StatusFound},
+		{Path: "/x/", Location: "https://example.com/x-slash", Code: http.StatusFound},
+		{Path: "/", Location: "https://example.com", Code: http.StatusFound},
+		{Path: "/GH"},
+	})
+
+	// without the option the paths match exactly
+	urlshorttest.AssertFallback(t, NewMutableHandler(store, urlshorttest.Fallback()), "/gh/")
+}
+
+func TestMutableHandlerCaseInsensitive(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewMutableHandler accepted WithCaseInsensitivePaths")
+		}
+	}()
+	NewMutableHandler(NewMutableStore(), urlshorttest.Fallback(), WithCaseInsensitivePaths())
+}
+
+func TestMutableStoreConcurrent(t *testing.T) {
+	store := NewMutableStore()
+	h := NewMutableHandler(store, urlshorttest.Fallback())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Add(fmt.Sprintf("/w%d-%d", i, j), "https://example.com")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				store.Remove(fmt.Sprintf("/w%d-%d", i, j))
+				if j%10 == 0 {
+					store.Replace(map[string]string{"/gh": "https://github.com"})
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				get(h, fmt.Sprintf("/w%d-%d", i, j))
+				get(h, "/gh")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,229 @@
+package urlshort
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// streamBatch is how many list entries ParseYAMLStream parses at
+// once, a single entry per call costs more than the parsing
+const streamBatch = 256
+
+// ParseYAMLStream reads YAML mappings from r and calls fn with each
+// entry as soon as it is parsed, so files too large to hold in
+// memory twice can be loaded. It reads the list YAMLHandler accepts,
+// a few hundred entries at a time, and documents separated by ---
+// that are either such a list or a single entry:
+//
+//	path: /a
+//	url: https://a.example.com
+//	---
+//	- path: /b
+//	  url: https://b.example.com
+//
+// Documents in the format with vars are read whole, vars and
+// redirects can come in any order, include isn't supported outside
+// of files. Entries are checked like
+// YAMLHandler checks them, errors name the index of the entry. An
+// error returned by fn stops the parsing and is returned as it is.
+func ParseYAMLStream(r io.Reader, fn func(Entry) error) error {
+	p := yamlStream{fn: fn}
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			if err := p.line(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return p.endDocument()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// yamlStream cuts a YAML stream into entries. The top level list
+// items of a document start at the indentation of its first dash.
+type yamlStream struct {
+	fn    func(Entry) error
+	index int
+
+	// chunk holds the lines of the entries being read, items is how
+	// many list entries it has
+	chunk []byte
+	items int
+	// started is set once the document had something besides
+	// comments, list tells whether it is a list and indent is the
+	// column of its dashes
+	started bool
+	list    bool
+	indent  int
+	// doc is set for a document in the format with vars, seen by
+	// one of its keys at the top level
+	doc bool
+}
+
+// yamlDocKeys start the top level lines of the format with vars
+var yamlDocKeys = []string{"include:", "vars:", "redirects:"}
+
+func (p *yamlStream) line(line string) error {
+	text := strings.TrimRight(line, " \r\n")
+	if text == "---" || strings.HasPrefix(text, "--- ") || text == "..." {
+		return p.endDocument()
+	}
+	trimmed := strings.TrimLeft(text, " ")
+	if trimmed == "" || trimmed[0] == '#' {
+		if p.started {
+			p.chunk = append(p.chunk, line...)
+		}
+		return nil
+	}
+
+	col := len(text) - len(trimmed)
+	marker := trimmed[0] == '-' && (len(trimmed) == 1 || trimmed[1] == ' ')
+	if !p.started {
+		p.started, p.list, p.indent, p.doc = true, marker, col, false
+	}
+	if !p.list && col == p.indent {
+		for _, key := range yamlDocKeys {
+			if strings.HasPrefix(trimmed, key) {
+				p.doc = true
+			}
+		}
+	}
+	if p.list && marker && col == p.indent {
+		if p.items == streamBatch {
+			if err := p.flush(); err != nil {
+				return err
+			}
+		}
+		p.items++
+	}
+	p.chunk = append(p.chunk, line...)
+	return nil
+}
+
+// endDocument hands out what is left of the current document
+func (p *yamlStream) endDocument() error {
+	err := p.flush()
+	p.started = false
+	return err
+}
+
+// flush parses the lines of the current chunk
+func (p *yamlStream) flush() error {
+	if len(bytes.TrimSpace(p.chunk)) == 0 {
+		p.chunk, p.items = p.chunk[:0], 0
+		return nil
+	}
+	chunk := p.chunk
+	p.chunk, p.items = p.chunk[:0], 0
+
+	if p.doc {
+		pathUrls, err := unmarshalYAML(chunk, yaml.Unmarshal)
+		if err != nil {
+			return fmt.Errorf("urlshort: entry %d: %s", p.index, strings.TrimPrefix(err.Error(), "urlshort: "))
+		}
+		for _, pu := range pathUrls {
+			if err := p.emit(pu); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !p.list {
+		var pu pathUrl
+		if err := yaml.Unmarshal(chunk, &pu); err != nil {
+			return fmt.Errorf("urlshort: entry %d: %v", p.index, err)
+		}
+		return p.emit(pu)
+	}
+	var pathUrls []pathUrl
+	if err := yaml.Unmarshal(chunk, &pathUrls); err != nil {
+		index := p.index
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			if i := entryAtLine(chunk, line); i >= 0 {
+				index += i
+			}
+		}
+		// the line numbers are of the batch, not of the stream
+		msg := strings.TrimPrefix(err.Error(), "yaml: ")
+		if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+			msg = strings.TrimSpace(strings.TrimPrefix(msg, m[0]))
+		}
+		return fmt.Errorf("urlshort: entry %d: %s", index, msg)
+	}
+	for _, pu := range pathUrls {
+		if err := p.emit(pu); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *yamlStream) emit(pu pathUrl) error {
+	i := p.index
+	p.index++
+	if err := streamEntry(i, &pu); err != nil {
+		return err
+	}
+	return p.fn(pu)
+}
+
+// streamEntry checks entry i of a stream and fills in its derived
+// fields
+func streamEntry(i int, pu *pathUrl) error {
+	if err := checkComplete(i, *pu); err != nil {
+		return err
+	}
+	if err := prepareEntry(pu); err != nil {
+		return fmt.Errorf("urlshort: entry %d: %s", i, strings.TrimPrefix(err.Error(), "urlshort: "))
+	}
+	return nil
+}
+
+// ParseJSONLines reads newline delimited JSON from r, one object
+// like the entries JSONHandler accepts per line, and calls fn with
+// each entry as soon as it is parsed. Blank lines and lines
+// starting with # are skipped. Lines can be of any length, only one
+// is held in memory at a time. Entries are checked like JSONHandler
+// checks them, errors name the index of the entry. An error returned
+// by fn stops the parsing and is returned as it is.
+func ParseJSONLines(r io.Reader, fn func(Entry) error) error {
+	br := bufio.NewReader(r)
+	index := 0
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != '#' {
+			var pu pathUrl
+			if err := json.Unmarshal(line, &pu); err != nil {
+				return fmt.Errorf("urlshort: entry %d (line %d): invalid JSON: %v", index, n, err)
+			}
+			if err := streamEntry(index, &pu); err != nil {
+				return err
+			}
+			if err := fn(pu); err != nil {
+				return err
+			}
+			index++
+		}
+		if err != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package urlshort
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamedHandler serves the entries ParseYAMLStream reads from data
+func streamedHandler(t *testing.T, data string) http.Handler {
+	t.Helper()
+	var entries []Entry
+	err := ParseYAMLStream(strings.NewReader(data), func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseYAMLStream: %v", err)
+	}
+	h, err := New(FromMap(nil), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Reload(entries); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	return h
+}
+
+func TestParseYAMLStreamMatchesYAMLHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		paths []string
+	}{
+		{
+			name: "list",
+			data: `
+- path: /a
+  url: https://a.example.com
+- path: /b
+  url: https://b.example.com
+  status: 301
+`,
+			paths: []string{"/a", "/b", "/c"},
+		},
+		{
+			name: "vars first",
+			data: `
+vars:
+  base: https://wiki.example.com
+redirects:
+  - path: /onboarding
+    url: ${base}/Onboarding
+  - path: /price
+    url: https://shop.example.com/$${price}
+`,
+			paths: []string{"/onboarding", "/price", "/base"},
+		},
+		{
+			name: "redirects first",
+			data: `
+# redirects before their vars
+redirects:
+  - path: /onboarding
+    url: ${base}/Onboarding
+vars:
+  base: https://wiki.example.com
+`,
+			paths: []string{"/onboarding"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := YAMLHandler([]byte(tt.data), nil)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			got := streamedHandler(t, tt.data)
+			for _, path := range tt.paths {
+				wantRes, gotRes := httptest.NewRecorder(), httptest.NewRecorder()
+				want.ServeHTTP(wantRes, httptest.NewRequest(http.MethodGet, path, nil))
+				got.ServeHTTP(gotRes, httptest.NewRequest(http.MethodGet, path, nil))
+				if gotRes.Code != wantRes.Code || gotRes.Header().Get("Location") != wantRes.Header().Get("Location") {
+					t.Errorf("GET %s: streamed %d to %q, YAMLHandler %d to %q", path,
+						gotRes.Code, gotRes.Header().Get("Location"), wantRes.Code, wantRes.Header().Get("Location"))
+				}
+			}
+		})
+	}
+}
+
+// streamBenchEntries is the size of the generated benchmark input,
+// about the size of a large migrated redirect file
+const streamBenchEntries = 1_000_000
+
+// writeBenchFile writes n generated entries in the format of line to
+// a file in dir
+func writeBenchFile(b *testing.B, dir, name string, n int, line func(w io.Writer, i int)) string {
+	b.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	for i := 0; i < n; i++ {
+		line(w, i)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// peakHeap runs fn and returns the most heap it had in use on top of
+// what was in use before, sampled every millisecond
+func peakHeap(fn func()) uint64 {
+	var ms runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&ms)
+	base, peak := ms.HeapAlloc, ms.HeapAlloc
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		var ms runtime.MemStats
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&ms)
+			peak = max(peak, ms.HeapAlloc)
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	fn()
+	close(stop)
+	<-done
+	return peak - base
+}
+
+// BenchmarkParseLarge builds the map of a generated 1M entry file by
+// reading it whole like YAMLHandler and JSONHandler do, and by
+// streaming it. peak-MB is the most heap in use while building,
+// -short uses 10k entries.
+func BenchmarkParseLarge(b *testing.B) {
+	n := streamBenchEntries
+	if testing.Short() {
+		n = 10_000
+	}
+	dir := b.TempDir()
+	yamlFile := writeBenchFile(b, dir, "paths.yaml", n, func(w io.Writer, i int) {
+		fmt.Fprintf(w, "- path: /p%d\n  url: https://example.com/page/%d\n", i, i)
+	})
+	jsonFile := writeBenchFile(b, dir, "paths.jsonl", n, func(w io.Writer, i int) {
+		fmt.Fprintf(w, `{"path": "/p%d", "url": "https://example.com/page/%d"}`+"\n", i, i)
+	})
+
+	whole := func(b *testing.B, path string, parse func([]byte) ([]pathUrl, error)) map[string]string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pathUrls, err := parse(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m := make(map[string]string)
+		for _, pu := range pathUrls {
+			m[pu.Path] = pu.URL
+		}
+		return m
+	}
+	streamed := func(b *testing.B, path string, parse func(io.Reader, func(Entry) error) error) map[string]string {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer f.Close()
+		m := make(map[string]string)
+		err = parse(f, func(e Entry) error {
+			m[e.Path] = e.URL
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		return m
+	}
+
+	benchmarks := []struct {
+		name  string
+		build func(b *testing.B) map[string]string
+	}{
+		{"yaml/whole", func(b *testing.B) map[string]string { return whole(b, yamlFile, parseYAML) }},
+		{"yaml/ParseYAMLStream", func(b *testing.B) map[string]string { return streamed(b, yamlFile, ParseYAMLStream) }},
+		{"json/whole", func(b *testing.B) map[string]string {
+			// JSONHandler reads an array, so give it one
+			return whole(b, jsonFile, func(data []byte) ([]pathUrl, error) {
+				data = bytes.ReplaceAll(bytes.TrimSpace(data), []byte("\n"), []byte(",\n"))
+				return parseJSON(append(append([]byte("["), data...), ']'))
+			})
+		}},
+		{"json/ParseJSONLines", func(b *testing.B) map[string]string { return streamed(b, jsonFile, ParseJSONLines) }},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				peak = max(peak, peakHeap(func() {
+					if m := bm.build(b); len(m) != n {
+						b.Fatalf("got %d entries, want %d", len(m), n)
+					}
+				}))
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-MB")
+		})
+	}
+}
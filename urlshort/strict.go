@@ -0,0 +1,122 @@
+package urlshort
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// WithStrictParsing makes the YAML parsers reject anything that
+// looks like a mistake instead of ignoring it: unknown keys (like
+// ulr instead of url), entries without a path or url, and paths
+// that don't start with a slash. Errors name the entry they were
+// found in.
+func WithStrictParsing() Option {
+	return func(c *config) {
+		c.strict = true
+	}
+}
+
+// parseYAML parses data in the mode the options ask for
+func (c *config) parseYAML(data []byte) ([]pathUrl, error) {
+	if c.strict {
+		return parseYAMLStrict(data)
+	}
+	return parseYAML(data)
+}
+
+// yamlErrorLine finds the line numbers yaml.v2 puts in its errors
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+func parseYAMLStrict(data []byte) ([]pathUrl, error) {
+	pathUrls, err := unmarshalYAML(data, yaml.UnmarshalStrict)
+	if err != nil {
+		return nil, strictError(data, err)
+	}
+	if err := checkStrict(pathUrls); err != nil {
+		return nil, err
+	}
+	return pathUrls, nil
+}
+
+// strictError points err at the entry instead of just the line
+// where possible
+func strictError(data []byte, err error) error {
+	if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		if i := entryAtLine(data, line); i >= 0 {
+			return fmt.Errorf("urlshort: entry %d: %v", i, err)
+		}
+	}
+	return err
+}
+
+// checkStrict prepares pathUrls, rejecting incomplete entries and
+// paths that don't start with a slash
+func checkStrict(pathUrls []pathUrl) error {
+	if err := checkEntries(pathUrls); err != nil {
+		return err
+	}
+	for i, pu := range pathUrls {
+		if !pu.Regex && pu.Path[0] != '/' {
+			return fmt.Errorf("urlshort: entry %d: path %q must start with /", i, pu.Path)
+		}
+	}
+	return nil
+}
+
+// entryAtLine returns the index of the entry that contains the
+// 1-based line, or -1 if it is before the first one.
+func entryAtLine(data []byte, line int) int {
+	index := -1
+	for i, start := range entryLines(data) {
+		if start > line {
+			break
+		}
+		index = i
+	}
+	return index
+}
+
+// entryLines returns the 1-based line every entry starts on, both
+// for a plain list and for the redirects of a document with vars.
+// It only looks at the "- " markers, which is good enough to point
+// a human at the right entry.
+func entryLines(data []byte) []int {
+	var starts []int
+	first, isDoc, inList := true, false, false
+	indent := -1
+	for n, text := range bytes.Split(data, []byte("\n")) {
+		text = bytes.TrimRight(text, " \r")
+		trimmed := bytes.TrimLeft(text, " ")
+		if len(trimmed) == 0 || trimmed[0] == '#' || bytes.Equal(trimmed, []byte("---")) {
+			continue
+		}
+		col := len(text) - len(trimmed)
+		marker := trimmed[0] == '-' && (len(trimmed) == 1 || trimmed[1] == ' ')
+
+		if first {
+			// the first line tells which of the two formats this is
+			first, isDoc = false, !marker
+		}
+		if isDoc && col == 0 && !marker {
+			// a new top level key
+			inList = bytes.HasPrefix(trimmed, []byte("redirects:"))
+			indent = -1
+			continue
+		}
+		if !marker || isDoc && !inList {
+			continue
+		}
+		if indent == -1 {
+			indent = col
+		}
+		if col == indent {
+			starts = append(starts, n+1)
+		}
+	}
+	return starts
+}
@@ -0,0 +1,60 @@
+package urlshort
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestStrictParsing(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"unknown key", "- path: /gh\n  url: https://github.com\n- path: /go\n  ulr: https://go.dev\n",
+			"urlshort: entry 1: yaml: unmarshal errors:\n  line 4: field ulr not found"},
+		{"unknown key in a document", "vars:\n  base: https://go.dev\nredirects:\n  - path: /gh\n    url: https://github.com\n  - path: /go\n    url: ${base}\n    cod: 301\n",
+			"urlshort: entry 1: "},
+		{"missing url", "- path: /gh\n  url: https://github.com\n- path: /go\n", "urlshort: entry 1 (/go) is missing a url"},
+		{"missing path", "- url: https://github.com\n", "urlshort: entry 0 is missing a path"},
+		{"no slash", "- path: gh\n  url: https://github.com\n", `urlshort: entry 0: path "gh" must start with /`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), urlshorttest.Fallback(), WithStrictParsing())
+			wantError(t, err, tt.want)
+		})
+	}
+
+	// without strict parsing unknown keys are ignored
+	h, err := YAMLHandler([]byte("- path: /gh\n  url: https://github.com\n  comment: the code\n"), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+
+	// regex entries don't start with a slash
+	h, err = YAMLHandler([]byte("- path: ^/issues/(\\d+)$\n  url: https://example.com/$1\n  regex: true\n"), urlshorttest.Fallback(), WithStrictParsing())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/issues/1", "https://example.com/1", http.StatusFound)
+}
+
+func TestEntryLines(t *testing.T) {
+	tests := []struct {
+		name, yaml string
+		want       []int
+	}{
+		{"list", "- path: /a\n  url: x\n\n# comment\n- path: /b\n  url: y\n", []int{1, 5}},
+		{"nested lists", "- path: /a\n  urls:\n    - url: x\n    - url: y\n- path: /b\n", []int{1, 5}},
+		{"document", "---\nvars:\n  - not an entry\nredirects:\n  - path: /a\n    url: x\n  - path: /b\nother:\n  - no\n", []int{5, 7}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		if got := entryLines([]byte(tt.yaml)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// maxSuggestDistance is the largest edit distance still suggested
+const maxSuggestDistance = 2
+
+// WithSuggestions computes the configured paths closest to a path
+// that didn't match (edit distance of at most 2) and passes them to
+// the fallback in the request context, see SuggestionsFromContext.
+// NotFoundHandler lists them on its page.
+func WithSuggestions() Option {
+	return func(c *config) {
+		c.suggest = true
+	}
+}
+
+// WithAutoCorrect redirects a path that didn't match to the closest
+// configured path when there is exactly one closest path, so /gihub
+// goes where /github goes. When two or more paths are equally close,
+// nothing is corrected and the fallback gets the suggestions like
+// with WithSuggestions.
+func WithAutoCorrect() Option {
+	return func(c *config) {
+		c.suggest = true
+		c.autoCorrect = true
+	}
+}
+
+// SuggestionsFromContext returns the suggested paths that a handler
+// with WithSuggestions or WithAutoCorrect attached to the request
+// before calling the fallback. They are ordered by edit distance,
+// then the entries for the host of the request come first, then
+// alphabetically.
+func SuggestionsFromContext(ctx context.Context) []string {
+	suggestions, _ := ctx.Value(suggestionsKey).([]string)
+	return suggestions
+}
+
+// withSuggestions attaches suggestions to the request context
+func withSuggestions(r *http.Request, suggestions []string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), suggestionsKey, suggestions))
+}
+
+// suggestion is a configured path close to the requested one
+type suggestion struct {
+	key      string
+	entry    pathUrl
+	distance int
+}
+
+// suggestHost returns the suggestions for path on host: the entries
+// for host come first among equally close ones, and replace generic
+// entries with the same key, like they do in lookupHost
+func (rt *routeTable) suggestHost(host, path string) []suggestion {
+	found := rt.suggest(path)
+	hostRoutes := rt.hosts[normalizeHost(host)]
+	if hostRoutes == nil {
+		return found
+	}
+	own := hostRoutes.suggest(path)
+	if len(own) == 0 {
+		return found
+	}
+	shadowed := make(map[string]bool, len(own))
+	for _, s := range own {
+		shadowed[s.key] = true
+	}
+	for _, s := range found {
+		if !shadowed[s.key] {
+			own = append(own, s)
+		}
+	}
+	sort.SliceStable(own, func(i, j int) bool { return own[i].distance < own[j].distance })
+	return own
+}
+
+// suggest returns the exact paths within maxSuggestDistance of path.
+// Only paths whose length is close enough to be in reach are
+// compared, so a miss doesn't cost a scan over the whole table.
+func (rt *routeTable) suggest(path string) []suggestion {
+	key := rt.normalize(path)
+	n := len(key)
+
+	var found []suggestion
+	for l := n - maxSuggestDistance; l <= n+maxSuggestDistance; l++ {
+		for _, candidate := range rt.byLength[l] {
+			if d, ok := boundedLevenshtein(key, candidate, maxSuggestDistance); ok {
+				found = append(found, suggestion{candidate, rt.exact[candidate], d})
+			}
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].distance != found[j].distance {
+			return found[i].distance < found[j].distance
+		}
+		return found[i].key < found[j].key
+	})
+	return found
+}
+
+// correction returns the single closest suggestion, if there is one
+func correction(found []suggestion) (suggestion, bool) {
+	if len(found) == 0 {
+		return suggestion{}, false
+	}
+	if len(found) > 1 && found[1].distance == found[0].distance {
+		return suggestion{}, false
+	}
+	return found[0], true
+}
+
+// buildLengthIndex groups the exact keys by length for suggest
+func buildLengthIndex(exact map[string]pathUrl) map[int][]string {
+	index := make(map[int][]string)
+	for key := range exact {
+		index[len(key)] = append(index[len(key)], key)
+	}
+	return index
+}
+
+// boundedLevenshtein is levenshtein that gives up as soon as the
+// distance is known to be over limit. Distances are in bytes, which
+// is close enough for suggestions.
+func boundedLevenshtein(a, b string, limit int) (int, bool) {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return 0, false
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return 0, false
+		}
+		prev, cur = cur, prev
+	}
+	if prev[len(b)] > limit {
+		return 0, false
+	}
+	return prev[len(b)], true
+}
@@ -0,0 +1,150 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const suggestEntries = `
+- path: /github
+  url: https://github.com
+- path: /docs
+  url: https://go.dev/doc
+- path: /dogs
+  url: https://example.com/dogs
+- path: /dot
+  url: https://example.com/dot
+- path: /blob
+  url: https://example.com/blob
+- path: /blog
+  url: https://go.example.com/blog
+  host: go.example.com
+- path: /github
+  url: https://github.com/golang
+  host: go.example.com
+`
+
+// suggestionsOf serves target on host and returns the status, the
+// Location and the suggestions the fallback got
+func suggestionsOf(h http.Handler, host, target string) (int, string, []string) {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Host = host
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code, rec.Header().Get("Location"), rec.Header().Values("X-Suggestions")
+}
+
+// suggestFallback answers 404 with the suggestions it got in the
+// X-Suggestions header
+var suggestFallback = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	for _, s := range SuggestionsFromContext(r.Context()) {
+		w.Header().Add("X-Suggestions", s)
+	}
+	http.NotFound(w, r)
+})
+
+func TestSuggestions(t *testing.T) {
+	h, err := YAMLHandler([]byte(suggestEntries), suggestFallback, WithSuggestions())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		name, host, target string
+		want               []string
+	}{
+		{"one close path", "example.com", "/gihub", []string{"/github"}},
+		{"two edits", "example.com", "/gthb", []string{"/github"}},
+		// three edits are too far off
+		{"three edits", "example.com", "/gth", nil},
+		// by distance, then alphabetically
+		{"ties", "example.com", "/dots", []string{"/docs", "/dogs", "/dot"}},
+		// the entries for the host are suggested there, and first
+		// among equally close ones
+		{"host entries", "go.example.com", "/blox", []string{"/blog", "/blob"}},
+		{"other host", "links.example.org", "/blox", []string{"/blob"}},
+		{"host case", "GO.example.com:8080", "/blgo", []string{"/blog", "/blob"}},
+		// a host entry replaces the generic one with the same path
+		{"shadowed", "go.example.com", "/gihub", []string{"/github"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, got := suggestionsOf(h, tt.host, tt.target)
+			if code != http.StatusNotFound {
+				t.Errorf("got %d, want 404", code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got suggestions %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoCorrect(t *testing.T) {
+	h, err := YAMLHandler([]byte(suggestEntries), suggestFallback, WithAutoCorrect())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		name, host, target string
+		location           string
+		suggestions        []string
+	}{
+		{"one closest", "example.com", "/gihub", "https://github.com", nil},
+		{"closer than the rest", "example.com", "/dott", "https://example.com/dot", nil},
+		{"host entry", "go.example.com", "/gihub", "https://github.com/golang", nil},
+		{"host table only", "go.example.com", "/bloggs", "https://go.example.com/blog", nil},
+		// equally close paths are only suggested
+		{"ambiguous", "example.com", "/dots", "", []string{"/docs", "/dogs", "/dot"}},
+		{"ambiguous across tables", "go.example.com", "/blox", "", []string{"/blog", "/blob"}},
+		{"too far", "example.com", "/gth", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, location, suggestions := suggestionsOf(h, tt.host, tt.target)
+			if tt.location == "" {
+				if code != http.StatusNotFound || !reflect.DeepEqual(suggestions, tt.suggestions) {
+					t.Errorf("got %d with suggestions %q, want 404 with %q", code, suggestions, tt.suggestions)
+				}
+				return
+			}
+			if code != http.StatusFound || location != tt.location {
+				t.Errorf("got %d to %q, want 302 to %q", code, location, tt.location)
+			}
+		})
+	}
+
+	// without WithAutoCorrect nothing is corrected
+	h, err = YAMLHandler([]byte(suggestEntries), urlshorttest.Fallback(), WithSuggestions())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertFallback(t, h, "/gihub")
+}
+
+func TestBoundedLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+		ok    bool
+	}{
+		{"/github", "/github", 2, 0, true},
+		{"/github", "/gihub", 2, 1, true},
+		{"/github", "/gihtub", 2, 2, true},
+		{"/github", "/gth", 2, 0, false},
+		{"/a", "/abcd", 2, 0, false},
+		{"", "ab", 2, 2, true},
+		{"abc", "xyz", 2, 0, false},
+		{"abc", "xyz", 3, 3, true},
+	}
+	for _, tt := range tests {
+		got, ok := boundedLevenshtein(tt.a, tt.b, tt.limit)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("boundedLevenshtein(%q, %q, %d) = %d, %v, want %d, %v", tt.a, tt.b, tt.limit, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// the functions every action of a destination template ends in,
+// picked by where in the url the action is
+const (
+	templatePathEscape  = "urlshort_path_escape"
+	templateQueryEscape = "urlshort_query_escape"
+)
+
+var templateFuncs = template.FuncMap{
+	templatePathEscape:  func(v any) string { return url.PathEscape(fmt.Sprint(v)) },
+	templateQueryEscape: func(v any) string { return url.QueryEscape(fmt.Sprint(v)) },
+}
+
+// DestinationData is what the url of an entry with template: true
+// is executed against:
+//
+//	url: https://support.example.com/new?subject=Issue+from+{{.Header.Get "X-App"}}
+//	url: https://search.example.com/{{.Query.Get "q"}}
+//
+// Indexing a map with a key it doesn't have, like {{.Query.q}} for
+// a request without q, fails the template, the getters return ""
+// instead.
+type DestinationData struct {
+	// Path is the request path
+	Path string
+	// Query is the parsed query of the request, {{.Query.Get "q"}}
+	Query url.Values
+	// Header holds the request headers, {{.Header.Get "X-App"}}
+	Header http.Header
+	// Host is the host the request was sent to
+	Host string
+}
+
+// checkTemplate compiles the url of a template entry. The template
+// must start with a fixed scheme and host, so requests can't choose
+// where they are sent.
+func checkTemplate(pu *pathUrl) error {
+	if !pu.Template || pu.tmpl != nil {
+		return nil
+	}
+	switch {
+	case pu.Regex || isParamPath(pu.Path):
+		return fmt.Errorf("urlshort: %s: template can't be combined with regex or :name parameters", pu.Path)
+	case len(pu.URLs) > 0 || len(pu.Geo) > 0 || len(pu.Lang) > 0 || len(pu.Device) > 0:
+		return fmt.Errorf("urlshort: %s: template only works with a single url", pu.Path)
+	case pu.Mode != "" && pu.Mode != modeRedirect && pu.Mode != modeInterstitial:
+		return fmt.Errorf("urlshort: %s: template only works for mode redirect and interstitial, not %s", pu.Path, pu.Mode)
+	}
+	if !fixedHost(pu.URL) {
+		return fmt.Errorf("urlshort: %s: template url %q must start with a fixed scheme and host, like https://example.com/", pu.Path, pu.URL)
+	}
+
+	// a key missing from a map would be written as <nil>
+	tmpl, err := template.New(pu.Path).Option("missingkey=error").Funcs(templateFuncs).Parse(pu.URL)
+	if err != nil {
+		return fmt.Errorf("urlshort: %s: invalid template: %v", pu.Path, err)
+	}
+	if len(tmpl.Templates()) > 1 {
+		// their actions would be written unescaped
+		return fmt.Errorf("urlshort: %s: template can't define templates", pu.Path)
+	}
+	escapeActions(tmpl.Tree.Root, false)
+	pu.tmpl = tmpl
+	return nil
+}
+
+// fixedHost reports whether the text before the first action of
+// the template dest holds an absolute URL up to the end of its host
+func fixedHost(dest string) bool {
+	prefix, _, _ := strings.Cut(dest, "{{")
+	scheme, rest, ok := strings.Cut(prefix, "://")
+	if !ok || scheme == "" {
+		return false
+	}
+	end := strings.IndexAny(rest, "/?#")
+	return end > 0
+}
+
+// escapeActions makes every action in list that writes something
+// escape its output, with QueryEscape after a ? and PathEscape
+// before it, like substituteParams does for parameter values. In
+// branches the query starts where it starts in any of them. It
+// returns whether the query started.
+func escapeActions(list *parse.ListNode, inQuery bool) bool {
+	if list == nil {
+		return inQuery
+	}
+	for _, node := range list.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			// the fragment counts as a path again up to a ?
+			if i := strings.LastIndexAny(string(n.Text), "?#"); i >= 0 {
+				inQuery = n.Text[i] == '?'
+			}
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 {
+				// assignments don't write anything
+				continue
+			}
+			name := templatePathEscape
+			if inQuery {
+				name = templateQueryEscape
+			}
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(name).SetPos(n.Pos)},
+			})
+		case *parse.IfNode:
+			inQuery = escapeBranches(&n.BranchNode, inQuery)
+		case *parse.RangeNode:
+			inQuery = escapeBranches(&n.BranchNode, inQuery)
+		case *parse.WithNode:
+			inQuery = escapeBranches(&n.BranchNode, inQuery)
+		}
+	}
+	return inQuery
+}
+
+// escapeBranches is escapeActions for both lists of an if, range
+// or with
+func escapeBranches(b *parse.BranchNode, inQuery bool) bool {
+	list := escapeActions(b.List, inQuery)
+	elseList := escapeActions(b.ElseList, inQuery)
+	return list || elseList
+}
+
+// templateDestination executes the template of pu for r
+func templateDestination(r *http.Request, pu pathUrl) (string, error) {
+	data := DestinationData{Path: r.URL.Path, Query: r.URL.Query(), Header: r.Header, Host: r.Host}
+	var b strings.Builder
+	if err := pu.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
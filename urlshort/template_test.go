@@ -0,0 +1,97 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const templateEntries = `
+- path: /search
+  url: https://search.example.com/{{.Query.Get "q"}}
+  template: true
+- path: /support
+  url: https://support.example.com/new?subject=Issue+from+{{.Header.Get "X-App"}}
+  template: true
+- path: /back
+  url: https://auth.example.com/login?next={{.Host}}
+  template: true
+- path: /docs
+  url: https://docs.example.com/{{if .Query.Get "lang"}}{{.Query.Get "lang"}}{{else}}en{{end}}/start
+  template: true
+- path: /broken
+  url: https://example.com/{{.Cookie}}
+  template: true
+- path: /missing-key
+  url: https://example.com/{{.Header.Missing}}
+  template: true
+- path: /third
+  url: https://example.com/{{index .Query.id 2}}
+  template: true
+`
+
+func TestTemplateDestinations(t *testing.T) {
+	h, err := YAMLHandler([]byte(templateEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	tests := []struct {
+		path   string
+		header http.Header
+		want   string
+	}{
+		// values are escaped for where in the url they end up
+		{"/search?q=golang", nil, "https://search.example.com/golang"},
+		{"/search?q=go+lang", nil, "https://search.example.com/go%20lang"},
+		{"/search?q=a/../b", nil, "https://search.example.com/a%2F..%2Fb"},
+		{"/search", nil, "https://search.example.com/"},
+		{"/support", http.Header{"X-App": {"Mail"}}, "https://support.example.com/new?subject=Issue+from+Mail"},
+		{"/support", http.Header{"X-App": {"Mail & Co"}}, "https://support.example.com/new?subject=Issue+from+Mail+%26+Co"},
+		{"/support", http.Header{"X-App": {"x&admin=1"}}, "https://support.example.com/new?subject=Issue+from+x%26admin%3D1"},
+		{"/support", nil, "https://support.example.com/new?subject=Issue+from+"},
+		{"http://go.example.com/back", nil, "https://auth.example.com/login?next=go.example.com"},
+		{"/docs?lang=de", nil, "https://docs.example.com/de/start"},
+		{"/docs", nil, "https://docs.example.com/en/start"},
+		{"/third?id=1&id=2&id=3", nil, "https://example.com/3"},
+	}
+	for _, tt := range tests {
+		res := requestFrom(h, tt.path, "198.51.100.1:5000", tt.header)
+		if res.Code != http.StatusFound || res.Header().Get("Location") != tt.want {
+			t.Errorf("GET %s with %v: got %d to %q, want 302 to %q", tt.path, tt.header, res.Code, res.Header().Get("Location"), tt.want)
+		}
+	}
+
+	// failing to execute falls back instead of a 500
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/broken"},
+		// a header map without the key is an error too, not <nil>
+		{Path: "/missing-key"},
+		{Path: "/third?id=1"},
+		{Path: "/missing"},
+	})
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"syntax", "- path: /a\n  url: 'https://example.com/{{.Query.Get'\n  template: true\n", "/a: invalid template"},
+		{"unknown function", "- path: /a\n  url: 'https://example.com/{{shell \"ls\"}}'\n  template: true\n", "/a: invalid template"},
+		{"host from the request", "- path: /a\n  url: 'https://{{.Host}}/a'\n  template: true\n",
+			`template url "https://{{.Host}}/a" must start with a fixed scheme and host`},
+		{"scheme from the request", "- path: /a\n  url: '{{.Query.Get \"u\"}}'\n  template: true\n", "must start with a fixed scheme and host"},
+		{"defines templates", "- path: /a\n  url: 'https://example.com/{{define \"x\"}}y{{end}}'\n  template: true\n", "template can't define templates"},
+		{"parameters", "- path: /a/:id\n  url: 'https://example.com/:id/{{.Host}}'\n  template: true\n", "template can't be combined with regex or :name parameters"},
+		{"several urls", "- path: /a\n  urls: [{url: https://a.example.com, weight: 1}, {url: https://b.example.com, weight: 1}]\n  template: true\n", "template only works with a single url"},
+		{"rewrite", "- path: /a\n  url: '/b/{{.Host}}'\n  mode: rewrite\n  template: true\n", "template only works for mode redirect and interstitial, not rewrite"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.data), urlshorttest.Fallback(), WithRelativeDestinations())
+			wantError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// tokenParam is the query parameter carrying the token of a
+// protected entry
+const tokenParam = "t"
+
+// WithSharedToken protects every entry with token, requests have to
+// pass it as ?t=token. Entries with a token of their own need that
+// one instead. Useful for fully internal deployments.
+func WithSharedToken(token string) Option {
+	return func(c *config) {
+		c.token = token
+	}
+}
+
+// entryToken returns the token requests for pu have to carry, ""
+// if the entry isn't protected
+func entryToken(pu pathUrl, cfg *config) string {
+	if pu.Token != "" {
+		return pu.Token
+	}
+	return cfg.token
+}
+
+// checkToken reports whether r carries the token pu needs. The
+// returned request is r without the token either way, so it ends up
+// neither in the destination nor in anything logging the request
+// later.
+func checkToken(r *http.Request, pu pathUrl, cfg *config) (*http.Request, bool) {
+	token := entryToken(pu, cfg)
+	if token == "" {
+		return r, true
+	}
+
+	query := r.URL.Query()
+	got := query.Get(tokenParam)
+	ok := subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+
+	query.Del(tokenParam)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	stripped := r.Clone(r.Context())
+	stripped.URL = &u
+	stripped.RequestURI = u.RequestURI()
+	return keepQuery(stripped, r), ok
+}
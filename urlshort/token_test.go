@@ -0,0 +1,71 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const tokenEntries = `
+- path: /secret
+  url: https://example.com/secret
+  token: hunter2
+- path: /open
+  url: https://example.com/open
+`
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		cases []urlshorttest.Case
+	}{
+		{"entry token", nil, []urlshorttest.Case{
+			{Path: "/secret?t=hunter2", Location: "https://example.com/secret", Code: http.StatusFound},
+			{Path: "/secret"},
+			{Path: "/secret?t=hunter3"},
+			{Path: "/secret?t=hunter"},
+			{Path: "/secret?token=hunter2"},
+			{Path: "/open", Location: "https://example.com/open", Code: http.StatusFound},
+		}},
+		{"shared token", []Option{WithSharedToken("s3cret")}, []urlshorttest.Case{
+			{Path: "/open?t=s3cret", Location: "https://example.com/open", Code: http.StatusFound},
+			{Path: "/open"},
+			// the entry token replaces the shared one
+			{Path: "/secret?t=hunter2", Location: "https://example.com/secret", Code: http.StatusFound},
+			{Path: "/secret?t=s3cret"},
+		}},
+		// the token isn't passed on, the rest of the query is
+		{"query forwarding", []Option{WithQueryForwarding()}, []urlshorttest.Case{
+			{Path: "/secret?t=hunter2&utm_source=mail", Location: "https://example.com/secret?utm_source=mail", Code: http.StatusFound},
+			{Path: "/open?t=x&q=1", Location: "https://example.com/open?q=1&t=x", Code: http.StatusFound},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := YAMLHandler([]byte(tokenEntries), urlshorttest.Fallback(), tt.opts...)
+			if err != nil {
+				t.Fatalf("YAMLHandler: %v", err)
+			}
+			urlshorttest.TableTest(t, h, tt.cases)
+		})
+	}
+}
+
+func TestTokenStripped(t *testing.T) {
+	// the fallback of a wrong token never sees it
+	var query string
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery + " " + r.RequestURI
+		http.NotFound(w, r)
+	})
+	h, err := YAMLHandler([]byte(tokenEntries), fallback)
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	get(h, "/secret?t=hunter3&q=1")
+	if want := "q=1 /secret?q=1"; query != want {
+		t.Errorf("the fallback got %q, want %q", query, want)
+	}
+}
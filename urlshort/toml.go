@@ -0,0 +1,51 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/BurntSushi/toml"
+)
+
+// TOMLHandler works like YAMLHandler, but parses the mappings
+// from TOML instead.
+//
+// TOML is expected to be an array of tables in the format:
+//
+//	[[redirect]]
+//	path = "/some-path"
+//	url = "https://www.some-url.com/demo"
+//
+// An error is returned for invalid TOML and for entries with
+// an empty path or url. A document without any redirects is
+// fine, the handler then always calls the fallback.
+func TOMLHandler(tomlBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTOML, err := parseTOML(tomlBytes)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(parsedTOML, fallback, cfg)
+}
+
+// tomlDoc is the top level of a TOML mapping document
+type tomlDoc struct {
+	Redirect []pathUrl `toml:"redirect"`
+}
+
+func parseTOML(data []byte) ([]pathUrl, error) {
+	var doc tomlDoc
+	if _, err := toml.Decode(string(data), &doc); err != nil {
+		return nil, fmt.Errorf("urlshort: invalid TOML: %v", err)
+	}
+
+	if err := checkEntries(doc.Redirect); err != nil {
+		return nil, err
+	}
+
+	return doc.Redirect, nil
+}
@@ -0,0 +1,66 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestTOMLHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		cases []urlshorttest.Case
+	}{
+		{
+			name: "mappings",
+			data: `
+[[redirect]]
+path = "/gh"
+url = "https://github.com"
+
+[[redirect]]
+path = "/go"
+url = "https://go.dev"
+`,
+			cases: []urlshorttest.Case{
+				{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+				{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+				{Path: "/missing"},
+			},
+		},
+		{
+			name:  "no redirects",
+			data:  `# nothing yet`,
+			cases: []urlshorttest.Case{{Path: "/gh"}, {Path: "/"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := TOMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			if err != nil {
+				t.Fatalf("TOMLHandler: %v", err)
+			}
+			urlshorttest.TableTest(t, h, tt.cases)
+		})
+	}
+}
+
+func TestTOMLHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"invalid", "[[redirect]\npath = /gh", "invalid TOML"},
+		{"empty path", "[[redirect]]\npath = \"\"\nurl = \"https://github.com\"", "missing a path"},
+		{"empty url", "[[redirect]]\npath = \"/gh\"\nurl = \"\"", "(/gh) is missing a url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TOMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
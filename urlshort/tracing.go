@@ -0,0 +1,94 @@
+package urlshort
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans
+const tracerName = "github.com/NilsKaden/gophercises/urlshort"
+
+// WithTracing creates an OpenTelemetry span from tp for every
+// request, continuing the trace of the inbound request as the
+// global propagator extracts it (see otel.SetTextMapPropagator).
+// The span tells whether the request matched, the configured path
+// that did, the backend and the redirect status. Handlers backed by
+// a store add a child span around every lookup that isn't
+// answered from the cache, so slow calls show up.
+//
+// Without it nothing is traced and requests don't pay for it.
+func WithTracing(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// startSpan starts the span of r, which the returned func ends. The
+// requestInfo of r, attached if there is none, fills in the
+// attributes. backend names where the entries are stored.
+func (c *config) startSpan(r *http.Request, backend string) (*http.Request, func()) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := c.tracer.Start(ctx, "urlshort.request",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("urlshort.backend", backend),
+		))
+	info, ok := ctx.Value(requestInfoKey).(*requestInfo)
+	if !ok {
+		info = &requestInfo{}
+		ctx = context.WithValue(ctx, requestInfoKey, info)
+	}
+
+	return r.WithContext(ctx), func() {
+		span.SetAttributes(attribute.Bool("urlshort.matched", info.matched))
+		if info.matched {
+			span.SetAttributes(
+				attribute.String("urlshort.route", info.path),
+				attribute.Int("http.response.status_code", info.status),
+			)
+		}
+		if info.err != nil {
+			span.SetStatus(codes.Error, "lookup failed")
+		}
+		span.End()
+	}
+}
+
+// startLookupSpan starts the span around a lookup in backend, the
+// returned func ends it with the result
+func (c *config) startLookupSpan(ctx context.Context, backend string) (context.Context, func(found bool, err error)) {
+	ctx, span := c.tracer.Start(ctx, "urlshort.lookup", trace.WithAttributes(attribute.String("urlshort.backend", backend)))
+	return ctx, func(found bool, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Bool("urlshort.found", found))
+		}
+		span.End()
+	}
+}
+
+// resolverBackend names the backend of res for the spans
+func resolverBackend(res Resolver) string {
+	switch res.(type) {
+	case *redisResolver:
+		return "redis"
+	case *sqlResolver:
+		return "sql"
+	case *boltResolver:
+		return "bolt"
+	case *tableResolver:
+		return "memory"
+	default:
+		return "resolver"
+	}
+}
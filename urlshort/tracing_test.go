@@ -0,0 +1,250 @@
+package urlshort
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTracing returns a provider that records every ended span
+func newTracing(t *testing.T) (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { tp.Shutdown(t.Context()) })
+	return tp, exporter
+}
+
+// spanAttributes returns the attributes of span by key
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracing(t *testing.T) {
+	tp, exporter := newTracing(t)
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com", "/u/:name": "https://github.com/:name"}),
+		urlshorttest.Fallback(), WithTracing(tp), WithStatus(http.StatusMovedPermanently))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		attrs []attribute.KeyValue
+	}{
+		{"/gh", []attribute.KeyValue{
+			attribute.Bool("urlshort.matched", true),
+			attribute.String("urlshort.route", "/gh"),
+			attribute.Int("http.response.status_code", http.StatusMovedPermanently),
+		}},
+		// the route is the configured pattern, not the request path
+		{"/u/nils", []attribute.KeyValue{
+			attribute.Bool("urlshort.matched", true),
+			attribute.String("urlshort.route", "/u/:name"),
+		}},
+		{"/missing", []attribute.KeyValue{attribute.Bool("urlshort.matched", false)}},
+	}
+	for _, tt := range tests {
+		exporter.Reset()
+		get(h, tt.path)
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("GET %s: got %d spans, want 1", tt.path, len(spans))
+		}
+		span := spans[0]
+		if span.Name != "urlshort.request" || span.SpanKind.String() != "server" {
+			t.Errorf("GET %s: got span %q of kind %v", tt.path, span.Name, span.SpanKind)
+		}
+		attrs := spanAttributes(span)
+		want := append(tt.attrs,
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("url.path", tt.path),
+			attribute.String("urlshort.backend", "map"),
+		)
+		for _, kv := range want {
+			if got, ok := attrs[kv.Key]; !ok || got != kv.Value {
+				t.Errorf("GET %s: %s is %v, want %v", tt.path, kv.Key, got.Emit(), kv.Value.Emit())
+			}
+		}
+		if route, ok := attrs["urlshort.route"]; ok && !attrs["urlshort.matched"].AsBool() {
+			t.Errorf("GET %s: a miss has route %v", tt.path, route.Emit())
+		}
+		if span.Status.Code != codes.Unset {
+			t.Errorf("GET %s: status %v", tt.path, span.Status)
+		}
+	}
+}
+
+func TestTracingPropagation(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+
+	tp, exporter := newTracing(t)
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil, WithTracing(tp))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/gh", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	// the span continues the trace of the edge
+	if got := spans[0].SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("got trace %s", got)
+	}
+	if got := spans[0].Parent.SpanID().String(); got != "00f067aa0ba902b7" || !spans[0].Parent.IsRemote() {
+		t.Errorf("got parent %s, remote %v", got, spans[0].Parent.IsRemote())
+	}
+}
+
+func TestTracingLookups(t *testing.T) {
+	tp, exporter := newTracing(t)
+	res := &flakyResolver{}
+	h := ResolverHandler(res, urlshorttest.Fallback(), WithTracing(tp), WithCache(time.Minute))
+
+	tests := []struct {
+		name    string
+		failing bool
+		path    string
+		// lookup is the found attribute of the lookup span, "" if there
+		// is none, "error" if it failed
+		lookup  string
+		matched bool
+	}{
+		{"found", false, "/gh", "true", true},
+		{"cached", false, "/gh", "", true},
+		{"not found", false, "/missing", "false", false},
+		{"failing", true, "/other", "error", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			res.failing.Store(tt.failing)
+			get(h, tt.path)
+
+			var request, lookup *tracetest.SpanStub
+			spans := exporter.GetSpans()
+			for i := range spans {
+				switch spans[i].Name {
+				case "urlshort.request":
+					request = &spans[i]
+				case "urlshort.lookup":
+					lookup = &spans[i]
+				default:
+					t.Errorf("unexpected span %q", spans[i].Name)
+				}
+			}
+			if request == nil {
+				t.Fatalf("no request span among %d", len(spans))
+			}
+			attrs := spanAttributes(*request)
+			if attrs["urlshort.matched"].AsBool() != tt.matched {
+				t.Errorf("matched: got %v, want %v", attrs["urlshort.matched"].AsBool(), tt.matched)
+			}
+			if tt.lookup == "" {
+				if lookup != nil {
+					t.Error("a cached result got a lookup span")
+				}
+				return
+			}
+			if lookup == nil {
+				t.Fatal("no lookup span")
+			}
+			if lookup.Parent.SpanID() != request.SpanContext.SpanID() {
+				t.Error("the lookup span isn't a child of the request span")
+			}
+			lookupAttrs := spanAttributes(*lookup)
+			if got := lookupAttrs["urlshort.backend"].AsString(); got != "resolver" {
+				t.Errorf("backend: got %q", got)
+			}
+			if tt.lookup == "error" {
+				if lookup.Status.Code != codes.Error || lookup.Status.Description != errStoreDown.Error() || len(lookup.Events) == 0 {
+					t.Errorf("the failed lookup: status %v, events %v", lookup.Status, lookup.Events)
+				}
+				if request.Status.Code != codes.Error {
+					t.Errorf("the request of a failed lookup: status %v", request.Status)
+				}
+				return
+			}
+			if got := lookupAttrs["urlshort.found"].Emit(); got != tt.lookup {
+				t.Errorf("found: got %s, want %s", got, tt.lookup)
+			}
+		})
+	}
+}
+
+func TestTracingStore(t *testing.T) {
+	tp, exporter := newTracing(t)
+	store := NewMutableStore()
+	store.Set("/gh", "https://github.com")
+	get(NewMutableHandler(store, urlshorttest.Fallback(), WithTracing(tp)), "/gh")
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spanAttributes(spans[0])["urlshort.backend"].AsString() != "mutable" {
+		t.Errorf("got spans %+v", spans)
+	}
+}
+
+func TestNoTracing(t *testing.T) {
+	// a global provider isn't used without WithTracing
+	tp, exporter := newTracing(t)
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	get(ResolverHandler(&flakyResolver{}, nil), "/gh")
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("got %d spans without WithTracing", len(spans))
+	}
+}
+
+// BenchmarkTracing compares a handler without WithTracing to one that
+// records every span
+func BenchmarkTracing(b *testing.B) {
+	paths := map[string]string{"/gh": "https://github.com"}
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer tp.Shutdown(b.Context())
+
+	for _, bm := range []struct {
+		name string
+		opts []Option
+	}{
+		{"off", nil},
+		{"on", []Option{WithTracing(tp)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			h, err := New(FromMap(paths), nil, bm.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/gh", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), req)
+				exporter.Reset()
+			}
+		})
+	}
+}
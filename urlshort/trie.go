@@ -0,0 +1,76 @@
+package urlshort
+
+import "strings"
+
+// prefixTrie holds the wildcard entries keyed by path segment, so
+// the longest matching prefix is found in a single walk over the
+// request path instead of trying every wildcard in turn.
+type prefixTrie struct {
+	root trieNode
+	size int
+}
+
+// trieNode is one path segment. wildcard is set when an entry
+// like /docs/* ends at this node.
+type trieNode struct {
+	children map[string]*trieNode
+	wildcard *pathUrl
+}
+
+// insert adds a wildcard entry, prefix keeps the trailing slash
+// like "/docs/". Prefixes that don't start with a slash can never
+// match a request path and are left out.
+func (t *prefixTrie) insert(prefix string, pu pathUrl) {
+	if !strings.HasPrefix(prefix, "/") {
+		return
+	}
+	node := &t.root
+	rest := prefix[1:]
+	for rest != "" {
+		seg, after, _ := strings.Cut(rest, "/")
+		child := node.children[seg]
+		if child == nil {
+			if node.children == nil {
+				node.children = make(map[string]*trieNode)
+			}
+			child = &trieNode{}
+			node.children[seg] = child
+		}
+		node, rest = child, after
+	}
+	if node.wildcard == nil {
+		t.size++
+	}
+	node.wildcard = &pu
+}
+
+// longest returns the entry with the longest prefix of key. A
+// wildcard only matches below its node, so /docs/* matches
+// /docs/ and /docs/x but neither /docs nor /docsearch.
+func (t *prefixTrie) longest(key string) (pathUrl, bool) {
+	if t.size == 0 || !strings.HasPrefix(key, "/") {
+		return pathUrl{}, false
+	}
+	best := t.root.wildcard
+	node := &t.root
+	rest := key[1:]
+	for {
+		seg, after, more := strings.Cut(rest, "/")
+		if !more {
+			// the last segment has nothing below it
+			break
+		}
+		node = node.children[seg]
+		if node == nil {
+			break
+		}
+		if node.wildcard != nil {
+			best = node.wildcard
+		}
+		rest = after
+	}
+	if best == nil {
+		return pathUrl{}, false
+	}
+	return *best, true
+}
@@ -0,0 +1,113 @@
+package urlshort
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestPrefixTrie(t *testing.T) {
+	var trie prefixTrie
+	for _, prefix := range []string{"/docs/", "/docs/api/", "/docs/api/v2/", "/blog/2024/", "no-slash/"} {
+		trie.insert(prefix, pathUrl{Path: prefix + "*", URL: "https://example.com" + prefix})
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"/docs/", "/docs/"},
+		{"/docs/x", "/docs/"},
+		{"/docs/api", "/docs/"},
+		{"/docs/api/", "/docs/api/"},
+		{"/docs/api/v1/users", "/docs/api/"},
+		{"/docs/api/v2/users", "/docs/api/v2/"},
+		{"/blog/2024/post", "/blog/2024/"},
+		{"/docs", ""},
+		{"/docsearch", ""},
+		{"/blog/2023/post", ""},
+		{"/blog/", ""},
+		{"no-slash/x", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		pu, ok := trie.longest(tt.key)
+		got := strings.TrimPrefix(pu.URL, "https://example.com")
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("longest(%q) = %q, %v, want %q", tt.key, got, ok, tt.want)
+		}
+	}
+
+	// a root wildcard matches everything else
+	trie.insert("/", pathUrl{Path: "/*", URL: "https://example.com/"})
+	if pu, ok := trie.longest("/docs"); !ok || pu.Path != "/*" {
+		t.Errorf("longest(/docs) with a root wildcard = %q, %v", pu.Path, ok)
+	}
+	if pu, ok := trie.longest("/docs/api/x"); !ok || pu.Path != "/docs/api/*" {
+		t.Errorf("longest(/docs/api/x) with a root wildcard = %q, %v", pu.Path, ok)
+	}
+}
+
+// wildcardPrefixes returns n prefixes of a deep hierarchy, like the
+// paths of a migrated CMS
+func wildcardPrefixes(n int) []string {
+	prefixes := make([]string, n)
+	for i := range prefixes {
+		prefixes[i] = fmt.Sprintf("/section%d/topic%d/page%d/", i%100, i%1000, i)
+	}
+	return prefixes
+}
+
+// scanLongest finds the longest prefix of key by trying every prefix,
+// the lookup the trie replaced
+func scanLongest(prefixes []string, key string) (string, bool) {
+	best := ""
+	for _, p := range prefixes {
+		if len(p) > len(best) && strings.HasPrefix(key, p) {
+			best = p
+		}
+	}
+	return best, best != ""
+}
+
+func BenchmarkWildcardLookup(b *testing.B) {
+	for _, n := range []int{1_000, 50_000, 500_000} {
+		prefixes := wildcardPrefixes(n)
+		var trie prefixTrie
+		for _, p := range prefixes {
+			trie.insert(p, pathUrl{Path: p + "*", URL: "https://example.com"})
+		}
+		hit := prefixes[n/2] + "sub/page"
+		miss := "/section1/unknown/page"
+
+		b.Run(fmt.Sprintf("trie/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				trie.longest(hit)
+				trie.longest(miss)
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				scanLongest(prefixes, hit)
+				scanLongest(prefixes, miss)
+			}
+		})
+	}
+}
+
+func BenchmarkMapHandlerExact(b *testing.B) {
+	for _, n := range []int{1_000, 50_000} {
+		paths := make(map[string]string, n)
+		for i := 0; i < n; i++ {
+			paths[fmt.Sprintf("/p%d", i)] = "https://example.com"
+		}
+		h := MapHandler(paths, urlshorttest.Fallback())
+		target := fmt.Sprintf("/p%d", n/2)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				get(h, target)
+			}
+		})
+	}
+}
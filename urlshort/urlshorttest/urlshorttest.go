@@ -0,0 +1,77 @@
+// Package urlshorttest has helpers for testing the redirects of an
+// urlshort handler, or of anything else that redirects.
+//
+//	h := urlshort.MapHandler(paths, urlshorttest.Fallback())
+//	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+//	urlshorttest.AssertFallback(t, h, "/missing")
+//
+// The helpers take a testing.TB, so they work in benchmarks too.
+package urlshorttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fallbackHeader marks the responses of the Fallback handler
+const fallbackHeader = "X-Urlshorttest-Fallback"
+
+// Fallback returns a handler to build the handler under test with,
+// so AssertFallback can tell that a request ended up there. It
+// answers with a 404.
+func Fallback() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(fallbackHeader, "1")
+		http.NotFound(w, r)
+	})
+}
+
+// Case is one request of a TableTest. An empty Location means the
+// request should reach the Fallback handler, Code is then ignored.
+type Case struct {
+	Path     string
+	Location string
+	Code     int
+}
+
+// AssertRedirect fails t unless a GET of path redirects to location
+// with code. path may be an absolute URL to set the Host of the
+// request.
+func AssertRedirect(t testing.TB, h http.Handler, path, location string, code int) {
+	t.Helper()
+	res := serve(h, path)
+	got := res.Header().Get("Location")
+	if res.Code != code || got != location {
+		t.Errorf("GET %s: got %d to %q, want %d to %q", path, res.Code, got, code, location)
+	}
+}
+
+// AssertFallback fails t unless a GET of path is passed to the
+// Fallback handler the handler under test was built with
+func AssertFallback(t testing.TB, h http.Handler, path string) {
+	t.Helper()
+	res := serve(h, path)
+	if res.Header().Get(fallbackHeader) == "" {
+		t.Errorf("GET %s: got %d to %q, want the fallback", path, res.Code, res.Header().Get("Location"))
+	}
+}
+
+// TableTest runs AssertRedirect or AssertFallback for every case
+func TableTest(t testing.TB, h http.Handler, cases []Case) {
+	t.Helper()
+	for _, c := range cases {
+		if c.Location == "" {
+			AssertFallback(t, h, c.Path)
+			continue
+		}
+		AssertRedirect(t, h, c.Path, c.Location, c.Code)
+	}
+}
+
+// serve records the response of h to a GET of path
+func serve(h http.Handler, path string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+	return res
+}
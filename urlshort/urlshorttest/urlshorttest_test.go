@@ -0,0 +1,120 @@
+package urlshorttest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recorder is a testing.TB that keeps the failures reported to it
+// instead of failing the test
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// testHandler redirects /gh and sends everything else to Fallback,
+// except /404 which gets a 404 of its own
+func testHandler() http.Handler {
+	fallback := Fallback()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gh":
+			if r.Host == "links.example.org" {
+				http.Redirect(w, r, "https://github.com/links", http.StatusMovedPermanently)
+				return
+			}
+			http.Redirect(w, r, "https://github.com", http.StatusFound)
+		case "/404":
+			http.NotFound(w, r)
+		default:
+			fallback.ServeHTTP(w, r)
+		}
+	})
+}
+
+func TestAssertRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		location string
+		code     int
+		want     string
+	}{
+		{"match", "/gh", "https://github.com", http.StatusFound, ""},
+		{"host", "http://links.example.org/gh", "https://github.com/links", http.StatusMovedPermanently, ""},
+		{"wrong code", "/gh", "https://github.com", http.StatusMovedPermanently,
+			`GET /gh: got 302 to "https://github.com", want 301 to "https://github.com"`},
+		{"wrong location", "/gh", "https://gitlab.com", http.StatusFound,
+			`GET /gh: got 302 to "https://github.com", want 302 to "https://gitlab.com"`},
+		{"no redirect", "/missing", "https://github.com", http.StatusFound,
+			`GET /missing: got 404 to "", want 302 to "https://github.com"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertRedirect(r, testHandler(), tt.path, tt.location, tt.code)
+			checkErrors(t, r, tt.want)
+		})
+	}
+}
+
+func TestAssertFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"fallback", "/missing", ""},
+		{"redirect", "/gh", `GET /gh: got 302 to "https://github.com", want the fallback`},
+		// a 404 alone isn't the fallback
+		{"other 404", "/404", `GET /404: got 404 to "", want the fallback`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			AssertFallback(r, testHandler(), tt.path)
+			checkErrors(t, r, tt.want)
+		})
+	}
+}
+
+func TestTableTest(t *testing.T) {
+	r := &recorder{TB: t}
+	TableTest(r, testHandler(), []Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/missing"},
+		{Path: "/missing", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/gh"},
+		// the code of fallback cases is ignored
+		{Path: "/other", Code: http.StatusFound},
+	})
+	checkErrors(t, r,
+		`GET /missing: got 404 to "", want 302 to "https://github.com"`,
+		`GET /gh: got 302 to "https://github.com", want the fallback`)
+}
+
+func BenchmarkAssertRedirect(b *testing.B) {
+	h := testHandler()
+	for i := 0; i < b.N; i++ {
+		AssertRedirect(b, h, "/gh", "https://github.com", http.StatusFound)
+	}
+}
+
+// checkErrors fails t unless r recorded exactly the errors in want
+func checkErrors(t *testing.T, r *recorder, want ...string) {
+	t.Helper()
+	if len(want) == 1 && want[0] == "" {
+		want = nil
+	}
+	if strings.Join(r.errors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("reported:\n%s\nwant:\n%s", strings.Join(r.errors, "\n"), strings.Join(want, "\n"))
+	}
+}
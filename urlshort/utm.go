@@ -0,0 +1,64 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// utmKeys are the UTM parameters entries can set, without the utm_
+// prefix
+var utmKeys = map[string]bool{"source": true, "medium": true, "campaign": true, "term": true, "content": true}
+
+// WithUTM tags every redirect with utm_source and utm_medium, and
+// utm_campaign set to the requested path without its leading slash.
+// The utm map of an entry overrides these per parameter, an empty
+// value leaves the parameter out, so an entry can opt out of the
+// tagging. Parameters the destination already has are left alone.
+func WithUTM(source, medium string) Option {
+	return func(c *config) {
+		c.utm = map[string]string{"source": source, "medium": medium}
+	}
+}
+
+// checkUTM makes sure the utm map of pu only has known parameters
+func checkUTM(pu *pathUrl) error {
+	for key := range pu.UTM {
+		if !utmKeys[key] {
+			return fmt.Errorf("urlshort: %s: unknown utm parameter %q (use source, medium, campaign, term or content)", pu.Path, key)
+		}
+	}
+	return nil
+}
+
+// addUTM adds the UTM parameters for a redirect of path to dest
+func addUTM(dest, path string, pu pathUrl, cfg *config) string {
+	if cfg.utm == nil && len(pu.UTM) == 0 {
+		return dest
+	}
+	if pu.Mode == modeRewrite || pu.Mode == modeProxy {
+		// only redirects leave the site, there is nothing to tag
+		return dest
+	}
+
+	params := url.Values{}
+	if cfg.utm != nil {
+		campaign := strings.TrimPrefix(path, "/")
+		if campaign != "" {
+			params.Set("utm_campaign", campaign)
+		}
+	}
+	for key, value := range cfg.utm {
+		if value != "" {
+			params.Set("utm_"+key, value)
+		}
+	}
+	for key, value := range pu.UTM {
+		if value == "" {
+			params.Del("utm_" + key)
+			continue
+		}
+		params.Set("utm_"+key, value)
+	}
+	return forwardQuery(dest, params)
+}
@@ -0,0 +1,77 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const utmEntries = `
+- path: /sale
+  url: https://shop.example.com/sale
+- path: /docs/api
+  url: https://docs.example.com/api
+- path: /
+  url: https://example.com
+- path: /spring
+  url: https://shop.example.com/spring
+  utm:
+    source: mail
+    campaign: spring-2025
+    content: banner
+- path: /tagged
+  url: https://shop.example.com/?utm_source=partner&ref=1
+- path: /fragment
+  url: https://shop.example.com/p?id=7#reviews
+- path: /no-medium
+  url: https://shop.example.com/n
+  utm:
+    medium: ""
+- path: /untagged
+  url: https://shop.example.com/u
+  utm:
+    source: ""
+    medium: ""
+    campaign: ""
+`
+
+func TestUTM(t *testing.T) {
+	h, err := YAMLHandler([]byte(utmEntries), urlshorttest.Fallback(), WithUTM("newsletter", "email"))
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		// the campaign is the path
+		{Path: "/sale", Location: "https://shop.example.com/sale?utm_campaign=sale&utm_medium=email&utm_source=newsletter", Code: http.StatusFound},
+		{Path: "/docs/api", Location: "https://docs.example.com/api?utm_campaign=docs%2Fapi&utm_medium=email&utm_source=newsletter", Code: http.StatusFound},
+		{Path: "/", Location: "https://example.com?utm_medium=email&utm_source=newsletter", Code: http.StatusFound},
+		// the entry wins per parameter
+		{Path: "/spring", Location: "https://shop.example.com/spring?utm_campaign=spring-2025&utm_content=banner&utm_medium=email&utm_source=mail", Code: http.StatusFound},
+		// parameters the destination has are kept, the query and the
+		// fragment too
+		{Path: "/tagged", Location: "https://shop.example.com/?utm_source=partner&ref=1&utm_campaign=tagged&utm_medium=email", Code: http.StatusFound},
+		{Path: "/fragment", Location: "https://shop.example.com/p?id=7&utm_campaign=fragment&utm_medium=email&utm_source=newsletter#reviews", Code: http.StatusFound},
+		// empty values opt out
+		{Path: "/no-medium", Location: "https://shop.example.com/n?utm_campaign=no-medium&utm_source=newsletter", Code: http.StatusFound},
+		{Path: "/untagged", Location: "https://shop.example.com/u", Code: http.StatusFound},
+	})
+}
+
+func TestUTMPerEntry(t *testing.T) {
+	// without WithUTM only the entries with a utm map are tagged
+	h, err := YAMLHandler([]byte(utmEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/sale", Location: "https://shop.example.com/sale", Code: http.StatusFound},
+		{Path: "/spring", Location: "https://shop.example.com/spring?utm_campaign=spring-2025&utm_content=banner&utm_source=mail", Code: http.StatusFound},
+		{Path: "/untagged", Location: "https://shop.example.com/u", Code: http.StatusFound},
+	})
+}
+
+func TestUTMErrors(t *testing.T) {
+	_, err := YAMLHandler([]byte("- path: /x\n  url: https://example.com\n  utm:\n    campaing: x\n"), urlshorttest.Fallback())
+	wantError(t, err, `/x: unknown utm parameter "campaing"`)
+}
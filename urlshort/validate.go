@@ -0,0 +1,158 @@
+package urlshort
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// WithAllowedSchemes sets the URL schemes destinations may use, the
+// default is http and https. WithAllowedSchemes("https") rejects
+// every plain http destination when the handler is built.
+func WithAllowedSchemes(schemes ...string) Option {
+	return func(c *config) {
+		c.schemes = schemes
+	}
+}
+
+// WithRelativeDestinations allows destinations without a scheme and
+// host, like /internal/page, for internal rewrites. Absolute
+// destinations still have to use an allowed scheme.
+func WithRelativeDestinations() Option {
+	return func(c *config) {
+		c.allowRelative = true
+	}
+}
+
+// ValidationError lists every entry that failed validation
+type ValidationError struct {
+	Issues []ValidationIssue
+}
+
+// ValidationIssue is a single invalid entry
+type ValidationIssue struct {
+	Path   string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "urlshort: %d invalid entries", len(e.Issues))
+	for _, issue := range e.Issues {
+		fmt.Fprintf(&b, "; %s: %s", issue.Path, issue.Reason)
+	}
+	return b.String()
+}
+
+// validateEntries checks the destination of every entry and
+// collects all problems into a *ValidationError.
+func validateEntries(pathUrls []pathUrl, cfg *config) error {
+	var issues []ValidationIssue
+	for _, pu := range pathUrls {
+		if pu.CacheMaxAge > 0 && pu.Code == 0 && !permanent(cfg.status) {
+			issues = append(issues, ValidationIssue{Path: pu.Path, Reason: fmt.Sprintf("cache_max_age only works for permanent redirects, the default code %d is temporary", cfg.status)})
+		}
+		if pu.Mode == modeRewrite {
+			// rewrite destinations are paths, checked by prepareEntries
+			continue
+		}
+		if pu.URL == "" && pu.Mode == modePixel {
+			// pixel entries only pass their url on to the redirect hook
+			continue
+		}
+		if reason := destinationIssue(pu.URL, cfg); reason != "" {
+			issues = append(issues, ValidationIssue{Path: pu.Path, Reason: reason})
+		}
+		if pu.InactiveURL != "" {
+			if reason := destinationIssue(pu.InactiveURL, cfg); reason != "" {
+				issues = append(issues, ValidationIssue{Path: pu.Path, Reason: "inactive " + reason})
+			}
+		}
+		// the first weighted url is pu.URL, it was just checked
+		for i := 1; i < len(pu.URLs); i++ {
+			if reason := destinationIssue(pu.URLs[i].URL, cfg); reason != "" {
+				issues = append(issues, ValidationIssue{Path: pu.Path, Reason: reason})
+			}
+		}
+		// so is the default of the destination maps
+		for _, m := range pu.destinationMaps() {
+			for _, key := range ownKeys(*m) {
+				if reason := destinationIssue((*m)[key], cfg); reason != "" {
+					issues = append(issues, ValidationIssue{Path: pu.Path, Reason: key + " " + reason})
+				}
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// destinationIssue returns why dest is not an acceptable
+// destination, or "" if it is.
+func destinationIssue(dest string, cfg *config) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return fmt.Sprintf("invalid url %q: %v", dest, err)
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		if cfg.allowRelative {
+			return ""
+		}
+		if looksLikeHost(dest) {
+			return fmt.Sprintf("url %q is missing a scheme, like https://%s (see WithDefaultScheme)", dest, dest)
+		}
+		return fmt.Sprintf("url %q is not absolute", dest)
+	}
+
+	if !schemeAllowed(u.Scheme, cfg) {
+		return fmt.Sprintf("url %q must use one of the schemes %s", dest, strings.Join(cfg.schemes, ", "))
+	}
+	if u.Host == "" {
+		return fmt.Sprintf("url %q is missing a host", dest)
+	}
+	return ""
+}
+
+// schemeAllowed reports whether destinations may use scheme
+func schemeAllowed(scheme string, cfg *config) bool {
+	for _, allowed := range cfg.schemes {
+		if strings.EqualFold(scheme, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRoutes validates parsed entries and builds their route table
+func buildRoutes(pathUrls []pathUrl, cfg *config) (*routeTable, error) {
+	pathUrls, err := punycodeEntries(schemeEntries(pathUrls, cfg), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEntries(pathUrls, cfg); err != nil {
+		return nil, err
+	}
+	routes, err := buildMap(pathUrls, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := routes.checkLoops(cfg); err != nil {
+		return nil, err
+	}
+	if err := routes.checkDefaultDestination(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.flattenDepth > 0 {
+		flattened, changed, err := routes.flatten(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			return buildMap(flattened, cfg)
+		}
+	}
+	return routes, nil
+}
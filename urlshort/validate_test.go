@@ -0,0 +1,93 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestValidationError(t *testing.T) {
+	yamlData := []byte(`
+- path: /ok
+  url: https://github.com
+- path: /relative
+  url: /somewhere
+- path: /ftp
+  url: ftp://files.example.com
+- path: /hostless
+  url: https://
+- path: /weighted
+  urls:
+    - url: https://a.example.com
+      weight: 1
+    - url: github.com
+      weight: 1
+`)
+	jsonData := []byte(`[
+  {"path": "/ok", "url": "https://github.com"},
+  {"path": "/relative", "url": "/somewhere"},
+  {"path": "/ftp", "url": "ftp://files.example.com"},
+  {"path": "/hostless", "url": "https://"},
+  {"path": "/weighted", "urls": [{"url": "https://a.example.com", "weight": 1}, {"url": "github.com", "weight": 1}]}
+]`)
+	// every invalid entry is reported, in order
+	want := []ValidationIssue{
+		{Path: "/relative", Reason: `url "/somewhere" is not absolute`},
+		{Path: "/ftp", Reason: `url "ftp://files.example.com" must use one of the schemes http, https`},
+		{Path: "/hostless", Reason: `url "https://" is missing a host`},
+		{Path: "/weighted", Reason: `url "github.com" is missing a scheme, like https://github.com (see WithDefaultScheme)`},
+	}
+	for name, build := range map[string]func() (http.HandlerFunc, error){
+		"yaml": func() (http.HandlerFunc, error) { return YAMLHandler(yamlData, urlshorttest.Fallback()) },
+		"json": func() (http.HandlerFunc, error) { return JSONHandler(jsonData, urlshorttest.Fallback()) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := build()
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("got %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(ve.Issues, want) {
+				t.Errorf("got issues %q\nwant %q", ve.Issues, want)
+			}
+			wantError(t, err, `urlshort: 4 invalid entries; /relative: url "/somewhere" is not absolute; /ftp: `)
+		})
+	}
+}
+
+func TestDestinationIssue(t *testing.T) {
+	tests := []struct {
+		name string
+		dest string
+		opts []Option
+		want string
+	}{
+		{"https", "https://github.com/x?y=1", nil, ""},
+		{"http", "http://example.com", nil, ""},
+		{"scheme case", "HTTPS://example.com", nil, ""},
+		{"parse error", "https://exa mple.com", nil, `invalid url "https://exa mple.com"`},
+		{"looks like a host", "example.com/x", nil, `url "example.com/x" is missing a scheme, like https://example.com/x`},
+		{"relative", "/internal", nil, `url "/internal" is not absolute`},
+		{"relative allowed", "/internal", []Option{WithRelativeDestinations()}, ""},
+		{"absolute with relative allowed", "ftp://example.com", []Option{WithRelativeDestinations()}, "must use one of the schemes http, https"},
+		{"scheme not allowed", "http://example.com", []Option{WithAllowedSchemes("https")}, `url "http://example.com" must use one of the schemes https`},
+		{"other scheme allowed", "mailto://team@example.com", []Option{WithAllowedSchemes("https", "mailto")}, ""},
+		{"javascript", "javascript:alert(1)", nil, "must use one of the schemes"},
+		{"no host", "https:///path", nil, "is missing a host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := destinationIssue(tt.dest, mustConfig(tt.opts))
+			if tt.want == "" {
+				if got != "" {
+					t.Errorf("got %q, want no issue", got)
+				}
+				return
+			}
+			wantError(t, errors.New(got), tt.want)
+		})
+	}
+}
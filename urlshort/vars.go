@@ -0,0 +1,109 @@
+package urlshort
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// yamlDoc is the YAML format with variables:
+//
+//	vars:
+//	  base: https://intranet.example.com
+//	redirects:
+//	  - path: /onboarding
+//	    url: ${base}/wiki/Onboarding
+//
+// Files can include others, see YAMLFileHandler.
+type yamlDoc struct {
+	Include   []string          `yaml:"include"`
+	Vars      map[string]string `yaml:"vars"`
+	Redirects []pathUrl         `yaml:"redirects"`
+}
+
+// varRef matches ${name} and the escaped form $${name}
+var varRef = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// errIncludeNeedsFile is returned for an include: in YAML that
+// wasn't read from a file, there is nothing to resolve it against
+var errIncludeNeedsFile = errors.New("urlshort: include only works in files, use YAMLFileHandler")
+
+// unmarshalYAML decodes either a plain list of entries or a yamlDoc,
+// whose variables are then filled into the urls. unmarshal is
+// yaml.Unmarshal or yaml.UnmarshalStrict.
+func unmarshalYAML(data []byte, unmarshal func([]byte, interface{}) error) ([]pathUrl, error) {
+	pathUrls, includes, err := unmarshalYAMLFile(data, unmarshal)
+	if err == nil && len(includes) > 0 {
+		return nil, errIncludeNeedsFile
+	}
+	return pathUrls, err
+}
+
+// unmarshalYAMLFile is unmarshalYAML that also returns the files
+// data includes instead of rejecting them
+func unmarshalYAMLFile(data []byte, unmarshal func([]byte, interface{}) error) ([]pathUrl, []string, error) {
+	doc, isDoc, err := decodeYAML(data, unmarshal)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isDoc {
+		if err := expandVars(doc.Redirects, doc.Vars); err != nil {
+			return nil, nil, err
+		}
+	}
+	return doc.Redirects, doc.Include, nil
+}
+
+// decodeYAML decodes data without filling in variables. isDoc
+// reports whether data is a yamlDoc rather than a plain list.
+func decodeYAML(data []byte, unmarshal func([]byte, interface{}) error) (doc yamlDoc, isDoc bool, err error) {
+	var probe interface{}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return yamlDoc{}, false, err
+	}
+	if _, ok := probe.(map[interface{}]interface{}); !ok {
+		err := unmarshal(data, &doc.Redirects)
+		return doc, false, err
+	}
+	err = unmarshal(data, &doc)
+	return doc, true, err
+}
+
+// expandVars replaces ${name} in the urls of every entry with the
+// value of vars[name]. All undefined variables are reported together
+// in a *ValidationError.
+func expandVars(pathUrls []pathUrl, vars map[string]string) error {
+	var issues []ValidationIssue
+	expand := func(pu pathUrl, s string) string {
+		return varRef.ReplaceAllStringFunc(s, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			name := ref[2 : len(ref)-1]
+			value, ok := vars[name]
+			if !ok {
+				issues = append(issues, ValidationIssue{Path: pu.Path, Reason: fmt.Sprintf("undefined variable ${%s}", name)})
+			}
+			return value
+		})
+	}
+
+	for i := range pathUrls {
+		pu := &pathUrls[i]
+		pu.URL = expand(*pu, pu.URL)
+		pu.InactiveURL = expand(*pu, pu.InactiveURL)
+		for j := range pu.URLs {
+			pu.URLs[j].URL = expand(*pu, pu.URLs[j].URL)
+		}
+		pu.mapDestinations(func(dest string) string {
+			return expand(*pu, dest)
+		})
+	}
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package urlshort
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const varsEntries = `
+vars:
+  base: https://intranet.example.com
+redirects:
+  - path: /onboarding
+    url: ${base}/wiki/Onboarding
+  - path: /twice
+    url: ${base}/a?next=${base}/b
+  - path: /escaped
+    url: https://example.com/$${base}
+  - path: /plain
+    url: https://example.com/$base
+`
+
+func TestVars(t *testing.T) {
+	h, err := YAMLHandler([]byte(varsEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/onboarding", Location: "https://intranet.example.com/wiki/Onboarding", Code: http.StatusFound},
+		{Path: "/twice", Location: "https://intranet.example.com/a?next=https://intranet.example.com/b", Code: http.StatusFound},
+		{Path: "/escaped", Location: "https://example.com/${base}", Code: http.StatusFound},
+		{Path: "/plain", Location: "https://example.com/$base", Code: http.StatusFound},
+	})
+
+	// a document without vars works, a plain list isn't expanded
+	h, err = YAMLHandler([]byte("redirects:\n  - path: /gh\n    url: https://github.com\n"), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"base": "https://example.com", "empty": ""}
+	pathUrls := []pathUrl{{
+		Path:        "/all",
+		URL:         "${base}/url",
+		InactiveURL: "${base}/ended",
+		URLs:        []weightedURL{{URL: "${base}/a", Weight: 1}},
+		Geo:         map[string]string{"de": "${base}/de"},
+		Lang:        map[string]string{"fr": "${base}/fr"},
+		Device:      map[string]string{"ios": "${base}/ios${empty}"},
+	}}
+	geo := pathUrls[0].Geo
+	if err := expandVars(pathUrls, vars); err != nil {
+		t.Fatalf("expandVars: %v", err)
+	}
+	want := pathUrl{
+		Path:        "/all",
+		URL:         "https://example.com/url",
+		InactiveURL: "https://example.com/ended",
+		URLs:        []weightedURL{{URL: "https://example.com/a", Weight: 1}},
+		Geo:         map[string]string{"de": "https://example.com/de"},
+		Lang:        map[string]string{"fr": "https://example.com/fr"},
+		Device:      map[string]string{"ios": "https://example.com/ios"},
+	}
+	if !reflect.DeepEqual(pathUrls[0], want) {
+		t.Errorf("got %+v\nwant %+v", pathUrls[0], want)
+	}
+	// the maps are copies
+	if geo["de"] != "${base}/de" {
+		t.Errorf("the original map was changed: %v", geo)
+	}
+}
+
+func TestVarsErrors(t *testing.T) {
+	pathUrls := []pathUrl{
+		{Path: "/a", URL: "${base}/${missing}"},
+		{Path: "/b", URL: "${}", Lang: map[string]string{"de": "${other}"}},
+	}
+	err := expandVars(pathUrls, map[string]string{"base": "https://example.com"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v, want a *ValidationError", err)
+	}
+	want := []ValidationIssue{
+		{Path: "/a", Reason: "undefined variable ${missing}"},
+		{Path: "/b", Reason: "undefined variable ${}"},
+		{Path: "/b", Reason: "undefined variable ${other}"},
+	}
+	if !reflect.DeepEqual(verr.Issues, want) {
+		t.Errorf("got %+v\nwant %+v", verr.Issues, want)
+	}
+
+	_, err = YAMLHandler([]byte("vars:\n  base: https://example.com\nredirects:\n  - path: /x\n    url: ${bsae}/x\n"), nil)
+	wantError(t, err, "undefined variable ${bsae}")
+	_, err = YAMLHandler([]byte("vars: [1]\nredirects: []\n"), nil)
+	if err == nil {
+		t.Error("vars as a list: no error")
+	}
+}
@@ -0,0 +1,182 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithPollInterval sets how often file backed handlers like
+// WatchingYAMLHandler check their file for changes. The default
+// is one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.pollInterval = d
+	}
+}
+
+// WatchedHandler serves redirects from a YAML file and reloads
+// them whenever the file or one it includes changes.
+type WatchedHandler struct {
+	Handler
+
+	path string
+
+	mu sync.Mutex
+	// files are the file and its includes as they were last read
+	files   map[string]fileState
+	lastErr error
+	onError func(error)
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchingYAMLHandler parses the YAML file at path (in the format
+// YAMLFileHandler accepts, includes and all) and serves it like
+// YAMLHandler does. The file and the files it includes are polled
+// for changes and the mappings are replaced as soon as the new
+// content parses cleanly.
+//
+// A broken edit never takes down the mappings that are being
+// served, the parse error is reported by LastError instead. Only
+// the first parse has to succeed, otherwise an error is returned.
+// Call Close to stop watching the file.
+func WatchingYAMLHandler(path string, fallback http.Handler, opts ...Option) (*WatchedHandler, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	fallback, err = fallbackFor(fallback, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	wh := &WatchedHandler{
+		Handler: Handler{fallback: fallback, cfg: cfg, source: "file"},
+		path:    path,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := wh.reload(); err != nil {
+		return nil, err
+	}
+
+	go wh.watch()
+	return wh, nil
+}
+
+// OnError registers fn to be called with every error that happens
+// while reloading the file in the background.
+func (wh *WatchedHandler) OnError(fn func(error)) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.onError = fn
+}
+
+// LastError returns the error of the most recent reload attempt,
+// or nil if it succeeded.
+func (wh *WatchedHandler) LastError() error {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	return wh.lastErr
+}
+
+// Close stops watching the file. The handler keeps serving the
+// last mappings afterwards.
+func (wh *WatchedHandler) Close() error {
+	wh.closeOnce.Do(func() {
+		close(wh.stop)
+		<-wh.done
+	})
+	return nil
+}
+
+// watch polls the file until Close is called
+func (wh *WatchedHandler) watch() {
+	defer close(wh.done)
+
+	ticker := time.NewTicker(wh.cfg.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-wh.stop:
+			return
+		case <-ticker.C:
+			if !wh.changed() {
+				continue
+			}
+			err := wh.reload()
+
+			wh.mu.Lock()
+			wh.lastErr = err
+			onError := wh.onError
+			wh.mu.Unlock()
+
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// fileState is what changed compares to tell that a file changed
+type fileState struct {
+	modTime time.Time
+	// size is -1 for a file that couldn't be stat'ed
+	size int64
+}
+
+// statFile returns the current state of the file at path
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{size: -1}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// changed reports whether the file or one of its includes looks
+// different from the last time they were read. A file that can't
+// be stat'ed counts as changed so the error gets reported, unless it
+// was already missing then.
+func (wh *WatchedHandler) changed() bool {
+	wh.mu.Lock()
+	files := wh.files
+	wh.mu.Unlock()
+
+	if _, err := os.Stat(wh.path); err != nil {
+		return true
+	}
+	for path, last := range files {
+		if now := statFile(path); !now.modTime.Equal(last.modTime) || now.size != last.size {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads and parses the file and its includes and swaps in the
+// new mappings
+func (wh *WatchedHandler) reload() error {
+	// remember the files even if they're broken, so they're not
+	// parsed again until fixed. Each is stat'ed before it is read, a
+	// write in between is caught by the next poll.
+	files := make(map[string]fileState)
+	pathUrls, err := parseYAMLFiles(wh.path, wh.cfg, func(path string) {
+		files[path] = statFile(path)
+	})
+
+	wh.mu.Lock()
+	wh.files = files
+	wh.mu.Unlock()
+
+	if err != nil {
+		// the errors name the file they are about
+		return err
+	}
+	return wh.Reload(pathUrls)
+}
@@ -0,0 +1,116 @@
+package urlshort
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// writeFile writes data to name in dir and returns its path
+func writeFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWatchingYAMLHandler(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: https://github.com\n")
+	wh, err := WatchingYAMLHandler(path, urlshorttest.Fallback(), WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchingYAMLHandler: %v", err)
+	}
+	defer wh.Close()
+	var mu sync.Mutex
+	var reported []error
+	wh.OnError(func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{{Path: "/gh", Location: "https://github.com", Code: http.StatusFound}})
+
+	writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: https://github.com/nils\n- path: /go\n  url: https://go.dev\n")
+	eventually(t, "the edit", func() bool { return get(wh, "/go").Code == http.StatusFound })
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+	})
+	if err := wh.LastError(); err != nil {
+		t.Errorf("LastError after a good edit: %v", err)
+	}
+
+	// a broken edit keeps the mappings
+	writeFile(t, dir, "paths.yaml", "- path: /gh\n  url: [broken\n")
+	eventually(t, "the broken edit", func() bool { return wh.LastError() != nil })
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com/nils", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+	})
+	mu.Lock()
+	if len(reported) == 0 {
+		t.Error("OnError wasn't called for the broken edit")
+	}
+	mu.Unlock()
+
+	// fixing it clears the error
+	writeFile(t, dir, "paths.yaml", "- path: /gl\n  url: https://gitlab.com\n")
+	eventually(t, "the fix", func() bool { return get(wh, "/gl").Code == http.StatusFound })
+	if err := wh.LastError(); err != nil {
+		t.Errorf("LastError after the fix: %v", err)
+	}
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{{Path: "/gh"}})
+
+	// nothing is reloaded after Close
+	wh.Close()
+	writeFile(t, dir, "paths.yaml", "- path: /closed\n  url: https://closed.example.com\n")
+	time.Sleep(50 * time.Millisecond)
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{
+		{Path: "/gl", Location: "https://gitlab.com", Code: http.StatusFound},
+		{Path: "/closed"},
+	})
+}
+
+func TestWatchingYAMLHandlerIncludes(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "paths.yaml", "include:\n  - team.yaml\nredirects:\n  - path: /gh\n    url: https://github.com\n")
+	writeFile(t, dir, "team.yaml", "- path: /team\n  url: https://team.example.com\n")
+	wh, err := WatchingYAMLHandler(path, urlshorttest.Fallback(), WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchingYAMLHandler: %v", err)
+	}
+	defer wh.Close()
+	urlshorttest.TableTest(t, wh, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/team", Location: "https://team.example.com", Code: http.StatusFound},
+	})
+
+	// an edit of the included file alone is picked up
+	writeFile(t, dir, "team.yaml", "- path: /team\n  url: https://team.example.com/v2\n")
+	eventually(t, "the include edit", func() bool {
+		return get(wh, "/team").Header().Get("Location") == "https://team.example.com/v2"
+	})
+}
+
+func TestWatchingYAMLHandlerErrors(t *testing.T) {
+	dir := t.TempDir()
+	_, err := WatchingYAMLHandler(filepath.Join(dir, "missing.yaml"), urlshorttest.Fallback())
+	if err == nil {
+		t.Error("WatchingYAMLHandler of a missing file: got no error")
+	}
+	path := writeFile(t, dir, "broken.yaml", "- path: /gh\n  url: [broken\n")
+	_, err = WatchingYAMLHandler(path, urlshorttest.Fallback())
+	if err == nil {
+		t.Error("WatchingYAMLHandler of a broken file: got no error")
+	}
+	_, err = WatchingYAMLHandler(path, urlshorttest.Fallback(), WithPollInterval(0))
+	wantError(t, err, "poll interval")
+}
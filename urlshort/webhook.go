@@ -0,0 +1,274 @@
+package urlshort
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaults of WebhookOptions
+const (
+	defaultWebhookQueue   = 1024
+	defaultWebhookBackoff = 500 * time.Millisecond
+	defaultWebhookTimeout = 5 * time.Second
+	// maxWebhookBackoff caps the doubling of the backoff
+	maxWebhookBackoff = 30 * time.Second
+	// maxEventUserAgent is how much of the user agent an event keeps
+	maxEventUserAgent = 256
+)
+
+// WebhookOptions changes how a Webhook delivers its events. The zero
+// value is usable, it sends every event once.
+type WebhookOptions struct {
+	// QueueSize is how many events can wait to be sent, further
+	// events are dropped. The default is 1024.
+	QueueSize int
+	// MaxRetries is how often a failed event is sent again before it
+	// is given up on, zero never retries
+	MaxRetries int
+	// Backoff is the wait before the first retry, it doubles for
+	// every further one. The default is 500ms.
+	Backoff time.Duration
+	// Timeout limits a single attempt when Client isn't set, the
+	// default is 5s
+	Timeout time.Duration
+	// Client sends the events, e.g. for custom TLS settings
+	Client *http.Client
+	// Headers are added to every request, e.g. for authentication
+	Headers map[string]string
+}
+
+// WebhookEvent is the JSON body posted for a redirect
+type WebhookEvent struct {
+	Path        string    `json:"path"`
+	Destination string    `json:"destination"`
+	Timestamp   time.Time `json:"timestamp"`
+	Referrer    string    `json:"referrer,omitempty"`
+	// UserAgent is cut to its first 256 bytes
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// Webhook posts a WebhookEvent to an endpoint for the redirects its
+// filter accepts, WithWebhook starts one. Events are queued and sent by a goroutine in the background, so a slow
+// or broken endpoint never slows down requests: failed events are
+// retried with exponential backoff for 5xx responses, 429 and
+// network errors, and events that don't fit in the queue are
+// dropped and counted.
+type Webhook struct {
+	endpoint string
+	filter   func(path string) bool
+	opts     WebhookOptions
+	client   *http.Client
+
+	// mu guards closing events against sends
+	mu     sync.RWMutex
+	closed bool
+	events chan WebhookEvent
+	done   chan struct{}
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// newWebhook starts a Webhook posting to endpoint
+func newWebhook(endpoint string, filter func(path string) bool, opts WebhookOptions) (*Webhook, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("urlshort: webhook url %q must be an absolute http or https URL", endpoint)
+	}
+	if opts.QueueSize < 0 || opts.MaxRetries < 0 || opts.Backoff < 0 || opts.Timeout < 0 {
+		return nil, errors.New("urlshort: webhook options can't be negative")
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = defaultWebhookQueue
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = defaultWebhookBackoff
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultWebhookTimeout
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: opts.Timeout}
+	}
+
+	wh := &Webhook{
+		endpoint: endpoint,
+		filter:   filter,
+		opts:     opts,
+		client:   client,
+		events:   make(chan WebhookEvent, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go wh.run()
+	return wh, nil
+}
+
+// WithWebhook posts a WebhookEvent to endpoint, an absolute http or
+// https URL, for every redirect whose request path filter accepts,
+// after the redirect was written. A nil filter sends events for all
+// of them, pixel entries count as redirects. Several webhooks can
+// be attached.
+//
+// The Webhook starts sending when WithWebhook is called, handlers
+// built with the same option share it. Use Handler.Webhooks to get
+// it, for its counters, and Handler.CloseWebhooks on shutdown so no
+// queued events are lost. Handlers that are only an
+// http.HandlerFunc, like the one of MapHandler, can't be closed,
+// build them with New instead.
+func WithWebhook(endpoint string, filter func(path string) bool, opts WebhookOptions) Option {
+	wh, err := newWebhook(endpoint, filter, opts)
+	return func(c *config) {
+		if err != nil {
+			c.webhookErr = err
+			return
+		}
+		c.webhooks = append(c.webhooks, wh)
+	}
+}
+
+// Webhooks returns the webhooks of WithWebhook that h sends events
+// to
+func (h *Handler) Webhooks() []*Webhook {
+	return append([]*Webhook(nil), h.cfg.webhooks...)
+}
+
+// CloseWebhooks closes the webhooks of h, see Webhook.Close. Events
+// for redirects after it are dropped.
+func (h *Handler) CloseWebhooks() error {
+	for _, wh := range h.cfg.webhooks {
+		wh.Close()
+	}
+	return nil
+}
+
+// Dropped returns how many events didn't fit in the queue
+func (wh *Webhook) Dropped() int64 {
+	return wh.dropped.Load()
+}
+
+// Failed returns how many events were given up on after the retries
+func (wh *Webhook) Failed() int64 {
+	return wh.failed.Load()
+}
+
+// Close stops taking events and returns once the queued ones were
+// sent or given up on, retries included. Events for redirects after
+// Close are dropped.
+func (wh *Webhook) Close() error {
+	wh.mu.Lock()
+	if !wh.closed {
+		wh.closed = true
+		close(wh.events)
+	}
+	wh.mu.Unlock()
+	<-wh.done
+	return nil
+}
+
+// notify queues an event for the redirect of r to dest, if the
+// filter wants it. It never blocks.
+func (wh *Webhook) notify(r *http.Request, path, dest string, now time.Time) {
+	if wh.filter != nil && !wh.filter(path) {
+		return
+	}
+	ev := WebhookEvent{
+		Path:        path,
+		Destination: dest,
+		Timestamp:   now.UTC(),
+		Referrer:    r.Referer(),
+		UserAgent:   truncateUTF8(r.UserAgent(), maxEventUserAgent),
+	}
+
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	if wh.closed {
+		wh.dropped.Add(1)
+		return
+	}
+	select {
+	case wh.events <- ev:
+	default:
+		wh.dropped.Add(1)
+	}
+}
+
+// run sends the queued events until Close
+func (wh *Webhook) run() {
+	defer close(wh.done)
+	for ev := range wh.events {
+		if !wh.deliver(ev) {
+			wh.failed.Add(1)
+		}
+	}
+}
+
+// deliver posts ev, retrying as the options allow, and reports
+// whether the endpoint took it
+func (wh *Webhook) deliver(ev WebhookEvent) bool {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return false
+	}
+	backoff := wh.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := wh.post(body)
+		if err == nil {
+			return true
+		}
+		if !retry || attempt == wh.opts.MaxRetries {
+			return false
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxWebhookBackoff {
+			backoff = maxWebhookBackoff
+		}
+	}
+}
+
+// post sends body once. retry reports whether a failure is worth
+// trying again.
+func (wh *Webhook) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, wh.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range wh.opts.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("urlshort: webhook answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("urlshort: webhook answered %s", resp.Status)
+	}
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,248 @@
+package urlshort
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+// webhookReceiver answers the posts of a webhook with the statuses
+// of script in turn, 200 once it runs out, and keeps the events
+// that were answered with a 2xx
+type webhookReceiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	script   []int
+	attempts int
+	events   []WebhookEvent
+	headers  []http.Header
+}
+
+func newWebhookReceiver(t *testing.T, script ...int) *webhookReceiver {
+	rcv := &webhookReceiver{script: script}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		status := http.StatusOK
+		if rcv.attempts < len(rcv.script) {
+			status = rcv.script[rcv.attempts]
+		}
+		rcv.attempts++
+		if status < 300 {
+			var ev WebhookEvent
+			if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+				t.Errorf("decoding the event: %v", err)
+			}
+			rcv.events = append(rcv.events, ev)
+			rcv.headers = append(rcv.headers, r.Header.Clone())
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+// received returns the number of attempts and the events so far
+func (rcv *webhookReceiver) received() (int, []WebhookEvent) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return rcv.attempts, append([]WebhookEvent(nil), rcv.events...)
+}
+
+func TestWebhookEvents(t *testing.T) {
+	rcv := newWebhookReceiver(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	h, err := New(FromYAML([]byte(pixelEntries+"- path: /promo/spring\n  url: https://shop.example.com/spring\n")), urlshorttest.Fallback(),
+		WithClock(func() time.Time { return now }),
+		WithWebhook(rcv.URL+"/events", func(path string) bool { return !strings.HasPrefix(path, "/gh") },
+			WebhookOptions{Headers: map[string]string{"Authorization": "Bearer hook"}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// a user agent cut in the middle of a rune loses the whole rune
+	ua := strings.Repeat("a", maxEventUserAgent-1) + "é and more"
+	for _, path := range []string{"/promo/spring", "/gh", "/missing", "/open/spring"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Referer", "https://mail.example.com/inbox")
+		req.Header.Set("User-Agent", ua)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := h.CloseWebhooks(); err != nil {
+		t.Fatalf("CloseWebhooks: %v", err)
+	}
+
+	_, events := rcv.received()
+	want := []WebhookEvent{
+		{Path: "/promo/spring", Destination: "https://shop.example.com/spring", Timestamp: now.UTC(),
+			Referrer: "https://mail.example.com/inbox", UserAgent: strings.Repeat("a", maxEventUserAgent-1)},
+		// pixel entries count as redirects, misses and filtered
+		// paths send nothing
+		{Path: "/open/spring", Timestamp: now.UTC(), Referrer: "https://mail.example.com/inbox", UserAgent: strings.Repeat("a", maxEventUserAgent-1)},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got events %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+	for _, header := range rcv.headers {
+		if header.Get("Content-Type") != "application/json" || header.Get("Authorization") != "Bearer hook" {
+			t.Errorf("got headers %v", header)
+		}
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   []int
+		retries  int
+		attempts int
+		failed   int64
+	}{
+		{"healthy", nil, 3, 1, 0},
+		{"flaky then healthy", []int{503, 502, 500}, 3, 4, 0},
+		{"rate limited", []int{429}, 1, 2, 0},
+		{"never healthy", []int{500, 500, 500, 500}, 2, 3, 1},
+		{"no retries", []int{503}, 0, 1, 1},
+		// a client error won't get better by retrying
+		{"client error", []int{400}, 3, 1, 1},
+		{"gone", []int{410}, 3, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := newWebhookReceiver(t, tt.script...)
+			h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(),
+				WithWebhook(rcv.URL, nil, WebhookOptions{MaxRetries: tt.retries, Backoff: time.Millisecond}))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+			h.CloseWebhooks()
+
+			attempts, events := rcv.received()
+			if attempts != tt.attempts {
+				t.Errorf("got %d attempts, want %d", attempts, tt.attempts)
+			}
+			if delivered := int64(len(events)); delivered != 1-tt.failed {
+				t.Errorf("got %d events delivered", delivered)
+			}
+			if got := h.Webhooks()[0].Failed(); got != tt.failed {
+				t.Errorf("Failed: got %d, want %d", got, tt.failed)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	rcv := newWebhookReceiver(t, 503, 503, 503)
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(),
+		WithWebhook(rcv.URL, nil, WebhookOptions{MaxRetries: 3, Backoff: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	start := time.Now()
+	get(h, "/gh")
+	h.CloseWebhooks()
+	// 20ms, 40ms and 80ms between the attempts
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 attempts took %v, the backoff doesn't double", elapsed)
+	}
+	if attempts, events := rcv.received(); attempts != 4 || len(events) != 1 {
+		t.Errorf("got %d attempts and %d events", attempts, len(events))
+	}
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	posted := 0
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		mu.Lock()
+		posted++
+		mu.Unlock()
+	}))
+	defer slow.Close()
+
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(),
+		WithWebhook(slow.URL, nil, WebhookOptions{QueueSize: 2}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// the endpoint hangs, requests go on without waiting for it
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("20 requests took %v with a hanging webhook", elapsed)
+	}
+
+	wh := h.Webhooks()[0]
+	// one event is being sent, two are queued
+	if got := wh.Dropped(); got < 17 || got > 18 {
+		t.Errorf("Dropped: got %d, want 17 or 18", got)
+	}
+	close(release)
+	h.CloseWebhooks()
+	mu.Lock()
+	defer mu.Unlock()
+	if int64(posted)+wh.Dropped() != 20 {
+		t.Errorf("posted %d and dropped %d of 20 events", posted, wh.Dropped())
+	}
+}
+
+func TestWebhookClose(t *testing.T) {
+	rcv := newWebhookReceiver(t, 503)
+	h, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), urlshorttest.Fallback(),
+		WithWebhook(rcv.URL, nil, WebhookOptions{MaxRetries: 1, Backoff: 50 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		get(h, "/gh")
+	}
+	// Close waits for the queue, retries included
+	h.CloseWebhooks()
+	if attempts, events := rcv.received(); attempts != 6 || len(events) != 5 {
+		t.Errorf("after Close: %d attempts and %d events, want 6 and 5", attempts, len(events))
+	}
+
+	// events after it are dropped, closing again is fine
+	urlshorttest.AssertRedirect(t, h, "/gh", "https://github.com", http.StatusFound)
+	if got := h.Webhooks()[0].Dropped(); got != 1 {
+		t.Errorf("Dropped after Close: got %d, want 1", got)
+	}
+	h.CloseWebhooks()
+}
+
+func TestWithWebhookErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		opts     WebhookOptions
+		want     string
+	}{
+		{"relative", "/events", WebhookOptions{}, `webhook url "/events" must be an absolute http or https URL`},
+		{"scheme", "ftp://hooks.example.com", WebhookOptions{}, "must be an absolute http or https URL"},
+		{"no host", "https://", WebhookOptions{}, "must be an absolute http or https URL"},
+		{"negative queue", "https://hooks.example.com", WebhookOptions{QueueSize: -1}, "webhook options can't be negative"},
+		{"negative retries", "https://hooks.example.com", WebhookOptions{MaxRetries: -1}, "webhook options can't be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(FromMap(map[string]string{"/gh": "https://github.com"}), nil, WithWebhook(tt.endpoint, nil, tt.opts))
+			wantError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package urlshort
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// stickyCookie keeps returning visitors on the destination they got
+// the first time
+const stickyCookie = "cookie"
+
+// stickyMaxAge is how long a sticky destination is remembered
+const stickyMaxAge = 30 * 24 * time.Hour
+
+// weightedURL is one destination of an A/B entry, it gets weight
+// out of the sum of all weights of the entry's requests
+type weightedURL struct {
+	URL    string `yaml:"url" json:"url" toml:"url" xml:"url"`
+	Weight int    `yaml:"weight" json:"weight" toml:"weight" xml:"weight"`
+}
+
+// checkWeighted validates the urls list of pu. A single destination
+// becomes the plain url, so it behaves exactly like one.
+func checkWeighted(pu *pathUrl) error {
+	switch pu.Sticky {
+	case "", stickyCookie:
+	default:
+		return fmt.Errorf("urlshort: %s: unknown sticky mode %q (use cookie)", pu.Path, pu.Sticky)
+	}
+	if len(pu.URLs) == 0 {
+		return nil
+	}
+	// an entry that was prepared before has the first url as its url
+	if pu.URL != "" && pu.URL != pu.URLs[0].URL {
+		return fmt.Errorf("urlshort: %s: use either url or urls, not both", pu.Path)
+	}
+	for i, wu := range pu.URLs {
+		if wu.URL == "" {
+			return fmt.Errorf("urlshort: %s: destination %d is missing a url", pu.Path, i)
+		}
+		if wu.Weight <= 0 {
+			return fmt.Errorf("urlshort: %s: destination %d has weight %d, weights must be positive", pu.Path, i, wu.Weight)
+		}
+	}
+
+	// the plain url is what everything that doesn't know about
+	// weights, like reverse lookups, sees
+	pu.URL = pu.URLs[0].URL
+	if len(pu.URLs) == 1 {
+		pu.URLs = nil
+	}
+	return nil
+}
+
+// weightedPicker chooses destinations, each handler has its own
+// source so handlers don't contend on the global one
+type weightedPicker struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newWeightedPicker() *weightedPicker {
+	return &weightedPicker{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// pick returns the index of a destination, chosen by weight
+func (wp *weightedPicker) pick(urls []weightedURL) int {
+	total := 0
+	for _, wu := range urls {
+		total += wu.Weight
+	}
+	wp.mu.Lock()
+	n := wp.rnd.Intn(total)
+	wp.mu.Unlock()
+
+	for i, wu := range urls {
+		if n < wu.Weight {
+			return i
+		}
+		n -= wu.Weight
+	}
+	return len(urls) - 1
+}
+
+// chooseDestination picks the destination of a weighted entry for
+// r. With sticky cookies a visitor who has been here before gets the
+// same destination again, as long as the entry still has it.
+func chooseDestination(w http.ResponseWriter, r *http.Request, pu pathUrl, cfg *config) string {
+	name := stickyCookieName(pu.Path)
+	if pu.Sticky == stickyCookie {
+		if c, err := r.Cookie(name); err == nil {
+			if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(pu.URLs) {
+				return pu.URLs[i].URL
+			}
+		}
+	}
+
+	i := cfg.picker.pick(pu.URLs)
+	if pu.Sticky == stickyCookie {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    strconv.Itoa(i),
+			Path:     "/",
+			MaxAge:   int(stickyMaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return pu.URLs[i].URL
+}
+
+// stickyCookieName is the cookie for the entry with path, paths
+// can contain characters cookie names can't
+func stickyCookieName(path string) string {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return fmt.Sprintf("urlshort_%08x", h.Sum32())
+}
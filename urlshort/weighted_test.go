@@ -0,0 +1,129 @@
+package urlshort
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+const weightedEntries = `
+- path: /ab
+  urls:
+    - url: https://a.example.com
+      weight: 3
+    - url: https://b.example.com
+      weight: 1
+- path: /sticky
+  sticky: cookie
+  urls:
+    - url: https://a.example.com
+      weight: 1
+    - url: https://b.example.com
+      weight: 1
+- path: /one
+  urls:
+    - url: https://one.example.com
+      weight: 5
+`
+
+func TestWeightedPick(t *testing.T) {
+	wp := &weightedPicker{rnd: rand.New(rand.NewSource(1))}
+	urls := []weightedURL{{"https://a.example.com", 3}, {"https://b.example.com", 1}, {"https://c.example.com", 0}}
+	const n = 10000
+	counts := make([]int, len(urls))
+	for i := 0; i < n; i++ {
+		counts[wp.pick(urls)]++
+	}
+	if counts[2] != 0 {
+		t.Errorf("a destination of weight 0 was picked %d times", counts[2])
+	}
+	// 3:1 with plenty of room
+	if counts[0] < n*70/100 || counts[0] > n*80/100 {
+		t.Errorf("got %v, want about 3:1", counts)
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	h, err := YAMLHandler([]byte(weightedEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	seen := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		res := get(h, "/ab")
+		if res.Code != http.StatusFound {
+			t.Fatalf("GET /ab: got %d", res.Code)
+		}
+		seen[res.Header().Get("Location")]++
+		if len(res.Result().Cookies()) != 0 {
+			t.Fatal("an entry that isn't sticky set a cookie")
+		}
+	}
+	if len(seen) != 2 || seen["https://a.example.com"] <= seen["https://b.example.com"] {
+		t.Errorf("got %v", seen)
+	}
+
+	// a single destination is a plain url
+	urlshorttest.AssertRedirect(t, h, "/one", "https://one.example.com", http.StatusFound)
+}
+
+func TestWeightedSticky(t *testing.T) {
+	h, err := YAMLHandler([]byte(weightedEntries), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("YAMLHandler: %v", err)
+	}
+	res := get(h, "/sticky")
+	cookies := res.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != stickyCookieName("/sticky") || !cookies[0].HttpOnly {
+		t.Fatalf("got cookies %v", cookies)
+	}
+	first := res.Header().Get("Location")
+
+	// the cookie keeps the visitor on their destination
+	for i := 0; i < 20; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/sticky", nil)
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Location"); got != first {
+			t.Fatalf("request %d: got %s, want %s", i, got, first)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Fatal("the cookie was set again")
+		}
+	}
+
+	// a cookie that doesn't fit the entry is replaced
+	for _, value := range []string{"7", "-1", "x"} {
+		req := httptest.NewRequest(http.MethodGet, "/sticky", nil)
+		req.AddCookie(&http.Cookie{Name: stickyCookieName("/sticky"), Value: value})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound || len(rec.Result().Cookies()) != 1 {
+			t.Errorf("cookie %q: got %d with cookies %v", value, rec.Code, rec.Result().Cookies())
+		}
+	}
+}
+
+func TestWeightedErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"url and urls", "- path: /ab\n  url: https://x.example.com\n  urls:\n    - url: https://a.example.com\n      weight: 1\n",
+			"urlshort: /ab: use either url or urls, not both"},
+		{"no url", "- path: /ab\n  urls:\n    - weight: 1\n", "urlshort: /ab: destination 0 is missing a url"},
+		{"no weight", "- path: /ab\n  urls:\n    - url: https://a.example.com\n", "urlshort: /ab: destination 0 has weight 0, weights must be positive"},
+		{"negative weight", "- path: /ab\n  urls:\n    - url: https://a.example.com\n      weight: 1\n    - url: https://b.example.com\n      weight: -2\n",
+			"destination 1 has weight -2"},
+		{"sticky mode", "- path: /ab\n  sticky: ip\n  url: https://a.example.com\n", `urlshort: /ab: unknown sticky mode "ip" (use cookie)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAMLHandler([]byte(tt.yaml), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}
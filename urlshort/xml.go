@@ -0,0 +1,53 @@
+package urlshort
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// XMLHandler works like YAMLHandler, but parses the mappings
+// from XML instead.
+//
+// XML is expected to be in the format:
+//
+//	<redirects>
+//	  <redirect>
+//	    <path>/some-path</path>
+//	    <url>https://www.some-url.com/demo</url>
+//	  </redirect>
+//	</redirects>
+//
+// Unknown elements are ignored. An error is returned for
+// invalid XML and for redirects missing a path or url.
+func XMLHandler(xmlBytes []byte, fallback http.Handler, opts ...Option) (http.HandlerFunc, error) {
+	cfg, err := newConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedXML, err := parseXML(xmlBytes)
+	if err != nil {
+		return nil, err
+	}
+	return entriesHandler(parsedXML, fallback, cfg)
+}
+
+// xmlDoc is the <redirects> root element
+type xmlDoc struct {
+	XMLName  xml.Name  `xml:"redirects"`
+	Redirect []pathUrl `xml:"redirect"`
+}
+
+func parseXML(data []byte) ([]pathUrl, error) {
+	var doc xmlDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("urlshort: invalid XML: %v", err)
+	}
+
+	if err := checkEntries(doc.Redirect); err != nil {
+		return nil, err
+	}
+
+	return doc.Redirect, nil
+}
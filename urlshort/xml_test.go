@@ -0,0 +1,57 @@
+package urlshort
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/NilsKaden/gophercises/urlshort/urlshorttest"
+)
+
+func TestXMLHandler(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<redirects>
+  <exported>2024-01-01</exported>
+  <redirect>
+    <path>/gh</path>
+    <url>https://github.com</url>
+    <owner>web team</owner>
+  </redirect>
+  <redirect>
+    <path>/go</path>
+    <url>https://go.dev</url>
+  </redirect>
+  <redirect>
+    <path>/docs</path>
+    <url>https://docs.example.com/?a=1&amp;b=2</url>
+  </redirect>
+</redirects>`
+	h, err := XMLHandler([]byte(data), urlshorttest.Fallback())
+	if err != nil {
+		t.Fatalf("XMLHandler: %v", err)
+	}
+	urlshorttest.TableTest(t, h, []urlshorttest.Case{
+		{Path: "/gh", Location: "https://github.com", Code: http.StatusFound},
+		{Path: "/go", Location: "https://go.dev", Code: http.StatusFound},
+		{Path: "/docs", Location: "https://docs.example.com/?a=1&b=2", Code: http.StatusFound},
+		{Path: "/owner"},
+	})
+}
+
+func TestXMLHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"malformed", `<redirects><redirect><path>/gh</path>`, "invalid XML"},
+		{"wrong root", `<links><redirect><path>/gh</path><url>https://github.com</url></redirect></links>`, "invalid XML"},
+		{"missing url", `<redirects><redirect><path>/gh</path></redirect></redirects>`, "(/gh) is missing a url"},
+		{"missing path", `<redirects><redirect><url>https://github.com</url></redirect></redirects>`, "missing a path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := XMLHandler([]byte(tt.data), urlshorttest.Fallback())
+			wantError(t, err, tt.want)
+		})
+	}
+}